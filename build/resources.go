@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/base64"
 	"flag"
 	"fmt"
 	"os"
@@ -9,14 +10,20 @@ import (
 	"strings"
 
 	templatev1 "github.com/openshift/api/template/v1"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/apis/managed/v1alpha1"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/policyexport"
 	"github.com/openshift/managed-cluster-validating-webhooks/pkg/syncset"
 	webhooks "github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/customresourcedefinitions"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/imageprovenance"
 	utils "github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
 	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 	admissionregv1 "k8s.io/api/admissionregistration/v1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"k8s.io/apimachinery/pkg/runtime"
@@ -53,15 +60,23 @@ const (
 )
 
 var (
-	listenPort    = flag.Int("port", 5000, "On which port should the Webhook binary listen? (Not the Service port)")
-	secretName    = flag.String("secretname", "webhook-cert", "Secret where TLS certs are created")
-	caBundleName  = flag.String("cabundlename", "webhook-cert", "ConfigMap where CA cert is created")
-	templateFile  = flag.String("syncsetfile", "", "Path to where the SelectorSyncSet template should be written")
-	packageDir    = flag.String("packagedir", "", "Path to where the package manifest and resources should be written")
-	replicas      = flag.Int("replicas", 2, "Number of replicas for Hypershift-based MCVW deployment")
-	excludes      = flag.String("exclude", "debug-hook", "Comma-separated list of webhook names to skip")
-	only          = flag.String("only", "", "Only include these comma-separated webhooks")
-	showHookNames = flag.Bool("showhooks", false, "Print registered webhook names and exit")
+	listenPort     = flag.Int("port", 5000, "On which port should the Webhook binary listen? (Not the Service port)")
+	secretName     = flag.String("secretname", "webhook-cert", "Secret where TLS certs are created")
+	caBundleName   = flag.String("cabundlename", "webhook-cert", "ConfigMap where CA cert is created")
+	templateFile   = flag.String("syncsetfile", "", "Path to where the SelectorSyncSet template should be written")
+	packageDir     = flag.String("packagedir", "", "Path to where the package manifest and resources should be written")
+	policyFile     = flag.String("policyfile", "", "Path to where ValidatingAdmissionPolicies for CEL-capable webhooks should be written")
+	gatekeeperFile = flag.String("gatekeeperfile", "", "Path to where Gatekeeper ConstraintTemplates and Constraints for CEL-capable webhooks should be written")
+	kyvernoFile    = flag.String("kyvernofile", "", "Path to where Kyverno ClusterPolicies for CEL-capable webhooks should be written")
+	replicas       = flag.Int("replicas", 2, "Number of replicas for Hypershift-based MCVW deployment")
+	hypershiftDir  = flag.String("hypershiftdir", "", "Path to where plain manifests for a single hosted control plane should be written")
+	hcpNamespace   = flag.String("hcpnamespace", "", "Hosted control plane namespace the -hypershiftdir manifests are rendered for")
+	serviceCAFile  = flag.String("servicecafile", "", "Path to the service CA the -hypershiftdir webhook configurations trust")
+	excludes       = flag.String("exclude", "debug-hook", "Comma-separated list of webhook names to skip")
+	only           = flag.String("only", "", "Only include these comma-separated webhooks")
+	showHookNames  = flag.Bool("showhooks", false, "Print registered webhook names and exit")
+	failPolicies   = flag.String("failurepolicies", "", "Comma-separated list of webhook=policy overriding the webhooks' failure policies, eg *=Fail for staging")
+	canaries       = flag.String("canaries", "", "Comma-separated list of webhook=true|false overriding which webhooks are canaries, eg podimagespec-mutation=false to promote it")
 
 	namespace = flag.String("namespace", "openshift-validation-webhook", "In what namespace should resources exist?")
 
@@ -133,6 +148,21 @@ func createRole() *rbacv1.Role {
 					"*",
 				},
 			},
+			{
+				APIGroups: []string{
+					"",
+				},
+				Resources: []string{
+					"configmaps",
+				},
+				Verbs: []string{
+					"get",
+					"list",
+					"watch",
+					"create",
+					"update",
+				},
+			},
 		},
 	}
 }
@@ -162,6 +192,62 @@ func createRoleBinding() *rbacv1.RoleBinding {
 	}
 }
 
+// createImageProvenanceRole returns the Role which lets imageprovenance-validation
+// read the customer's allowlist, and no other ConfigMap of openshift-config
+func createImageProvenanceRole() *rbacv1.Role {
+	return &rbacv1.Role{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Role",
+			APIVersion: rbacv1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      roleName,
+			Namespace: imageprovenance.AllowlistNamespace,
+		},
+		Rules: []rbacv1.PolicyRule{
+			{
+				APIGroups: []string{
+					"",
+				},
+				Resources: []string{
+					"configmaps",
+				},
+				ResourceNames: []string{
+					imageprovenance.AllowlistName,
+				},
+				Verbs: []string{
+					"get",
+				},
+			},
+		},
+	}
+}
+
+func createImageProvenanceRoleBinding() *rbacv1.RoleBinding {
+	return &rbacv1.RoleBinding{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "RoleBinding",
+			APIVersion: rbacv1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s:%s", roleName, serviceAccountName),
+			Namespace: imageprovenance.AllowlistNamespace,
+		},
+		Subjects: []rbacv1.Subject{
+			{
+				Kind:      "ServiceAccount",
+				Name:      serviceAccountName,
+				Namespace: *namespace,
+			},
+		},
+		RoleRef: rbacv1.RoleRef{
+			Name:     roleName,
+			Kind:     "Role",
+			APIGroup: rbacv1.GroupName,
+		},
+	}
+}
+
 func createClusterRole() *rbacv1.ClusterRole {
 	return &rbacv1.ClusterRole{
 		TypeMeta: metav1.TypeMeta{
@@ -181,6 +267,321 @@ func createClusterRole() *rbacv1.ClusterRole {
 				},
 				Verbs: []string{
 					"get",
+					"list",
+					"watch",
+				},
+			},
+			{
+				APIGroups: []string{
+					"",
+				},
+				Resources: []string{
+					"pods",
+				},
+				Verbs: []string{
+					"list",
+				},
+			},
+			{
+				// -revert-rewritten-images finds the Deployments of the pods
+				// with rewritten images through their ReplicaSets
+				APIGroups: []string{
+					"apps",
+				},
+				Resources: []string{
+					"replicasets",
+				},
+				Verbs: []string{
+					"get",
+				},
+			},
+			{
+				// -revert-rewritten-images restarts the workloads of the pods
+				// with rewritten images
+				APIGroups: []string{
+					"apps",
+				},
+				Resources: []string{
+					"deployments",
+					"statefulsets",
+					"daemonsets",
+				},
+				Verbs: []string{
+					"get",
+					"patch",
+				},
+			},
+			{
+				APIGroups: []string{
+					"",
+				},
+				Resources: []string{
+					"namespaces",
+				},
+				Verbs: []string{
+					"get",
+					"list",
+					"watch",
+				},
+			},
+			{
+				// objectquota-validation counts the Secrets, ConfigMaps and
+				// CronJobs of customer namespaces, listing their metadata
+				// only, so Secrets' data is never read
+				APIGroups: []string{
+					"",
+				},
+				Resources: []string{
+					"secrets",
+					"configmaps",
+				},
+				Verbs: []string{
+					"list",
+				},
+			},
+			{
+				APIGroups: []string{
+					"batch",
+				},
+				Resources: []string{
+					"cronjobs",
+				},
+				Verbs: []string{
+					"list",
+				},
+			},
+			{
+				// podresources-validation caches the nodes' allocatable
+				// resources
+				APIGroups: []string{
+					"",
+				},
+				Resources: []string{
+					"nodes",
+				},
+				Verbs: []string{
+					"get",
+					"list",
+					"watch",
+				},
+			},
+			{
+				// servicetype-validation caches the Services to count the
+				// LoadBalancers of each namespace
+				APIGroups: []string{
+					"",
+				},
+				Resources: []string{
+					"services",
+				},
+				Verbs: []string{
+					"get",
+					"list",
+					"watch",
+				},
+			},
+			{
+				APIGroups: []string{
+					"config.openshift.io",
+				},
+				Resources: []string{
+					"dnses",
+					"ingresses",
+				},
+				Verbs: []string{
+					"get",
+					"list",
+					"watch",
+				},
+			},
+			{
+				APIGroups: []string{
+					"storage.k8s.io",
+				},
+				Resources: []string{
+					"storageclasses",
+				},
+				Verbs: []string{
+					"get",
+					"list",
+					"watch",
+				},
+			},
+			{
+				// managednetworkpolicy-validation caches the NetworkPolicies
+				// to check the others of a namespace still allow platform
+				// ingress
+				APIGroups: []string{
+					"networking.k8s.io",
+				},
+				Resources: []string{
+					"networkpolicies",
+				},
+				Verbs: []string{
+					"get",
+					"list",
+					"watch",
+				},
+			},
+			{
+				// clusterversion-validation reads the upgrade scheduled by
+				// the managed-upgrade-operator into its denials
+				APIGroups: []string{
+					"upgrade.managed.openshift.io",
+				},
+				Resources: []string{
+					"upgradeconfigs",
+				},
+				Verbs: []string{
+					"list",
+				},
+			},
+			{
+				APIGroups: []string{
+					"",
+				},
+				Resources: []string{
+					"events",
+				},
+				Verbs: []string{
+					"create",
+				},
+			},
+			{
+				APIGroups: []string{
+					"coordination.k8s.io",
+				},
+				Resources: []string{
+					"leases",
+				},
+				Verbs: []string{
+					"get",
+					"create",
+					"update",
+				},
+			},
+			{
+				APIGroups: []string{
+					"admissionregistration.k8s.io",
+				},
+				Resources: []string{
+					"validatingwebhookconfigurations",
+					"mutatingwebhookconfigurations",
+				},
+				Verbs: []string{
+					"get",
+					"create",
+					"update",
+					"delete",
+				},
+			},
+			{
+				// -self-register counts the replicas still serving before
+				// deregistering the webhooks
+				APIGroups: []string{
+					"discovery.k8s.io",
+				},
+				Resources: []string{
+					"endpointslices",
+				},
+				Verbs: []string{
+					"list",
+				},
+			},
+			{
+				APIGroups: []string{
+					v1alpha1.SchemeGroupVersion.Group,
+				},
+				Resources: []string{
+					"webhookpolicies",
+				},
+				Verbs: []string{
+					"get",
+					"list",
+					"watch",
+				},
+			},
+		},
+	}
+}
+
+// createWebhookPolicyCRD returns the CRD of the WebhookPolicies enforced by the
+// webhookpolicy-validation webhook
+func createWebhookPolicyCRD() *apiextensionsv1.CustomResourceDefinition {
+	stringArray := apiextensionsv1.JSONSchemaProps{
+		Type:  "array",
+		Items: &apiextensionsv1.JSONSchemaPropsOrArray{Schema: &apiextensionsv1.JSONSchemaProps{Type: "string"}},
+	}
+	requiredStringArray := stringArray
+	requiredStringArray.MinItems = pointer.Int64(1)
+
+	return &apiextensionsv1.CustomResourceDefinition{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "CustomResourceDefinition",
+			APIVersion: apiextensionsv1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "webhookpolicies." + v1alpha1.SchemeGroupVersion.Group,
+		},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: v1alpha1.SchemeGroupVersion.Group,
+			Names: apiextensionsv1.CustomResourceDefinitionNames{
+				Plural:   "webhookpolicies",
+				Singular: "webhookpolicy",
+				Kind:     "WebhookPolicy",
+				ListKind: "WebhookPolicyList",
+			},
+			Scope: apiextensionsv1.ClusterScoped,
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
+				{
+					Name:    v1alpha1.SchemeGroupVersion.Version,
+					Served:  true,
+					Storage: true,
+					Schema: &apiextensionsv1.CustomResourceValidation{
+						OpenAPIV3Schema: &apiextensionsv1.JSONSchemaProps{
+							Type: "object",
+							Properties: map[string]apiextensionsv1.JSONSchemaProps{
+								"apiVersion": {Type: "string"},
+								"kind":       {Type: "string"},
+								"metadata":   {Type: "object"},
+								"spec": {
+									Type:     "object",
+									Required: []string{"apiGroups", "resources", "verbs", "message"},
+									Properties: map[string]apiextensionsv1.JSONSchemaProps{
+										"apiGroups": stringArray,
+										"resources": requiredStringArray,
+										"verbs": {
+											Type:     "array",
+											MinItems: pointer.Int64(1),
+											Items: &apiextensionsv1.JSONSchemaPropsOrArray{Schema: &apiextensionsv1.JSONSchemaProps{
+												Type: "string",
+												Enum: []apiextensionsv1.JSON{
+													{Raw: []byte(`"create"`)},
+													{Raw: []byte(`"update"`)},
+													{Raw: []byte(`"delete"`)},
+													{Raw: []byte(`"connect"`)},
+													{Raw: []byte(`"*"`)},
+												},
+											}},
+										},
+										"namespaceSelector": {
+											Type:                   "object",
+											XPreserveUnknownFields: pointer.Bool(true),
+										},
+										"exceptions": {
+											Type: "object",
+											Properties: map[string]apiextensionsv1.JSONSchemaProps{
+												"users":  stringArray,
+												"groups": stringArray,
+											},
+										},
+										"message": {Type: "string", MinLength: pointer.Int64(1)},
+									},
+								},
+							},
+							Required: []string{"spec"},
+						},
+					},
 				},
 			},
 		},
@@ -211,6 +612,106 @@ func createClusterRoleBinding() *rbacv1.ClusterRoleBinding {
 	}
 }
 
+// createCustomResourcesClusterRole returns the ClusterRole aggregating the
+// ClusterRoles which let customresourcedefinitions-validation list the custom
+// resources of CustomResourceDefinitions being deleted
+func createCustomResourcesClusterRole() *rbacv1.ClusterRole {
+	return &rbacv1.ClusterRole{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "ClusterRole",
+			APIVersion: rbacv1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: customresourcedefinitions.AggregatedClusterRoleName,
+		},
+		AggregationRule: &rbacv1.AggregationRule{
+			ClusterRoleSelectors: []metav1.LabelSelector{
+				{
+					MatchLabels: map[string]string{
+						customresourcedefinitions.AggregationLabel: "true",
+					},
+				},
+			},
+		},
+	}
+}
+
+// createPlatformCustomResourcesClusterRole returns the ClusterRole, aggregated
+// into createCustomResourcesClusterRole, which lets the webhook list the custom
+// resources of the platform's API groups
+func createPlatformCustomResourcesClusterRole() *rbacv1.ClusterRole {
+	return &rbacv1.ClusterRole{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "ClusterRole",
+			APIVersion: rbacv1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: customresourcedefinitions.AggregatedClusterRoleName + "-platform",
+			Labels: map[string]string{
+				customresourcedefinitions.AggregationLabel: "true",
+			},
+		},
+		Rules: []rbacv1.PolicyRule{
+			{
+				APIGroups: customresourcedefinitions.PlatformGroups,
+				Resources: []string{
+					"*",
+				},
+				Verbs: []string{
+					"list",
+				},
+			},
+		},
+	}
+}
+
+func createCustomResourcesClusterRoleBinding() *rbacv1.ClusterRoleBinding {
+	return &rbacv1.ClusterRoleBinding{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "ClusterRoleBinding",
+			APIVersion: rbacv1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: fmt.Sprintf("%s:%s", customresourcedefinitions.AggregatedClusterRoleName, serviceAccountName),
+		},
+		Subjects: []rbacv1.Subject{
+			{
+				Kind:      "ServiceAccount",
+				Name:      serviceAccountName,
+				Namespace: *namespace,
+			},
+		},
+		RoleRef: rbacv1.RoleRef{
+			Name:     customresourcedefinitions.AggregatedClusterRoleName,
+			Kind:     "ClusterRole",
+			APIGroup: rbacv1.GroupName,
+		},
+	}
+}
+
+// customResourcesRBAC returns the ClusterRoles and ClusterRoleBinding which let
+// customresourcedefinitions-validation list custom resources, annotated with
+// the package-operator phase, if any
+func customResourcesRBAC(phase string) ([]runtime.RawExtension, error) {
+	aggregated := createCustomResourcesClusterRole()
+	platform := createPlatformCustomResourcesClusterRole()
+	binding := createCustomResourcesClusterRoleBinding()
+	if phase != "" {
+		for _, obj := range []metav1.Object{aggregated, platform, binding} {
+			obj.SetAnnotations(map[string]string{pkoPhaseAnnotation: phase})
+		}
+	}
+	encodedAggregated, err := syncset.EncodeAggregatedClusterRole(aggregated)
+	if err != nil {
+		return nil, err
+	}
+	return []runtime.RawExtension{
+		{Raw: encodedAggregated},
+		{Object: platform},
+		{Object: binding},
+	}, nil
+}
+
 func createPrometheusRole() *rbacv1.Role {
 	return &rbacv1.Role{
 		TypeMeta: metav1.TypeMeta{
@@ -501,6 +1002,8 @@ func createPackagedDeployment(replicas int32, phase string) *appsv1.Deployment {
 									ContainerPort: int32(*listenPort),
 								},
 							},
+							LivenessProbe:  webhookProbe("/healthz"),
+							ReadinessProbe: webhookProbe("/readyz"),
 							Command: []string{
 								"webhooks",
 								"-tlskey", "/service-certs/tls.key",
@@ -522,6 +1025,22 @@ func createPackagedDeployment(replicas int32, phase string) *appsv1.Deployment {
 	}
 }
 
+// webhookProbe probes path on the webhook server
+func webhookProbe(path string) *corev1.Probe {
+	return &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			HTTPGet: &corev1.HTTPGetAction{
+				Path:   path,
+				Port:   intstr.FromInt32(int32(*listenPort)),
+				Scheme: corev1.URISchemeHTTPS,
+			},
+		},
+		PeriodSeconds:    10,
+		TimeoutSeconds:   3,
+		FailureThreshold: 3,
+	}
+}
+
 func createDaemonSet() *appsv1.DaemonSet {
 	return &appsv1.DaemonSet{
 		TypeMeta: metav1.TypeMeta{
@@ -635,12 +1154,18 @@ func createDaemonSet() *appsv1.DaemonSet {
 									ContainerPort: int32(*listenPort),
 								},
 							},
+							// the drift repair keeps the canaries' namespaceSelectors
+							// as they're rendered here
+							Env:            canaryEnv(),
+							LivenessProbe:  webhookProbe("/healthz"),
+							ReadinessProbe: webhookProbe("/readyz"),
 							Command: []string{
 								"webhooks",
 								"-tlskey", "/service-certs/tls.key",
 								"-tlscert", "/service-certs/tls.crt",
 								"-cacert", "/service-ca/service-ca.crt",
 								"-tls",
+								"-repair-drift",
 							},
 						},
 					},
@@ -650,6 +1175,19 @@ func createDaemonSet() *appsv1.DaemonSet {
 	}
 }
 
+// canaryEnv passes the -canaries overrides on to the webhooks
+func canaryEnv() []corev1.EnvVar {
+	if *canaries == "" {
+		return nil
+	}
+	return []corev1.EnvVar{
+		{
+			Name:  webhooks.CanaryEnvVar,
+			Value: *canaries,
+		},
+	}
+}
+
 func createService() *corev1.Service {
 	return &corev1.Service{
 		TypeMeta: metav1.TypeMeta{
@@ -712,50 +1250,10 @@ func createPackagedValidatingWebhookConfiguration(webhook webhooks.Webhook, phas
 	return webhookConfiguration
 }
 
-// hookToResources turns a Webhook into a ValidatingWebhookConfiguration and Service.
-// The Webhook is expected to implement Rules() which will return a
+// createValidatingWebhookConfiguration turns a Webhook into the
+// ValidatingWebhookConfiguration registering it with the Service
 func createValidatingWebhookConfiguration(hook webhooks.Webhook) admissionregv1.ValidatingWebhookConfiguration {
-	failPolicy := hook.FailurePolicy()
-	timeout := hook.TimeoutSeconds()
-	matchPolicy := hook.MatchPolicy()
-	sideEffects := hook.SideEffects()
-
-	return admissionregv1.ValidatingWebhookConfiguration{
-		TypeMeta: metav1.TypeMeta{
-			Kind:       "ValidatingWebhookConfiguration",
-			APIVersion: "admissionregistration.k8s.io/v1",
-		},
-		ObjectMeta: metav1.ObjectMeta{
-			Name: fmt.Sprintf("sre-%s", hook.Name()),
-
-			Annotations: map[string]string{
-				// service.beta.openshift.io/inject-cabundle annotation will instruct
-				// service-ca-operator to install a CA cert in the
-				// ValidatingWebhookConfiguration object, which is required for
-				// Kubernetes to communicate securely to the Service.
-				"service.beta.openshift.io/inject-cabundle": "true",
-			},
-		},
-		Webhooks: []admissionregv1.ValidatingWebhook{
-			{
-				AdmissionReviewVersions: []string{"v1"},
-				TimeoutSeconds:          &timeout,
-				SideEffects:             &sideEffects,
-				MatchPolicy:             &matchPolicy,
-				Name:                    fmt.Sprintf("%s.managed.openshift.io", hook.Name()),
-				ObjectSelector:          hook.ObjectSelector(),
-				FailurePolicy:           &failPolicy,
-				ClientConfig: admissionregv1.WebhookClientConfig{
-					Service: &admissionregv1.ServiceReference{
-						Namespace: *namespace,
-						Path:      pointer.StringPtr(hook.GetURI()),
-						Name:      serviceName,
-					},
-				},
-				Rules: hook.Rules(),
-			},
-		},
-	}
+	return webhooks.ValidatingWebhookConfiguration(hook, *namespace)
 }
 
 func createPackagedMutatingWebhookConfiguration(webhook webhooks.Webhook, phase string) admissionregv1.MutatingWebhookConfiguration {
@@ -772,49 +1270,99 @@ func createPackagedMutatingWebhookConfiguration(webhook webhooks.Webhook, phase
 }
 
 func createMutatingWebhookConfiguration(hook webhooks.Webhook) admissionregv1.MutatingWebhookConfiguration {
-	failPolicy := hook.FailurePolicy()
-	timeout := hook.TimeoutSeconds()
+	return webhooks.MutatingWebhookConfiguration(hook, *namespace)
+}
+
+// createValidatingAdmissionPolicy renders the CEL validations of a
+// PolicyWebhook as a ValidatingAdmissionPolicy matching the same requests as
+// its ValidatingWebhookConfiguration
+func createValidatingAdmissionPolicy(hook webhooks.Webhook, validations []admissionregv1.Validation) admissionregv1.ValidatingAdmissionPolicy {
+	failPolicy := webhooks.FailurePolicy(hook)
 	matchPolicy := hook.MatchPolicy()
-	sideEffects := hook.SideEffects()
 
-	return admissionregv1.MutatingWebhookConfiguration{
+	resourceRules := make([]admissionregv1.NamedRuleWithOperations, 0, len(hook.Rules()))
+	for _, rule := range hook.Rules() {
+		resourceRules = append(resourceRules, admissionregv1.NamedRuleWithOperations{RuleWithOperations: rule})
+	}
+
+	return admissionregv1.ValidatingAdmissionPolicy{
 		TypeMeta: metav1.TypeMeta{
-			Kind:       "MutatingWebhookConfiguration",
+			Kind:       "ValidatingAdmissionPolicy",
 			APIVersion: "admissionregistration.k8s.io/v1",
 		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name: fmt.Sprintf("sre-%s", hook.Name()),
-
-			Annotations: map[string]string{
-				// service.beta.openshift.io/inject-cabundle annotation will instruct
-				// service-ca-operator to install a CA cert in the
-				// MutatingWebhookConfiguration object, which is required for
-				// Kubernetes to communicate securely to the Service.
-				"service.beta.openshift.io/inject-cabundle": "true",
-			},
 		},
-		Webhooks: []admissionregv1.MutatingWebhook{
-			{
-				AdmissionReviewVersions: []string{"v1"},
-				TimeoutSeconds:          &timeout,
-				SideEffects:             &sideEffects,
-				MatchPolicy:             &matchPolicy,
-				Name:                    fmt.Sprintf("%s.managed.openshift.io", hook.Name()),
-				ObjectSelector:          hook.ObjectSelector(),
-				FailurePolicy:           &failPolicy,
-				ClientConfig: admissionregv1.WebhookClientConfig{
-					Service: &admissionregv1.ServiceReference{
-						Namespace: *namespace,
-						Path:      pointer.StringPtr(hook.GetURI()),
-						Name:      serviceName,
-					},
-				},
-				Rules: hook.Rules(),
+		Spec: admissionregv1.ValidatingAdmissionPolicySpec{
+			FailurePolicy: &failPolicy,
+			MatchConstraints: &admissionregv1.MatchResources{
+				ObjectSelector: hook.ObjectSelector(),
+				MatchPolicy:    &matchPolicy,
+				ResourceRules:  resourceRules,
 			},
+			Validations: validations,
 		},
 	}
 }
 
+func createValidatingAdmissionPolicyBinding(hook webhooks.Webhook) admissionregv1.ValidatingAdmissionPolicyBinding {
+	return admissionregv1.ValidatingAdmissionPolicyBinding{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "ValidatingAdmissionPolicyBinding",
+			APIVersion: "admissionregistration.k8s.io/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: fmt.Sprintf("sre-%s", hook.Name()),
+		},
+		Spec: admissionregv1.ValidatingAdmissionPolicyBindingSpec{
+			PolicyName:        fmt.Sprintf("sre-%s", hook.Name()),
+			ValidationActions: []admissionregv1.ValidationAction{admissionregv1.Deny},
+		},
+	}
+}
+
+// policyHooks returns the PolicyWebhooks to render as policies, sorted by name
+func policyHooks(skip, onlyInclude []string) []webhooks.Webhook {
+	hookNames := make([]string, 0)
+	for name := range webhooks.Webhooks {
+		hookNames = append(hookNames, name)
+	}
+	sort.Strings(hookNames)
+
+	hooks := []webhooks.Webhook{}
+	for _, hookName := range hookNames {
+		hook := webhooks.Webhooks[hookName]()
+		if _, ok := hook.(webhooks.PolicyWebhook); !ok || len(hook.Rules()) == 0 {
+			continue
+		}
+		if sliceContains(hook.Name(), skip) {
+			continue
+		}
+		if len(onlyInclude) > 0 && !sliceContains(hook.Name(), onlyInclude) {
+			continue
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks
+}
+
+// writeDocuments writes the resources to the file as YAML documents
+func writeDocuments(file string, resources []interface{}) {
+	var pb strings.Builder
+	for _, resource := range resources {
+		y, err := yaml.Marshal(resource)
+		if err != nil {
+			panic(fmt.Sprintf("couldn't marshal: %s\n", err.Error()))
+		}
+		pb.WriteString("---\n")
+		pb.Write(y)
+	}
+	err := os.WriteFile(file, []byte(pb.String()), 0644)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to write to %s: %s\n", file, err.Error()))
+	}
+}
+
 func sliceContains(needle string, haystack []string) bool {
 	for _, hay := range haystack {
 		if hay == needle {
@@ -827,6 +1375,18 @@ func sliceContains(needle string, haystack []string) bool {
 func main() {
 	flag.Parse()
 
+	policies, err := webhooks.ParseFailurePolicies(*failPolicies)
+	if err != nil {
+		panic(fmt.Sprintf("Invalid -failurepolicies: %s\n", err.Error()))
+	}
+	webhooks.SetFailurePolicies(policies)
+
+	canaryOverrides, err := webhooks.ParseCanaries(*canaries)
+	if err != nil {
+		panic(fmt.Sprintf("Invalid -canaries: %s\n", err.Error()))
+	}
+	webhooks.SetCanaries(canaryOverrides)
+
 	skip := strings.Split(*excludes, ",")
 	onlyInclude := strings.Split(*only, "")
 
@@ -840,19 +1400,66 @@ func main() {
 		buildPackage = true
 	}
 
+	if *policyFile != "" {
+		resources := []interface{}{}
+		for _, hook := range policyHooks(skip, onlyInclude) {
+			resources = append(resources,
+				createValidatingAdmissionPolicy(hook, hook.(webhooks.PolicyWebhook).Validations()),
+				createValidatingAdmissionPolicyBinding(hook))
+		}
+		writeDocuments(*policyFile, resources)
+	}
+
+	if *gatekeeperFile != "" || *kyvernoFile != "" {
+		scheme, err := k8sutil.SharedScheme()
+		if err != nil {
+			panic(fmt.Sprintf("couldn't build the scheme: %s\n", err.Error()))
+		}
+		gatekeeper, kyverno := []interface{}{}, []interface{}{}
+		for _, hook := range policyHooks(skip, onlyInclude) {
+			validations := hook.(webhooks.PolicyWebhook).Validations()
+			template, err := policyexport.Gatekeeper(hook, validations, scheme)
+			if err != nil {
+				panic(err.Error())
+			}
+			gatekeeper = append(gatekeeper, template...)
+			policy, err := policyexport.Kyverno(hook, validations, scheme)
+			if err != nil {
+				panic(err.Error())
+			}
+			kyverno = append(kyverno, policy)
+		}
+		if *gatekeeperFile != "" {
+			writeDocuments(*gatekeeperFile, gatekeeper)
+		}
+		if *kyvernoFile != "" {
+			writeDocuments(*kyvernoFile, kyverno)
+		}
+	}
+
 	if buildSelectorSyncSet {
 		templateResources := syncset.SyncSetResourcesByLabelSelector{}
 		templateResources.Add(utils.DefaultLabelSelector(), runtime.RawExtension{Object: createNamespace()})
 		templateResources.Add(utils.DefaultLabelSelector(), runtime.RawExtension{Object: createServiceAccount()})
 		templateResources.Add(utils.DefaultLabelSelector(), runtime.RawExtension{Object: createRole()})
 		templateResources.Add(utils.DefaultLabelSelector(), runtime.RawExtension{Object: createRoleBinding()})
+		templateResources.Add(utils.DefaultLabelSelector(), runtime.RawExtension{Object: createImageProvenanceRole()})
+		templateResources.Add(utils.DefaultLabelSelector(), runtime.RawExtension{Object: createImageProvenanceRoleBinding()})
 		templateResources.Add(utils.DefaultLabelSelector(), runtime.RawExtension{Object: createClusterRole()})
 		templateResources.Add(utils.DefaultLabelSelector(), runtime.RawExtension{Object: createClusterRoleBinding()})
+		customResourcesResources, err := customResourcesRBAC("")
+		if err != nil {
+			panic(fmt.Sprintf("couldn't marshal: %s\n", err.Error()))
+		}
+		for _, resource := range customResourcesResources {
+			templateResources.Add(utils.DefaultLabelSelector(), resource)
+		}
 		templateResources.Add(utils.DefaultLabelSelector(), runtime.RawExtension{Object: createPrometheusRole()})
 		templateResources.Add(utils.DefaultLabelSelector(), runtime.RawExtension{Object: createPromethusRoleBinding()})
 		templateResources.Add(utils.DefaultLabelSelector(), runtime.RawExtension{Object: createServiceMonitor()})
 		templateResources.Add(utils.DefaultLabelSelector(), runtime.RawExtension{Object: createCACertConfigMap()})
 		templateResources.Add(utils.DefaultLabelSelector(), runtime.RawExtension{Object: createService()})
+		templateResources.Add(utils.DefaultLabelSelector(), runtime.RawExtension{Object: createWebhookPolicyCRD()})
 
 		encodedDaemonSet, err := syncset.EncodeAndFixDaemonset(createDaemonSet())
 		if err != nil {
@@ -967,52 +1574,16 @@ func main() {
 		packageResources = append(packageResources, runtime.RawExtension{Object: createPackagedCACertConfigMap(configPhase)})
 		packageResources = append(packageResources, runtime.RawExtension{Object: createPackagedService(deployPhase)})
 		packageResources = append(packageResources, runtime.RawExtension{Object: createPackagedDeployment(int32(*replicas), deployPhase)})
-
-		hookNames := make([]string, 0)
-		for name := range webhooks.Webhooks {
-			hookNames = append(hookNames, name)
+		// The custom resources RBAC is for the hosted cluster, with the webhooks
+		customResourcesResources, err := customResourcesRBAC(webhooksPhase)
+		if err != nil {
+			fmt.Printf("Error encoding packaged custom resources RBAC: %v\n", err)
+			os.Exit(1)
 		}
-		sort.Strings(hookNames)
-		seen := make(map[string]bool)
-		for _, hookName := range hookNames {
-			hook := webhooks.Webhooks[hookName]
-			if seen[hook().GetURI()] {
-				panic(fmt.Sprintf("Duplicate hook URI: %s", hook().GetURI()))
-			}
-			seen[hook().GetURI()] = true
-
-			if !hook().HypershiftEnabled() {
-				continue
-			}
+		packageResources = append(packageResources, customResourcesResources...)
 
-			// no rules...?
-			if len(hook().Rules()) == 0 {
-				continue
-			}
-
-			if *showHookNames {
-				fmt.Println(hook().Name())
-			}
-			if sliceContains(hook().Name(), skip) {
-				continue
-			}
-			if len(onlyInclude) > 0 && !sliceContains(hook().Name(), onlyInclude) {
-				continue
-			}
-
-			// MutatingWebhookConfigurations have special names (e.g., service-mutation)
-			if strings.HasSuffix(hookName, "-mutation") {
-				encodedWebhook, err := syncset.EncodeMutatingAndFixCA(createPackagedMutatingWebhookConfiguration(hook(), webhooksPhase))
-				if err != nil {
-					fmt.Printf("Error encoding packaged webhook: %v\n", err)
-					os.Exit(1)
-				}
-				packageResources = append(packageResources, runtime.RawExtension{Raw: encodedWebhook})
-				continue
-			}
-
-			// Now handle all Validating webhooks
-			encodedWebhook, err := syncset.EncodeValidatingAndFixCA(createPackagedValidatingWebhookConfiguration(hook(), webhooksPhase))
+		for _, hook := range hypershiftHooks(skip, onlyInclude) {
+			encodedWebhook, err := encodePackagedWebhookConfiguration(hook)
 			if err != nil {
 				fmt.Printf("Error encoding packaged webhook: %v\n", err)
 				os.Exit(1)
@@ -1029,11 +1600,161 @@ func main() {
 			rb.Write(resourceYaml)
 		}
 		fname := filepath.Join(*packageDir, "resources.yaml.gotmpl")
-		err := os.WriteFile(fname, []byte(rb.String()), 0644)
+		err = os.WriteFile(fname, []byte(rb.String()), 0644)
 		if err != nil {
 			panic(fmt.Sprintf("Failed to write to %s: %s", fname, err.Error()))
 		}
 	} else {
 		fmt.Printf("No -packagedir option supplied, will not generate package manifest\n")
 	}
+
+	if *hypershiftDir != "" {
+		if err := writeHostedControlPlane(*hypershiftDir, skip, onlyInclude); err != nil {
+			fmt.Printf("Error rendering hosted control plane manifests: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// hypershiftHooks returns the webhooks enabled on HyperShift hosted clusters,
+// sorted by name
+func hypershiftHooks(skip, onlyInclude []string) []webhooks.Webhook {
+	hookNames := make([]string, 0)
+	for name := range webhooks.Webhooks {
+		hookNames = append(hookNames, name)
+	}
+	sort.Strings(hookNames)
+
+	hooks := make([]webhooks.Webhook, 0)
+	seen := make(map[string]bool)
+	for _, hookName := range hookNames {
+		hook := webhooks.Webhooks[hookName]()
+		if seen[hook.GetURI()] {
+			panic(fmt.Sprintf("Duplicate hook URI: %s", hook.GetURI()))
+		}
+		seen[hook.GetURI()] = true
+
+		if !hook.HypershiftEnabled() {
+			continue
+		}
+
+		// no rules...?
+		if len(hook.Rules()) == 0 {
+			continue
+		}
+
+		if *showHookNames {
+			fmt.Println(hook.Name())
+		}
+		if sliceContains(hook.Name(), skip) {
+			continue
+		}
+		if len(onlyInclude) > 0 && !sliceContains(hook.Name(), onlyInclude) {
+			continue
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks
+}
+
+// encodePackagedWebhookConfiguration encodes the webhook configuration
+// installed on the hosted cluster for hook
+func encodePackagedWebhookConfiguration(hook webhooks.Webhook) ([]byte, error) {
+	// MutatingWebhookConfigurations have special names (e.g., service-mutation)
+	if strings.HasSuffix(hook.Name(), "-mutation") {
+		return syncset.EncodeMutatingAndFixCA(createPackagedMutatingWebhookConfiguration(hook, webhooksPhase))
+	}
+	return syncset.EncodeValidatingAndFixCA(createPackagedValidatingWebhookConfiguration(hook, webhooksPhase))
+}
+
+// writeHostedControlPlane writes the package's resources as plain manifests for
+// a single hosted control plane, without package-operator. The webhook server
+// runs in the HCP namespace on the management cluster, where the hosted
+// kube-apiserver reaches it through the allow-guest-webhooks Service, and only
+// the webhook configurations of webhooks enabled on HyperShift are installed on
+// the hosted cluster.
+func writeHostedControlPlane(dir string, skip, onlyInclude []string) error {
+	if *hcpNamespace == "" {
+		return fmt.Errorf("-hcpnamespace is required with -hypershiftdir")
+	}
+	serviceCA := []byte{}
+	if *serviceCAFile != "" {
+		var err error
+		if serviceCA, err = os.ReadFile(*serviceCAFile); err != nil {
+			return err
+		}
+	}
+	// The package's template values are filled in here instead of by
+	// package-operator
+	values := strings.NewReplacer(
+		"{{.package.metadata.namespace}}", *hcpNamespace,
+		"{{.config.serviceca | b64enc }}", base64.StdEncoding.EncodeToString(serviceCA),
+	)
+
+	managementCluster := []runtime.RawExtension{}
+	for _, obj := range []metav1.Object{
+		createPackagedCACertConfigMap(configPhase),
+		createPackagedService(deployPhase),
+		createPackagedDeployment(int32(*replicas), deployPhase),
+	} {
+		obj.SetNamespace(*hcpNamespace)
+		delete(obj.GetAnnotations(), pkoPhaseAnnotation)
+		managementCluster = append(managementCluster, runtime.RawExtension{Object: obj.(runtime.Object)})
+	}
+
+	hostedCluster, err := customResourcesRBAC("")
+	if err != nil {
+		return err
+	}
+	for _, hook := range hypershiftHooks(skip, onlyInclude) {
+		encodedWebhook, err := encodePackagedWebhookConfiguration(hook)
+		if err != nil {
+			return err
+		}
+		hostedCluster = append(hostedCluster, runtime.RawExtension{Raw: encodedWebhook})
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for file, resources := range map[string][]runtime.RawExtension{
+		"management-cluster.yaml": managementCluster,
+		"hosted-cluster.yaml":     hostedCluster,
+	} {
+		var rb strings.Builder
+		for _, resource := range resources {
+			resourceYaml, err := yaml.Marshal(resource)
+			if err != nil {
+				return err
+			}
+			if resource.Raw != nil {
+				// Drop the package-operator annotations from the webhook
+				// configurations; everything else is a typed object
+				if resourceYaml, err = withoutPhaseAnnotation(resourceYaml); err != nil {
+					return err
+				}
+			}
+			rb.WriteString("---\n")
+			rb.WriteString(values.Replace(string(resourceYaml)))
+		}
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(rb.String()), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withoutPhaseAnnotation removes the package-operator phase annotation from an
+// encoded resource, which only package-operator understands
+func withoutPhaseAnnotation(resourceYaml []byte) ([]byte, error) {
+	resource := map[string]interface{}{}
+	if err := yaml.Unmarshal(resourceYaml, &resource); err != nil {
+		return nil, err
+	}
+	if metadata, ok := resource["metadata"].(map[string]interface{}); ok {
+		if annotations, ok := metadata["annotations"].(map[string]interface{}); ok {
+			delete(annotations, pkoPhaseAnnotation)
+		}
+	}
+	return yaml.Marshal(resource)
 }
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	webhooks "github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/customresourcedefinitions"
+)
+
+// readManifests decodes the YAML documents of a rendered manifest file
+func readManifests(t *testing.T, file string) []unstructured.Unstructured {
+	t.Helper()
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", file, err)
+	}
+	if strings.Contains(string(raw), "{{") {
+		t.Errorf("expected the package's template values to be filled in in %s", file)
+	}
+	objs := []unstructured.Unstructured{}
+	for _, doc := range strings.Split(string(raw), "---\n") {
+		if strings.TrimSpace(doc) == "" {
+			continue
+		}
+		obj := unstructured.Unstructured{}
+		if err := yaml.Unmarshal([]byte(doc), &obj.Object); err != nil {
+			t.Fatalf("failed to decode a resource of %s: %v", file, err)
+		}
+		if _, ok := obj.GetAnnotations()[pkoPhaseAnnotation]; ok {
+			t.Errorf("expected no package-operator phase on %s %s", obj.GetKind(), obj.GetName())
+		}
+		objs = append(objs, obj)
+	}
+	return objs
+}
+
+func TestWriteHostedControlPlane(t *testing.T) {
+	dir := t.TempDir()
+	serviceCA := filepath.Join(dir, "service-ca.crt")
+	if err := os.WriteFile(serviceCA, []byte("test-ca"), 0644); err != nil {
+		t.Fatalf("failed to write the service CA: %v", err)
+	}
+	defer func(namespace, file string) { *hcpNamespace, *serviceCAFile = namespace, file }(*hcpNamespace, *serviceCAFile)
+	*hcpNamespace, *serviceCAFile = "clusters-test", serviceCA
+
+	if err := writeHostedControlPlane(dir, []string{"debug-hook"}, nil); err != nil {
+		t.Fatalf("unexpected error rendering the manifests: %v", err)
+	}
+
+	kinds := map[string]bool{}
+	for _, obj := range readManifests(t, filepath.Join(dir, "management-cluster.yaml")) {
+		kinds[obj.GetKind()] = true
+		if obj.GetNamespace() != "clusters-test" {
+			t.Errorf("expected %s %s in the HCP namespace, got %q", obj.GetKind(), obj.GetName(), obj.GetNamespace())
+		}
+		if obj.GetKind() == "Service" && obj.GetLabels()["hypershift.openshift.io/allow-guest-webhooks"] != "true" {
+			t.Errorf("expected the Service to be reachable from the hosted kube-apiserver")
+		}
+	}
+	for _, kind := range []string{"ConfigMap", "Service", "Deployment"} {
+		if !kinds[kind] {
+			t.Errorf("expected a %s in management-cluster.yaml", kind)
+		}
+	}
+
+	hypershiftHooks := map[string]bool{}
+	for name, hook := range webhooks.Webhooks {
+		if h := hook(); h.HypershiftEnabled() && len(h.Rules()) > 0 && name != "debug-hook" {
+			hypershiftHooks[webhooks.ConfigurationName(name)] = true
+		}
+	}
+	rendered := map[string]bool{}
+	clusterRoles := map[string]bool{}
+	for _, obj := range readManifests(t, filepath.Join(dir, "hosted-cluster.yaml")) {
+		switch obj.GetKind() {
+		case "ClusterRole", "ClusterRoleBinding":
+			clusterRoles[obj.GetName()] = true
+		case "ValidatingWebhookConfiguration", "MutatingWebhookConfiguration":
+			rendered[obj.GetName()] = true
+			configs, _, _ := unstructured.NestedSlice(obj.Object, "webhooks")
+			for _, config := range configs {
+				url, _, _ := unstructured.NestedString(config.(map[string]interface{}), "clientConfig", "url")
+				if !strings.HasPrefix(url, "https://validation-webhook.clusters-test.svc.cluster.local/") {
+					t.Errorf("expected %s to call the Service in the HCP namespace, got %s", obj.GetName(), url)
+				}
+				caBundle, _, _ := unstructured.NestedString(config.(map[string]interface{}), "clientConfig", "caBundle")
+				if ca, _ := base64.StdEncoding.DecodeString(caBundle); string(ca) != "test-ca" {
+					t.Errorf("expected %s to trust the service CA, got %q", obj.GetName(), caBundle)
+				}
+			}
+		default:
+			t.Errorf("unexpected %s %s in hosted-cluster.yaml", obj.GetKind(), obj.GetName())
+		}
+	}
+	for name := range hypershiftHooks {
+		if !rendered[name] {
+			t.Errorf("expected the webhook configuration of %s in hosted-cluster.yaml", name)
+		}
+	}
+	for name := range rendered {
+		if !hypershiftHooks[name] {
+			t.Errorf("expected no webhook configuration for %s, which isn't enabled on HyperShift", name)
+		}
+	}
+	if !clusterRoles[customresourcedefinitions.AggregatedClusterRoleName] {
+		t.Errorf("expected the custom resources RBAC in hosted-cluster.yaml")
+	}
+}
+
+func TestWriteHostedControlPlaneRequiresNamespace(t *testing.T) {
+	defer func(namespace string) { *hcpNamespace = namespace }(*hcpNamespace)
+	*hcpNamespace = ""
+	if err := writeHostedControlPlane(t.TempDir(), nil, nil); err == nil {
+		t.Fatalf("expected an error without -hcpnamespace")
+	}
+}
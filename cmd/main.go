@@ -10,33 +10,78 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/openshift/operator-custom-metrics/pkg/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"k8s.io/apimachinery/pkg/runtime"
 	klog "k8s.io/klog/v2"
 	"k8s.io/klog/v2/klogr"
+	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	ctrl "sigs.k8s.io/controller-runtime/pkg/manager/signals"
 
 	"github.com/openshift/managed-cluster-validating-webhooks/config"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/audit"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/clustercontext"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/dependencies"
 	"github.com/openshift/managed-cluster-validating-webhooks/pkg/dispatcher"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/drift"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/evaluate"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/events"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/failurepolicy"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/faultinject"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/featuregates"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/genfixtures"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/health"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
 	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/leader"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/lint"
 	"github.com/openshift/managed-cluster-validating-webhooks/pkg/localmetrics"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/notify"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/profiling"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/registration"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/registryrevert"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/replay"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/selftest"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/tracing"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhookpolicy"
 	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks"
 )
 
 var log = logf.Log.WithName("handler")
 
+// warmUpTimeout bounds how long the server stays unready warming up webhooks
+// whose dependencies can't be initialized
+const warmUpTimeout = 30 * time.Second
+
 var (
 	listenAddress = flag.String("listen", "0.0.0.0", "listen address")
 	listenPort    = flag.String("port", "5000", "port to listen on")
 	testHooks     = flag.Bool("testhooks", false, "Test webhook URI uniqueness and quit?")
 
+	selfTest         = flag.Bool("selftest", false, "Replay canned admission requests through every webhook, report and quit?")
+	selfTestFixtures = flag.String("selftest-fixtures", "", "Directory of selftest fixtures to use in place of the built-in ones")
+
 	useTLS  = flag.Bool("tls", false, "Use TLS? Must specify -tlskey, -tlscert, -cacert")
 	tlsKey  = flag.String("tlskey", "", "TLS Key for TLS")
 	tlsCert = flag.String("tlscert", "", "TLS Certificate")
 	caCert  = flag.String("cacert", "", "CA Cert file")
 
+	repairDrift  = flag.Bool("repair-drift", false, "Repair webhook configurations which drift from their generated state?")
+	selfRegister = flag.Bool("self-register", false, "Create the webhook configurations once the server is ready, and delete them when the last replica shuts down?")
+
+	revertRewrittenImages   = flag.Bool("revert-rewritten-images", false, "Restart workloads whose images podimagespec-mutation rewrote once the internal image registry is available again?")
+	leaderElectionNamespace = flag.String("leader-election-namespace", config.OperatorNamespace, "Namespace of the Lease electing the replica which runs the controllers needing a single instance")
+
+	pprofAddress   = flag.String("pprof-address", "", "Address to serve the pprof endpoints on, eg 127.0.0.1:6060. Must be a loopback address unless "+profiling.TokenEnvVar+" is set. Disabled when empty.")
+	heapProfileDir = flag.String("heap-profile-dir", os.TempDir(), "Directory heap profiles are written to on SIGUSR1")
+
 	metricsPath = "/metrics"
 	metricsPort = "8080"
 )
@@ -44,14 +89,52 @@ var (
 func main() {
 	var metricsAddr string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":"+metricsPort, "The address the metric endpoint binds to.")
-	flag.Parse()
 	klog.SetOutput(os.Stdout)
 
 	logf.SetLogger(klogr.New())
 
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		os.Exit(runReplay(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "gen-fixtures" {
+		os.Exit(runGenFixtures(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "evaluate-server" {
+		os.Exit(runEvaluateServer(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "lint" {
+		os.Exit(runLint(os.Args[2:]))
+	}
+	flag.Parse()
+
+	// build the scheme and decoder the webhooks share once, before any webhook
+	// is created
+	if _, err := k8sutil.SharedScheme(); err != nil {
+		log.Error(err, "Failed to build shared scheme")
+		os.Exit(1)
+	}
+
+	if *selfTest {
+		os.Exit(runSelfTest())
+	}
+
 	if !*testHooks {
 		log.Info("HTTP server running at", "listen", net.JoinHostPort(*listenAddress, *listenPort))
 	}
+	// fail open or closed as this environment overrides
+	failurePolicies, err := webhooks.FailurePoliciesFromEnv()
+	if err != nil {
+		log.Error(err, "Failed to read failure policy overrides; webhooks keep their own")
+		failurePolicies = webhooks.FailurePolicies{}
+	}
+	webhooks.SetFailurePolicies(failurePolicies)
+	// roll out or promote canaries as this environment overrides
+	if canaries, err := webhooks.CanariesFromEnv(); err != nil {
+		log.Error(err, "Failed to read canary overrides; webhooks keep their own")
+	} else {
+		webhooks.SetCanaries(canaries)
+	}
+
 	dispatcher := dispatcher.NewDispatcher(webhooks.Webhooks)
 	seen := make(map[string]bool)
 	for name, hook := range webhooks.Webhooks {
@@ -69,6 +152,167 @@ func main() {
 		os.Exit(0)
 	}
 
+	ctx := ctrl.SetupSignalHandler()
+
+	// write a heap profile on SIGUSR1, and serve pprof when asked to
+	profiling.DumpHeapOnSignal(ctx, *heapProfileDir)
+	if *pprofAddress != "" {
+		if pprofServer, err := profiling.NewServer(*pprofAddress, os.Getenv(profiling.TokenEnvVar)); err != nil {
+			log.Error(err, "Not serving pprof endpoints")
+		} else {
+			pprofServer.Start(ctx)
+		}
+	}
+
+	// export a span per admission request when an OTLP endpoint is configured
+	shutdownTracing, err := tracing.Setup(ctx)
+	if err != nil {
+		log.Error(err, "Failed to configure tracing; requests will not be traced")
+		shutdownTracing = func(context.Context) error { return nil }
+	}
+
+	// opt-in webhooks stay disabled until the feature gate ConfigMap enables them
+	featuregates.SetOptIn(webhooks.Webhooks)
+
+	// share one cached client between the webhooks which read from the cluster.
+	// Only the kinds read by the webhooks enabled on this cluster are cached, so
+	// the feature gates are synced first; webhooks enabled later read what they
+	// need from the API server. If the cache can't be built they share an
+	// uncached client, so webhooks don't each create their own while answering
+	// requests.
+	var sharedClient client.Client
+	var gateWatcher *featuregates.Watcher
+	scheme, _ := k8sutil.SharedScheme()
+	if sharedClient, err = k8sutil.KubeClient(scheme); err != nil {
+		log.Error(err, "Failed to create shared client; webhooks will create their own")
+		sharedClient = nil
+	}
+	if sharedClient != nil {
+		// enable and disable webhooks on this cluster from the feature gate
+		// ConfigMap
+		gateWatcher = featuregates.NewWatcher(sharedClient, webhooks.Webhooks, config.OperatorNamespace)
+		if err := gateWatcher.Start(ctx); err != nil {
+			log.Error(err, "Failed to watch webhook feature gates; all webhooks are enabled")
+			gateWatcher = nil
+		}
+		if cachedClient, err := k8sutil.CachedClient(ctx, scheme, webhooks.Webhooks.CachedObjects(featuregates.Enabled)); err != nil {
+			log.Error(err, "Failed to create shared cached client; webhooks will read from the API server")
+		} else {
+			sharedClient = cachedClient
+		}
+	}
+	// staging builds can inject faults into the webhooks' reads, to check
+	// their failure policies and fallbacks
+	faults, err := faultinject.FromEnv()
+	if err != nil {
+		log.Error(err, "Not injecting faults")
+	}
+	var webhookClient client.Client
+	if sharedClient != nil {
+		webhookClient = tracing.Client(faultinject.Client(sharedClient, faults))
+		webhooks.SetClient(webhookClient)
+	}
+
+	// record the requests webhooks deny
+	if sinks, err := audit.SinksFromEnv(sharedClient, config.OperatorNamespace); err != nil {
+		log.Error(err, "Failed to configure audit sinks; denials will not be audited")
+	} else {
+		auditor := audit.NewAuditor(sinks...)
+		auditor.Start(ctx)
+		dispatcher.SetAuditor(auditor)
+	}
+
+	// notify SRE of security-sensitive denials
+	if broker, err := notify.FromEnv(); err != nil {
+		log.Error(err, "Failed to configure notifications; SRE will not be notified of denials")
+	} else if broker != nil {
+		broker.Start(ctx)
+		dispatcher.SetNotifier(broker)
+	}
+
+	// record Events for the changes mutating webhooks make to objects
+	var recorder *events.Recorder
+	if sharedClient != nil {
+		recorder = events.NewRecorder(sharedClient)
+		recorder.Start(ctx)
+		events.SetRecorder(recorder)
+	}
+
+	// webhooks implementing WebhookV2 are handed these dependencies with each
+	// request rather than creating their own
+	deps := dependencies.Bundle{Recorder: recorder}
+	if webhookClient != nil {
+		deps.Client = webhookClient
+	}
+	dependencies.Set(deps)
+
+	// resolve SRE and privileged identities from the identity policy ConfigMap,
+	// read the cluster's product from the cluster context ConfigMap OCM syncs,
+	// override failure policies from the cluster's failure policy ConfigMap,
+	// and enforce the WebhookPolicies on the cluster
+	if sharedClient != nil {
+		if err := identity.NewWatcher(sharedClient, config.OperatorNamespace).Start(ctx); err != nil {
+			log.Error(err, "Failed to watch identity policy; the default policy is used")
+		}
+		if err := clustercontext.NewWatcher(sharedClient, config.OperatorNamespace).Start(ctx); err != nil {
+			log.Error(err, "Failed to watch cluster context; the cluster's product is unknown")
+		}
+		if err := failurepolicy.NewWatcher(sharedClient, config.OperatorNamespace, failurePolicies).Start(ctx); err != nil {
+			log.Error(err, "Failed to watch failure policy overrides; those of the environment are used")
+		}
+		policyWatcher := webhookpolicy.NewWatcher(sharedClient, config.OperatorNamespace)
+		if err := policyWatcher.Start(ctx); err != nil {
+			log.Error(err, "Failed to watch WebhookPolicies; they are not enforced")
+			policyWatcher = nil
+		}
+
+		// controllers needing a single instance only run on the elected
+		// leader, while every replica serves admission requests
+		elector := leader.NewElector()
+		// the configurations of webhooks disabled by their feature gate are
+		// removed, and restored once they're enabled again
+		if gateWatcher != nil {
+			elector.Add("featuregates", gateWatcher.Run)
+		}
+		// the configuration of the WebhookPolicy webhook selects the requests
+		// the policies apply to
+		if policyWatcher != nil {
+			elector.Add("webhookpolicy", policyWatcher.Run)
+		}
+		// the configurations of Classic clusters are generated from the
+		// webhooks served here; those of hosted clusters are left to their
+		// package
+		if *repairDrift {
+			elector.Add("drift", drift.NewDetector(sharedClient, webhooks.Webhooks, config.OperatorNamespace, *caCert).Run)
+		}
+		// workloads with images rewritten while the internal image registry
+		// was removed go back to it once it's available again
+		if *revertRewrittenImages {
+			elector.Add("registryrevert", registryrevert.NewReverter(sharedClient).Run)
+		}
+		if err := elector.Start(ctx, *leaderElectionNamespace); err != nil {
+			log.Error(err, "Failed to stand for leader election; controllers needing a single instance are not run")
+		}
+	}
+
+	// report liveness, and readiness once the webhooks are warmed up and their
+	// dependencies are served
+	checker := health.NewChecker(webhooks.Webhooks, sharedClient)
+	if sharedClient != nil {
+		warmedUp := checker.WarmingUp()
+		go func() {
+			defer warmedUp()
+			warmUpCtx, cancel := context.WithTimeout(ctx, warmUpTimeout)
+			defer cancel()
+			start := time.Now()
+			if err := webhooks.Webhooks.WarmUp(warmUpCtx); err != nil {
+				log.Error(err, "Failed to warm up webhooks; requests will initialize what's missing")
+			}
+			log.Info("Warmed up webhooks", "duration", time.Since(start).String())
+		}()
+	}
+	checker.Register(http.DefaultServeMux)
+
 	// start metrics server
 	metricsServer := metrics.NewBuilder(config.OperatorNamespace, fmt.Sprintf("%s-metrics", config.OperatorName)).
 		WithPort(metricsPort).
@@ -77,8 +321,6 @@ func main() {
 		WithCollectors(localmetrics.MetricsList).
 		GetConfig()
 
-	ctx := ctrl.SetupSignalHandler()
-
 	// get the namespace we're running in to confirm if running in a cluster
 	if _, err := k8sutil.GetOperatorNamespace(); err != nil {
 		if errors.Is(err, k8sutil.ErrRunLocal) {
@@ -106,21 +348,57 @@ func main() {
 		certpool := x509.NewCertPool()
 		certpool.AppendCertsFromPEM(cafile)
 
+		// reload the serving certificate when service-ca rotates it
+		watcher, err := certwatcher.New(*tlsCert, *tlsKey)
+		if err != nil {
+			log.Error(err, "Couldn't load serving certificate")
+			os.Exit(1)
+		}
+		watcher.RegisterCallback(localmetrics.ObserveServingCert)
+		go func() {
+			if err := watcher.Start(ctx); err != nil {
+				log.Error(err, "Failed to watch serving certificate")
+			}
+		}()
+
 		server.TLSConfig = &tls.Config{
-			RootCAs: certpool,
+			RootCAs:        certpool,
+			GetCertificate: watcher.GetCertificate,
 		}
 	}
 
-	// Start server in background
+	// Start server in background, listening first so webhooks are only
+	// registered once requests can be answered
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		log.Error(err, "Couldn't listen")
+		os.Exit(1)
+	}
 	errCh := make(chan error, 1)
 	go func() {
 		if *useTLS {
-			errCh <- server.ListenAndServeTLS(*tlsCert, *tlsKey)
+			errCh <- server.ServeTLS(listener, "", "")
 		} else {
-			errCh <- server.ListenAndServe()
+			errCh <- server.Serve(listener)
 		}
 	}()
 
+	// register the webhooks once the server is ready, rather than have the API
+	// server call them before, and keep them registered while it serves
+	var registrar *registration.Registrar
+	if *selfRegister {
+		if sharedClient == nil {
+			log.Info("Not registering webhooks; there's no client to register them with")
+		} else {
+			registrar = registration.NewRegistrar(sharedClient, webhooks.Webhooks, config.OperatorNamespace, *caCert, checker.Ready)
+			go func() {
+				if err := registrar.Run(ctx); err != nil && ctx.Err() == nil {
+					log.Error(err, "Failed to register webhooks")
+				}
+			}()
+		}
+	}
+
 	// Wait for signal or server error
 	select {
 	case err := <-errCh:
@@ -133,9 +411,269 @@ func main() {
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	// deregister the webhooks while still serving, if no other replica is left
+	// to answer them
+	if registrar != nil {
+		if err := registrar.Deregister(shutdownCtx); err != nil {
+			log.Error(err, "Failed to deregister webhooks")
+		}
+	}
 	if err := server.Shutdown(shutdownCtx); err != nil {
 		log.Error(err, "Server shutdown error")
 		os.Exit(1)
 	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Error(err, "Failed to flush traces")
+	}
 	log.Info("Server stopped gracefully")
 }
+
+// runSelfTest replays the selftest fixtures through the registered webhooks,
+// printing a report, and returns the exit code
+func runSelfTest() int {
+	fsys, err := selftest.Fixtures(*selfTestFixtures)
+	if err != nil {
+		log.Error(err, "Couldn't open selftest fixtures")
+		return 1
+	}
+	fixtures, err := selftest.Load(fsys)
+	if err != nil {
+		log.Error(err, "Couldn't load selftest fixtures")
+		return 1
+	}
+	scheme, err := k8sutil.SharedScheme()
+	if err != nil {
+		log.Error(err, "Failed to build shared scheme")
+		return 1
+	}
+
+	report := selftest.Run(webhooks.Webhooks, fixtures, scheme)
+	report.Print(os.Stdout)
+	if !report.Passed() {
+		return 1
+	}
+	return 0
+}
+
+// runReplay replays the admission requests recorded in audit logs or saved
+// AdmissionReviews through the registered webhooks, printing where their
+// decisions differ from the recorded ones, and returns the exit code
+func runReplay(args []string) int {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	only := flags.String("webhook", "", "Only replay through these comma-separated webhooks")
+	fromCluster := flags.Bool("cluster", false, "Serve the objects webhooks read from the cluster the kubeconfig points at, rather than from an empty cluster")
+	verbose := flags.Bool("v", false, "Print every result and skipped entry, not only differences")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s replay [flags] <audit log or AdmissionReview files, or - for stdin>...\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	// keep the webhooks' logs out of the report
+	klog.SetOutput(os.Stderr)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	scheme, err := k8sutil.SharedScheme()
+	if err != nil {
+		log.Error(err, "Failed to build shared scheme")
+		return 1
+	}
+	newClient := func() client.Client {
+		return fake.NewClientBuilder().WithScheme(scheme).Build()
+	}
+	if *fromCluster {
+		c, err := k8sutil.KubeClient(scheme)
+		if err != nil {
+			log.Error(err, "Failed to create client")
+			return 1
+		}
+		newClient = func() client.Client { return c }
+	}
+
+	entries := []replay.Entry{}
+	for _, name := range flags.Args() {
+		read, err := readReplayEntries(name, scheme)
+		if err != nil {
+			log.Error(err, "Couldn't read admission requests", "file", name)
+			return 1
+		}
+		entries = append(entries, read...)
+	}
+
+	webhookNames := []string{}
+	for _, name := range strings.Split(*only, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			webhookNames = append(webhookNames, name)
+		}
+	}
+	report := replay.Run(webhooks.Webhooks, entries, webhookNames, newClient)
+	report.Print(os.Stdout, *verbose)
+	if report.Differs() {
+		return 1
+	}
+	return 0
+}
+
+// runGenFixtures writes selftest fixtures generated from the objects of the
+// cluster the kubeconfig points at, and returns the exit code
+func runGenFixtures(args []string) int {
+	flags := flag.NewFlagSet("gen-fixtures", flag.ExitOnError)
+	out := flags.String("out", "testdata", "Directory to write the fixtures into, one directory per webhook")
+	namespaces := flags.String("namespaces", "", "Only sample pods from these comma-separated namespaces")
+	maxPods := flags.Int("max-pods", genfixtures.DefaultMaxPods, "The most pods to sample")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s gen-fixtures [flags]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	// keep the webhooks' logs out of the summary
+	klog.SetOutput(os.Stderr)
+
+	scheme, err := k8sutil.SharedScheme()
+	if err != nil {
+		log.Error(err, "Failed to build shared scheme")
+		return 1
+	}
+	c, err := k8sutil.KubeClient(scheme)
+	if err != nil {
+		log.Error(err, "Failed to create client")
+		return 1
+	}
+
+	opts := genfixtures.Options{MaxPods: *maxPods}
+	for _, namespace := range strings.Split(*namespaces, ",") {
+		if namespace = strings.TrimSpace(namespace); namespace != "" {
+			opts.Namespaces = append(opts.Namespaces, namespace)
+		}
+	}
+	generated, err := genfixtures.Generate(context.Background(), c, webhooks.Webhooks, scheme, opts)
+	if err != nil {
+		log.Error(err, "Failed to generate fixtures")
+		return 1
+	}
+	if err := genfixtures.Write(*out, generated); err != nil {
+		log.Error(err, "Failed to write fixtures", "dir", *out)
+		return 1
+	}
+	for _, g := range generated {
+		fmt.Fprintf(os.Stdout, "%s/%s/%s.yaml\n", *out, g.Webhook, g.Name)
+	}
+	fmt.Fprintf(os.Stdout, "Wrote %d fixtures; review them before committing\n", len(generated))
+	return 0
+}
+
+// runEvaluateServer serves the gRPC API evaluating manifests against the
+// registered webhooks, without a cluster, until interrupted, and returns the
+// exit code
+func runEvaluateServer(args []string) int {
+	flags := flag.NewFlagSet("evaluate-server", flag.ExitOnError)
+	listen := flags.String("listen", "127.0.0.1:50051", "Address to serve the gRPC API on")
+	certFile := flags.String("tlscert", "", "TLS certificate to serve with. Plaintext is served when empty.")
+	keyFile := flags.String("tlskey", "", "TLS key of -tlscert")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s evaluate-server [flags]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+
+	scheme, err := k8sutil.SharedScheme()
+	if err != nil {
+		log.Error(err, "Failed to build shared scheme")
+		return 1
+	}
+	opts := []grpc.ServerOption{}
+	if *certFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(*certFile, *keyFile)
+		if err != nil {
+			log.Error(err, "Couldn't load serving certificate")
+			return 1
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	listener, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Error(err, "Couldn't listen", "address", *listen)
+		return 1
+	}
+
+	server := grpc.NewServer(opts...)
+	evaluate.Register(server, evaluate.NewEvaluator(webhooks.Webhooks, scheme))
+	ctx := ctrl.SetupSignalHandler()
+	go func() {
+		<-ctx.Done()
+		server.GracefulStop()
+	}()
+	log.Info("Evaluate API running at", "listen", *listen)
+	if err := server.Serve(listener); err != nil {
+		log.Error(err, "Server failed")
+		return 1
+	}
+	return 0
+}
+
+// runLint checks the webhook configurations in the files given, or those the
+// registered webhooks render to when none are, prints the findings and returns
+// the exit code
+func runLint(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	namespace := flags.String("namespace", config.OperatorNamespace, "Namespace of the Service the registered webhooks are rendered with, when no files are given")
+	maxFailTimeout := flags.Int("max-fail-timeout", int(lint.DefaultMaxFailTimeoutSeconds), "The longest timeout, in seconds, of a webhook failing closed")
+	strict := flags.Bool("strict", false, "Exit non-zero on warnings as well as errors")
+	verbose := flags.Bool("v", false, "Print notes as well as errors and warnings")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s lint [flags] [manifest files, or - for stdin]...\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	// keep the webhooks' logs out of the report
+	klog.SetOutput(os.Stderr)
+
+	configurations := []lint.Configuration{}
+	if flags.NArg() == 0 {
+		configurations = lint.Rendered(webhooks.Webhooks, *namespace)
+	}
+	for _, name := range flags.Args() {
+		read, err := readLintConfigurations(name)
+		if err != nil {
+			log.Error(err, "Couldn't read webhook configurations", "file", name)
+			return 1
+		}
+		configurations = append(configurations, read...)
+	}
+
+	findings := lint.Lint(configurations, webhooks.Webhooks, lint.Options{MaxFailTimeoutSeconds: int32(*maxFailTimeout)})
+	lint.Print(os.Stdout, findings, len(configurations), *verbose)
+	if lint.Failed(findings, *strict) {
+		return 1
+	}
+	return 0
+}
+
+// readLintConfigurations reads the configurations of the named file, or of
+// stdin for "-"
+func readLintConfigurations(name string) ([]lint.Configuration, error) {
+	if name == "-" {
+		return lint.Read("stdin", os.Stdin)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return lint.Read(name, f)
+}
+
+// readReplayEntries reads the entries of the named file, or of stdin for "-"
+func readReplayEntries(name string, scheme *runtime.Scheme) ([]replay.Entry, error) {
+	if name == "-" {
+		return replay.Read("stdin", os.Stdin, scheme)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return replay.Read(name, f, scheme)
+}
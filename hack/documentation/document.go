@@ -9,20 +9,28 @@ import (
 	"os"
 	"sort"
 
+	"github.com/ghodss/yaml"
 	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
 	admissionregv1 "k8s.io/api/admissionregistration/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 var (
-	hideRules = flag.Bool("hideRules", false, "Hide the Admission Rules?")
+	hideRules    = flag.Bool("hideRules", false, "Hide the Admission Rules?")
+	failPolicies = flag.String("failurePolicies", "", "Comma-separated list of webhook=policy overriding the webhooks' failure policies")
+	format       = flag.String("format", "json", "Output format, json or yaml")
 )
 
 type docuhook struct {
 	Name                string                              `json:"webhookName"`
 	Rules               []admissionregv1.RuleWithOperations `json:"rules,omitempty"`
 	ObjectSelector      *metav1.LabelSelector               `json:"webhookObjectSelector,omitempty"`
+	FailurePolicy       admissionregv1.FailurePolicyType    `json:"failurePolicy,omitempty"`
 	DocumentationString string                              `json:"documentString"`
+	RuleDocs            []utils.RuleDoc                     `json:"ruleDocs"`
+	ClassicEnabled      bool                                `json:"classicEnabled"`
+	HypershiftEnabled   bool                                `json:"hypershiftEnabled"`
 }
 
 // WriteDocs will write out all the docs.
@@ -39,13 +47,26 @@ func WriteDocs() {
 		realHook := hook()
 		dochooks[i].Name = realHook.Name()
 		dochooks[i].DocumentationString = realHook.Doc()
+		dochooks[i].RuleDocs = realHook.RuleDocs()
+		dochooks[i].ClassicEnabled = realHook.ClassicEnabled()
+		dochooks[i].HypershiftEnabled = realHook.HypershiftEnabled()
 		if !*hideRules {
 			dochooks[i].Rules = realHook.Rules()
 			dochooks[i].ObjectSelector = realHook.ObjectSelector()
+			dochooks[i].FailurePolicy = webhooks.FailurePolicy(realHook)
 		}
 	}
 
-	b, err := json.MarshalIndent(&dochooks, "", "  ")
+	var b []byte
+	var err error
+	switch *format {
+	case "json":
+		b, err = json.MarshalIndent(&dochooks, "", "  ")
+	case "yaml":
+		b, err = yaml.Marshal(&dochooks)
+	default:
+		err = fmt.Errorf("unknown format %q, expected json or yaml", *format)
+	}
 	if err != nil {
 		fmt.Printf("Error encoding: %s\n", err.Error())
 		os.Exit(1)
@@ -62,5 +83,11 @@ func WriteDocs() {
 
 func main() {
 	flag.Parse()
+	policies, err := webhooks.ParseFailurePolicies(*failPolicies)
+	if err != nil {
+		fmt.Printf("Error parsing -failurePolicies: %s\n", err.Error())
+		os.Exit(1)
+	}
+	webhooks.SetFailurePolicies(policies)
 	WriteDocs()
 }
@@ -0,0 +1,84 @@
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// DeepCopyInto copies the policy into out
+func (in *WebhookPolicy) DeepCopyInto(out *WebhookPolicy) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy copies the policy
+func (in *WebhookPolicy) DeepCopy() *WebhookPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(WebhookPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject implements runtime.Object
+func (in *WebhookPolicy) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto copies the spec into out
+func (in *WebhookPolicySpec) DeepCopyInto(out *WebhookPolicySpec) {
+	*out = *in
+	out.APIGroups = copyStrings(in.APIGroups)
+	out.Resources = copyStrings(in.Resources)
+	out.Verbs = copyStrings(in.Verbs)
+	if in.NamespaceSelector != nil {
+		out.NamespaceSelector = in.NamespaceSelector.DeepCopy()
+	}
+	out.Exceptions.Users = copyStrings(in.Exceptions.Users)
+	out.Exceptions.Groups = copyStrings(in.Exceptions.Groups)
+}
+
+// DeepCopyInto copies the list into out
+func (in *WebhookPolicyList) DeepCopyInto(out *WebhookPolicyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		out.Items = make([]WebhookPolicy, len(in.Items))
+		for i := range in.Items {
+			in.Items[i].DeepCopyInto(&out.Items[i])
+		}
+	}
+}
+
+// DeepCopy copies the list
+func (in *WebhookPolicyList) DeepCopy() *WebhookPolicyList {
+	if in == nil {
+		return nil
+	}
+	out := new(WebhookPolicyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject implements runtime.Object
+func (in *WebhookPolicyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+func copyStrings(in []string) []string {
+	if in == nil {
+		return nil
+	}
+	out := make([]string, len(in))
+	copy(out, in)
+	return out
+}
@@ -0,0 +1,71 @@
+// Package v1alpha1 contains the managed.openshift.io/v1alpha1 types served by
+// the webhooks' own CustomResourceDefinitions
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var (
+	// SchemeGroupVersion is the group and version of the types
+	SchemeGroupVersion = schema.GroupVersion{Group: "managed.openshift.io", Version: "v1alpha1"}
+
+	schemeBuilder = runtime.NewSchemeBuilder(addKnownTypes)
+	// AddToScheme adds the types to a scheme
+	AddToScheme = schemeBuilder.AddToScheme
+)
+
+func addKnownTypes(scheme *runtime.Scheme) error {
+	scheme.AddKnownTypes(SchemeGroupVersion, &WebhookPolicy{}, &WebhookPolicyList{})
+	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
+	return nil
+}
+
+// WebhookPolicy denies changes to resources, unless they're made by the
+// excepted users and groups. It lets SRE ship simple deny rules as data
+// rather than as a new webhook.
+type WebhookPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec WebhookPolicySpec `json:"spec"`
+}
+
+// WebhookPolicySpec is what a WebhookPolicy denies
+type WebhookPolicySpec struct {
+	// APIGroups are the API groups of the resources, "" being the core group
+	// and "*" any group
+	APIGroups []string `json:"apiGroups"`
+	// Resources are the resources the policy denies changes to, eg.
+	// "configmaps", or "*" for any resource
+	Resources []string `json:"resources"`
+	// Verbs are the changes denied: create, update, delete, connect, or "*"
+	// for all of them
+	Verbs []string `json:"verbs"`
+	// NamespaceSelector limits the policy to resources in the namespaces it
+	// selects. Cluster-scoped resources are never selected. When unset the
+	// policy applies in every namespace and to cluster-scoped resources.
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	// Exceptions are the users and groups the policy doesn't apply to. SRE,
+	// cluster admins and privileged service accounts are always excepted.
+	Exceptions WebhookPolicyExceptions `json:"exceptions,omitempty"`
+	// Message explains the denial to the user
+	Message string `json:"message"`
+}
+
+// WebhookPolicyExceptions are the users and groups a WebhookPolicy doesn't
+// apply to
+type WebhookPolicyExceptions struct {
+	Users  []string `json:"users,omitempty"`
+	Groups []string `json:"groups,omitempty"`
+}
+
+// WebhookPolicyList is a list of WebhookPolicies
+type WebhookPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []WebhookPolicy `json:"items"`
+}
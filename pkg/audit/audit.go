@@ -0,0 +1,169 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/localmetrics"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+)
+
+const (
+	// SinksEnvVar is a comma-separated list of the sinks denials are recorded
+	// to: stdout, event and http. Defaults to stdout.
+	SinksEnvVar = "AUDIT_SINKS"
+	// HTTPEndpointEnvVar is the URL the http sink POSTs records to
+	HTTPEndpointEnvVar = "AUDIT_HTTP_ENDPOINT"
+
+	SinkStdout = "stdout"
+	SinkEvent  = "event"
+	SinkHTTP   = "http"
+
+	// queueSize is how many records may wait for the sinks before new records
+	// are dropped rather than holding up admission requests
+	queueSize = 1000
+)
+
+var log = logf.Log.WithName("audit")
+
+// Record is the audit trail of one denied admission request
+type Record struct {
+	Timestamp time.Time `json:"timestamp"`
+	Webhook   string    `json:"webhook"`
+	UID       string    `json:"uid"`
+	User      string    `json:"user"`
+	Groups    []string  `json:"groups,omitempty"`
+	Operation string    `json:"operation"`
+	Group     string    `json:"group,omitempty"`
+	Version   string    `json:"version"`
+	Kind      string    `json:"kind"`
+	Resource  string    `json:"resource"`
+	Namespace string    `json:"namespace,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	Code      string    `json:"code,omitempty"`
+	Reason    string    `json:"reason"`
+}
+
+// NewRecord builds the Record of the webhook's response to the request
+func NewRecord(webhook string, request admissionctl.Request, resp admissionctl.Response) Record {
+	var reason string
+	if resp.Result != nil {
+		reason = resp.Result.Message
+	}
+	return Record{
+		Timestamp: time.Now().UTC(),
+		Webhook:   webhook,
+		UID:       string(request.UID),
+		User:      request.UserInfo.Username,
+		Groups:    request.UserInfo.Groups,
+		Operation: string(request.Operation),
+		Group:     request.Kind.Group,
+		Version:   request.Kind.Version,
+		Kind:      request.Kind.Kind,
+		Resource:  request.Resource.Resource,
+		Namespace: request.Namespace,
+		Name:      request.Name,
+		Code:      string(response.CodeOf(resp)),
+		Reason:    reason,
+	}
+}
+
+// Sink is somewhere audit records are written
+type Sink interface {
+	// Name identifies the sink in logs and metrics
+	Name() string
+	// Write records r
+	Write(ctx context.Context, r Record) error
+}
+
+// Auditor hands the records of denied requests to its sinks in the
+// background, so slow sinks don't hold up admission requests
+type Auditor struct {
+	sinks   []Sink
+	records chan Record
+}
+
+// NewAuditor creates an Auditor writing to sinks
+func NewAuditor(sinks ...Sink) *Auditor {
+	return &Auditor{
+		sinks:   sinks,
+		records: make(chan Record, queueSize),
+	}
+}
+
+// Start writes queued records to the sinks until ctx is cancelled
+func (a *Auditor) Start(ctx context.Context) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case r := <-a.records:
+				a.write(ctx, r)
+			}
+		}
+	}()
+}
+
+func (a *Auditor) write(ctx context.Context, r Record) {
+	for _, sink := range a.sinks {
+		if err := sink.Write(ctx, r); err != nil {
+			localmetrics.IncrementAuditFailure(sink.Name())
+			log.Error(err, "Failed to write audit record", "sink", sink.Name(), "webhookName", r.Webhook, "uid", r.UID)
+		}
+	}
+}
+
+// Record queues the record of a denied request. When the queue is full the
+// record is dropped and logged instead. A nil Auditor records nothing.
+func (a *Auditor) Record(r Record) {
+	if a == nil {
+		return
+	}
+	select {
+	case a.records <- r:
+	default:
+		localmetrics.IncrementAuditFailure("queue")
+		log.Info("Audit queue is full, dropping record", "record", r)
+	}
+}
+
+// SinksFromEnv builds the sinks listed in SinksEnvVar. c is used by the
+// event sink.
+func SinksFromEnv(c client.Client, namespace string) ([]Sink, error) {
+	names := os.Getenv(SinksEnvVar)
+	if strings.TrimSpace(names) == "" {
+		names = SinkStdout
+	}
+
+	sinks := []Sink{}
+	for _, name := range strings.Split(names, ",") {
+		switch strings.TrimSpace(name) {
+		case "":
+			continue
+		case SinkStdout:
+			sinks = append(sinks, NewWriterSink(os.Stdout))
+		case SinkEvent:
+			if c == nil {
+				return nil, fmt.Errorf("the %s audit sink needs a client", SinkEvent)
+			}
+			sinks = append(sinks, NewEventSink(c, namespace))
+		case SinkHTTP:
+			endpoint := os.Getenv(HTTPEndpointEnvVar)
+			if endpoint == "" {
+				return nil, fmt.Errorf("the %s audit sink needs %s to be set", SinkHTTP, HTTPEndpointEnvVar)
+			}
+			sinks = append(sinks, NewHTTPSink(endpoint))
+		default:
+			return nil, fmt.Errorf("unknown audit sink %q", name)
+		}
+	}
+	return sinks, nil
+}
@@ -0,0 +1,214 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+)
+
+const testNamespace = "openshift-validation-webhook"
+
+func testRecord(namespace string) Record {
+	request := admissionctl.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			UID:       "test-uid",
+			Kind:      metav1.GroupVersionKind{Group: "quota.openshift.io", Version: "v1", Kind: "ClusterResourceQuota"},
+			Resource:  metav1.GroupVersionResource{Group: "quota.openshift.io", Version: "v1", Resource: "clusterresourcequotas"},
+			Operation: admissionv1.Update,
+			Namespace: namespace,
+			Name:      "managed-quota",
+			UserInfo: authenticationv1.UserInfo{
+				Username: "unpriv-user",
+				Groups:   []string{"system:authenticated"},
+			},
+		},
+	}
+	return NewRecord("hiveownership-validation", request, response.Denied(response.ManagedResource, "Prevented from accessing Red Hat managed resources"))
+}
+
+func TestNewRecord(t *testing.T) {
+	r := testRecord("")
+	expected := Record{
+		Timestamp: r.Timestamp,
+		Webhook:   "hiveownership-validation",
+		UID:       "test-uid",
+		User:      "unpriv-user",
+		Groups:    []string{"system:authenticated"},
+		Operation: "UPDATE",
+		Group:     "quota.openshift.io",
+		Version:   "v1",
+		Kind:      "ClusterResourceQuota",
+		Resource:  "clusterresourcequotas",
+		Name:      "managed-quota",
+		Code:      "ManagedResource",
+		Reason:    "Prevented from accessing Red Hat managed resources",
+	}
+	if !reflect.DeepEqual(r, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, r)
+	}
+}
+
+func TestWriterSink(t *testing.T) {
+	buf := &bytes.Buffer{}
+	r := testRecord("")
+	if err := NewWriterSink(buf).Write(context.Background(), r); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	written := Record{}
+	if err := json.Unmarshal(buf.Bytes(), &written); err != nil {
+		t.Fatalf("Expected a JSON record, got %s", buf.String())
+	}
+	if !written.Timestamp.Equal(r.Timestamp) || written.UID != r.UID || written.Reason != r.Reason {
+		t.Fatalf("Expected %+v, got %+v", r, written)
+	}
+}
+
+func TestEventSink(t *testing.T) {
+	tests := []struct {
+		name              string
+		namespace         string
+		expectedNamespace string
+	}{
+		{name: "cluster-scoped", namespace: "", expectedNamespace: testNamespace},
+		{name: "namespaced", namespace: "my-project", expectedNamespace: "my-project"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+			if err := NewEventSink(c, testNamespace).Write(context.Background(), testRecord(test.namespace)); err != nil {
+				t.Fatalf("Expected no error, got %s", err.Error())
+			}
+			events := &corev1.EventList{}
+			if err := c.List(context.Background(), events, client.InNamespace(test.expectedNamespace)); err != nil {
+				t.Fatalf("Expected no error, got %s", err.Error())
+			}
+			if len(events.Items) != 1 {
+				t.Fatalf("Expected one event in %s, got %d", test.expectedNamespace, len(events.Items))
+			}
+			event := events.Items[0]
+			if event.Type != corev1.EventTypeWarning || event.Reason != eventReason {
+				t.Fatalf("Expected a Warning %s event, got %s %s", eventReason, event.Type, event.Reason)
+			}
+			if event.InvolvedObject.Kind != "ClusterResourceQuota" || event.InvolvedObject.Name != "managed-quota" {
+				t.Fatalf("Expected the event to involve the denied object, got %+v", event.InvolvedObject)
+			}
+		})
+	}
+}
+
+func TestHTTPSink(t *testing.T) {
+	received := make(chan Record, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		record := Record{}
+		if err := json.Unmarshal(body, &record); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received <- record
+	}))
+	defer server.Close()
+
+	if err := NewHTTPSink(server.URL).Write(context.Background(), testRecord("")); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	if r := <-received; r.UID != "test-uid" {
+		t.Fatalf("Expected the record to be posted, got %+v", r)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	if err := NewHTTPSink(failing.URL).Write(context.Background(), testRecord("")); err == nil {
+		t.Fatalf("Expected an error from a failing endpoint")
+	}
+}
+
+func TestSinksFromEnv(t *testing.T) {
+	c := fake.NewClientBuilder().Build()
+	tests := []struct {
+		sinks       string
+		endpoint    string
+		client      client.Client
+		expected    []string
+		expectedErr bool
+	}{
+		{sinks: "", expected: []string{SinkStdout}},
+		{sinks: "stdout, event,http", endpoint: "http://audit.example.com", client: c, expected: []string{SinkStdout, SinkEvent, SinkHTTP}},
+		{sinks: "event", expectedErr: true},
+		{sinks: "http", expectedErr: true},
+		{sinks: "syslog", expectedErr: true},
+	}
+	for _, test := range tests {
+		t.Setenv(SinksEnvVar, test.sinks)
+		t.Setenv(HTTPEndpointEnvVar, test.endpoint)
+		sinks, err := SinksFromEnv(test.client, testNamespace)
+		if test.expectedErr {
+			if err == nil {
+				t.Errorf("%q: expected an error", test.sinks)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: expected no error, got %s", test.sinks, err.Error())
+			continue
+		}
+		names := []string{}
+		for _, sink := range sinks {
+			names = append(names, sink.Name())
+		}
+		if !reflect.DeepEqual(names, test.expected) {
+			t.Errorf("%q: expected sinks %v, got %v", test.sinks, test.expected, names)
+		}
+	}
+}
+
+func TestAuditorRecord(t *testing.T) {
+	// A nil Auditor records nothing
+	var nilAuditor *Auditor
+	nilAuditor.Record(testRecord(""))
+
+	buf := &syncBuffer{written: make(chan []byte, 1)}
+	a := NewAuditor(NewWriterSink(buf))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	a.Start(ctx)
+
+	a.Record(testRecord(""))
+	select {
+	case b := <-buf.written:
+		if !bytes.Contains(b, []byte(`"uid":"test-uid"`)) {
+			t.Fatalf("Expected the record to be written, got %s", b)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for the record to be written")
+	}
+}
+
+// syncBuffer hands everything written to it to a channel
+type syncBuffer struct {
+	written chan []byte
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.written <- append([]byte{}, p...)
+	return len(p), nil
+}
@@ -0,0 +1,147 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	eventReason    = "AdmissionDenied"
+	eventComponent = "validation-webhook"
+
+	httpTimeout = 5 * time.Second
+)
+
+// WriterSink writes records as JSON lines, eg to stdout
+type WriterSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterSink creates a WriterSink writing to w
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+// Name implements Sink interface
+func (s *WriterSink) Name() string { return SinkStdout }
+
+// Write implements Sink interface
+func (s *WriterSink) Write(_ context.Context, r Record) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(b, '\n'))
+	return err
+}
+
+// EventSink records denials as Warning Events on the denied object, so they
+// show up alongside it. Events about cluster-scoped objects are created in
+// namespace.
+type EventSink struct {
+	c         client.Client
+	namespace string
+}
+
+// NewEventSink creates an EventSink
+func NewEventSink(c client.Client, namespace string) *EventSink {
+	return &EventSink{c: c, namespace: namespace}
+}
+
+// Name implements Sink interface
+func (s *EventSink) Name() string { return SinkEvent }
+
+// Write implements Sink interface
+func (s *EventSink) Write(ctx context.Context, r Record) error {
+	return s.c.Create(ctx, s.event(r))
+}
+
+func (s *EventSink) event(r Record) *corev1.Event {
+	namespace := r.Namespace
+	if namespace == "" {
+		namespace = s.namespace
+	}
+	name := r.Name
+	if name == "" {
+		name = strings.ToLower(r.Kind)
+	}
+	apiVersion := r.Version
+	if r.Group != "" {
+		apiVersion = r.Group + "/" + r.Version
+	}
+	now := metav1.NewTime(r.Timestamp)
+
+	return &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			// Named the way client-go's event recorder names events
+			Name:      fmt.Sprintf("%v.%x", name, r.Timestamp.UnixNano()),
+			Namespace: namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion: apiVersion,
+			Kind:       r.Kind,
+			Namespace:  r.Namespace,
+			Name:       r.Name,
+		},
+		Reason:         eventReason,
+		Message:        fmt.Sprintf("%s denied %s by %s: %s", r.Webhook, r.Operation, r.User, r.Reason),
+		Type:           corev1.EventTypeWarning,
+		Source:         corev1.EventSource{Component: eventComponent},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+}
+
+// HTTPSink POSTs each record as JSON to an endpoint
+type HTTPSink struct {
+	endpoint string
+	client   *http.Client
+}
+
+// NewHTTPSink creates an HTTPSink posting to endpoint
+func NewHTTPSink(endpoint string) *HTTPSink {
+	return &HTTPSink{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: httpTimeout},
+	}
+}
+
+// Name implements Sink interface
+func (s *HTTPSink) Name() string { return SinkHTTP }
+
+// Write implements Sink interface
+func (s *HTTPSink) Write(ctx context.Context, r Record) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("audit endpoint returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+// Package clustercontext tells webhooks which managed product the cluster is
+// and under which terms it's supported, as synced by OCM, so webhooks can
+// differ by product on the cluster rather than in separate builds.
+package clustercontext
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Product is the managed OpenShift product a cluster is
+type Product string
+
+const (
+	// ProductUnknown is used until OCM syncs the cluster context
+	ProductUnknown Product = ""
+	ProductOSD     Product = "osd"
+	ProductROSA    Product = "rosa"
+	ProductROSAHCP Product = "rosa-hcp"
+)
+
+// SupportLevel is whether Red Hat fully supports the cluster
+type SupportLevel string
+
+const (
+	// SupportUnknown is used until OCM syncs the cluster context
+	SupportUnknown SupportLevel = ""
+	SupportFull    SupportLevel = "full"
+	// SupportLimited is for clusters placed in limited support, eg after the
+	// customer broke a managed component
+	SupportLimited SupportLevel = "limited"
+)
+
+const (
+	// productKey is the ConfigMap key holding the Product
+	productKey string = "product"
+	// supportLevelKey is the ConfigMap key holding the SupportLevel
+	supportLevelKey string = "supportLevel"
+	// customerTierKey is the ConfigMap key holding the CustomerTier
+	customerTierKey string = "customerTier"
+)
+
+var (
+	log = logf.Log.WithName("clustercontext")
+
+	mu      sync.RWMutex
+	current Context
+)
+
+// Context is what OCM knows about the cluster
+type Context struct {
+	Product      Product
+	SupportLevel SupportLevel
+	// CustomerTier is the customer's subscription tier, eg "standard" or
+	// "premium", as named by OCM
+	CustomerTier string
+}
+
+// Parse reads a context from the data of the synced ConfigMap. Missing keys
+// are unknown; unknown products and support levels are an error.
+func Parse(data map[string]string) (Context, error) {
+	ctx := Context{
+		Product:      Product(strings.ToLower(strings.TrimSpace(data[productKey]))),
+		SupportLevel: SupportLevel(strings.ToLower(strings.TrimSpace(data[supportLevelKey]))),
+		CustomerTier: strings.TrimSpace(data[customerTierKey]),
+	}
+	switch ctx.Product {
+	case ProductUnknown, ProductOSD, ProductROSA, ProductROSAHCP:
+	default:
+		return Context{}, fmt.Errorf("unknown product %q", ctx.Product)
+	}
+	switch ctx.SupportLevel {
+	case SupportUnknown, SupportFull, SupportLimited:
+	default:
+		return Context{}, fmt.Errorf("unknown support level %q", ctx.SupportLevel)
+	}
+	return ctx, nil
+}
+
+// Set replaces the context the webhooks use
+func Set(ctx Context) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = ctx
+}
+
+// Current returns the context the webhooks use. Its fields are unknown until
+// OCM syncs the ConfigMap, so webhooks must behave sensibly without them.
+func Current() Context {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// IsProduct checks whether the cluster is known to be one of the products
+func IsProduct(products ...Product) bool {
+	product := Current().Product
+	for _, p := range products {
+		if p != ProductUnknown && p == product {
+			return true
+		}
+	}
+	return false
+}
+
+// IsROSA checks whether the cluster is known to be ROSA, Classic or with
+// hosted control planes
+func IsROSA() bool { return IsProduct(ProductROSA, ProductROSAHCP) }
+
+// IsLimitedSupport checks whether the cluster is known to be in limited
+// support
+func IsLimitedSupport() bool { return Current().SupportLevel == SupportLimited }
@@ -0,0 +1,84 @@
+package clustercontext
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const testNamespace = "openshift-validation-webhook"
+
+func TestParse(t *testing.T) {
+	ctx, err := Parse(map[string]string{"product": " ROSA-HCP", "supportLevel": "limited", "customerTier": "premium"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	expected := Context{Product: ProductROSAHCP, SupportLevel: SupportLimited, CustomerTier: "premium"}
+	if ctx != expected {
+		t.Fatalf("Expected %+v, got %+v", expected, ctx)
+	}
+
+	if ctx, err := Parse(nil); err != nil || ctx != (Context{}) {
+		t.Errorf("Expected an unknown context without data, got %+v, %v", ctx, err)
+	}
+	if _, err := Parse(map[string]string{"product": "aro"}); err == nil {
+		t.Errorf("Expected an unknown product to be rejected")
+	}
+	if _, err := Parse(map[string]string{"supportLevel": "best-effort"}); err == nil {
+		t.Errorf("Expected an unknown support level to be rejected")
+	}
+}
+
+func TestIsProduct(t *testing.T) {
+	t.Cleanup(func() { Set(Context{}) })
+
+	if IsProduct(ProductUnknown) || IsROSA() {
+		t.Errorf("Expected an unknown product not to match")
+	}
+	Set(Context{Product: ProductROSAHCP})
+	if !IsROSA() {
+		t.Errorf("Expected ROSA with hosted control planes to be ROSA")
+	}
+	if IsProduct(ProductOSD, ProductROSA) {
+		t.Errorf("Expected ROSA with hosted control planes not to be OSD or ROSA Classic")
+	}
+}
+
+func TestWatcherSync(t *testing.T) {
+	t.Cleanup(func() { Set(Context{}) })
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: ConfigMapName, Namespace: testNamespace},
+		Data:       map[string]string{"product": "osd", "supportLevel": "full"},
+	}
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(cm).Build()
+	w := NewWatcher(c, testNamespace)
+	ctx := context.Background()
+
+	w.Sync(ctx)
+	if !IsProduct(ProductOSD) || IsLimitedSupport() {
+		t.Fatalf("Expected a fully supported OSD cluster, got %+v", Current())
+	}
+
+	// An invalid context leaves the current one in place
+	cm.Data["product"] = "aro"
+	if err := c.Update(ctx, cm); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	w.Sync(ctx)
+	if !IsProduct(ProductOSD) {
+		t.Fatalf("Expected the previous context to be kept")
+	}
+
+	if err := c.Delete(ctx, cm); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	w.Sync(ctx)
+	if Current() != (Context{}) {
+		t.Fatalf("Expected an unknown context without the ConfigMap, got %+v", Current())
+	}
+}
@@ -0,0 +1,108 @@
+package clustercontext
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/util/wait"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+)
+
+const (
+	// ConfigMapName is the ConfigMap in the webhook namespace, synced by OCM,
+	// holding the cluster's properties
+	ConfigMapName string = "managed-cluster-context"
+
+	// resyncPeriod is how often the ConfigMap is read again, in case an event
+	// was missed
+	resyncPeriod = 5 * time.Minute
+)
+
+// Watcher keeps the context in sync with the ConfigMap
+type Watcher struct {
+	reader    client.Reader
+	client    client.Client
+	namespace string
+}
+
+// NewWatcher creates a Watcher for the ConfigMap in namespace
+func NewWatcher(c client.Client, namespace string) *Watcher {
+	return &Watcher{
+		reader:    c,
+		client:    c,
+		namespace: namespace,
+	}
+}
+
+// Start syncs the context, then keeps it in sync with the ConfigMap until ctx
+// is cancelled
+func (w *Watcher) Start(ctx context.Context) error {
+	config, err := k8sutil.RestConfig()
+	if err != nil {
+		return err
+	}
+	informers, err := cache.New(config, cache.Options{
+		Scheme:            w.client.Scheme(),
+		DefaultNamespaces: map[string]cache.Config{w.namespace: {}},
+		ByObject: map[client.Object]cache.ByObject{
+			&corev1.ConfigMap{}: {Field: fields.OneTermEqualSelector("metadata.name", ConfigMapName)},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	w.reader = informers
+
+	informer, err := informers.GetInformer(ctx, &corev1.ConfigMap{})
+	if err != nil {
+		return err
+	}
+	if _, err := informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { w.Sync(ctx) },
+		UpdateFunc: func(interface{}, interface{}) { w.Sync(ctx) },
+		DeleteFunc: func(interface{}) { w.Sync(ctx) },
+	}); err != nil {
+		return err
+	}
+
+	go func() {
+		if err := informers.Start(ctx); err != nil {
+			log.Error(err, "Cluster context cache stopped")
+		}
+	}()
+	if !informers.WaitForCacheSync(ctx) {
+		return fmt.Errorf("failed to sync cluster context cache")
+	}
+	go wait.UntilWithContext(ctx, w.Sync, resyncPeriod)
+	return nil
+}
+
+// Sync reads the ConfigMap and updates the context. A missing ConfigMap makes
+// the context unknown; an invalid one leaves the context unchanged.
+func (w *Watcher) Sync(ctx context.Context) {
+	cm := &corev1.ConfigMap{}
+	err := w.reader.Get(ctx, client.ObjectKey{Namespace: w.namespace, Name: ConfigMapName}, cm)
+	if err != nil && !apierrors.IsNotFound(err) {
+		log.Error(err, "Failed to read cluster context")
+		return
+	}
+
+	clusterContext, err := Parse(cm.Data)
+	if err != nil {
+		log.Error(err, "Ignoring invalid cluster context", "configMap", ConfigMapName)
+		return
+	}
+	if clusterContext == Current() {
+		return
+	}
+	Set(clusterContext)
+	log.Info("Cluster context changed", "product", clusterContext.Product, "supportLevel", clusterContext.SupportLevel, "customerTier", clusterContext.CustomerTier)
+}
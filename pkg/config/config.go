@@ -8,3 +8,8 @@ import (
 func IsPrivilegedNamespace(ns string) bool {
 	return utils.RegexSliceContains(ns, PrivilegedNamespaces)
 }
+
+// ExcludedNamespaces is the platform namespaces webhooks which only police
+// customer namespaces skip: the privileged namespaces. Webhooks implementing
+// webhooks.NamespaceExclusionWebhook return it, or a copy with exceptions.
+var ExcludedNamespaces = utils.NamespaceExclusion{Namespaces: PrivilegedNamespaces}
@@ -0,0 +1,75 @@
+// Package dependencies bundles what webhooks use besides the request: the
+// client reading the cluster, the Event recorder, metrics and the cluster
+// context. The server builds the Bundle once and hands it to the webhooks
+// answering requests, rather than each webhook creating its own.
+package dependencies
+
+import (
+	"sync"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/clustercontext"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/events"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/localmetrics"
+)
+
+var (
+	mu      sync.RWMutex
+	current Bundle
+)
+
+// Metrics records the metrics webhooks report themselves
+type Metrics interface {
+	// RequestError counts a request the webhook couldn't answer, by reason
+	RequestError(webhook, reason string)
+}
+
+// Bundle is what the server provides the webhooks answering requests. Its zero
+// value is usable: fields left unset are defaulted by WithDefaults.
+type Bundle struct {
+	// Client reads the cluster. When nil, webhooks which read the cluster
+	// create their own client, as they did before the Bundle.
+	Client client.Client
+	// Recorder records Events. A nil Recorder records nothing.
+	Recorder *events.Recorder
+	// Metrics records the webhooks' own metrics, to localmetrics unless set
+	Metrics Metrics
+	// ClusterContext returns what OCM knows about the cluster,
+	// clustercontext.Current unless set
+	ClusterContext func() clustercontext.Context
+}
+
+// WithDefaults returns the Bundle with its unset Metrics and ClusterContext
+// set to the server's
+func (b Bundle) WithDefaults() Bundle {
+	if b.Metrics == nil {
+		b.Metrics = localMetrics{}
+	}
+	if b.ClusterContext == nil {
+		b.ClusterContext = clustercontext.Current
+	}
+	return b
+}
+
+// Set sets the Bundle returned by Current. It must be called before any
+// requests are served.
+func Set(b Bundle) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = b
+}
+
+// Current returns the Bundle set by Set, with defaults
+func Current() Bundle {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current.WithDefaults()
+}
+
+// localMetrics records metrics with localmetrics
+type localMetrics struct{}
+
+func (localMetrics) RequestError(webhook, reason string) {
+	localmetrics.IncrementWebhookRequestError(webhook, reason)
+}
@@ -0,0 +1,176 @@
+package dispatcher
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	// DecisionCacheSizeEnvVar is how many decisions of cacheable webhooks are
+	// kept to answer identical requests with. 0 disables the cache. Defaults
+	// to defaultDecisionCacheSize.
+	DecisionCacheSizeEnvVar = "WEBHOOK_DECISION_CACHE_SIZE"
+
+	defaultDecisionCacheSize = 1000
+)
+
+// volatileMetadata are the metadata fields which differ between otherwise
+// identical objects, such as the pods of one ReplicaSet
+var volatileMetadata = []string{"name", "uid", "resourceVersion", "creationTimestamp", "managedFields", "selfLink"}
+
+// decisionKey is what a cacheable webhook's decision depends on
+type decisionKey struct {
+	Webhook     string                                 `json:"webhook"`
+	Operation   string                                 `json:"operation"`
+	Kind        metav1.GroupVersionKind                `json:"kind"`
+	SubResource string                                 `json:"subResource"`
+	Namespace   string                                 `json:"namespace"`
+	User        string                                 `json:"user"`
+	Groups      []string                               `json:"groups"`
+	Extra       map[string]authenticationv1.ExtraValue `json:"extra"`
+	DryRun      bool                                   `json:"dryRun"`
+	Object      map[string]interface{}                 `json:"object"`
+	OldObject   map[string]interface{}                 `json:"oldObject"`
+}
+
+// keyOf hashes what the webhook's decision on the request depends on: the
+// request without the name, UID and other metadata which differ between
+// otherwise identical objects. It returns false if the request's objects
+// can't be read.
+func keyOf(webhook string, request admissionctl.Request) ([sha256.Size]byte, bool) {
+	key := decisionKey{
+		Webhook:     webhook,
+		Operation:   string(request.Operation),
+		Kind:        request.Kind,
+		SubResource: request.SubResource,
+		Namespace:   request.Namespace,
+		User:        request.UserInfo.Username,
+		Groups:      request.UserInfo.Groups,
+		Extra:       request.UserInfo.Extra,
+		DryRun:      request.DryRun != nil && *request.DryRun,
+	}
+	var ok bool
+	if key.Object, ok = withoutVolatileMetadata(request.Object.Raw); !ok {
+		return [sha256.Size]byte{}, false
+	}
+	if key.OldObject, ok = withoutVolatileMetadata(request.OldObject.Raw); !ok {
+		return [sha256.Size]byte{}, false
+	}
+	// maps are marshalled with sorted keys, so equal keys hash the same
+	raw, err := json.Marshal(key)
+	if err != nil {
+		return [sha256.Size]byte{}, false
+	}
+	return sha256.Sum256(raw), true
+}
+
+// withoutVolatileMetadata decodes the object without its volatileMetadata
+func withoutVolatileMetadata(raw []byte) (map[string]interface{}, bool) {
+	if len(raw) == 0 {
+		return nil, true
+	}
+	obj := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, false
+	}
+	if metadata, ok := obj["metadata"].(map[string]interface{}); ok {
+		for _, field := range volatileMetadata {
+			delete(metadata, field)
+		}
+	}
+	return obj, true
+}
+
+// decision is a cached response
+type decision struct {
+	response admissionctl.Response
+	expires  time.Time
+}
+
+// decisionCache holds the recent decisions of cacheable webhooks. Only
+// requests they allowed, with or without a patch, are cached: denials are
+// always evaluated, so their messages and audit records are about the
+// request's own object.
+type decisionCache struct {
+	mu        sync.Mutex
+	size      int
+	decisions map[[sha256.Size]byte]decision
+}
+
+// newDecisionCache creates a cache of size decisions, or a nil cache, which
+// holds none, when size is 0
+func newDecisionCache(size int) *decisionCache {
+	if size <= 0 {
+		return nil
+	}
+	return &decisionCache{size: size, decisions: map[[sha256.Size]byte]decision{}}
+}
+
+// decisionCacheSizeFromEnv parses the cache size from DecisionCacheSizeEnvVar,
+// where 0 disables the cache, falling back to defaultDecisionCacheSize
+func decisionCacheSizeFromEnv() int {
+	value := strings.TrimSpace(os.Getenv(DecisionCacheSizeEnvVar))
+	if value == "" {
+		return defaultDecisionCacheSize
+	}
+	size, err := strconv.Atoi(value)
+	if err != nil || size < 0 {
+		log.Info("Ignoring invalid decision cache size", "envVar", DecisionCacheSizeEnvVar, "value", value, "default", defaultDecisionCacheSize)
+		return defaultDecisionCacheSize
+	}
+	return size
+}
+
+// get returns the cached decision for the key, answering the request uid
+func (c *decisionCache) get(key [sha256.Size]byte, uid types.UID, now time.Time) (admissionctl.Response, bool) {
+	if c == nil {
+		return admissionctl.Response{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	d, ok := c.decisions[key]
+	if !ok {
+		return admissionctl.Response{}, false
+	}
+	if !now.Before(d.expires) {
+		delete(c.decisions, key)
+		return admissionctl.Response{}, false
+	}
+	ret := d.response
+	ret.UID = uid
+	return ret, true
+}
+
+// set caches an allowed response for ttl. When the cache is full, expired
+// decisions are dropped, and then arbitrary ones if it's still full.
+func (c *decisionCache) set(key [sha256.Size]byte, response admissionctl.Response, ttl time.Duration, now time.Time) {
+	if c == nil || !response.Allowed {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.decisions) >= c.size {
+		for k, d := range c.decisions {
+			if !now.Before(d.expires) {
+				delete(c.decisions, k)
+			}
+		}
+		for k := range c.decisions {
+			if len(c.decisions) < c.size {
+				break
+			}
+			delete(c.decisions, k)
+		}
+	}
+	c.decisions[key] = decision{response: response, expires: now.Add(ttl)}
+}
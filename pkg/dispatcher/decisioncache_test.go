@@ -0,0 +1,109 @@
+package dispatcher
+
+import (
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks"
+)
+
+func podRequest(t *testing.T, name, uid, image, username string) admissionctl.Request {
+	raw := []byte(`{"metadata":{"name":"` + name + `","uid":"` + uid + `","generateName":"api-7d4b9c-","labels":{"app":"api"}},` +
+		`"spec":{"containers":[{"name":"api","image":"` + image + `"}]}}`)
+	return testutils.NewRequest(t, metav1.GroupVersionKind{Version: "v1", Kind: "Pod"}, admissionv1.Create, authenticationv1.UserInfo{Username: username}, "payments", "", raw, nil)
+}
+
+func TestKeyOf(t *testing.T) {
+	key, ok := keyOf("pod-validation", podRequest(t, "api-7d4b9c-x2x8k", "uid-1", "quay.io/acme/api:v1", "replicaset-controller"))
+	if !ok {
+		t.Fatalf("Expected the request to be cacheable")
+	}
+	tests := []struct {
+		name    string
+		webhook string
+		request admissionctl.Request
+		same    bool
+	}{
+		{"sibling pod", "pod-validation", podRequest(t, "api-7d4b9c-9fzq2", "uid-2", "quay.io/acme/api:v1", "replicaset-controller"), true},
+		{"other image", "pod-validation", podRequest(t, "api-7d4b9c-9fzq2", "uid-2", "quay.io/acme/api:v2", "replicaset-controller"), false},
+		{"other user", "pod-validation", podRequest(t, "api-7d4b9c-9fzq2", "uid-2", "quay.io/acme/api:v1", "customer"), false},
+		{"other webhook", "hostaccess-validation", podRequest(t, "api-7d4b9c-9fzq2", "uid-2", "quay.io/acme/api:v1", "replicaset-controller"), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			other, ok := keyOf(test.webhook, test.request)
+			if !ok {
+				t.Fatalf("Expected the request to be cacheable")
+			}
+			if (other == key) != test.same {
+				t.Errorf("Expected the keys to be equal %v, got %v", test.same, other == key)
+			}
+		})
+	}
+
+	invalid := podRequest(t, "api", "uid", "api", "customer")
+	invalid.Object.Raw = []byte("{")
+	if _, ok := keyOf("pod-validation", invalid); ok {
+		t.Errorf("Expected a request with an invalid object not to be cacheable")
+	}
+}
+
+func TestDecisionCache(t *testing.T) {
+	disabled := newDecisionCache(0)
+	disabled.set([32]byte{'a'}, admissionctl.Allowed("ok"), time.Second, time.Now())
+	if _, ok := disabled.get([32]byte{'a'}, "uid-1", time.Now()); ok {
+		t.Fatalf("Expected a size of 0 to disable the cache")
+	}
+	c := newDecisionCache(2)
+	now := time.Now()
+	keyA, keyB, keyC := [32]byte{'a'}, [32]byte{'b'}, [32]byte{'c'}
+
+	c.set(keyA, admissionctl.Allowed("ok"), time.Second, now)
+	c.set(keyB, admissionctl.Denied("no"), time.Second, now)
+	if response, ok := c.get(keyA, "uid-2", now); !ok || !response.Allowed || response.UID != "uid-2" {
+		t.Errorf("Expected the allowed decision to answer uid-2, got %v %v", ok, response)
+	}
+	if _, ok := c.get(keyB, "uid-2", now); ok {
+		t.Errorf("Expected the denial not to be cached")
+	}
+	if _, ok := c.get(keyA, "uid-3", now.Add(time.Second)); ok {
+		t.Errorf("Expected the decision to expire")
+	}
+
+	// full caches drop expired decisions first
+	c.set(keyA, admissionctl.Allowed("ok"), time.Second, now)
+	c.set(keyB, admissionctl.Allowed("ok"), time.Minute, now)
+	c.set(keyC, admissionctl.Allowed("ok"), time.Minute, now.Add(2*time.Second))
+	if _, ok := c.get(keyB, "uid-4", now.Add(2*time.Second)); !ok {
+		t.Errorf("Expected the unexpired decision to be kept")
+	}
+	if len(c.decisions) != 2 {
+		t.Errorf("Expected the cache to hold 2 decisions, got %d", len(c.decisions))
+	}
+}
+
+func TestDecisionCacheSizeFromEnv(t *testing.T) {
+	for value, expected := range map[string]int{
+		"":    defaultDecisionCacheSize,
+		"0":   0,
+		"50":  50,
+		"-1":  defaultDecisionCacheSize,
+		"lot": defaultDecisionCacheSize,
+	} {
+		t.Setenv(DecisionCacheSizeEnvVar, value)
+		if size := decisionCacheSizeFromEnv(); size != expected {
+			t.Errorf("Expected %q to set a size of %d, got %d", value, expected, size)
+		}
+	}
+
+	t.Setenv(DecisionCacheSizeEnvVar, "0")
+	if d := NewDispatcher(webhooks.RegisteredWebhooks{}); d.decisions != nil {
+		t.Errorf("Expected %s=0 to disable the decision cache", DecisionCacheSizeEnvVar)
+	}
+}
@@ -1,36 +1,123 @@
 package dispatcher
 
 import (
+	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
-	"sync"
+	"os"
+	"strings"
+	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/audit"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/dependencies"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/featuregates"
 	responsehelper "github.com/openshift/managed-cluster-validating-webhooks/pkg/helpers"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/localmetrics"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/notify"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/tracing"
 	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks"
 	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
 )
 
-var log = logf.Log.WithName("dispatcher")
+// AuditOnlyEnvVar is a comma-separated list of webhook names which run in
+// audit-only mode: their decisions are logged and counted but every request
+// is allowed unchanged.
+const AuditOnlyEnvVar = "AUDIT_ONLY_WEBHOOKS"
+
+const (
+	// MaxRequestBytesEnvVar is the largest AdmissionReview body, in bytes, the
+	// webhooks accept. Larger requests are rejected before they're read in
+	// full. Defaults to defaultMaxRequestBytes.
+	MaxRequestBytesEnvVar = "WEBHOOK_MAX_REQUEST_BYTES"
+
+	// defaultMaxRequestBytes fits an AdmissionReview of an UPDATE of the
+	// largest object etcd stores, which carries both the old and new object
+	defaultMaxRequestBytes = 7 * 1024 * 1024
+)
+
+var (
+	log = logf.Log.WithName("dispatcher")
+
+	// timeoutMargin is how long before the API server gives up on a webhook
+	// the dispatcher stops waiting for it and answers on its behalf, leaving
+	// time for the response to reach the API server
+	timeoutMargin = 250 * time.Millisecond
+
+	// underBackpressure reports whether the API server is throttling the
+	// webhooks' clients
+	underBackpressure = k8sutil.UnderBackpressure
+)
 
 // Dispatcher struct
 type Dispatcher struct {
-	hooks *map[string]webhooks.WebhookFactory // uri -> hookfactory
-	mu    sync.Mutex
+	hooks     *map[string]webhooks.WebhookFactory // uri -> hookfactory
+	auditOnly map[string]bool                     // webhook name -> audit-only
+	limiters  map[string]*limiter                 // webhook name -> limiter
+	auditor   *audit.Auditor
+	notifier  *notify.Broker
+	decisions *decisionCache // nil when disabled
+
+	maxRequestBytes int64
 }
 
 // NewDispatcher new dispatcher
 func NewDispatcher(hooks webhooks.RegisteredWebhooks) *Dispatcher {
+	maxInFlight := limitFromEnv(MaxInFlightEnvVar, defaultMaxInFlight)
+	maxQueued := limitFromEnv(MaxQueuedEnvVar, defaultMaxQueued)
 	hookMap := make(map[string]webhooks.WebhookFactory)
-	for _, hook := range hooks {
+	limiters := make(map[string]*limiter)
+	for name, hook := range hooks {
 		hookMap[hook().GetURI()] = hook
+		limiters[name] = newLimiter(maxInFlight, maxQueued)
+	}
+	auditOnly := auditOnlyWebhooks(os.Getenv(AuditOnlyEnvVar))
+	for name := range auditOnly {
+		log.Info("Webhook is running in audit-only mode", "webhookName", name)
 	}
 	return &Dispatcher{
-		hooks: &hookMap,
+		hooks:     &hookMap,
+		auditOnly: auditOnly,
+		limiters:  limiters,
+		decisions: newDecisionCache(decisionCacheSizeFromEnv()),
+
+		maxRequestBytes: int64(limitFromEnv(MaxRequestBytesEnvVar, defaultMaxRequestBytes)),
+	}
+}
+
+// SetAuditor sets the Auditor recording the requests webhooks deny. It must
+// be called before any requests are served.
+func (d *Dispatcher) SetAuditor(a *audit.Auditor) {
+	d.auditor = a
+}
+
+// SetNotifier sets the Broker notifying SRE of security-sensitive denials. It
+// must be called before any requests are served.
+func (d *Dispatcher) SetNotifier(b *notify.Broker) {
+	d.notifier = b
+}
+
+// auditOnlyWebhooks parses the comma-separated list of audit-only webhooks
+func auditOnlyWebhooks(names string) map[string]bool {
+	auditOnly := make(map[string]bool)
+	for _, name := range strings.Split(names, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			auditOnly[name] = true
+		}
 	}
+	return auditOnly
 }
 
 // HandleRequest http request
@@ -41,8 +128,6 @@ func NewDispatcher(hooks webhooks.RegisteredWebhooks) *Dispatcher {
 // request, or some internal problem) it is appropriate to use the HTTP status
 // code to communicate.
 func (d *Dispatcher) HandleRequest(w http.ResponseWriter, r *http.Request) {
-	d.mu.Lock()
-	defer d.mu.Unlock()
 	log.V(1).Info("Handling request", "request", r.RequestURI)
 	url, err := url.Parse(r.RequestURI)
 	if err != nil {
@@ -53,19 +138,25 @@ func (d *Dispatcher) HandleRequest(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// is it one of ours?
-	if hook, ok := (*d.hooks)[url.Path]; ok {
-		// it's one of ours, so let's attempt to parse the request
+	if hookFactory, ok := (*d.hooks)[url.Path]; ok {
+		hook := hookFactory()
+		// it's one of ours, so let's attempt to parse the request, without
+		// reading more of it than any AdmissionReview needs
+		r.Body = http.MaxBytesReader(w, r.Body, d.maxRequestBytes)
 		request, _, err := utils.ParseHTTPRequest(r)
 		// Problem even parsing an AdmissionReview, so use HTTP status code
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			log.Error(err, "Error parsing HTTP Request Body")
-			responsehelper.SendResponse(w, admissionctl.Errored(http.StatusBadRequest, err))
+			status, reason := parseErrorStatus(err)
+			localmetrics.IncrementWebhookRequestError(hook.Name(), reason)
+			w.WriteHeader(status)
+			log.Error(err, "Error parsing HTTP Request Body", "reason", reason)
+			responsehelper.SendResponse(w, admissionctl.Errored(int32(status), err))
 			return
 		}
 		// Valid AdmissionReview, but we can't do anything with it because we do not
 		// think the request inside is valid.
-		if !hook().Validate(request) {
+		if !hook.Validate(request) {
+			localmetrics.IncrementWebhookRequestError(hook.Name(), "invalid")
 			err = fmt.Errorf("not a valid webhook request")
 			log.Error(err, "Error validaing HTTP Request Body")
 			responsehelper.SendResponse(w,
@@ -73,8 +164,9 @@ func (d *Dispatcher) HandleRequest(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		// Dispatch
-		responsehelper.SendResponse(w, hook().Authorized(request))
+		// Dispatch, continuing the API server's trace if it sent one
+		ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.HeaderCarrier(r.Header))
+		responsehelper.SendResponse(w, d.authorize(ctx, hook, request))
 		return
 	}
 	log.Info("Request is not for a registered webhook.", "known_hooks", *d.hooks, "parsed_url", url, "lookup", (*d.hooks)[url.Path])
@@ -86,3 +178,240 @@ func (d *Dispatcher) HandleRequest(w http.ResponseWriter, r *http.Request) {
 		admissionctl.Errored(http.StatusBadRequest,
 			fmt.Errorf("request is not for a registered webhook")))
 }
+
+// parseErrorStatus returns the HTTP status code and metric reason for an
+// error parsing a request
+func parseErrorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, utils.ErrRequestTooLarge):
+		return http.StatusRequestEntityTooLarge, "too-large"
+	case errors.Is(err, utils.ErrUnsupportedContentType):
+		return http.StatusUnsupportedMediaType, "content-type"
+	default:
+		return http.StatusBadRequest, "parse"
+	}
+}
+
+// authorize hands the request to the webhook, recording metrics and a trace
+// span about the response so every registered webhook is instrumented the
+// same way
+func (d *Dispatcher) authorize(ctx context.Context, hook webhooks.Webhook, request admissionctl.Request) admissionctl.Response {
+	// The API server may still call a webhook shortly after it's disabled
+	if !featuregates.Enabled(hook.Name()) {
+		ret := admissionctl.Allowed(fmt.Sprintf("Webhook %s is disabled on this cluster", hook.Name()))
+		ret.UID = request.UID
+		return ret
+	}
+	// The namespaceSelector of a canary can't restrict requests for
+	// cluster-scoped objects, so they're only enforced once it's promoted
+	if request.Namespace == "" && webhooks.IsCanary(hook) {
+		ret := admissionctl.Allowed(fmt.Sprintf("Webhook %s is a canary, only enforced in namespaces labelled %s=true", hook.Name(), webhooks.CanaryLabel))
+		ret.UID = request.UID
+		return ret
+	}
+
+	ctx, span := tracing.Start(ctx, "admission "+hook.Name(),
+		attribute.String("webhook", hook.Name()),
+		attribute.String("k8s.uid", string(request.UID)),
+		attribute.String("k8s.operation", string(request.Operation)),
+		attribute.String("k8s.resource", request.Resource.Resource),
+		attribute.String("k8s.namespace", request.Namespace),
+		attribute.String("k8s.name", request.Name),
+	)
+	defer span.End()
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, latencyBudget(hook))
+	defer cancel()
+
+	var response admissionctl.Response
+	ttl := webhooks.DecisionTTL(hook)
+	var key [sha256.Size]byte
+	cacheable := d.decisions != nil && ttl > 0
+	if cacheable {
+		key, cacheable = keyOf(hook.Name(), request)
+	}
+	cached := false
+	if cacheable {
+		response, cached = d.decisions.get(key, request.UID, start)
+	}
+	if cached {
+		span.AddEvent("cached")
+		localmetrics.IncrementWebhookDecisionCacheHit(hook.Name())
+	} else if shedsUnderBackpressure(hook) && underBackpressure() {
+		span.AddEvent("backpressure")
+		response = backpressureResponse(hook, request)
+	} else if l := d.limiters[hook.Name()]; l != nil && !l.acquire(ctx) {
+		span.AddEvent("shed")
+		response = shedResponse(hook, request)
+	} else {
+		// the slot is held until the webhook returns, which may be after
+		// it ran out of time and was answered for
+		done := func() {}
+		if l != nil {
+			done = l.release
+		}
+		response = authorizeWithinBudget(ctx, hook, request, done)
+		// a response made for the webhook because it ran out of time isn't
+		// its decision
+		if cacheable && ctx.Err() == nil {
+			d.decisions.set(key, response, ttl, start)
+		}
+	}
+	if d.auditOnly[hook.Name()] {
+		response = auditOnlyResponse(hook.Name(), request, response)
+	}
+	response = warned(hook.Name(), response)
+	if localmetrics.ResponseOutcome(response) == localmetrics.OutcomeDenied {
+		record := audit.NewRecord(hook.Name(), request, response)
+		d.auditor.Record(record)
+		d.notifier.Denied(record)
+	}
+	localmetrics.ObserveWebhookResponse(hook.Name(), request, response, time.Since(start))
+	span.SetAttributes(attribute.String("outcome", localmetrics.ResponseOutcome(response)))
+	return response
+}
+
+// authorizeWithinBudget runs the webhook until ctx's deadline, shortly before
+// the API server's timeout, so a slow webhook still answers (according to its
+// FailurePolicy) instead of the API server timing out without us knowing.
+// done is called once the webhook returns, even after the deadline.
+func authorizeWithinBudget(ctx context.Context, hook webhooks.Webhook, request admissionctl.Request, done func()) admissionctl.Response {
+	// buffered so a webhook which finishes after the deadline doesn't block
+	responses := make(chan admissionctl.Response, 1)
+	go func() {
+		defer done()
+		defer func() {
+			if r := recover(); r != nil {
+				log.Error(fmt.Errorf("%v", r), "Webhook panicked", "webhookName", hook.Name())
+				ret := admissionctl.Errored(http.StatusInternalServerError, fmt.Errorf("webhook %s failed", hook.Name()))
+				ret.UID = request.UID
+				responses <- ret
+			}
+		}()
+		responses <- webhooks.Admit(ctx, hook, dependencies.Current(), request)
+	}()
+
+	select {
+	case response := <-responses:
+		return response
+	case <-ctx.Done():
+		trace.SpanFromContext(ctx).AddEvent("timeout")
+		return timeoutResponse(hook, request)
+	}
+}
+
+// latencyBudget is how long the webhook has to answer: its timeout less
+// timeoutMargin
+func latencyBudget(hook webhooks.Webhook) time.Duration {
+	timeout := time.Duration(hook.TimeoutSeconds()) * time.Second
+	if timeout <= 0 {
+		// the API server's default
+		timeout = 10 * time.Second
+	}
+	if timeout <= timeoutMargin {
+		return timeout
+	}
+	return timeout - timeoutMargin
+}
+
+// timeoutResponse answers for a webhook which ran out of time the way the API
+// server would have: allow when it fails open, deny when it fails closed
+func timeoutResponse(hook webhooks.Webhook, request admissionctl.Request) admissionctl.Response {
+	localmetrics.IncrementWebhookTimeout(hook.Name())
+	log.Info("Webhook exceeded its latency budget", "webhookName", hook.Name(), "uid", request.UID, "budget", latencyBudget(hook).String())
+
+	var ret admissionctl.Response
+	if webhooks.FailurePolicy(hook) == admissionregv1.Ignore {
+		ret = admissionctl.Allowed(fmt.Sprintf("Webhook %s timed out, allowing request", hook.Name()))
+	} else {
+		ret = response.Denied(response.WebhookTimeout, fmt.Sprintf("Webhook %s timed out, denying request. Please try again later.", hook.Name()))
+	}
+	ret.UID = request.UID
+	return ret
+}
+
+// shedResponse answers for a webhook with too many requests waiting: allow
+// when it fails open, and tell the client to retry when it fails closed
+func shedResponse(hook webhooks.Webhook, request admissionctl.Request) admissionctl.Response {
+	localmetrics.IncrementWebhookShed(hook.Name())
+	log.V(1).Info("Webhook is overloaded, shedding request", "webhookName", hook.Name(), "uid", request.UID)
+
+	var ret admissionctl.Response
+	if webhooks.FailurePolicy(hook) == admissionregv1.Ignore {
+		ret = admissionctl.Allowed(fmt.Sprintf("Webhook %s is overloaded, allowing request", hook.Name()))
+	} else {
+		ret = admissionctl.Errored(http.StatusTooManyRequests, fmt.Errorf("webhook %s is overloaded, please try again later", hook.Name()))
+	}
+	ret.UID = request.UID
+	return ret
+}
+
+// shedsUnderBackpressure returns true if the webhook is answered without
+// evaluation while the API server throttles: it reads from the cluster, so
+// would likely wait on the API server, and fails open anyway
+func shedsUnderBackpressure(hook webhooks.Webhook) bool {
+	_, reads := hook.(webhooks.CachingWebhook)
+	return reads && webhooks.FailurePolicy(hook) == admissionregv1.Ignore
+}
+
+// backpressureResponse allows the request for a webhook which fails open
+// while the API server throttles, instead of queueing its reads until the
+// webhook's timeout
+func backpressureResponse(hook webhooks.Webhook, request admissionctl.Request) admissionctl.Response {
+	localmetrics.IncrementWebhookBackpressure(hook.Name())
+	log.V(1).Info("API server is throttling, allowing request", "webhookName", hook.Name(), "uid", request.UID)
+
+	ret := admissionctl.Allowed(fmt.Sprintf("API server is throttling webhook %s, allowing request", hook.Name()))
+	ret.UID = request.UID
+	return ret
+}
+
+// auditOnlyResponse logs and counts what an audit-only webhook would have
+// done with the request, and allows it unchanged instead
+func auditOnlyResponse(name string, request admissionctl.Request, resp admissionctl.Response) admissionctl.Response {
+	outcome := localmetrics.ResponseOutcome(resp)
+	if outcome == localmetrics.OutcomeAllowed {
+		return resp
+	}
+
+	var reason string
+	if resp.Result != nil {
+		reason = resp.Result.Message
+	}
+	localmetrics.IncrementWebhookAuditOnly(name, outcome)
+	log.Info("Audit-only webhook allowed a request it would otherwise have "+outcome,
+		"webhookName", name,
+		"uid", request.UID,
+		"user", request.UserInfo.Username,
+		"operation", request.Operation,
+		"resource", request.Resource.Resource,
+		"namespace", request.Namespace,
+		"name", request.Name,
+		"code", response.CodeOf(resp),
+		"reason", reason)
+
+	// tell the client what the webhook would have done, so customers see
+	// what's coming before it's enforced
+	warning := fmt.Sprintf("%s is in audit-only mode, and would have %s this request", name, outcome)
+	if code := response.CodeOf(resp); code != "" {
+		warning += fmt.Sprintf(" (%s)", code)
+	}
+	if reason != "" {
+		warning += ": " + reason
+	}
+	ret := admissionctl.Allowed(fmt.Sprintf("Audit-only mode: request would have been %s", outcome))
+	ret = ret.WithWarnings(resp.Warnings...).WithWarnings(warning)
+	ret.UID = request.UID
+	return ret
+}
+
+// warned makes the response's warnings what the API server returns to the
+// client, and counts them
+func warned(name string, resp admissionctl.Response) admissionctl.Response {
+	resp.Warnings = response.Warnings(resp.Warnings)
+	if len(resp.Warnings) > 0 {
+		localmetrics.AddWebhookWarnings(name, len(resp.Warnings))
+	}
+	return resp
+}
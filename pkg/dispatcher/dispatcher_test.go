@@ -0,0 +1,417 @@
+package dispatcher
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/audit"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/hiveownership"
+)
+
+const managedQuotaRaw string = `{
+	"metadata": {
+	  "name": "managed-quota",
+	  "uid": "managed-quota-uid",
+	  "labels": {"hive.openshift.io/managed": "true"}
+	}
+  }`
+
+// sendDeniedRequest sends a request which hiveownership-validation denies
+// through the dispatcher and returns the response
+func sendDeniedRequest(t *testing.T, d *Dispatcher) *admissionv1.AdmissionResponse {
+	gvk := metav1.GroupVersionKind{Group: "quota.openshift.io", Version: "v1", Kind: "ClusterResourceQuota"}
+	gvr := metav1.GroupVersionResource{Group: "quota.openshift.io", Version: "v1", Resource: "clusterresourcequotas"}
+	obj := &runtime.RawExtension{Raw: []byte(managedQuotaRaw)}
+	req, err := testutils.CreateHTTPRequest("/"+hiveownership.WebhookName, "test-uid", gvk, gvr, admissionv1.Update,
+		"unpriv-user", []string{"system:authenticated"}, "", obj, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+
+	w := httptest.NewRecorder()
+	d.HandleRequest(w, req)
+	review := &admissionv1.AdmissionReview{}
+	if err := json.Unmarshal(w.Body.Bytes(), review); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	return review.Response
+}
+
+func newTestDispatcher() *Dispatcher {
+	return NewDispatcher(webhooks.RegisteredWebhooks{
+		hiveownership.WebhookName: func() webhooks.Webhook { return hiveownership.NewWebhook() },
+	})
+}
+
+func TestHandleRequest(t *testing.T) {
+	response := sendDeniedRequest(t, newTestDispatcher())
+	if response.Allowed {
+		t.Fatalf("Expected request to be denied")
+	}
+	if response.UID != "test-uid" {
+		t.Fatalf("Expected response UID test-uid, got %s", response.UID)
+	}
+}
+
+func TestHandleRequestAuditOnly(t *testing.T) {
+	t.Setenv(AuditOnlyEnvVar, hiveownership.WebhookName)
+	response := sendDeniedRequest(t, newTestDispatcher())
+	if !response.Allowed {
+		t.Fatalf("Expected audit-only webhook to allow the request")
+	}
+	if response.UID != "test-uid" {
+		t.Fatalf("Expected response UID test-uid, got %s", response.UID)
+	}
+	if len(response.Warnings) != 1 || !strings.HasPrefix(response.Warnings[0], "hiveownership-validation is in audit-only mode, and would have denied this request (ManagedResource): ") {
+		t.Errorf("Expected a warning about what the webhook would have done, got %q", response.Warnings)
+	}
+}
+
+func TestHandleRequestCanary(t *testing.T) {
+	webhooks.SetCanaries(webhooks.Canaries{hiveownership.WebhookName: true})
+	t.Cleanup(func() { webhooks.SetCanaries(webhooks.Canaries{}) })
+	// the ClusterResourceQuota is cluster-scoped, so the canary's
+	// namespaceSelector can't exclude it
+	response := sendDeniedRequest(t, newTestDispatcher())
+	if !response.Allowed {
+		t.Fatalf("Expected the canary to allow a request for a cluster-scoped object")
+	}
+}
+
+func TestHandleRequestRejectsBadBodies(t *testing.T) {
+	t.Setenv(MaxRequestBytesEnvVar, "1024")
+	d := newTestDispatcher()
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+	}{
+		{"too large", "application/json", `{"padding":"` + strings.Repeat("x", 2048) + `"}`, http.StatusRequestEntityTooLarge},
+		{"wrong content type", "text/plain", "{}", http.StatusUnsupportedMediaType},
+		{"malformed", "application/json", "{not json", http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/"+hiveownership.WebhookName, strings.NewReader(test.body))
+			r.Header.Set("Content-Type", test.contentType)
+			w := httptest.NewRecorder()
+			d.HandleRequest(w, r)
+			if w.Code != test.status {
+				t.Errorf("Expected status %d, got %d", test.status, w.Code)
+			}
+			review := &admissionv1.AdmissionReview{}
+			if err := json.Unmarshal(w.Body.Bytes(), review); err != nil {
+				t.Fatalf("Expected an AdmissionReview, got %s", w.Body.String())
+			}
+			if review.Response == nil || review.Response.Result == nil || review.Response.Result.Code != int32(test.status) {
+				t.Errorf("Expected the AdmissionReview to report status %d, got %+v", test.status, review.Response)
+			}
+		})
+	}
+}
+
+func TestAuditOnlyWebhooks(t *testing.T) {
+	tests := []struct {
+		names    string
+		expected map[string]bool
+	}{
+		{
+			names:    "",
+			expected: map[string]bool{},
+		},
+		{
+			names:    "namespace-validation, podimagespec-mutation,",
+			expected: map[string]bool{"namespace-validation": true, "podimagespec-mutation": true},
+		},
+	}
+
+	for _, test := range tests {
+		if actual := auditOnlyWebhooks(test.names); !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("auditOnlyWebhooks(%q): expected %v, got %v", test.names, test.expected, actual)
+		}
+	}
+}
+
+// slowHook is hiveownership-validation taking longer than its latency budget
+type slowHook struct {
+	*hiveownership.HiveOwnershipWebhook
+	failurePolicy admissionregv1.FailurePolicyType
+}
+
+func (h *slowHook) FailurePolicy() admissionregv1.FailurePolicyType { return h.failurePolicy }
+
+func (h *slowHook) AuthorizedContext(ctx context.Context, request admissionctl.Request) admissionctl.Response {
+	<-ctx.Done()
+	time.Sleep(10 * time.Millisecond)
+	return h.Authorized(request)
+}
+
+func TestHandleRequestTimeout(t *testing.T) {
+	timeoutMargin = 900 * time.Millisecond
+	t.Cleanup(func() { timeoutMargin = 250 * time.Millisecond })
+
+	tests := []struct {
+		failurePolicy admissionregv1.FailurePolicyType
+		allowed       bool
+	}{
+		{failurePolicy: admissionregv1.Ignore, allowed: true},
+		{failurePolicy: admissionregv1.Fail, allowed: false},
+	}
+	for _, test := range tests {
+		t.Run(string(test.failurePolicy), func(t *testing.T) {
+			d := NewDispatcher(webhooks.RegisteredWebhooks{
+				hiveownership.WebhookName: func() webhooks.Webhook {
+					return &slowHook{HiveOwnershipWebhook: hiveownership.NewWebhook(), failurePolicy: test.failurePolicy}
+				},
+			})
+			response := sendDeniedRequest(t, d)
+			if response.Allowed != test.allowed {
+				t.Fatalf("Expected allowed %v on timeout, got %v", test.allowed, response.Allowed)
+			}
+			if response.Result == nil || !strings.Contains(response.Result.Message, "timed out") {
+				t.Fatalf("Expected a timeout response, got %v", response.Result)
+			}
+			if response.UID != "test-uid" {
+				t.Fatalf("Expected response UID test-uid, got %s", response.UID)
+			}
+		})
+	}
+}
+
+// heldHook is hiveownership-validation which doesn't return before unblocked,
+// whatever its latency budget
+type heldHook struct {
+	*hiveownership.HiveOwnershipWebhook
+	unblock <-chan struct{}
+}
+
+func (h *heldHook) AuthorizedContext(ctx context.Context, request admissionctl.Request) admissionctl.Response {
+	<-h.unblock
+	return h.Authorized(request)
+}
+
+func TestHandleRequestTimeoutHoldsInFlightSlot(t *testing.T) {
+	timeoutMargin = 1900 * time.Millisecond
+	t.Cleanup(func() { timeoutMargin = 250 * time.Millisecond })
+	t.Setenv(MaxInFlightEnvVar, "1")
+
+	unblock := make(chan struct{})
+	d := NewDispatcher(webhooks.RegisteredWebhooks{
+		hiveownership.WebhookName: func() webhooks.Webhook {
+			return &heldHook{HiveOwnershipWebhook: hiveownership.NewWebhook(), unblock: unblock}
+		},
+	})
+	l := d.limiters[hiveownership.WebhookName]
+
+	response := sendDeniedRequest(t, d)
+	if response.Result == nil || !strings.Contains(response.Result.Message, "timed out") {
+		t.Fatalf("Expected a timeout response, got %v", response.Result)
+	}
+	// the webhook is still running, so its slot isn't free
+	if len(l.inFlight) != 1 {
+		t.Fatalf("Expected the timed out webhook to hold its in-flight slot, got %d in flight", len(l.inFlight))
+	}
+
+	close(unblock)
+	deadline := time.Now().Add(time.Second)
+	for len(l.inFlight) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the in-flight slot to be released once the webhook returned")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLatencyBudget(t *testing.T) {
+	if budget := latencyBudget(hiveownership.NewWebhook()); budget != 2*time.Second-timeoutMargin {
+		t.Fatalf("Expected a budget of 2s less %s, got %s", timeoutMargin, budget)
+	}
+}
+
+// readingHook is hiveownership-validation reading from the cluster
+type readingHook struct {
+	*hiveownership.HiveOwnershipWebhook
+	failurePolicy admissionregv1.FailurePolicyType
+}
+
+func (h *readingHook) FailurePolicy() admissionregv1.FailurePolicyType { return h.failurePolicy }
+
+func (h *readingHook) InjectClient(client.Client) {}
+
+func (h *readingHook) CachedObjects() []client.Object { return nil }
+
+func TestHandleRequestBackpressure(t *testing.T) {
+	underBackpressure = func() bool { return true }
+	t.Cleanup(func() { underBackpressure = k8sutil.UnderBackpressure })
+
+	tests := []struct {
+		name    string
+		hook    webhooks.Webhook
+		allowed bool
+	}{
+		{
+			name:    "reading webhook failing open",
+			hook:    &readingHook{HiveOwnershipWebhook: hiveownership.NewWebhook(), failurePolicy: admissionregv1.Ignore},
+			allowed: true,
+		},
+		{
+			name:    "reading webhook failing closed",
+			hook:    &readingHook{HiveOwnershipWebhook: hiveownership.NewWebhook(), failurePolicy: admissionregv1.Fail},
+			allowed: false,
+		},
+		{
+			name:    "webhook not reading from the cluster",
+			hook:    hiveownership.NewWebhook(),
+			allowed: false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := NewDispatcher(webhooks.RegisteredWebhooks{
+				hiveownership.WebhookName: func() webhooks.Webhook { return test.hook },
+			})
+			response := sendDeniedRequest(t, d)
+			if response.Allowed != test.allowed {
+				t.Fatalf("Expected allowed %v under backpressure, got %v: %v", test.allowed, response.Allowed, response.Result)
+			}
+			if test.allowed && !strings.Contains(response.Result.Message, "throttling") {
+				t.Errorf("Expected a backpressure response, got %v", response.Result)
+			}
+		})
+	}
+}
+
+// recordingSink collects the audit records written to it
+type recordingSink struct {
+	records chan audit.Record
+}
+
+func (s *recordingSink) Name() string { return "recording" }
+
+func (s *recordingSink) Write(_ context.Context, r audit.Record) error {
+	s.records <- r
+	return nil
+}
+
+func TestHandleRequestAuditsDenials(t *testing.T) {
+	sink := &recordingSink{records: make(chan audit.Record, 1)}
+	auditor := audit.NewAuditor(sink)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	auditor.Start(ctx)
+
+	d := newTestDispatcher()
+	d.SetAuditor(auditor)
+	sendDeniedRequest(t, d)
+
+	select {
+	case r := <-sink.records:
+		if r.Webhook != hiveownership.WebhookName || r.UID != "test-uid" || r.User != "unpriv-user" {
+			t.Fatalf("Expected the denial to be audited, got %+v", r)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for the denial to be audited")
+	}
+}
+
+// cacheableHook is hiveownership-validation counting the requests it evaluates
+type cacheableHook struct {
+	*hiveownership.HiveOwnershipWebhook
+	evaluated int
+}
+
+func (h *cacheableHook) DecisionTTL() time.Duration { return time.Minute }
+
+func (h *cacheableHook) Authorized(request admissionctl.Request) admissionctl.Response {
+	h.evaluated++
+	return h.HiveOwnershipWebhook.Authorized(request)
+}
+
+func TestAuthorizeDecisionCache(t *testing.T) {
+	hook := &cacheableHook{HiveOwnershipWebhook: hiveownership.NewWebhook()}
+	d := NewDispatcher(webhooks.RegisteredWebhooks{
+		hiveownership.WebhookName: func() webhooks.Webhook { return hook },
+	})
+	request := func(uid, name, username string) admissionctl.Request {
+		raw := strings.NewReplacer("managed-quota-uid", uid+"-object", "managed-quota", name).Replace(managedQuotaRaw)
+		gvk := metav1.GroupVersionKind{Group: "quota.openshift.io", Version: "v1", Kind: "ClusterResourceQuota"}
+		request := testutils.NewRequest(t, gvk, admissionv1.Update, authenticationv1.UserInfo{Username: username}, "", name, []byte(raw), nil)
+		request.UID = types.UID(uid)
+		return request
+	}
+
+	first := d.authorize(context.Background(), hook, request("uid-1", "quota-a", "kube:admin"))
+	second := d.authorize(context.Background(), hook, request("uid-2", "quota-b", "kube:admin"))
+	if !first.Allowed || !second.Allowed {
+		t.Fatalf("Expected both requests to be allowed, got %v and %v", first.Result, second.Result)
+	}
+	if hook.evaluated != 1 {
+		t.Errorf("Expected the identical request to be answered from the cache, the webhook evaluated %d", hook.evaluated)
+	}
+	if second.UID != "uid-2" {
+		t.Errorf("Expected the cached decision to answer uid-2, got %s", second.UID)
+	}
+
+	// another user's request is evaluated, and denials aren't cached
+	for i := 0; i < 2; i++ {
+		if response := d.authorize(context.Background(), hook, request("uid-3", "quota-a", "unpriv-user")); response.Allowed {
+			t.Fatalf("Expected the request to be denied, got %v", response.Result)
+		}
+	}
+	if hook.evaluated != 3 {
+		t.Errorf("Expected denials to be evaluated each time, the webhook evaluated %d", hook.evaluated)
+	}
+}
+
+// FuzzHandleRequest sends malformed AdmissionReviews through the dispatcher,
+// which must always answer with an AdmissionReview rather than panic
+func FuzzHandleRequest(f *testing.F) {
+	gvk := metav1.GroupVersionKind{Group: "quota.openshift.io", Version: "v1", Kind: "ClusterResourceQuota"}
+	gvr := metav1.GroupVersionResource{Group: "quota.openshift.io", Version: "v1", Resource: "clusterresourcequotas"}
+	obj := &runtime.RawExtension{Raw: []byte(managedQuotaRaw)}
+	review, err := testutils.CreateFakeRequestJSON("test-uid", gvk, gvr, admissionv1.Update,
+		"unpriv-user", []string{"system:authenticated"}, "", obj, nil)
+	if err != nil {
+		f.Fatalf("Expected no error, got %s", err.Error())
+	}
+	f.Add(review)
+	f.Add(review[:len(review)/2])
+	f.Add([]byte(`{"request": null}`))
+	f.Add([]byte(`{"request": {"uid": "test-uid", "object": 42, "oldObject": "{"}}`))
+	f.Add([]byte(`null`))
+
+	d := newTestDispatcher()
+	f.Fuzz(func(t *testing.T, body []byte) {
+		req := httptest.NewRequest("POST", "/"+hiveownership.WebhookName, strings.NewReader(string(body)))
+		req.Header["Content-Type"] = []string{"application/json"}
+		w := httptest.NewRecorder()
+		d.HandleRequest(w, req)
+
+		if w.Code == http.StatusInternalServerError {
+			t.Fatalf("Expected the dispatcher not to fail internally, got %s", w.Body.String())
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &admissionv1.AdmissionReview{}); err != nil {
+			t.Fatalf("Expected an AdmissionReview in the response, got %s: %v", w.Body.String(), err)
+		}
+	})
+}
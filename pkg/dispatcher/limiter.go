@@ -0,0 +1,81 @@
+package dispatcher
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MaxInFlightEnvVar is how many admission requests each webhook may
+	// evaluate at once. Defaults to defaultMaxInFlight.
+	MaxInFlightEnvVar = "WEBHOOK_MAX_INFLIGHT"
+	// MaxQueuedEnvVar is how many admission requests may wait for each
+	// webhook once it's at MaxInFlightEnvVar. Further requests are shed.
+	// Defaults to defaultMaxQueued.
+	MaxQueuedEnvVar = "WEBHOOK_MAX_QUEUED"
+
+	defaultMaxInFlight = 20
+	defaultMaxQueued   = 100
+)
+
+// limiter bounds the requests a webhook evaluates at once, queueing a limited
+// number of requests beyond that
+type limiter struct {
+	inFlight chan struct{}
+	queued   chan struct{}
+}
+
+func newLimiter(maxInFlight, maxQueued int) *limiter {
+	return &limiter{
+		inFlight: make(chan struct{}, maxInFlight),
+		queued:   make(chan struct{}, maxQueued),
+	}
+}
+
+// acquire takes an in-flight slot, waiting in the queue until ctx is done if
+// none is free. It returns false when the request should be shed because the
+// queue is full or ctx is done first. Each successful acquire must be
+// released.
+func (l *limiter) acquire(ctx context.Context) bool {
+	select {
+	case l.inFlight <- struct{}{}:
+		return true
+	default:
+	}
+
+	select {
+	case l.queued <- struct{}{}:
+	default:
+		return false
+	}
+	defer func() { <-l.queued }()
+
+	select {
+	case l.inFlight <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// release frees an in-flight slot
+func (l *limiter) release() {
+	<-l.inFlight
+}
+
+// limitFromEnv parses a positive limit from the environment variable, falling
+// back to def
+func limitFromEnv(name string, def int) int {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return def
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 {
+		log.Info("Ignoring invalid limit", "envVar", name, "value", value, "default", def)
+		return def
+	}
+	return limit
+}
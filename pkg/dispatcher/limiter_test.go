@@ -0,0 +1,85 @@
+package dispatcher
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/hiveownership"
+)
+
+func TestLimiter(t *testing.T) {
+	l := newLimiter(1, 1)
+	ctx := context.Background()
+
+	if !l.acquire(ctx) {
+		t.Fatalf("Expected a free in-flight slot")
+	}
+
+	// the second request queues until the first is released
+	acquired := make(chan bool)
+	go func() { acquired <- l.acquire(ctx) }()
+	for len(l.queued) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// the queue is full, so the third request is shed
+	if l.acquire(ctx) {
+		t.Fatalf("Expected the request to be shed when the queue is full")
+	}
+
+	l.release()
+	if !<-acquired {
+		t.Fatalf("Expected the queued request to acquire the released slot")
+	}
+
+	// a queued request is shed when its deadline passes
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if l.acquire(timeout) {
+		t.Fatalf("Expected the queued request to be shed at its deadline")
+	}
+	if len(l.queued) != 0 {
+		t.Fatalf("Expected the queue to be empty, got %d", len(l.queued))
+	}
+}
+
+func TestLimitFromEnv(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected int
+	}{
+		{value: "", expected: 5},
+		{value: "12", expected: 12},
+		{value: "0", expected: 5},
+		{value: "many", expected: 5},
+	}
+	for _, test := range tests {
+		t.Setenv(MaxInFlightEnvVar, test.value)
+		if actual := limitFromEnv(MaxInFlightEnvVar, 5); actual != test.expected {
+			t.Errorf("limitFromEnv(%q): expected %d, got %d", test.value, test.expected, actual)
+		}
+	}
+}
+
+func TestShedResponse(t *testing.T) {
+	request := admissionctl.Request{}
+	request.UID = "test-uid"
+
+	ignore := shedResponse(&slowHook{HiveOwnershipWebhook: hiveownership.NewWebhook(), failurePolicy: admissionregv1.Ignore}, request)
+	if !ignore.Allowed {
+		t.Fatalf("Expected a webhook which fails open to allow shed requests")
+	}
+
+	fail := shedResponse(&slowHook{HiveOwnershipWebhook: hiveownership.NewWebhook(), failurePolicy: admissionregv1.Fail}, request)
+	if fail.Allowed || fail.Result == nil || fail.Result.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected a webhook which fails closed to answer shed requests with %d, got %v", http.StatusTooManyRequests, fail.Result)
+	}
+	if fail.UID != "test-uid" {
+		t.Fatalf("Expected response UID test-uid, got %s", fail.UID)
+	}
+}
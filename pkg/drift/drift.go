@@ -0,0 +1,316 @@
+// Package drift keeps the webhooks' Validating and
+// MutatingWebhookConfigurations as they are generated. Manual edits or partial
+// syncs of the configurations can silently stop the API server from calling a
+// webhook, so the Detector periodically compares them to their generated state
+// and repairs them.
+package drift
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/featuregates"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/localmetrics"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks"
+)
+
+// resyncPeriod is how often the configurations are compared to their
+// generated state
+const resyncPeriod = 5 * time.Minute
+
+var log = logf.Log.WithName("drift")
+
+// Detector repairs the configurations of the webhooks enabled on Classic
+// clusters which have drifted from their generated state
+type Detector struct {
+	client    client.Client
+	hooks     webhooks.RegisteredWebhooks
+	namespace string
+	// caFile holds the CA bundle the configurations should carry. When empty,
+	// the CA bundles are left to service-ca-operator.
+	caFile string
+
+	mu sync.Mutex
+}
+
+// NewDetector creates a Detector for the configurations of the webhooks
+// served in namespace
+func NewDetector(c client.Client, hooks webhooks.RegisteredWebhooks, namespace, caFile string) *Detector {
+	return &Detector{
+		client:    c,
+		hooks:     hooks,
+		namespace: namespace,
+		caFile:    caFile,
+	}
+}
+
+// Run repairs drifted configurations every resyncPeriod until ctx is
+// cancelled. It must only run on the elected leader, so replicas don't race
+// to repair the same configurations.
+func (d *Detector) Run(ctx context.Context) {
+	wait.UntilWithContext(ctx, d.Sync, resyncPeriod)
+}
+
+// Sync compares the configuration of each webhook to its generated state and
+// repairs it, creating it if it's missing. Configurations which couldn't be
+// repaired are logged and retried the next time.
+func (d *Detector) Sync(ctx context.Context) {
+	_ = d.Apply(ctx)
+}
+
+// Apply creates or repairs the configuration of each webhook as Sync does,
+// returning the errors of those it couldn't
+func (d *Detector) Apply(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	caBundle := d.caBundle()
+	names := make([]string, 0, len(d.hooks))
+	for name := range d.hooks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	errs := []error{}
+	for _, name := range names {
+		hook := d.hooks[name]()
+		// Only the configurations the SelectorSyncSet creates are generated
+		// here, and those of disabled webhooks are removed on purpose
+		if !hook.ClassicEnabled() || len(hook.Rules()) == 0 || !featuregates.Enabled(name) {
+			continue
+		}
+		if err := d.sync(ctx, hook, caBundle); err != nil {
+			log.Error(err, "Failed to repair webhook configuration", "webhookName", name)
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (d *Detector) sync(ctx context.Context, hook webhooks.Webhook, caBundle []byte) error {
+	var desired, existing client.Object
+	if webhooks.IsMutating(hook.Name()) {
+		configuration := webhooks.MutatingWebhookConfiguration(hook, d.namespace)
+		desired, existing = &configuration, &admissionregv1.MutatingWebhookConfiguration{}
+	} else {
+		configuration := webhooks.ValidatingWebhookConfiguration(hook, d.namespace)
+		desired, existing = &configuration, &admissionregv1.ValidatingWebhookConfiguration{}
+	}
+
+	err := d.client.Get(ctx, client.ObjectKeyFromObject(desired), existing)
+	if apierrors.IsNotFound(err) {
+		localmetrics.IncrementConfigurationDrift(hook.Name(), "missing")
+		// sets the CA bundle, so the webhook is called before
+		// service-ca-operator injects it
+		repair(desired, desired, caBundle)
+		if err := d.client.Create(ctx, desired); err != nil && !apierrors.IsAlreadyExists(err) {
+			return err
+		}
+		log.Info("Recreated missing webhook configuration", "webhookName", hook.Name(), "configuration", desired.GetName())
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	fields := drifted(existing, desired, caBundle)
+	if len(fields) == 0 {
+		return nil
+	}
+	for _, field := range fields {
+		localmetrics.IncrementConfigurationDrift(hook.Name(), field)
+	}
+	repair(existing, desired, caBundle)
+	if err := d.client.Update(ctx, existing); err != nil {
+		return err
+	}
+	log.Info("Repaired drifted webhook configuration", "webhookName", hook.Name(), "configuration", existing.GetName(), "fields", fields)
+	return nil
+}
+
+// caBundle reads the CA bundle the configurations should carry, or returns
+// nil to leave them as they are
+func (d *Detector) caBundle() []byte {
+	if d.caFile == "" {
+		return nil
+	}
+	caBundle, err := os.ReadFile(d.caFile)
+	if err != nil {
+		log.Error(err, "Failed to read CA bundle; CA bundles of webhook configurations are not checked", "file", d.caFile)
+		return nil
+	}
+	return caBundle
+}
+
+// webhook holds the fields of a ValidatingWebhook or MutatingWebhook which are
+// generated
+type webhook struct {
+	name           string
+	rules          []admissionregv1.RuleWithOperations
+	timeoutSeconds *int32
+	failurePolicy  *admissionregv1.FailurePolicyType
+	sideEffects    *admissionregv1.SideEffectClass
+	matchPolicy    *admissionregv1.MatchPolicyType
+	objectSelector *metav1.LabelSelector
+	// namespaceSelector is generated for canaries, and defaulted otherwise
+	namespaceSelector *metav1.LabelSelector
+	clientConfig      admissionregv1.WebhookClientConfig
+}
+
+// webhooksOf returns the generated fields of the configuration's webhooks
+func webhooksOf(obj client.Object) []webhook {
+	hooks := []webhook{}
+	switch configuration := obj.(type) {
+	case *admissionregv1.ValidatingWebhookConfiguration:
+		for _, w := range configuration.Webhooks {
+			hooks = append(hooks, webhook{w.Name, w.Rules, w.TimeoutSeconds, w.FailurePolicy, w.SideEffects, w.MatchPolicy, w.ObjectSelector, w.NamespaceSelector, w.ClientConfig})
+		}
+	case *admissionregv1.MutatingWebhookConfiguration:
+		for _, w := range configuration.Webhooks {
+			hooks = append(hooks, webhook{w.Name, w.Rules, w.TimeoutSeconds, w.FailurePolicy, w.SideEffects, w.MatchPolicy, w.ObjectSelector, w.NamespaceSelector, w.ClientConfig})
+		}
+	}
+	return hooks
+}
+
+// drifted returns the fields in which the existing configuration differs from
+// the desired one. The CA bundle is only compared when caBundle is set.
+func drifted(existing, desired client.Object, caBundle []byte) []string {
+	existingHooks, desiredHooks := webhooksOf(existing), webhooksOf(desired)
+	if len(existingHooks) != len(desiredHooks) {
+		return []string{"webhooks"}
+	}
+
+	fields := []string{}
+	for i, e := range existingHooks {
+		w := desiredHooks[i]
+		if e.name != w.name {
+			fields = append(fields, "name")
+		}
+		if !reflect.DeepEqual(normalizeRules(e.rules), normalizeRules(w.rules)) {
+			fields = append(fields, "rules")
+		}
+		if !equal(e.timeoutSeconds, w.timeoutSeconds) {
+			fields = append(fields, "timeoutSeconds")
+		}
+		if !equal(e.failurePolicy, w.failurePolicy) {
+			fields = append(fields, "failurePolicy")
+		}
+		if !equal(e.sideEffects, w.sideEffects) {
+			fields = append(fields, "sideEffects")
+		}
+		if !equal(e.matchPolicy, w.matchPolicy) {
+			fields = append(fields, "matchPolicy")
+		}
+		if !reflect.DeepEqual(normalizeSelector(e.objectSelector), normalizeSelector(w.objectSelector)) {
+			fields = append(fields, "objectSelector")
+		}
+		if !reflect.DeepEqual(normalizeSelector(e.namespaceSelector), normalizeSelector(w.namespaceSelector)) {
+			fields = append(fields, "namespaceSelector")
+		}
+		if !sameService(e.clientConfig.Service, w.clientConfig.Service) || e.clientConfig.URL != nil {
+			fields = append(fields, "clientConfig")
+		}
+		if caBundle != nil && !bytes.Equal(e.clientConfig.CABundle, caBundle) {
+			fields = append(fields, "caBundle")
+		}
+	}
+	return fields
+}
+
+// repair sets the generated fields of the existing configuration to those of
+// the desired one, keeping the fields the API server defaults. The existing CA
+// bundle is kept unless caBundle is set.
+func repair(existing, desired client.Object, caBundle []byte) {
+	switch configuration := existing.(type) {
+	case *admissionregv1.ValidatingWebhookConfiguration:
+		hooks := desired.(*admissionregv1.ValidatingWebhookConfiguration).DeepCopy().Webhooks
+		for i := range hooks {
+			hooks[i].ClientConfig.CABundle = caBundle
+			if i < len(configuration.Webhooks) {
+				old := configuration.Webhooks[i]
+				hooks[i].NamespaceSelector = namespaceSelector(old.NamespaceSelector, hooks[i].NamespaceSelector)
+				if caBundle == nil {
+					hooks[i].ClientConfig.CABundle = old.ClientConfig.CABundle
+				}
+			}
+		}
+		configuration.Webhooks = hooks
+	case *admissionregv1.MutatingWebhookConfiguration:
+		hooks := desired.(*admissionregv1.MutatingWebhookConfiguration).DeepCopy().Webhooks
+		for i := range hooks {
+			hooks[i].ClientConfig.CABundle = caBundle
+			if i < len(configuration.Webhooks) {
+				old := configuration.Webhooks[i]
+				hooks[i].NamespaceSelector = namespaceSelector(old.NamespaceSelector, hooks[i].NamespaceSelector)
+				hooks[i].ReinvocationPolicy = old.ReinvocationPolicy
+				if caBundle == nil {
+					hooks[i].ClientConfig.CABundle = old.ClientConfig.CABundle
+				}
+			}
+		}
+		configuration.Webhooks = hooks
+	}
+}
+
+// namespaceSelector returns the existing namespace selector if it's the
+// desired one, which keeps the one the API server defaulted, and the desired
+// one otherwise, eg when a canary is promoted
+func namespaceSelector(existing, desired *metav1.LabelSelector) *metav1.LabelSelector {
+	if reflect.DeepEqual(normalizeSelector(existing), normalizeSelector(desired)) {
+		return existing
+	}
+	return normalizeSelector(desired)
+}
+
+// normalizeRules defaults the scope of the rules as the API server does
+func normalizeRules(rules []admissionregv1.RuleWithOperations) []admissionregv1.RuleWithOperations {
+	normalized := make([]admissionregv1.RuleWithOperations, 0, len(rules))
+	for _, rule := range rules {
+		rule = *rule.DeepCopy()
+		if rule.Scope == nil {
+			scope := admissionregv1.AllScopes
+			rule.Scope = &scope
+		}
+		normalized = append(normalized, rule)
+	}
+	return normalized
+}
+
+// normalizeSelector defaults an unset selector to the empty selector, as the
+// API server does
+func normalizeSelector(selector *metav1.LabelSelector) *metav1.LabelSelector {
+	if selector == nil {
+		return &metav1.LabelSelector{}
+	}
+	return selector
+}
+
+// sameService compares the Services the webhooks are served by, ignoring the
+// port the API server defaults
+func sameService(a, b *admissionregv1.ServiceReference) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Namespace == b.Namespace && a.Name == b.Name && equal(a.Path, b.Path)
+}
+
+func equal[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
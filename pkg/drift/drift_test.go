@@ -0,0 +1,226 @@
+package drift
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/debugpodtolerations"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/hiveownership"
+)
+
+const testNamespace = "openshift-validation-webhook"
+
+var testHooks = webhooks.RegisteredWebhooks{
+	hiveownership.WebhookName:       func() webhooks.Webhook { return hiveownership.NewWebhook() },
+	debugpodtolerations.WebhookName: func() webhooks.Webhook { return debugpodtolerations.NewWebhook() },
+}
+
+// applied returns the configuration as the API server stores it, with its
+// defaulted fields set
+func applied(hook webhooks.Webhook) *admissionregv1.ValidatingWebhookConfiguration {
+	configuration := webhooks.ValidatingWebhookConfiguration(hook, testNamespace)
+	w := &configuration.Webhooks[0]
+	w.NamespaceSelector = &metav1.LabelSelector{}
+	if w.ObjectSelector == nil {
+		w.ObjectSelector = &metav1.LabelSelector{}
+	}
+	w.ClientConfig.Service.Port = ptr.To(int32(443))
+	w.ClientConfig.CABundle = []byte("ca")
+	return &configuration
+}
+
+func TestDrifted(t *testing.T) {
+	hook := hiveownership.NewWebhook()
+	desired := webhooks.ValidatingWebhookConfiguration(hook, testNamespace)
+
+	tests := []struct {
+		name     string
+		mutate   func(*admissionregv1.ValidatingWebhook)
+		caBundle []byte
+		expected []string
+	}{
+		{
+			name:     "defaulted fields",
+			mutate:   func(*admissionregv1.ValidatingWebhook) {},
+			expected: []string{},
+		},
+		{
+			name: "failure policy and timeout",
+			mutate: func(w *admissionregv1.ValidatingWebhook) {
+				w.FailurePolicy = ptr.To(admissionregv1.Fail)
+				w.TimeoutSeconds = ptr.To(int32(30))
+			},
+			expected: []string{"timeoutSeconds", "failurePolicy"},
+		},
+		{
+			name:     "rules",
+			mutate:   func(w *admissionregv1.ValidatingWebhook) { w.Rules = nil },
+			expected: []string{"rules"},
+		},
+		{
+			name: "object selector",
+			mutate: func(w *admissionregv1.ValidatingWebhook) {
+				w.ObjectSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"never": "matches"}}
+			},
+			expected: []string{"objectSelector"},
+		},
+		{
+			name: "namespace selector",
+			mutate: func(w *admissionregv1.ValidatingWebhook) {
+				w.NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"never": "matches"}}
+			},
+			expected: []string{"namespaceSelector"},
+		},
+		{
+			name:     "CA bundle",
+			mutate:   func(*admissionregv1.ValidatingWebhook) {},
+			caBundle: []byte("rotated"),
+			expected: []string{"caBundle"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			existing := applied(hook)
+			test.mutate(&existing.Webhooks[0])
+			if actual := drifted(existing, &desired, test.caBundle); !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("Expected drifted fields %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestDetectorSync(t *testing.T) {
+	caFile := filepath.Join(t.TempDir(), "service-ca.crt")
+	if err := os.WriteFile(caFile, []byte("ca"), 0600); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+
+	edited := applied(hiveownership.NewWebhook())
+	edited.Webhooks[0].FailurePolicy = ptr.To(admissionregv1.Ignore)
+	edited.Webhooks[0].Rules = edited.Webhooks[0].Rules[:0]
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(edited).Build()
+	d := NewDetector(c, testHooks, testNamespace, caFile)
+	ctx := context.Background()
+
+	d.Sync(ctx)
+
+	repaired := &admissionregv1.ValidatingWebhookConfiguration{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(edited), repaired); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	desired := webhooks.ValidatingWebhookConfiguration(hiveownership.NewWebhook(), testNamespace)
+	if fields := drifted(repaired, &desired, []byte("ca")); len(fields) != 0 {
+		t.Fatalf("Expected the configuration to be repaired, still drifted in %v", fields)
+	}
+	if repaired.Webhooks[0].NamespaceSelector == nil {
+		t.Errorf("Expected the defaulted namespace selector to be kept")
+	}
+
+	// The missing configuration of the mutating webhook is recreated
+	recreated := &admissionregv1.MutatingWebhookConfiguration{}
+	if err := c.Get(ctx, client.ObjectKey{Name: webhooks.ConfigurationName(debugpodtolerations.WebhookName)}, recreated); err != nil {
+		t.Fatalf("Expected the missing configuration to be recreated, got %v", err)
+	}
+	if string(recreated.Webhooks[0].ClientConfig.CABundle) != "ca" {
+		t.Errorf("Expected the recreated configuration to carry the CA bundle, got %q", recreated.Webhooks[0].ClientConfig.CABundle)
+	}
+}
+
+func TestDetectorSyncWithoutCABundle(t *testing.T) {
+	existing := applied(hiveownership.NewWebhook())
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(existing).Build()
+	d := NewDetector(c, webhooks.RegisteredWebhooks{
+		hiveownership.WebhookName: testHooks[hiveownership.WebhookName],
+	}, testNamespace, "")
+	ctx := context.Background()
+
+	d.Sync(ctx)
+
+	unchanged := &admissionregv1.ValidatingWebhookConfiguration{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(existing), unchanged); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	if unchanged.ResourceVersion != existing.ResourceVersion {
+		t.Errorf("Expected the configuration not to be updated")
+	}
+	if err := c.Delete(ctx, unchanged); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	d.Sync(ctx)
+	if err := c.Get(ctx, client.ObjectKeyFromObject(existing), unchanged); apierrors.IsNotFound(err) {
+		t.Fatalf("Expected the configuration to be recreated")
+	}
+}
+
+func TestDetectorSyncFailurePolicies(t *testing.T) {
+	t.Cleanup(func() { webhooks.SetFailurePolicies(webhooks.FailurePolicies{}) })
+	webhooks.SetFailurePolicies(webhooks.FailurePolicies{
+		webhooks.AllWebhooks:            admissionregv1.Fail,
+		debugpodtolerations.WebhookName: admissionregv1.Ignore,
+	})
+
+	hook := hiveownership.NewWebhook()
+	existing := applied(hook)
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(existing).Build()
+	d := NewDetector(c, testHooks, testNamespace, "")
+	ctx := context.Background()
+
+	d.Sync(ctx)
+
+	validating := &admissionregv1.ValidatingWebhookConfiguration{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(existing), validating); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	if policy := *validating.Webhooks[0].FailurePolicy; policy != admissionregv1.Fail {
+		t.Errorf("Expected the overridden failure policy Fail, got %s", policy)
+	}
+	mutating := &admissionregv1.MutatingWebhookConfiguration{}
+	if err := c.Get(ctx, client.ObjectKey{Name: webhooks.ConfigurationName(debugpodtolerations.WebhookName)}, mutating); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	if policy := *mutating.Webhooks[0].FailurePolicy; policy != admissionregv1.Ignore {
+		t.Errorf("Expected the webhook's own override Ignore, got %s", policy)
+	}
+}
+
+func TestDetectorSyncCanaries(t *testing.T) {
+	t.Cleanup(func() { webhooks.SetCanaries(webhooks.Canaries{}) })
+	hook := hiveownership.NewWebhook()
+	existing := applied(hook)
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(existing).Build()
+	d := NewDetector(c, testHooks, testNamespace, "")
+	ctx := context.Background()
+
+	webhooks.SetCanaries(webhooks.Canaries{hook.Name(): true})
+	d.Sync(ctx)
+	canary := &admissionregv1.ValidatingWebhookConfiguration{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(existing), canary); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	if selector := canary.Webhooks[0].NamespaceSelector; selector == nil || selector.MatchLabels[webhooks.CanaryLabel] != "true" {
+		t.Errorf("Expected the canary to be limited to the opted-in namespaces, got %v", selector)
+	}
+
+	webhooks.SetCanaries(webhooks.Canaries{})
+	d.Sync(ctx)
+	promoted := &admissionregv1.ValidatingWebhookConfiguration{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(existing), promoted); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	if selector := promoted.Webhooks[0].NamespaceSelector; selector == nil || len(selector.MatchLabels) != 0 {
+		t.Errorf("Expected the promoted webhook to match every namespace, got %v", selector)
+	}
+}
@@ -0,0 +1,221 @@
+// Package evaluate checks manifests against the webhooks without a cluster,
+// through the same code paths used at admission time, so CI pipelines and the
+// OCM backend can tell whether a manifest would be admitted before applying
+// it. It is served over gRPC by the evaluate-server subcommand.
+package evaluate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gomodules.xyz/jsonpatch/v2"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/dependencies"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/replay"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks"
+)
+
+var (
+	// ErrUnknownWebhook is returned for requests naming a webhook which isn't
+	// registered
+	ErrUnknownWebhook = errors.New("unknown webhook")
+	// ErrInvalidRequest is returned for requests which can't be turned into an
+	// admission request
+	ErrInvalidRequest = errors.New("invalid request")
+)
+
+// defaultUser is who requests are evaluated as unless they say otherwise: a
+// customer without any privileges webhooks grant exceptions for
+var defaultUser = authenticationv1.UserInfo{
+	Username: "customer",
+	Groups:   []string{"system:authenticated"},
+}
+
+// Request is a manifest to evaluate against a webhook
+type Request struct {
+	// Webhook is the name of the webhook, eg namespace-validation
+	Webhook string `json:"webhook"`
+	// Operation is CREATE unless set
+	Operation admissionv1.Operation `json:"operation,omitempty"`
+	// Object is the manifest. For DELETE it is the object being deleted.
+	Object map[string]interface{} `json:"object"`
+	// OldObject is the object an UPDATE replaces
+	OldObject map[string]interface{} `json:"oldObject,omitempty"`
+	// Resource is the plural resource of the object, which is guessed from
+	// its kind unless set
+	Resource string `json:"resource,omitempty"`
+	// SubResource is the subresource the request is made to, if any
+	SubResource string `json:"subResource,omitempty"`
+	// UserInfo is who makes the request, an unprivileged customer unless set
+	UserInfo *authenticationv1.UserInfo `json:"userInfo,omitempty"`
+	// Objects are served by the client of webhooks which read the cluster,
+	// in place of the cluster's objects
+	Objects []map[string]interface{} `json:"objects,omitempty"`
+}
+
+// Result is a webhook's answer to a Request
+type Result struct {
+	// Evaluated is false when the API server wouldn't send the request to the
+	// webhook, which then has no say in whether it's admitted
+	Evaluated bool `json:"evaluated"`
+	Allowed   bool `json:"allowed"`
+	// Code is the response code of a denial
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+	// Patch is the JSONPatch a mutating webhook would apply to the object
+	Patch    []jsonpatch.JsonPatchOperation `json:"patch,omitempty"`
+	Warnings []string                       `json:"warnings,omitempty"`
+}
+
+// Evaluator evaluates requests against registered webhooks
+type Evaluator struct {
+	hooks  webhooks.RegisteredWebhooks
+	scheme *runtime.Scheme
+}
+
+// NewEvaluator creates an Evaluator of the webhooks. The scheme resolves the
+// resources of the objects evaluated and those served to the webhooks.
+func NewEvaluator(hooks webhooks.RegisteredWebhooks, scheme *runtime.Scheme) *Evaluator {
+	return &Evaluator{hooks: hooks, scheme: scheme}
+}
+
+// Evaluate sends the request to a new instance of its webhook, as the
+// dispatcher would, and returns the webhook's answer. Requests are dry runs,
+// so webhooks with side effects skip them.
+func (e *Evaluator) Evaluate(ctx context.Context, req Request) (Result, error) {
+	factory, ok := e.hooks[req.Webhook]
+	if !ok {
+		return Result{}, fmt.Errorf("%w %q", ErrUnknownWebhook, req.Webhook)
+	}
+	admissionRequest, err := e.admissionRequest(req)
+	if err != nil {
+		return Result{}, fmt.Errorf("%w: %w", ErrInvalidRequest, err)
+	}
+
+	hook := factory()
+	request := admissionctl.Request{AdmissionRequest: admissionRequest}
+	if !replay.Matches(hook, admissionRequest) || !hook.Validate(request) {
+		return Result{Evaluated: false, Allowed: true}, nil
+	}
+	deps := dependencies.Bundle{}
+	if _, ok := hook.(webhooks.CachingWebhook); ok {
+		c, err := e.client(req.Objects)
+		if err != nil {
+			return Result{}, fmt.Errorf("%w: %w", ErrInvalidRequest, err)
+		}
+		deps.Client = c
+	}
+
+	resp := webhooks.Admit(ctx, hook, deps, request)
+	result := Result{
+		Evaluated: true,
+		Allowed:   resp.Allowed,
+		Code:      string(response.CodeOf(resp)),
+		Patch:     resp.Patches,
+		Warnings:  resp.Warnings,
+	}
+	if resp.Result != nil {
+		result.Message = resp.Result.Message
+	}
+	return result, nil
+}
+
+// admissionRequest builds the admission request the API server would send for
+// the request's object
+func (e *Evaluator) admissionRequest(req Request) (admissionv1.AdmissionRequest, error) {
+	if len(req.Object) == 0 {
+		return admissionv1.AdmissionRequest{}, errors.New("the request has no object")
+	}
+	object := &unstructured.Unstructured{Object: req.Object}
+	gvk := object.GroupVersionKind()
+	if gvk.Kind == "" || gvk.Version == "" {
+		return admissionv1.AdmissionRequest{}, errors.New("the object has no apiVersion or kind")
+	}
+	resource := req.Resource
+	if resource == "" {
+		plural, _ := meta.UnsafeGuessKindToResource(gvk)
+		resource = plural.Resource
+	}
+
+	operation := req.Operation
+	if operation == "" {
+		operation = admissionv1.Create
+	}
+	operation = admissionv1.Operation(strings.ToUpper(string(operation)))
+	raw, err := json.Marshal(req.Object)
+	if err != nil {
+		return admissionv1.AdmissionRequest{}, err
+	}
+	var newObject, oldObject runtime.RawExtension
+	switch operation {
+	case admissionv1.Create, admissionv1.Connect:
+		newObject.Raw = raw
+	case admissionv1.Update:
+		if len(req.OldObject) == 0 {
+			return admissionv1.AdmissionRequest{}, errors.New("an UPDATE needs the old object")
+		}
+		newObject.Raw = raw
+		if oldObject.Raw, err = json.Marshal(req.OldObject); err != nil {
+			return admissionv1.AdmissionRequest{}, err
+		}
+	case admissionv1.Delete:
+		oldObject.Raw = raw
+	default:
+		return admissionv1.AdmissionRequest{}, fmt.Errorf("unknown operation %q", operation)
+	}
+
+	user := defaultUser
+	if req.UserInfo != nil {
+		user = *req.UserInfo
+	}
+	kind := metav1.GroupVersionKind{Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind}
+	groupVersionResource := metav1.GroupVersionResource{Group: gvk.Group, Version: gvk.Version, Resource: resource}
+	namespace := object.GetNamespace()
+	// Namespace requests carry the namespace's name as their namespace
+	if gvk.Group == "" && gvk.Kind == "Namespace" {
+		namespace = object.GetName()
+	}
+	return admissionv1.AdmissionRequest{
+		UID:             types.UID("evaluate-" + req.Webhook),
+		Kind:            kind,
+		Resource:        groupVersionResource,
+		SubResource:     req.SubResource,
+		RequestKind:     &kind,
+		RequestResource: &groupVersionResource,
+		Name:            object.GetName(),
+		Namespace:       namespace,
+		Operation:       operation,
+		UserInfo:        user,
+		Object:          newObject,
+		OldObject:       oldObject,
+		DryRun:          ptr.To(true),
+	}, nil
+}
+
+// client serves the objects to webhooks which read the cluster
+func (e *Evaluator) client(objects []map[string]interface{}) (client.Client, error) {
+	served := make([]client.Object, 0, len(objects))
+	for i, object := range objects {
+		u := &unstructured.Unstructured{Object: runtime.DeepCopyJSON(object)}
+		if u.GetKind() == "" || u.GetName() == "" {
+			return nil, fmt.Errorf("object %d has no kind or name", i)
+		}
+		served = append(served, u)
+	}
+	return fake.NewClientBuilder().WithScheme(e.scheme).WithObjects(served...).Build(), nil
+}
@@ -0,0 +1,195 @@
+package evaluate
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/costlabels"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/priorityclass"
+)
+
+var testHooks = webhooks.RegisteredWebhooks{
+	costlabels.WebhookName:    func() webhooks.Webhook { return costlabels.NewWebhook() },
+	priorityclass.WebhookName: func() webhooks.Webhook { return priorityclass.NewWebhook() },
+}
+
+func pod(namespace string) map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata":   map[string]interface{}{"name": "api", "namespace": namespace},
+		"spec": map[string]interface{}{
+			"containers": []interface{}{map[string]interface{}{"name": "api", "image": "quay.io/payments/api:latest"}},
+		},
+	}
+}
+
+func namespace(name string, labels map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata":   map[string]interface{}{"name": name, "labels": labels},
+	}
+}
+
+func priorityClass(name string) map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": "scheduling.k8s.io/v1",
+		"kind":       "PriorityClass",
+		"metadata":   map[string]interface{}{"name": name},
+		"value":      1000,
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	evaluator := NewEvaluator(testHooks, clientgoscheme.Scheme)
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		request   Request
+		evaluated bool
+		allowed   bool
+		code      string
+		patched   bool
+	}{
+		{
+			name: "mutated with the served objects",
+			request: Request{
+				Webhook: costlabels.WebhookName,
+				Object:  pod("payments"),
+				Objects: []map[string]interface{}{namespace("payments", map[string]interface{}{"cost-center": "cc-42", "team": "payments"})},
+			},
+			evaluated: true,
+			allowed:   true,
+			patched:   true,
+		},
+		{
+			name: "denied without the served objects",
+			request: Request{
+				Webhook: costlabels.WebhookName,
+				Object:  pod("payments"),
+				Objects: []map[string]interface{}{namespace("payments", nil)},
+			},
+			evaluated: true,
+			allowed:   false,
+			code:      "MissingCostAllocationLabels",
+		},
+		{
+			name: "denied deletion",
+			request: Request{
+				Webhook:   priorityclass.WebhookName,
+				Operation: "delete",
+				Object:    priorityClass("system-cluster-critical"),
+			},
+			evaluated: true,
+			allowed:   false,
+			code:      "ClusterCriticalPriorityClass",
+		},
+		{
+			name: "allowed for SRE",
+			request: Request{
+				Webhook:   priorityclass.WebhookName,
+				Operation: "DELETE",
+				Object:    priorityClass("system-cluster-critical"),
+				UserInfo:  &authenticationv1.UserInfo{Username: "backplane-cluster-admin"},
+			},
+			evaluated: true,
+			allowed:   true,
+		},
+		{
+			name: "object the webhook isn't sent",
+			request: Request{
+				Webhook: priorityclass.WebhookName,
+				Object:  pod("payments"),
+			},
+			evaluated: false,
+			allowed:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := evaluator.Evaluate(ctx, test.request)
+			if err != nil {
+				t.Fatalf("Expected no error, got %s", err.Error())
+			}
+			if result.Evaluated != test.evaluated || result.Allowed != test.allowed || result.Code != test.code {
+				t.Fatalf("Expected evaluated %v, allowed %v and code %q, got %+v", test.evaluated, test.allowed, test.code, result)
+			}
+			if patched := len(result.Patch) > 0; patched != test.patched {
+				t.Fatalf("Expected patched %v, got %v", test.patched, result.Patch)
+			}
+		})
+	}
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	evaluator := NewEvaluator(testHooks, clientgoscheme.Scheme)
+	ctx := context.Background()
+
+	if _, err := evaluator.Evaluate(ctx, Request{Webhook: "unknown-validation", Object: pod("payments")}); !errors.Is(err, ErrUnknownWebhook) {
+		t.Errorf("Expected an unknown webhook error, got %v", err)
+	}
+	invalid := []Request{
+		{Webhook: costlabels.WebhookName},
+		{Webhook: costlabels.WebhookName, Object: map[string]interface{}{"metadata": map[string]interface{}{"name": "api"}}},
+		{Webhook: costlabels.WebhookName, Object: pod("payments"), Operation: "UPDATE"},
+		{Webhook: costlabels.WebhookName, Object: pod("payments"), Operation: "PATCH"},
+	}
+	for _, request := range invalid {
+		if _, err := evaluator.Evaluate(ctx, request); !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("Expected an invalid request error for %+v, got %v", request, err)
+		}
+	}
+}
+
+func TestGRPC(t *testing.T) {
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	Register(server, NewEvaluator(testHooks, clientgoscheme.Scheme))
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	c := NewClient(conn)
+	ctx := context.Background()
+
+	result, err := c.Evaluate(ctx, Request{
+		Webhook: costlabels.WebhookName,
+		Object:  pod("payments"),
+		Objects: []map[string]interface{}{namespace("payments", map[string]interface{}{"cost-center": "cc-42", "team": "payments"})},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	if !result.Evaluated || !result.Allowed || len(result.Patch) != 1 || result.Patch[0].Path != "/metadata/labels" {
+		t.Fatalf("Expected the pod to be labelled, got %+v", result)
+	}
+
+	_, err = c.Evaluate(ctx, Request{Webhook: "unknown-validation", Object: pod("payments")})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound for an unknown webhook, got %v", err)
+	}
+	_, err = c.Evaluate(ctx, Request{Webhook: costlabels.WebhookName})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for a request without an object, got %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package evaluate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// The service is described in evaluator.proto. Its messages are
+// google.protobuf.Structs holding a Request and a Result, so it needs no
+// generated code and any gRPC client can call it.
+const (
+	ServiceName    = "managed.openshift.io.webhooks.v1.Evaluator"
+	evaluateMethod = "/" + ServiceName + "/Evaluate"
+)
+
+// EvaluatorServer is the server API of the Evaluator service
+type EvaluatorServer interface {
+	Evaluate(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*EvaluatorServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Evaluate", Handler: evaluateHandler},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "evaluator.proto",
+}
+
+func evaluateHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := &structpb.Struct{}
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EvaluatorServer).Evaluate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: evaluateMethod}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EvaluatorServer).Evaluate(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Register serves the Evaluator service of e on s
+func Register(s *grpc.Server, e *Evaluator) {
+	s.RegisterService(&serviceDesc, &server{evaluator: e})
+}
+
+// server implements EvaluatorServer
+type server struct {
+	evaluator *Evaluator
+}
+
+// Evaluate implements EvaluatorServer
+func (s *server) Evaluate(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error) {
+	req := Request{}
+	if err := fromStruct(in, &req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "couldn't read the request: %v", err)
+	}
+	result, err := s.evaluator.Evaluate(ctx, req)
+	switch {
+	case errors.Is(err, ErrUnknownWebhook):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrInvalidRequest):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	out, err := toStruct(result)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "couldn't write the result: %v", err)
+	}
+	return out, nil
+}
+
+// Client calls the Evaluator service
+type Client struct {
+	conn grpc.ClientConnInterface
+}
+
+// NewClient creates a Client of the service served on conn
+func NewClient(conn grpc.ClientConnInterface) *Client {
+	return &Client{conn: conn}
+}
+
+// Evaluate evaluates the request on the server
+func (c *Client) Evaluate(ctx context.Context, req Request, opts ...grpc.CallOption) (Result, error) {
+	in, err := toStruct(req)
+	if err != nil {
+		return Result{}, err
+	}
+	out := &structpb.Struct{}
+	if err := c.conn.Invoke(ctx, evaluateMethod, in, out, opts...); err != nil {
+		return Result{}, err
+	}
+	result := Result{}
+	err = fromStruct(out, &result)
+	return result, err
+}
+
+// toStruct converts v to a Struct through its JSON encoding
+func toStruct(v interface{}) (*structpb.Struct, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	s := &structpb.Struct{}
+	err = s.UnmarshalJSON(raw)
+	return s, err
+}
+
+// fromStruct converts s to v through its JSON encoding
+func fromStruct(s *structpb.Struct, v interface{}) error {
+	raw, err := s.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
@@ -0,0 +1,135 @@
+// Package events records Kubernetes Events about changes the webhooks make to
+// admitted objects, and about attempts to change objects they protect, so
+// users can discover them. Events are created in the background, after the
+// admission request is answered.
+package events
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/config"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/localmetrics"
+)
+
+const (
+	// component is the source of the Events
+	component = "validation-webhook"
+
+	// queueSize is how many Events may wait to be created before new Events
+	// are dropped rather than holding up admission requests
+	queueSize = 1000
+)
+
+var (
+	log = logf.Log.WithName("events")
+
+	mu       sync.RWMutex
+	recorder *Recorder
+)
+
+// Recorder creates Events in the background
+type Recorder struct {
+	c      client.Client
+	events chan *corev1.Event
+}
+
+// NewRecorder creates a Recorder creating Events with c
+func NewRecorder(c client.Client) *Recorder {
+	return &Recorder{
+		c:      c,
+		events: make(chan *corev1.Event, queueSize),
+	}
+}
+
+// Start creates queued Events until ctx is cancelled
+func (r *Recorder) Start(ctx context.Context) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event := <-r.events:
+				if err := r.c.Create(ctx, event); err != nil {
+					localmetrics.IncrementEventFailure("create")
+					log.Error(err, "Failed to create Event", "namespace", event.Namespace, "reason", event.Reason)
+				}
+			}
+		}
+	}()
+}
+
+// Record queues the Event. When the queue is full the Event is dropped and
+// logged instead. A nil Recorder records nothing.
+func (r *Recorder) Record(event *corev1.Event) {
+	if r == nil {
+		return
+	}
+	select {
+	case r.events <- event:
+	default:
+		localmetrics.IncrementEventFailure("queue")
+		log.Info("Event queue is full, dropping Event", "namespace", event.Namespace, "reason", event.Reason, "message", event.Message)
+	}
+}
+
+// SetRecorder sets the Recorder used by Normal. Until it is set, no Events
+// are recorded.
+func SetRecorder(r *Recorder) {
+	mu.Lock()
+	defer mu.Unlock()
+	recorder = r
+}
+
+// Normal records a Normal Event about the object with the shared Recorder
+func Normal(object corev1.ObjectReference, reason, message string) {
+	mu.RLock()
+	r := recorder
+	mu.RUnlock()
+	r.Record(NewEvent(object, corev1.EventTypeNormal, reason, message))
+}
+
+// Warning records a Warning Event about the object with the shared Recorder
+func Warning(object corev1.ObjectReference, reason, message string) {
+	mu.RLock()
+	r := recorder
+	mu.RUnlock()
+	r.Record(NewEvent(object, corev1.EventTypeWarning, reason, message))
+}
+
+// NewEvent builds an Event about the object, in its namespace. Events about
+// cluster-scoped objects are created in the webhook namespace.
+func NewEvent(object corev1.ObjectReference, eventType, reason, message string) *corev1.Event {
+	name := strings.TrimSuffix(object.Name, "-")
+	if name == "" {
+		name = strings.ToLower(object.Kind)
+	}
+	namespace := object.Namespace
+	if namespace == "" {
+		namespace = config.OperatorNamespace
+	}
+	now := metav1.Now()
+
+	return &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			// Named the way client-go's event recorder names events
+			Name:      fmt.Sprintf("%v.%x", name, now.UnixNano()),
+			Namespace: namespace,
+		},
+		InvolvedObject: object,
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		Source:         corev1.EventSource{Component: component},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+}
@@ -0,0 +1,106 @@
+package events
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/config"
+)
+
+func testObject(name string) corev1.ObjectReference {
+	return corev1.ObjectReference{APIVersion: "v1", Kind: "Pod", Namespace: "customer", Name: name}
+}
+
+func TestNewEvent(t *testing.T) {
+	tests := []struct {
+		name   string
+		object corev1.ObjectReference
+		prefix string
+	}{
+		{
+			name:   "named object",
+			object: testObject("app"),
+			prefix: "app.",
+		},
+		{
+			name:   "generated name",
+			object: testObject("app-7d9f8-"),
+			prefix: "app-7d9f8.",
+		},
+		{
+			name:   "unnamed object",
+			object: testObject(""),
+			prefix: "pod.",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			event := NewEvent(test.object, corev1.EventTypeNormal, "Test", "message")
+			if !strings.HasPrefix(event.Name, test.prefix) {
+				t.Errorf("Expected the Event name to start with %q, got %s", test.prefix, event.Name)
+			}
+			if event.Namespace != "customer" || event.InvolvedObject != test.object {
+				t.Errorf("Expected the Event to be about the object in its namespace, got %+v", event)
+			}
+			if event.Source.Component != component || event.Count != 1 {
+				t.Errorf("Unexpected Event %+v", event)
+			}
+		})
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	// A nil Recorder records nothing
+	var nilRecorder *Recorder
+	nilRecorder.Record(NewEvent(testObject("app"), corev1.EventTypeNormal, "Test", "message"))
+
+	c := fake.NewClientBuilder().Build()
+	r := NewRecorder(c)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r.Start(ctx)
+
+	SetRecorder(r)
+	defer SetRecorder(nil)
+	Normal(testObject("app"), "Test", "message")
+
+	list := &corev1.EventList{}
+	err := wait.PollUntilContextTimeout(ctx, 10*time.Millisecond, 5*time.Second, true, func(ctx context.Context) (bool, error) {
+		if err := c.List(ctx, list); err != nil {
+			return false, err
+		}
+		return len(list.Items) == 1, nil
+	})
+	if err != nil {
+		t.Fatalf("Expected the Event to be created: %v", err)
+	}
+	if list.Items[0].Type != corev1.EventTypeNormal || list.Items[0].Reason != "Test" {
+		t.Errorf("Unexpected Event %+v", list.Items[0])
+	}
+}
+
+func TestRecorderQueueFull(t *testing.T) {
+	// Without Start nothing drains the queue
+	r := NewRecorder(fake.NewClientBuilder().Build())
+	for i := 0; i < queueSize+10; i++ {
+		r.Record(NewEvent(testObject("app"), corev1.EventTypeNormal, "Test", "message"))
+	}
+	if len(r.events) != queueSize {
+		t.Errorf("Expected the queue to hold %d Events, got %d", queueSize, len(r.events))
+	}
+}
+
+func TestNewEventClusterScoped(t *testing.T) {
+	object := corev1.ObjectReference{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRoleBinding", Name: "backplane-srep"}
+	event := NewEvent(object, corev1.EventTypeWarning, "Test", "message")
+	if event.Namespace != config.OperatorNamespace || event.InvolvedObject != object {
+		t.Errorf("Expected the Event to be about the object in the webhook namespace, got %+v", event)
+	}
+}
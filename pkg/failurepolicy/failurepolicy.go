@@ -0,0 +1,128 @@
+// Package failurepolicy keeps the failure policy overrides of the webhooks in
+// sync with the webhooks.FailurePolicyConfigMapName ConfigMap on every
+// replica, so requests timed out on or shed are answered as the cluster's
+// overrides say.
+package failurepolicy
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/util/wait"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks"
+)
+
+const (
+	// resyncPeriod is how often the ConfigMap is read again, in case an event
+	// was missed
+	resyncPeriod = 5 * time.Minute
+)
+
+var log = logf.Log.WithName("failurepolicy")
+
+// Watcher keeps the failure policy overrides in sync with the ConfigMap,
+// whose overrides take precedence over those the Watcher is created with
+type Watcher struct {
+	reader    client.Reader
+	client    client.Client
+	namespace string
+	defaults  webhooks.FailurePolicies
+
+	mu      sync.Mutex
+	current webhooks.FailurePolicies
+}
+
+// NewWatcher creates a Watcher for the ConfigMap in namespace. defaults are
+// the overrides set without the ConfigMap, eg from
+// webhooks.FailurePoliciesFromEnv.
+func NewWatcher(c client.Client, namespace string, defaults webhooks.FailurePolicies) *Watcher {
+	return &Watcher{
+		reader:    c,
+		client:    c,
+		namespace: namespace,
+		defaults:  defaults,
+		current:   defaults,
+	}
+}
+
+// Start syncs the overrides, then keeps them in sync with the ConfigMap until
+// ctx is cancelled
+func (w *Watcher) Start(ctx context.Context) error {
+	config, err := k8sutil.RestConfig()
+	if err != nil {
+		return err
+	}
+	informers, err := cache.New(config, cache.Options{
+		Scheme:            w.client.Scheme(),
+		DefaultNamespaces: map[string]cache.Config{w.namespace: {}},
+		ByObject: map[client.Object]cache.ByObject{
+			&corev1.ConfigMap{}: {Field: fields.OneTermEqualSelector("metadata.name", webhooks.FailurePolicyConfigMapName)},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	w.reader = informers
+
+	informer, err := informers.GetInformer(ctx, &corev1.ConfigMap{})
+	if err != nil {
+		return err
+	}
+	if _, err := informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { w.Sync(ctx) },
+		UpdateFunc: func(interface{}, interface{}) { w.Sync(ctx) },
+		DeleteFunc: func(interface{}) { w.Sync(ctx) },
+	}); err != nil {
+		return err
+	}
+
+	go func() {
+		if err := informers.Start(ctx); err != nil {
+			log.Error(err, "Failure policy cache stopped")
+		}
+	}()
+	if !informers.WaitForCacheSync(ctx) {
+		return fmt.Errorf("failed to sync failure policy cache")
+	}
+	go wait.UntilWithContext(ctx, w.Sync, resyncPeriod)
+	return nil
+}
+
+// Sync reads the ConfigMap and sets the overrides. A missing ConfigMap
+// restores the defaults; invalid entries are left out.
+func (w *Watcher) Sync(ctx context.Context) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	cm := &corev1.ConfigMap{}
+	err := w.reader.Get(ctx, client.ObjectKey{Namespace: w.namespace, Name: webhooks.FailurePolicyConfigMapName}, cm)
+	if err != nil && !apierrors.IsNotFound(err) {
+		// Keep the overrides in place rather than flip every webhook back
+		log.Error(err, "Failed to read failure policy overrides")
+		return
+	}
+	overrides, errs := webhooks.FailurePoliciesFromData(cm.Data)
+	for _, err := range errs {
+		log.Error(err, "Ignoring invalid failure policy override", "configMap", webhooks.FailurePolicyConfigMapName)
+	}
+
+	policies := w.defaults.Merge(overrides)
+	if reflect.DeepEqual(policies, w.current) {
+		return
+	}
+	w.current = policies
+	webhooks.SetFailurePolicies(policies)
+	log.Info("Failure policy overrides changed", "overrides", policies)
+}
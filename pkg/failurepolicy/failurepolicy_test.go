@@ -0,0 +1,51 @@
+package failurepolicy
+
+import (
+	"context"
+	"testing"
+
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/debugpodtolerations"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/hiveownership"
+)
+
+const testNamespace = "openshift-validation-webhook"
+
+func TestWatcherSync(t *testing.T) {
+	t.Cleanup(func() { webhooks.SetFailurePolicies(webhooks.FailurePolicies{}) })
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: webhooks.FailurePolicyConfigMapName, Namespace: testNamespace},
+		Data: map[string]string{
+			debugpodtolerations.WebhookName: "Ignore",
+			hiveownership.WebhookName:       "Sometimes",
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(cm).Build()
+	w := NewWatcher(c, testNamespace, webhooks.FailurePolicies{webhooks.AllWebhooks: admissionregv1.Fail})
+	ctx := context.Background()
+
+	// The ConfigMap's overrides take precedence over the defaults, and invalid
+	// ones are left out
+	w.Sync(ctx)
+	if policy := webhooks.FailurePolicy(debugpodtolerations.NewWebhook()); policy != admissionregv1.Ignore {
+		t.Fatalf("Expected the ConfigMap's failure policy Ignore, got %s", policy)
+	}
+	if policy := webhooks.FailurePolicy(hiveownership.NewWebhook()); policy != admissionregv1.Fail {
+		t.Fatalf("Expected the default failure policy Fail, got %s", policy)
+	}
+
+	if err := c.Delete(ctx, cm); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	w.Sync(ctx)
+	if policy := webhooks.FailurePolicy(debugpodtolerations.NewWebhook()); policy != admissionregv1.Fail {
+		t.Fatalf("Expected the defaults without the ConfigMap, got %s", policy)
+	}
+}
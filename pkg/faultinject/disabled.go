@@ -0,0 +1,7 @@
+//go:build !fault_injection
+
+package faultinject
+
+// Enabled is false unless the binary is built with BuildTag, so faults are
+// never injected in production images
+const Enabled = false
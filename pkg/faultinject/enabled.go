@@ -0,0 +1,6 @@
+//go:build fault_injection
+
+package faultinject
+
+// Enabled is true in binaries built with BuildTag
+const Enabled = true
@@ -0,0 +1,177 @@
+package faultinject
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// LatencyEnvVar is a duration, eg 1500ms, added to every read the
+	// webhooks make through the shared client, simulating a slow API server.
+	// Reads whose context ends first fail with its error.
+	LatencyEnvVar = "FAULT_INJECTION_LATENCY"
+	// NotFoundEnvVar is a comma-separated list of the kinds, as Kind.group,
+	// whose Gets fail as not found, eg
+	// ImageStreamTag.image.openshift.io,Config.imageregistry.operator.openshift.io
+	NotFoundEnvVar = "FAULT_INJECTION_NOT_FOUND"
+	// RatioEnvVar is the fraction, between 0 and 1, of reads faults are
+	// injected into. Defaults to 1.
+	RatioEnvVar = "FAULT_INJECTION_RATIO"
+
+	// BuildTag is the build tag fault injection is compiled in with
+	BuildTag = "fault_injection"
+)
+
+var log = logf.Log.WithName("faultinject")
+
+// Faults are the failures injected into the reads of a client
+type Faults struct {
+	// Latency is added to every faulted read
+	Latency time.Duration
+	// NotFound are the kinds whose faulted Gets fail as not found
+	NotFound []schema.GroupKind
+	// Ratio is the fraction of reads which are faulted
+	Ratio float64
+
+	random func() float64
+}
+
+// String describes the faults for logs
+func (f *Faults) String() string {
+	kinds := make([]string, 0, len(f.NotFound))
+	for _, gk := range f.NotFound {
+		kinds = append(kinds, gk.String())
+	}
+	return fmt.Sprintf("latency=%s notFound=[%s] ratio=%g", f.Latency, strings.Join(kinds, ","), f.Ratio)
+}
+
+// FromEnv reads the faults from LatencyEnvVar, NotFoundEnvVar and
+// RatioEnvVar. It returns nil when none are set, and an error when they're
+// set in a binary built without BuildTag, so production images never inject
+// faults.
+func FromEnv() (*Faults, error) {
+	latency, notFound, ratio := os.Getenv(LatencyEnvVar), os.Getenv(NotFoundEnvVar), os.Getenv(RatioEnvVar)
+	if latency == "" && notFound == "" && ratio == "" {
+		return nil, nil
+	}
+	if !Enabled {
+		return nil, fmt.Errorf("fault injection is only available in binaries built with the %s tag", BuildTag)
+	}
+	return parse(latency, notFound, ratio)
+}
+
+func parse(latency, notFound, ratio string) (*Faults, error) {
+	f := &Faults{Ratio: 1}
+	var err error
+	if latency != "" {
+		if f.Latency, err = time.ParseDuration(latency); err != nil || f.Latency < 0 {
+			return nil, fmt.Errorf("invalid %s %q", LatencyEnvVar, latency)
+		}
+	}
+	for _, kind := range strings.Split(notFound, ",") {
+		kind = strings.TrimSpace(kind)
+		if kind == "" {
+			continue
+		}
+		gk := schema.ParseGroupKind(kind)
+		if gk.Kind == "" {
+			return nil, fmt.Errorf("invalid %s kind %q", NotFoundEnvVar, kind)
+		}
+		f.NotFound = append(f.NotFound, gk)
+	}
+	if ratio != "" {
+		if f.Ratio, err = strconv.ParseFloat(ratio, 64); err != nil || f.Ratio < 0 || f.Ratio > 1 {
+			return nil, fmt.Errorf("invalid %s %q", RatioEnvVar, ratio)
+		}
+	}
+	return f, nil
+}
+
+// faultyClient injects faults into the reads of the client it wraps. Writes
+// are passed through.
+type faultyClient struct {
+	client.Client
+	faults *Faults
+}
+
+// Client wraps c so the faults are injected into its reads
+func Client(c client.Client, f *Faults) client.Client {
+	if c == nil || f == nil {
+		return c
+	}
+	log.Info("Injecting faults into the webhooks' reads", "faults", f.String())
+	return &faultyClient{Client: c, faults: f}
+}
+
+// Get implements client.Reader
+func (c *faultyClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	if !c.faulted() {
+		return c.Client.Get(ctx, key, obj, opts...)
+	}
+	if err := c.delay(ctx); err != nil {
+		return err
+	}
+	if gvk, err := apiutil.GVKForObject(obj, c.Scheme()); err == nil && slices.Contains(c.faults.NotFound, gvk.GroupKind()) {
+		return apierrors.NewNotFound(c.resource(gvk), key.Name)
+	}
+	return c.Client.Get(ctx, key, obj, opts...)
+}
+
+// List implements client.Reader. Lists are only delayed.
+func (c *faultyClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	if c.faulted() {
+		if err := c.delay(ctx); err != nil {
+			return err
+		}
+	}
+	return c.Client.List(ctx, list, opts...)
+}
+
+// faulted decides whether a read is faulted
+func (c *faultyClient) faulted() bool {
+	if c.faults.Ratio >= 1 {
+		return true
+	}
+	random := c.faults.random
+	if random == nil {
+		random = rand.Float64
+	}
+	return random() < c.faults.Ratio
+}
+
+// delay waits for the latency, or returns the context's error if it ends
+// first
+func (c *faultyClient) delay(ctx context.Context) error {
+	if c.faults.Latency <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(c.faults.Latency)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("injected latency of %s: %w", c.faults.Latency, ctx.Err())
+	}
+}
+
+// resource returns the group and resource of the kind for not found errors,
+// falling back to the kind when the RESTMapper doesn't know it
+func (c *faultyClient) resource(gvk schema.GroupVersionKind) schema.GroupResource {
+	if mapping, err := c.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version); err == nil {
+		return mapping.Resource.GroupResource()
+	}
+	return schema.GroupResource{Group: gvk.Group, Resource: strings.ToLower(gvk.Kind)}
+}
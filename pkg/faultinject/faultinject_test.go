@@ -0,0 +1,138 @@
+package faultinject
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	imagev1 "github.com/openshift/api/image/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+)
+
+var imageStreamTag = schema.GroupKind{Group: "image.openshift.io", Kind: "ImageStreamTag"}
+
+func newClient(t *testing.T) client.Client {
+	t.Helper()
+	scheme, err := k8sutil.SharedScheme()
+	if err != nil {
+		t.Fatalf("Unexpected error getting the scheme: %v", err)
+	}
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&imagev1.ImageStreamTag{ObjectMeta: metav1.ObjectMeta{Name: "cli:latest", Namespace: "openshift"}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "openshift"}},
+	).Build()
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		latency  string
+		notFound string
+		ratio    string
+		expected *Faults
+		err      bool
+	}{
+		{
+			name:     "every fault",
+			latency:  "1500ms",
+			notFound: "ImageStreamTag.image.openshift.io, Config.imageregistry.operator.openshift.io",
+			ratio:    "0.25",
+			expected: &Faults{Latency: 1500 * time.Millisecond, NotFound: []schema.GroupKind{imageStreamTag, {Group: "imageregistry.operator.openshift.io", Kind: "Config"}}, Ratio: 0.25},
+		},
+		{
+			name:     "ratio defaults to every read",
+			latency:  "2s",
+			expected: &Faults{Latency: 2 * time.Second, Ratio: 1},
+		},
+		{name: "invalid latency", latency: "slow", err: true},
+		{name: "negative latency", latency: "-1s", err: true},
+		{name: "ratio out of range", ratio: "1.5", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			faults, err := parse(test.latency, test.notFound, test.ratio)
+			if test.err {
+				if err == nil {
+					t.Fatalf("Expected an error, got %s", faults)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %s", err.Error())
+			}
+			if faults.String() != test.expected.String() {
+				t.Errorf("Expected %s, got %s", test.expected, faults)
+			}
+		})
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	if faults, err := FromEnv(); faults != nil || err != nil {
+		t.Fatalf("Expected no faults without the environment variables, got %v, %v", faults, err)
+	}
+
+	t.Setenv(LatencyEnvVar, "1s")
+	faults, err := FromEnv()
+	if Enabled && (faults == nil || err != nil) {
+		t.Errorf("Expected faults when built with %s, got %v, %v", BuildTag, faults, err)
+	}
+	if !Enabled && (faults != nil || err == nil) {
+		t.Errorf("Expected an error when built without %s, got %v, %v", BuildTag, faults, err)
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	c := Client(newClient(t), &Faults{NotFound: []schema.GroupKind{imageStreamTag}, Ratio: 1})
+	ctx := context.Background()
+
+	err := c.Get(ctx, client.ObjectKey{Namespace: "openshift", Name: "cli:latest"}, &imagev1.ImageStreamTag{})
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("Expected the ImageStreamTag not to be found, got %v", err)
+	}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: "openshift", Name: "config"}, &corev1.ConfigMap{}); err != nil {
+		t.Errorf("Expected other kinds to be read, got %s", err.Error())
+	}
+}
+
+func TestLatency(t *testing.T) {
+	c := Client(newClient(t), &Faults{Latency: time.Minute, Ratio: 1})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := c.Get(ctx, client.ObjectKey{Namespace: "openshift", Name: "config"}, &corev1.ConfigMap{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the read to time out, got %v", err)
+	}
+	err = c.List(ctx, &corev1.ConfigMapList{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the list to time out, got %v", err)
+	}
+}
+
+func TestRatio(t *testing.T) {
+	draws := []float64{0.1, 0.9}
+	faults := &Faults{NotFound: []schema.GroupKind{imageStreamTag}, Ratio: 0.5, random: func() float64 {
+		draw := draws[0]
+		draws = draws[1:]
+		return draw
+	}}
+	c := Client(newClient(t), faults)
+	key := client.ObjectKey{Namespace: "openshift", Name: "cli:latest"}
+
+	if err := c.Get(context.Background(), key, &imagev1.ImageStreamTag{}); !apierrors.IsNotFound(err) {
+		t.Errorf("Expected the first read to be faulted, got %v", err)
+	}
+	if err := c.Get(context.Background(), key, &imagev1.ImageStreamTag{}); err != nil {
+		t.Errorf("Expected the second read not to be faulted, got %s", err.Error())
+	}
+}
@@ -0,0 +1,298 @@
+package featuregates
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks"
+)
+
+const (
+	// ConfigMapName is the ConfigMap in the webhook namespace which enables or
+	// disables webhooks on this cluster. Each key is a webhook name and each
+	// value "true" or "false". Opt-in webhooks must be enabled in it.
+	ConfigMapName string = "webhook-feature-gates"
+
+	// resyncPeriod is how often the leader removes the configurations of
+	// disabled webhooks again, in case whatever manages them has put them back
+	resyncPeriod = 5 * time.Minute
+)
+
+var (
+	log = logf.Log.WithName("featuregates")
+
+	mu sync.RWMutex
+	// gates are the webhooks the ConfigMap enables or disables
+	gates = map[string]bool{}
+	// optIn are the webhooks which are disabled unless the ConfigMap enables
+	// them
+	optIn = map[string]bool{}
+)
+
+// Enabled tells whether the webhook is enabled on this cluster. Webhooks are
+// enabled unless the ConfigMap disables them, and opt-in webhooks are
+// disabled unless it enables them.
+func Enabled(name string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return enabled(name)
+}
+
+// enabled is Enabled with mu held
+func enabled(name string) bool {
+	if gate, ok := gates[name]; ok {
+		return gate
+	}
+	return !optIn[name]
+}
+
+// SetOptIn records which of the webhooks are opt-in, so they stay disabled
+// until the ConfigMap enables them. It must be called before the webhooks
+// serve requests.
+func SetOptIn(hooks webhooks.RegisteredWebhooks) {
+	next := map[string]bool{}
+	for name, factory := range hooks {
+		if webhooks.IsOptIn(factory()) {
+			next[name] = true
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	optIn = next
+}
+
+// set replaces the gates with those in the ConfigMap data and returns the
+// names of the webhooks which the change enabled or disabled
+func set(data map[string]string) []string {
+	next := parse(data)
+
+	mu.Lock()
+	defer mu.Unlock()
+	names := map[string]bool{}
+	for name := range next {
+		names[name] = true
+	}
+	for name := range gates {
+		names[name] = true
+	}
+	was := map[string]bool{}
+	for name := range names {
+		was[name] = enabled(name)
+	}
+	gates = next
+	changed := []string{}
+	for name := range names {
+		if enabled(name) != was[name] {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// parse returns the webhooks the ConfigMap data enables or disables
+func parse(data map[string]string) map[string]bool {
+	parsed := map[string]bool{}
+	for name, value := range data {
+		enabled, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			log.Error(err, "Ignoring invalid feature gate", "webhookName", name, "value", value)
+			continue
+		}
+		parsed[name] = enabled
+	}
+	return parsed
+}
+
+// Watcher keeps the gates in sync with the ConfigMap on every replica. On the
+// elected leader it also removes the WebhookConfigurations of disabled
+// webhooks so the API server stops calling them, and restores them when the
+// webhooks are enabled again.
+type Watcher struct {
+	reader    client.Reader
+	client    client.Client
+	hooks     webhooks.RegisteredWebhooks
+	namespace string
+
+	mu sync.Mutex
+	// changed is signalled when the gates change, so the leader reconciles
+	// the configurations without waiting for the resync
+	changed chan struct{}
+}
+
+// NewWatcher creates a Watcher for the ConfigMap in namespace
+func NewWatcher(c client.Client, hooks webhooks.RegisteredWebhooks, namespace string) *Watcher {
+	return &Watcher{
+		reader:    c,
+		client:    c,
+		hooks:     hooks,
+		namespace: namespace,
+		changed:   make(chan struct{}, 1),
+	}
+}
+
+// Start syncs the gates, then keeps them in sync with the ConfigMap until ctx
+// is cancelled
+func (w *Watcher) Start(ctx context.Context) error {
+	config, err := k8sutil.RestConfig()
+	if err != nil {
+		return err
+	}
+	informers, err := cache.New(config, cache.Options{
+		Scheme:            w.client.Scheme(),
+		DefaultNamespaces: map[string]cache.Config{w.namespace: {}},
+		ByObject: map[client.Object]cache.ByObject{
+			&corev1.ConfigMap{}: {Field: fields.OneTermEqualSelector("metadata.name", ConfigMapName)},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	w.reader = informers
+
+	informer, err := informers.GetInformer(ctx, &corev1.ConfigMap{})
+	if err != nil {
+		return err
+	}
+	if _, err := informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { w.Sync(ctx) },
+		UpdateFunc: func(interface{}, interface{}) { w.Sync(ctx) },
+		DeleteFunc: func(interface{}) { w.Sync(ctx) },
+	}); err != nil {
+		return err
+	}
+
+	go func() {
+		if err := informers.Start(ctx); err != nil {
+			log.Error(err, "Feature gate cache stopped")
+		}
+	}()
+	if !informers.WaitForCacheSync(ctx) {
+		return fmt.Errorf("failed to sync feature gate cache")
+	}
+	return nil
+}
+
+// Sync reads the ConfigMap and updates the gates
+func (w *Watcher) Sync(ctx context.Context) {
+	cm := &corev1.ConfigMap{}
+	err := w.reader.Get(ctx, client.ObjectKey{Namespace: w.namespace, Name: ConfigMapName}, cm)
+	if err != nil && !apierrors.IsNotFound(err) {
+		log.Error(err, "Failed to read webhook feature gates")
+		return
+	}
+	// A missing ConfigMap has no data, which enables every webhook but the
+	// opt-in ones
+	changed := set(cm.Data)
+	for _, name := range changed {
+		log.Info("Webhook feature gate changed", "webhookName", name, "enabled", Enabled(name))
+	}
+	if len(changed) > 0 {
+		select {
+		case w.changed <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Run removes or restores the configurations of the webhooks whenever the
+// gates change, and every resyncPeriod in case whatever manages them has put
+// them back, until ctx is cancelled. It must only run on the elected leader,
+// so replicas don't race to remove and restore the same configurations.
+func (w *Watcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(resyncPeriod)
+	defer ticker.Stop()
+	for {
+		w.Reconcile(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.changed:
+		}
+	}
+}
+
+// Reconcile removes the configurations of the disabled webhooks and restores
+// those of the enabled ones
+func (w *Watcher) Reconcile(ctx context.Context) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for name := range w.hooks {
+		if Enabled(name) {
+			w.restore(ctx, name)
+		} else {
+			w.remove(ctx, name)
+		}
+	}
+}
+
+// remove deletes the webhook's configuration
+func (w *Watcher) remove(ctx context.Context, name string) {
+	obj := configuration(name)
+	if err := w.client.Delete(ctx, obj); err != nil {
+		if !apierrors.IsNotFound(err) {
+			log.Error(err, "Failed to remove configuration of disabled webhook", "webhookName", name)
+		}
+		return
+	}
+	log.Info("Removed configuration of disabled webhook", "webhookName", name, "configuration", obj.GetName())
+}
+
+// restore creates the webhook's configuration, as it's generated, if it's
+// missing. Only the configurations the SelectorSyncSet creates are generated
+// here; those of webhooks only enabled on hosted clusters are left to their
+// package to restore.
+func (w *Watcher) restore(ctx context.Context, name string) {
+	hook := w.hooks[name]()
+	if !hook.ClassicEnabled() || len(hook.Rules()) == 0 {
+		return
+	}
+	var obj client.Object
+	if webhooks.IsMutating(name) {
+		generated := webhooks.MutatingWebhookConfiguration(hook, w.namespace)
+		obj = &generated
+	} else {
+		generated := webhooks.ValidatingWebhookConfiguration(hook, w.namespace)
+		obj = &generated
+	}
+	if err := w.client.Get(ctx, client.ObjectKeyFromObject(obj), configuration(name)); !apierrors.IsNotFound(err) {
+		if err != nil {
+			log.Error(err, "Failed to get configuration of enabled webhook", "webhookName", name)
+		}
+		return
+	}
+	if err := w.client.Create(ctx, obj); err != nil && !apierrors.IsAlreadyExists(err) {
+		log.Error(err, "Failed to restore configuration of enabled webhook", "webhookName", name)
+		return
+	}
+	log.Info("Restored configuration of enabled webhook", "webhookName", name, "configuration", obj.GetName())
+}
+
+// configuration returns an empty Validating or MutatingWebhookConfiguration
+// named for the webhook
+func configuration(name string) client.Object {
+	meta := metav1.ObjectMeta{Name: webhooks.ConfigurationName(name)}
+	if webhooks.IsMutating(name) {
+		return &admissionregv1.MutatingWebhookConfiguration{ObjectMeta: meta}
+	}
+	return &admissionregv1.ValidatingWebhookConfiguration{ObjectMeta: meta}
+}
@@ -0,0 +1,169 @@
+package featuregates
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/hiveownership"
+)
+
+const testNamespace = "openshift-validation-webhook"
+
+func TestParse(t *testing.T) {
+	data := map[string]string{
+		"namespace-validation":  "false",
+		"pod-validation":        " False ",
+		"service-mutation":      "true",
+		"podimagespec-mutation": "maybe",
+	}
+	expected := map[string]bool{"namespace-validation": false, "pod-validation": false, "service-mutation": true}
+	if actual := parse(data); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("Expected %v, got %v", expected, actual)
+	}
+}
+
+func TestSet(t *testing.T) {
+	t.Cleanup(func() { set(nil) })
+
+	if changed := set(map[string]string{"namespace-validation": "false"}); !reflect.DeepEqual(changed, []string{"namespace-validation"}) {
+		t.Fatalf("Expected namespace-validation to change, got %v", changed)
+	}
+	if Enabled("namespace-validation") {
+		t.Fatalf("Expected namespace-validation to be disabled")
+	}
+	if changed := set(map[string]string{"namespace-validation": "false"}); len(changed) != 0 {
+		t.Fatalf("Expected nothing to change, got %v", changed)
+	}
+	if changed := set(nil); !reflect.DeepEqual(changed, []string{"namespace-validation"}) {
+		t.Fatalf("Expected namespace-validation to change, got %v", changed)
+	}
+	if !Enabled("namespace-validation") {
+		t.Fatalf("Expected namespace-validation to be enabled")
+	}
+}
+
+// optInHook is a webhook which is disabled unless enabled per cluster
+type optInHook struct {
+	webhooks.Webhook
+}
+
+func (optInHook) OptIn() bool { return true }
+
+func TestSetOptIn(t *testing.T) {
+	t.Cleanup(func() {
+		set(nil)
+		SetOptIn(nil)
+	})
+
+	SetOptIn(webhooks.RegisteredWebhooks{
+		"optin-validation":        func() webhooks.Webhook { return optInHook{hiveownership.NewWebhook()} },
+		hiveownership.WebhookName: func() webhooks.Webhook { return hiveownership.NewWebhook() },
+	})
+	if Enabled("optin-validation") {
+		t.Fatalf("Expected the opt-in webhook to be disabled by default")
+	}
+	if !Enabled(hiveownership.WebhookName) {
+		t.Fatalf("Expected %s to be enabled by default", hiveownership.WebhookName)
+	}
+	if changed := set(map[string]string{"optin-validation": "true"}); !reflect.DeepEqual(changed, []string{"optin-validation"}) {
+		t.Fatalf("Expected optin-validation to change, got %v", changed)
+	}
+	if !Enabled("optin-validation") {
+		t.Fatalf("Expected the opt-in webhook to be enabled by the ConfigMap")
+	}
+	if changed := set(map[string]string{"optin-validation": "false"}); !reflect.DeepEqual(changed, []string{"optin-validation"}) {
+		t.Fatalf("Expected optin-validation to change, got %v", changed)
+	}
+	if changed := set(nil); len(changed) != 0 {
+		t.Fatalf("Expected removing the disabled gate of an opt-in webhook to change nothing, got %v", changed)
+	}
+}
+
+func TestWatcherSync(t *testing.T) {
+	t.Cleanup(func() { set(nil) })
+
+	gates := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: ConfigMapName, Namespace: testNamespace},
+		Data:       map[string]string{hiveownership.WebhookName: "false"},
+	}
+	config := &admissionregv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "sre-" + hiveownership.WebhookName},
+		Webhooks:   []admissionregv1.ValidatingWebhook{{Name: hiveownership.WebhookName + ".managed.openshift.io"}},
+	}
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(gates, config).Build()
+	hooks := webhooks.RegisteredWebhooks{
+		hiveownership.WebhookName: func() webhooks.Webhook { return hiveownership.NewWebhook() },
+	}
+	w := NewWatcher(c, hooks, testNamespace)
+	ctx := context.Background()
+
+	// Every replica syncs the gates, but only the leader reconciles the
+	// configurations
+	w.Sync(ctx)
+	if Enabled(hiveownership.WebhookName) {
+		t.Fatalf("Expected %s to be disabled", hiveownership.WebhookName)
+	}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(config), &admissionregv1.ValidatingWebhookConfiguration{}); err != nil {
+		t.Fatalf("Expected syncing the gates to leave the configuration alone, got %v", err)
+	}
+	w.Reconcile(ctx)
+	err := c.Get(ctx, client.ObjectKeyFromObject(config), &admissionregv1.ValidatingWebhookConfiguration{})
+	if !apierrors.IsNotFound(err) {
+		t.Fatalf("Expected the configuration of the disabled webhook to be removed, got %v", err)
+	}
+
+	gates.Data[hiveownership.WebhookName] = "true"
+	if err := c.Update(ctx, gates); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	// A new leader, which didn't remove the configuration, restores it
+	leader := NewWatcher(c, hooks, testNamespace)
+	leader.Sync(ctx)
+	if !Enabled(hiveownership.WebhookName) {
+		t.Fatalf("Expected %s to be enabled", hiveownership.WebhookName)
+	}
+	leader.Reconcile(ctx)
+	restored := &admissionregv1.ValidatingWebhookConfiguration{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(config), restored); err != nil {
+		t.Fatalf("Expected the configuration of the enabled webhook to be restored, got %v", err)
+	}
+	generated := webhooks.ValidatingWebhookConfiguration(hiveownership.NewWebhook(), testNamespace)
+	if !reflect.DeepEqual(restored.Webhooks, generated.Webhooks) {
+		t.Fatalf("Expected the generated webhooks %v, got %v", generated.Webhooks, restored.Webhooks)
+	}
+}
+
+func TestWatcherSignalsChanges(t *testing.T) {
+	t.Cleanup(func() { set(nil) })
+
+	gates := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: ConfigMapName, Namespace: testNamespace},
+		Data:       map[string]string{hiveownership.WebhookName: "false"},
+	}
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(gates).Build()
+	w := NewWatcher(c, webhooks.RegisteredWebhooks{}, testNamespace)
+	ctx := context.Background()
+
+	w.Sync(ctx)
+	select {
+	case <-w.changed:
+	default:
+		t.Fatalf("Expected the leader to be signalled the gates changed")
+	}
+	w.Sync(ctx)
+	select {
+	case <-w.changed:
+		t.Fatalf("Expected no signal when the gates are unchanged")
+	default:
+	}
+}
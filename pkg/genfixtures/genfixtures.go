@@ -0,0 +1,399 @@
+// Package genfixtures generates selftest fixtures from the objects of a live
+// cluster, so the webhooks are tested against the shapes real clusters have
+// rather than hand-written minimal objects. Objects are sanitized of anything
+// which could identify the cluster or its workloads before they're written,
+// but generated fixtures should still be reviewed before they're committed.
+package genfixtures
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	imagestreamv1 "github.com/openshift/api/image/v1"
+	registryv1 "github.com/openshift/api/imageregistry/v1"
+	operatorv1 "github.com/openshift/api/operator/v1"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/imagespec"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/selftest"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/podimagespec"
+)
+
+const (
+	// DefaultMaxPods is how many pods are sampled when Options doesn't say
+	DefaultMaxPods = 20
+
+	// sanitizedAppsDomain replaces the cluster's apps domain in the hostname
+	// of the internal registry's default route
+	sanitizedAppsDomain = "apps.example.com"
+)
+
+var (
+	// fixtureUser creates the sampled pods. The users which created them
+	// aren't known from the pods.
+	fixtureUser = authenticationv1.UserInfo{Username: "customer", Groups: []string{"system:authenticated"}}
+
+	parser = imagespec.NewParser()
+)
+
+// Options configures the fixtures generated
+type Options struct {
+	// Namespaces are the namespaces pods are sampled from, or every namespace
+	// when empty
+	Namespaces []string
+	// MaxPods is the most pods sampled, DefaultMaxPods when 0
+	MaxPods int
+}
+
+// Generated is a fixture generated from the cluster
+type Generated struct {
+	// Webhook is the webhook the fixture is for
+	Webhook string
+	// Name names the fixture's file
+	Name    string
+	Fixture selftest.Fixture
+}
+
+// Generate samples the pods of the cluster using images in the internal
+// registry, at most one per controller, and returns a podimagespec-mutation
+// fixture creating each. The fixtures serve the registry config and the
+// ImageStreamTags of the pods' images, and expect the answer the webhook
+// gives now.
+func Generate(ctx context.Context, c client.Client, hooks webhooks.RegisteredWebhooks, scheme *runtime.Scheme, opts Options) ([]Generated, error) {
+	factory := hooks[podimagespec.WebhookName]
+	if factory == nil {
+		return nil, fmt.Errorf("webhook %s is not registered", podimagespec.WebhookName)
+	}
+	maxPods := opts.MaxPods
+	if maxPods <= 0 {
+		maxPods = DefaultMaxPods
+	}
+
+	registryConfig := &registryv1.Config{}
+	if err := c.Get(ctx, client.ObjectKey{Name: "cluster"}, registryConfig); err != nil {
+		return nil, fmt.Errorf("couldn't get the image registry config: %w", err)
+	}
+
+	pods, err := samplePods(ctx, c, opts.Namespaces, maxPods)
+	if err != nil {
+		return nil, err
+	}
+
+	s := newSanitizer()
+	generated := []Generated{}
+	for i, pod := range pods {
+		objects := []runtime.Object{sanitizeRegistryConfig(registryConfig)}
+		for _, ref := range internalImages(&pod) {
+			ist := &imagestreamv1.ImageStreamTag{}
+			err := c.Get(ctx, client.ObjectKey{Namespace: ref.Namespace, Name: ref.ImageStreamTag()}, ist)
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("couldn't get ImageStreamTag %s/%s: %w", ref.Namespace, ref.ImageStreamTag(), err)
+			}
+			objects = append(objects, s.imageStreamTag(ist))
+		}
+
+		sanitized := s.pod(&pod, i+1)
+		fixture, err := newFixture(fmt.Sprintf("gen-fixtures-%d", i+1), sanitized, objects)
+		if err != nil {
+			return nil, err
+		}
+		if err := selftest.Record(factory, &fixture, scheme); err != nil {
+			return nil, err
+		}
+		generated = append(generated, Generated{
+			Webhook: podimagespec.WebhookName,
+			Name:    sanitized.Namespace + "-" + sanitized.Name,
+			Fixture: fixture,
+		})
+	}
+	return generated, nil
+}
+
+// Write writes each fixture as YAML into the directory of its webhook in dir,
+// the layout selftest.Load reads
+func Write(dir string, generated []Generated) error {
+	for _, g := range generated {
+		raw, err := json.Marshal(g.Fixture)
+		if err != nil {
+			return err
+		}
+		fixture := map[string]interface{}{}
+		if err := json.Unmarshal(raw, &fixture); err != nil {
+			return err
+		}
+		raw, err = yaml.Marshal(dropNulls(fixture))
+		if err != nil {
+			return err
+		}
+		webhookDir := filepath.Join(dir, g.Webhook)
+		if err := os.MkdirAll(webhookDir, 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(webhookDir, g.Name+".yaml"), raw, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropNulls removes the null fields the API types marshal, recursively
+func dropNulls(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if field == nil {
+				delete(v, key)
+				continue
+			}
+			v[key] = dropNulls(field)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = dropNulls(item)
+		}
+	}
+	return value
+}
+
+// samplePods lists the pods using images in the internal registry, keeping
+// the first of each controller's pods, as the others have the same shape
+func samplePods(ctx context.Context, c client.Client, namespaces []string, maxPods int) ([]corev1.Pod, error) {
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	sampled := []corev1.Pod{}
+	controllers := map[types.UID]bool{}
+	for _, namespace := range namespaces {
+		pods := &corev1.PodList{}
+		if err := c.List(ctx, pods, client.InNamespace(namespace)); err != nil {
+			return nil, fmt.Errorf("couldn't list pods: %w", err)
+		}
+		for _, pod := range pods.Items {
+			if len(internalImages(&pod)) == 0 {
+				continue
+			}
+			if owner := metav1.GetControllerOf(&pod); owner != nil {
+				if controllers[owner.UID] {
+					continue
+				}
+				controllers[owner.UID] = true
+			}
+			sampled = append(sampled, pod)
+			if len(sampled) == maxPods {
+				return sampled, nil
+			}
+		}
+	}
+	return sampled, nil
+}
+
+// internalImages returns the images of the pod in the internal registry
+func internalImages(pod *corev1.Pod) []imagespec.Reference {
+	refs := []imagespec.Reference{}
+	for _, container := range append(slices.Clone(pod.Spec.InitContainers), pod.Spec.Containers...) {
+		if ref, ok := parser.Parse(container.Image); ok && !slices.Contains(refs, ref) {
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// newFixture returns a fixture creating the pod, serving the objects
+func newFixture(uid string, pod *corev1.Pod, objects []runtime.Object) (selftest.Fixture, error) {
+	object, err := toUnstructured(pod)
+	if err != nil {
+		return selftest.Fixture{}, err
+	}
+	raw, err := json.Marshal(object)
+	if err != nil {
+		return selftest.Fixture{}, err
+	}
+	fixture := selftest.Fixture{
+		Description: fmt.Sprintf("pod %s/%s, generated from a live cluster", pod.Namespace, pod.Name),
+		Request: admissionv1.AdmissionRequest{
+			UID:       types.UID(uid),
+			Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+			Resource:  metav1.GroupVersionResource{Version: "v1", Resource: "pods"},
+			Operation: admissionv1.Create,
+			Namespace: pod.Namespace,
+			Name:      pod.Name,
+			UserInfo:  fixtureUser,
+			Object:    runtime.RawExtension{Raw: raw},
+		},
+	}
+	for _, obj := range objects {
+		object, err := toUnstructured(obj)
+		if err != nil {
+			return selftest.Fixture{}, err
+		}
+		fixture.Objects = append(fixture.Objects, object)
+	}
+	return fixture, nil
+}
+
+// toUnstructured converts the object, leaving out the empty creation
+// timestamp and status the sanitized objects would otherwise carry
+func toUnstructured(obj runtime.Object) (map[string]interface{}, error) {
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	unstructured.RemoveNestedField(object, "metadata", "creationTimestamp")
+	unstructured.RemoveNestedField(object, "status")
+	return object, nil
+}
+
+// sanitizer strips the sampled objects down to the fields the webhooks read,
+// and renames the namespaces and pods of customers consistently across them
+type sanitizer struct {
+	// namespaces maps customer namespaces to their sanitized names
+	namespaces map[string]string
+}
+
+func newSanitizer() *sanitizer {
+	return &sanitizer{namespaces: map[string]string{}}
+}
+
+// namespace returns the sanitized name of the namespace. Platform namespaces
+// keep their names, as the webhooks treat them specially.
+func (s *sanitizer) namespace(namespace string) string {
+	if namespace == "openshift" || namespace == metav1.NamespaceDefault || strings.HasPrefix(namespace, "openshift-") || strings.HasPrefix(namespace, "kube-") {
+		return namespace
+	}
+	if sanitized, ok := s.namespaces[namespace]; ok {
+		return sanitized
+	}
+	sanitized := fmt.Sprintf("customer-%d", len(s.namespaces)+1)
+	s.namespaces[namespace] = sanitized
+	return sanitized
+}
+
+// image returns the sanitized image reference. Images in the internal
+// registry are renamed with their namespace, and pulled through its default
+// route without the cluster's apps domain.
+func (s *sanitizer) image(image string) string {
+	ref, ok := parser.Parse(image)
+	if !ok {
+		return image
+	}
+	if strings.HasPrefix(ref.Registry, "default-route-openshift-image-registry.") {
+		ref.Registry = "default-route-openshift-image-registry." + sanitizedAppsDomain
+	}
+	ref.Namespace = s.namespace(ref.Namespace)
+	return ref.String()
+}
+
+// pod returns the pod with only the fields affecting how it's admitted: no
+// environment, commands or volumes, which may carry secrets. Pods in customer
+// namespaces are renamed app-<n>.
+func (s *sanitizer) pod(pod *corev1.Pod, n int) *corev1.Pod {
+	sanitized := &corev1.Pod{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pod.Name,
+			Namespace: s.namespace(pod.Namespace),
+		},
+		Spec: corev1.PodSpec{
+			InitContainers:   s.containers(pod.Spec.InitContainers),
+			Containers:       s.containers(pod.Spec.Containers),
+			NodeSelector:     pod.Spec.NodeSelector,
+			Tolerations:      pod.Spec.Tolerations,
+			ImagePullSecrets: pod.Spec.ImagePullSecrets,
+			SecurityContext:  pod.Spec.SecurityContext,
+		},
+	}
+	if sanitized.Namespace != pod.Namespace {
+		sanitized.Name = fmt.Sprintf("app-%d", n)
+	}
+	return sanitized
+}
+
+// containers returns the containers with only their names, images, resources
+// and security contexts
+func (s *sanitizer) containers(containers []corev1.Container) []corev1.Container {
+	if len(containers) == 0 {
+		return nil
+	}
+	sanitized := make([]corev1.Container, 0, len(containers))
+	for _, container := range containers {
+		sanitized = append(sanitized, corev1.Container{
+			Name:            container.Name,
+			Image:           s.image(container.Image),
+			ImagePullPolicy: container.ImagePullPolicy,
+			Resources:       container.Resources,
+			SecurityContext: container.SecurityContext,
+		})
+	}
+	return sanitized
+}
+
+// imageStreamTag returns the ImageStreamTag with only its tag and the
+// reference, digest, manifests and architecture of its image
+func (s *sanitizer) imageStreamTag(ist *imagestreamv1.ImageStreamTag) *imagestreamv1.ImageStreamTag {
+	sanitized := &imagestreamv1.ImageStreamTag{
+		TypeMeta: metav1.TypeMeta{APIVersion: "image.openshift.io/v1", Kind: "ImageStreamTag"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ist.Name,
+			Namespace: s.namespace(ist.Namespace),
+		},
+		Tag: ist.Tag,
+		Image: imagestreamv1.Image{
+			ObjectMeta:                   metav1.ObjectMeta{Name: ist.Image.Name},
+			DockerImageReference:         ist.Image.DockerImageReference,
+			DockerImageManifestMediaType: ist.Image.DockerImageManifestMediaType,
+			DockerImageManifests:         ist.Image.DockerImageManifests,
+			DockerImageMetadata:          architectureOnly(ist.Image.DockerImageMetadata),
+		},
+	}
+	if sanitized.Tag != nil && sanitized.Tag.From != nil && sanitized.Tag.From.Kind == "DockerImage" {
+		sanitized.Tag = sanitized.Tag.DeepCopy()
+		sanitized.Tag.From.Name = s.image(sanitized.Tag.From.Name)
+	}
+	return sanitized
+}
+
+// architectureOnly returns the image metadata with only its architecture,
+// dropping its environment, labels and history
+func architectureOnly(metadata runtime.RawExtension) runtime.RawExtension {
+	decoded := struct {
+		Architecture string `json:"architecture,omitempty"`
+	}{}
+	if len(metadata.Raw) == 0 || json.Unmarshal(metadata.Raw, &decoded) != nil || decoded.Architecture == "" {
+		return runtime.RawExtension{}
+	}
+	raw, err := json.Marshal(decoded)
+	if err != nil {
+		return runtime.RawExtension{}
+	}
+	return runtime.RawExtension{Raw: raw}
+}
+
+// sanitizeRegistryConfig returns the registry config with only its management
+// state, as its storage identifies the cluster's cloud account
+func sanitizeRegistryConfig(config *registryv1.Config) *registryv1.Config {
+	return &registryv1.Config{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "imageregistry.operator.openshift.io/v1", Kind: "Config"},
+		ObjectMeta: metav1.ObjectMeta{Name: config.Name},
+		Spec: registryv1.ImageRegistrySpec{
+			OperatorSpec: operatorv1.OperatorSpec{ManagementState: config.Spec.ManagementState},
+		},
+	}
+}
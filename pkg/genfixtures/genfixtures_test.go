@@ -0,0 +1,136 @@
+package genfixtures
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	imagestreamv1 "github.com/openshift/api/image/v1"
+	registryv1 "github.com/openshift/api/imageregistry/v1"
+	operatorv1 "github.com/openshift/api/operator/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/selftest"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/podimagespec"
+)
+
+func newPod(namespace, name, image string, owner types.UID) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:    "app",
+				Image:   image,
+				Command: []string{"/bin/app", "--token=s3cr3t"},
+				Env:     []corev1.EnvVar{{Name: "DB_PASSWORD", Value: "hunter2"}},
+			}},
+			NodeName: "ip-10-0-1-23.ec2.internal",
+		},
+	}
+	if owner != "" {
+		pod.OwnerReferences = []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "payments-7d4b9c", UID: owner, Controller: ptr.To(true)}}
+	}
+	return pod
+}
+
+func TestGenerate(t *testing.T) {
+	scheme, err := k8sutil.SharedScheme()
+	if err != nil {
+		t.Fatalf("Unexpected error building the scheme: %v", err)
+	}
+	registryConfig := &registryv1.Config{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+		Spec: registryv1.ImageRegistrySpec{
+			OperatorSpec: operatorv1.OperatorSpec{ManagementState: operatorv1.Removed},
+			Storage:      registryv1.ImageRegistryConfigStorage{S3: &registryv1.ImageRegistryConfigStorageS3{Bucket: "acme-prod-registry", Region: "us-east-1"}},
+		},
+	}
+	tools := &imagestreamv1.ImageStreamTag{
+		ObjectMeta: metav1.ObjectMeta{Name: "tools:latest", Namespace: "openshift"},
+		Tag: &imagestreamv1.TagReference{
+			Name: "latest",
+			From: &corev1.ObjectReference{Kind: "DockerImage", Name: "quay.io/openshift-release-dev/ocp-v4.0-art-dev@sha256:4dbe2a75a516a947eab036ef6a1d086f1b1610f6bd21c6ab5f95db68ec177ea2"},
+		},
+		Image: imagestreamv1.Image{
+			ObjectMeta:          metav1.ObjectMeta{Name: "sha256:4dbe2a75a516a947eab036ef6a1d086f1b1610f6bd21c6ab5f95db68ec177ea2"},
+			DockerImageMetadata: runtimeRaw(t, map[string]interface{}{"architecture": "amd64", "config": map[string]interface{}{"Env": []string{"SECRET=x"}}}),
+		},
+	}
+	internalImage := "default-route-openshift-image-registry.apps.acme-prod.abcd.p1.openshiftapps.com/openshift/tools:latest"
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		registryConfig,
+		tools,
+		newPod("acme-payments", "payments-7d4b9c-x2x8k", internalImage, "rs-1"),
+		newPod("acme-payments", "payments-7d4b9c-9fzq2", internalImage, "rs-1"),
+		newPod("acme-payments", "ledger", "quay.io/acme/ledger:v2", ""),
+	).Build()
+
+	hooks := webhooks.RegisteredWebhooks{
+		podimagespec.WebhookName: func() webhooks.Webhook { return podimagespec.NewWebhook() },
+	}
+	generated, err := Generate(context.Background(), c, hooks, scheme, Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error generating fixtures: %v", err)
+	}
+	// one pod per controller, and only those using the internal registry
+	if len(generated) != 1 {
+		t.Fatalf("Expected 1 fixture, got %d", len(generated))
+	}
+	g := generated[0]
+	if g.Webhook != podimagespec.WebhookName || g.Name != "customer-1-app-1" {
+		t.Errorf("Expected fixture %s/customer-1-app-1, got %s/%s", podimagespec.WebhookName, g.Webhook, g.Name)
+	}
+	if !g.Fixture.Allowed || g.Fixture.Patched == nil || !*g.Fixture.Patched {
+		t.Errorf("Expected the fixture to expect the image to be rewritten, got allowed %v patched %v", g.Fixture.Allowed, g.Fixture.Patched)
+	}
+
+	raw, err := json.Marshal(g.Fixture)
+	if err != nil {
+		t.Fatalf("Unexpected error marshalling the fixture: %v", err)
+	}
+	for _, leaked := range []string{"acme", "hunter2", "s3cr3t", "SECRET", "ec2.internal", "us-east-1"} {
+		if strings.Contains(string(raw), leaked) {
+			t.Errorf("Expected %q to be sanitized from the fixture, got %s", leaked, raw)
+		}
+	}
+	if !strings.Contains(string(raw), "default-route-openshift-image-registry.apps.example.com/openshift/tools:latest") {
+		t.Errorf("Expected the image to be pulled through the sanitized default route, got %s", raw)
+	}
+
+	// the written fixtures pass the selftest
+	dir := t.TempDir()
+	if err := Write(dir, generated); err != nil {
+		t.Fatalf("Unexpected error writing fixtures: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, podimagespec.WebhookName, "customer-1-app-1.yaml")); err != nil {
+		t.Fatalf("Expected the fixture to be written: %v", err)
+	}
+	fixtures, err := selftest.Load(os.DirFS(dir))
+	if err != nil {
+		t.Fatalf("Unexpected error loading the written fixtures: %v", err)
+	}
+	if report := selftest.Run(hooks, fixtures, scheme); !report.Passed() {
+		var out strings.Builder
+		report.Print(&out)
+		t.Errorf("Expected the written fixtures to pass the selftest:\n%s", out.String())
+	}
+}
+
+func runtimeRaw(t *testing.T, metadata map[string]interface{}) runtime.RawExtension {
+	t.Helper()
+	raw, err := json.Marshal(metadata)
+	if err != nil {
+		t.Fatalf("Unexpected error marshalling image metadata: %v", err)
+	}
+	return runtime.RawExtension{Raw: raw}
+}
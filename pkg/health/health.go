@@ -0,0 +1,233 @@
+// Package health serves the liveness and readiness endpoints of the webhook
+// server. The server is ready once the webhooks are warmed up, it can reach
+// the API server and the cluster serves every kind the webhooks read.
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/featuregates"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks"
+)
+
+const (
+	// HealthzPath answers whether the server is alive
+	HealthzPath string = "/healthz"
+	// ReadyzPath answers whether every webhook is ready. ReadyzPath/<webhook
+	// name> answers for a single webhook.
+	ReadyzPath string = "/readyz"
+
+	// checkTimeout bounds each probe's calls to the API server
+	checkTimeout = 2 * time.Second
+	// resultTTL is how long check results are reused, so frequent probes
+	// don't each call the API server
+	resultTTL = 5 * time.Second
+)
+
+var log = logf.Log.WithName("health")
+
+// Checker checks the readiness of the server and its webhooks
+type Checker struct {
+	hooks webhooks.RegisteredWebhooks
+	// client resolves the kinds webhooks read. When nil, they aren't checked.
+	client client.Client
+	// ping checks the API server can be reached. When nil, it isn't checked.
+	ping func(context.Context) error
+	// warming is whether the webhooks are still warming up
+	warming atomic.Bool
+
+	mu      sync.Mutex
+	checked time.Time
+	results map[string]error
+}
+
+// NewChecker creates a Checker for the hooks. c is the client shared by the
+// webhooks, which may be nil when running outside a cluster.
+func NewChecker(hooks webhooks.RegisteredWebhooks, c client.Client) *Checker {
+	checker := &Checker{
+		hooks:  hooks,
+		client: c,
+	}
+	if c == nil {
+		return checker
+	}
+	config, err := k8sutil.RestConfig()
+	if err != nil {
+		log.Error(err, "Failed to build API server config; connectivity will not be checked")
+		return checker
+	}
+	dc, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		log.Error(err, "Failed to build discovery client; connectivity will not be checked")
+		return checker
+	}
+	checker.ping = func(ctx context.Context) error {
+		return dc.RESTClient().Get().AbsPath("/readyz").Do(ctx).Error()
+	}
+	return checker
+}
+
+// WarmingUp makes the server unready until the returned function is called,
+// once the webhooks are warmed up
+func (c *Checker) WarmingUp() (done func()) {
+	c.warming.Store(true)
+	return func() {
+		c.warming.Store(false)
+		// Report ready straight away rather than once the results expire
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.results = nil
+	}
+}
+
+// Register adds the health endpoints to mux
+func (c *Checker) Register(mux *http.ServeMux) {
+	mux.HandleFunc(HealthzPath, c.Healthz)
+	mux.HandleFunc(ReadyzPath, c.Readyz)
+	mux.HandleFunc(ReadyzPath+"/", c.Readyz)
+}
+
+// Healthz answers ok for as long as the server is serving
+func (c *Checker) Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(w, "ok")
+}
+
+// Readyz answers ok when the API server can be reached and the webhooks'
+// dependencies are satisfied. With ?verbose every check is listed.
+func (c *Checker) Readyz(w http.ResponseWriter, r *http.Request) {
+	results := c.check(r.Context())
+
+	names := []string{}
+	if name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, ReadyzPath), "/"); name != "" {
+		if _, ok := c.hooks[name]; !ok {
+			http.NotFound(w, r)
+			return
+		}
+		names = append(names, warmUpCheck, apiServerCheck, webhookCheck(name))
+	} else {
+		for check := range results {
+			names = append(names, check)
+		}
+		sort.Strings(names)
+	}
+
+	ready := true
+	report := &strings.Builder{}
+	for _, name := range names {
+		if err := results[name]; err != nil {
+			ready = false
+			fmt.Fprintf(report, "[-]%s failed: %s\n", name, err)
+		} else {
+			fmt.Fprintf(report, "[+]%s ok\n", name)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if !ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, report.String())
+		return
+	}
+	if _, verbose := r.URL.Query()["verbose"]; verbose {
+		fmt.Fprint(w, report.String())
+	}
+	fmt.Fprint(w, "ok")
+}
+
+// Ready returns the first failed check, in the order Readyz lists them, or nil
+// once the server is ready
+func (c *Checker) Ready(ctx context.Context) error {
+	results := c.check(ctx)
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := results[name]; err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+const (
+	warmUpCheck    = "warm-up"
+	apiServerCheck = "kube-apiserver"
+)
+
+// errWarmingUp fails the readiness check while the webhooks warm up
+var errWarmingUp = errors.New("webhooks are warming up")
+
+func webhookCheck(name string) string { return "webhook/" + name }
+
+// check runs every check, reusing results younger than resultTTL
+func (c *Checker) check(ctx context.Context) map[string]error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.results != nil && time.Since(c.checked) < resultTTL {
+		return c.results
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	results := map[string]error{warmUpCheck: nil}
+	if c.warming.Load() {
+		results[warmUpCheck] = errWarmingUp
+	}
+	if c.ping != nil {
+		results[apiServerCheck] = c.ping(ctx)
+	} else {
+		results[apiServerCheck] = nil
+	}
+	for name, factory := range c.hooks {
+		results[webhookCheck(name)] = c.checkWebhook(name, factory())
+	}
+	for name, err := range results {
+		if err != nil && (c.results == nil || c.results[name] == nil) {
+			log.Info("Readiness check failed", "check", name, "error", err.Error())
+		}
+	}
+	c.results = results
+	c.checked = time.Now()
+	return results
+}
+
+// checkWebhook checks the cluster serves the kinds the webhook reads. Webhooks
+// disabled on this cluster are always ready.
+func (c *Checker) checkWebhook(name string, hook webhooks.Webhook) error {
+	if !featuregates.Enabled(name) || c.client == nil {
+		return nil
+	}
+	cw, ok := hook.(webhooks.CachingWebhook)
+	if !ok {
+		return nil
+	}
+	for _, obj := range cw.CachedObjects() {
+		gvk, err := apiutil.GVKForObject(obj, c.client.Scheme())
+		if err != nil {
+			return err
+		}
+		if _, err := c.client.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+			return fmt.Errorf("%s is not served: %w", gvk.String(), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	registryv1 "github.com/openshift/api/imageregistry/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/hiveownership"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/podimagespec"
+)
+
+var testHooks = webhooks.RegisteredWebhooks{
+	hiveownership.WebhookName: func() webhooks.Webhook { return hiveownership.NewWebhook() },
+	podimagespec.WebhookName:  func() webhooks.Webhook { return podimagespec.NewWebhook() },
+}
+
+func newScheme(t *testing.T) *runtime.Scheme {
+	s := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(s); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	if err := registryv1.AddToScheme(s); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	return s
+}
+
+func get(t *testing.T, handler http.HandlerFunc, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
+
+func TestHealthz(t *testing.T) {
+	checker := NewChecker(testHooks, nil)
+	if rec := get(t, checker.Healthz, HealthzPath); rec.Code != http.StatusOK || rec.Body.String() != "ok" {
+		t.Fatalf("Expected 200 ok, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	missingRegistry := meta.NewDefaultRESTMapper(nil)
+	servedRegistry := meta.NewDefaultRESTMapper(nil)
+	servedRegistry.Add(registryv1.SchemeGroupVersion.WithKind("Config"), meta.RESTScopeRoot)
+
+	tests := []struct {
+		name     string
+		client   func(*runtime.Scheme) client.Client
+		ping     error
+		path     string
+		expected int
+		body     string
+	}{
+		{
+			name:     "outside a cluster",
+			path:     ReadyzPath,
+			expected: http.StatusOK,
+			body:     "ok",
+		},
+		{
+			name: "dependencies served",
+			client: func(s *runtime.Scheme) client.Client {
+				return fake.NewClientBuilder().WithScheme(s).WithRESTMapper(servedRegistry).Build()
+			},
+			path:     ReadyzPath + "?verbose",
+			expected: http.StatusOK,
+			body:     "[+]webhook/" + podimagespec.WebhookName + " ok",
+		},
+		{
+			name: "image registry CRD missing",
+			client: func(s *runtime.Scheme) client.Client {
+				return fake.NewClientBuilder().WithScheme(s).WithRESTMapper(missingRegistry).Build()
+			},
+			path:     ReadyzPath,
+			expected: http.StatusServiceUnavailable,
+			body:     "[-]webhook/" + podimagespec.WebhookName + " failed",
+		},
+		{
+			name: "unaffected webhook with image registry CRD missing",
+			client: func(s *runtime.Scheme) client.Client {
+				return fake.NewClientBuilder().WithScheme(s).WithRESTMapper(missingRegistry).Build()
+			},
+			path:     ReadyzPath + "/" + hiveownership.WebhookName,
+			expected: http.StatusOK,
+			body:     "ok",
+		},
+		{
+			name:     "API server unreachable",
+			client:   func(s *runtime.Scheme) client.Client { return fake.NewClientBuilder().WithScheme(s).Build() },
+			ping:     errors.New("connection refused"),
+			path:     ReadyzPath + "/" + hiveownership.WebhookName,
+			expected: http.StatusServiceUnavailable,
+			body:     "[-]kube-apiserver failed: connection refused",
+		},
+		{
+			name:     "unknown webhook",
+			path:     ReadyzPath + "/unknown-validation",
+			expected: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			checker := &Checker{hooks: testHooks}
+			if test.client != nil {
+				checker.client = test.client(newScheme(t))
+				checker.ping = func(context.Context) error { return test.ping }
+			}
+			rec := get(t, checker.Readyz, test.path)
+			if rec.Code != test.expected {
+				t.Fatalf("Expected %d, got %d: %s", test.expected, rec.Code, rec.Body.String())
+			}
+			if !strings.Contains(rec.Body.String(), test.body) {
+				t.Errorf("Expected the body to contain %q, got %s", test.body, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestReadyzWarmingUp(t *testing.T) {
+	checker := NewChecker(testHooks, nil)
+	done := checker.WarmingUp()
+	rec := get(t, checker.Readyz, ReadyzPath+"/"+hiveownership.WebhookName)
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "[-]warm-up failed") {
+		t.Fatalf("Expected the server to be unready while warming up, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	if err := checker.Ready(context.Background()); !errors.Is(err, errWarmingUp) {
+		t.Errorf("Expected Ready to fail warming up, got %v", err)
+	}
+
+	// Warming up finishing is reported without waiting for the results to expire
+	done()
+	if rec := get(t, checker.Readyz, ReadyzPath); rec.Code != http.StatusOK {
+		t.Fatalf("Expected the server to be ready once warmed up, got %d %s", rec.Code, rec.Body.String())
+	}
+	if err := checker.Ready(context.Background()); err != nil {
+		t.Errorf("Expected Ready once warmed up, got %v", err)
+	}
+}
@@ -0,0 +1,223 @@
+// Package identity decides who webhooks treat as SRE, cluster admins and
+// privileged service accounts, so those identities are defined in one place
+// and can be changed on a cluster without changing every webhook.
+package identity
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/ghodss/yaml"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+)
+
+// hiveUsername is the user Hive applies the managed resources as
+const hiveUsername = "system:admin"
+
+var (
+	log = logf.Log.WithName("identity")
+
+	mu      sync.RWMutex
+	current = compile(Default())
+)
+
+// Identities matches users by username, group, service account namespace or
+// group pattern
+type Identities struct {
+	Usernames []string `json:"usernames,omitempty"`
+	Groups    []string `json:"groups,omitempty"`
+	// ServiceAccountNamespaces matches every service account in the namespaces
+	ServiceAccountNamespaces []string `json:"serviceAccountNamespaces,omitempty"`
+	// GroupPatterns are regular expressions matched against the user's groups
+	GroupPatterns []string `json:"groupPatterns,omitempty"`
+}
+
+// Policy is who the webhooks trust
+type Policy struct {
+	// SRE are Red Hat SRE, acting through backplane
+	SRE Identities `json:"sre"`
+	// ClusterAdmins are the cluster's built-in administrators
+	ClusterAdmins Identities `json:"clusterAdmins"`
+	// PrivilegedServiceAccounts are the service accounts of the platform and
+	// the operators managing the cluster
+	PrivilegedServiceAccounts Identities `json:"privilegedServiceAccounts"`
+}
+
+// Default is the policy used unless the ConfigMap overrides it
+func Default() Policy {
+	return Policy{
+		SRE: Identities{
+			Usernames: []string{"backplane-cluster-admin"},
+			Groups:    []string{"system:serviceaccounts:openshift-backplane-srep"},
+		},
+		ClusterAdmins: Identities{
+			Usernames: []string{"kube:admin", "system:admin"},
+		},
+		PrivilegedServiceAccounts: Identities{
+			GroupPatterns: []string{utils.PrivilegedServiceAccountGroups},
+		},
+	}
+}
+
+// Parse reads a policy in YAML or JSON. Each section in it replaces the
+// default section; sections missing from it keep their defaults.
+func Parse(raw []byte) (Policy, error) {
+	var sections struct {
+		SRE                       *Identities `json:"sre"`
+		ClusterAdmins             *Identities `json:"clusterAdmins"`
+		PrivilegedServiceAccounts *Identities `json:"privilegedServiceAccounts"`
+	}
+	if err := yaml.Unmarshal(raw, &sections); err != nil {
+		return Policy{}, err
+	}
+	policy := Default()
+	if sections.SRE != nil {
+		policy.SRE = *sections.SRE
+	}
+	if sections.ClusterAdmins != nil {
+		policy.ClusterAdmins = *sections.ClusterAdmins
+	}
+	if sections.PrivilegedServiceAccounts != nil {
+		policy.PrivilegedServiceAccounts = *sections.PrivilegedServiceAccounts
+	}
+	if _, err := compilePatterns(policy); err != nil {
+		return Policy{}, err
+	}
+	return policy, nil
+}
+
+// Set replaces the policy the webhooks use
+func Set(policy Policy) {
+	compiled := compile(policy)
+	mu.Lock()
+	defer mu.Unlock()
+	current = compiled
+}
+
+// Current returns the policy the webhooks use
+func Current() Policy {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current.Policy
+}
+
+// IsSRE checks whether the user is SRE
+func IsSRE(user authenticationv1.UserInfo) bool {
+	return get().sre.matches(user)
+}
+
+// IsClusterAdmin checks whether the user is a built-in cluster administrator
+func IsClusterAdmin(user authenticationv1.UserInfo) bool {
+	return get().clusterAdmins.matches(user)
+}
+
+// IsPrivilegedServiceAccount checks whether the user is a service account of
+// the platform or a managed operator
+func IsPrivilegedServiceAccount(user authenticationv1.UserInfo) bool {
+	return get().privilegedServiceAccounts.matches(user)
+}
+
+// IsManager checks whether the user manages the cluster for Red Hat: SRE, a
+// privileged service account, or Hive, which applies the managed resources as
+// system:admin. Webhooks protecting what even cluster admins may not change
+// allow only them.
+func IsManager(user authenticationv1.UserInfo) bool {
+	policy := get()
+	return policy.sre.matches(user) || policy.privilegedServiceAccounts.matches(user) || user.Username == hiveUsername
+}
+
+// IsAdmin checks whether the user is SRE, a cluster admin or a privileged
+// service account, which most webhooks allow to change managed resources
+func IsAdmin(user authenticationv1.UserInfo) bool {
+	policy := get()
+	return policy.sre.matches(user) || policy.clusterAdmins.matches(user) || policy.privilegedServiceAccounts.matches(user)
+}
+
+func get() *compiledPolicy {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// compiledPolicy is a Policy with its patterns compiled
+type compiledPolicy struct {
+	Policy
+	sre                       compiledIdentities
+	clusterAdmins             compiledIdentities
+	privilegedServiceAccounts compiledIdentities
+}
+
+type compiledIdentities struct {
+	Identities
+	patterns []*regexp.Regexp
+}
+
+// compile compiles the policy's patterns. Invalid patterns are logged and
+// match nothing; Parse rejects them before they get here.
+func compile(policy Policy) *compiledPolicy {
+	patterns, err := compilePatterns(policy)
+	if err != nil {
+		log.Error(err, "Ignoring invalid identity group pattern")
+	}
+	return &compiledPolicy{
+		Policy:                    policy,
+		sre:                       compiledIdentities{Identities: policy.SRE, patterns: patterns[0]},
+		clusterAdmins:             compiledIdentities{Identities: policy.ClusterAdmins, patterns: patterns[1]},
+		privilegedServiceAccounts: compiledIdentities{Identities: policy.PrivilegedServiceAccounts, patterns: patterns[2]},
+	}
+}
+
+// compilePatterns compiles the group patterns of each section of the policy,
+// in the order SRE, ClusterAdmins, PrivilegedServiceAccounts
+func compilePatterns(policy Policy) ([3][]*regexp.Regexp, error) {
+	var compiled [3][]*regexp.Regexp
+	var firstErr error
+	for i, identities := range []Identities{policy.SRE, policy.ClusterAdmins, policy.PrivilegedServiceAccounts} {
+		for _, pattern := range identities.GroupPatterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			compiled[i] = append(compiled[i], re)
+		}
+	}
+	return compiled, firstErr
+}
+
+func (i compiledIdentities) matches(user authenticationv1.UserInfo) bool {
+	if slices.Contains(i.Usernames, user.Username) {
+		return true
+	}
+	if namespace, ok := serviceAccountNamespace(user.Username); ok && slices.Contains(i.ServiceAccountNamespaces, namespace) {
+		return true
+	}
+	for _, group := range user.Groups {
+		if slices.Contains(i.Groups, group) {
+			return true
+		}
+		for _, re := range i.patterns {
+			if re.MatchString(group) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// serviceAccountNamespace returns the namespace of a service account's
+// username, system:serviceaccount:<namespace>:<name>
+func serviceAccountNamespace(username string) (string, bool) {
+	parts := strings.Split(username, ":")
+	if len(parts) != 4 || parts[0] != "system" || parts[1] != "serviceaccount" {
+		return "", false
+	}
+	return parts[2], true
+}
@@ -0,0 +1,171 @@
+package identity
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const testNamespace = "openshift-validation-webhook"
+
+func TestDefault(t *testing.T) {
+	tests := []struct {
+		name                     string
+		user                     authenticationv1.UserInfo
+		sre                      bool
+		clusterAdmin             bool
+		privilegedServiceAccount bool
+	}{
+		{
+			name: "backplane-cluster-admin",
+			user: authenticationv1.UserInfo{Username: "backplane-cluster-admin"},
+			sre:  true,
+		},
+		{
+			name: "srep service account",
+			user: authenticationv1.UserInfo{
+				Username: "system:serviceaccount:openshift-backplane-srep:sre",
+				Groups:   []string{"system:serviceaccounts", "system:serviceaccounts:openshift-backplane-srep"},
+			},
+			sre: true,
+			// openshift-* service accounts are privileged too
+			privilegedServiceAccount: true,
+		},
+		{
+			name:         "kube:admin",
+			user:         authenticationv1.UserInfo{Username: "kube:admin"},
+			clusterAdmin: true,
+		},
+		{
+			name:         "system:admin",
+			user:         authenticationv1.UserInfo{Username: "system:admin"},
+			clusterAdmin: true,
+		},
+		{
+			name: "managed operator",
+			user: authenticationv1.UserInfo{
+				Username: "system:serviceaccount:openshift-monitoring:cluster-monitoring-operator",
+				Groups:   []string{"system:serviceaccounts", "system:serviceaccounts:openshift-monitoring"},
+			},
+			privilegedServiceAccount: true,
+		},
+		{
+			name: "customer service account",
+			user: authenticationv1.UserInfo{
+				Username: "system:serviceaccount:customer:pruner",
+				Groups:   []string{"system:serviceaccounts", "system:serviceaccounts:customer"},
+			},
+		},
+		{
+			name: "dedicated admin",
+			user: authenticationv1.UserInfo{Username: "customer", Groups: []string{"dedicated-admins", "system:authenticated"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := IsSRE(test.user); actual != test.sre {
+				t.Errorf("Expected IsSRE %v, got %v", test.sre, actual)
+			}
+			if actual := IsClusterAdmin(test.user); actual != test.clusterAdmin {
+				t.Errorf("Expected IsClusterAdmin %v, got %v", test.clusterAdmin, actual)
+			}
+			if actual := IsPrivilegedServiceAccount(test.user); actual != test.privilegedServiceAccount {
+				t.Errorf("Expected IsPrivilegedServiceAccount %v, got %v", test.privilegedServiceAccount, actual)
+			}
+			admin := test.sre || test.clusterAdmin || test.privilegedServiceAccount
+			if actual := IsAdmin(test.user); actual != admin {
+				t.Errorf("Expected IsAdmin %v, got %v", admin, actual)
+			}
+			manager := test.sre || test.privilegedServiceAccount || test.user.Username == "system:admin"
+			if actual := IsManager(test.user); actual != manager {
+				t.Errorf("Expected IsManager %v, got %v", manager, actual)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	policy, err := Parse([]byte(`
+sre:
+  groups: [sre-team]
+  serviceAccountNamespaces: [openshift-backplane-srep]
+privilegedServiceAccounts:
+  groupPatterns: ["^system:serviceaccounts:(openshift|openshift-.*)$"]
+`))
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	expected := Default()
+	expected.SRE = Identities{Groups: []string{"sre-team"}, ServiceAccountNamespaces: []string{"openshift-backplane-srep"}}
+	expected.PrivilegedServiceAccounts = Identities{GroupPatterns: []string{"^system:serviceaccounts:(openshift|openshift-.*)$"}}
+	if !reflect.DeepEqual(policy, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, policy)
+	}
+
+	if _, err := Parse([]byte(`clusterAdmins: {groupPatterns: ["("]}`)); err == nil {
+		t.Errorf("Expected an invalid group pattern to be rejected")
+	}
+	if _, err := Parse([]byte(`sre: [`)); err == nil {
+		t.Errorf("Expected invalid YAML to be rejected")
+	}
+}
+
+func TestSet(t *testing.T) {
+	t.Cleanup(func() { Set(Default()) })
+
+	Set(Policy{SRE: Identities{ServiceAccountNamespaces: []string{"sre-tools"}}})
+	if !IsSRE(authenticationv1.UserInfo{Username: "system:serviceaccount:sre-tools:runner"}) {
+		t.Errorf("Expected service accounts in sre-tools to be SRE")
+	}
+	if IsSRE(authenticationv1.UserInfo{Username: "backplane-cluster-admin"}) {
+		t.Errorf("Expected backplane-cluster-admin not to be SRE")
+	}
+	if IsAdmin(authenticationv1.UserInfo{Username: "kube:admin"}) {
+		t.Errorf("Expected kube:admin not to be an admin")
+	}
+}
+
+func TestWatcherSync(t *testing.T) {
+	t.Cleanup(func() { Set(Default()) })
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: ConfigMapName, Namespace: testNamespace},
+		Data:       map[string]string{ConfigMapKey: "sre: {usernames: [sre-break-glass]}"},
+	}
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(cm).Build()
+	w := NewWatcher(c, testNamespace)
+	ctx := context.Background()
+
+	w.Sync(ctx)
+	if !IsSRE(authenticationv1.UserInfo{Username: "sre-break-glass"}) {
+		t.Fatalf("Expected the ConfigMap's SRE to be SRE")
+	}
+	if !IsClusterAdmin(authenticationv1.UserInfo{Username: "kube:admin"}) {
+		t.Fatalf("Expected the default cluster admins to be kept")
+	}
+
+	// An invalid policy leaves the current one in place
+	cm.Data[ConfigMapKey] = `sre: {groupPatterns: ["("]}`
+	if err := c.Update(ctx, cm); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	w.Sync(ctx)
+	if !IsSRE(authenticationv1.UserInfo{Username: "sre-break-glass"}) {
+		t.Fatalf("Expected the previous policy to be kept")
+	}
+
+	if err := c.Delete(ctx, cm); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	w.Sync(ctx)
+	if !reflect.DeepEqual(Current(), Default()) {
+		t.Fatalf("Expected the default policy without the ConfigMap, got %+v", Current())
+	}
+}
@@ -0,0 +1,111 @@
+package identity
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/util/wait"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+)
+
+const (
+	// ConfigMapName is the ConfigMap in the webhook namespace which overrides
+	// the default identity policy
+	ConfigMapName string = "webhook-identity-policy"
+	// ConfigMapKey is the key of the ConfigMap holding the policy
+	ConfigMapKey string = "policy.yaml"
+
+	// resyncPeriod is how often the ConfigMap is read again, in case an event
+	// was missed
+	resyncPeriod = 5 * time.Minute
+)
+
+// Watcher keeps the policy in sync with the ConfigMap
+type Watcher struct {
+	reader    client.Reader
+	client    client.Client
+	namespace string
+}
+
+// NewWatcher creates a Watcher for the ConfigMap in namespace
+func NewWatcher(c client.Client, namespace string) *Watcher {
+	return &Watcher{
+		reader:    c,
+		client:    c,
+		namespace: namespace,
+	}
+}
+
+// Start syncs the policy, then keeps it in sync with the ConfigMap until ctx
+// is cancelled
+func (w *Watcher) Start(ctx context.Context) error {
+	config, err := k8sutil.RestConfig()
+	if err != nil {
+		return err
+	}
+	informers, err := cache.New(config, cache.Options{
+		Scheme:            w.client.Scheme(),
+		DefaultNamespaces: map[string]cache.Config{w.namespace: {}},
+		ByObject: map[client.Object]cache.ByObject{
+			&corev1.ConfigMap{}: {Field: fields.OneTermEqualSelector("metadata.name", ConfigMapName)},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	w.reader = informers
+
+	informer, err := informers.GetInformer(ctx, &corev1.ConfigMap{})
+	if err != nil {
+		return err
+	}
+	if _, err := informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { w.Sync(ctx) },
+		UpdateFunc: func(interface{}, interface{}) { w.Sync(ctx) },
+		DeleteFunc: func(interface{}) { w.Sync(ctx) },
+	}); err != nil {
+		return err
+	}
+
+	go func() {
+		if err := informers.Start(ctx); err != nil {
+			log.Error(err, "Identity policy cache stopped")
+		}
+	}()
+	if !informers.WaitForCacheSync(ctx) {
+		return fmt.Errorf("failed to sync identity policy cache")
+	}
+	go wait.UntilWithContext(ctx, w.Sync, resyncPeriod)
+	return nil
+}
+
+// Sync reads the ConfigMap and updates the policy. A missing ConfigMap
+// restores the default policy; an invalid one leaves the policy unchanged.
+func (w *Watcher) Sync(ctx context.Context) {
+	cm := &corev1.ConfigMap{}
+	err := w.reader.Get(ctx, client.ObjectKey{Namespace: w.namespace, Name: ConfigMapName}, cm)
+	if err != nil && !apierrors.IsNotFound(err) {
+		log.Error(err, "Failed to read identity policy")
+		return
+	}
+
+	policy, err := Parse([]byte(cm.Data[ConfigMapKey]))
+	if err != nil {
+		log.Error(err, "Ignoring invalid identity policy", "configMap", ConfigMapName)
+		return
+	}
+	if reflect.DeepEqual(policy, Current()) {
+		return
+	}
+	Set(policy)
+	log.Info("Identity policy changed", "policy", policy)
+}
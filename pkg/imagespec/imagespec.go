@@ -0,0 +1,113 @@
+// Package imagespec parses references to images in the OpenShift internal
+// image registry, which may be pulled through any of the registry's hostnames,
+// and any other forms of image references matched by the patterns of a
+// Registry.
+package imagespec
+
+import (
+	"strings"
+)
+
+const (
+	// ServiceHostname is the hostname of the internal image registry's Service
+	ServiceHostname string = "image-registry.openshift-image-registry.svc:5000"
+	// ClusterLocalHostname is the fully qualified hostname of the internal
+	// image registry's Service
+	ClusterLocalHostname string = "image-registry.openshift-image-registry.svc.cluster.local:5000"
+	// DefaultRouteHostname matches the hostname of the internal image
+	// registry's default route, which is followed by the cluster's apps domain
+	DefaultRouteHostname string = "default-route-openshift-image-registry.*"
+)
+
+// DefaultHostnames are the hostnames of the internal image registry matched
+// by a Parser created without any
+var DefaultHostnames = []string{ServiceHostname, ClusterLocalHostname, DefaultRouteHostname}
+
+// Reference is an image in the internal image registry, tagged in an
+// ImageStream
+type Reference struct {
+	// Registry is the hostname the image is pulled through
+	Registry string
+	// Namespace is the namespace of the ImageStream
+	Namespace string
+	// Name is the name of the ImageStream
+	Name string
+	Tag  string
+}
+
+// ImageStreamTag returns the name of the ImageStreamTag the image refers to
+func (r Reference) ImageStreamTag() string {
+	return r.Name + ":" + r.Tag
+}
+
+// String returns the image reference
+func (r Reference) String() string {
+	return r.Registry + "/" + r.Namespace + "/" + r.ImageStreamTag()
+}
+
+// Parser parses image references pulled through a list of internal image
+// registry hostnames. A hostname ending in "*" matches any hostname it
+// prefixes.
+type Parser struct {
+	hostnames []string
+}
+
+// NewParser creates a Parser for images pulled through hostnames, or through
+// the DefaultHostnames when there are none
+func NewParser(hostnames ...string) *Parser {
+	if len(hostnames) == 0 {
+		hostnames = DefaultHostnames
+	}
+	return &Parser{hostnames: hostnames}
+}
+
+// Parse parses a tagged image reference in the internal image registry. Images
+// from other registries, and those referenced by digest, don't match.
+func (p *Parser) Parse(image string) (Reference, bool) {
+	registry, path, ok := strings.Cut(image, "/")
+	if !ok || !p.matchesHostname(registry) {
+		return Reference{}, false
+	}
+
+	namespace, nameTag, ok := cutLast(path, "/")
+	if !ok || namespace == "" || strings.ContainsAny(namespace, " \t\n") {
+		return Reference{}, false
+	}
+	name, tag, ok := strings.Cut(nameTag, ":")
+	if !ok || name == "" || !isWord(name) || strings.ContainsAny(tag, " \t\n") {
+		return Reference{}, false
+	}
+	return Reference{Registry: registry, Namespace: namespace, Name: name, Tag: tag}, true
+}
+
+// matchesHostname returns true if registry is one of the parser's hostnames
+func (p *Parser) matchesHostname(registry string) bool {
+	for _, hostname := range p.hostnames {
+		if prefix, wildcard := strings.CutSuffix(hostname, "*"); wildcard {
+			if len(registry) > len(prefix) && strings.HasPrefix(registry, prefix) {
+				return true
+			}
+		} else if registry == hostname {
+			return true
+		}
+	}
+	return false
+}
+
+// cutLast slices s around the last instance of sep
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// isWord returns true if s only holds letters, digits and underscores
+func isWord(s string) bool {
+	for _, c := range s {
+		if !(c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
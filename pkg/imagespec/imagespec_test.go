@@ -0,0 +1,122 @@
+package imagespec
+
+import (
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		hostnames []string
+		image     string
+		expected  Reference
+		matched   bool
+	}{
+		{
+			name:  "short image",
+			image: "ubuntu",
+		},
+		{
+			name:  "short tagged image",
+			image: "ubuntu:latest",
+		},
+		{
+			name:  "fully qualified tagged image",
+			image: "docker.io/library/ubuntu:latest",
+		},
+		{
+			name:  "fully qualified image by digest",
+			image: "quay.io/openshift-release-dev/ocp-release@sha256:4dbe2a75a516a947eab036ef6a1d086f1b1610f6bd21c6ab5f95db68ec177ea2",
+		},
+		{
+			name:  "internal image by digest",
+			image: "image-registry.openshift-image-registry.svc:5000/openshift/cli@sha256:4dbe2a75a516a947eab036ef6a1d086f1b1610f6bd21c6ab5f95db68ec177ea2",
+		},
+		{
+			name:  "internal image without a tag",
+			image: "image-registry.openshift-image-registry.svc:5000/openshift/cli",
+		},
+		{
+			name:  "internal image without a namespace",
+			image: "image-registry.openshift-image-registry.svc:5000/cli:latest",
+		},
+		{
+			name:     "internal tagged image",
+			image:    "image-registry.openshift-image-registry.svc:5000/openshift/cli:latest",
+			expected: Reference{Registry: ServiceHostname, Namespace: "openshift", Name: "cli", Tag: "latest"},
+			matched:  true,
+		},
+		{
+			name:     "fully qualified service hostname",
+			image:    "image-registry.openshift-image-registry.svc.cluster.local:5000/openshift/tools:v4.16",
+			expected: Reference{Registry: ClusterLocalHostname, Namespace: "openshift", Name: "tools", Tag: "v4.16"},
+			matched:  true,
+		},
+		{
+			name:     "default route",
+			image:    "default-route-openshift-image-registry.apps.example.com/openshift/cli:latest",
+			expected: Reference{Registry: "default-route-openshift-image-registry.apps.example.com", Namespace: "openshift", Name: "cli", Tag: "latest"},
+			matched:  true,
+		},
+		{
+			name:  "default route prefix without a domain",
+			image: "default-route-openshift-image-registry./openshift/cli:latest",
+		},
+		{
+			name:  "lookalike hostname",
+			image: "image-registry.openshift-image-registry.svc.example.com:5000/openshift/cli:latest",
+		},
+		{
+			name:      "configured hostname",
+			hostnames: []string{"registry.example.com"},
+			image:     "registry.example.com/openshift/cli:latest",
+			expected:  Reference{Registry: "registry.example.com", Namespace: "openshift", Name: "cli", Tag: "latest"},
+			matched:   true,
+		},
+		{
+			name:      "default hostname not configured",
+			hostnames: []string{"registry.example.com"},
+			image:     "image-registry.openshift-image-registry.svc:5000/openshift/cli:latest",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, matched := NewParser(test.hostnames...).Parse(test.image)
+			if matched != test.matched || actual != test.expected {
+				t.Errorf("Expected %+v (matched %t), got %+v (matched %t)", test.expected, test.matched, actual, matched)
+			}
+			if matched && actual.String() != test.image {
+				t.Errorf("Expected the reference to print as %s, got %s", test.image, actual.String())
+			}
+		})
+	}
+}
+
+// FuzzParse checks images which parse are exactly the reference they parse to
+func FuzzParse(f *testing.F) {
+	for _, image := range []string{
+		"image-registry.openshift-image-registry.svc:5000/openshift/cli:latest",
+		"default-route-openshift-image-registry.apps.example.com/ns/name:tag",
+		"image-registry.openshift-image-registry.svc:5000/openshift/cli@sha256:abc",
+		"image-registry.openshift-image-registry.svc:5000//:",
+		"quay.io/openshift/origin-cli:4.16",
+		"",
+	} {
+		f.Add(image)
+	}
+
+	p := NewParser()
+	f.Fuzz(func(t *testing.T, image string) {
+		ref, ok := p.Parse(image)
+		if !ok {
+			return
+		}
+		if ref.Namespace == "" || ref.Name == "" {
+			t.Fatalf("Expected %q to parse to a namespace and a name, got %+v", image, ref)
+		}
+		if ref.String() != image {
+			t.Fatalf("Expected %q to parse to itself, got %s", image, ref.String())
+		}
+	})
+}
@@ -0,0 +1,122 @@
+package imagespec
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// Groups a Pattern without a Rewrite template must name, which make the
+// Reference of the images it matches
+const (
+	NamespaceGroup string = "namespace"
+	NameGroup      string = "name"
+	TagGroup       string = "tag"
+)
+
+// Pattern matches additional forms of image references with a regular
+// expression, such as new hostnames of the internal image registry or other
+// source registries. A Pattern with a Rewrite template rewrites the images it
+// matches straight to the expanded template, eg
+// registry.redhat.io/openshift4/ose-${name}:${tag}. Otherwise its namespace,
+// name and tag groups name the ImageStreamTag the image is resolved through.
+type Pattern struct {
+	Name    string `json:"name"`
+	Source  string `json:"source"`
+	Rewrite string `json:"rewrite,omitempty"`
+
+	source *regexp.Regexp
+}
+
+// compile compiles the pattern's source, checking it names the groups its
+// images need
+func (p *Pattern) compile() error {
+	source, err := regexp.Compile(p.Source)
+	if err != nil {
+		return fmt.Errorf("pattern %q: %w", p.Name, err)
+	}
+	if p.Rewrite == "" {
+		for _, group := range []string{NamespaceGroup, NameGroup, TagGroup} {
+			if !slices.Contains(source.SubexpNames(), group) {
+				return fmt.Errorf("pattern %q has no rewrite template, so its source must name the %s group", p.Name, group)
+			}
+		}
+	}
+	p.source = source
+	return nil
+}
+
+// ParsePatterns reads a YAML or JSON list of patterns, compiling them. An
+// invalid pattern fails the whole list, so a typo doesn't silently change
+// which images are rewritten.
+func ParsePatterns(raw []byte) ([]Pattern, error) {
+	patterns := []Pattern{}
+	if err := yaml.Unmarshal(raw, &patterns); err != nil {
+		return nil, err
+	}
+	for i := range patterns {
+		if err := patterns[i].compile(); err != nil {
+			return nil, err
+		}
+	}
+	return patterns, nil
+}
+
+// Match is an image matched by a Registry
+type Match struct {
+	Reference
+	// Rewrite is the image the matching pattern rewrites the image to, or ""
+	// when it's resolved through its ImageStreamTag
+	Rewrite string
+	// Pattern is the name of the matching pattern, or "" when the image was
+	// parsed by the Registry's Parser
+	Pattern string
+}
+
+// Registry matches images with a Parser, and then with additional patterns
+// in order
+type Registry struct {
+	parser   *Parser
+	patterns []Pattern
+}
+
+// NewRegistry creates a Registry. The patterns must come from ParsePatterns.
+func NewRegistry(parser *Parser, patterns ...Pattern) *Registry {
+	return &Registry{parser: parser, patterns: patterns}
+}
+
+// Match matches the image with the Registry's Parser, or the first of its
+// patterns matching it
+func (r *Registry) Match(image string) (Match, bool) {
+	if ref, ok := r.parser.Parse(image); ok {
+		return Match{Reference: ref}, true
+	}
+	for _, p := range r.patterns {
+		if p.source == nil {
+			continue
+		}
+		groups := p.source.FindStringSubmatchIndex(image)
+		if groups == nil {
+			continue
+		}
+		if p.Rewrite != "" {
+			rewrite := string(p.source.ExpandString(nil, p.Rewrite, image, groups))
+			return Match{Rewrite: rewrite, Pattern: p.Name}, true
+		}
+		registry, _, _ := strings.Cut(image, "/")
+		ref := Reference{
+			Registry:  registry,
+			Namespace: string(p.source.ExpandString(nil, "${"+NamespaceGroup+"}", image, groups)),
+			Name:      string(p.source.ExpandString(nil, "${"+NameGroup+"}", image, groups)),
+			Tag:       string(p.source.ExpandString(nil, "${"+TagGroup+"}", image, groups)),
+		}
+		if ref.Namespace == "" || ref.Name == "" || ref.Tag == "" {
+			continue
+		}
+		return Match{Reference: ref, Pattern: p.Name}, true
+	}
+	return Match{}, false
+}
@@ -0,0 +1,134 @@
+package imagespec
+
+import (
+	"strings"
+	"testing"
+)
+
+const testPatterns = `
+- name: ci-registry
+  source: '^registry\.ci\.example\.com/(?P<namespace>[a-z0-9-]+)/(?P<name>\w+):(?P<tag>[\w.-]+)$'
+- name: origin-tools
+  source: '^quay\.io/openshift/origin-(?P<name>cli|must-gather|tools):(?P<tag>[\w.-]+)$'
+  rewrite: 'registry.redhat.io/openshift4/ose-${name}:${tag}'
+`
+
+func TestParsePatterns(t *testing.T) {
+	tests := []struct {
+		name      string
+		raw       string
+		expectErr bool
+	}{
+		{
+			name: "patterns",
+			raw:  testPatterns,
+		},
+		{
+			name: "no patterns",
+			raw:  "",
+		},
+		{
+			name:      "invalid regular expression",
+			raw:       `[{"name": "broken", "source": "^quay\\.io/(", "rewrite": "x"}]`,
+			expectErr: true,
+		},
+		{
+			name:      "ImageStreamTag pattern missing a group",
+			raw:       `[{"name": "no-tag", "source": "^quay\\.io/(?P<namespace>\\w+)/(?P<name>\\w+)$"}]`,
+			expectErr: true,
+		},
+		{
+			name:      "not a list",
+			raw:       `name: origin-tools`,
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParsePatterns([]byte(test.raw))
+			if (err != nil) != test.expectErr {
+				t.Fatalf("Expected error %v, got %v", test.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestRegistryMatch(t *testing.T) {
+	patterns, err := ParsePatterns([]byte(testPatterns))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing the patterns: %v", err)
+	}
+	r := NewRegistry(NewParser(), patterns...)
+
+	tests := []struct {
+		name     string
+		image    string
+		expected Match
+		matched  bool
+	}{
+		{
+			name:     "internal registry image",
+			image:    "image-registry.openshift-image-registry.svc:5000/openshift/cli:latest",
+			expected: Match{Reference: Reference{Registry: ServiceHostname, Namespace: "openshift", Name: "cli", Tag: "latest"}},
+			matched:  true,
+		},
+		{
+			name:     "ImageStreamTag pattern",
+			image:    "registry.ci.example.com/openshift/tools:v4.16",
+			expected: Match{Reference: Reference{Registry: "registry.ci.example.com", Namespace: "openshift", Name: "tools", Tag: "v4.16"}, Pattern: "ci-registry"},
+			matched:  true,
+		},
+		{
+			name:     "rewrite pattern",
+			image:    "quay.io/openshift/origin-must-gather:4.16",
+			expected: Match{Rewrite: "registry.redhat.io/openshift4/ose-must-gather:4.16", Pattern: "origin-tools"},
+			matched:  true,
+		},
+		{
+			name:  "no pattern matches",
+			image: "quay.io/openshift/origin-console:4.16",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, matched := r.Match(test.image)
+			if matched != test.matched {
+				t.Fatalf("Expected matched %v, got %v", test.matched, matched)
+			}
+			if actual != test.expected {
+				t.Errorf("Expected %+v, got %+v", test.expected, actual)
+			}
+		})
+	}
+}
+
+// FuzzRegistryMatch feeds adversarial images to a Registry with patterns,
+// which must match them in linear time without panicking
+func FuzzRegistryMatch(f *testing.F) {
+	for _, image := range []string{
+		"registry.ci.example.com/openshift/tools:v4.16",
+		"quay.io/openshift/origin-must-gather:4.16",
+		"registry.ci.example.com/" + strings.Repeat("a-", 4096) + "/tools:",
+		"quay.io/openshift/origin-" + strings.Repeat("cli", 4096),
+		"registry.ci.example.com/openshift/tools:v4.16\n",
+	} {
+		f.Add(image)
+	}
+
+	patterns, err := ParsePatterns([]byte(testPatterns))
+	if err != nil {
+		f.Fatalf("Unexpected error parsing the patterns: %v", err)
+	}
+	r := NewRegistry(NewParser(), patterns...)
+	f.Fuzz(func(t *testing.T, image string) {
+		match, ok := r.Match(image)
+		if !ok {
+			return
+		}
+		if match.Rewrite == "" && (match.Namespace == "" || match.Name == "" || match.Tag == "") {
+			t.Fatalf("Expected %q to match an ImageStreamTag or a rewrite, got %+v", image, match)
+		}
+	})
+}
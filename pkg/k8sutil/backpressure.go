@@ -0,0 +1,95 @@
+package k8sutil
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// defaultBackpressureWindow is how long the API server is considered to
+	// be throttling after a 429 without a usable Retry-After
+	defaultBackpressureWindow = 5 * time.Second
+	// maxBackpressureWindow bounds the Retry-After honoured, so one response
+	// can't switch webhooks off for long
+	maxBackpressureWindow = 30 * time.Second
+
+	// priorityLevelHeader is set by API Priority and Fairness on the responses
+	// it throttles
+	priorityLevelHeader = "X-Kubernetes-Pf-Prioritylevel-Uid"
+)
+
+// backpressureUntil is when the API server last asked us to back off until,
+// in Unix nanoseconds
+var backpressureUntil atomic.Int64
+
+// UnderBackpressure returns true while the API server is throttling the reads
+// of the shared client webhooks read through: until the Retry-After of the
+// latest 429 response to a GET or LIST has passed. client-go retries throttled
+// requests after waiting, so webhooks reading the API server would otherwise
+// wait until their admission timeout.
+func UnderBackpressure() bool {
+	return time.Now().UnixNano() < backpressureUntil.Load()
+}
+
+// recordBackpressure notes the API server is throttling for the window
+func recordBackpressure(window time.Duration, priorityLevel string) {
+	until := time.Now().Add(window).UnixNano()
+	for {
+		current := backpressureUntil.Load()
+		if until <= current {
+			return
+		}
+		if backpressureUntil.CompareAndSwap(current, until) {
+			if current < time.Now().UnixNano() {
+				log.Info("API server is throttling requests, shedding webhooks which fail open", "window", window.String(), "priorityLevel", priorityLevel)
+			}
+			return
+		}
+	}
+}
+
+// backpressureWindow returns how long to back off for from the Retry-After of
+// a throttled response
+func backpressureWindow(header http.Header) time.Duration {
+	seconds, err := strconv.Atoi(header.Get("Retry-After"))
+	if err != nil || seconds < 1 {
+		return defaultBackpressureWindow
+	}
+	if window := time.Duration(seconds) * time.Second; window < maxBackpressureWindow {
+		return window
+	}
+	return maxBackpressureWindow
+}
+
+// backpressureTransport records the 429 responses of the API server to reads,
+// including those of API Priority and Fairness. Throttled writes and watches
+// don't hold up admission requests, so they aren't recorded.
+type backpressureTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *backpressureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusTooManyRequests && isRead(req) {
+		recordBackpressure(backpressureWindow(resp.Header), resp.Header.Get(priorityLevelHeader))
+	}
+	return resp, err
+}
+
+// isRead returns true if the request is a GET or LIST, rather than a write or
+// a watch
+func isRead(req *http.Request) bool {
+	if req.Method != http.MethodGet {
+		return false
+	}
+	watch, _ := strconv.ParseBool(req.URL.Query().Get("watch"))
+	return !watch
+}
+
+// wrapBackpressure wraps the transport of a rest.Config to record throttling
+func wrapBackpressure(rt http.RoundTripper) http.RoundTripper {
+	return &backpressureTransport{next: rt}
+}
@@ -0,0 +1,104 @@
+package k8sutil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBackpressureTransport(t *testing.T) {
+	t.Cleanup(func() { backpressureUntil.Store(0) })
+
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "2")
+			w.Header().Set(priorityLevelHeader, "workload-low")
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	c := &http.Client{Transport: wrapBackpressure(http.DefaultTransport)}
+
+	get := func() {
+		t.Helper()
+		resp, err := c.Get(server.URL)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	get()
+	if UnderBackpressure() {
+		t.Fatalf("Expected no backpressure after a successful response")
+	}
+
+	status = http.StatusTooManyRequests
+	get()
+	if !UnderBackpressure() {
+		t.Fatalf("Expected backpressure after a 429 response")
+	}
+	if until := time.Unix(0, backpressureUntil.Load()); time.Until(until) > 2*time.Second {
+		t.Errorf("Expected backpressure for the Retry-After of 2s, got until %s", until)
+	}
+
+	// backpressure ends once the Retry-After has passed
+	backpressureUntil.Store(time.Now().Add(-time.Second).UnixNano())
+	if UnderBackpressure() {
+		t.Errorf("Expected backpressure to end")
+	}
+}
+
+func TestBackpressureTransportReadsOnly(t *testing.T) {
+	t.Cleanup(func() { backpressureUntil.Store(0) })
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "2")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+	c := &http.Client{Transport: wrapBackpressure(http.DefaultTransport)}
+
+	do := func(method, url string) {
+		t.Helper()
+		req, err := http.NewRequest(method, url, nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	do(http.MethodPost, server.URL+"/api/v1/namespaces/default/events")
+	do(http.MethodGet, server.URL+"/api/v1/pods?watch=true")
+	if UnderBackpressure() {
+		t.Fatalf("Expected no backpressure after throttled writes and watches")
+	}
+
+	do(http.MethodGet, server.URL+"/api/v1/pods?limit=500")
+	if !UnderBackpressure() {
+		t.Errorf("Expected backpressure after a throttled list")
+	}
+}
+
+func TestBackpressureWindow(t *testing.T) {
+	tests := map[string]time.Duration{
+		"":      defaultBackpressureWindow,
+		"0":     defaultBackpressureWindow,
+		"soon":  defaultBackpressureWindow,
+		"3":     3 * time.Second,
+		"86400": maxBackpressureWindow,
+	}
+	for retryAfter, expected := range tests {
+		header := http.Header{}
+		header.Set("Retry-After", retryAfter)
+		if window := backpressureWindow(header); window != expected {
+			t.Errorf("Expected a window of %s for Retry-After %q, got %s", expected, retryAfter, window)
+		}
+	}
+}
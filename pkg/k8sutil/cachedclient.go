@@ -0,0 +1,122 @@
+package k8sutil
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// cacheSyncTimeout bounds how long CachedClient waits for the cache to sync
+const cacheSyncTimeout = 2 * time.Minute
+
+// CachedClient creates a client which serves reads of the cachedObjects kinds
+// from an informer cache and everything else from the API server. The cache is
+// synced before returning and runs until ctx is cancelled. If it doesn't sync
+// within cacheSyncTimeout, for instance because a kind can't be listed, the
+// cache is stopped and an error returned.
+func CachedClient(ctx context.Context, s *runtime.Scheme, cachedObjects []client.Object) (client.Client, error) {
+	config, err := buildConfig(os.Getenv("KUBECONFIG"))
+	if err != nil {
+		return nil, err
+	}
+
+	// note when the API server throttles the reads of webhooks, see
+	// UnderBackpressure
+	readConfig := rest.CopyConfig(config)
+	readConfig.Wrap(wrapBackpressure)
+	c, err := client.New(readConfig, client.Options{
+		Scheme: s,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(cachedObjects) == 0 {
+		return c, nil
+	}
+
+	informers, err := cache.New(config, cache.Options{
+		Scheme: s,
+	})
+	if err != nil {
+		return nil, err
+	}
+	cached := map[schema.GroupVersionKind]bool{}
+	for _, obj := range cachedObjects {
+		gvk, err := apiutil.GVKForObject(obj, s)
+		if err != nil {
+			return nil, err
+		}
+		if cached[gvk] {
+			continue
+		}
+		if _, err := informers.GetInformer(ctx, obj); err != nil {
+			return nil, err
+		}
+		cached[gvk] = true
+		log.Info("Caching kind for webhooks", "kind", gvk.String())
+	}
+
+	// the cache only keeps running, until ctx is cancelled, once it's synced
+	cacheCtx, stopCache := context.WithCancel(ctx)
+	synced := false
+	defer func() {
+		if !synced {
+			stopCache()
+		}
+	}()
+	go func() {
+		if err := informers.Start(cacheCtx); err != nil {
+			log.Error(err, "Shared client cache stopped")
+		}
+	}()
+	syncCtx, cancel := context.WithTimeout(cacheCtx, cacheSyncTimeout)
+	defer cancel()
+	if !informers.WaitForCacheSync(syncCtx) {
+		return nil, fmt.Errorf("failed to sync shared client cache within %s", cacheSyncTimeout)
+	}
+	synced = true
+
+	return &cachedClient{Client: c, cache: informers, scheme: s, cached: cached}, nil
+}
+
+// cachedClient reads the cached kinds from the cache and delegates everything
+// else to the embedded client
+type cachedClient struct {
+	client.Client
+	cache  cache.Cache
+	scheme *runtime.Scheme
+	cached map[schema.GroupVersionKind]bool
+}
+
+// reader returns the cache when obj, or the items of the list obj, is a
+// cached kind
+func (c *cachedClient) reader(obj runtime.Object) client.Reader {
+	gvk, err := apiutil.GVKForObject(obj, c.scheme)
+	if err != nil {
+		return c.Client
+	}
+	gvk.Kind = strings.TrimSuffix(gvk.Kind, "List")
+	if c.cached[gvk] {
+		return c.cache
+	}
+	return c.Client
+}
+
+// Get implements client.Reader
+func (c *cachedClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	return c.reader(obj).Get(ctx, key, obj, opts...)
+}
+
+// List implements client.Reader
+func (c *cachedClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	return c.reader(list).List(ctx, list, opts...)
+}
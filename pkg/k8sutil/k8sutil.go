@@ -31,22 +31,25 @@ var (
 
 func buildConfig(kubeconfig string) (*rest.Config, error) {
 	// Try loading KUBECONFIG env var.  If not set fallback on InClusterConfig
-
+	var cfg *rest.Config
+	var err error
 	if kubeconfig != "" {
-		cfg, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
-		if err != nil {
-			return nil, err
-		}
-		return cfg, nil
+		cfg, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
+	} else {
+		cfg, err = rest.InClusterConfig()
 	}
-
-	cfg, err := rest.InClusterConfig()
 	if err != nil {
 		return nil, err
 	}
 	return cfg, nil
 }
 
+// RestConfig returns the config for the Kube api, from KUBECONFIG or the
+// in-cluster service account
+func RestConfig() (*rest.Config, error) {
+	return buildConfig(os.Getenv("KUBECONFIG"))
+}
+
 // KubeClient creates a new kubeclient that interacts with the Kube api with the service account secrets
 func KubeClient(s *runtime.Scheme) (client.Client, error) {
 	// Try loading KUBECONFIG env var.  Else falls back on in-cluster config
@@ -0,0 +1,60 @@
+package k8sutil
+
+import (
+	"sync"
+
+	configv1 "github.com/openshift/api/config/v1"
+	imagev1 "github.com/openshift/api/image/v1"
+	registryv1 "github.com/openshift/api/imageregistry/v1"
+	operatorv1alpha1 "github.com/openshift/api/operator/v1alpha1"
+	quotav1 "github.com/openshift/api/quota/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var (
+	schemeOnce    sync.Once
+	sharedScheme  *runtime.Scheme
+	sharedDecoder admissionctl.Decoder
+	schemeErr     error
+)
+
+// SharedScheme returns the scheme shared by the shared client and the
+// webhooks, with every kind they read or decode. It's built once, on first
+// use, and must not be changed.
+func SharedScheme() (*runtime.Scheme, error) {
+	schemeOnce.Do(buildScheme)
+	return sharedScheme, schemeErr
+}
+
+// SharedDecoder returns a decoder of admission requests using SharedScheme.
+// Building a decoder is costly, so webhooks should use this one rather than
+// build their own for every request.
+func SharedDecoder() (admissionctl.Decoder, error) {
+	schemeOnce.Do(buildScheme)
+	return sharedDecoder, schemeErr
+}
+
+func buildScheme() {
+	s := runtime.NewScheme()
+	for _, addToScheme := range []func(*runtime.Scheme) error{
+		clientgoscheme.AddToScheme,
+		admissionv1.AddToScheme,
+		configv1.AddToScheme,
+		imagev1.AddToScheme,
+		registryv1.AddToScheme,
+		routev1.AddToScheme,
+		operatorv1alpha1.AddToScheme,
+		quotav1.AddToScheme,
+	} {
+		if err := addToScheme(s); err != nil {
+			schemeErr = err
+			return
+		}
+	}
+	sharedScheme = s
+	sharedDecoder = admissionctl.NewDecoder(s)
+}
@@ -0,0 +1,37 @@
+package k8sutil
+
+import (
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestSharedDecoder(t *testing.T) {
+	scheme, err := SharedScheme()
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	if again, _ := SharedScheme(); again != scheme {
+		t.Errorf("Expected the scheme to be built once")
+	}
+	for _, obj := range []runtime.Object{&corev1.Pod{}, &admissionv1.AdmissionReview{}} {
+		if _, _, err := scheme.ObjectKinds(obj); err != nil {
+			t.Errorf("Expected %T to be in the shared scheme, got %s", obj, err.Error())
+		}
+	}
+
+	decoder, err := SharedDecoder()
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	pod := &corev1.Pod{}
+	raw := runtime.RawExtension{Raw: []byte(`{"apiVersion":"v1","kind":"Pod","metadata":{"name":"test","namespace":"default"}}`)}
+	if err := decoder.DecodeRaw(raw, pod); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	if pod.Name != "test" {
+		t.Errorf("Expected the Pod to be decoded, got %+v", pod)
+	}
+}
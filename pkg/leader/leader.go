@@ -0,0 +1,156 @@
+// Package leader elects the one replica of the webhook server which runs the
+// controllers needing a single active instance, such as the registry reverter
+// and the drift detector. Admission is stateless and served by every replica
+// whether it leads or not.
+package leader
+
+import (
+	"context"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/localmetrics"
+)
+
+const (
+	// LeaseName is the Lease the replicas of the webhook server elect their
+	// leader with
+	LeaseName string = "validation-webhook-leader"
+
+	leaseDuration = 60 * time.Second
+	renewDeadline = 15 * time.Second
+	retryPeriod   = 5 * time.Second
+)
+
+var log = logf.Log.WithName("leader")
+
+// runnable is a controller run while leading
+type runnable struct {
+	name string
+	run  func(ctx context.Context)
+}
+
+// Elector runs the controllers added to it while this replica is the elected
+// leader, and stops them when leadership is lost
+type Elector struct {
+	mu        sync.Mutex
+	runnables []runnable
+	started   bool
+
+	leading atomic.Bool
+}
+
+// NewElector creates an Elector
+func NewElector() *Elector {
+	return &Elector{}
+}
+
+// Add runs the controller while leading. run must return once its context is
+// cancelled, as it is when leadership is lost; it's run again if this replica
+// is elected again. Controllers must be added before Start.
+func (e *Elector) Add(name string, run func(ctx context.Context)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.started {
+		log.Info("Ignoring controller added after the election started", "controller", name)
+		return
+	}
+	e.runnables = append(e.runnables, runnable{name: name, run: run})
+}
+
+// IsLeader returns true while this replica is the elected leader
+func (e *Elector) IsLeader() bool {
+	return e.leading.Load()
+}
+
+// Start stands for election through the Lease in namespace until ctx is
+// cancelled. Nothing is elected when no controllers were added.
+func (e *Elector) Start(ctx context.Context, namespace string) error {
+	e.mu.Lock()
+	e.started = true
+	empty := len(e.runnables) == 0
+	e.mu.Unlock()
+	if empty {
+		return nil
+	}
+
+	config, err := k8sutil.RestConfig()
+	if err != nil {
+		return err
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+	identity, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+	return e.start(ctx, &resourcelock.LeaseLock{
+		LeaseMeta:  metav1.ObjectMeta{Name: LeaseName, Namespace: namespace},
+		Client:     clientset.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+	})
+}
+
+// start stands for election through lock
+func (e *Elector) start(ctx context.Context, lock resourcelock.Interface) error {
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   leaseDuration,
+		RenewDeadline:   renewDeadline,
+		RetryPeriod:     retryPeriod,
+		ReleaseOnCancel: true,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: e.lead,
+			OnStoppedLeading: func() {
+				log.Info("Stopped leading", "identity", lock.Identity())
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		// Run returns when leadership is lost; stand for election again
+		for ctx.Err() == nil {
+			elector.Run(ctx)
+		}
+	}()
+	return nil
+}
+
+// lead runs every controller until ctx is cancelled, when leadership is lost
+func (e *Elector) lead(ctx context.Context) {
+	e.mu.Lock()
+	runnables := append([]runnable(nil), e.runnables...)
+	e.mu.Unlock()
+
+	e.leading.Store(true)
+	localmetrics.SetLeader(true)
+	defer func() {
+		e.leading.Store(false)
+		localmetrics.SetLeader(false)
+	}()
+
+	var wg sync.WaitGroup
+	for _, r := range runnables {
+		log.Info("Starting controller while leading", "controller", r.name)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.run(ctx)
+		}()
+	}
+	wg.Wait()
+}
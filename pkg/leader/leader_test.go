@@ -0,0 +1,68 @@
+package leader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+func TestElectorRunsControllersWhileLeading(t *testing.T) {
+	started := make(chan struct{})
+	stopped := make(chan struct{})
+	e := NewElector()
+	e.Add("test", func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		close(stopped)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta:  metav1.ObjectMeta{Name: LeaseName, Namespace: "openshift-validation-webhook"},
+		Client:     fake.NewClientset().CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: "replica-a"},
+	}
+	if err := e.start(ctx, lock); err != nil {
+		t.Fatalf("Unexpected error standing for election: %v", err)
+	}
+
+	select {
+	case <-started:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Expected the controller to start once elected")
+	}
+	if !e.IsLeader() {
+		t.Errorf("Expected the elector to be leading")
+	}
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Expected the controller to stop when leadership is lost")
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for e.IsLeader() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if e.IsLeader() {
+		t.Errorf("Expected the elector to stop leading")
+	}
+}
+
+func TestElectorWithoutControllers(t *testing.T) {
+	e := NewElector()
+	// nothing to run, so no Lease and no client are needed
+	if err := e.Start(context.Background(), "openshift-validation-webhook"); err != nil {
+		t.Fatalf("Expected no error without controllers, got %v", err)
+	}
+	e.Add("late", func(ctx context.Context) {})
+	if len(e.runnables) != 0 {
+		t.Errorf("Expected controllers added after the election started to be ignored")
+	}
+}
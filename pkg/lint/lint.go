@@ -0,0 +1,504 @@
+// Package lint checks rendered Validating and MutatingWebhookConfigurations
+// for settings which are valid but dangerous: webhooks which can block the
+// pods serving them, rules several webhooks compete for, failure policies
+// which turn a slow webhook into a cluster outage, and side effects declared
+// differently than the webhook's code declares them.
+package lint
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+)
+
+const (
+	// DefaultMaxFailTimeoutSeconds is the longest timeout a webhook failing
+	// closed may have before it's reported
+	DefaultMaxFailTimeoutSeconds int32 = 5
+
+	// defaultTimeoutSeconds is the timeout the API server defaults
+	// admissionregistration.k8s.io/v1 webhooks to
+	defaultTimeoutSeconds int32 = 10
+
+	validatingKind = "ValidatingWebhookConfiguration"
+	mutatingKind   = "MutatingWebhookConfiguration"
+)
+
+// Severity is how bad a Finding is
+type Severity string
+
+const (
+	// SeverityError findings can take down the cluster's API, or are
+	// rejected by the API server
+	SeverityError Severity = "ERROR"
+	// SeverityWarning findings are likely mistakes
+	SeverityWarning Severity = "WARNING"
+	// SeverityInfo findings are worth knowing, but usually intended
+	SeverityInfo Severity = "INFO"
+)
+
+// Checks reported by Lint
+const (
+	CheckOwnNamespace = "own-namespace"
+	CheckOverlap      = "overlap"
+	CheckFailTimeout  = "fail-timeout"
+	CheckSideEffects  = "side-effects"
+)
+
+// Options tune the checks
+type Options struct {
+	// MaxFailTimeoutSeconds is the longest timeout a webhook failing closed
+	// may have. DefaultMaxFailTimeoutSeconds is used when it's 0.
+	MaxFailTimeoutSeconds int32
+}
+
+// Finding is a problem with a webhook of a configuration
+type Finding struct {
+	Severity Severity
+	Check    string
+	// Configuration is the kind and name of the configuration, as
+	// kind/name
+	Configuration string
+	Webhook       string
+	Message       string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s %s %s %s: %s", f.Severity, f.Check, f.Configuration, f.Webhook, f.Message)
+}
+
+// Configuration is a Validating or MutatingWebhookConfiguration
+type Configuration struct {
+	Kind     string
+	Name     string
+	Webhooks []Webhook
+}
+
+func (c Configuration) String() string {
+	return c.Kind + "/" + c.Name
+}
+
+// Webhook has the fields of a validating or mutating webhook the checks read
+type Webhook struct {
+	Name              string
+	ClientConfig      admissionregv1.WebhookClientConfig
+	Rules             []admissionregv1.RuleWithOperations
+	FailurePolicy     *admissionregv1.FailurePolicyType
+	SideEffects       *admissionregv1.SideEffectClass
+	TimeoutSeconds    *int32
+	NamespaceSelector *metav1.LabelSelector
+	// ReinvocationPolicy is only set for mutating webhooks
+	ReinvocationPolicy *admissionregv1.ReinvocationPolicyType
+}
+
+// FromValidating reads the webhooks of a ValidatingWebhookConfiguration
+func FromValidating(vwc admissionregv1.ValidatingWebhookConfiguration) Configuration {
+	c := Configuration{Kind: validatingKind, Name: vwc.Name}
+	for _, w := range vwc.Webhooks {
+		c.Webhooks = append(c.Webhooks, Webhook{w.Name, w.ClientConfig, w.Rules, w.FailurePolicy, w.SideEffects, w.TimeoutSeconds, w.NamespaceSelector, nil})
+	}
+	return c
+}
+
+// FromMutating reads the webhooks of a MutatingWebhookConfiguration
+func FromMutating(mwc admissionregv1.MutatingWebhookConfiguration) Configuration {
+	c := Configuration{Kind: mutatingKind, Name: mwc.Name}
+	for _, w := range mwc.Webhooks {
+		c.Webhooks = append(c.Webhooks, Webhook{w.Name, w.ClientConfig, w.Rules, w.FailurePolicy, w.SideEffects, w.TimeoutSeconds, w.NamespaceSelector, w.ReinvocationPolicy})
+	}
+	return c
+}
+
+// Rendered returns the configurations of the registered webhooks, as they're
+// rendered for Classic clusters with the Service in namespace
+func Rendered(hooks webhooks.RegisteredWebhooks, namespace string) []Configuration {
+	names := make([]string, 0, len(hooks))
+	for name := range hooks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	configurations := []Configuration{}
+	for _, name := range names {
+		hook := hooks[name]()
+		if !hook.ClassicEnabled() {
+			continue
+		}
+		if webhooks.IsMutating(hook.Name()) {
+			configurations = append(configurations, FromMutating(webhooks.MutatingWebhookConfiguration(hook, namespace)))
+		} else {
+			configurations = append(configurations, FromValidating(webhooks.ValidatingWebhookConfiguration(hook, namespace)))
+		}
+	}
+	return configurations
+}
+
+// Read reads the configurations in r, named name. It may hold YAML or JSON
+// documents, and the configurations may be nested in Lists, Templates and
+// SelectorSyncSets, as the generated manifests are. Other objects are ignored.
+func Read(name string, r io.Reader) ([]Configuration, error) {
+	configurations := []Configuration{}
+	decoder := utilyaml.NewYAMLOrJSONDecoder(r, 4096)
+	for index := 1; ; index++ {
+		document := map[string]interface{}{}
+		if err := decoder.Decode(&document); errors.Is(err, io.EOF) {
+			return configurations, nil
+		} else if err != nil {
+			return configurations, fmt.Errorf("couldn't read %s:%d: %w", name, index, err)
+		}
+		found, err := collect(document)
+		if err != nil {
+			return configurations, fmt.Errorf("couldn't read %s:%d: %w", name, index, err)
+		}
+		configurations = append(configurations, found...)
+	}
+}
+
+// collect returns the configurations in the object, or nested in its items,
+// objects or resources
+func collect(object map[string]interface{}) ([]Configuration, error) {
+	kind, _ := object["kind"].(string)
+	switch kind {
+	case validatingKind:
+		vwc := admissionregv1.ValidatingWebhookConfiguration{}
+		if err := convert(object, &vwc); err != nil {
+			return nil, err
+		}
+		return []Configuration{FromValidating(vwc)}, nil
+	case mutatingKind:
+		mwc := admissionregv1.MutatingWebhookConfiguration{}
+		if err := convert(object, &mwc); err != nil {
+			return nil, err
+		}
+		return []Configuration{FromMutating(mwc)}, nil
+	}
+
+	nested := []interface{}{}
+	for _, key := range []string{"items", "objects"} {
+		if list, ok := object[key].([]interface{}); ok {
+			nested = append(nested, list...)
+		}
+	}
+	if spec, ok := object["spec"].(map[string]interface{}); ok {
+		if list, ok := spec["resources"].([]interface{}); ok {
+			nested = append(nested, list...)
+		}
+	}
+	configurations := []Configuration{}
+	for _, n := range nested {
+		if child, ok := n.(map[string]interface{}); ok {
+			found, err := collect(child)
+			if err != nil {
+				return nil, err
+			}
+			configurations = append(configurations, found...)
+		}
+	}
+	return configurations, nil
+}
+
+func convert(object map[string]interface{}, into interface{}) error {
+	raw, err := json.Marshal(object)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, into)
+}
+
+// Lint checks the configurations, returning the findings in the order of the
+// configurations. The side effects each webhook declares are compared with
+// those of the registered webhook it calls, found by its path or name.
+func Lint(configurations []Configuration, hooks webhooks.RegisteredWebhooks, opts Options) []Finding {
+	if opts.MaxFailTimeoutSeconds == 0 {
+		opts.MaxFailTimeoutSeconds = DefaultMaxFailTimeoutSeconds
+	}
+	registered := map[string]webhooks.Webhook{}
+	for _, factory := range hooks {
+		hook := factory()
+		registered[hook.GetURI()] = hook
+		registered[hook.Name()] = hook
+	}
+
+	findings := []Finding{}
+	for _, c := range configurations {
+		for _, w := range c.Webhooks {
+			finding := func(severity Severity, check, format string, args ...interface{}) {
+				findings = append(findings, Finding{severity, check, c.String(), w.Name, fmt.Sprintf(format, args...)})
+			}
+			checkOwnNamespace(w, finding)
+			checkFailTimeout(w, opts.MaxFailTimeoutSeconds, finding)
+			checkSideEffects(w, registeredHook(registered, w), finding)
+		}
+	}
+	return append(findings, checkOverlaps(configurations)...)
+}
+
+type report func(severity Severity, check, format string, args ...interface{})
+
+// checkOwnNamespace reports webhooks the API server calls for requests in the
+// namespace of the Service serving them. A webhook failing closed on its own
+// namespace can't be recovered once its pods are gone, as their replacements
+// are denied; one failing open only delays them by its timeout, so it's
+// noted. Scopes are taken from the rules, so rules of "*" scope are assumed to
+// match namespaced resources.
+func checkOwnNamespace(w Webhook, finding report) {
+	service := w.ClientConfig.Service
+	if service == nil || service.Namespace == "" || !namespaced(w.Rules) {
+		return
+	}
+	if w.NamespaceSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(w.NamespaceSelector)
+		if err != nil {
+			finding(SeverityError, CheckOwnNamespace, "invalid namespaceSelector: %v", err)
+			return
+		}
+		if !selector.Matches(labels.Set{utils.NamespaceNameLabel: service.Namespace}) {
+			return
+		}
+	}
+	if failurePolicy(w) == admissionregv1.Fail {
+		finding(SeverityError, CheckOwnNamespace, "fails closed and its namespaceSelector doesn't exclude %s, the namespace serving it, so its pods can't be replaced while it's down", service.Namespace)
+		return
+	}
+	finding(SeverityInfo, CheckOwnNamespace, "its namespaceSelector doesn't exclude %s, the namespace serving it, so requests there wait for its timeout while it's down", service.Namespace)
+}
+
+// namespaced returns true if any rule matches namespaced resources
+func namespaced(rules []admissionregv1.RuleWithOperations) bool {
+	for _, rule := range rules {
+		if rule.Scope == nil || *rule.Scope != admissionregv1.ClusterScope {
+			return true
+		}
+	}
+	return false
+}
+
+// checkFailTimeout reports webhooks failing closed whose timeout is longer
+// than maxSeconds: while such a webhook hangs, every request it matches waits
+// that long before being denied
+func checkFailTimeout(w Webhook, maxSeconds int32, finding report) {
+	if failurePolicy(w) != admissionregv1.Fail {
+		return
+	}
+	timeout := defaultTimeoutSeconds
+	if w.TimeoutSeconds != nil {
+		timeout = *w.TimeoutSeconds
+	}
+	if timeout > maxSeconds {
+		finding(SeverityError, CheckFailTimeout, "fails closed with a %ds timeout, longer than %ds", timeout, maxSeconds)
+	}
+}
+
+// checkSideEffects reports side effects the API server rejects, and those
+// which differ from what the webhook's code declares
+func checkSideEffects(w Webhook, hook webhooks.Webhook, finding report) {
+	declared := admissionregv1.SideEffectClass("")
+	if w.SideEffects != nil {
+		declared = *w.SideEffects
+	}
+	if declared != admissionregv1.SideEffectClassNone && declared != admissionregv1.SideEffectClassNoneOnDryRun {
+		finding(SeverityError, CheckSideEffects, "sideEffects %q isn't None or NoneOnDryRun, which admissionregistration.k8s.io/v1 requires", declared)
+		return
+	}
+	if hook == nil {
+		finding(SeverityInfo, CheckSideEffects, "no registered webhook serves it, so its side effects can't be compared")
+		return
+	}
+	if code := hook.SideEffects(); code != declared {
+		finding(SeverityError, CheckSideEffects, "declares sideEffects %s, but %s declares %s, so dry runs are sent to or kept from it wrongly", declared, hook.Name(), code)
+	}
+}
+
+// registeredHook returns the registered webhook the webhook calls, by its
+// Service path or else its name, or nil if there's none
+func registeredHook(registered map[string]webhooks.Webhook, w Webhook) webhooks.Webhook {
+	if service := w.ClientConfig.Service; service != nil && service.Path != nil {
+		if hook, ok := registered[*service.Path]; ok {
+			return hook
+		}
+	}
+	return registered[strings.TrimSuffix(w.Name, ".managed.openshift.io")]
+}
+
+// checkOverlaps reports pairs of webhooks matching some of the same requests.
+// Mutating webhooks are called in turn, so overlapping ones see each other's
+// changes in an order which depends on their names, unless they're reinvoked;
+// validating webhooks are called in parallel, so overlaps are only noted.
+func checkOverlaps(configurations []Configuration) []Finding {
+	type located struct {
+		configuration Configuration
+		webhook       Webhook
+	}
+	all := []located{}
+	for _, c := range configurations {
+		for _, w := range c.Webhooks {
+			all = append(all, located{c, w})
+		}
+	}
+
+	findings := []Finding{}
+	for i, a := range all {
+		for _, b := range all[i+1:] {
+			if a.configuration.Kind != b.configuration.Kind {
+				continue
+			}
+			overlap, ok := overlapping(a.webhook.Rules, b.webhook.Rules)
+			if !ok {
+				continue
+			}
+			severity := SeverityInfo
+			if a.configuration.Kind == mutatingKind && !(reinvoked(a.webhook) && reinvoked(b.webhook)) {
+				severity = SeverityWarning
+			}
+			findings = append(findings, Finding{severity, CheckOverlap, a.configuration.String(), a.webhook.Name, fmt.Sprintf("matches %s like %s %s", overlap, b.configuration, b.webhook.Name)})
+		}
+	}
+	return findings
+}
+
+func reinvoked(w Webhook) bool {
+	return w.ReinvocationPolicy != nil && *w.ReinvocationPolicy == admissionregv1.IfNeededReinvocationPolicy
+}
+
+// overlapping describes the first requests both sets of rules match, if any
+func overlapping(a, b []admissionregv1.RuleWithOperations) (string, bool) {
+	for _, ra := range a {
+		for _, rb := range b {
+			operations := intersect(operationStrings(ra.Operations), operationStrings(rb.Operations))
+			groups := intersect(ra.APIGroups, rb.APIGroups)
+			versions := intersect(ra.APIVersions, rb.APIVersions)
+			resources := intersectResources(ra.Resources, rb.Resources)
+			if len(operations) == 0 || len(groups) == 0 || len(versions) == 0 || len(resources) == 0 || !scopesOverlap(ra.Scope, rb.Scope) {
+				continue
+			}
+			for i, group := range groups {
+				if group == "" {
+					groups[i] = "core"
+				}
+			}
+			return fmt.Sprintf("%s %s in %s", strings.Join(operations, ","), strings.Join(resources, ","), strings.Join(groups, ",")), true
+		}
+	}
+	return "", false
+}
+
+func operationStrings(operations []admissionregv1.OperationType) []string {
+	s := make([]string, 0, len(operations))
+	for _, op := range operations {
+		s = append(s, string(op))
+	}
+	return s
+}
+
+// intersect returns the values both lists match, where "*" matches any value
+func intersect(a, b []string) []string {
+	switch {
+	case slices.Contains(a, "*"):
+		return slices.Clone(b)
+	case slices.Contains(b, "*"):
+		return slices.Clone(a)
+	}
+	both := []string{}
+	for _, value := range a {
+		if slices.Contains(b, value) && !slices.Contains(both, value) {
+			both = append(both, value)
+		}
+	}
+	return both
+}
+
+// intersectResources returns the resources both lists match, where "*"
+// matches any resource, "*/*" any resource or subresource, "*/sub" the
+// subresource of any resource and "resource/*" any subresource of resource
+func intersectResources(a, b []string) []string {
+	both := []string{}
+	for _, ra := range a {
+		for _, rb := range b {
+			if r, ok := intersectResource(ra, rb); ok && !slices.Contains(both, r) {
+				both = append(both, r)
+			}
+		}
+	}
+	return both
+}
+
+func intersectResource(a, b string) (string, bool) {
+	if a == "*/*" {
+		return b, true
+	}
+	if b == "*/*" {
+		return a, true
+	}
+	nameA, subA, hasSubA := strings.Cut(a, "/")
+	nameB, subB, hasSubB := strings.Cut(b, "/")
+	if hasSubA != hasSubB {
+		return "", false
+	}
+	name, ok := intersectPart(nameA, nameB)
+	if !ok {
+		return "", false
+	}
+	if !hasSubA {
+		return name, true
+	}
+	sub, ok := intersectPart(subA, subB)
+	return name + "/" + sub, ok
+}
+
+func intersectPart(a, b string) (string, bool) {
+	switch {
+	case a == "*":
+		return b, true
+	case b == "*", a == b:
+		return a, true
+	}
+	return "", false
+}
+
+func scopesOverlap(a, b *admissionregv1.ScopeType) bool {
+	return a == nil || b == nil || *a == admissionregv1.AllScopes || *b == admissionregv1.AllScopes || *a == *b
+}
+
+// failurePolicy returns the webhook's failure policy, which the API server
+// defaults to Fail
+func failurePolicy(w Webhook) admissionregv1.FailurePolicyType {
+	if w.FailurePolicy == nil {
+		return admissionregv1.Fail
+	}
+	return *w.FailurePolicy
+}
+
+// Failed returns true if any finding is an error, or also a warning when
+// strict
+func Failed(findings []Finding, strict bool) bool {
+	for _, f := range findings {
+		if f.Severity == SeverityError || (strict && f.Severity == SeverityWarning) {
+			return true
+		}
+	}
+	return false
+}
+
+// Print writes the errors and warnings, or also the notes when verbose, then
+// a summary
+func Print(w io.Writer, findings []Finding, configurations int, verbose bool) {
+	counts := map[Severity]int{}
+	for _, f := range findings {
+		counts[f.Severity]++
+		if f.Severity != SeverityInfo || verbose {
+			fmt.Fprintln(w, f)
+		}
+	}
+	fmt.Fprintf(w, "%d configurations linted: %d errors, %d warnings, %d notes\n", configurations, counts[SeverityError], counts[SeverityWarning], counts[SeverityInfo])
+}
@@ -0,0 +1,297 @@
+package lint
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/priorityclass"
+)
+
+// template nests configurations as the generated SelectorSyncSet template does
+const template = `apiVersion: template.openshift.io/v1
+kind: Template
+objects:
+- apiVersion: hive.openshift.io/v1
+  kind: SelectorSyncSet
+  spec:
+    resources:
+    - apiVersion: v1
+      kind: Namespace
+      metadata:
+        name: openshift-validation-webhook
+    - apiVersion: admissionregistration.k8s.io/v1
+      kind: ValidatingWebhookConfiguration
+      metadata:
+        name: sre-priorityclass-validation
+      webhooks:
+      - name: priorityclass-validation.managed.openshift.io
+        clientConfig:
+          service:
+            name: validation-webhook
+            namespace: openshift-validation-webhook
+            path: /priorityclass-validation
+        failurePolicy: Ignore
+        sideEffects: None
+        timeoutSeconds: 2
+        rules:
+        - apiGroups: ["scheduling.k8s.io"]
+          apiVersions: ["*"]
+          operations: ["*"]
+          resources: ["priorityclasses"]
+          scope: Cluster
+---
+apiVersion: admissionregistration.k8s.io/v1
+kind: MutatingWebhookConfiguration
+metadata:
+  name: sre-service-mutation
+webhooks:
+- name: service-mutation.managed.openshift.io
+  sideEffects: None
+`
+
+var testHooks = webhooks.RegisteredWebhooks{
+	priorityclass.WebhookName: func() webhooks.Webhook { return priorityclass.NewWebhook() },
+}
+
+func TestRead(t *testing.T) {
+	configurations, err := Read("selectorsyncset.yaml", strings.NewReader(template))
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	if len(configurations) != 2 {
+		t.Fatalf("Expected 2 configurations, got %d: %v", len(configurations), configurations)
+	}
+	if name := configurations[0].String(); name != "ValidatingWebhookConfiguration/sre-priorityclass-validation" {
+		t.Errorf("Expected the validating configuration first, got %s", name)
+	}
+	if name := configurations[1].String(); name != "MutatingWebhookConfiguration/sre-service-mutation" {
+		t.Errorf("Expected the mutating configuration second, got %s", name)
+	}
+	if path := configurations[0].Webhooks[0].ClientConfig.Service.Path; path == nil || *path != "/priorityclass-validation" {
+		t.Errorf("Expected the service path to be read, got %v", path)
+	}
+
+	if _, err := Read("broken.yaml", strings.NewReader("kind: [")); err == nil {
+		t.Errorf("Expected an error reading invalid YAML")
+	}
+}
+
+// webhook is a webhook failing open on cluster-scoped priorityclasses, which
+// the tests change one setting of
+func webhook(name string) Webhook {
+	return Webhook{
+		Name: name + ".managed.openshift.io",
+		ClientConfig: admissionregv1.WebhookClientConfig{
+			Service: &admissionregv1.ServiceReference{Name: "validation-webhook", Namespace: "openshift-validation-webhook", Path: ptr.To("/" + name)},
+		},
+		Rules: []admissionregv1.RuleWithOperations{
+			{
+				Operations: []admissionregv1.OperationType{admissionregv1.Delete},
+				Rule: admissionregv1.Rule{
+					APIGroups:   []string{"scheduling.k8s.io"},
+					APIVersions: []string{"*"},
+					Resources:   []string{"priorityclasses"},
+					Scope:       ptr.To(admissionregv1.ClusterScope),
+				},
+			},
+		},
+		FailurePolicy:  ptr.To(admissionregv1.Ignore),
+		SideEffects:    ptr.To(admissionregv1.SideEffectClassNone),
+		TimeoutSeconds: ptr.To(int32(2)),
+	}
+}
+
+func podRules(operations ...admissionregv1.OperationType) []admissionregv1.RuleWithOperations {
+	return []admissionregv1.RuleWithOperations{
+		{
+			Operations: operations,
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{""},
+				APIVersions: []string{"v1"},
+				Resources:   []string{"pods"},
+			},
+		},
+	}
+}
+
+func TestLint(t *testing.T) {
+	failing := webhook(priorityclass.WebhookName)
+	failing.FailurePolicy = ptr.To(admissionregv1.Fail)
+
+	slow := failing
+	slow.TimeoutSeconds = nil
+
+	onPods := failing
+	onPods.Rules = podRules(admissionregv1.Create)
+
+	excluded := onPods
+	excluded.NamespaceSelector = &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: "kubernetes.io/metadata.name", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"openshift-validation-webhook"}},
+		},
+	}
+
+	openOnPods := webhook(priorityclass.WebhookName)
+	openOnPods.Rules = podRules(admissionregv1.Create)
+
+	dryRun := webhook(priorityclass.WebhookName)
+	dryRun.SideEffects = ptr.To(admissionregv1.SideEffectClassNoneOnDryRun)
+
+	some := webhook(priorityclass.WebhookName)
+	some.SideEffects = ptr.To(admissionregv1.SideEffectClassSome)
+
+	mutatingA, mutatingB := webhook("a-mutation"), webhook("b-mutation")
+	mutatingA.Rules = podRules(admissionregv1.Create, admissionregv1.Update)
+	mutatingB.Rules = podRules(admissionregv1.OperationAll)
+	mutatingB.Rules[0].Resources = []string{"*"}
+
+	reinvokedA, reinvokedB := mutatingA, mutatingB
+	reinvokedA.ReinvocationPolicy = ptr.To(admissionregv1.IfNeededReinvocationPolicy)
+	reinvokedB.ReinvocationPolicy = ptr.To(admissionregv1.IfNeededReinvocationPolicy)
+
+	subresources := mutatingB
+	subresources.Rules = podRules(admissionregv1.Create)
+	subresources.Rules[0].Resources = []string{"pods/exec"}
+
+	validating := func(webhooks ...Webhook) Configuration {
+		return Configuration{Kind: validatingKind, Name: "test", Webhooks: webhooks}
+	}
+	mutating := func(webhooks ...Webhook) Configuration {
+		return Configuration{Kind: mutatingKind, Name: "test", Webhooks: webhooks}
+	}
+
+	tests := []struct {
+		name           string
+		configurations []Configuration
+		opts           Options
+		expected       []string
+	}{
+		{
+			name:           "fails open with a short timeout",
+			configurations: []Configuration{validating(webhook(priorityclass.WebhookName))},
+		},
+		{
+			name:           "fails closed with a short timeout",
+			configurations: []Configuration{validating(failing)},
+		},
+		{
+			name:           "fails closed with the default timeout",
+			configurations: []Configuration{validating(slow)},
+			expected:       []string{"ERROR fail-timeout ValidatingWebhookConfiguration/test priorityclass-validation.managed.openshift.io: fails closed with a 10s timeout, longer than 5s"},
+		},
+		{
+			name:           "fails closed with a timeout longer than allowed",
+			configurations: []Configuration{validating(failing)},
+			opts:           Options{MaxFailTimeoutSeconds: 1},
+			expected:       []string{"ERROR fail-timeout"},
+		},
+		{
+			name:           "fails closed on its own namespace",
+			configurations: []Configuration{validating(onPods)},
+			expected:       []string{"ERROR own-namespace ValidatingWebhookConfiguration/test priorityclass-validation.managed.openshift.io: fails closed and its namespaceSelector doesn't exclude openshift-validation-webhook"},
+		},
+		{
+			name:           "excludes its own namespace",
+			configurations: []Configuration{validating(excluded)},
+		},
+		{
+			name:           "fails open on its own namespace",
+			configurations: []Configuration{validating(openOnPods)},
+			expected:       []string{"INFO own-namespace"},
+		},
+		{
+			name:           "side effects differ from the code",
+			configurations: []Configuration{validating(dryRun)},
+			expected:       []string{"ERROR side-effects ValidatingWebhookConfiguration/test priorityclass-validation.managed.openshift.io: declares sideEffects NoneOnDryRun, but priorityclass-validation declares None"},
+		},
+		{
+			name:           "side effects the API server rejects",
+			configurations: []Configuration{validating(some)},
+			expected:       []string{`ERROR side-effects ValidatingWebhookConfiguration/test priorityclass-validation.managed.openshift.io: sideEffects "Some" isn't None or NoneOnDryRun`},
+		},
+		{
+			name:           "overlapping mutating webhooks",
+			configurations: []Configuration{mutating(mutatingA), mutating(mutatingB)},
+			expected: []string{
+				"INFO own-namespace", "INFO side-effects", "INFO own-namespace", "INFO side-effects",
+				"WARNING overlap MutatingWebhookConfiguration/test a-mutation.managed.openshift.io: matches CREATE,UPDATE pods in core like MutatingWebhookConfiguration/test b-mutation.managed.openshift.io",
+			},
+		},
+		{
+			name:           "overlapping reinvoked mutating webhooks",
+			configurations: []Configuration{mutating(reinvokedA, reinvokedB)},
+			expected:       []string{"INFO own-namespace", "INFO side-effects", "INFO own-namespace", "INFO side-effects", "INFO overlap"},
+		},
+		{
+			name:           "overlapping validating webhooks",
+			configurations: []Configuration{validating(mutatingA), validating(mutatingB)},
+			expected:       []string{"INFO own-namespace", "INFO side-effects", "INFO own-namespace", "INFO side-effects", "INFO overlap"},
+		},
+		{
+			name:           "webhooks of different kinds",
+			configurations: []Configuration{validating(mutatingA), mutating(mutatingB)},
+			expected:       []string{"INFO own-namespace", "INFO side-effects", "INFO own-namespace", "INFO side-effects"},
+		},
+		{
+			name:           "subresources don't overlap resources",
+			configurations: []Configuration{mutating(mutatingA, subresources)},
+			expected:       []string{"INFO own-namespace", "INFO side-effects", "INFO own-namespace", "INFO side-effects"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			findings := Lint(test.configurations, testHooks, test.opts)
+			if len(findings) != len(test.expected) {
+				t.Fatalf("Expected %d findings, got %d: %v", len(test.expected), len(findings), findings)
+			}
+			for i, finding := range findings {
+				if !strings.HasPrefix(finding.String(), test.expected[i]) {
+					t.Errorf("Expected finding %d to start with %q, got %q", i, test.expected[i], finding.String())
+				}
+			}
+		})
+	}
+}
+
+func TestRendered(t *testing.T) {
+	configurations := Rendered(testHooks, "openshift-validation-webhook")
+	if len(configurations) != 1 {
+		t.Fatalf("Expected 1 configuration, got %d", len(configurations))
+	}
+	findings := Lint(configurations, testHooks, Options{})
+	if Failed(findings, true) {
+		t.Errorf("Expected the rendered configuration to pass, got %v", findings)
+	}
+}
+
+func TestPrint(t *testing.T) {
+	findings := []Finding{
+		{SeverityError, CheckFailTimeout, "ValidatingWebhookConfiguration/a", "a", "slow"},
+		{SeverityWarning, CheckOverlap, "MutatingWebhookConfiguration/b", "b", "overlaps"},
+		{SeverityInfo, CheckOverlap, "ValidatingWebhookConfiguration/c", "c", "overlaps"},
+	}
+	if !Failed(findings[:1], false) || Failed(findings[1:], false) || !Failed(findings[1:], true) || Failed(findings[2:], true) {
+		t.Errorf("Expected errors to fail, and warnings only when strict")
+	}
+
+	out := &bytes.Buffer{}
+	Print(out, findings, 3, false)
+	if strings.Contains(out.String(), "INFO") {
+		t.Errorf("Expected notes to be left out, got %s", out.String())
+	}
+	if !strings.HasSuffix(out.String(), "3 configurations linted: 1 errors, 1 warnings, 1 notes\n") {
+		t.Errorf("Expected a summary, got %s", out.String())
+	}
+	out.Reset()
+	Print(out, findings, 3, true)
+	if !strings.Contains(out.String(), "INFO overlap ValidatingWebhookConfiguration/c c: overlaps") {
+		t.Errorf("Expected notes when verbose, got %s", out.String())
+	}
+}
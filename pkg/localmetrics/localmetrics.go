@@ -1,7 +1,21 @@
 package localmetrics
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	OutcomeAllowed = "allowed"
+	OutcomeDenied  = "denied"
+	OutcomeMutated = "mutated"
+	OutcomeErrored = "errored"
 )
 
 var (
@@ -10,11 +24,251 @@ var (
 		Help: "Report how many times the managed node webhook has blocked requests",
 	}, []string{"user"})
 
+	MetricWebhookRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "managed_webhook_requests_total",
+		Help: "Report how many admission requests each webhook has answered, by operation and outcome (allowed, denied, mutated or errored)",
+	}, []string{"webhook", "operation", "outcome"})
+
+	MetricWebhookRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "managed_webhook_request_duration_seconds",
+		Help:    "Report how long each webhook took to answer admission requests",
+		Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2, 5},
+	}, []string{"webhook"})
+
+	MetricWebhookPatchSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "managed_webhook_patch_size_bytes",
+		Help:    "Report the size of the JSONPatch returned by mutating webhooks",
+		Buckets: prometheus.ExponentialBuckets(64, 4, 8),
+	}, []string{"webhook"})
+
+	MetricWebhookRequestErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "managed_webhook_request_errors_total",
+		Help: "Report how many admission requests could not be handed to a webhook, by reason",
+	}, []string{"webhook", "reason"})
+
+	MetricWebhookAuditOnly = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "managed_webhook_audit_only_total",
+		Help: "Report how many requests audit-only webhooks allowed which they would otherwise have denied, mutated or errored",
+	}, []string{"webhook", "outcome"})
+
+	MetricWebhookWarnings = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "managed_webhook_warnings_total",
+		Help: "Report how many warnings webhooks returned to clients with their responses",
+	}, []string{"webhook"})
+
+	MetricWebhookTimeouts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "managed_webhook_timeouts_total",
+		Help: "Report how many admission requests webhooks failed to answer within their latency budget",
+	}, []string{"webhook"})
+
+	MetricWebhookShed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "managed_webhook_shed_requests_total",
+		Help: "Report how many admission requests were answered without evaluation because the webhook had too many requests waiting",
+	}, []string{"webhook"})
+
+	MetricWebhookBackpressure = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "managed_webhook_backpressure_allowed_total",
+		Help: "Report how many admission requests webhooks which fail open allowed without evaluation because the API server was throttling the webhooks' requests",
+	}, []string{"webhook"})
+
+	MetricWebhookDecisionCacheHits = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "managed_webhook_decision_cache_hits_total",
+		Help: "Report how many admission requests were answered with the decision a webhook made for an identical request shortly before",
+	}, []string{"webhook"})
+
+	MetricAuditFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "managed_webhook_audit_failures_total",
+		Help: "Report how many audit records of denied requests could not be written, by sink (queue when the record was dropped)",
+	}, []string{"sink"})
+
+	MetricNotificationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "managed_webhook_notification_failures_total",
+		Help: "Report how many notifications of denied requests could not be sent, by notifier (queue when the notification was dropped, ratelimit when it was over the rate limit)",
+	}, []string{"notifier"})
+
+	MetricEventFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "managed_webhook_event_failures_total",
+		Help: "Report how many Events about changes made by the webhooks could not be created, by reason (queue when the Event was dropped)",
+	}, []string{"reason"})
+
+	MetricConfigurationDrift = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "managed_webhook_configuration_drift_total",
+		Help: "Report how many times a webhook's configuration was found drifted from its generated state and repaired, by field (missing when it had been removed)",
+	}, []string{"webhook", "field"})
+
+	MetricRegistryReverts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "managed_webhook_registry_reverts_total",
+		Help: "Report how many workloads were restarted to revert the images podimagespec-mutation rewrote, by kind",
+	}, []string{"kind"})
+
+	MetricServingCertExpiry = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "managed_webhook_serving_cert_expiry_timestamp_seconds",
+		Help: "Report when the serving certificate currently loaded by the webhook server expires, as a Unix timestamp",
+	})
+
+	MetricLeader = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "managed_webhook_leader",
+		Help: "Report 1 while the replica is the elected leader running the controllers which need a single instance, and 0 otherwise",
+	})
+
 	MetricsList = []prometheus.Collector{
 		MetricNodeWebhookBlockedReqeust,
+		MetricWebhookRequests,
+		MetricWebhookRequestDuration,
+		MetricWebhookPatchSize,
+		MetricWebhookRequestErrors,
+		MetricWebhookAuditOnly,
+		MetricWebhookWarnings,
+		MetricWebhookTimeouts,
+		MetricWebhookShed,
+		MetricWebhookBackpressure,
+		MetricWebhookDecisionCacheHits,
+		MetricAuditFailures,
+		MetricNotificationFailures,
+		MetricEventFailures,
+		MetricConfigurationDrift,
+		MetricRegistryReverts,
+		MetricServingCertExpiry,
+		MetricLeader,
 	}
 )
 
 func IncrementNodeWebhookBlockedRequest(user string) {
 	MetricNodeWebhookBlockedReqeust.With(prometheus.Labels{"user": user}).Inc()
 }
+
+// IncrementWebhookRequestError records an admission request for the webhook
+// which failed before reaching the webhook, eg because it couldn't be parsed
+func IncrementWebhookRequestError(webhook, reason string) {
+	MetricWebhookRequestErrors.With(prometheus.Labels{"webhook": webhook, "reason": reason}).Inc()
+}
+
+// IncrementWebhookAuditOnly records a request an audit-only webhook allowed
+// in place of the given outcome
+func IncrementWebhookAuditOnly(webhook, outcome string) {
+	MetricWebhookAuditOnly.With(prometheus.Labels{"webhook": webhook, "outcome": outcome}).Inc()
+}
+
+// AddWebhookWarnings records the warnings a webhook returned with a response
+func AddWebhookWarnings(webhook string, count int) {
+	MetricWebhookWarnings.With(prometheus.Labels{"webhook": webhook}).Add(float64(count))
+}
+
+// IncrementWebhookTimeout records a request the webhook didn't answer within
+// its latency budget
+func IncrementWebhookTimeout(webhook string) {
+	MetricWebhookTimeouts.With(prometheus.Labels{"webhook": webhook}).Inc()
+}
+
+// IncrementWebhookShed records a request shed because the webhook was
+// overloaded
+func IncrementWebhookShed(webhook string) {
+	MetricWebhookShed.With(prometheus.Labels{"webhook": webhook}).Inc()
+}
+
+// IncrementWebhookBackpressure records a request allowed without evaluation
+// because the API server was throttling
+func IncrementWebhookBackpressure(webhook string) {
+	MetricWebhookBackpressure.With(prometheus.Labels{"webhook": webhook}).Inc()
+}
+
+// IncrementWebhookDecisionCacheHit records a request answered from the
+// decision cache
+func IncrementWebhookDecisionCacheHit(webhook string) {
+	MetricWebhookDecisionCacheHits.With(prometheus.Labels{"webhook": webhook}).Inc()
+}
+
+// IncrementAuditFailure records an audit record which the sink failed to write
+func IncrementAuditFailure(sink string) {
+	MetricAuditFailures.With(prometheus.Labels{"sink": sink}).Inc()
+}
+
+// IncrementNotificationFailure records a notification which the notifier
+// failed to send
+func IncrementNotificationFailure(notifier string) {
+	MetricNotificationFailures.With(prometheus.Labels{"notifier": notifier}).Inc()
+}
+
+// IncrementEventFailure records an Event which could not be created
+func IncrementEventFailure(reason string) {
+	MetricEventFailures.With(prometheus.Labels{"reason": reason}).Inc()
+}
+
+// IncrementConfigurationDrift records a field of the webhook's configuration
+// which had drifted from its generated state
+func IncrementConfigurationDrift(webhook, field string) {
+	MetricConfigurationDrift.With(prometheus.Labels{"webhook": webhook, "field": field}).Inc()
+}
+
+// IncrementRegistryRevert records a workload restarted to revert rewritten
+// images
+func IncrementRegistryRevert(kind string) {
+	MetricRegistryReverts.With(prometheus.Labels{"kind": kind}).Inc()
+}
+
+// SetLeader records whether the replica is the elected leader
+func SetLeader(leading bool) {
+	if leading {
+		MetricLeader.Set(1)
+	} else {
+		MetricLeader.Set(0)
+	}
+}
+
+// ObserveServingCert records the expiry of a newly loaded serving certificate
+func ObserveServingCert(cert tls.Certificate) {
+	leaf := cert.Leaf
+	if leaf == nil {
+		if len(cert.Certificate) == 0 {
+			return
+		}
+		var err error
+		if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return
+		}
+	}
+	MetricServingCertExpiry.Set(float64(leaf.NotAfter.Unix()))
+}
+
+// ObserveWebhookResponse records the outcome, latency and patch size of a
+// webhook's response to an admission request
+func ObserveWebhookResponse(webhook string, request admissionctl.Request, response admissionctl.Response, duration time.Duration) {
+	outcome := ResponseOutcome(response)
+	MetricWebhookRequests.With(prometheus.Labels{
+		"webhook":   webhook,
+		"operation": string(request.Operation),
+		"outcome":   outcome,
+	}).Inc()
+	MetricWebhookRequestDuration.With(prometheus.Labels{"webhook": webhook}).Observe(duration.Seconds())
+	if outcome == OutcomeMutated {
+		MetricWebhookPatchSize.With(prometheus.Labels{"webhook": webhook}).Observe(float64(patchSize(response)))
+	}
+}
+
+// ResponseOutcome classifies an admission response as allowed, denied,
+// mutated or errored
+func ResponseOutcome(response admissionctl.Response) string {
+	if response.Allowed {
+		if len(response.Patch) > 0 || len(response.Patches) > 0 {
+			return OutcomeMutated
+		}
+		return OutcomeAllowed
+	}
+	if response.Result != nil && response.Result.Code == http.StatusForbidden {
+		return OutcomeDenied
+	}
+	return OutcomeErrored
+}
+
+// patchSize returns the size in bytes of the response's JSONPatch, which is
+// only serialized into response.Patch once the response is completed
+func patchSize(response admissionctl.Response) int {
+	if len(response.Patch) > 0 {
+		return len(response.Patch)
+	}
+	b, err := json.Marshal(response.Patches)
+	if err != nil {
+		return 0
+	}
+	return len(b)
+}
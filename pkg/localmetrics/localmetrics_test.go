@@ -0,0 +1,100 @@
+package localmetrics
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gomodules.xyz/jsonpatch/v2"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestResponseOutcome(t *testing.T) {
+	tests := []struct {
+		name     string
+		response admissionctl.Response
+		expected string
+	}{
+		{
+			name:     "allowed",
+			response: admissionctl.Allowed("ok"),
+			expected: OutcomeAllowed,
+		},
+		{
+			name:     "denied",
+			response: admissionctl.Denied("no"),
+			expected: OutcomeDenied,
+		},
+		{
+			name:     "patched",
+			response: admissionctl.Patched("mutated", jsonpatch.NewOperation("add", "/metadata/labels", map[string]string{"a": "b"})),
+			expected: OutcomeMutated,
+		},
+		{
+			name:     "errored",
+			response: admissionctl.Errored(http.StatusBadRequest, errors.New("bad request")),
+			expected: OutcomeErrored,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := ResponseOutcome(test.response); actual != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestObserveWebhookResponse(t *testing.T) {
+	request := admissionctl.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Create,
+		},
+	}
+	ObserveWebhookResponse("test-validation", request, admissionctl.Denied("no"), time.Millisecond)
+	ObserveWebhookResponse("test-mutation", request, admissionctl.Patched("mutated", jsonpatch.NewOperation("add", "/metadata/labels", map[string]string{"a": "b"})), time.Millisecond)
+
+	if actual := testutil.ToFloat64(MetricWebhookRequests.WithLabelValues("test-validation", "CREATE", OutcomeDenied)); actual != 1 {
+		t.Errorf("expected 1 denied request, got %v", actual)
+	}
+	if actual := testutil.ToFloat64(MetricWebhookRequests.WithLabelValues("test-mutation", "CREATE", OutcomeMutated)); actual != 1 {
+		t.Errorf("expected 1 mutated request, got %v", actual)
+	}
+	if actual := testutil.CollectAndCount(MetricWebhookPatchSize); actual != 1 {
+		t.Errorf("expected patch size to be observed for the mutating webhook only, got %d series", actual)
+	}
+}
+
+func TestObserveServingCert(t *testing.T) {
+	notAfter := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "validation-webhook"},
+		NotBefore:    notAfter.Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+
+	ObserveServingCert(tls.Certificate{Certificate: [][]byte{der}})
+	if actual := testutil.ToFloat64(MetricServingCertExpiry); actual != float64(notAfter.Unix()) {
+		t.Errorf("expected expiry %d, got %v", notAfter.Unix(), actual)
+	}
+}
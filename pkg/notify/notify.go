@@ -0,0 +1,230 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"golang.org/x/time/rate"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/audit"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/localmetrics"
+)
+
+const (
+	// EndpointEnvVar is the HTTPS URL notifications are POSTed to, eg a Slack
+	// incoming webhook or a PagerDuty events endpoint. Notifications are
+	// disabled unless it's set.
+	EndpointEnvVar = "NOTIFY_ENDPOINT"
+	// EventsEnvVar is a comma-separated list of the denials SRE are notified
+	// of, by webhook name or denial code. Defaults to defaultEvents.
+	EventsEnvVar = "NOTIFY_EVENTS"
+	// TemplateEnvVar is the text/template rendering the payload from the
+	// audit.Record of the denial. Defaults to defaultTemplate.
+	TemplateEnvVar = "NOTIFY_TEMPLATE"
+	// RatePerMinuteEnvVar is how many notifications may be sent per minute.
+	// Defaults to defaultRatePerMinute.
+	RatePerMinuteEnvVar = "NOTIFY_RATE_PER_MINUTE"
+
+	// defaultEvents are the denials of attempts to remove SRE's access or to
+	// disrupt etcd
+	defaultEvents = "SREAccess,EtcdProtected"
+	// defaultTemplate is a Slack message
+	defaultTemplate = `{"text": {{ printf "%s denied %s of %s %s by %s: %s" .Webhook .Operation .Kind (qualified .Namespace .Name) .User .Reason | json }}}`
+
+	defaultRatePerMinute = 10
+
+	// queueSize is how many notifications may wait for the notifiers before
+	// new ones are dropped rather than holding up admission requests
+	queueSize = 100
+
+	httpTimeout = 5 * time.Second
+)
+
+var log = logf.Log.WithName("notify")
+
+// Notifier tells SRE about a denial
+type Notifier interface {
+	// Name identifies the notifier in logs and metrics
+	Name() string
+	// Notify sends the notification of the denial r
+	Notify(ctx context.Context, r audit.Record) error
+}
+
+// Broker hands the denials SRE are notified of to the notifiers in the
+// background, so slow notifiers don't hold up admission requests
+type Broker struct {
+	notifiers []Notifier
+	events    map[string]bool // webhook names and denial codes
+	limiter   *rate.Limiter
+	records   chan audit.Record
+}
+
+// NewBroker creates a Broker sending at most perMinute notifications of the
+// events, which are webhook names or denial codes, to the notifiers
+func NewBroker(events []string, perMinute int, notifiers ...Notifier) *Broker {
+	b := &Broker{
+		notifiers: notifiers,
+		events:    map[string]bool{},
+		limiter:   rate.NewLimiter(rate.Limit(float64(perMinute)/60), perMinute),
+		records:   make(chan audit.Record, queueSize),
+	}
+	for _, event := range events {
+		if event = strings.TrimSpace(event); event != "" {
+			b.events[event] = true
+		}
+	}
+	return b
+}
+
+// Start sends queued notifications until ctx is cancelled
+func (b *Broker) Start(ctx context.Context) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case r := <-b.records:
+				b.notify(ctx, r)
+			}
+		}
+	}()
+}
+
+func (b *Broker) notify(ctx context.Context, r audit.Record) {
+	for _, n := range b.notifiers {
+		if err := n.Notify(ctx, r); err != nil {
+			localmetrics.IncrementNotificationFailure(n.Name())
+			log.Error(err, "Failed to send notification", "notifier", n.Name(), "webhookName", r.Webhook, "uid", r.UID)
+		}
+	}
+}
+
+// Denied queues the notification of a denial, if SRE are notified of it.
+// Denials over the rate limit, or when the queue is full, are dropped. A nil
+// Broker notifies nothing.
+func (b *Broker) Denied(r audit.Record) {
+	if b == nil || !(b.events[r.Webhook] || b.events[r.Code]) {
+		return
+	}
+	if !b.limiter.Allow() {
+		localmetrics.IncrementNotificationFailure("ratelimit")
+		log.Info("Notification rate limit exceeded, dropping notification", "webhookName", r.Webhook, "uid", r.UID)
+		return
+	}
+	select {
+	case b.records <- r:
+	default:
+		localmetrics.IncrementNotificationFailure("queue")
+		log.Info("Notification queue is full, dropping notification", "webhookName", r.Webhook, "uid", r.UID)
+	}
+}
+
+// HTTPNotifier POSTs the payload its template renders from each denial to an
+// HTTPS endpoint
+type HTTPNotifier struct {
+	endpoint string
+	payload  *template.Template
+	client   *http.Client
+}
+
+// NewHTTPNotifier creates an HTTPNotifier. The endpoint must be an HTTPS URL,
+// as it usually carries a token. The payload template may use the json
+// function to quote strings for JSON payloads.
+func NewHTTPNotifier(endpoint, payload string) (*HTTPNotifier, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid notification endpoint: %w", err)
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("the notification endpoint must be an https URL")
+	}
+	tmpl, err := template.New("payload").Funcs(template.FuncMap{
+		"json":      quote,
+		"qualified": qualified,
+	}).Parse(payload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid notification template: %w", err)
+	}
+	return &HTTPNotifier{
+		endpoint: endpoint,
+		payload:  tmpl,
+		client:   &http.Client{Timeout: httpTimeout},
+	}, nil
+}
+
+// Name implements Notifier interface
+func (n *HTTPNotifier) Name() string { return "http" }
+
+// Notify implements Notifier interface
+func (n *HTTPNotifier) Notify(ctx context.Context, r audit.Record) error {
+	body := &bytes.Buffer{}
+	if err := n.payload.Execute(body, r); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.endpoint, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("notification endpoint returned %s", resp.Status)
+	}
+	return nil
+}
+
+// quote returns v as JSON
+func quote(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// qualified returns namespace/name, or name for cluster-scoped objects
+func qualified(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "/" + name
+}
+
+// FromEnv builds the Broker configured by the environment. It returns nil
+// when notifications aren't enabled.
+func FromEnv() (*Broker, error) {
+	endpoint := os.Getenv(EndpointEnvVar)
+	if endpoint == "" {
+		return nil, nil
+	}
+	payload := os.Getenv(TemplateEnvVar)
+	if payload == "" {
+		payload = defaultTemplate
+	}
+	n, err := NewHTTPNotifier(endpoint, payload)
+	if err != nil {
+		return nil, err
+	}
+	events := os.Getenv(EventsEnvVar)
+	if strings.TrimSpace(events) == "" {
+		events = defaultEvents
+	}
+	perMinute := defaultRatePerMinute
+	if value := os.Getenv(RatePerMinuteEnvVar); value != "" {
+		if perMinute, err = strconv.Atoi(value); err != nil || perMinute < 1 {
+			return nil, fmt.Errorf("invalid %s %q", RatePerMinuteEnvVar, value)
+		}
+	}
+	return NewBroker(strings.Split(events, ","), perMinute, n), nil
+}
@@ -0,0 +1,139 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/audit"
+)
+
+type recordingNotifier struct {
+	mu      sync.Mutex
+	records []audit.Record
+}
+
+func (n *recordingNotifier) Name() string { return "recording" }
+
+func (n *recordingNotifier) Notify(_ context.Context, r audit.Record) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.records = append(n.records, r)
+	return nil
+}
+
+func (n *recordingNotifier) count() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return len(n.records)
+}
+
+func TestBrokerFiltersDenials(t *testing.T) {
+	n := &recordingNotifier{}
+	b := NewBroker([]string{"SREAccess", " etcd-validation "}, 10, n)
+
+	b.Denied(audit.Record{Webhook: "clusterrolebinding-validation", Code: "SREAccess"})
+	b.Denied(audit.Record{Webhook: "etcd-validation", Code: "ManagedResource"})
+	b.Denied(audit.Record{Webhook: "namespace-validation", Code: "ManagedNamespace"})
+	if len(b.records) != 2 {
+		t.Fatalf("Expected 2 queued notifications, got %d", len(b.records))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b.Start(ctx)
+	deadline := time.Now().Add(5 * time.Second)
+	for n.count() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n.count() != 2 {
+		t.Fatalf("Expected 2 notifications to be sent, got %d", n.count())
+	}
+}
+
+func TestBrokerRateLimit(t *testing.T) {
+	b := NewBroker([]string{"SREAccess"}, 3, &recordingNotifier{})
+	for i := 0; i < 5; i++ {
+		b.Denied(audit.Record{Code: "SREAccess"})
+	}
+	if len(b.records) != 3 {
+		t.Fatalf("Expected the rate limit to allow a burst of 3 notifications, got %d", len(b.records))
+	}
+}
+
+func TestNilBroker(t *testing.T) {
+	var b *Broker
+	b.Denied(audit.Record{Code: "SREAccess"})
+}
+
+func TestHTTPNotifier(t *testing.T) {
+	var body []byte
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Expected a JSON payload, got %s", r.Header.Get("Content-Type"))
+		}
+	}))
+	defer server.Close()
+
+	n, err := NewHTTPNotifier(server.URL, defaultTemplate)
+	if err != nil {
+		t.Fatalf("Unexpected error creating the notifier: %v", err)
+	}
+	n.client = server.Client()
+	r := audit.Record{
+		Webhook:   "etcd-validation",
+		Operation: "DELETE",
+		Kind:      "Pod",
+		Namespace: "openshift-etcd",
+		Name:      "etcd-master-0",
+		User:      "customer",
+		Reason:    `Prevented from deleting "etcd" pods`,
+	}
+	if err := n.Notify(context.Background(), r); err != nil {
+		t.Fatalf("Unexpected error notifying: %v", err)
+	}
+
+	payload := map[string]string{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("Expected a JSON payload, got %s", body)
+	}
+	expected := `etcd-validation denied DELETE of Pod openshift-etcd/etcd-master-0 by customer: Prevented from deleting "etcd" pods`
+	if payload["text"] != expected {
+		t.Errorf("Expected text %q, got %q", expected, payload["text"])
+	}
+}
+
+func TestNewHTTPNotifierRequiresHTTPS(t *testing.T) {
+	if _, err := NewHTTPNotifier("http://hooks.example.com/notify", defaultTemplate); err == nil {
+		t.Errorf("Expected an error for an http endpoint")
+	}
+	if _, err := NewHTTPNotifier("https://hooks.example.com/notify", "{{ .Nope"); err == nil {
+		t.Errorf("Expected an error for an invalid template")
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	b, err := FromEnv()
+	if err != nil || b != nil {
+		t.Fatalf("Expected no Broker without an endpoint, got %v, %v", b, err)
+	}
+
+	t.Setenv(EndpointEnvVar, "https://hooks.example.com/notify")
+	if b, err = FromEnv(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !b.events["SREAccess"] || !b.events["EtcdProtected"] {
+		t.Errorf("Expected the default events, got %v", b.events)
+	}
+
+	t.Setenv(RatePerMinuteEnvVar, "0")
+	if _, err = FromEnv(); err == nil {
+		t.Errorf("Expected an error for an invalid rate")
+	}
+}
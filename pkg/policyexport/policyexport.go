@@ -0,0 +1,277 @@
+// Package policyexport translates the CEL validations of PolicyWebhooks into
+// the policies of Gatekeeper and Kyverno, so clusters which don't run the
+// webhooks, such as customers' pre-production clusters, can enforce the same
+// rules with the policy engine they already run. Both engines evaluate the
+// validations as the API server does a ValidatingAdmissionPolicy: Gatekeeper
+// with its K8sNativeValidation engine, and Kyverno with CEL validate rules.
+package policyexport
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+)
+
+const (
+	// gatekeeperTarget is the target of Gatekeeper's admission constraints
+	gatekeeperTarget = "admission.k8s.gatekeeper.sh"
+	// gatekeeperEngine is the engine of Gatekeeper evaluating CEL
+	gatekeeperEngine = "K8sNativeValidation"
+)
+
+// Gatekeeper returns the ConstraintTemplate holding the webhook's validations
+// and the Constraint enforcing it on the kinds the webhook matches. Gatekeeper
+// only sends DELETE requests to constraints when its webhook is configured to,
+// so the operations are checked in the template's match conditions.
+func Gatekeeper(hook webhooks.Webhook, validations []admissionregv1.Validation, scheme *runtime.Scheme) ([]interface{}, error) {
+	kinds, err := matchedKinds(hook.Rules(), scheme)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't export %s: %w", hook.Name(), err)
+	}
+	kind := constraintKind(hook.Name())
+
+	source := map[string]interface{}{
+		"validations": celValidations(validations),
+	}
+	if operations := operations(hook.Rules()); operations != nil {
+		source["matchConditions"] = []interface{}{
+			map[string]interface{}{
+				"name":       "operations",
+				"expression": fmt.Sprintf("request.operation in %s", utils.CELStringList(operations)),
+			},
+		}
+	}
+	template := map[string]interface{}{
+		"apiVersion": "templates.gatekeeper.sh/v1",
+		"kind":       "ConstraintTemplate",
+		"metadata": map[string]interface{}{
+			"name":        strings.ToLower(kind),
+			"annotations": description(hook),
+		},
+		"spec": map[string]interface{}{
+			"crd": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"names": map[string]interface{}{"kind": kind},
+				},
+			},
+			"targets": []interface{}{
+				map[string]interface{}{
+					"target": gatekeeperTarget,
+					"code": []interface{}{
+						map[string]interface{}{
+							"engine": gatekeeperEngine,
+							"source": source,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	match := map[string]interface{}{}
+	kindsByGroup := map[string][]string{}
+	for _, gk := range kinds {
+		kindsByGroup[gk.Group] = append(kindsByGroup[gk.Group], gk.Kind)
+	}
+	matchKinds := []interface{}{}
+	for _, group := range sortedKeys(kindsByGroup) {
+		matchKinds = append(matchKinds, map[string]interface{}{
+			"apiGroups": []interface{}{group},
+			"kinds":     toInterfaces(kindsByGroup[group]),
+		})
+	}
+	match["kinds"] = matchKinds
+	if selector := hook.ObjectSelector(); selector != nil {
+		match["labelSelector"] = selector
+	}
+	constraint := map[string]interface{}{
+		"apiVersion": "constraints.gatekeeper.sh/v1beta1",
+		"kind":       kind,
+		"metadata": map[string]interface{}{
+			"name": policyName(hook.Name()),
+		},
+		"spec": map[string]interface{}{
+			"enforcementAction": "deny",
+			"match":             match,
+		},
+	}
+	return []interface{}{template, constraint}, nil
+}
+
+// Kyverno returns the ClusterPolicy enforcing the webhook's validations on the
+// requests it matches. It isn't applied to existing resources in the
+// background, as the validations may read the request, which only admission
+// has.
+func Kyverno(hook webhooks.Webhook, validations []admissionregv1.Validation, scheme *runtime.Scheme) (interface{}, error) {
+	kinds, err := matchedKinds(hook.Rules(), scheme)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't export %s: %w", hook.Name(), err)
+	}
+	names := []interface{}{}
+	for _, gk := range kinds {
+		if gk.Group == "" {
+			names = append(names, gk.Kind)
+		} else {
+			names = append(names, gk.Group+"/*/"+gk.Kind)
+		}
+	}
+	resources := map[string]interface{}{"kinds": names}
+	if operations := operations(hook.Rules()); operations != nil {
+		resources["operations"] = toInterfaces(operations)
+	}
+	if selector := hook.ObjectSelector(); selector != nil {
+		resources["selector"] = selector
+	}
+
+	return map[string]interface{}{
+		"apiVersion": "kyverno.io/v1",
+		"kind":       "ClusterPolicy",
+		"metadata": map[string]interface{}{
+			"name":        policyName(hook.Name()),
+			"annotations": description(hook),
+		},
+		"spec": map[string]interface{}{
+			"validationFailureAction": "Enforce",
+			"background":              false,
+			"rules": []interface{}{
+				map[string]interface{}{
+					"name": hook.Name(),
+					"match": map[string]interface{}{
+						"any": []interface{}{
+							map[string]interface{}{"resources": resources},
+						},
+					},
+					"validate": map[string]interface{}{
+						"cel": map[string]interface{}{
+							"expressions": celValidations(validations),
+						},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+// matchedKinds returns the kinds of the resources the rules match, sorted.
+// Rules matching every group or resource, or subresources, can't be
+// translated, as the policy engines match kinds.
+func matchedKinds(rules []admissionregv1.RuleWithOperations, scheme *runtime.Scheme) ([]schema.GroupKind, error) {
+	kinds := []schema.GroupKind{}
+	for _, rule := range rules {
+		for _, group := range rule.APIGroups {
+			for _, resource := range rule.Resources {
+				if group == "*" || resource == "*" || strings.Contains(resource, "/") {
+					return nil, fmt.Errorf("resource %q of group %q isn't a single resource", resource, group)
+				}
+				gk, ok := kindOf(schema.GroupResource{Group: group, Resource: resource}, scheme)
+				if !ok {
+					return nil, fmt.Errorf("the kind of resource %q of group %q isn't known", resource, group)
+				}
+				if !slices.Contains(kinds, gk) {
+					kinds = append(kinds, gk)
+				}
+			}
+		}
+	}
+	sort.Slice(kinds, func(i, j int) bool {
+		if kinds[i].Group != kinds[j].Group {
+			return kinds[i].Group < kinds[j].Group
+		}
+		return kinds[i].Kind < kinds[j].Kind
+	})
+	return kinds, nil
+}
+
+// kindOf returns the kind of the resource among the scheme's kinds
+func kindOf(gr schema.GroupResource, scheme *runtime.Scheme) (schema.GroupKind, bool) {
+	for gvk := range scheme.AllKnownTypes() {
+		if gvk.Group != gr.Group || strings.HasSuffix(gvk.Kind, "List") {
+			continue
+		}
+		if plural, _ := meta.UnsafeGuessKindToResource(gvk); plural.Resource == gr.Resource {
+			return gvk.GroupKind(), true
+		}
+	}
+	return schema.GroupKind{}, false
+}
+
+// operations returns the operations the rules match, or nil if they match
+// every operation
+func operations(rules []admissionregv1.RuleWithOperations) []string {
+	ops := []string{}
+	for _, rule := range rules {
+		for _, op := range rule.Operations {
+			if op == admissionregv1.OperationAll {
+				return nil
+			}
+			if !slices.Contains(ops, string(op)) {
+				ops = append(ops, string(op))
+			}
+		}
+	}
+	return ops
+}
+
+func celValidations(validations []admissionregv1.Validation) []interface{} {
+	translated := make([]interface{}, 0, len(validations))
+	for _, v := range validations {
+		translated = append(translated, map[string]interface{}{
+			"expression": v.Expression,
+			"message":    v.Message,
+		})
+	}
+	return translated
+}
+
+// description annotates the policy with the webhook's documentation, so
+// whoever reads the policy knows what it's mirroring
+func description(hook webhooks.Webhook) map[string]interface{} {
+	return map[string]interface{}{
+		"managed.openshift.io/webhook":     hook.Name(),
+		"managed.openshift.io/description": hook.Doc(),
+	}
+}
+
+// policyName is the name of the webhook's policies, the name of its
+// ValidatingAdmissionPolicy
+func policyName(name string) string {
+	return fmt.Sprintf("sre-%s", name)
+}
+
+// constraintKind is the kind of the webhook's Gatekeeper constraint, eg
+// SreHiveownershipValidation
+func constraintKind(name string) string {
+	kind := ""
+	for _, part := range strings.Split(policyName(name), "-") {
+		if part != "" {
+			kind += strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	return kind
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func toInterfaces(values []string) []interface{} {
+	s := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		s = append(s, v)
+	}
+	return s
+}
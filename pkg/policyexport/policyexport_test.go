@@ -0,0 +1,148 @@
+package policyexport
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/hiveownership"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/techpreviewnoupgrade"
+)
+
+// render marshals the resources as YAML, as the bundles are written
+func render(t *testing.T, resources ...interface{}) string {
+	t.Helper()
+	rendered := ""
+	for _, resource := range resources {
+		y, err := yaml.Marshal(resource)
+		if err != nil {
+			t.Fatalf("Couldn't marshal: %s", err.Error())
+		}
+		rendered += "---\n" + string(y)
+	}
+	return rendered
+}
+
+func policyHook(t *testing.T, hook webhooks.Webhook) (webhooks.Webhook, []admissionregv1.Validation) {
+	t.Helper()
+	policy, ok := hook.(webhooks.PolicyWebhook)
+	if !ok {
+		t.Fatalf("Expected %s to be a PolicyWebhook", hook.Name())
+	}
+	return hook, policy.Validations()
+}
+
+func TestGatekeeper(t *testing.T) {
+	scheme, err := k8sutil.SharedScheme()
+	if err != nil {
+		t.Fatalf("Couldn't build the scheme: %s", err.Error())
+	}
+	hook, validations := policyHook(t, hiveownership.NewWebhook())
+	resources, err := Gatekeeper(hook, validations, scheme)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	if len(resources) != 2 {
+		t.Fatalf("Expected a ConstraintTemplate and a Constraint, got %d resources", len(resources))
+	}
+
+	rendered := render(t, resources...)
+	for _, expected := range []string{
+		"kind: ConstraintTemplate",
+		"name: srehiveownershipvalidation",
+		"kind: SreHiveownershipValidation",
+		"engine: K8sNativeValidation",
+		`expression: request.operation in ["UPDATE", "DELETE"]`,
+		"expression: request.userInfo.username in",
+		"name: sre-hiveownership-validation",
+		"enforcementAction: deny",
+		"- quota.openshift.io\n      kinds:\n      - ClusterResourceQuota",
+		"hive.openshift.io/managed: \"true\"",
+		"managed.openshift.io/webhook: hiveownership-validation",
+	} {
+		if !strings.Contains(rendered, expected) {
+			t.Errorf("Expected the Gatekeeper resources to contain %q, got\n%s", expected, rendered)
+		}
+	}
+}
+
+func TestKyverno(t *testing.T) {
+	scheme, err := k8sutil.SharedScheme()
+	if err != nil {
+		t.Fatalf("Couldn't build the scheme: %s", err.Error())
+	}
+	hook, validations := policyHook(t, techpreviewnoupgrade.NewWebhook())
+	policy, err := Kyverno(hook, validations, scheme)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+
+	rendered := render(t, policy)
+	for _, expected := range []string{
+		"kind: ClusterPolicy",
+		"name: sre-techpreviewnoupgrade-validation",
+		"validationFailureAction: Enforce",
+		"background: false",
+		"- config.openshift.io/*/FeatureGate",
+		"operations:\n          - CREATE\n          - UPDATE",
+		"TechPreviewNoUpgrade",
+		"message: The TechPreviewNoUpgrade Feature Gate is not allowed",
+	} {
+		if !strings.Contains(rendered, expected) {
+			t.Errorf("Expected the Kyverno policy to contain %q, got\n%s", expected, rendered)
+		}
+	}
+	if strings.Contains(rendered, "selector") {
+		t.Errorf("Expected no selector for a webhook without an object selector, got\n%s", rendered)
+	}
+}
+
+func TestMatchedKinds(t *testing.T) {
+	scheme, err := k8sutil.SharedScheme()
+	if err != nil {
+		t.Fatalf("Couldn't build the scheme: %s", err.Error())
+	}
+	rule := func(group string, resources ...string) admissionregv1.RuleWithOperations {
+		return admissionregv1.RuleWithOperations{
+			Operations: []admissionregv1.OperationType{admissionregv1.Create},
+			Rule:       admissionregv1.Rule{APIGroups: []string{group}, APIVersions: []string{"*"}, Resources: resources},
+		}
+	}
+
+	kinds, err := matchedKinds([]admissionregv1.RuleWithOperations{rule("apps", "deployments", "statefulsets"), rule("", "pods", "configmaps")}, scheme)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	got := []string{}
+	for _, gk := range kinds {
+		got = append(got, gk.String())
+	}
+	if strings.Join(got, ",") != "ConfigMap,Pod,Deployment.apps,StatefulSet.apps" {
+		t.Errorf("Expected the kinds sorted by group, got %v", got)
+	}
+
+	for _, rules := range [][]admissionregv1.RuleWithOperations{
+		{rule("*", "pods")},
+		{rule("", "*")},
+		{rule("", "pods/exec")},
+		{rule("example.com", "widgets")},
+	} {
+		if _, err := matchedKinds(rules, scheme); err == nil {
+			t.Errorf("Expected an error translating %v", rules)
+		}
+	}
+
+	if ops := operations([]admissionregv1.RuleWithOperations{{Operations: []admissionregv1.OperationType{admissionregv1.OperationAll}}}); ops != nil {
+		t.Errorf("Expected every operation to need no condition, got %v", ops)
+	}
+}
+
+func TestConstraintKind(t *testing.T) {
+	if kind := constraintKind("hiveownership-validation"); kind != "SreHiveownershipValidation" {
+		t.Errorf("Expected SreHiveownershipValidation, got %s", kind)
+	}
+}
@@ -0,0 +1,144 @@
+// Package profiling serves the pprof endpoints of the webhook server and
+// writes heap profiles on demand, so SRE can debug the memory and goroutines
+// of the long-running server without restarting it.
+package profiling
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	runtimepprof "runtime/pprof"
+	"strings"
+	"syscall"
+	"time"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// TokenEnvVar is the bearer token required to reach the pprof endpoints.
+	// Without it, they're only served on a loopback address.
+	TokenEnvVar string = "WEBHOOK_PPROF_TOKEN"
+
+	// readHeaderTimeout bounds how long the pprof server waits for request
+	// headers. Profiles themselves may take longer to collect.
+	readHeaderTimeout = 10 * time.Second
+)
+
+var log = logf.Log.WithName("profiling")
+
+// Server serves the pprof endpoints
+type Server struct {
+	addr  string
+	token string
+}
+
+// NewServer creates a Server listening on addr. Requests must carry the token
+// as a bearer token unless it's empty, in which case addr must be a loopback
+// address so the endpoints can only be reached from within the pod, eg with
+// oc port-forward.
+func NewServer(addr, token string) (*Server, error) {
+	if token == "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			return nil, fmt.Errorf("pprof endpoints on %s must be protected by %s unless they're served on a loopback address", addr, TokenEnvVar)
+		}
+	}
+	return &Server{addr: addr, token: token}, nil
+}
+
+// Handler serves the pprof endpoints under /debug/pprof/, including cpu
+// (profile), heap and goroutine
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	if s.token == "" {
+		return mux
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// Start serves the pprof endpoints until ctx is done
+func (s *Server) Start(ctx context.Context) {
+	server := &http.Server{
+		Addr:              s.addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+	go func() {
+		log.Info("Serving pprof endpoints", "address", s.addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error(err, "pprof server failed")
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		if err := server.Close(); err != nil {
+			log.Error(err, "Failed to stop pprof server")
+		}
+	}()
+}
+
+// DumpHeapOnSignal writes a heap profile to dir each time the process
+// receives SIGUSR1, until ctx is done. Profiles can then be copied out of the
+// pod, eg with oc cp.
+func DumpHeapOnSignal(ctx context.Context, dir string) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-signals:
+				path, err := WriteHeapProfile(dir)
+				if err != nil {
+					log.Error(err, "Failed to write heap profile", "dir", dir)
+					continue
+				}
+				log.Info("Wrote heap profile", "path", path)
+			}
+		}
+	}()
+}
+
+// WriteHeapProfile writes a heap profile, as of the last garbage collection,
+// to a new timestamped file in dir and returns its path
+func WriteHeapProfile(dir string) (string, error) {
+	path := filepath.Join(dir, fmt.Sprintf("heap-%s.pprof", time.Now().UTC().Format("20060102T150405.000000000")))
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	// collect garbage first so the profile shows what's still in use
+	runtime.GC()
+	if err := runtimepprof.WriteHeapProfile(f); err != nil {
+		return "", err
+	}
+	return path, f.Close()
+}
@@ -0,0 +1,93 @@
+package profiling
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestNewServer(t *testing.T) {
+	tests := []struct {
+		addr    string
+		token   string
+		wantErr bool
+	}{
+		{addr: "127.0.0.1:6060"},
+		{addr: "localhost:6060"},
+		{addr: "[::1]:6060"},
+		{addr: "0.0.0.0:6060", wantErr: true},
+		{addr: ":6060", wantErr: true},
+		{addr: "0.0.0.0:6060", token: "secret"},
+		{addr: "not-an-address", wantErr: true},
+	}
+
+	for _, test := range tests {
+		_, err := NewServer(test.addr, test.token)
+		if (err != nil) != test.wantErr {
+			t.Errorf("NewServer(%q, %q): expected error %t, got %v", test.addr, test.token, test.wantErr, err)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		status        int
+	}{
+		{name: "loopback without token", status: http.StatusOK},
+		{name: "valid token", token: "secret", authorization: "Bearer secret", status: http.StatusOK},
+		{name: "wrong token", token: "secret", authorization: "Bearer wrong", status: http.StatusUnauthorized},
+		{name: "missing token", token: "secret", status: http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := NewServer("127.0.0.1:6060", test.token)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			r := httptest.NewRequest(http.MethodGet, "/debug/pprof/goroutine?debug=1", nil)
+			if test.authorization != "" {
+				r.Header.Set("Authorization", test.authorization)
+			}
+			w := httptest.NewRecorder()
+			s.Handler().ServeHTTP(w, r)
+			if w.Code != test.status {
+				t.Errorf("Expected status %d, got %d", test.status, w.Code)
+			}
+		})
+	}
+}
+
+func TestDumpHeapOnSignal(t *testing.T) {
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	DumpHeapOnSignal(ctx, dir)
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatalf("Unexpected error signalling: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		profiles, err := filepath.Glob(filepath.Join(dir, "heap-*.pprof"))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(profiles) == 1 {
+			info, err := os.Stat(profiles[0])
+			if err == nil && info.Size() > 0 {
+				return
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for a heap profile in %s", dir)
+}
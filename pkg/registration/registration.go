@@ -0,0 +1,188 @@
+// Package registration lets the webhook server register its webhooks itself.
+// When the SelectorSyncSet creates the Validating and
+// MutatingWebhookConfigurations, the API server calls the webhooks as soon as
+// they're synced, whether a replica is ready to answer or not, which fails the
+// requests of webhooks failing closed. The Registrar only creates the
+// configurations once its replica is ready, keeps them registered while it
+// serves, and removes them when the last ready replica shuts down gracefully.
+package registration
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/config"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/drift"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks"
+)
+
+const (
+	// retryPeriod is how often readiness is checked, and registering retried
+	retryPeriod = 5 * time.Second
+	// resyncPeriod is how often a serving replica registers the webhooks
+	// again, in case a replica shutting down deregistered them as this one
+	// became ready
+	resyncPeriod = 30 * time.Second
+)
+
+var log = logf.Log.WithName("registration")
+
+// Registrar registers the configurations of the webhooks enabled on Classic
+// clusters once the server is ready, and deregisters them when the last
+// replica shuts down
+type Registrar struct {
+	client    client.Client
+	hooks     webhooks.RegisteredWebhooks
+	namespace string
+	// ready returns nil once the server is ready to answer admission requests
+	ready func(context.Context) error
+	// detector creates and repairs the configurations as they are generated
+	detector *drift.Detector
+	// podName is the name of this replica's pod, left out when counting the
+	// replicas still serving
+	podName string
+	// retryPeriod is how often readiness is checked, and registering retried
+	retryPeriod time.Duration
+	// resyncPeriod is how often the webhooks are registered again while the
+	// server serves
+	resyncPeriod time.Duration
+}
+
+// NewRegistrar creates a Registrar for the configurations of the webhooks
+// served in namespace. ready reports whether the server is ready, and caFile
+// holds the CA bundle the configurations carry, as it does for the drift
+// detector.
+func NewRegistrar(c client.Client, hooks webhooks.RegisteredWebhooks, namespace, caFile string, ready func(context.Context) error) *Registrar {
+	// Pods are named after their hostname, as the leader election identity is
+	podName, err := os.Hostname()
+	if err != nil {
+		log.Error(err, "Failed to read hostname; this replica counts as another one serving on shutdown")
+	}
+	return &Registrar{
+		client:       c,
+		hooks:        hooks,
+		namespace:    namespace,
+		ready:        ready,
+		detector:     drift.NewDetector(c, hooks, namespace, caFile),
+		podName:      podName,
+		retryPeriod:  retryPeriod,
+		resyncPeriod: resyncPeriod,
+	}
+}
+
+// Register waits for the server to be ready and creates or repairs the
+// configurations, retrying until they're all registered or ctx is cancelled.
+// Every replica registers, as the configurations are only created once.
+func (r *Registrar) Register(ctx context.Context) error {
+	var notReady error
+	return wait.PollUntilContextCancel(ctx, r.retryPeriod, true, func(ctx context.Context) (bool, error) {
+		if err := r.ready(ctx); err != nil {
+			if notReady == nil || notReady.Error() != err.Error() {
+				log.Info("Waiting for the server to be ready before registering webhooks", "reason", err.Error())
+			}
+			notReady = err
+			return false, nil
+		}
+		if err := r.detector.Apply(ctx); err != nil {
+			log.Error(err, "Failed to register webhooks; retrying")
+			return false, nil
+		}
+		log.Info("Registered webhooks")
+		return true, nil
+	})
+}
+
+// Run registers the webhooks as Register does, then registers them again
+// every resyncPeriod while the server is ready, until ctx is cancelled. A
+// replica shutting down only sees the replicas already serving, so it may
+// deregister the webhooks just as another replica registered them; that
+// replica restores them the next time.
+func (r *Registrar) Run(ctx context.Context) error {
+	if err := r.Register(ctx); err != nil {
+		return err
+	}
+	wait.UntilWithContext(ctx, r.reconcile, r.resyncPeriod)
+	return nil
+}
+
+// reconcile creates or repairs the configurations when the server is ready
+func (r *Registrar) reconcile(ctx context.Context) {
+	if err := r.ready(ctx); err != nil {
+		return
+	}
+	if err := r.detector.Apply(ctx); err != nil {
+		log.Error(err, "Failed to keep webhooks registered; retrying")
+	}
+}
+
+// Deregister deletes the configurations when no other replica is ready to
+// answer admission requests, so the API server doesn't call webhooks which
+// aren't served anymore. It's called once the server is shutting down, with a
+// context outliving the signal's.
+func (r *Registrar) Deregister(ctx context.Context) error {
+	serving, err := r.servingReplicas(ctx)
+	if err != nil {
+		return err
+	}
+	if serving > 0 {
+		log.Info("Keeping webhooks registered for the replicas still serving", "replicas", serving)
+		return nil
+	}
+
+	errs := []error{}
+	for name, factory := range r.hooks {
+		hook := factory()
+		if !hook.ClassicEnabled() || len(hook.Rules()) == 0 {
+			continue
+		}
+		var configuration client.Object = &admissionregv1.ValidatingWebhookConfiguration{}
+		if webhooks.IsMutating(name) {
+			configuration = &admissionregv1.MutatingWebhookConfiguration{}
+		}
+		configuration.SetName(webhooks.ConfigurationName(name))
+		if err := r.client.Delete(ctx, configuration); err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	log.Info("Deregistered webhooks as the last replica shuts down")
+	return nil
+}
+
+// servingReplicas counts the other replicas whose endpoints of the webhook
+// Service are ready. Replicas shutting down are terminating, and aren't
+// counted, so replicas stopped together each deregister.
+func (r *Registrar) servingReplicas(ctx context.Context) (int, error) {
+	endpointSlices := &discoveryv1.EndpointSliceList{}
+	if err := r.client.List(ctx, endpointSlices, client.InNamespace(r.namespace), client.MatchingLabels{discoveryv1.LabelServiceName: config.OperatorName}); err != nil {
+		return 0, err
+	}
+	serving := 0
+	for _, slice := range endpointSlices.Items {
+		for _, endpoint := range slice.Endpoints {
+			if endpoint.TargetRef != nil && endpoint.TargetRef.Name == r.podName {
+				continue
+			}
+			// An unset condition means the endpoint is ready
+			if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+				continue
+			}
+			if endpoint.Conditions.Terminating != nil && *endpoint.Conditions.Terminating {
+				continue
+			}
+			serving++
+		}
+	}
+	return serving, nil
+}
@@ -0,0 +1,200 @@
+package registration
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/config"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/drift"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/debugpodtolerations"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/hiveownership"
+)
+
+const (
+	testNamespace = "openshift-validation-webhook"
+	testPod       = "validation-webhook-a"
+)
+
+var testHooks = webhooks.RegisteredWebhooks{
+	hiveownership.WebhookName:       func() webhooks.Webhook { return hiveownership.NewWebhook() },
+	debugpodtolerations.WebhookName: func() webhooks.Webhook { return debugpodtolerations.NewWebhook() },
+}
+
+func newRegistrar(c client.Client, ready func(context.Context) error) *Registrar {
+	return &Registrar{
+		client:       c,
+		hooks:        testHooks,
+		namespace:    testNamespace,
+		ready:        ready,
+		detector:     drift.NewDetector(c, testHooks, testNamespace, ""),
+		podName:      testPod,
+		retryPeriod:  time.Millisecond,
+		resyncPeriod: time.Millisecond,
+	}
+}
+
+// registered returns whether the configurations of the validating and the
+// mutating test webhook exist
+func registered(t *testing.T, c client.Client) []bool {
+	t.Helper()
+	configurations := map[string]client.Object{
+		hiveownership.WebhookName:       &admissionregv1.ValidatingWebhookConfiguration{},
+		debugpodtolerations.WebhookName: &admissionregv1.MutatingWebhookConfiguration{},
+	}
+	found := []bool{}
+	for _, name := range []string{hiveownership.WebhookName, debugpodtolerations.WebhookName} {
+		err := c.Get(context.Background(), client.ObjectKey{Name: webhooks.ConfigurationName(name)}, configurations[name])
+		if err != nil && !apierrors.IsNotFound(err) {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+		found = append(found, err == nil)
+	}
+	return found
+}
+
+func TestRegisterOnceReady(t *testing.T) {
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+	checks := 0
+	r := newRegistrar(c, func(context.Context) error {
+		checks++
+		if found := registered(t, c); found[0] || found[1] {
+			t.Fatalf("Expected no webhook to be registered before the server is ready, got %v", found)
+		}
+		if checks < 3 {
+			return errors.New("webhooks are warming up")
+		}
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := r.Register(ctx); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	if found := registered(t, c); !found[0] || !found[1] {
+		t.Errorf("Expected every webhook to be registered once ready, got %v", found)
+	}
+}
+
+func TestRegisterCancelled(t *testing.T) {
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+	r := newRegistrar(c, func(context.Context) error { return errors.New("kube-apiserver unreachable") })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := r.Register(ctx); err == nil {
+		t.Fatalf("Expected registering to give up when cancelled")
+	}
+	if found := registered(t, c); found[0] || found[1] {
+		t.Errorf("Expected no webhook to be registered, got %v", found)
+	}
+}
+
+func TestRunReregisters(t *testing.T) {
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+	r := newRegistrar(c, func(context.Context) error { return nil })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- r.Run(ctx) }()
+	if err := wait.PollUntilContextTimeout(ctx, time.Millisecond, 5*time.Second, true, func(context.Context) (bool, error) {
+		found := registered(t, c)
+		return found[0] && found[1], nil
+	}); err != nil {
+		t.Fatalf("Expected the webhooks to be registered, got %s", err.Error())
+	}
+
+	// A replica shutting down deregisters the webhooks after this one
+	// registered them
+	if err := c.Delete(ctx, &admissionregv1.ValidatingWebhookConfiguration{ObjectMeta: metav1.ObjectMeta{Name: webhooks.ConfigurationName(hiveownership.WebhookName)}}); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	if err := wait.PollUntilContextTimeout(ctx, time.Millisecond, 5*time.Second, true, func(context.Context) (bool, error) {
+		return registered(t, c)[0], nil
+	}); err != nil {
+		t.Errorf("Expected the serving replica to register the webhooks again, got %s", err.Error())
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Expected no error once cancelled, got %s", err.Error())
+	}
+}
+
+func endpoint(pod string, ready, terminating bool) discoveryv1.Endpoint {
+	return discoveryv1.Endpoint{
+		Addresses:  []string{"10.0.0.1"},
+		Conditions: discoveryv1.EndpointConditions{Ready: ptr.To(ready), Terminating: ptr.To(terminating)},
+		TargetRef:  &corev1.ObjectReference{Kind: "Pod", Name: pod},
+	}
+}
+
+func TestDeregister(t *testing.T) {
+	tests := []struct {
+		name       string
+		endpoints  []discoveryv1.Endpoint
+		registered bool
+	}{
+		{
+			name:       "last replica",
+			endpoints:  []discoveryv1.Endpoint{endpoint(testPod, true, false)},
+			registered: false,
+		},
+		{
+			name:       "other replica serving",
+			endpoints:  []discoveryv1.Endpoint{endpoint(testPod, false, true), endpoint("validation-webhook-b", true, false)},
+			registered: true,
+		},
+		{
+			name:       "other replicas shutting down or unready",
+			endpoints:  []discoveryv1.Endpoint{endpoint("validation-webhook-b", false, true), endpoint("validation-webhook-c", false, false)},
+			registered: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			slice := &discoveryv1.EndpointSlice{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      config.OperatorName + "-abcde",
+					Namespace: testNamespace,
+					Labels:    map[string]string{discoveryv1.LabelServiceName: config.OperatorName},
+				},
+				AddressType: discoveryv1.AddressTypeIPv4,
+				Endpoints:   test.endpoints,
+			}
+			c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(slice).Build()
+			r := newRegistrar(c, func(context.Context) error { return nil })
+			ctx := context.Background()
+			if err := r.Register(ctx); err != nil {
+				t.Fatalf("Expected no error, got %s", err.Error())
+			}
+
+			if err := r.Deregister(ctx); err != nil {
+				t.Fatalf("Expected no error, got %s", err.Error())
+			}
+			found := registered(t, c)
+			if found[0] != test.registered || found[1] != test.registered {
+				t.Errorf("Expected webhooks registered to be %v, got %v", test.registered, found)
+			}
+			// Deregistering again finds nothing left to delete
+			if err := r.Deregister(ctx); err != nil {
+				t.Errorf("Expected no error deregistering again, got %s", err.Error())
+			}
+		})
+	}
+}
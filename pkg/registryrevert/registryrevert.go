@@ -0,0 +1,160 @@
+// Package registryrevert restarts the workloads whose pods podimagespec-mutation
+// rewrote once the internal image registry is available again, so their pods
+// go back to the internal registry images they were created with.
+package registryrevert
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	registryv1 "github.com/openshift/api/imageregistry/v1"
+	operatorv1 "github.com/openshift/api/operator/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/localmetrics"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/podimagespec"
+)
+
+const (
+	// RestartedAtAnnotation is set on the pod template of a workload to roll
+	// its pods, as `oc rollout restart` does
+	RestartedAtAnnotation string = "kubectl.kubernetes.io/restartedAt"
+
+	// resyncPeriod is how often the leader looks for pods to revert
+	resyncPeriod = 5 * time.Minute
+)
+
+var log = logf.Log.WithName("registryrevert")
+
+// workload is a Deployment, StatefulSet or DaemonSet owning rewritten pods
+type workload struct {
+	kind      string
+	namespace string
+	name      string
+}
+
+// Reverter restarts the workloads of rewritten pods once the internal image
+// registry is Managed again. Only one replica of the webhook server, the
+// elected leader, runs it.
+type Reverter struct {
+	client client.Client
+}
+
+// NewReverter creates a Reverter
+func NewReverter(c client.Client) *Reverter {
+	return &Reverter{client: c}
+}
+
+// Run restarts the workloads of rewritten pods every resyncPeriod until ctx
+// is cancelled. It must only run on the elected leader.
+func (r *Reverter) Run(ctx context.Context) {
+	wait.UntilWithContext(ctx, r.Sync, resyncPeriod)
+}
+
+// Sync restarts the workloads of rewritten pods if the internal image registry
+// is Managed. Workloads already restarted since their newest rewritten pod was
+// created are left alone.
+func (r *Reverter) Sync(ctx context.Context) {
+	registry := &registryv1.Config{}
+	if err := r.client.Get(ctx, client.ObjectKey{Name: "cluster"}, registry); err != nil {
+		log.Error(err, "Failed to read image registry config")
+		return
+	}
+	if registry.Spec.ManagementState != operatorv1.Managed {
+		return
+	}
+
+	pods := &corev1.PodList{}
+	if err := r.client.List(ctx, pods, client.MatchingLabels{podimagespec.ImageRewrittenLabel: "true"}); err != nil {
+		log.Error(err, "Failed to list rewritten pods")
+		return
+	}
+
+	// newest is the creation time of each workload's newest rewritten pod
+	newest := map[workload]time.Time{}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		w, ok := r.workloadOf(ctx, pod)
+		if !ok {
+			continue
+		}
+		if created := pod.CreationTimestamp.Time; created.After(newest[w]) {
+			newest[w] = created
+		}
+	}
+
+	workloads := make([]workload, 0, len(newest))
+	for w := range newest {
+		workloads = append(workloads, w)
+	}
+	sort.Slice(workloads, func(i, j int) bool {
+		return workloads[i].namespace+"/"+workloads[i].name < workloads[j].namespace+"/"+workloads[j].name
+	})
+	for _, w := range workloads {
+		if err := r.restart(ctx, w, newest[w]); err != nil {
+			log.Error(err, "Failed to restart workload with rewritten images", "kind", w.kind, "namespace", w.namespace, "name", w.name)
+		}
+	}
+}
+
+// workloadOf returns the workload controlling the pod. Pods without one can't
+// be restarted safely and are left alone.
+func (r *Reverter) workloadOf(ctx context.Context, pod *corev1.Pod) (workload, bool) {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return workload{}, false
+	}
+	switch owner.Kind {
+	case "StatefulSet", "DaemonSet":
+		return workload{kind: owner.Kind, namespace: pod.Namespace, name: owner.Name}, true
+	case "ReplicaSet":
+		rs := &appsv1.ReplicaSet{}
+		if err := r.client.Get(ctx, client.ObjectKey{Namespace: pod.Namespace, Name: owner.Name}, rs); err != nil {
+			log.Error(err, "Failed to read ReplicaSet of rewritten pod", "namespace", pod.Namespace, "pod", pod.Name)
+			return workload{}, false
+		}
+		if deployment := metav1.GetControllerOf(rs); deployment != nil && deployment.Kind == "Deployment" {
+			return workload{kind: deployment.Kind, namespace: pod.Namespace, name: deployment.Name}, true
+		}
+	}
+	return workload{}, false
+}
+
+// restart rolls the workload's pods, unless it was restarted after since
+func (r *Reverter) restart(ctx context.Context, w workload, since time.Time) error {
+	var obj client.Object
+	var template *corev1.PodTemplateSpec
+	switch w.kind {
+	case "Deployment":
+		deployment := &appsv1.Deployment{}
+		obj, template = deployment, &deployment.Spec.Template
+	case "StatefulSet":
+		statefulSet := &appsv1.StatefulSet{}
+		obj, template = statefulSet, &statefulSet.Spec.Template
+	case "DaemonSet":
+		daemonSet := &appsv1.DaemonSet{}
+		obj, template = daemonSet, &daemonSet.Spec.Template
+	}
+	if err := r.client.Get(ctx, client.ObjectKey{Namespace: w.namespace, Name: w.name}, obj); err != nil {
+		return err
+	}
+
+	if restartedAt, err := time.Parse(time.RFC3339, template.Annotations[RestartedAtAnnotation]); err == nil && !restartedAt.Before(since) {
+		return nil
+	}
+
+	patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+	metav1.SetMetaDataAnnotation(&template.ObjectMeta, RestartedAtAnnotation, time.Now().Format(time.RFC3339))
+	if err := r.client.Patch(ctx, obj, patch); err != nil {
+		return err
+	}
+	localmetrics.IncrementRegistryRevert(w.kind)
+	log.Info("Restarted workload to revert rewritten images", "kind", w.kind, "namespace", w.namespace, "name", w.name)
+	return nil
+}
@@ -0,0 +1,111 @@
+package registryrevert
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	registryv1 "github.com/openshift/api/imageregistry/v1"
+	operatorv1 "github.com/openshift/api/operator/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/podimagespec"
+)
+
+func controlledBy(kind, name string) []metav1.OwnerReference {
+	return []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: kind, Name: name, Controller: ptr.To(true)}}
+}
+
+func rewrittenPod(name string, created time.Time, owners []metav1.OwnerReference) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "customer",
+			Labels:            map[string]string{podimagespec.ImageRewrittenLabel: "true"},
+			CreationTimestamp: metav1.NewTime(created),
+			OwnerReferences:   owners,
+		},
+	}
+}
+
+func newClient(t *testing.T, state operatorv1.ManagementState, objs ...client.Object) client.Client {
+	t.Helper()
+	s := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(s); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	if err := registryv1.AddToScheme(s); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	registry := &registryv1.Config{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+		Spec:       registryv1.ImageRegistrySpec{OperatorSpec: operatorv1.OperatorSpec{ManagementState: state}},
+	}
+	return fake.NewClientBuilder().WithScheme(s).WithObjects(append(objs, registry)...).Build()
+}
+
+func restartedAt(t *testing.T, c client.Client, obj client.Object, template *corev1.PodTemplateSpec) string {
+	t.Helper()
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(obj), obj); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	return template.Annotations[RestartedAtAnnotation]
+}
+
+func TestSync(t *testing.T) {
+	created := time.Now().Add(-time.Hour).Truncate(time.Second)
+	objects := func() []client.Object {
+		return []client.Object{
+			&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "customer"}},
+			&appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Name: "app-7d9f8", Namespace: "customer", OwnerReferences: controlledBy("Deployment", "app")}},
+			rewrittenPod("app-7d9f8-abcde", created, controlledBy("ReplicaSet", "app-7d9f8")),
+			&appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "customer"}},
+			rewrittenPod("db-0", created, controlledBy("StatefulSet", "db")),
+			rewrittenPod("standalone", created, nil),
+		}
+	}
+
+	t.Run("registry removed", func(t *testing.T) {
+		c := newClient(t, operatorv1.Removed, objects()...)
+		NewReverter(c).Sync(context.Background())
+
+		deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "customer"}}
+		if restarted := restartedAt(t, c, deployment, &deployment.Spec.Template); restarted != "" {
+			t.Errorf("Expected no restart while the registry is removed, got %s", restarted)
+		}
+	})
+
+	t.Run("registry managed", func(t *testing.T) {
+		c := newClient(t, operatorv1.Managed, objects()...)
+		r := NewReverter(c)
+		r.Sync(context.Background())
+
+		deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "customer"}}
+		first := restartedAt(t, c, deployment, &deployment.Spec.Template)
+		if first == "" {
+			t.Fatalf("Expected the Deployment to be restarted")
+		}
+		statefulSet := &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "customer"}}
+		if restartedAt(t, c, statefulSet, &statefulSet.Spec.Template) == "" {
+			t.Errorf("Expected the StatefulSet to be restarted")
+		}
+
+		// The old pods remain while the workload rolls, which doesn't restart
+		// it again
+		deployment.Spec.Template.Annotations[RestartedAtAnnotation] = created.Add(time.Minute).Format(time.RFC3339)
+		if err := c.Update(context.Background(), deployment); err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+		r.Sync(context.Background())
+		if second := restartedAt(t, c, deployment, &deployment.Spec.Template); second != created.Add(time.Minute).Format(time.RFC3339) {
+			t.Errorf("Expected the Deployment not to be restarted again, got %s", second)
+		}
+	})
+}
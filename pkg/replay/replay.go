@@ -0,0 +1,477 @@
+// Package replay reconstructs admission requests from API server audit logs
+// and saved AdmissionReviews, and runs them through the current webhooks,
+// reporting where their decisions differ from the recorded ones. It is used to
+// check refactors of webhooks against the requests clusters actually see.
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/dependencies"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks"
+)
+
+// Decision is a webhook's answer to an admission request
+type Decision struct {
+	Allowed bool
+	// Patched is whether the webhook mutated the request
+	Patched bool
+	Message string
+}
+
+func (d Decision) String() string {
+	switch {
+	case !d.Allowed:
+		return "denied"
+	case d.Patched:
+		return "patched"
+	}
+	return "allowed"
+}
+
+// Entry is an admission request read from an audit log or a saved
+// AdmissionReview
+type Entry struct {
+	// Source locates the entry, as file:index
+	Source  string
+	Request admissionv1.AdmissionRequest
+	// Skip explains why the request can't be reconstructed, if it can't
+	Skip string
+
+	// recorded returns the decision recorded for the named webhook, if it is
+	// known
+	recorded func(webhook string) (Decision, bool)
+}
+
+// Result is the outcome of replaying an entry through one webhook
+type Result struct {
+	Entry   Entry
+	Webhook string
+	// Recorded is the recorded decision, or nil if it isn't known
+	Recorded *Decision
+	Replayed Decision
+}
+
+// Differs is true when the webhook no longer decides as recorded
+func (r Result) Differs() bool {
+	if r.Recorded == nil {
+		return false
+	}
+	if r.Recorded.Allowed != r.Replayed.Allowed {
+		return true
+	}
+	return webhooks.IsMutating(r.Webhook) && r.Recorded.Patched != r.Replayed.Patched
+}
+
+// Report is the outcome of a replay
+type Report struct {
+	Results []Result
+	// Skipped are the entries which couldn't be replayed
+	Skipped []Entry
+}
+
+// Differs is true when any webhook no longer decides as recorded
+func (r Report) Differs() bool {
+	for _, result := range r.Results {
+		if result.Differs() {
+			return true
+		}
+	}
+	return false
+}
+
+// Print writes the results which differ, or every result when verbose, then
+// a summary
+func (r Report) Print(w io.Writer, verbose bool) {
+	differ, unknown := 0, 0
+	for _, result := range r.Results {
+		request := result.Entry.Request
+		object := request.Name
+		if request.Namespace != "" {
+			object = request.Namespace + "/" + object
+		}
+		describe := fmt.Sprintf("%s %s %s %s", result.Webhook, result.Entry.Source, request.Operation, resourceName(request))
+		if object != "" {
+			describe += " " + object
+		}
+		switch {
+		case result.Recorded == nil:
+			unknown++
+			if verbose {
+				fmt.Fprintf(w, "UNKNOWN %s: now %s\n", describe, result.Replayed)
+			}
+		case result.Differs():
+			differ++
+			fmt.Fprintf(w, "DIFF %s: recorded %s, now %s\n", describe, result.Recorded, result.Replayed)
+			if result.Replayed.Message != "" {
+				fmt.Fprintf(w, "    %s\n", result.Replayed.Message)
+			}
+		case verbose:
+			fmt.Fprintf(w, "SAME %s: %s\n", describe, result.Replayed)
+		}
+	}
+	if verbose {
+		for _, entry := range r.Skipped {
+			fmt.Fprintf(w, "SKIP %s: %s\n", entry.Source, entry.Skip)
+		}
+	}
+	fmt.Fprintf(w, "%d replayed, %d differ, %d without a recorded decision, %d skipped\n", len(r.Results), differ, unknown, len(r.Skipped))
+}
+
+func resourceName(request admissionv1.AdmissionRequest) string {
+	resource := request.Resource.Resource
+	if request.Resource.Group != "" {
+		resource += "." + request.Resource.Group
+	}
+	if request.SubResource != "" {
+		resource += "/" + request.SubResource
+	}
+	return resource
+}
+
+// Run replays each entry through every webhook it would be sent to, or only
+// through the webhooks named in only when it isn't empty. Webhooks which read
+// the cluster use a client from newClient.
+func Run(hooks webhooks.RegisteredWebhooks, entries []Entry, only []string, newClient func() client.Client) Report {
+	names := []string{}
+	for name := range hooks {
+		if len(only) == 0 || slices.Contains(only, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	report := Report{}
+	for _, entry := range entries {
+		if entry.Skip != "" {
+			report.Skipped = append(report.Skipped, entry)
+			continue
+		}
+		request := admissionctl.Request{AdmissionRequest: entry.Request}
+		for _, name := range names {
+			hook := hooks[name]()
+			if !Matches(hook, entry.Request) || !hook.Validate(request) {
+				continue
+			}
+			deps := dependencies.Bundle{}
+			if _, ok := hook.(webhooks.CachingWebhook); ok {
+				deps.Client = newClient()
+			}
+			response := webhooks.Admit(context.Background(), hook, deps, request)
+			result := Result{
+				Entry:   entry,
+				Webhook: name,
+				Replayed: Decision{
+					Allowed: response.Allowed,
+					Patched: len(response.Patch) > 0 || len(response.Patches) > 0,
+				},
+			}
+			if response.Result != nil {
+				result.Replayed.Message = response.Result.Message
+			}
+			if entry.recorded != nil {
+				if recorded, ok := entry.recorded(name); ok {
+					result.Recorded = &recorded
+				}
+			}
+			report.Results = append(report.Results, result)
+		}
+	}
+	return report
+}
+
+// Matches returns true if the API server would send the request to the
+// webhook. Namespace selectors aren't known here, so they aren't checked.
+func Matches(hook webhooks.Webhook, request admissionv1.AdmissionRequest) bool {
+	if selector := hook.ObjectSelector(); selector != nil {
+		s, err := metav1.LabelSelectorAsSelector(selector)
+		if err != nil || !(s.Matches(objectLabels(request.Object)) || s.Matches(objectLabels(request.OldObject))) {
+			return false
+		}
+	}
+	for _, rule := range hook.Rules() {
+		if matchesRule(rule, request) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesRule(rule admissionregv1.RuleWithOperations, request admissionv1.AdmissionRequest) bool {
+	operation := false
+	for _, op := range rule.Operations {
+		operation = operation || op == admissionregv1.OperationAll || string(op) == string(request.Operation)
+	}
+	resource := request.Resource.Resource
+	if request.SubResource != "" {
+		resource += "/" + request.SubResource
+	}
+	resourceMatched := false
+	for _, r := range rule.Resources {
+		name, sub, _ := strings.Cut(r, "/")
+		requestName, requestSub, _ := strings.Cut(resource, "/")
+		resourceMatched = resourceMatched || r == resource || r == "*/*" || (name == "*" && sub == requestSub) || (name == requestName && sub == "*")
+	}
+	if rule.Scope != nil && *rule.Scope != admissionregv1.AllScopes {
+		// Namespaces are cluster scoped, although their requests carry their
+		// name as namespace
+		namespaced := request.Namespace != "" && request.Resource.Resource != "namespaces"
+		if namespaced != (*rule.Scope == admissionregv1.NamespacedScope) {
+			return false
+		}
+	}
+	return operation && resourceMatched &&
+		matchesAny(rule.APIGroups, request.Resource.Group) &&
+		matchesAny(rule.APIVersions, request.Resource.Version)
+}
+
+func matchesAny(values []string, value string) bool {
+	return slices.Contains(values, "*") || slices.Contains(values, value)
+}
+
+// objectLabels returns the labels of the raw object
+func objectLabels(raw runtime.RawExtension) labels.Set {
+	object := struct {
+		Metadata struct {
+			Labels map[string]string `json:"labels"`
+		} `json:"metadata"`
+	}{}
+	if len(raw.Raw) > 0 {
+		_ = json.Unmarshal(raw.Raw, &object)
+	}
+	return labels.Set(object.Metadata.Labels)
+}
+
+// Read reads the audit events and AdmissionReviews in r, named name. They may
+// be JSON lines, as audit logs are written, or concatenated JSON documents.
+// Audit events of requests which aren't admitted, and of stages other than
+// ResponseComplete, are ignored. The scheme resolves the kinds of the
+// resources in audit events.
+func Read(name string, r io.Reader, scheme *runtime.Scheme) ([]Entry, error) {
+	entries := []Entry{}
+	decoder := json.NewDecoder(r)
+	for index := 1; ; index++ {
+		raw := json.RawMessage{}
+		if err := decoder.Decode(&raw); errors.Is(err, io.EOF) {
+			return entries, nil
+		} else if err != nil {
+			return entries, fmt.Errorf("couldn't read %s:%d: %w", name, index, err)
+		}
+		source := fmt.Sprintf("%s:%d", name, index)
+
+		typeMeta := metav1.TypeMeta{}
+		if err := json.Unmarshal(raw, &typeMeta); err != nil {
+			return entries, fmt.Errorf("couldn't read %s: %w", source, err)
+		}
+		switch {
+		case typeMeta.Kind == "AdmissionReview":
+			entry, err := fromReview(source, raw)
+			if err != nil {
+				return entries, err
+			}
+			entries = append(entries, entry)
+		case typeMeta.Kind == "Event" && strings.HasPrefix(typeMeta.APIVersion, "audit.k8s.io/"):
+			event := auditEvent{}
+			if err := json.Unmarshal(raw, &event); err != nil {
+				return entries, fmt.Errorf("couldn't read audit event %s: %w", source, err)
+			}
+			if entry, ok := fromAuditEvent(source, event, scheme); ok {
+				entries = append(entries, entry)
+			}
+		default:
+			return entries, fmt.Errorf("%s is neither an audit event nor an AdmissionReview", source)
+		}
+	}
+}
+
+// fromReview reads a saved AdmissionReview. Its response, if any, is the
+// recorded decision of whichever webhooks it is replayed through.
+func fromReview(source string, raw []byte) (Entry, error) {
+	review := admissionv1.AdmissionReview{}
+	if err := json.Unmarshal(raw, &review); err != nil {
+		return Entry{}, fmt.Errorf("couldn't read AdmissionReview %s: %w", source, err)
+	}
+	if review.Request == nil {
+		return Entry{Source: source, Skip: "the AdmissionReview has no request"}, nil
+	}
+	entry := Entry{Source: source, Request: *review.Request}
+	if response := review.Response; response != nil {
+		recorded := Decision{Allowed: response.Allowed, Patched: len(response.Patch) > 0}
+		if response.Result != nil {
+			recorded.Message = response.Result.Message
+		}
+		entry.recorded = func(string) (Decision, bool) { return recorded, true }
+	}
+	return entry, nil
+}
+
+// auditEvent holds the fields of an audit.k8s.io Event needed to reconstruct
+// the admission request
+type auditEvent struct {
+	AuditID          types.UID                  `json:"auditID"`
+	Stage            string                     `json:"stage"`
+	Level            string                     `json:"level"`
+	RequestURI       string                     `json:"requestURI"`
+	Verb             string                     `json:"verb"`
+	User             authenticationv1.UserInfo  `json:"user"`
+	ImpersonatedUser *authenticationv1.UserInfo `json:"impersonatedUser,omitempty"`
+	ObjectRef        *struct {
+		Resource    string `json:"resource"`
+		Namespace   string `json:"namespace"`
+		Name        string `json:"name"`
+		APIGroup    string `json:"apiGroup"`
+		APIVersion  string `json:"apiVersion"`
+		Subresource string `json:"subresource"`
+	} `json:"objectRef,omitempty"`
+	ResponseStatus *metav1.Status       `json:"responseStatus,omitempty"`
+	RequestObject  runtime.RawExtension `json:"requestObject,omitempty"`
+	ResponseObject runtime.RawExtension `json:"responseObject,omitempty"`
+	Annotations    map[string]string    `json:"annotations,omitempty"`
+}
+
+// operations maps the verbs of the requests which are admitted to their
+// admission operations
+var operations = map[string]admissionv1.Operation{
+	"create":           admissionv1.Create,
+	"update":           admissionv1.Update,
+	"patch":            admissionv1.Update,
+	"delete":           admissionv1.Delete,
+	"deletecollection": admissionv1.Delete,
+}
+
+// fromAuditEvent reconstructs the admission request of an audit event, unless
+// it isn't of an admitted request. The old object of updates and deletions
+// isn't recorded in audit logs, so webhooks which read it can't be replayed
+// faithfully from them.
+func fromAuditEvent(source string, event auditEvent, scheme *runtime.Scheme) (Entry, bool) {
+	operation, admitted := operations[event.Verb]
+	if !admitted || event.ObjectRef == nil || (event.Stage != "" && event.Stage != "ResponseComplete") {
+		return Entry{}, false
+	}
+	ref := event.ObjectRef
+	user := event.User
+	if event.ImpersonatedUser != nil {
+		user = *event.ImpersonatedUser
+	}
+	resource := metav1.GroupVersionResource{Group: ref.APIGroup, Version: ref.APIVersion, Resource: ref.Resource}
+	entry := Entry{
+		Source: source,
+		Request: admissionv1.AdmissionRequest{
+			UID:         event.AuditID,
+			Resource:    resource,
+			SubResource: ref.Subresource,
+			Name:        ref.Name,
+			Namespace:   ref.Namespace,
+			Operation:   operation,
+			UserInfo:    user,
+			DryRun:      ptr.To(strings.Contains(event.RequestURI, "dryRun=All")),
+		},
+		recorded: func(webhook string) (Decision, bool) { return recordedDecision(event, webhook) },
+	}
+	kind, ok := kindOf(event, scheme)
+	if !ok {
+		entry.Skip = fmt.Sprintf("the kind of %s isn't known", resourceName(entry.Request))
+		return entry, true
+	}
+	entry.Request.Kind = kind
+	entry.Request.RequestKind = &kind
+	entry.Request.RequestResource = &resource
+
+	succeeded := event.ResponseStatus == nil || event.ResponseStatus.Code < 400
+	switch {
+	case operation == admissionv1.Delete:
+	case event.Level != "Request" && event.Level != "RequestResponse":
+		entry.Skip = fmt.Sprintf("the %s audit level doesn't record the object", event.Level)
+	case event.Verb == "patch" && succeeded && event.Level == "RequestResponse":
+		// the request object is the patch, the response the patched object
+		entry.Request.Object = event.ResponseObject
+	case event.Verb == "patch":
+		entry.Skip = "the patched object isn't recorded"
+	default:
+		entry.Request.Object = event.RequestObject
+	}
+	return entry, true
+}
+
+// kindOf returns the kind of the audit event's object: that of the request
+// object, or else the kind the scheme registers for the resource
+func kindOf(event auditEvent, scheme *runtime.Scheme) (metav1.GroupVersionKind, bool) {
+	ref := event.ObjectRef
+	gv := schema.GroupVersion{Group: ref.APIGroup, Version: ref.APIVersion}
+	typeMeta := metav1.TypeMeta{}
+	if len(event.RequestObject.Raw) > 0 && json.Unmarshal(event.RequestObject.Raw, &typeMeta) == nil && typeMeta.APIVersion == gv.String() && typeMeta.Kind != "" && ref.Subresource == "" {
+		return metav1.GroupVersionKind{Group: gv.Group, Version: gv.Version, Kind: typeMeta.Kind}, true
+	}
+	for gvk := range scheme.AllKnownTypes() {
+		if gvk.GroupVersion() != gv || strings.HasSuffix(gvk.Kind, "List") {
+			continue
+		}
+		if plural, _ := meta.UnsafeGuessKindToResource(gvk); plural.Resource == ref.Resource {
+			return metav1.GroupVersionKind{Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind}, true
+		}
+	}
+	return metav1.GroupVersionKind{}, false
+}
+
+// deniedPrefix starts the message of a request denied by a webhook, followed
+// by the webhook's name and `" denied the request`
+const deniedPrefix = `admission webhook "`
+
+// recordedDecision returns the decision the webhook made on the audited
+// request, as far as the audit event records it. A denial names the webhook
+// which denied it; mutating webhooks which were called are recorded in
+// mutation annotations. When the request was denied for another reason, or
+// the webhook failed open, the webhook's decision isn't known.
+func recordedDecision(event auditEvent, webhook string) (Decision, bool) {
+	name := fmt.Sprintf("%s.managed.openshift.io", webhook)
+	for key, value := range event.Annotations {
+		if strings.HasPrefix(key, "failed-open.") && strings.Contains(value, `"`+name+`"`) {
+			return Decision{}, false
+		}
+	}
+
+	if status := event.ResponseStatus; status != nil && status.Code >= 400 {
+		if strings.Contains(status.Message, deniedPrefix+name+`" denied the request`) {
+			return Decision{Allowed: false, Message: status.Message}, true
+		}
+		return Decision{}, false
+	}
+
+	if !webhooks.IsMutating(webhook) {
+		return Decision{Allowed: true}, true
+	}
+	for key, value := range event.Annotations {
+		if !strings.HasPrefix(key, "mutation.webhook.admission.k8s.io/") {
+			continue
+		}
+		annotation := struct {
+			Webhook string `json:"webhook"`
+			Mutated bool   `json:"mutated"`
+		}{}
+		if json.Unmarshal([]byte(value), &annotation) == nil && annotation.Webhook == name {
+			return Decision{Allowed: true, Patched: annotation.Mutated}, true
+		}
+	}
+	// The webhook wasn't called
+	return Decision{}, false
+}
@@ -0,0 +1,160 @@
+package replay
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/priorityclass"
+)
+
+// auditLog holds audit events as the API server writes them, one per line
+const auditLog = `{"kind":"Event","apiVersion":"audit.k8s.io/v1","level":"Metadata","auditID":"a1","stage":"ResponseComplete","requestURI":"/apis/scheduling.k8s.io/v1/priorityclasses/system-cluster-critical","verb":"delete","user":{"username":"customer","groups":["system:authenticated"]},"objectRef":{"resource":"priorityclasses","name":"system-cluster-critical","apiGroup":"scheduling.k8s.io","apiVersion":"v1"},"responseStatus":{"metadata":{},"status":"Failure","message":"admission webhook \"priorityclass-validation.managed.openshift.io\" denied the request: Prevented from deleting","reason":"Forbidden","code":403}}
+{"kind":"Event","apiVersion":"audit.k8s.io/v1","level":"Metadata","auditID":"a2","stage":"ResponseComplete","requestURI":"/apis/scheduling.k8s.io/v1/priorityclasses/system-node-critical","verb":"delete","user":{"username":"customer","groups":["system:authenticated"]},"objectRef":{"resource":"priorityclasses","name":"system-node-critical","apiGroup":"scheduling.k8s.io","apiVersion":"v1"},"responseStatus":{"metadata":{},"code":200}}
+{"kind":"Event","apiVersion":"audit.k8s.io/v1","level":"Metadata","auditID":"a3","stage":"RequestReceived","requestURI":"/apis/scheduling.k8s.io/v1/priorityclasses/system-node-critical","verb":"delete","user":{"username":"customer"},"objectRef":{"resource":"priorityclasses","name":"system-node-critical","apiGroup":"scheduling.k8s.io","apiVersion":"v1"}}
+{"kind":"Event","apiVersion":"audit.k8s.io/v1","level":"Metadata","auditID":"a4","stage":"ResponseComplete","requestURI":"/apis/scheduling.k8s.io/v1/priorityclasses","verb":"list","user":{"username":"customer"},"objectRef":{"resource":"priorityclasses","apiGroup":"scheduling.k8s.io","apiVersion":"v1"},"responseStatus":{"metadata":{},"code":200}}
+{"kind":"Event","apiVersion":"audit.k8s.io/v1","level":"Metadata","auditID":"a5","stage":"ResponseComplete","requestURI":"/apis/scheduling.k8s.io/v1/priorityclasses/system-node-critical","verb":"update","user":{"username":"customer"},"objectRef":{"resource":"priorityclasses","name":"system-node-critical","apiGroup":"scheduling.k8s.io","apiVersion":"v1"},"responseStatus":{"metadata":{},"code":200}}
+{"kind":"Event","apiVersion":"audit.k8s.io/v1","level":"Metadata","auditID":"a6","stage":"ResponseComplete","requestURI":"/apis/scheduling.k8s.io/v1/priorityclasses/system-cluster-critical","verb":"delete","user":{"username":"customer"},"objectRef":{"resource":"priorityclasses","name":"system-cluster-critical","apiGroup":"scheduling.k8s.io","apiVersion":"v1"},"responseStatus":{"metadata":{},"message":"admission webhook \"other.example.com\" denied the request","code":403}}
+`
+
+// reviews holds a saved AdmissionReview, as the API server sends it
+const reviews = `{
+  "kind": "AdmissionReview",
+  "apiVersion": "admission.k8s.io/v1",
+  "request": {
+    "uid": "r1",
+    "kind": {"group": "scheduling.k8s.io", "version": "v1", "kind": "PriorityClass"},
+    "resource": {"group": "scheduling.k8s.io", "version": "v1", "resource": "priorityclasses"},
+    "name": "customer-priority",
+    "operation": "DELETE",
+    "userInfo": {"username": "customer"}
+  },
+  "response": {"uid": "r1", "allowed": true}
+}`
+
+var testHooks = webhooks.RegisteredWebhooks{
+	priorityclass.WebhookName: func() webhooks.Webhook { return priorityclass.NewWebhook() },
+}
+
+func emptyClient() client.Client {
+	return fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+}
+
+func TestReadAuditLog(t *testing.T) {
+	entries, err := Read("audit.log", strings.NewReader(auditLog), clientgoscheme.Scheme)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	sources := []string{}
+	for _, entry := range entries {
+		sources = append(sources, entry.Source)
+	}
+	// The RequestReceived stage and the list aren't admitted requests
+	if expected := "audit.log:1 audit.log:2 audit.log:5 audit.log:6"; strings.Join(sources, " ") != expected {
+		t.Fatalf("Expected entries %s, got %v", expected, sources)
+	}
+
+	deleted := entries[0].Request
+	if deleted.Operation != admissionv1.Delete || deleted.Name != "system-cluster-critical" || deleted.UID != "a1" {
+		t.Errorf("Unexpected request %+v", deleted)
+	}
+	if expected := (metav1.GroupVersionKind{Group: "scheduling.k8s.io", Version: "v1", Kind: "PriorityClass"}); deleted.Kind != expected {
+		t.Errorf("Expected kind %v, got %v", expected, deleted.Kind)
+	}
+	if entries[2].Skip == "" {
+		t.Errorf("Expected the update audited at the Metadata level to be skipped")
+	}
+}
+
+func TestRun(t *testing.T) {
+	entries, err := Read("audit.log", strings.NewReader(auditLog), clientgoscheme.Scheme)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	saved, err := Read("reviews.json", strings.NewReader(reviews), clientgoscheme.Scheme)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	report := Run(testHooks, append(entries, saved...), nil, emptyClient)
+
+	outcomes := map[string]string{}
+	for _, result := range report.Results {
+		switch {
+		case result.Recorded == nil:
+			outcomes[result.Entry.Source] = "unknown"
+		case result.Differs():
+			outcomes[result.Entry.Source] = "differs"
+		default:
+			outcomes[result.Entry.Source] = "same"
+		}
+	}
+	expected := map[string]string{
+		"audit.log:1":    "same",
+		"audit.log:2":    "differs",
+		"audit.log:6":    "unknown",
+		"reviews.json:1": "same",
+	}
+	for source, outcome := range expected {
+		if outcomes[source] != outcome {
+			t.Errorf("Expected %s to be %s, got %q", source, outcome, outcomes[source])
+		}
+	}
+	if len(report.Skipped) != 1 || !report.Differs() {
+		t.Errorf("Expected one skipped entry and differences, got %+v", report)
+	}
+
+	out := &bytes.Buffer{}
+	report.Print(out, false)
+	if !strings.Contains(out.String(), "DIFF priorityclass-validation audit.log:2 DELETE priorityclasses.scheduling.k8s.io system-node-critical: recorded allowed, now denied") {
+		t.Errorf("Expected the difference to be printed, got\n%s", out.String())
+	}
+
+	// Other webhooks aren't replayed
+	if report := Run(testHooks, entries, []string{"namespace-validation"}, emptyClient); len(report.Results) != 0 {
+		t.Errorf("Expected no results, got %+v", report.Results)
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	if _, err := Read("other.json", strings.NewReader(`{"kind":"Pod","apiVersion":"v1"}`), clientgoscheme.Scheme); err == nil {
+		t.Errorf("Expected an error reading neither an audit event nor an AdmissionReview")
+	}
+}
+
+func TestMatchesRule(t *testing.T) {
+	namespaced := admissionregv1.NamespacedScope
+	rule := admissionregv1.RuleWithOperations{
+		Operations: []admissionregv1.OperationType{admissionregv1.Update},
+		Rule: admissionregv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"pods/ephemeralcontainers"},
+			Scope:       &namespaced,
+		},
+	}
+	request := func(subResource, namespace string) admissionv1.AdmissionRequest {
+		return admissionv1.AdmissionRequest{
+			Operation:   admissionv1.Update,
+			Resource:    metav1.GroupVersionResource{Version: "v1", Resource: "pods"},
+			SubResource: subResource,
+			Namespace:   namespace,
+		}
+	}
+	if !matchesRule(rule, request("ephemeralcontainers", "default")) {
+		t.Errorf("Expected the subresource to match")
+	}
+	if matchesRule(rule, request("", "default")) {
+		t.Errorf("Expected the resource not to match the subresource rule")
+	}
+	if matchesRule(rule, request("ephemeralcontainers", "")) {
+		t.Errorf("Expected a cluster scoped request not to match a namespaced rule")
+	}
+}
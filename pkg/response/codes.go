@@ -0,0 +1,135 @@
+package response
+
+// The codes the webhooks deny requests with. Each is documented in DocsURL.
+const (
+	// Unauthenticated is for requests without an authenticated user
+	Unauthenticated Code = "Unauthenticated"
+	// ManagedResource is for changes to resources Red Hat manages which have
+	// no more specific code
+	ManagedResource Code = "ManagedResource"
+	// WebhookTimeout is for requests a fail-closed webhook couldn't answer in
+	// time
+	WebhookTimeout Code = "WebhookTimeout"
+
+	ClusterAutoscalerConfig            Code = "ClusterAutoscalerConfig"
+	ClusterCriticalPriorityClass       Code = "ClusterCriticalPriorityClass"
+	CustomResourceDefinitionInUse      Code = "CustomResourceDefinitionInUse"
+	DrainBlockingDisruptionBudget      Code = "DrainBlockingDisruptionBudget"
+	EtcdProtected                      Code = "EtcdProtected"
+	FailClosedWebhookConfiguration     Code = "FailClosedWebhookConfiguration"
+	HighAvailabilityTopology           Code = "HighAvailabilityTopology"
+	HostAccess                         Code = "HostAccess"
+	HostedClusterDeletion              Code = "HostedClusterDeletion"
+	HostedClusterInvariant             Code = "HostedClusterInvariant"
+	ImageMirrorConflict                Code = "ImageMirrorConflict"
+	ImageProvenance                    Code = "ImageProvenance"
+	InfraNodeScheduling                Code = "InfraNodeScheduling"
+	InvalidMonitoringConfig            Code = "InvalidMonitoringConfig"
+	KubeadminRemoved                   Code = "KubeadminRemoved"
+	LogRetentionOutOfRange             Code = "LogRetentionOutOfRange"
+	ManagedClusterConfig               Code = "ManagedClusterConfig"
+	ManagedFinalizer                   Code = "ManagedFinalizer"
+	ManagedIngress                     Code = "ManagedIngress"
+	ManagedLogForwarding               Code = "ManagedLogForwarding"
+	ManagedMachineSet                  Code = "ManagedMachineSet"
+	ManagedMonitoring                  Code = "ManagedMonitoring"
+	ManagedNamespace                   Code = "ManagedNamespace"
+	ManagedNamespaceLabel              Code = "ManagedNamespaceLabel"
+	ManagedNetworkConfig               Code = "ManagedNetworkConfig"
+	ManagedNetworkPolicy               Code = "ManagedNetworkPolicy"
+	ManagedNode                        Code = "ManagedNode"
+	ManagedNodeLabel                   Code = "ManagedNodeLabel"
+	ManagedOAuthConfig                 Code = "ManagedOAuthConfig"
+	ManagedOperatorScaledToZero        Code = "ManagedOperatorScaledToZero"
+	ManagedRBAC                        Code = "ManagedRBAC"
+	ManagedSecurityContextConstraint   Code = "ManagedSecurityContextConstraint"
+	ManagedServiceAccount              Code = "ManagedServiceAccount"
+	ManagedStorageClass                Code = "ManagedStorageClass"
+	ManagedUpgrade                     Code = "ManagedUpgrade"
+	MissingCostAllocationLabels        Code = "MissingCostAllocationLabels"
+	NamespaceCreationRateLimited       Code = "NamespaceCreationRateLimited"
+	NamespaceDeletionBlocked           Code = "NamespaceDeletionBlocked"
+	NetworkPolicyBlocksPlatformIngress Code = "NetworkPolicyBlocksPlatformIngress"
+	NetworkPolicyDefaultIngress        Code = "NetworkPolicyDefaultIngress"
+	ObjectCountQuota                   Code = "ObjectCountQuota"
+	PlatformCertificateApproval        Code = "PlatformCertificateApproval"
+	PlatformImageStream                Code = "PlatformImageStream"
+	PlatformPersistentVolume           Code = "PlatformPersistentVolume"
+	PlatformServiceAccountToken        Code = "PlatformServiceAccountToken"
+	PrivilegedPod                      Code = "PrivilegedPod"
+	RequestsExceedNodeAllocatable      Code = "RequestsExceedNodeAllocatable"
+	ReservedAPIGroup                   Code = "ReservedAPIGroup"
+	ReservedCertificateHost            Code = "ReservedCertificateHost"
+	ReservedNamespaceName              Code = "ReservedNamespaceName"
+	ReservedRouteHost                  Code = "ReservedRouteHost"
+	SREAccess                          Code = "SREAccess"
+	SREImpersonation                   Code = "SREImpersonation"
+	ServiceLoadBalancerQuota           Code = "ServiceLoadBalancerQuota"
+	ServiceNodePort                    Code = "ServiceNodePort"
+	TechPreviewFeatureSet              Code = "TechPreviewFeatureSet"
+	WebhookPolicy                      Code = "WebhookPolicy"
+)
+
+// Codes describes every code, for the documentation
+var Codes = map[Code]string{
+	Unauthenticated:                    "The request has no authenticated user.",
+	ManagedResource:                    "The request changes a resource Red Hat manages.",
+	WebhookTimeout:                     "The webhook couldn't answer the request in time and fails closed. Try again later.",
+	ClusterAutoscalerConfig:            "The ClusterAutoscaler or MachineAutoscaler scales beyond the cluster's maximum node count, or down aggressively enough to destabilize the cluster.",
+	ClusterCriticalPriorityClass:       "The request changes or deletes a cluster-critical PriorityClass managed pods depend on.",
+	CustomResourceDefinitionInUse:      "The CustomResourceDefinition has custom resources in platform namespaces, which deleting it would delete too.",
+	DrainBlockingDisruptionBudget:      "The PodDisruptionBudget allows none of its pods to be disrupted, which blocks the node drains of managed upgrades.",
+	EtcdProtected:                      "The request deletes or changes the pods, secrets or disruption budgets of etcd.",
+	FailClosedWebhookConfiguration:     "The webhook configuration fails closed on requests in platform namespaces or for nodes and namespaces, so the whole cluster would become unavailable whenever the webhook is.",
+	HighAvailabilityTopology:           "The Deployment or StatefulSet is labelled ha=true, but has no topology spread constraint or pod anti-affinity term spreading its pods across zones, which the SLA's high availability requires. It's only denied when the webhook's mode is deny.",
+	HostAccess:                         "The pod uses host access, such as the host's network or paths, in a customer namespace which isn't labelled to allow it.",
+	HostedClusterDeletion:              "The request deletes hosted control plane resources, which only their managing service accounts may delete.",
+	HostedClusterInvariant:             "The HostedCluster or NodePool uses a release image from outside the managed release streams, changes its AWS endpoint access, or has fewer replicas than managed clusters need.",
+	ImageMirrorConflict:                "The image mirror configuration conflicts with the registries the platform pulls from.",
+	ImageProvenance:                    "The pod uses an image from a registry the customer hasn't allowlisted for namespaces enforcing image provenance.",
+	InfraNodeScheduling:                "The pod or IngressController tolerates the taints of infra or control plane nodes, which are reserved for managed components.",
+	InvalidMonitoringConfig:            "The cluster monitoring configuration is invalid.",
+	KubeadminRemoved:                   "The request recreates or changes the kubeadmin Secret of a cluster whose kubeadmin user is removed.",
+	LogRetentionOutOfRange:             "The ClusterLogging retention is outside the supported range.",
+	ManagedClusterConfig:               "The request changes cluster configuration Red Hat manages.",
+	ManagedFinalizer:                   "The request adds a finalizer to a managed resource, which can wedge its deletion and the cluster's upgrades, or changes which finalizers SRE allowed on it.",
+	ManagedIngress:                     "The request changes the ingress configuration Red Hat manages.",
+	ManagedLogForwarding:               "The request deletes or unlabels a managed ClusterLogForwarder or ClusterLogging, or changes it so the cluster's audit logs would no longer be forwarded to Red Hat SRE. The denial lists the fields changed.",
+	ManagedMachineSet:                  "The request changes a MachineSet managed through OpenShift Cluster Manager machine pools.",
+	ManagedMonitoring:                  "The request changes the platform monitoring Red Hat SRE rely on.",
+	ManagedNamespace:                   "The request changes a namespace Red Hat manages.",
+	ManagedNamespaceLabel:              "The request sets or changes a namespace label Red Hat manages.",
+	ManagedNetworkConfig:               "The request changes network configuration which is fixed on managed clusters.",
+	ManagedNetworkPolicy:               "The request changes or deletes a NetworkPolicy labelled as managed by Red Hat, or labels a NetworkPolicy as managed.",
+	ManagedNode:                        "The request deletes a node, or changes a control plane or infra node.",
+	ManagedNodeLabel:                   "The request changes node labels which place managed components.",
+	ManagedOAuthConfig:                 "The request changes the identity providers or templates of the OAuth config managed by OpenShift Cluster Manager.",
+	ManagedOperatorScaledToZero:        "The request scales a Deployment of a managed platform operator or component, such as ingress, monitoring, the image registry or OVN-Kubernetes, to zero replicas.",
+	ManagedRBAC:                        "The request changes a ClusterRole or ClusterRoleBinding Red Hat manages.",
+	ManagedSecurityContextConstraint:   "The request changes or deletes a default SecurityContextConstraints.",
+	ManagedServiceAccount:              "The request deletes a service account Red Hat manages.",
+	ManagedStorageClass:                "The request deletes or changes a StorageClass Red Hat manages.",
+	ManagedUpgrade:                     "The request changes the channel, desired update or update server of the ClusterVersion. Upgrades are scheduled through OpenShift Cluster Manager.",
+	MissingCostAllocationLabels:        "The workload is created in a namespace lacking the cost allocation labels its cluster requires.",
+	NamespaceCreationRateLimited:       "Too many namespaces or projects were created recently. Wait before creating more.",
+	NamespaceDeletionBlocked:           "The namespace still has must-gather or debug pods running.",
+	NetworkPolicyBlocksPlatformIngress: "The NetworkPolicy would cut the pods it selects off from ingress the platform needs, such as metrics scraping, which no other NetworkPolicy of the namespace allows.",
+	NetworkPolicyDefaultIngress:        "The NetworkPolicy could block the default ingress of managed namespaces.",
+	ObjectCountQuota:                   "The namespace already has as many objects of the kind as its quota allows, on a cluster limiting object counts.",
+	PlatformCertificateApproval:        "The request approves or denies a CertificateSigningRequest of a kubelet or the platform, which the cluster's machine approver decides on.",
+	PlatformImageStream:                "The request deletes an ImageStream of the openshift namespace, which images of the internal registry are resolved through. The Samples Operator recreates deleted samples while it's Managed, and stops managing those in its skippedImagestreams.",
+	PlatformPersistentVolume:           "The request deletes, or changes the reclaim policy of, a PersistentVolume bound to a claim in a platform namespace.",
+	PlatformServiceAccountToken:        "The request asks for a token of a service account in a platform namespace, which would let its holder act as the platform.",
+	PrivilegedPod:                      "The pod uses a privileged SCC, or equivalent privileged settings, in a customer namespace which isn't annotated to allow it.",
+	RequestsExceedNodeAllocatable:      "A container of the pod requests more CPU, memory or ephemeral storage than any node in the cluster can allocate, so the pod could never be scheduled.",
+	ReservedAPIGroup:                   "The CustomResourceDefinition is in an API group reserved for OpenShift and Red Hat managed components.",
+	ReservedCertificateHost:            "The CertificateSigningRequest asks for a certificate for a host reserved for the cluster's endpoints, or for the in-cluster host of the kubernetes Service or a platform Service.",
+	ReservedNamespaceName:              "The namespace name is reserved, as it would impact DNS resolution.",
+	ReservedRouteHost:                  "The Route host is reserved for the cluster's endpoints, or is a wildcard which could shadow them.",
+	SREAccess:                          "The request removes the access Red Hat SRE need to support the cluster.",
+	SREImpersonation:                   "The ClusterRole grants impersonating Red Hat SRE, or any user or group, which would let its subjects act with SRE's access.",
+	ServiceLoadBalancerQuota:           "The namespace already has as many LoadBalancer Services as its quota allows, on a cluster restricting them.",
+	ServiceNodePort:                    "The Service is of type NodePort, which customer namespaces may not use on clusters restricting them.",
+	TechPreviewFeatureSet:              "The TechPreviewNoUpgrade feature set can't be enabled on managed clusters.",
+	WebhookPolicy:                      "A WebhookPolicy configured on the cluster denies the request.",
+}
@@ -0,0 +1,63 @@
+// Package response builds the denials the webhooks return with a stable,
+// machine-readable reason code and a link to its documentation, so OpenShift
+// Cluster Manager and consoles can map denials to knowledge base articles
+// without parsing their messages, which may change.
+package response
+
+import (
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	// DocsURL documents every Code, each under a heading named after it
+	DocsURL string = "https://github.com/openshift/managed-cluster-validating-webhooks/blob/master/docs/denials.md"
+
+	// CauseTypeCode is the type of the status cause carrying the denial's Code
+	CauseTypeCode metav1.CauseType = "managed.openshift.io/code"
+	// CauseTypeDocumentation is the type of the status cause carrying the URL
+	// documenting the denial's Code
+	CauseTypeDocumentation metav1.CauseType = "managed.openshift.io/documentation"
+)
+
+// Code identifies why a webhook denied a request. Codes are part of the API
+// of the webhooks: once released, they may not be renamed or reused for
+// another reason.
+type Code string
+
+// Denied returns a response denying the request with the message, the way
+// admissionctl.Denied does, carrying the code as its status reason and the
+// code and its documentation URL as status causes. The API server returns
+// them to the client as they are.
+func Denied(code Code, message string) admissionctl.Response {
+	ret := admissionctl.Denied(message)
+	ret.Result.Reason = metav1.StatusReason(code)
+	ret.Result.Details = &metav1.StatusDetails{
+		Causes: []metav1.StatusCause{
+			{Type: CauseTypeCode, Message: string(code)},
+			{Type: CauseTypeDocumentation, Message: DocumentationURL(code)},
+		},
+	}
+	return ret
+}
+
+// DocumentationURL returns the URL documenting the code
+func DocumentationURL(code Code) string {
+	return DocsURL + "#" + strings.ToLower(string(code))
+}
+
+// CodeOf returns the Code of a denial built by Denied, or "" if the response
+// carries none
+func CodeOf(response admissionctl.Response) Code {
+	if response.Result == nil || response.Result.Details == nil {
+		return ""
+	}
+	for _, cause := range response.Result.Details.Causes {
+		if cause.Type == CauseTypeCode {
+			return Code(cause.Message)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,90 @@
+package response
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestDenied(t *testing.T) {
+	ret := Denied(ManagedStorageClass, "Prevented from deleting the managed StorageClass gp3-csi")
+	if ret.Allowed {
+		t.Fatalf("Expected the request to be denied")
+	}
+	if ret.Result.Code != 403 || ret.Result.Message != "Prevented from deleting the managed StorageClass gp3-csi" {
+		t.Errorf("Expected a 403 with the message, got %d %q", ret.Result.Code, ret.Result.Message)
+	}
+	if ret.Result.Reason != "ManagedStorageClass" {
+		t.Errorf("Expected the code as the reason, got %q", ret.Result.Reason)
+	}
+	if code := CodeOf(ret); code != ManagedStorageClass {
+		t.Errorf("Expected code %s, got %q", ManagedStorageClass, code)
+	}
+	causes := ret.Result.Details.Causes
+	if len(causes) != 2 || causes[1].Type != CauseTypeDocumentation || causes[1].Message != DocsURL+"#managedstorageclass" {
+		t.Errorf("Expected the documentation URL as a cause, got %+v", causes)
+	}
+	// Clients checking for Forbidden still see the denial as one
+	if !errors.IsForbidden(&errors.StatusError{ErrStatus: *ret.Result}) {
+		t.Errorf("Expected the denial to be Forbidden")
+	}
+}
+
+func TestCodeOf(t *testing.T) {
+	if code := CodeOf(admissionctl.Denied("no code")); code != "" {
+		t.Errorf("Expected no code, got %q", code)
+	}
+	if code := CodeOf(admissionctl.Allowed("")); code != "" {
+		t.Errorf("Expected no code, got %q", code)
+	}
+}
+
+func TestCodesDocumented(t *testing.T) {
+	raw, err := os.ReadFile("../../docs/denials.md")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	docs := string(raw)
+	for code, description := range Codes {
+		if !strings.Contains(docs, "## "+string(code)+"\n\n"+description+"\n") {
+			t.Errorf("Expected docs/denials.md to document %s as %q", code, description)
+		}
+	}
+	if headings := strings.Count(docs, "\n## "); headings != len(Codes) {
+		t.Errorf("Expected docs/denials.md to document %d codes, found %d", len(Codes), headings)
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	if warnings := Warnings(nil); warnings != nil {
+		t.Errorf("Expected no warnings, got %v", warnings)
+	}
+
+	long := strings.Repeat("é", MaxWarningLength)
+	warnings := Warnings([]string{"Rewrote the image\nof container cli", "", "  ", "Rewrote the image of container cli", "tab\tand\x07bell", long})
+	if len(warnings) != 3 {
+		t.Fatalf("Expected empty and repeated warnings to be dropped, got %q", warnings)
+	}
+	if warnings[0] != "Rewrote the image of container cli" || warnings[1] != "tab andbell" {
+		t.Errorf("Expected warnings made single lines without control characters, got %q", warnings)
+	}
+	if len(warnings[2]) > MaxWarningLength || !strings.HasSuffix(warnings[2], "é...") {
+		t.Errorf("Expected the long warning truncated to %d bytes on a character boundary, got %d bytes %q", MaxWarningLength, len(warnings[2]), warnings[2])
+	}
+
+	many := []string{}
+	for i := 0; i < 2*MaxWarningsLength/MaxWarningLength; i++ {
+		many = append(many, fmt.Sprintf("%03d%s", i, strings.Repeat("x", MaxWarningLength)))
+	}
+	total := 0
+	for _, warning := range Warnings(many) {
+		total += len(warning)
+	}
+	if total > MaxWarningsLength || total < MaxWarningsLength-MaxWarningLength {
+		t.Errorf("Expected the warnings to fill up to %d bytes, got %d", MaxWarningsLength, total)
+	}
+}
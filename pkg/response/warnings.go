@@ -0,0 +1,65 @@
+package response
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// MaxWarningLength is the length of a warning past which the API server
+	// may truncate it
+	MaxWarningLength = 256
+	// MaxWarningsLength is the total length of the warnings of a request past
+	// which the API server ignores the others
+	MaxWarningsLength = 4096
+
+	truncatedSuffix = "..."
+)
+
+// Warnings returns the warnings as the API server returns them to the client,
+// which kubectl and oc print, so they're worded consistently whichever
+// webhook sets them. Each warning is made a single line, as the API server
+// drops those with control characters, and truncated to MaxWarningLength.
+// Empty and repeated warnings are dropped, as are those past
+// MaxWarningsLength.
+func Warnings(warnings []string) []string {
+	if len(warnings) == 0 {
+		return nil
+	}
+	sanitized := make([]string, 0, len(warnings))
+	total := 0
+	for _, warning := range warnings {
+		warning = strings.Join(strings.FieldsFunc(warning, unicode.IsSpace), " ")
+		warning = strings.Map(func(r rune) rune {
+			if unicode.IsControl(r) {
+				return -1
+			}
+			return r
+		}, warning)
+		if warning == "" || slices.Contains(sanitized, warning) {
+			continue
+		}
+		if len(warning) > MaxWarningLength {
+			warning = truncate(warning, MaxWarningLength-len(truncatedSuffix)) + truncatedSuffix
+		}
+		if total+len(warning) > MaxWarningsLength {
+			break
+		}
+		total += len(warning)
+		sanitized = append(sanitized, warning)
+	}
+	return sanitized
+}
+
+// truncate shortens s to at most n bytes without splitting a character
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
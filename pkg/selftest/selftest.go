@@ -0,0 +1,241 @@
+// Package selftest replays canned admission requests through the registered
+// webhooks and reports whether each still answers them as expected, to check
+// behaviour hasn't changed between releases.
+package selftest
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/dependencies"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks"
+)
+
+// fixtures is the corpus built into the binary, one directory per webhook, so
+// the selftest can run on a cluster as well as in CI
+//
+//go:embed fixtures
+var fixtures embed.FS
+
+// Fixture is one canned admission request and the expected answer
+type Fixture struct {
+	// Description says what the fixture checks
+	Description string `json:"description"`
+	// Request is sent to the webhook's Authorized
+	Request admissionv1.AdmissionRequest `json:"request"`
+	// Objects are served by the client of webhooks which read the cluster
+	Objects []map[string]interface{} `json:"objects,omitempty"`
+	// Allowed is whether the webhook should admit the request
+	Allowed bool `json:"allowed"`
+	// Patched, when set, is whether the webhook should mutate the request
+	Patched *bool `json:"patched,omitempty"`
+
+	// file the fixture was loaded from
+	file string
+}
+
+// Result is the outcome of one fixture
+type Result struct {
+	Fixture Fixture
+	// Failure explains why the fixture failed, or is empty when it passed
+	Failure string
+}
+
+// WebhookReport is the outcome of all of a webhook's fixtures
+type WebhookReport struct {
+	Webhook string
+	Results []Result
+}
+
+// Passed is true when none of the webhook's fixtures failed
+func (r WebhookReport) Passed() bool {
+	for _, result := range r.Results {
+		if result.Failure != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Report is the outcome of the selftest, sorted by webhook
+type Report []WebhookReport
+
+// Passed is true when no webhook failed
+func (r Report) Passed() bool {
+	for _, webhook := range r {
+		if !webhook.Passed() {
+			return false
+		}
+	}
+	return true
+}
+
+// Print writes a per-webhook pass/fail report, detailing failures
+func (r Report) Print(w io.Writer) {
+	for _, webhook := range r {
+		switch {
+		case len(webhook.Results) == 0:
+			fmt.Fprintf(w, "SKIP %s: no fixtures\n", webhook.Webhook)
+			continue
+		case webhook.Passed():
+			fmt.Fprintf(w, "PASS %s: %d/%d\n", webhook.Webhook, len(webhook.Results), len(webhook.Results))
+			continue
+		}
+		failed := 0
+		for _, result := range webhook.Results {
+			if result.Failure != "" {
+				failed++
+			}
+		}
+		fmt.Fprintf(w, "FAIL %s: %d/%d\n", webhook.Webhook, len(webhook.Results)-failed, len(webhook.Results))
+		for _, result := range webhook.Results {
+			if result.Failure != "" {
+				fmt.Fprintf(w, "    %s (%s): %s\n", result.Fixture.Description, result.Fixture.file, result.Failure)
+			}
+		}
+	}
+}
+
+// Fixtures returns the built-in corpus, or the corpus in dir if it isn't empty
+func Fixtures(dir string) (fs.FS, error) {
+	if dir != "" {
+		return os.DirFS(dir), nil
+	}
+	return fs.Sub(fixtures, "fixtures")
+}
+
+// Load reads each webhook's fixtures from the directory named after it.
+// Fixtures are YAML or JSON files.
+func Load(fsys fs.FS) (map[string][]Fixture, error) {
+	loaded := map[string][]Fixture{}
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := path.Ext(p)
+		if d.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			return nil
+		}
+		webhook := strings.Split(p, "/")[0]
+		if webhook == p {
+			return fmt.Errorf("fixture %s is not in a webhook's directory", p)
+		}
+
+		raw, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		fixture := Fixture{}
+		if err := yaml.Unmarshal(raw, &fixture); err != nil {
+			return fmt.Errorf("couldn't parse fixture %s: %w", p, err)
+		}
+		fixture.file = p
+		loaded[webhook] = append(loaded[webhook], fixture)
+		return nil
+	})
+	return loaded, err
+}
+
+// Run replays the fixtures through the registered webhooks. Fixtures for
+// webhooks which aren't registered fail.
+func Run(hooks webhooks.RegisteredWebhooks, fixtures map[string][]Fixture, scheme *runtime.Scheme) Report {
+	names := map[string]bool{}
+	for name := range hooks {
+		names[name] = true
+	}
+	for name := range fixtures {
+		names[name] = true
+	}
+
+	report := Report{}
+	for name := range names {
+		webhook := WebhookReport{Webhook: name}
+		for _, fixture := range fixtures[name] {
+			webhook.Results = append(webhook.Results, Result{
+				Fixture: fixture,
+				Failure: replay(hooks[name], fixture, scheme),
+			})
+		}
+		report = append(report, webhook)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Webhook < report[j].Webhook })
+	return report
+}
+
+// replay sends the fixture's request to a new webhook, returning why the
+// answer was unexpected or "" if it was as expected
+func replay(factory webhooks.WebhookFactory, fixture Fixture, scheme *runtime.Scheme) string {
+	if factory == nil {
+		return "webhook is not registered"
+	}
+	hook, deps, request := prepare(factory, fixture, scheme)
+	if !hook.Validate(request) {
+		return "request is not valid for the webhook"
+	}
+	response := webhooks.Admit(context.Background(), hook, deps, request)
+
+	if response.Allowed != fixture.Allowed {
+		reason := ""
+		if response.Result != nil {
+			reason = response.Result.Message
+		}
+		return fmt.Sprintf("expected allowed %v, got %v: %s", fixture.Allowed, response.Allowed, reason)
+	}
+	if fixture.Patched != nil {
+		patched := len(response.Patch) > 0 || len(response.Patches) > 0
+		if patched != *fixture.Patched {
+			return fmt.Sprintf("expected patched %v, got %v", *fixture.Patched, patched)
+		}
+	}
+	return ""
+}
+
+// Record sets the fixture's expected answer to the one the webhook gives now,
+// for fixtures generated from live objects rather than written by hand
+func Record(factory webhooks.WebhookFactory, fixture *Fixture, scheme *runtime.Scheme) error {
+	hook, deps, request := prepare(factory, *fixture, scheme)
+	if !hook.Validate(request) {
+		return fmt.Errorf("request is not valid for webhook %s", hook.Name())
+	}
+	response := webhooks.Admit(context.Background(), hook, deps, request)
+	patched := len(response.Patch) > 0 || len(response.Patches) > 0
+	fixture.Allowed = response.Allowed
+	fixture.Patched = &patched
+	return nil
+}
+
+// prepare creates the webhook, the dependencies serving the fixture's objects
+// to webhooks which read the cluster, and the request to send it
+func prepare(factory webhooks.WebhookFactory, fixture Fixture, scheme *runtime.Scheme) (webhooks.Webhook, dependencies.Bundle, admissionctl.Request) {
+	hook := factory()
+	deps := dependencies.Bundle{}
+	if _, ok := hook.(webhooks.CachingWebhook); ok {
+		deps.Client = fixtureClient(fixture, scheme)
+	}
+	return hook, deps, admissionctl.Request{AdmissionRequest: fixture.Request}
+}
+
+// fixtureClient serves the fixture's objects
+func fixtureClient(fixture Fixture, scheme *runtime.Scheme) client.Client {
+	objects := []client.Object{}
+	for _, object := range fixture.Objects {
+		// the fake client sets the resourceVersion of the objects it's given
+		objects = append(objects, &unstructured.Unstructured{Object: runtime.DeepCopyJSON(object)})
+	}
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+}
@@ -0,0 +1,335 @@
+package selftest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"gomodules.xyz/jsonpatch/v2"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks"
+)
+
+// allocationBudgets is the most allocations each webhook may make answering
+// any of its built-in fixtures, including reads from the fixture's fake
+// client. A change which makes a webhook allocate more on its hot path fails
+// TestAllocationBudgets; raise the budget only when the cost is intended.
+var allocationBudgets = map[string]float64{
+	"certificatesigningrequest-validation": 240,
+	"clusterautoscaler-validation":         80,
+	"clusterconfig-validation":             25,
+	"clusterlogforwarder-validation":       80,
+	"clusterversion-validation":            155,
+	"costlabels-mutation":                  560,
+	"customresourcedefinitions-validation": 185,
+	"debugpodsecurity-mutation":            240,
+	"debugpodtolerations-mutation":         225,
+	"defaultingresscontroller-validation":  10,
+	"etcd-validation":                      20,
+	"finalizers-validation":                130,
+	"hatopology-validation":                480,
+	"hivedeletion-validation":              20,
+	"hostaccess-validation":                325,
+	"hostedclusterspec-validation":         35,
+	"imageprovenance-validation":           520,
+	"imagestream-validation":               20,
+	"impersonation-validation":             115,
+	"labeledresources-validation":          40,
+	"machineset-validation":                180,
+	"managednetworkpolicy-validation":      375,
+	"managedrbac-validation":               65,
+	"monitoringconfig-validation":          260,
+	"namespace-validation":                 375,
+	"namespacelabels-mutation":             310,
+	"namespacerate-validation":             20,
+	"networkconfig-validation":             125,
+	"nodelabels-validation":                115,
+	"oauth-validation":                     100,
+	"objectquota-validation":               400,
+	"operatorscale-validation":             25,
+	"persistentvolume-validation":          200,
+	"poddisruptionbudget-validation":       440,
+	"podimagespec-mutation":                250,
+	"podresources-validation":              605,
+	"priorityclass-validation":             20,
+	"privilegedscc-validation":             75,
+	"routehosts-validation":                175,
+	"servicetype-validation":               320,
+	"storageclass-validation":              160,
+	"webhookconfigurations-validation":     60,
+}
+
+// goldenSchemaVersion is the version of the golden patch files' format. Bump
+// it, and rewrite them with -update, when changing goldenPatch.
+const goldenSchemaVersion = 1
+
+// goldenDir holds the golden patches, in a directory per mutating webhook and
+// a file per fixture
+const goldenDir = "testdata/patches"
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden patches of the mutating webhooks' fixtures")
+
+// goldenPatch is a mutating webhook's answer to one of its fixtures. The
+// operations of its patch are sorted by path.
+type goldenPatch struct {
+	SchemaVersion int                            `json:"schemaVersion"`
+	Description   string                         `json:"description"`
+	Allowed       bool                           `json:"allowed"`
+	Patch         []jsonpatch.JsonPatchOperation `json:"patch"`
+}
+
+func loadBuiltinFixtures(tb testing.TB) (map[string][]Fixture, *runtime.Scheme) {
+	fsys, err := Fixtures("")
+	if err != nil {
+		tb.Fatalf("Expected no error, got %v", err)
+	}
+	fixtures, err := Load(fsys)
+	if err != nil {
+		tb.Fatalf("Expected no error, got %v", err)
+	}
+	if len(fixtures) == 0 {
+		tb.Fatalf("Expected built-in fixtures")
+	}
+	scheme, err := k8sutil.SharedScheme()
+	if err != nil {
+		tb.Fatalf("Expected no error, got %v", err)
+	}
+	return fixtures, scheme
+}
+
+// sortedWebhooks returns the names of the webhooks with fixtures, sorted
+func sortedWebhooks(fixtures map[string][]Fixture) []string {
+	names := []string{}
+	for name := range fixtures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TestBuiltinFixtures keeps the built-in corpus passing
+func TestBuiltinFixtures(t *testing.T) {
+	fixtures, scheme := loadBuiltinFixtures(t)
+
+	report := Run(webhooks.Webhooks, fixtures, scheme)
+	if !report.Passed() {
+		out := &bytes.Buffer{}
+		report.Print(out)
+		t.Fatalf("Expected the built-in fixtures to pass:\n%s", out.String())
+	}
+}
+
+func TestRunReport(t *testing.T) {
+	fsys := fstest.MapFS{
+		"namespace-validation/wrong.yaml": {Data: []byte(`
+description: customers may not create Red Hat managed namespaces
+request:
+  uid: test
+  kind: {group: "", version: v1, kind: Namespace}
+  resource: {group: "", version: v1, resource: namespaces}
+  operation: CREATE
+  name: openshift-monitoring
+  userInfo: {username: customer}
+  object: {apiVersion: v1, kind: Namespace, metadata: {name: openshift-monitoring}}
+allowed: true
+`)},
+		"unregistered-validation/any.json": {Data: []byte(`{"description": "unregistered", "allowed": true}`)},
+	}
+	fixtures, err := Load(fsys)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	scheme, err := k8sutil.SharedScheme()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	report := Run(webhooks.Webhooks, fixtures, scheme)
+	if report.Passed() {
+		t.Fatalf("Expected the report to fail")
+	}
+	out := &bytes.Buffer{}
+	report.Print(out)
+	for _, expected := range []string{
+		"FAIL namespace-validation: 0/1",
+		"expected allowed true, got false",
+		"FAIL unregistered-validation: 0/1",
+		"webhook is not registered",
+		"SKIP pod-validation: no fixtures",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected report to contain %q, got:\n%s", expected, out.String())
+		}
+	}
+}
+
+func TestLoadRequiresWebhookDirectory(t *testing.T) {
+	if _, err := Load(fstest.MapFS{"fixture.yaml": {Data: []byte(`{}`)}}); err == nil {
+		t.Fatalf("Expected an error for a fixture outside a webhook's directory")
+	}
+}
+
+// TestGoldenPatches keeps the exact JSONPatch each mutating webhook answers
+// its fixtures with, so refactoring a webhook can't silently change the
+// patches it emits. Run the test with -update to rewrite the golden patches
+// after an intended change, and review their diff.
+func TestGoldenPatches(t *testing.T) {
+	fixtures, scheme := loadBuiltinFixtures(t)
+
+	expected := map[string]bool{}
+	for _, name := range sortedWebhooks(fixtures) {
+		if !webhooks.IsMutating(name) {
+			continue
+		}
+		for _, fixture := range fixtures[name] {
+			file := path.Join(goldenDir, strings.TrimSuffix(fixture.file, path.Ext(fixture.file))+".json")
+			expected[file] = true
+
+			hook, deps, request := prepare(webhooks.Webhooks[name], fixture, scheme)
+			response := webhooks.Admit(context.Background(), hook, deps, request)
+			patch := append([]jsonpatch.JsonPatchOperation{}, response.Patches...)
+			if len(response.Patch) > 0 {
+				if err := json.Unmarshal(response.Patch, &patch); err != nil {
+					t.Fatalf("Webhook %s answered %s with an invalid patch: %v", name, fixture.file, err)
+				}
+			}
+			// PatchResponseFromRaw emits operations in random order
+			sort.SliceStable(patch, func(i, j int) bool {
+				if patch[i].Path != patch[j].Path {
+					return patch[i].Path < patch[j].Path
+				}
+				return patch[i].Operation < patch[j].Operation
+			})
+			actual, err := json.MarshalIndent(goldenPatch{
+				SchemaVersion: goldenSchemaVersion,
+				Description:   fixture.Description,
+				Allowed:       response.Allowed,
+				Patch:         patch,
+			}, "", "  ")
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			actual = append(actual, '\n')
+
+			if *updateGolden {
+				if err := os.MkdirAll(path.Dir(file), 0o755); err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				if err := os.WriteFile(file, actual, 0o644); err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				continue
+			}
+			golden, err := os.ReadFile(file)
+			if err != nil {
+				t.Errorf("Webhook %s has no golden patch for %s, run the test with -update: %v", name, fixture.file, err)
+				continue
+			}
+			version := goldenPatch{}
+			if err := json.Unmarshal(golden, &version); err != nil || version.SchemaVersion != goldenSchemaVersion {
+				t.Errorf("Golden patch %s isn't of schema version %d, run the test with -update", file, goldenSchemaVersion)
+				continue
+			}
+			if !bytes.Equal(golden, actual) {
+				t.Errorf("Webhook %s answered %s differently from %s, run the test with -update if the change is intended.\nExpected:\n%s\nGot:\n%s", name, fixture.file, file, golden, actual)
+			}
+		}
+	}
+
+	// Golden patches of removed fixtures would never be checked again
+	err := filepath.WalkDir(goldenDir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || expected[filepath.ToSlash(file)] {
+			return err
+		}
+		if *updateGolden {
+			return os.Remove(file)
+		}
+		t.Errorf("Golden patch %s has no fixture, run the test with -update", file)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+}
+
+// TestAllocationBudgets keeps the allocations of each webhook answering its
+// fixtures within its budget
+func TestAllocationBudgets(t *testing.T) {
+	fixtures, scheme := loadBuiltinFixtures(t)
+
+	for _, name := range sortedWebhooks(fixtures) {
+		budget, ok := allocationBudgets[name]
+		if !ok {
+			t.Errorf("Webhook %s has fixtures but no allocation budget", name)
+			continue
+		}
+		for _, fixture := range fixtures[name] {
+			hook, deps, request := prepare(webhooks.Webhooks[name], fixture, scheme)
+			allocs := testing.AllocsPerRun(20, func() { webhooks.Admit(context.Background(), hook, deps, request) })
+			if allocs > budget {
+				t.Errorf("Webhook %s allocated %.0f times answering %s, over its budget of %.0f", name, allocs, fixture.file, budget)
+			}
+		}
+	}
+}
+
+// BenchmarkFixtures measures each webhook answering each of its fixtures
+func BenchmarkFixtures(b *testing.B) {
+	fixtures, scheme := loadBuiltinFixtures(b)
+
+	for _, name := range sortedWebhooks(fixtures) {
+		for _, fixture := range fixtures[name] {
+			b.Run(strings.TrimSuffix(fixture.file, path.Ext(fixture.file)), func(b *testing.B) {
+				hook, deps, request := prepare(webhooks.Webhooks[name], fixture, scheme)
+				b.ReportAllocs()
+				for b.Loop() {
+					webhooks.Admit(context.Background(), hook, deps, request)
+				}
+			})
+		}
+	}
+}
+
+// FuzzFixtures sends each webhook malformed and truncated versions of its
+// fixtures' objects, which it must answer or reject without panicking
+func FuzzFixtures(f *testing.F) {
+	fixtures, scheme := loadBuiltinFixtures(f)
+
+	for _, name := range sortedWebhooks(fixtures) {
+		for i, fixture := range fixtures[name] {
+			object, oldObject := fixture.Request.Object.Raw, fixture.Request.OldObject.Raw
+			f.Add(name, i, object, oldObject)
+			f.Add(name, i, object[:len(object)/2], oldObject[:len(oldObject)/2])
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, name string, i int, object, oldObject []byte) {
+		factory, ok := webhooks.Webhooks[name]
+		if !ok || len(fixtures[name]) == 0 || i < 0 {
+			return
+		}
+		fixture := fixtures[name][i%len(fixtures[name])]
+		fixture.Request.Object = runtime.RawExtension{Raw: object}
+		fixture.Request.OldObject = runtime.RawExtension{Raw: oldObject}
+
+		hook, deps, request := prepare(factory, fixture, scheme)
+		if !hook.Validate(request) {
+			return
+		}
+		response := webhooks.Admit(context.Background(), hook, deps, request)
+		if !response.Allowed && response.Result == nil {
+			t.Fatalf("Expected webhook %s to explain why it didn't allow the request", name)
+		}
+	})
+}
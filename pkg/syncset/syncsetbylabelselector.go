@@ -13,6 +13,7 @@ import (
 
 	admissionregv1 "k8s.io/api/admissionregistration/v1"
 	v1 "k8s.io/api/apps/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 
 	hivev1 "github.com/openshift/hive/apis/hive/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -185,3 +186,31 @@ func EncodeMutatingAndFixCA(vw admissionregv1.MutatingWebhookConfiguration) ([]b
 	}
 	return r, nil
 }
+
+// EncodeAggregatedClusterRole leaves the rules out of an aggregated
+// ClusterRole, as they're filled in by the aggregation controller and syncing
+// "rules: null" would keep clearing them
+func EncodeAggregatedClusterRole(cr *rbacv1.ClusterRole) ([]byte, error) {
+	if cr.AggregationRule == nil {
+		return nil, fmt.Errorf("ClusterRole %s is not aggregated", cr.Name)
+	}
+
+	// Convert to json
+	o, err := json.Marshal(cr)
+	if err != nil {
+		return nil, err
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(o, &decoded); err != nil {
+		return nil, err
+	}
+	delete(decoded, "rules")
+
+	// convert back to json
+	r, err := json.Marshal(decoded)
+	if err != nil {
+		return nil, fmt.Errorf("Error encoding %+v\n", decoded)
+	}
+	return r, nil
+}
@@ -0,0 +1,295 @@
+// Package envtest runs webhooks behind a real kube-apiserver, so tests exercise
+// the admission requests the API server sends rather than hand-built ones.
+//
+// It needs the envtest binaries (kube-apiserver and etcd), which are found
+// through KUBEBUILDER_ASSETS. Tests are skipped when they aren't installed:
+//
+//	go run sigs.k8s.io/controller-runtime/tools/setup-envtest@latest use -p path
+package envtest
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crenvtest "sigs.k8s.io/controller-runtime/pkg/envtest"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/dispatcher"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks"
+)
+
+// AssetsEnvVar points at the envtest binaries
+const AssetsEnvVar = "KUBEBUILDER_ASSETS"
+
+// Environment is a kube-apiserver sending admission requests to the webhooks
+// served in-process
+type Environment struct {
+	// Client talks to the API server as an admin
+	Client client.Client
+	// Config is the admin's rest config
+	Config *rest.Config
+
+	env    *crenvtest.Environment
+	server *http.Server
+}
+
+// Start starts an API server with the generated webhook configurations of the
+// named webhooks installed. The test is skipped when the envtest binaries
+// aren't available, and the environment is stopped when the test ends.
+func Start(t *testing.T, names ...string) *Environment {
+	t.Helper()
+	if os.Getenv(AssetsEnvVar) == "" {
+		t.Skipf("%s is not set, skipping envtest", AssetsEnvVar)
+	}
+
+	e, err := start(names)
+	if err != nil {
+		t.Fatalf("Failed to start envtest: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := e.stop(); err != nil {
+			t.Errorf("Failed to stop envtest: %v", err)
+		}
+	})
+	return e
+}
+
+func start(names []string) (*Environment, error) {
+	validating, mutating, err := generatedConfigurations(names)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Environment{
+		env: &crenvtest.Environment{
+			WebhookInstallOptions: crenvtest.WebhookInstallOptions{
+				ValidatingWebhooks: validating,
+				MutatingWebhooks:   mutating,
+			},
+		},
+	}
+	if e.Config, err = e.env.Start(); err != nil {
+		return nil, err
+	}
+	if err := e.serve(); err != nil {
+		_ = e.env.Stop()
+		return nil, err
+	}
+	if e.Client, err = client.New(e.Config, client.Options{}); err != nil {
+		_ = e.stop()
+		return nil, err
+	}
+	if err := e.grantAuthenticated(); err != nil {
+		_ = e.stop()
+		return nil, err
+	}
+	return e, nil
+}
+
+// grantAuthenticated makes every user a cluster-admin as far as RBAC is
+// concerned, so only the webhooks decide whether requests are admitted
+func (e *Environment) grantAuthenticated() error {
+	return e.Client.Create(context.Background(), &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "envtest-authenticated-cluster-admin"},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     "cluster-admin",
+		},
+		Subjects: []rbacv1.Subject{{
+			APIGroup: rbacv1.GroupName,
+			Kind:     rbacv1.GroupKind,
+			Name:     "system:authenticated",
+		}},
+	})
+}
+
+// serve serves the webhooks on the address and certificate the webhook
+// configurations were pointed at
+func (e *Environment) serve() error {
+	options := e.env.WebhookInstallOptions
+	cert, err := tls.LoadX509KeyPair(
+		filepath.Join(options.LocalServingCertDir, "tls.crt"),
+		filepath.Join(options.LocalServingCertDir, "tls.key"),
+	)
+	if err != nil {
+		return err
+	}
+
+	d := dispatcher.NewDispatcher(webhooks.Webhooks)
+	mux := http.NewServeMux()
+	for _, hook := range webhooks.Webhooks {
+		mux.HandleFunc(hook().GetURI(), d.HandleRequest)
+	}
+
+	listener, err := tls.Listen("tcp", net.JoinHostPort(options.LocalServingHost, strconv.Itoa(options.LocalServingPort)), &tls.Config{
+		Certificates: []tls.Certificate{cert},
+	})
+	if err != nil {
+		return err
+	}
+	e.server = &http.Server{Handler: mux}
+	go func() {
+		if err := e.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Fprintf(os.Stderr, "envtest webhook server failed: %v\n", err)
+		}
+	}()
+	return nil
+}
+
+func (e *Environment) stop() error {
+	if e.server != nil {
+		_ = e.server.Shutdown(context.Background())
+	}
+	return e.env.Stop()
+}
+
+// ClientAs returns a client impersonating the user, so requests are admitted
+// as they would be for them
+func (e *Environment) ClientAs(username string, groups ...string) (client.Client, error) {
+	config := rest.CopyConfig(e.Config)
+	config.Impersonate = rest.ImpersonationConfig{
+		UserName: username,
+		Groups:   groups,
+	}
+	return client.New(config, client.Options{Scheme: e.Client.Scheme()})
+}
+
+// Case is one admission request made as a user
+type Case struct {
+	Name     string
+	Username string
+	Groups   []string
+	// Do makes the request with a client impersonating the user
+	Do func(ctx context.Context, c client.Client) error
+	// Allowed is whether the webhook should admit the request
+	Allowed bool
+}
+
+// Run runs the cases as subtests
+func (e *Environment) Run(t *testing.T, cases []Case) {
+	t.Helper()
+	for _, test := range cases {
+		t.Run(test.Name, func(t *testing.T) {
+			c, err := e.ClientAs(test.Username, test.Groups...)
+			if err != nil {
+				t.Fatalf("Failed to create client for %s: %v", test.Username, err)
+			}
+			err = test.Do(context.Background(), c)
+			if test.Allowed && err != nil {
+				t.Fatalf("Expected request to be allowed, got %v", err)
+			}
+			if !test.Allowed && !IsDenied(err) {
+				t.Fatalf("Expected request to be denied by a webhook, got %v", err)
+			}
+		})
+	}
+}
+
+// IsDenied checks the error is a webhook denying the request
+func IsDenied(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "denied the request")
+}
+
+// generatedConfigurations loads the named webhooks' configurations from the
+// generated SelectorSyncSet, so the configurations which ship are tested. Only
+// webhooks enabled on classic clusters are in the SelectorSyncSet.
+func generatedConfigurations(names []string) ([]*admissionregv1.ValidatingWebhookConfiguration, []*admissionregv1.MutatingWebhookConfiguration, error) {
+	_, file, _, _ := runtime.Caller(0)
+	raw, err := os.ReadFile(filepath.Join(filepath.Dir(file), "..", "..", "..", "build", "selectorsyncset.yaml"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := struct {
+		Objects []struct {
+			Spec struct {
+				Resources []map[string]interface{} `json:"resources"`
+			} `json:"spec"`
+		} `json:"objects"`
+	}{}
+	if err := yaml.Unmarshal(raw, &template); err != nil {
+		return nil, nil, err
+	}
+
+	wanted := map[string]bool{}
+	for _, name := range names {
+		wanted["sre-"+name] = true
+	}
+	seen := map[string]bool{}
+	validating := []*admissionregv1.ValidatingWebhookConfiguration{}
+	mutating := []*admissionregv1.MutatingWebhookConfiguration{}
+	for _, sss := range template.Objects {
+		for _, resource := range sss.Spec.Resources {
+			kind, _ := resource["kind"].(string)
+			if kind != "ValidatingWebhookConfiguration" && kind != "MutatingWebhookConfiguration" {
+				continue
+			}
+			b, err := yaml.Marshal(resource)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			if kind == "ValidatingWebhookConfiguration" {
+				config := &admissionregv1.ValidatingWebhookConfiguration{}
+				if err := yaml.Unmarshal(b, config); err != nil {
+					return nil, nil, err
+				}
+				if !wanted[config.Name] || seen[config.Name] {
+					continue
+				}
+				seen[config.Name] = true
+				for i := range config.Webhooks {
+					localClientConfig(&config.Webhooks[i].ClientConfig)
+				}
+				validating = append(validating, config)
+				continue
+			}
+
+			config := &admissionregv1.MutatingWebhookConfiguration{}
+			if err := yaml.Unmarshal(b, config); err != nil {
+				return nil, nil, err
+			}
+			if !wanted[config.Name] || seen[config.Name] {
+				continue
+			}
+			seen[config.Name] = true
+			for i := range config.Webhooks {
+				localClientConfig(&config.Webhooks[i].ClientConfig)
+			}
+			mutating = append(mutating, config)
+		}
+	}
+
+	for name := range wanted {
+		if !seen[name] {
+			return nil, nil, fmt.Errorf("no generated webhook configuration %s", name)
+		}
+	}
+	return validating, mutating, nil
+}
+
+// localClientConfig prepares the service client config for envtest, which
+// replaces it with a URL. envtest adds its own leading slash to the path.
+func localClientConfig(cc *admissionregv1.WebhookClientConfig) {
+	cc.CABundle = nil
+	if cc.Service != nil && cc.Service.Path != nil {
+		path := strings.TrimPrefix(*cc.Service.Path, "/")
+		cc.Service.Path = &path
+	}
+}
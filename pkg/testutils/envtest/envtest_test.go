@@ -0,0 +1,26 @@
+package envtest
+
+import (
+	"testing"
+)
+
+func TestGeneratedConfigurations(t *testing.T) {
+	validating, mutating, err := generatedConfigurations([]string{"namespace-validation", "debugpodtolerations-mutation"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(validating) != 1 || validating[0].Name != "sre-namespace-validation" {
+		t.Fatalf("Expected sre-namespace-validation, got %v", validating)
+	}
+	if len(mutating) != 1 || mutating[0].Name != "sre-debugpodtolerations-mutation" {
+		t.Fatalf("Expected sre-debugpodtolerations-mutation, got %v", mutating)
+	}
+	service := validating[0].Webhooks[0].ClientConfig.Service
+	if service == nil || service.Path == nil || *service.Path != "namespace-validation" {
+		t.Fatalf("Expected the service path without its leading slash, got %v", service)
+	}
+
+	if _, _, err := generatedConfigurations([]string{"no-such-webhook"}); err == nil {
+		t.Fatalf("Expected an error for a webhook without a configuration")
+	}
+}
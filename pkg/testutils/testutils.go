@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"testing"
 
 	admissionv1 "k8s.io/api/admission/v1"
 	authenticationv1 "k8s.io/api/authentication/v1"
@@ -93,6 +95,48 @@ func CreateFakeRequestJSON(uid string,
 	return b, nil
 }
 
+// NewRequest builds the admission request a webhook's Authorized receives for
+// the given kind, operation and user, with UID "test-uid". obj and oldObject
+// are marshalled into the request's Object and OldObject; either may be nil,
+// and a []byte is used as already encoded JSON. Callers set any other fields,
+// such as Resource or SubResource, on the returned request.
+func NewRequest(t testing.TB,
+	gvk metav1.GroupVersionKind,
+	operation admissionv1.Operation,
+	user authenticationv1.UserInfo, namespace, name string,
+	obj, oldObject interface{}) admissionctl.Request {
+	t.Helper()
+	return admissionctl.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			UID:       "test-uid",
+			Kind:      gvk,
+			Operation: operation,
+			Namespace: namespace,
+			Name:      name,
+			UserInfo:  user,
+			Object:    RawObject(t, obj),
+			OldObject: RawObject(t, oldObject),
+		},
+	}
+}
+
+// RawObject marshals obj for use as a request's Object or OldObject. A nil obj
+// gives an empty RawExtension and a []byte is used as already encoded JSON.
+func RawObject(t testing.TB, obj interface{}) runtime.RawExtension {
+	t.Helper()
+	if raw, ok := obj.([]byte); ok {
+		return runtime.RawExtension{Raw: raw}
+	}
+	if v := reflect.ValueOf(obj); !v.IsValid() || (v.Kind() == reflect.Ptr && v.IsNil()) {
+		return runtime.RawExtension{}
+	}
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatalf("Unexpected error marshalling %T: %v", obj, err)
+	}
+	return runtime.RawExtension{Raw: raw}
+}
+
 // CreateHTTPRequest takes all the information needed for an AdmissionReview.
 // See also CreateFakeRequestJSON for more.
 func CreateHTTPRequest(uri, uid string,
@@ -0,0 +1,55 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// tracedClient starts a span for each read, so slow lookups show up under the
+// admission request which made them
+type tracedClient struct {
+	client.Client
+}
+
+// Client wraps c so its reads are traced. Writes aren't traced.
+func Client(c client.Client) client.Client {
+	if c == nil {
+		return nil
+	}
+	return &tracedClient{Client: c}
+}
+
+// Get implements client.Reader
+func (c *tracedClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	ctx, span := Start(ctx, "client.Get",
+		attribute.String("k8s.kind", c.kind(obj)),
+		attribute.String("k8s.namespace", key.Namespace),
+		attribute.String("k8s.name", key.Name),
+	)
+	err := c.Client.Get(ctx, key, obj, opts...)
+	End(span, err)
+	return err
+}
+
+// List implements client.Reader
+func (c *tracedClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	ctx, span := Start(ctx, "client.List",
+		attribute.String("k8s.kind", c.kind(list)),
+	)
+	err := c.Client.List(ctx, list, opts...)
+	End(span, err)
+	return err
+}
+
+// kind names the kind of obj for a span, or returns "" if it isn't known
+func (c *tracedClient) kind(obj runtime.Object) string {
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil {
+		return ""
+	}
+	return gvk.Kind
+}
@@ -0,0 +1,112 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestEnabled(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		expected bool
+	}{
+		{
+			name:     "no endpoint",
+			expected: false,
+		},
+		{
+			name:     "endpoint",
+			env:      map[string]string{EndpointEnvVar: "http://otel-collector:4318"},
+			expected: true,
+		},
+		{
+			name:     "traces endpoint",
+			env:      map[string]string{TracesEndpointEnvVar: "http://otel-collector:4318/v1/traces"},
+			expected: true,
+		},
+		{
+			name:     "sdk disabled",
+			env:      map[string]string{EndpointEnvVar: "http://otel-collector:4318", "OTEL_SDK_DISABLED": "true"},
+			expected: false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, name := range []string{EndpointEnvVar, TracesEndpointEnvVar, "OTEL_SDK_DISABLED"} {
+				t.Setenv(name, test.env[name])
+			}
+			if actual := Enabled(); actual != test.expected {
+				t.Errorf("Expected enabled %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestClient(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	c := Client(fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(&corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "openshift-monitoring"},
+	}).Build())
+
+	ctx, parent := Start(context.Background(), "admission")
+	if err := c.Get(ctx, client.ObjectKey{Name: "openshift-monitoring"}, &corev1.Namespace{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := c.Get(ctx, client.ObjectKey{Name: "missing"}, &corev1.Namespace{}); err == nil {
+		t.Fatalf("Expected an error getting a missing namespace")
+	}
+	if err := c.List(ctx, &corev1.PodList{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 4 {
+		t.Fatalf("Expected 4 spans, got %d", len(spans))
+	}
+	expected := []struct {
+		name   string
+		kind   string
+		status codes.Code
+	}{
+		{name: "client.Get", kind: "Namespace", status: codes.Unset},
+		{name: "client.Get", kind: "Namespace", status: codes.Error},
+		{name: "client.List", kind: "PodList", status: codes.Unset},
+	}
+	for i, e := range expected {
+		span := spans[i]
+		if span.Name() != e.name {
+			t.Errorf("Expected span %d to be %s, got %s", i, e.name, span.Name())
+		}
+		if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("Expected span %d to be a child of the admission span", i)
+		}
+		if span.Status().Code != e.status {
+			t.Errorf("Expected span %d status %v, got %v", i, e.status, span.Status().Code)
+		}
+		kind := ""
+		for _, attr := range span.Attributes() {
+			if attr.Key == "k8s.kind" {
+				kind = attr.Value.AsString()
+			}
+		}
+		if kind != e.kind {
+			t.Errorf("Expected span %d kind %s, got %s", i, e.kind, kind)
+		}
+	}
+}
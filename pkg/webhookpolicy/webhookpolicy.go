@@ -0,0 +1,281 @@
+// Package webhookpolicy keeps the WebhookPolicies enforced by the
+// webhookpolicy-validation webhook in sync with the cluster, and configures
+// the API server to send the webhook the requests they select.
+package webhookpolicy
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	toolscache "k8s.io/client-go/tools/cache"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/config"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/apis/managed/v1alpha1"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/featuregates"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks"
+	policyhook "github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/webhookpolicy"
+)
+
+const (
+	// resyncPeriod is how often the configuration is reconciled again, in
+	// case it was changed or removed
+	resyncPeriod = 5 * time.Minute
+)
+
+var log = logf.Log.WithName("webhookpolicy")
+
+// Watcher enforces the WebhookPolicies on the cluster. On every replica it
+// hands them to the webhook; on the elected leader it also keeps the
+// webhook's ValidatingWebhookConfiguration selecting the requests they apply
+// to, removing it while there are none.
+type Watcher struct {
+	reader    client.Reader
+	client    client.Client
+	namespace string
+
+	// installed is set once Start finds the WebhookPolicy CRD
+	installed bool
+
+	mu sync.Mutex
+	// changed is signalled when the policies change, so the leader reconciles
+	// the configuration without waiting for the resync
+	changed chan struct{}
+}
+
+// NewWatcher creates a Watcher. namespace is where the webhook's Service is.
+func NewWatcher(c client.Client, namespace string) *Watcher {
+	return &Watcher{
+		reader:    c,
+		client:    c,
+		namespace: namespace,
+		changed:   make(chan struct{}, 1),
+	}
+}
+
+// Start syncs the policies, then keeps them in sync until ctx is cancelled.
+// Nothing is enforced when the WebhookPolicy CRD isn't installed.
+func (w *Watcher) Start(ctx context.Context) error {
+	gvk := v1alpha1.SchemeGroupVersion.WithKind("WebhookPolicy")
+	if _, err := w.client.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+		if meta.IsNoMatchError(err) {
+			log.Info("WebhookPolicy CRD is not installed; WebhookPolicies are not enforced")
+			return nil
+		}
+		return err
+	}
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return err
+	}
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		return err
+	}
+	restConfig, err := k8sutil.RestConfig()
+	if err != nil {
+		return err
+	}
+	informers, err := cache.New(restConfig, cache.Options{Scheme: scheme})
+	if err != nil {
+		return err
+	}
+	w.reader = informers
+
+	informer, err := informers.GetInformer(ctx, &v1alpha1.WebhookPolicy{})
+	if err != nil {
+		return err
+	}
+	if _, err := informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { w.Sync(ctx) },
+		UpdateFunc: func(interface{}, interface{}) { w.Sync(ctx) },
+		DeleteFunc: func(interface{}) { w.Sync(ctx) },
+	}); err != nil {
+		return err
+	}
+
+	go func() {
+		if err := informers.Start(ctx); err != nil {
+			log.Error(err, "WebhookPolicy cache stopped")
+		}
+	}()
+	if !informers.WaitForCacheSync(ctx) {
+		return fmt.Errorf("failed to sync WebhookPolicy cache")
+	}
+	w.installed = true
+	return nil
+}
+
+// Sync hands the policies to the webhook
+func (w *Watcher) Sync(ctx context.Context) {
+	policies, err := w.list(ctx)
+	if err != nil {
+		log.Error(err, "Failed to list WebhookPolicies")
+		return
+	}
+	policyhook.SetPolicies(policies)
+	select {
+	case w.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Run reconciles the webhook's configuration whenever the policies change,
+// and every resyncPeriod in case it was changed or removed, until ctx is
+// cancelled. It must only run on the elected leader, so replicas don't race
+// to update the same configuration. Nothing is done when Start didn't find
+// the WebhookPolicy CRD.
+func (w *Watcher) Run(ctx context.Context) {
+	if !w.installed {
+		return
+	}
+	ticker := time.NewTicker(resyncPeriod)
+	defer ticker.Stop()
+	for {
+		w.Reconcile(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.changed:
+		}
+	}
+}
+
+// list returns the policies sorted by name
+func (w *Watcher) list(ctx context.Context) ([]v1alpha1.WebhookPolicy, error) {
+	list := &v1alpha1.WebhookPolicyList{}
+	if err := w.reader.List(ctx, list); err != nil {
+		return nil, err
+	}
+	sort.Slice(list.Items, func(i, j int) bool { return list.Items[i].Name < list.Items[j].Name })
+	return list.Items, nil
+}
+
+// Reconcile creates, updates or removes the webhook's configuration so it
+// selects the requests the policies apply to
+func (w *Watcher) Reconcile(ctx context.Context) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// While the webhook is disabled its configuration is left to the feature
+	// gates
+	if !featuregates.Enabled(policyhook.WebhookName) {
+		return
+	}
+
+	policies, err := w.list(ctx)
+	if err != nil {
+		log.Error(err, "Failed to list WebhookPolicies")
+		return
+	}
+
+	if len(policies) == 0 {
+		obj := &admissionregv1.ValidatingWebhookConfiguration{ObjectMeta: metav1.ObjectMeta{Name: configurationName}}
+		if err := w.client.Delete(ctx, obj); err != nil && !apierrors.IsNotFound(err) {
+			log.Error(err, "Failed to remove WebhookPolicy webhook configuration")
+		}
+		return
+	}
+
+	desired := Configuration(policies, w.namespace)
+	existing := &admissionregv1.ValidatingWebhookConfiguration{}
+	err = w.client.Get(ctx, client.ObjectKeyFromObject(desired), existing)
+	if apierrors.IsNotFound(err) {
+		if err := w.client.Create(ctx, desired); err != nil {
+			log.Error(err, "Failed to create WebhookPolicy webhook configuration")
+			return
+		}
+		log.Info("Created WebhookPolicy webhook configuration", "policies", len(policies))
+		return
+	}
+	if err != nil {
+		log.Error(err, "Failed to get WebhookPolicy webhook configuration")
+		return
+	}
+
+	// Keep the CA bundle injected by service-ca
+	if len(existing.Webhooks) > 0 {
+		desired.Webhooks[0].ClientConfig.CABundle = existing.Webhooks[0].ClientConfig.CABundle
+	}
+	if reflect.DeepEqual(existing.Webhooks, desired.Webhooks) {
+		return
+	}
+	existing.Webhooks = desired.Webhooks
+	if err := w.client.Update(ctx, existing); err != nil {
+		log.Error(err, "Failed to update WebhookPolicy webhook configuration")
+		return
+	}
+	log.Info("Updated WebhookPolicy webhook configuration", "policies", len(policies))
+}
+
+// configurationName is the name of the webhook's configuration, as the
+// feature gates expect
+var configurationName = "sre-" + policyhook.WebhookName
+
+// Configuration returns the ValidatingWebhookConfiguration sending the
+// webhook the requests the policies select
+func Configuration(policies []v1alpha1.WebhookPolicy, namespace string) *admissionregv1.ValidatingWebhookConfiguration {
+	hook := policyhook.NewWebhook()
+	scope := admissionregv1.AllScopes
+	rules := []admissionregv1.RuleWithOperations{}
+	for _, policy := range policies {
+		rules = append(rules, admissionregv1.RuleWithOperations{
+			Operations: policyhook.Operations(policy),
+			Rule: admissionregv1.Rule{
+				APIGroups:   policy.Spec.APIGroups,
+				APIVersions: []string{"*"},
+				Resources:   policy.Spec.Resources,
+				Scope:       &scope,
+			},
+		})
+	}
+
+	return &admissionregv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: configurationName,
+			Annotations: map[string]string{
+				// have service-ca-operator inject the CA of the webhook's
+				// serving certificate
+				"service.beta.openshift.io/inject-cabundle": "true",
+			},
+		},
+		Webhooks: []admissionregv1.ValidatingWebhook{
+			{
+				AdmissionReviewVersions: []string{"v1"},
+				TimeoutSeconds:          ptr.To(hook.TimeoutSeconds()),
+				SideEffects:             ptr.To(hook.SideEffects()),
+				MatchPolicy:             ptr.To(hook.MatchPolicy()),
+				Name:                    fmt.Sprintf("%s.managed.openshift.io", hook.Name()),
+				FailurePolicy:           ptr.To(webhooks.FailurePolicy(hook)),
+				// The API server defaults these; set them so the desired and
+				// existing configurations compare equal
+				NamespaceSelector: &metav1.LabelSelector{},
+				ObjectSelector:    &metav1.LabelSelector{},
+				ClientConfig: admissionregv1.WebhookClientConfig{
+					Service: &admissionregv1.ServiceReference{
+						Namespace: namespace,
+						Name:      config.OperatorName,
+						Path:      ptr.To(hook.GetURI()),
+						Port:      ptr.To(int32(443)),
+					},
+				},
+				Rules: rules,
+			},
+		},
+	}
+}
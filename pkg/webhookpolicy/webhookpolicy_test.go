@@ -0,0 +1,144 @@
+package webhookpolicy
+
+import (
+	"context"
+	"testing"
+
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/apis/managed/v1alpha1"
+	policyhook "github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/webhookpolicy"
+)
+
+const testNamespace = "openshift-validation-webhook"
+
+func newPolicy(name string, resources ...string) *v1alpha1.WebhookPolicy {
+	return &v1alpha1.WebhookPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: v1alpha1.WebhookPolicySpec{
+			APIGroups: []string{""},
+			Resources: resources,
+			Verbs:     []string{"delete"},
+			Message:   "Denied by " + name,
+		},
+	}
+}
+
+func TestConfiguration(t *testing.T) {
+	policies := []v1alpha1.WebhookPolicy{*newPolicy("a", "configmaps"), *newPolicy("b", "secrets", "services")}
+	obj := Configuration(policies, testNamespace)
+
+	if obj.Name != "sre-webhookpolicy-validation" {
+		t.Fatalf("Expected the configuration to be named for the webhook, got %s", obj.Name)
+	}
+	if len(obj.Webhooks) != 1 {
+		t.Fatalf("Expected one webhook, got %d", len(obj.Webhooks))
+	}
+	hook := obj.Webhooks[0]
+	if hook.ClientConfig.Service.Namespace != testNamespace || *hook.ClientConfig.Service.Path != "/webhookpolicy-validation" {
+		t.Fatalf("Unexpected service %+v", hook.ClientConfig.Service)
+	}
+	if *hook.FailurePolicy != admissionregv1.Ignore {
+		t.Fatalf("Expected the webhook to fail open, got %s", *hook.FailurePolicy)
+	}
+	if len(hook.Rules) != 2 {
+		t.Fatalf("Expected a rule per policy, got %d", len(hook.Rules))
+	}
+	if len(hook.Rules[1].Resources) != 2 || hook.Rules[1].Operations[0] != admissionregv1.Delete {
+		t.Fatalf("Unexpected rule %+v", hook.Rules[1])
+	}
+}
+
+func TestWatcherSync(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	w := NewWatcher(c, testNamespace)
+	ctx := context.Background()
+	key := client.ObjectKey{Name: configurationName}
+
+	// Without policies there is no configuration
+	w.Reconcile(ctx)
+	obj := &admissionregv1.ValidatingWebhookConfiguration{}
+	if err := c.Get(ctx, key, obj); !apierrors.IsNotFound(err) {
+		t.Fatalf("Expected no configuration without policies, got %v", err)
+	}
+
+	if err := c.Create(ctx, newPolicy("a", "configmaps")); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	w.Reconcile(ctx)
+	if err := c.Get(ctx, key, obj); err != nil {
+		t.Fatalf("Expected the configuration to be created, got %s", err.Error())
+	}
+	if len(obj.Webhooks[0].Rules) != 1 {
+		t.Fatalf("Expected one rule, got %d", len(obj.Webhooks[0].Rules))
+	}
+
+	// The injected CA bundle is kept when the rules change
+	obj.Webhooks[0].ClientConfig.CABundle = []byte("ca")
+	if err := c.Update(ctx, obj); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	if err := c.Create(ctx, newPolicy("b", "secrets")); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	w.Reconcile(ctx)
+	if err := c.Get(ctx, key, obj); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	if len(obj.Webhooks[0].Rules) != 2 {
+		t.Fatalf("Expected two rules, got %d", len(obj.Webhooks[0].Rules))
+	}
+	if string(obj.Webhooks[0].ClientConfig.CABundle) != "ca" {
+		t.Fatalf("Expected the CA bundle to be kept, got %q", obj.Webhooks[0].ClientConfig.CABundle)
+	}
+
+	// Removing the last policy removes the configuration
+	for _, name := range []string{"a", "b"} {
+		if err := c.Delete(ctx, newPolicy(name)); err != nil {
+			t.Fatalf("Expected no error, got %s", err.Error())
+		}
+	}
+	w.Reconcile(ctx)
+	if err := c.Get(ctx, key, obj); !apierrors.IsNotFound(err) {
+		t.Fatalf("Expected the configuration to be removed, got %v", err)
+	}
+}
+
+func TestWatcherSyncOnlyLoadsPolicies(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(newPolicy("a", "configmaps")).Build()
+	w := NewWatcher(c, testNamespace)
+	ctx := context.Background()
+
+	// Every replica loads the policies, but only the leader reconciles the
+	// configuration
+	w.Sync(ctx)
+	defer policyhook.SetPolicies(nil)
+	if err := c.Get(ctx, client.ObjectKey{Name: configurationName}, &admissionregv1.ValidatingWebhookConfiguration{}); !apierrors.IsNotFound(err) {
+		t.Fatalf("Expected Sync to leave the configuration alone, got %v", err)
+	}
+	select {
+	case <-w.changed:
+	default:
+		t.Fatalf("Expected Sync to signal the leader to reconcile")
+	}
+}
@@ -0,0 +1,9 @@
+package webhooks
+
+import (
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/certificatesigningrequest"
+)
+
+func init() {
+	Register(certificatesigningrequest.WebhookName, func() Webhook { return certificatesigningrequest.NewWebhook() })
+}
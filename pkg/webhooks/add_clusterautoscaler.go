@@ -0,0 +1,9 @@
+package webhooks
+
+import (
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/clusterautoscaler"
+)
+
+func init() {
+	Register(clusterautoscaler.WebhookName, func() Webhook { return clusterautoscaler.NewWebhook() })
+}
@@ -0,0 +1,9 @@
+package webhooks
+
+import (
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/clusterconfig"
+)
+
+func init() {
+	Register(clusterconfig.WebhookName, func() Webhook { return clusterconfig.NewWebhook() })
+}
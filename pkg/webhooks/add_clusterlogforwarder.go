@@ -0,0 +1,9 @@
+package webhooks
+
+import (
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/clusterlogforwarder"
+)
+
+func init() {
+	Register(clusterlogforwarder.WebhookName, func() Webhook { return clusterlogforwarder.NewWebhook() })
+}
@@ -0,0 +1,9 @@
+package webhooks
+
+import (
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/clusterversion"
+)
+
+func init() {
+	Register(clusterversion.WebhookName, func() Webhook { return clusterversion.NewWebhook() })
+}
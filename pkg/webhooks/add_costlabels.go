@@ -0,0 +1,9 @@
+package webhooks
+
+import (
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/costlabels"
+)
+
+func init() {
+	Register(costlabels.WebhookName, func() Webhook { return costlabels.NewWebhook() })
+}
@@ -0,0 +1,9 @@
+package webhooks
+
+import (
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/debugnamespace"
+)
+
+func init() {
+	Register(debugnamespace.WebhookName, func() Webhook { return debugnamespace.NewWebhook() })
+}
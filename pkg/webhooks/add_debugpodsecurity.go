@@ -0,0 +1,9 @@
+package webhooks
+
+import (
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/debugpodsecurity"
+)
+
+func init() {
+	Register(debugpodsecurity.WebhookName, func() Webhook { return debugpodsecurity.NewWebhook() })
+}
@@ -0,0 +1,9 @@
+package webhooks
+
+import (
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/debugpodtolerations"
+)
+
+func init() {
+	Register(debugpodtolerations.WebhookName, func() Webhook { return debugpodtolerations.NewWebhook() })
+}
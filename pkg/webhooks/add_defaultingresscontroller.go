@@ -0,0 +1,9 @@
+package webhooks
+
+import (
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/defaultingresscontroller"
+)
+
+func init() {
+	Register(defaultingresscontroller.WebhookName, func() Webhook { return defaultingresscontroller.NewWebhook() })
+}
@@ -0,0 +1,9 @@
+package webhooks
+
+import (
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/etcd"
+)
+
+func init() {
+	Register(etcd.WebhookName, func() Webhook { return etcd.NewWebhook() })
+}
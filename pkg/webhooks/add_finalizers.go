@@ -0,0 +1,9 @@
+package webhooks
+
+import (
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/finalizers"
+)
+
+func init() {
+	Register(finalizers.WebhookName, func() Webhook { return finalizers.NewWebhook() })
+}
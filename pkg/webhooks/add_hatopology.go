@@ -0,0 +1,9 @@
+package webhooks
+
+import (
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/hatopology"
+)
+
+func init() {
+	Register(hatopology.WebhookName, func() Webhook { return hatopology.NewWebhook() })
+}
@@ -0,0 +1,9 @@
+package webhooks
+
+import (
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/hivedeletion"
+)
+
+func init() {
+	Register(hivedeletion.WebhookName, func() Webhook { return hivedeletion.NewWebhook() })
+}
@@ -0,0 +1,9 @@
+package webhooks
+
+import (
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/hostaccess"
+)
+
+func init() {
+	Register(hostaccess.WebhookName, func() Webhook { return hostaccess.NewWebhook() })
+}
@@ -0,0 +1,9 @@
+package webhooks
+
+import (
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/hostedclusterspec"
+)
+
+func init() {
+	Register(hostedclusterspec.WebhookName, func() Webhook { return hostedclusterspec.NewWebhook() })
+}
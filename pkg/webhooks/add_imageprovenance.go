@@ -0,0 +1,9 @@
+package webhooks
+
+import (
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/imageprovenance"
+)
+
+func init() {
+	Register(imageprovenance.WebhookName, func() Webhook { return imageprovenance.NewWebhook() })
+}
@@ -0,0 +1,9 @@
+package webhooks
+
+import (
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/imagestream"
+)
+
+func init() {
+	Register(imagestream.WebhookName, func() Webhook { return imagestream.NewWebhook() })
+}
@@ -0,0 +1,9 @@
+package webhooks
+
+import (
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/impersonation"
+)
+
+func init() {
+	Register(impersonation.WebhookName, func() Webhook { return impersonation.NewWebhook() })
+}
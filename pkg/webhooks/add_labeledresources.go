@@ -0,0 +1,9 @@
+package webhooks
+
+import (
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/labeledresources"
+)
+
+func init() {
+	Register(labeledresources.WebhookName, func() Webhook { return labeledresources.NewWebhook() })
+}
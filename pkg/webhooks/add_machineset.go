@@ -0,0 +1,9 @@
+package webhooks
+
+import (
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/machineset"
+)
+
+func init() {
+	Register(machineset.WebhookName, func() Webhook { return machineset.NewWebhook() })
+}
@@ -0,0 +1,9 @@
+package webhooks
+
+import (
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/managednetworkpolicy"
+)
+
+func init() {
+	Register(managednetworkpolicy.WebhookName, func() Webhook { return managednetworkpolicy.NewWebhook() })
+}
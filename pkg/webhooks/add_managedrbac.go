@@ -0,0 +1,9 @@
+package webhooks
+
+import (
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/managedrbac"
+)
+
+func init() {
+	Register(managedrbac.WebhookName, func() Webhook { return managedrbac.NewWebhook() })
+}
@@ -0,0 +1,9 @@
+package webhooks
+
+import (
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/monitoringconfig"
+)
+
+func init() {
+	Register(monitoringconfig.WebhookName, func() Webhook { return monitoringconfig.NewWebhook() })
+}
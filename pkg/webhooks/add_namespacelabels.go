@@ -0,0 +1,9 @@
+package webhooks
+
+import (
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/namespacelabels"
+)
+
+func init() {
+	Register(namespacelabels.WebhookName, func() Webhook { return namespacelabels.NewWebhook() })
+}
@@ -0,0 +1,9 @@
+package webhooks
+
+import (
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/namespacerate"
+)
+
+func init() {
+	Register(namespacerate.WebhookName, func() Webhook { return namespacerate.NewWebhook() })
+}
@@ -0,0 +1,9 @@
+package webhooks
+
+import (
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/networkconfig"
+)
+
+func init() {
+	Register(networkconfig.WebhookName, func() Webhook { return networkconfig.NewWebhook() })
+}
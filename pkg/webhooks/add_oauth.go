@@ -0,0 +1,9 @@
+package webhooks
+
+import (
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/oauth"
+)
+
+func init() {
+	Register(oauth.WebhookName, func() Webhook { return oauth.NewWebhook() })
+}
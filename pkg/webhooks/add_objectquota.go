@@ -0,0 +1,9 @@
+package webhooks
+
+import (
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/objectquota"
+)
+
+func init() {
+	Register(objectquota.WebhookName, func() Webhook { return objectquota.NewWebhook() })
+}
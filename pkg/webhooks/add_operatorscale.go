@@ -0,0 +1,9 @@
+package webhooks
+
+import (
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/operatorscale"
+)
+
+func init() {
+	Register(operatorscale.WebhookName, func() Webhook { return operatorscale.NewWebhook() })
+}
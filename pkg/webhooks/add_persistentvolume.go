@@ -0,0 +1,9 @@
+package webhooks
+
+import (
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/persistentvolume"
+)
+
+func init() {
+	Register(persistentvolume.WebhookName, func() Webhook { return persistentvolume.NewWebhook() })
+}
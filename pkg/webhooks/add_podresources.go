@@ -0,0 +1,9 @@
+package webhooks
+
+import (
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/podresources"
+)
+
+func init() {
+	Register(podresources.WebhookName, func() Webhook { return podresources.NewWebhook() })
+}
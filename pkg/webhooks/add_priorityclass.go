@@ -0,0 +1,9 @@
+package webhooks
+
+import (
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/priorityclass"
+)
+
+func init() {
+	Register(priorityclass.WebhookName, func() Webhook { return priorityclass.NewWebhook() })
+}
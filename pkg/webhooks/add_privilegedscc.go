@@ -0,0 +1,9 @@
+package webhooks
+
+import (
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/privilegedscc"
+)
+
+func init() {
+	Register(privilegedscc.WebhookName, func() Webhook { return privilegedscc.NewWebhook() })
+}
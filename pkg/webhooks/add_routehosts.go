@@ -0,0 +1,9 @@
+package webhooks
+
+import (
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/routehosts"
+)
+
+func init() {
+	Register(routehosts.WebhookName, func() Webhook { return routehosts.NewWebhook() })
+}
@@ -0,0 +1,9 @@
+package webhooks
+
+import (
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/servicetype"
+)
+
+func init() {
+	Register(servicetype.WebhookName, func() Webhook { return servicetype.NewWebhook() })
+}
@@ -0,0 +1,9 @@
+package webhooks
+
+import (
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/webhookconfigurations"
+)
+
+func init() {
+	Register(webhookconfigurations.WebhookName, func() Webhook { return webhookconfigurations.NewWebhook() })
+}
@@ -0,0 +1,9 @@
+package webhooks
+
+import (
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/webhookpolicy"
+)
+
+func init() {
+	Register(webhookpolicy.WebhookName, func() Webhook { return webhookpolicy.NewWebhook() })
+}
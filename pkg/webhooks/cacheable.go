@@ -0,0 +1,23 @@
+package webhooks
+
+import "time"
+
+// CacheableWebhook is implemented by webhooks whose decisions only depend on
+// the request and on state which may be a little stale, so the dispatcher can
+// answer identical requests, such as for the pods of one ReplicaSet, with a
+// decision it made shortly before
+type CacheableWebhook interface {
+	// DecisionTTL returns how long a decision may be reused for an identical
+	// request. It must be shorter than the webhook accepts the state it reads
+	// to be stale. Zero disables caching.
+	DecisionTTL() time.Duration
+}
+
+// DecisionTTL returns how long the webhook's decisions may be reused, zero
+// for webhooks which aren't cacheable
+func DecisionTTL(hook Webhook) time.Duration {
+	if cacheable, ok := hook.(CacheableWebhook); ok {
+		return cacheable.DecisionTTL()
+	}
+	return 0
+}
@@ -0,0 +1,91 @@
+package webhooks
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// CanaryLabel opts a namespace into the canary webhooks when set to "true".
+	// Canary webhooks are only called for requests in those namespaces.
+	CanaryLabel string = "managed.openshift.io/webhook-canary"
+
+	// CanaryEnvVar overrides which webhooks are canaries, as a comma-separated
+	// list of webhook=true|false. false promotes a canary to full scope, eg
+	// "podimagespec-mutation=false" once it has proven itself on a fleet.
+	CanaryEnvVar string = "WEBHOOK_CANARIES"
+)
+
+var (
+	canariesMu sync.RWMutex
+	canaries   = Canaries{}
+)
+
+// CanaryWebhook is implemented by webhooks which can be rolled out as
+// canaries, only admitting requests in the namespaces opted in with
+// CanaryLabel until they're promoted
+type CanaryWebhook interface {
+	// Canary returns true while the webhook should be rolled out as a canary
+	Canary() bool
+}
+
+// Canaries override whether webhooks are canaries by name
+type Canaries map[string]bool
+
+// IsCanary tells whether the webhook is registered as a canary: whether it
+// says it is, unless that's overridden
+func IsCanary(hook Webhook) bool {
+	canariesMu.RLock()
+	defer canariesMu.RUnlock()
+	if canary, ok := canaries[hook.Name()]; ok {
+		return canary
+	}
+	if canary, ok := canaries[AllWebhooks]; ok {
+		return canary
+	}
+	if canaryHook, ok := hook.(CanaryWebhook); ok {
+		return canaryHook.Canary()
+	}
+	return false
+}
+
+// SetCanaries replaces the canary overrides
+func SetCanaries(overrides Canaries) {
+	canariesMu.Lock()
+	defer canariesMu.Unlock()
+	canaries = overrides
+}
+
+// ParseCanaries reads overrides from a comma-separated list of
+// webhook=true|false
+func ParseCanaries(list string) (Canaries, error) {
+	overrides := Canaries{}
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, found := strings.Cut(entry, "=")
+		if !found {
+			return nil, fmt.Errorf("canary override %q is not webhook=true|false", entry)
+		}
+		canary, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("canary override %q is not webhook=true|false", entry)
+		}
+		overrides[strings.TrimSpace(name)] = canary
+	}
+	return overrides, nil
+}
+
+// CanariesFromEnv reads the overrides set by CanaryEnvVar
+func CanariesFromEnv() (Canaries, error) {
+	overrides, err := ParseCanaries(os.Getenv(CanaryEnvVar))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", CanaryEnvVar, err)
+	}
+	return overrides, nil
+}
@@ -0,0 +1,92 @@
+package webhooks
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/hiveownership"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+)
+
+// canaryHook is a webhook rolled out as a canary
+type canaryHook struct {
+	*hiveownership.HiveOwnershipWebhook
+}
+
+func (h *canaryHook) Canary() bool { return true }
+
+// excludingHook is a canary webhook excluding a managed namespace
+type excludingHook struct {
+	canaryHook
+}
+
+func (h *excludingHook) ExcludedNamespaces() utils.NamespaceExclusion {
+	return utils.NamespaceExclusion{Namespaces: []string{"^openshift-monitoring$", "^kube-.*"}}
+}
+
+func TestParseCanaries(t *testing.T) {
+	overrides, err := ParseCanaries("*=true, podimagespec-mutation=false")
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	if len(overrides) != 2 || !overrides[AllWebhooks] || overrides["podimagespec-mutation"] {
+		t.Errorf("Expected every webhook but podimagespec-mutation to be a canary, got %v", overrides)
+	}
+
+	for _, list := range []string{"namespace-validation", "namespace-validation=maybe"} {
+		if _, err := ParseCanaries(list); err == nil {
+			t.Errorf("Expected %q to be invalid", list)
+		}
+	}
+}
+
+func TestIsCanary(t *testing.T) {
+	t.Cleanup(func() { SetCanaries(Canaries{}) })
+	hook := &canaryHook{HiveOwnershipWebhook: hiveownership.NewWebhook()}
+
+	if !IsCanary(hook) {
+		t.Errorf("Expected the webhook's own canary setting without overrides")
+	}
+	configuration := ValidatingWebhookConfiguration(hook, "test")
+	selector := configuration.Webhooks[0].NamespaceSelector
+	if selector == nil || selector.MatchLabels[CanaryLabel] != "true" {
+		t.Errorf("Expected the canary to be registered for the opted-in namespaces, got %v", selector)
+	}
+	if IsCanary(hiveownership.NewWebhook()) {
+		t.Errorf("Expected webhooks not to be canaries unless they say so")
+	}
+
+	// promoting the canary registers it for every namespace
+	SetCanaries(Canaries{hook.Name(): false})
+	if IsCanary(hook) {
+		t.Errorf("Expected the canary to be promoted")
+	}
+	if configuration := MutatingWebhookConfiguration(hook, "test"); configuration.Webhooks[0].NamespaceSelector != nil {
+		t.Errorf("Expected the promoted webhook to have no namespace selector, got %v", configuration.Webhooks[0].NamespaceSelector)
+	}
+}
+
+func TestNamespaceSelectorCanaryExclusion(t *testing.T) {
+	hook := &excludingHook{canaryHook{HiveOwnershipWebhook: hiveownership.NewWebhook()}}
+	SetCanaries(Canaries{})
+	t.Cleanup(func() { SetCanaries(Canaries{}) })
+
+	expected := &metav1.LabelSelector{
+		MatchLabels: map[string]string{CanaryLabel: "true"},
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: utils.NamespaceNameLabel, Operator: metav1.LabelSelectorOpNotIn, Values: []string{"openshift-monitoring"}},
+		},
+	}
+	if selector := NamespaceSelector(hook); !reflect.DeepEqual(selector, expected) {
+		t.Errorf("Expected namespace selector %v, got %v", expected, selector)
+	}
+
+	// the exclusion stays once the canary is promoted
+	SetCanaries(Canaries{hook.Name(): false})
+	expected.MatchLabels = nil
+	if selector := NamespaceSelector(hook); !reflect.DeepEqual(selector, expected) {
+		t.Errorf("Expected namespace selector %v, got %v", expected, selector)
+	}
+}
@@ -0,0 +1,379 @@
+package certificatesigningrequest
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	configv1 "github.com/openshift/api/config/v1"
+	hookconfig "github.com/openshift/managed-cluster-validating-webhooks/pkg/config"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/routehosts"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "certificatesigningrequest-validation"
+	docString   string = `Managed OpenShift customers may not approve or deny the CertificateSigningRequests of kubelets and the platform, which the cluster's machine approver decides on, nor create CertificateSigningRequests for the hosts of the cluster's API, console and OAuth endpoints or of the kubernetes and platform Services. This hardens the cluster's PKI against certificates impersonating nodes or the platform.`
+
+	approvalSubResource string = "approval"
+	nodeUserPrefix      string = "system:node:"
+	nodesGroup          string = "system:nodes"
+)
+
+var (
+	scope = admissionregv1.ClusterScope
+	rules = []admissionregv1.RuleWithOperations{
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Create},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{"certificates.k8s.io"},
+				APIVersions: []string{"*"},
+				Resources:   []string{"certificatesigningrequests"},
+				Scope:       &scope,
+			},
+		},
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Update},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{"certificates.k8s.io"},
+				APIVersions: []string{"*"},
+				Resources:   []string{"certificatesigningrequests/approval"},
+				Scope:       &scope,
+			},
+		},
+	}
+	log = logf.Log.WithName(WebhookName)
+
+	// kubeletSigners sign the client and serving certificates of kubelets
+	kubeletSigners = []string{certificatesv1.KubeletServingSignerName, certificatesv1.KubeAPIServerClientKubeletSignerName}
+
+	// reservedServiceNames are the in-cluster hosts of the API server, besides
+	// those of the Services in the default and platform namespaces
+	reservedServiceNames = []string{"kubernetes", "openshift"}
+)
+
+// CertificateSigningRequestWebhook denies customers approving platform
+// CertificateSigningRequests and requesting certificates for platform hosts
+type CertificateSigningRequestWebhook struct {
+	s          *runtime.Scheme
+	decoder    admissionctl.Decoder
+	kubeClient client.Client
+}
+
+// NewWebhook creates a new webhook
+func NewWebhook() *CertificateSigningRequestWebhook {
+	scheme, err := k8sutil.SharedScheme()
+	if err != nil {
+		log.Error(err, "Fail getting the shared scheme for CertificateSigningRequestWebhook")
+		os.Exit(1)
+	}
+	decoder, err := k8sutil.SharedDecoder()
+	if err != nil {
+		log.Error(err, "Fail getting the shared decoder for CertificateSigningRequestWebhook")
+		os.Exit(1)
+	}
+
+	return &CertificateSigningRequestWebhook{
+		s:       scheme,
+		decoder: decoder,
+	}
+}
+
+// InjectClient implements ClientWebhook interface
+func (s *CertificateSigningRequestWebhook) InjectClient(c client.Client) {
+	s.kubeClient = c
+}
+
+// CachedObjects implements ClientWebhook interface. The cluster's DNS and
+// Ingress configs are singletons, which are cheap to cache.
+func (s *CertificateSigningRequestWebhook) CachedObjects() []client.Object {
+	return []client.Object{&configv1.DNS{}, &configv1.Ingress{}}
+}
+
+// Authorized implements Webhook interface
+func (s *CertificateSigningRequestWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.authorized(context.Background(), request)
+}
+
+// AuthorizedContext implements ContextWebhook interface
+func (s *CertificateSigningRequestWebhook) AuthorizedContext(ctx context.Context, request admissionctl.Request) admissionctl.Response {
+	return s.authorized(ctx, request)
+}
+
+func (s *CertificateSigningRequestWebhook) authorized(ctx context.Context, request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if identity.IsAdmin(request.UserInfo) {
+		ret = admissionctl.Allowed("Admins and managed service accounts may request and approve any certificate")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	csr := &certificatesv1.CertificateSigningRequest{}
+	if err := s.decoder.Decode(request, csr); err != nil {
+		log.Error(err, "Couldn't decode the CertificateSigningRequest from the request", "name", request.Name)
+		ret = admissionctl.Errored(http.StatusBadRequest, err)
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	if request.SubResource == approvalSubResource {
+		return s.authorizeApproval(request, csr)
+	}
+	return s.authorizeCreate(ctx, request, csr)
+}
+
+// authorizeApproval denies customers approving or denying the
+// CertificateSigningRequests of kubelets and the platform
+func (s *CertificateSigningRequestWebhook) authorizeApproval(request admissionctl.Request, csr *certificatesv1.CertificateSigningRequest) admissionctl.Response {
+	var ret admissionctl.Response
+
+	reason := platformRequest(csr)
+	if reason == "" {
+		ret = admissionctl.Allowed("CertificateSigningRequest isn't the platform's")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	log.Info("Denying approval of platform CertificateSigningRequest", "name", request.Name, "signer", csr.Spec.SignerName, "requester", csr.Spec.Username, "user", request.UserInfo.Username)
+	ret = response.Denied(response.PlatformCertificateApproval, fmt.Sprintf("Prevented from approving or denying CertificateSigningRequest %s, as %s. The cluster's machine approver decides on the CertificateSigningRequests of kubelets and the platform. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", request.Name, reason))
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// authorizeCreate denies customers requesting certificates for the hosts of
+// the platform's endpoints and Services
+func (s *CertificateSigningRequestWebhook) authorizeCreate(ctx context.Context, request admissionctl.Request, csr *certificatesv1.CertificateSigningRequest) admissionctl.Response {
+	var ret admissionctl.Response
+
+	hosts, err := dnsNames(csr.Spec.Request)
+	if err != nil {
+		// The API server rejects malformed requests itself
+		ret = admissionctl.Allowed("CertificateSigningRequest has no parseable request")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	if len(hosts) == 0 {
+		ret = admissionctl.Allowed("CertificateSigningRequest requests no DNS names")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	for _, host := range hosts {
+		if reservedServiceHost(host) {
+			return deniedHost(request, host, "the in-cluster host of a platform Service")
+		}
+	}
+
+	reserved, err := s.reservedDomains(ctx)
+	if err != nil {
+		log.Error(err, "Couldn't read the cluster's domains")
+		ret = admissionctl.Allowed("Unable to determine the cluster's domains")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	for _, host := range hosts {
+		if domain, ok := coversReservedDomain(host, reserved); ok {
+			return deniedHost(request, host, "covering "+domain+", which is reserved for the cluster's endpoints")
+		}
+	}
+
+	ret = admissionctl.Allowed("CertificateSigningRequest requests no reserved hosts")
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+func deniedHost(request admissionctl.Request, host, reason string) admissionctl.Response {
+	log.Info("Denying CertificateSigningRequest for reserved host", "name", request.Name, "host", host, "user", request.UserInfo.Username)
+	ret := response.Denied(response.ReservedCertificateHost, fmt.Sprintf("Prevented from requesting a certificate for %s, %s. Request certificates for your own applications' hosts instead. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", host, reason))
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// platformRequest returns why the CertificateSigningRequest is a kubelet's or
+// the platform's, or "" when it's neither
+func platformRequest(csr *certificatesv1.CertificateSigningRequest) string {
+	if slices.Contains(kubeletSigners, csr.Spec.SignerName) {
+		return "it's for the kubelet signer " + csr.Spec.SignerName
+	}
+	if strings.HasPrefix(csr.Spec.Username, nodeUserPrefix) || slices.Contains(csr.Spec.Groups, nodesGroup) {
+		return "it's requested by node " + strings.TrimPrefix(csr.Spec.Username, nodeUserPrefix)
+	}
+	requester := authenticationv1.UserInfo{Username: csr.Spec.Username, Groups: csr.Spec.Groups}
+	if identity.IsAdmin(requester) || platformServiceAccount(csr.Spec.Username) {
+		return "it's requested by the platform's " + csr.Spec.Username
+	}
+	return ""
+}
+
+// platformServiceAccount is true when the username is a service account of a
+// platform namespace
+func platformServiceAccount(username string) bool {
+	parts := strings.Split(username, ":")
+	if len(parts) != 4 || parts[0] != "system" || parts[1] != "serviceaccount" {
+		return false
+	}
+	return hookconfig.ExcludedNamespaces.Excludes(parts[2])
+}
+
+// dnsNames returns the normalised DNS names the PEM encoded certificate
+// request asks for
+func dnsNames(request []byte) ([]string, error) {
+	block, _ := pem.Decode(request)
+	if block == nil {
+		return nil, errors.New("no PEM block in the request")
+	}
+	parsed, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	hosts := make([]string, 0, len(parsed.DNSNames))
+	for _, name := range parsed.DNSNames {
+		hosts = append(hosts, routehosts.NormaliseHost(name))
+	}
+	return hosts, nil
+}
+
+// reservedServiceHost is true for the in-cluster hosts of the API server and
+// of the Services in the default and platform namespaces, such as
+// kubernetes.default.svc or prometheus-k8s.openshift-monitoring.svc
+func reservedServiceHost(host string) bool {
+	host = strings.TrimSuffix(host, ".cluster.local")
+	host = strings.TrimSuffix(host, ".svc")
+	labels := strings.Split(host, ".")
+	switch len(labels) {
+	case 1:
+		return slices.Contains(reservedServiceNames, labels[0])
+	case 2:
+		return labels[1] == metav1.NamespaceDefault || hookconfig.ExcludedNamespaces.Excludes(labels[1])
+	}
+	return false
+}
+
+// coversReservedDomain returns the reserved domain the host is, is under, or
+// as a wildcard matches
+func coversReservedDomain(host string, reserved []string) (string, bool) {
+	if domain, ok := routehosts.ReservedDomain(host, reserved); ok {
+		return domain, true
+	}
+	parent, ok := strings.CutPrefix(host, "*.")
+	if !ok {
+		return "", false
+	}
+	for _, domain := range reserved {
+		if _, rest, _ := strings.Cut(domain, "."); rest == parent {
+			return domain, true
+		}
+	}
+	return "", false
+}
+
+// reservedDomains returns the reserved names in the cluster's base and apps
+// domains
+func (s *CertificateSigningRequestWebhook) reservedDomains(ctx context.Context) ([]string, error) {
+	var err error
+	if s.kubeClient == nil {
+		s.kubeClient, err = k8sutil.KubeClient(s.s)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return routehosts.ReservedDomains(ctx, s.kubeClient)
+}
+
+// GetURI implements Webhook interface
+func (s *CertificateSigningRequestWebhook) GetURI() string { return "/" + WebhookName }
+
+// Validate implements Webhook interface
+func (s *CertificateSigningRequestWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	valid = valid && (request.Kind.Kind == "CertificateSigningRequest")
+	switch request.Operation {
+	case admissionv1.Create:
+		valid = valid && (request.SubResource == "")
+	case admissionv1.Update:
+		valid = valid && (request.SubResource == approvalSubResource)
+	default:
+		valid = false
+	}
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *CertificateSigningRequestWebhook) Name() string { return WebhookName }
+
+// FailurePolicy implements Webhook interface
+func (s *CertificateSigningRequestWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *CertificateSigningRequestWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *CertificateSigningRequestWebhook) Rules() []admissionregv1.RuleWithOperations {
+	return rules
+}
+
+// ObjectSelector implements Webhook interface
+func (s *CertificateSigningRequestWebhook) ObjectSelector() *metav1.LabelSelector { return nil }
+
+// SideEffects implements Webhook interface
+func (s *CertificateSigningRequestWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *CertificateSigningRequestWebhook) TimeoutSeconds() int32 { return 2 }
+
+// Doc implements Webhook interface
+func (s *CertificateSigningRequestWebhook) Doc() string {
+	return docString
+}
+
+// RuleDocs implements Webhook interface
+func (s *CertificateSigningRequestWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    fmt.Sprintf("Customers may not approve or deny CertificateSigningRequests for the %s signers, nor those requested by nodes, admins or the service accounts of platform namespaces.", strings.Join(kubeletSigners, " and ")),
+			Exceptions: []string{utils.AdminsException},
+		},
+		{
+			Summary:    "Customers may not create CertificateSigningRequests with DNS names under the reserved names of the cluster's base and apps domains, wildcards matching them, or the in-cluster hosts of the kubernetes Service and the Services of the default and platform namespaces.",
+			Exceptions: []string{utils.AdminsException},
+		},
+	}
+}
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+func (s *CertificateSigningRequestWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// ClassicEnabled implements Webhook interface
+func (s *CertificateSigningRequestWebhook) ClassicEnabled() bool { return true }
+
+// HypershiftEnabled implements Webhook interface
+func (s *CertificateSigningRequestWebhook) HypershiftEnabled() bool { return true }
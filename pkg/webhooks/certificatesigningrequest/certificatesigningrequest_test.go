@@ -0,0 +1,234 @@
+package certificatesigningrequest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	configv1 "github.com/openshift/api/config/v1"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+)
+
+const (
+	baseDomain = "mycluster.abcd.p1.openshiftapps.com"
+	appsDomain = "apps." + baseDomain
+)
+
+var (
+	customer   = authenticationv1.UserInfo{Username: "customer", Groups: []string{"dedicated-admins", "system:authenticated"}}
+	sre        = authenticationv1.UserInfo{Username: "backplane-cluster-admin", Groups: []string{"system:authenticated"}}
+	approver   = authenticationv1.UserInfo{Username: "system:serviceaccount:openshift-cluster-machine-approver:machine-approver-sa", Groups: []string{"system:serviceaccounts", "system:serviceaccounts:openshift-cluster-machine-approver"}}
+	customerSA = "system:serviceaccount:payments:cert-requester"
+)
+
+// certificateRequest returns a PEM encoded certificate request for the DNS
+// names
+func certificateRequest(t *testing.T, dnsNames ...string) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Unexpected error generating key: %v", err)
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{DNSNames: dnsNames}, key)
+	if err != nil {
+		t.Fatalf("Unexpected error creating certificate request: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})
+}
+
+func newCSR(signer, requester string, groups []string, request []byte) *certificatesv1.CertificateSigningRequest {
+	return &certificatesv1.CertificateSigningRequest{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "certificates.k8s.io/v1", Kind: "CertificateSigningRequest"},
+		ObjectMeta: metav1.ObjectMeta{Name: "csr-abcde"},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request:    request,
+			SignerName: signer,
+			Username:   requester,
+			Groups:     groups,
+			Usages:     []certificatesv1.KeyUsage{certificatesv1.UsageDigitalSignature, certificatesv1.UsageServerAuth},
+		},
+	}
+}
+
+func newRequest(t *testing.T, user authenticationv1.UserInfo, csr *certificatesv1.CertificateSigningRequest, approval bool) admissionctl.Request {
+	t.Helper()
+	kind := metav1.GroupVersionKind{Group: "certificates.k8s.io", Version: "v1", Kind: "CertificateSigningRequest"}
+	if !approval {
+		return testutils.NewRequest(t, kind, admissionv1.Create, user, "", csr.Name, csr, nil)
+	}
+	request := testutils.NewRequest(t, kind, admissionv1.Update, user, "", csr.Name, csr, csr)
+	request.SubResource = approvalSubResource
+	return request
+}
+
+func TestApproval(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    authenticationv1.UserInfo
+		csr     *certificatesv1.CertificateSigningRequest
+		allowed bool
+		message string
+	}{
+		{
+			name:    "customer approving kubelet serving",
+			user:    customer,
+			csr:     newCSR(certificatesv1.KubeletServingSignerName, "system:node:worker-0", []string{"system:nodes", "system:authenticated"}, nil),
+			allowed: false,
+			message: "as it's for the kubelet signer kubernetes.io/kubelet-serving",
+		},
+		{
+			name:    "customer approving node bootstrap",
+			user:    customer,
+			csr:     newCSR(certificatesv1.KubeAPIServerClientKubeletSignerName, "system:serviceaccount:openshift-machine-config-operator:node-bootstrapper", nil, nil),
+			allowed: false,
+		},
+		{
+			name:    "customer approving platform service account",
+			user:    customer,
+			csr:     newCSR(certificatesv1.KubeAPIServerClientSignerName, "system:serviceaccount:openshift-monitoring:prometheus-k8s", nil, nil),
+			allowed: false,
+			message: "as it's requested by the platform's system:serviceaccount:openshift-monitoring:prometheus-k8s",
+		},
+		{
+			name:    "customer approving own client certificate",
+			user:    customer,
+			csr:     newCSR(certificatesv1.KubeAPIServerClientSignerName, customerSA, nil, nil),
+			allowed: true,
+		},
+		{
+			name:    "machine approver approving kubelet serving",
+			user:    approver,
+			csr:     newCSR(certificatesv1.KubeletServingSignerName, "system:node:worker-0", []string{"system:nodes"}, nil),
+			allowed: true,
+		},
+		{
+			name:    "sre approving kubelet serving",
+			user:    sre,
+			csr:     newCSR(certificatesv1.KubeletServingSignerName, "system:node:worker-0", []string{"system:nodes"}, nil),
+			allowed: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hook := NewWebhook()
+			request := newRequest(t, test.user, test.csr, true)
+			if !hook.Validate(request) {
+				t.Fatalf("Expected request to be valid")
+			}
+			response := hook.Authorized(request)
+			if response.Allowed != test.allowed {
+				t.Fatalf("Expected allowed %v, got %v: %v", test.allowed, response.Allowed, response.Result)
+			}
+			if response.UID != request.UID {
+				t.Fatalf("Expected UID %s, got %s", request.UID, response.UID)
+			}
+			if test.message != "" && !strings.Contains(response.Result.Message, test.message) {
+				t.Errorf("Expected the message to contain %q, got %s", test.message, response.Result.Message)
+			}
+		})
+	}
+}
+
+func TestCreate(t *testing.T) {
+	tests := []struct {
+		name     string
+		user     authenticationv1.UserInfo
+		dnsNames []string
+		allowed  bool
+		message  string
+	}{
+		{
+			name:     "console host",
+			user:     customer,
+			dnsNames: []string{"app.example.com", "console-openshift-console." + appsDomain},
+			allowed:  false,
+			message:  "covering console-openshift-console." + appsDomain,
+		},
+		{
+			name:     "apps wildcard",
+			user:     customer,
+			dnsNames: []string{"*." + appsDomain},
+			allowed:  false,
+		},
+		{
+			name:     "api host",
+			user:     customer,
+			dnsNames: []string{"API." + baseDomain + "."},
+			allowed:  false,
+		},
+		{
+			name:     "kubernetes service",
+			user:     customer,
+			dnsNames: []string{"kubernetes.default.svc.cluster.local"},
+			allowed:  false,
+			message:  "the in-cluster host of a platform Service",
+		},
+		{
+			name:     "platform service",
+			user:     customer,
+			dnsNames: []string{"prometheus-k8s.openshift-monitoring.svc"},
+			allowed:  false,
+		},
+		{
+			name:     "customer service and route",
+			user:     customer,
+			dnsNames: []string{"api.payments.svc", "shop." + appsDomain, "*.shop." + appsDomain},
+			allowed:  true,
+		},
+		{
+			name:    "no dns names",
+			user:    customer,
+			allowed: true,
+		},
+		{
+			name:     "sre console host",
+			user:     sre,
+			dnsNames: []string{"console-openshift-console." + appsDomain},
+			allowed:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hook := NewWebhook()
+			hook.InjectClient(fake.NewClientBuilder().WithScheme(hook.s).WithObjects(
+				&configv1.DNS{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}, Spec: configv1.DNSSpec{BaseDomain: baseDomain}},
+				&configv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}, Spec: configv1.IngressSpec{Domain: appsDomain}},
+			).Build())
+			csr := newCSR("example.com/serving", customerSA, nil, certificateRequest(t, test.dnsNames...))
+			request := newRequest(t, test.user, csr, false)
+			if !hook.Validate(request) {
+				t.Fatalf("Expected request to be valid")
+			}
+			response := hook.Authorized(request)
+			if response.Allowed != test.allowed {
+				t.Fatalf("Expected allowed %v, got %v: %v", test.allowed, response.Allowed, response.Result)
+			}
+			if test.message != "" && !strings.Contains(response.Result.Message, test.message) {
+				t.Errorf("Expected the message to contain %q, got %s", test.message, response.Result.Message)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	hook := NewWebhook()
+	csr := newCSR(certificatesv1.KubeletServingSignerName, "system:node:worker-0", nil, nil)
+	request := newRequest(t, customer, csr, true)
+	request.SubResource = "status"
+	if hook.Validate(request) {
+		t.Errorf("Expected status updates not to be valid")
+	}
+}
@@ -0,0 +1,379 @@
+package clusterautoscaler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/config"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "clusterautoscaler-validation"
+	docString   string = `Managed OpenShift customers may not configure the ClusterAutoscaler or MachineAutoscalers to scale beyond the cluster's maximum node count, or to scale down aggressively enough to destabilize the cluster. The limits are synced by OCM to the %s ConfigMap.`
+
+	// LimitsConfigMapName is the ConfigMap in the webhook namespace, synced by
+	// OCM, holding the limits of the cluster's subscription
+	LimitsConfigMapName string = "cluster-autoscaler-limits"
+	// maxNodesTotalKey is the most nodes the subscription allows. Without it
+	// node counts are not limited.
+	maxNodesTotalKey string = "maxNodesTotal"
+	// minDelayAfterAddKey is the shortest scaleDown.delayAfterAdd allowed
+	minDelayAfterAddKey string = "minScaleDownDelayAfterAdd"
+	// minUnneededTimeKey is the shortest scaleDown.unneededTime allowed
+	minUnneededTimeKey string = "minScaleDownUnneededTime"
+	// maxUtilizationThresholdKey is the highest scaleDown.utilizationThreshold
+	// allowed
+	maxUtilizationThresholdKey string = "maxScaleDownUtilizationThreshold"
+
+	clusterAutoscalerKind string = "ClusterAutoscaler"
+	machineAutoscalerKind string = "MachineAutoscaler"
+)
+
+var (
+	// defaultLimits are the scale down limits used unless the ConfigMap sets
+	// them. Scaling down sooner or at higher utilization than this churns
+	// nodes faster than workloads can be rescheduled.
+	defaultLimits = limits{
+		minDelayAfterAdd:        5 * time.Minute,
+		minUnneededTime:         5 * time.Minute,
+		maxUtilizationThreshold: 0.75,
+	}
+
+	scope = admissionregv1.AllScopes
+	rules = []admissionregv1.RuleWithOperations{
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Create, admissionregv1.Update},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{"autoscaling.openshift.io"},
+				APIVersions: []string{"*"},
+				Resources:   []string{"clusterautoscalers", "machineautoscalers"},
+				Scope:       &scope,
+			},
+		},
+	}
+	log = logf.Log.WithName(WebhookName)
+)
+
+// clusterAutoscaler holds the fields of an autoscaling.openshift.io
+// ClusterAutoscaler which the webhook checks
+type clusterAutoscaler struct {
+	Spec struct {
+		ResourceLimits *struct {
+			MaxNodesTotal *int32 `json:"maxNodesTotal,omitempty"`
+		} `json:"resourceLimits,omitempty"`
+		ScaleDown *struct {
+			Enabled              bool    `json:"enabled"`
+			DelayAfterAdd        *string `json:"delayAfterAdd,omitempty"`
+			UnneededTime         *string `json:"unneededTime,omitempty"`
+			UtilizationThreshold *string `json:"utilizationThreshold,omitempty"`
+		} `json:"scaleDown,omitempty"`
+	} `json:"spec"`
+}
+
+// machineAutoscaler holds the fields of an autoscaling.openshift.io
+// MachineAutoscaler which the webhook checks
+type machineAutoscaler struct {
+	Spec struct {
+		MaxReplicas int32 `json:"maxReplicas"`
+	} `json:"spec"`
+}
+
+// limits are the autoscaling limits of the cluster
+type limits struct {
+	// maxNodesTotal is the most nodes the subscription allows, or 0 when
+	// unlimited
+	maxNodesTotal           int32
+	minDelayAfterAdd        time.Duration
+	minUnneededTime         time.Duration
+	maxUtilizationThreshold float64
+}
+
+// ClusterAutoscalerWebhook validates ClusterAutoscalers and
+// MachineAutoscalers against the cluster's autoscaling limits
+type ClusterAutoscalerWebhook struct {
+	s          *runtime.Scheme
+	kubeClient client.Client
+}
+
+// NewWebhook creates a new webhook
+func NewWebhook() *ClusterAutoscalerWebhook {
+	scheme := runtime.NewScheme()
+	err := admissionv1.AddToScheme(scheme)
+	if err != nil {
+		log.Error(err, "Fail adding admissionv1 scheme to ClusterAutoscalerWebhook")
+		os.Exit(1)
+	}
+	err = corev1.AddToScheme(scheme)
+	if err != nil {
+		log.Error(err, "Fail adding corev1 scheme to ClusterAutoscalerWebhook")
+		os.Exit(1)
+	}
+
+	return &ClusterAutoscalerWebhook{
+		s: scheme,
+	}
+}
+
+// InjectClient implements ClientWebhook interface
+func (s *ClusterAutoscalerWebhook) InjectClient(c client.Client) {
+	s.kubeClient = c
+}
+
+// CachedObjects implements ClientWebhook interface. The limits ConfigMap is
+// only read when an autoscaler changes, which is rare.
+func (s *ClusterAutoscalerWebhook) CachedObjects() []client.Object { return nil }
+
+// Authorized implements Webhook interface
+func (s *ClusterAutoscalerWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.authorized(context.Background(), request)
+}
+
+// AuthorizedContext implements ContextWebhook interface
+func (s *ClusterAutoscalerWebhook) AuthorizedContext(ctx context.Context, request admissionctl.Request) admissionctl.Response {
+	return s.authorized(ctx, request)
+}
+
+func (s *ClusterAutoscalerWebhook) authorized(ctx context.Context, request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if identity.IsAdmin(request.UserInfo) {
+		ret = admissionctl.Allowed("Admins and managed service accounts may configure autoscaling")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	l, err := s.limits(ctx)
+	if err != nil {
+		// Not knowing the limits is no reason to block autoscaling
+		log.Error(err, "Failed to read autoscaler limits, allowing request", "kind", request.Kind.Kind, "name", request.Name)
+		ret = admissionctl.Allowed("Unable to determine the cluster's autoscaling limits")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	var violations []string
+	switch request.Kind.Kind {
+	case clusterAutoscalerKind:
+		ca := &clusterAutoscaler{}
+		if err := json.Unmarshal(request.Object.Raw, ca); err != nil {
+			log.Error(err, "Couldn't render a ClusterAutoscaler from the incoming request")
+			return admissionctl.Errored(http.StatusBadRequest, err)
+		}
+		violations = l.checkClusterAutoscaler(ca)
+	case machineAutoscalerKind:
+		ma := &machineAutoscaler{}
+		if err := json.Unmarshal(request.Object.Raw, ma); err != nil {
+			log.Error(err, "Couldn't render a MachineAutoscaler from the incoming request")
+			return admissionctl.Errored(http.StatusBadRequest, err)
+		}
+		violations = l.checkMachineAutoscaler(ma)
+	}
+
+	if len(violations) > 0 {
+		log.Info("Denying autoscaler exceeding cluster limits", "kind", request.Kind.Kind, "name", request.Name, "user", request.UserInfo.Username, "violations", violations)
+		ret = response.Denied(response.ClusterAutoscalerConfig, fmt.Sprintf("Prevented from configuring %s %s: %s. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", request.Kind.Kind, request.Name, strings.Join(violations, "; ")))
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	ret = admissionctl.Allowed("Autoscaler is within the cluster's limits")
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// checkClusterAutoscaler returns how the ClusterAutoscaler exceeds the limits
+func (l limits) checkClusterAutoscaler(ca *clusterAutoscaler) []string {
+	violations := []string{}
+	if l.maxNodesTotal > 0 {
+		if ca.Spec.ResourceLimits == nil || ca.Spec.ResourceLimits.MaxNodesTotal == nil {
+			violations = append(violations, fmt.Sprintf("spec.resourceLimits.maxNodesTotal must be set, to at most %d", l.maxNodesTotal))
+		} else if *ca.Spec.ResourceLimits.MaxNodesTotal > l.maxNodesTotal {
+			violations = append(violations, fmt.Sprintf("spec.resourceLimits.maxNodesTotal %d exceeds the cluster's maximum of %d nodes", *ca.Spec.ResourceLimits.MaxNodesTotal, l.maxNodesTotal))
+		}
+	}
+
+	scaleDown := ca.Spec.ScaleDown
+	if scaleDown == nil || !scaleDown.Enabled {
+		return violations
+	}
+	if violation := checkMinDuration("spec.scaleDown.delayAfterAdd", scaleDown.DelayAfterAdd, l.minDelayAfterAdd); violation != "" {
+		violations = append(violations, violation)
+	}
+	if violation := checkMinDuration("spec.scaleDown.unneededTime", scaleDown.UnneededTime, l.minUnneededTime); violation != "" {
+		violations = append(violations, violation)
+	}
+	if scaleDown.UtilizationThreshold != nil {
+		threshold, err := strconv.ParseFloat(*scaleDown.UtilizationThreshold, 64)
+		if err != nil {
+			violations = append(violations, fmt.Sprintf("spec.scaleDown.utilizationThreshold %q is not a number", *scaleDown.UtilizationThreshold))
+		} else if threshold > l.maxUtilizationThreshold {
+			violations = append(violations, fmt.Sprintf("spec.scaleDown.utilizationThreshold %s exceeds the maximum of %g", *scaleDown.UtilizationThreshold, l.maxUtilizationThreshold))
+		}
+	}
+	return violations
+}
+
+// checkMachineAutoscaler returns how the MachineAutoscaler exceeds the limits
+func (l limits) checkMachineAutoscaler(ma *machineAutoscaler) []string {
+	if l.maxNodesTotal > 0 && ma.Spec.MaxReplicas > l.maxNodesTotal {
+		return []string{fmt.Sprintf("spec.maxReplicas %d exceeds the cluster's maximum of %d nodes", ma.Spec.MaxReplicas, l.maxNodesTotal)}
+	}
+	return nil
+}
+
+// checkMinDuration returns a violation if the duration is set below min. Unset
+// durations take the autoscaler's defaults, which are safe.
+func checkMinDuration(field string, value *string, min time.Duration) string {
+	if value == nil {
+		return ""
+	}
+	d, err := time.ParseDuration(*value)
+	if err != nil {
+		return fmt.Sprintf("%s %q is not a duration", field, *value)
+	}
+	if d < min {
+		return fmt.Sprintf("%s %s is shorter than the minimum of %s", field, *value, min)
+	}
+	return ""
+}
+
+// limits reads the cluster's limits from the ConfigMap, falling back to the
+// defaults for those it doesn't set
+func (s *ClusterAutoscalerWebhook) limits(ctx context.Context) (limits, error) {
+	var err error
+	if s.kubeClient == nil {
+		s.kubeClient, err = k8sutil.KubeClient(s.s)
+		if err != nil {
+			return limits{}, err
+		}
+	}
+
+	cm := &corev1.ConfigMap{}
+	err = s.kubeClient.Get(ctx, client.ObjectKey{Namespace: config.OperatorNamespace, Name: LimitsConfigMapName}, cm)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return limits{}, err
+	}
+	return parseLimits(cm.Data)
+}
+
+// parseLimits parses the limits in the ConfigMap data
+func parseLimits(data map[string]string) (limits, error) {
+	l := defaultLimits
+	if value, ok := data[maxNodesTotalKey]; ok {
+		maxNodesTotal, err := strconv.ParseInt(strings.TrimSpace(value), 10, 32)
+		if err != nil {
+			return limits{}, fmt.Errorf("invalid %s: %w", maxNodesTotalKey, err)
+		}
+		l.maxNodesTotal = int32(maxNodesTotal)
+	}
+	for key, d := range map[string]*time.Duration{
+		minDelayAfterAddKey: &l.minDelayAfterAdd,
+		minUnneededTimeKey:  &l.minUnneededTime,
+	} {
+		if value, ok := data[key]; ok {
+			parsed, err := time.ParseDuration(strings.TrimSpace(value))
+			if err != nil {
+				return limits{}, fmt.Errorf("invalid %s: %w", key, err)
+			}
+			*d = parsed
+		}
+	}
+	if value, ok := data[maxUtilizationThresholdKey]; ok {
+		threshold, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return limits{}, fmt.Errorf("invalid %s: %w", maxUtilizationThresholdKey, err)
+		}
+		l.maxUtilizationThreshold = threshold
+	}
+	return l, nil
+}
+
+// GetURI implements Webhook interface
+func (s *ClusterAutoscalerWebhook) GetURI() string { return "/" + WebhookName }
+
+// Validate implements Webhook interface
+func (s *ClusterAutoscalerWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	valid = valid && (request.Kind.Kind == clusterAutoscalerKind || request.Kind.Kind == machineAutoscalerKind)
+	valid = valid && (len(request.Object.Raw) > 0)
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *ClusterAutoscalerWebhook) Name() string { return WebhookName }
+
+// FailurePolicy implements Webhook interface
+func (s *ClusterAutoscalerWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *ClusterAutoscalerWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *ClusterAutoscalerWebhook) Rules() []admissionregv1.RuleWithOperations { return rules }
+
+// ObjectSelector implements Webhook interface
+func (s *ClusterAutoscalerWebhook) ObjectSelector() *metav1.LabelSelector { return nil }
+
+// SideEffects implements Webhook interface
+func (s *ClusterAutoscalerWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *ClusterAutoscalerWebhook) TimeoutSeconds() int32 { return 2 }
+
+// Doc implements Webhook interface
+func (s *ClusterAutoscalerWebhook) Doc() string {
+	return fmt.Sprintf(docString, LimitsConfigMapName)
+}
+
+// RuleDocs implements Webhook interface
+func (s *ClusterAutoscalerWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers may not configure the ClusterAutoscaler or MachineAutoscalers to scale beyond the cluster's maximum node count.",
+			Exceptions: []string{utils.AdminsException, "Clusters whose limits can't be read from the limits ConfigMap"},
+		},
+		{
+			Summary:    "Customers may not configure the ClusterAutoscaler to scale down aggressively enough to destabilize the cluster.",
+			Exceptions: []string{utils.AdminsException, "Clusters whose limits can't be read from the limits ConfigMap"},
+		},
+	}
+}
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+func (s *ClusterAutoscalerWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// ClassicEnabled implements Webhook interface
+func (s *ClusterAutoscalerWebhook) ClassicEnabled() bool { return true }
+
+// HypershiftEnabled implements Webhook interface. Hosted clusters are scaled
+// through their NodePools instead.
+func (s *ClusterAutoscalerWebhook) HypershiftEnabled() bool { return false }
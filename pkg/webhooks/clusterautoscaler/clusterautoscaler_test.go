@@ -0,0 +1,194 @@
+package clusterautoscaler
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/config"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	clusterAutoscalerJSON = `{"apiVersion":"autoscaling.openshift.io/v1","kind":"ClusterAutoscaler","metadata":{"name":"default"},"spec":%s}`
+	machineAutoscalerJSON = `{"apiVersion":"autoscaling.openshift.io/v1beta1","kind":"MachineAutoscaler","metadata":{"name":"worker-us-east-1a","namespace":"openshift-machine-api"},"spec":%s}`
+)
+
+func newRequest(t *testing.T, kind, object, username string, groups []string) admissionctl.Request {
+	template := clusterAutoscalerJSON
+	if kind == machineAutoscalerKind {
+		template = machineAutoscalerJSON
+	}
+	gvk := metav1.GroupVersionKind{Group: "autoscaling.openshift.io", Version: "v1", Kind: kind}
+	user := authenticationv1.UserInfo{Username: username, Groups: groups}
+	return testutils.NewRequest(t, gvk, admissionv1.Update, user, "", "default", []byte(strings.Replace(template, "%s", object, 1)), nil)
+}
+
+func newLimitsConfigMap(data map[string]string) client.Object {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: LimitsConfigMapName, Namespace: config.OperatorNamespace},
+		Data:       data,
+	}
+}
+
+func TestAuthorized(t *testing.T) {
+	limits := newLimitsConfigMap(map[string]string{maxNodesTotalKey: "10"})
+
+	tests := []struct {
+		name     string
+		kind     string
+		object   string
+		limits   client.Object
+		username string
+		groups   []string
+		allowed  bool
+		message  string
+	}{
+		{
+			name:     "within the node limit",
+			kind:     clusterAutoscalerKind,
+			object:   `{"resourceLimits":{"maxNodesTotal":10}}`,
+			limits:   limits,
+			username: "customer",
+			allowed:  true,
+		},
+		{
+			name:     "over the node limit",
+			kind:     clusterAutoscalerKind,
+			object:   `{"resourceLimits":{"maxNodesTotal":50}}`,
+			limits:   limits,
+			username: "customer",
+			allowed:  false,
+			message:  "maxNodesTotal 50 exceeds the cluster's maximum of 10 nodes",
+		},
+		{
+			name:     "node limit unset",
+			kind:     clusterAutoscalerKind,
+			object:   `{}`,
+			limits:   limits,
+			username: "customer",
+			allowed:  false,
+			message:  "maxNodesTotal must be set",
+		},
+		{
+			name:     "no limits configured",
+			kind:     clusterAutoscalerKind,
+			object:   `{}`,
+			username: "customer",
+			allowed:  true,
+		},
+		{
+			name:     "aggressive scale down",
+			kind:     clusterAutoscalerKind,
+			object:   `{"scaleDown":{"enabled":true,"delayAfterAdd":"30s","unneededTime":"1m","utilizationThreshold":"0.9"}}`,
+			username: "customer",
+			allowed:  false,
+			message:  "delayAfterAdd 30s is shorter than the minimum of 5m0s; spec.scaleDown.unneededTime 1m is shorter than the minimum of 5m0s; spec.scaleDown.utilizationThreshold 0.9 exceeds the maximum of 0.75",
+		},
+		{
+			name:     "aggressive scale down disabled",
+			kind:     clusterAutoscalerKind,
+			object:   `{"scaleDown":{"enabled":false,"delayAfterAdd":"30s"}}`,
+			username: "customer",
+			allowed:  true,
+		},
+		{
+			name:     "scale down within configured limits",
+			kind:     clusterAutoscalerKind,
+			object:   `{"scaleDown":{"enabled":true,"delayAfterAdd":"2m"}}`,
+			limits:   newLimitsConfigMap(map[string]string{minDelayAfterAddKey: "1m"}),
+			username: "customer",
+			allowed:  true,
+		},
+		{
+			name:     "machine autoscaler within the node limit",
+			kind:     machineAutoscalerKind,
+			object:   `{"minReplicas":1,"maxReplicas":6}`,
+			limits:   limits,
+			username: "customer",
+			allowed:  true,
+		},
+		{
+			name:     "machine autoscaler over the node limit",
+			kind:     machineAutoscalerKind,
+			object:   `{"minReplicas":1,"maxReplicas":12}`,
+			limits:   limits,
+			username: "customer",
+			allowed:  false,
+			message:  "spec.maxReplicas 12 exceeds the cluster's maximum of 10 nodes",
+		},
+		{
+			name:     "sre over the node limit",
+			kind:     clusterAutoscalerKind,
+			object:   `{"resourceLimits":{"maxNodesTotal":50}}`,
+			limits:   limits,
+			username: "backplane-cluster-admin",
+			allowed:  true,
+		},
+		{
+			name:     "invalid limits",
+			kind:     clusterAutoscalerKind,
+			object:   `{"resourceLimits":{"maxNodesTotal":50}}`,
+			limits:   newLimitsConfigMap(map[string]string{maxNodesTotalKey: "lots"}),
+			username: "customer",
+			allowed:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hook := NewWebhook()
+			builder := fake.NewClientBuilder().WithScheme(hook.s)
+			if test.limits != nil {
+				builder = builder.WithObjects(test.limits)
+			}
+			hook.InjectClient(builder.Build())
+
+			request := newRequest(t, test.kind, test.object, test.username, test.groups)
+			if !hook.Validate(request) {
+				t.Fatalf("Expected request to be valid")
+			}
+			response := hook.Authorized(request)
+			if response.Allowed != test.allowed {
+				t.Fatalf("Expected allowed %v, got %v: %v", test.allowed, response.Allowed, response.Result)
+			}
+			if response.UID != request.UID {
+				t.Fatalf("Expected UID %s, got %s", request.UID, response.UID)
+			}
+			if test.message != "" && !strings.Contains(response.Result.Message, test.message) {
+				t.Errorf("Expected the message to contain %q, got %s", test.message, response.Result.Message)
+			}
+		})
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	l, err := parseLimits(map[string]string{
+		maxNodesTotalKey:           " 24 ",
+		minUnneededTimeKey:         "10m",
+		maxUtilizationThresholdKey: "0.6",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	expected := limits{
+		maxNodesTotal:           24,
+		minDelayAfterAdd:        defaultLimits.minDelayAfterAdd,
+		minUnneededTime:         10 * time.Minute,
+		maxUtilizationThreshold: 0.6,
+	}
+	if l != expected {
+		t.Fatalf("Expected %+v, got %+v", expected, l)
+	}
+
+	if _, err := parseLimits(map[string]string{minDelayAfterAddKey: "soon"}); err == nil {
+		t.Errorf("Expected an invalid duration to be rejected")
+	}
+}
@@ -0,0 +1,282 @@
+package clusterconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	configv1 "github.com/openshift/api/config/v1"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "clusterconfig-validation"
+	docString   string = `Managed OpenShift customers may not lower the audit profile of the cluster APIServer config, including through custom audit rules, nor replace the integrated OAuth server of the cluster Authentication config, as SRE rely on both to operate the cluster.`
+
+	// clusterConfigName is the name of the cluster's singleton configs
+	clusterConfigName string = "cluster"
+
+	apiServerKind      string = "APIServer"
+	authenticationKind string = "Authentication"
+)
+
+var (
+	// auditProfileRanks orders the audit profiles by how much they record
+	auditProfileRanks = map[configv1.AuditProfileType]int{
+		configv1.NoneAuditProfileType:               0,
+		configv1.DefaultAuditProfileType:            1,
+		configv1.WriteRequestBodiesAuditProfileType: 2,
+		configv1.AllRequestBodiesAuditProfileType:   3,
+	}
+
+	scope = admissionregv1.ClusterScope
+	rules = []admissionregv1.RuleWithOperations{
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Update},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{"config.openshift.io"},
+				APIVersions: []string{"*"},
+				Resources:   []string{"apiservers", "authentications"},
+				Scope:       &scope,
+			},
+		},
+	}
+	log = logf.Log.WithName(WebhookName)
+)
+
+// apiServer holds the fields of a config.openshift.io APIServer which the
+// webhook checks
+type apiServer struct {
+	Spec struct {
+		Audit struct {
+			Profile     configv1.AuditProfileType `json:"profile,omitempty"`
+			CustomRules []struct {
+				Group   string                    `json:"group"`
+				Profile configv1.AuditProfileType `json:"profile,omitempty"`
+			} `json:"customRules,omitempty"`
+		} `json:"audit"`
+	} `json:"spec"`
+}
+
+// authentication holds the fields of a config.openshift.io Authentication
+// which the webhook checks
+type authentication struct {
+	Spec struct {
+		Type configv1.AuthenticationType `json:"type,omitempty"`
+	} `json:"spec"`
+}
+
+// ClusterConfigWebhook prevents the settings of the cluster's APIServer and
+// Authentication configs SRE rely on from being removed
+type ClusterConfigWebhook struct {
+	s *runtime.Scheme
+}
+
+// NewWebhook creates a new webhook
+func NewWebhook() *ClusterConfigWebhook {
+	scheme := runtime.NewScheme()
+	err := admissionv1.AddToScheme(scheme)
+	if err != nil {
+		log.Error(err, "Fail adding admissionv1 scheme to ClusterConfigWebhook")
+		os.Exit(1)
+	}
+
+	return &ClusterConfigWebhook{
+		s: scheme,
+	}
+}
+
+// Authorized implements Webhook interface
+func (s *ClusterConfigWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.authorized(request)
+}
+
+func (s *ClusterConfigWebhook) authorized(request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if request.Name != clusterConfigName {
+		ret = admissionctl.Allowed("Only the cluster config is protected")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	if identity.IsAdmin(request.UserInfo) {
+		ret = admissionctl.Allowed("Admins and managed service accounts may change the cluster config")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	var violations []string
+	var err error
+	switch request.Kind.Kind {
+	case apiServerKind:
+		violations, err = checkAPIServer(request)
+	case authenticationKind:
+		violations, err = checkAuthentication(request)
+	}
+	if err != nil {
+		log.Error(err, "Couldn't render the cluster config from the incoming request", "kind", request.Kind.Kind)
+		ret = admissionctl.Errored(http.StatusBadRequest, err)
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	if len(violations) > 0 {
+		log.Info("Denying change to the cluster config", "kind", request.Kind.Kind, "user", request.UserInfo.Username, "violations", violations)
+		ret = response.Denied(response.ManagedClusterConfig, fmt.Sprintf("Prevented from changing the cluster %s config: %s. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", request.Kind.Kind, strings.Join(violations, "; ")))
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	ret = admissionctl.Allowed("Cluster config keeps the settings SRE require")
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// checkAPIServer returns how the update lowers the audit profile
+func checkAPIServer(request admissionctl.Request) ([]string, error) {
+	oldConfig, newConfig := &apiServer{}, &apiServer{}
+	if err := json.Unmarshal(request.OldObject.Raw, oldConfig); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(request.Object.Raw, newConfig); err != nil {
+		return nil, err
+	}
+
+	violations := []string{}
+	oldProfile, newProfile := oldConfig.Spec.Audit.Profile, newConfig.Spec.Audit.Profile
+	if auditProfileRank(newProfile) < auditProfileRank(oldProfile) {
+		violations = append(violations, fmt.Sprintf("spec.audit.profile may not be lowered from %s to %s", auditProfileName(oldProfile), auditProfileName(newProfile)))
+	}
+	// Custom rules may record less for some groups, so none may record less
+	// than the cluster's audit profile did
+	minimum := auditProfileRank(oldProfile)
+	for _, rule := range oldConfig.Spec.Audit.CustomRules {
+		minimum = min(minimum, auditProfileRank(rule.Profile))
+	}
+	for _, rule := range newConfig.Spec.Audit.CustomRules {
+		if auditProfileRank(rule.Profile) < minimum {
+			violations = append(violations, fmt.Sprintf("spec.audit.customRules may not lower the audit profile of group %s to %s", rule.Group, auditProfileName(rule.Profile)))
+		}
+	}
+	return violations, nil
+}
+
+// checkAuthentication returns how the update replaces the integrated OAuth
+// server, which serves the identity providers OCM manages
+func checkAuthentication(request admissionctl.Request) ([]string, error) {
+	oldConfig, newConfig := &authentication{}, &authentication{}
+	if err := json.Unmarshal(request.OldObject.Raw, oldConfig); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(request.Object.Raw, newConfig); err != nil {
+		return nil, err
+	}
+
+	if isIntegratedOAuth(oldConfig.Spec.Type) && !isIntegratedOAuth(newConfig.Spec.Type) {
+		return []string{fmt.Sprintf("spec.type may not be changed from %s to %s", configv1.AuthenticationTypeIntegratedOAuth, newConfig.Spec.Type)}, nil
+	}
+	return nil, nil
+}
+
+// auditProfileRank ranks the audit profile by how much it records. Unset and
+// unknown profiles are treated as the Default profile, as the API server does.
+func auditProfileRank(profile configv1.AuditProfileType) int {
+	if rank, ok := auditProfileRanks[profile]; ok {
+		return rank
+	}
+	return auditProfileRanks[configv1.DefaultAuditProfileType]
+}
+
+// auditProfileName names the profile, naming unset profiles Default
+func auditProfileName(profile configv1.AuditProfileType) configv1.AuditProfileType {
+	if profile == "" {
+		return configv1.DefaultAuditProfileType
+	}
+	return profile
+}
+
+// isIntegratedOAuth checks whether the authentication type is the integrated
+// OAuth server, which is the default
+func isIntegratedOAuth(authType configv1.AuthenticationType) bool {
+	return authType == "" || authType == configv1.AuthenticationTypeIntegratedOAuth
+}
+
+// GetURI implements Webhook interface
+func (s *ClusterConfigWebhook) GetURI() string { return "/" + WebhookName }
+
+// Validate implements Webhook interface
+func (s *ClusterConfigWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	valid = valid && (request.Kind.Kind == apiServerKind || request.Kind.Kind == authenticationKind)
+	valid = valid && (len(request.Object.Raw) > 0 && len(request.OldObject.Raw) > 0)
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *ClusterConfigWebhook) Name() string { return WebhookName }
+
+// FailurePolicy implements Webhook interface
+func (s *ClusterConfigWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *ClusterConfigWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *ClusterConfigWebhook) Rules() []admissionregv1.RuleWithOperations { return rules }
+
+// ObjectSelector implements Webhook interface
+func (s *ClusterConfigWebhook) ObjectSelector() *metav1.LabelSelector { return nil }
+
+// SideEffects implements Webhook interface
+func (s *ClusterConfigWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *ClusterConfigWebhook) TimeoutSeconds() int32 { return 2 }
+
+// Doc implements Webhook interface
+func (s *ClusterConfigWebhook) Doc() string { return docString }
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+func (s *ClusterConfigWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// RuleDocs implements Webhook interface
+func (s *ClusterConfigWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers may not lower the audit profile of the cluster APIServer config, including through custom audit rules.",
+			Exceptions: []string{utils.AdminsException},
+		},
+		{
+			Summary:    "Customers may not replace the integrated OAuth server in the cluster Authentication config.",
+			Exceptions: []string{utils.AdminsException},
+		},
+	}
+}
+
+// ClassicEnabled implements Webhook interface
+func (s *ClusterConfigWebhook) ClassicEnabled() bool { return true }
+
+// HypershiftEnabled implements Webhook interface. The configs of hosted
+// clusters are set through their HostedCluster instead.
+func (s *ClusterConfigWebhook) HypershiftEnabled() bool { return false }
@@ -0,0 +1,162 @@
+package clusterconfig
+
+import (
+	"strings"
+	"testing"
+
+	configv1 "github.com/openshift/api/config/v1"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+)
+
+func newRequest(t *testing.T, kind, name, username string, groups []string, oldObj, obj interface{}) admissionctl.Request {
+	t.Helper()
+	gvk := metav1.GroupVersionKind{Group: "config.openshift.io", Version: "v1", Kind: kind}
+	return testutils.NewRequest(t, gvk, admissionv1.Update, authenticationv1.UserInfo{Username: username, Groups: groups}, "", name, obj, oldObj)
+}
+
+func newAPIServer(profile configv1.AuditProfileType, rules ...configv1.AuditCustomRule) *configv1.APIServer {
+	return &configv1.APIServer{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterConfigName},
+		Spec: configv1.APIServerSpec{
+			Audit: configv1.Audit{Profile: profile, CustomRules: rules},
+		},
+	}
+}
+
+func newAuthentication(authType configv1.AuthenticationType) *configv1.Authentication {
+	return &configv1.Authentication{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterConfigName},
+		Spec:       configv1.AuthenticationSpec{Type: authType},
+	}
+}
+
+func TestAuthorized(t *testing.T) {
+	tests := []struct {
+		name     string
+		kind     string
+		username string
+		groups   []string
+		oldObj   interface{}
+		obj      interface{}
+		allowed  bool
+		message  string
+	}{
+		{
+			name:     "raising the audit profile",
+			kind:     apiServerKind,
+			username: "customer",
+			oldObj:   newAPIServer(""),
+			obj:      newAPIServer(configv1.WriteRequestBodiesAuditProfileType),
+			allowed:  true,
+		},
+		{
+			name:     "lowering the audit profile",
+			kind:     apiServerKind,
+			username: "customer",
+			oldObj:   newAPIServer(configv1.DefaultAuditProfileType),
+			obj:      newAPIServer(configv1.NoneAuditProfileType),
+			allowed:  false,
+			message:  "spec.audit.profile may not be lowered from Default to None",
+		},
+		{
+			name:     "lowering a raised audit profile to the default",
+			kind:     apiServerKind,
+			username: "customer",
+			oldObj:   newAPIServer(configv1.AllRequestBodiesAuditProfileType),
+			obj:      newAPIServer(""),
+			allowed:  false,
+			message:  "from AllRequestBodies to Default",
+		},
+		{
+			name:     "custom rule lowering a group's audit profile",
+			kind:     apiServerKind,
+			username: "customer",
+			oldObj:   newAPIServer(configv1.DefaultAuditProfileType),
+			obj:      newAPIServer(configv1.DefaultAuditProfileType, configv1.AuditCustomRule{Group: "system:authenticated:oauth", Profile: configv1.NoneAuditProfileType}),
+			allowed:  false,
+			message:  "group system:authenticated:oauth to None",
+		},
+		{
+			name:     "custom rule raising a group's audit profile",
+			kind:     apiServerKind,
+			username: "customer",
+			oldObj:   newAPIServer(configv1.DefaultAuditProfileType),
+			obj:      newAPIServer(configv1.DefaultAuditProfileType, configv1.AuditCustomRule{Group: "system:authenticated:oauth", Profile: configv1.AllRequestBodiesAuditProfileType}),
+			allowed:  true,
+		},
+		{
+			name:     "SRE lowering the audit profile",
+			kind:     apiServerKind,
+			username: "backplane-cluster-admin",
+			oldObj:   newAPIServer(configv1.DefaultAuditProfileType),
+			obj:      newAPIServer(configv1.NoneAuditProfileType),
+			allowed:  true,
+		},
+		{
+			name:     "replacing the integrated OAuth server",
+			kind:     authenticationKind,
+			username: "customer",
+			oldObj:   newAuthentication(""),
+			obj:      newAuthentication(configv1.AuthenticationTypeOIDC),
+			allowed:  false,
+			message:  "spec.type may not be changed from IntegratedOAuth to OIDC",
+		},
+		{
+			name:     "disabling authentication",
+			kind:     authenticationKind,
+			username: "customer",
+			oldObj:   newAuthentication(configv1.AuthenticationTypeIntegratedOAuth),
+			obj:      newAuthentication(configv1.AuthenticationTypeNone),
+			allowed:  false,
+		},
+		{
+			name:     "setting the default authentication type",
+			kind:     authenticationKind,
+			username: "customer",
+			oldObj:   newAuthentication(""),
+			obj:      newAuthentication(configv1.AuthenticationTypeIntegratedOAuth),
+			allowed:  true,
+		},
+		{
+			name:     "privileged service account replacing the integrated OAuth server",
+			kind:     authenticationKind,
+			username: "system:serviceaccount:openshift-authentication-operator:authentication-operator",
+			groups:   []string{"system:serviceaccounts", "system:serviceaccounts:openshift-authentication-operator"},
+			oldObj:   newAuthentication(""),
+			obj:      newAuthentication(configv1.AuthenticationTypeOIDC),
+			allowed:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := newRequest(t, test.kind, clusterConfigName, test.username, test.groups, test.oldObj, test.obj)
+			hook := NewWebhook()
+			if !hook.Validate(request) {
+				t.Fatalf("Expected request to be valid")
+			}
+			response := hook.Authorized(request)
+			if response.Allowed != test.allowed {
+				t.Fatalf("Expected allowed %v, got %v: %v", test.allowed, response.Allowed, response.Result)
+			}
+			if response.UID != request.UID {
+				t.Fatalf("Expected UID %s, got %s", request.UID, response.UID)
+			}
+			if test.message != "" && !strings.Contains(response.Result.Message, test.message) {
+				t.Errorf("Expected the message to contain %q, got %s", test.message, response.Result.Message)
+			}
+		})
+	}
+}
+
+func TestOtherConfigsAllowed(t *testing.T) {
+	request := newRequest(t, apiServerKind, "other", "customer", nil, newAPIServer(configv1.DefaultAuditProfileType), newAPIServer(configv1.NoneAuditProfileType))
+	if response := NewWebhook().Authorized(request); !response.Allowed {
+		t.Fatalf("Expected configs other than the cluster's to be allowed, got %v", response.Result)
+	}
+}
@@ -0,0 +1,349 @@
+package clusterlogforwarder
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	cl "github.com/openshift/cluster-logging-operator/apis/logging/v1"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/labeledresources"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "clusterlogforwarder-validation"
+	docString   string = `Managed OpenShift customers may not delete the ClusterLogForwarders and ClusterLogging instances labelled %s=true, which forward the cluster's audit logs to Red Hat SRE, nor edit them in ways which stop the forwarding. Customers may still add their own inputs, outputs and pipelines to a managed ClusterLogForwarder.`
+
+	clusterLogForwarderKind string = "ClusterLogForwarder"
+	clusterLoggingKind      string = "ClusterLogging"
+)
+
+var (
+	scope = admissionregv1.NamespacedScope
+	rules = []admissionregv1.RuleWithOperations{
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Update, admissionregv1.Delete},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{"logging.openshift.io"},
+				APIVersions: []string{"v1"},
+				Resources:   []string{"clusterlogforwarders", "clusterloggings"},
+				Scope:       &scope,
+			},
+		},
+	}
+	log = logf.Log.WithName(WebhookName)
+)
+
+// ClusterLogForwarderWebhook protects the managed log forwarding of the
+// cluster's audit logs to SRE
+type ClusterLogForwarderWebhook struct{}
+
+// NewWebhook creates a new webhook
+func NewWebhook() *ClusterLogForwarderWebhook {
+	return &ClusterLogForwarderWebhook{}
+}
+
+// Authorized implements Webhook interface
+func (s *ClusterLogForwarderWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.authorized(request)
+}
+
+func (s *ClusterLogForwarderWebhook) authorized(request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if identity.IsAdmin(request.UserInfo) {
+		ret = admissionctl.Allowed("Admins and managed service accounts may change managed log forwarding")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	old := &metav1.PartialObjectMetadata{}
+	if err := json.Unmarshal(request.OldObject.Raw, old); err != nil {
+		log.Error(err, "Couldn't decode the old object from the request")
+		ret = admissionctl.Errored(http.StatusBadRequest, err)
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	// The old object is checked, so removing the label is denied too
+	if old.Labels[labeledresources.ManagedLabel] != "true" {
+		ret = admissionctl.Allowed("Only managed log forwarding is protected")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	if request.Operation == admissionv1.Delete {
+		log.Info("Denying deletion of managed log forwarding", "kind", request.Kind.Kind, "namespace", request.Namespace, "name", request.Name, "user", request.UserInfo.Username)
+		ret = response.Denied(response.ManagedLogForwarding, fmt.Sprintf("Prevented from deleting the managed %s %s/%s, which is labelled %s=true, as it forwards the cluster's audit logs to Red Hat SRE. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", request.Kind.Kind, request.Namespace, request.Name, labeledresources.ManagedLabel))
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	changes, err := breakingChanges(request)
+	if err != nil {
+		log.Error(err, "Couldn't decode the request", "kind", request.Kind.Kind)
+		ret = admissionctl.Errored(http.StatusBadRequest, err)
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	if len(changes) == 0 {
+		ret = admissionctl.Allowed("Update keeps the managed log forwarding")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	log.Info("Denying update breaking managed log forwarding", "kind", request.Kind.Kind, "namespace", request.Namespace, "name", request.Name, "user", request.UserInfo.Username, "changes", changes)
+	ret = response.Denied(response.ManagedLogForwarding, fmt.Sprintf("Prevented from updating the managed %s %s/%s, as it would stop forwarding the cluster's audit logs to Red Hat SRE: %s. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", request.Kind.Kind, request.Namespace, request.Name, strings.Join(changes, "; ")))
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// breakingChanges returns the changes, a field path and its old and new
+// values each, the update makes to what the managed log forwarding relies on
+func breakingChanges(request admissionctl.Request) ([]string, error) {
+	changes := []string{}
+	switch request.Kind.Kind {
+	case clusterLogForwarderKind:
+		old, updated := &cl.ClusterLogForwarder{}, &cl.ClusterLogForwarder{}
+		if err := decode(request, old, updated); err != nil {
+			return nil, err
+		}
+		changes = append(changes, labelChanges(updated.Labels)...)
+		changes = append(changes, forwarderChanges(&old.Spec, &updated.Spec)...)
+	case clusterLoggingKind:
+		old, updated := &cl.ClusterLogging{}, &cl.ClusterLogging{}
+		if err := decode(request, old, updated); err != nil {
+			return nil, err
+		}
+		changes = append(changes, labelChanges(updated.Labels)...)
+		changes = append(changes, loggingChanges(&old.Spec, &updated.Spec)...)
+	default:
+		return nil, fmt.Errorf("unexpected kind %s", request.Kind.Kind)
+	}
+	return changes, nil
+}
+
+func decode(request admissionctl.Request, old, updated any) error {
+	if err := json.Unmarshal(request.OldObject.Raw, old); err != nil {
+		return err
+	}
+	return json.Unmarshal(request.Object.Raw, updated)
+}
+
+// labelChanges returns the removal of the managed label, which would leave the
+// instance unprotected
+func labelChanges(labels map[string]string) []string {
+	if labels[labeledresources.ManagedLabel] == "true" {
+		return nil
+	}
+	return []string{fmt.Sprintf("metadata.labels[%s]: %q -> %q", labeledresources.ManagedLabel, "true", labels[labeledresources.ManagedLabel])}
+}
+
+// forwarderChanges returns the changes which stop audit logs reaching an
+// output an old pipeline forwarded them to, or change or remove such an
+// output. Other pipelines and outputs may be changed freely.
+func forwarderChanges(old, updated *cl.ClusterLogForwarderSpec) []string {
+	changes := []string{}
+	checked := []string{}
+	for i, pipeline := range old.Pipelines {
+		if !slices.Contains(pipeline.InputRefs, cl.InputNameAudit) {
+			continue
+		}
+		for _, output := range pipeline.OutputRefs {
+			if !forwardsAudit(updated.Pipelines, output) {
+				changes = append(changes, fmt.Sprintf("%s: audit logs are no longer forwarded to output %s", pipelinePath(pipeline, i), output))
+			}
+			if output == cl.OutputNameDefault || slices.Contains(checked, output) {
+				continue
+			}
+			checked = append(checked, output)
+			changes = append(changes, outputChanges(output, findOutput(old.Outputs, output), findOutput(updated.Outputs, output))...)
+		}
+	}
+	return changes
+}
+
+// forwardsAudit is true when one of the pipelines forwards audit logs to the
+// output
+func forwardsAudit(pipelines []cl.PipelineSpec, output string) bool {
+	for _, pipeline := range pipelines {
+		if slices.Contains(pipeline.InputRefs, cl.InputNameAudit) && slices.Contains(pipeline.OutputRefs, output) {
+			return true
+		}
+	}
+	return false
+}
+
+// pipelinePath returns the path of the pipeline, by name or, as names are
+// optional, by index
+func pipelinePath(pipeline cl.PipelineSpec, i int) string {
+	if pipeline.Name != "" {
+		return "spec.pipelines[" + pipeline.Name + "]"
+	}
+	return "spec.pipelines[" + strconv.Itoa(i) + "]"
+}
+
+func findOutput(outputs []cl.OutputSpec, name string) *cl.OutputSpec {
+	for i := range outputs {
+		if outputs[i].Name == name {
+			return &outputs[i]
+		}
+	}
+	return nil
+}
+
+// outputChanges returns the changes to an output audit logs are forwarded to.
+// Its type, URL and secret are diffed field by field; its other settings as a
+// whole.
+func outputChanges(name string, old, updated *cl.OutputSpec) []string {
+	path := "spec.outputs[" + name + "]"
+	if old == nil {
+		return nil
+	}
+	if updated == nil {
+		return []string{path + ": removed"}
+	}
+	changes := []string{}
+	if old.Type != updated.Type {
+		changes = append(changes, fmt.Sprintf("%s.type: %q -> %q", path, old.Type, updated.Type))
+	}
+	if old.URL != updated.URL {
+		changes = append(changes, fmt.Sprintf("%s.url: %q -> %q", path, old.URL, updated.URL))
+	}
+	if oldSecret, secret := secretName(old), secretName(updated); oldSecret != secret {
+		changes = append(changes, fmt.Sprintf("%s.secret.name: %q -> %q", path, oldSecret, secret))
+	}
+	oldSettings, settings := *old, *updated
+	for _, output := range []*cl.OutputSpec{&oldSettings, &settings} {
+		output.Type, output.URL, output.Secret = "", "", nil
+	}
+	if !equality.Semantic.DeepEqual(oldSettings, settings) {
+		changes = append(changes, path+": settings changed")
+	}
+	return changes
+}
+
+func secretName(output *cl.OutputSpec) string {
+	if output.Secret == nil {
+		return ""
+	}
+	return output.Secret.Name
+}
+
+// loggingChanges returns the changes which stop the ClusterLogging's collector
+// running: making it unmanaged, or removing or replacing its collection
+func loggingChanges(old, updated *cl.ClusterLoggingSpec) []string {
+	changes := []string{}
+	if updated.ManagementState == cl.ManagementStateUnmanaged && old.ManagementState != cl.ManagementStateUnmanaged {
+		changes = append(changes, fmt.Sprintf("spec.managementState: %q -> %q", old.ManagementState, updated.ManagementState))
+	}
+	switch {
+	case old.Collection == nil:
+	case updated.Collection == nil:
+		changes = append(changes, "spec.collection: removed")
+	case collectionType(old.Collection) != collectionType(updated.Collection):
+		changes = append(changes, fmt.Sprintf("spec.collection.type: %q -> %q", collectionType(old.Collection), collectionType(updated.Collection)))
+	}
+	return changes
+}
+
+// collectionType returns the collector's type, set by the deprecated
+// collection.logs on older instances
+func collectionType(collection *cl.CollectionSpec) cl.LogCollectionType {
+	if collection.Type == "" && collection.Logs != nil {
+		return collection.Logs.Type
+	}
+	return collection.Type
+}
+
+// GetURI implements Webhook interface
+func (s *ClusterLogForwarderWebhook) GetURI() string { return "/" + WebhookName }
+
+// Validate implements Webhook interface
+func (s *ClusterLogForwarderWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	valid = valid && (request.Kind.Kind == clusterLogForwarderKind || request.Kind.Kind == clusterLoggingKind)
+	valid = valid && (len(request.OldObject.Raw) > 0)
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *ClusterLogForwarderWebhook) Name() string { return WebhookName }
+
+// FailurePolicy implements Webhook interface
+func (s *ClusterLogForwarderWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *ClusterLogForwarderWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *ClusterLogForwarderWebhook) Rules() []admissionregv1.RuleWithOperations { return rules }
+
+// ObjectSelector implements Webhook interface. The API server matches the
+// selector against both the old and the new object, so updates removing the
+// label are still sent to the webhook.
+func (s *ClusterLogForwarderWebhook) ObjectSelector() *metav1.LabelSelector {
+	return &metav1.LabelSelector{
+		MatchLabels: map[string]string{
+			labeledresources.ManagedLabel: "true",
+		},
+	}
+}
+
+// SideEffects implements Webhook interface
+func (s *ClusterLogForwarderWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *ClusterLogForwarderWebhook) TimeoutSeconds() int32 { return 2 }
+
+// Doc implements Webhook interface
+func (s *ClusterLogForwarderWebhook) Doc() string {
+	return fmt.Sprintf(docString, labeledresources.ManagedLabel)
+}
+
+// RuleDocs implements Webhook interface
+func (s *ClusterLogForwarderWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    fmt.Sprintf("Customers may not delete or unlabel ClusterLogForwarders and ClusterLogging instances labelled %s=true.", labeledresources.ManagedLabel),
+			Exceptions: []string{utils.AdminsException},
+		},
+		{
+			Summary:    "Customers may not update a managed ClusterLogForwarder so audit logs are no longer forwarded to an output a pipeline forwarded them to, nor change or remove such an output.",
+			Exceptions: []string{utils.AdminsException, "Inputs, outputs and pipelines audit logs aren't forwarded through"},
+		},
+		{
+			Summary:    "Customers may not make a managed ClusterLogging Unmanaged, nor remove or change the type of its collection.",
+			Exceptions: []string{utils.AdminsException},
+		},
+	}
+}
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+func (s *ClusterLogForwarderWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// ClassicEnabled implements Webhook interface
+func (s *ClusterLogForwarderWebhook) ClassicEnabled() bool { return true }
+
+// HypershiftEnabled implements Webhook interface
+func (s *ClusterLogForwarderWebhook) HypershiftEnabled() bool { return true }
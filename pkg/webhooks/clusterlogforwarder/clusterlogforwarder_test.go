@@ -0,0 +1,208 @@
+package clusterlogforwarder
+
+import (
+	"strings"
+	"testing"
+
+	cl "github.com/openshift/cluster-logging-operator/apis/logging/v1"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/labeledresources"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+var (
+	customer = authenticationv1.UserInfo{Username: "customer", Groups: []string{"dedicated-admins", "system:authenticated"}}
+	sre      = authenticationv1.UserInfo{Username: "backplane-cluster-admin", Groups: []string{"system:authenticated"}}
+	managed  = map[string]string{labeledresources.ManagedLabel: "true"}
+)
+
+func forwarder(labels map[string]string, mutate func(*cl.ClusterLogForwarderSpec)) runtime.Object {
+	f := &cl.ClusterLogForwarder{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "logging.openshift.io/v1", Kind: clusterLogForwarderKind},
+		ObjectMeta: metav1.ObjectMeta{Name: "instance", Namespace: "openshift-logging", Labels: labels},
+		Spec: cl.ClusterLogForwarderSpec{
+			Outputs: []cl.OutputSpec{
+				{Name: "sre-audit", Type: "http", URL: "https://audit.sre.example.com", Secret: &cl.OutputSecretSpec{Name: "sre-audit"}},
+			},
+			Pipelines: []cl.PipelineSpec{
+				{Name: "sre-audit", InputRefs: []string{cl.InputNameAudit}, OutputRefs: []string{"sre-audit"}},
+			},
+		},
+	}
+	if mutate != nil {
+		mutate(&f.Spec)
+	}
+	return f
+}
+
+func logging(labels map[string]string, mutate func(*cl.ClusterLoggingSpec)) runtime.Object {
+	c := &cl.ClusterLogging{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "logging.openshift.io/v1", Kind: clusterLoggingKind},
+		ObjectMeta: metav1.ObjectMeta{Name: "instance", Namespace: "openshift-logging", Labels: labels},
+		Spec: cl.ClusterLoggingSpec{
+			ManagementState: cl.ManagementStateManaged,
+			Collection:      &cl.CollectionSpec{Type: cl.LogCollectionTypeVector},
+		},
+	}
+	if mutate != nil {
+		mutate(&c.Spec)
+	}
+	return c
+}
+
+func TestAuthorized(t *testing.T) {
+	tests := []struct {
+		name      string
+		user      authenticationv1.UserInfo
+		operation admissionv1.Operation
+		old       runtime.Object
+		obj       runtime.Object
+		allowed   bool
+		message   string
+	}{
+		{
+			name:      "customer deletes managed forwarder",
+			user:      customer,
+			operation: admissionv1.Delete,
+			old:       forwarder(managed, nil),
+			allowed:   false,
+			message:   "Prevented from deleting the managed ClusterLogForwarder openshift-logging/instance",
+		},
+		{
+			name:      "customer deletes unmanaged forwarder",
+			user:      customer,
+			operation: admissionv1.Delete,
+			old:       forwarder(nil, nil),
+			allowed:   true,
+		},
+		{
+			name:      "customer drops audit pipeline",
+			user:      customer,
+			operation: admissionv1.Update,
+			old:       forwarder(managed, nil),
+			obj: forwarder(managed, func(spec *cl.ClusterLogForwarderSpec) {
+				spec.Pipelines[0].InputRefs = []string{cl.InputNameApplication}
+			}),
+			allowed: false,
+			message: "spec.pipelines[sre-audit]: audit logs are no longer forwarded to output sre-audit",
+		},
+		{
+			name:      "customer redirects audit output",
+			user:      customer,
+			operation: admissionv1.Update,
+			old:       forwarder(managed, nil),
+			obj: forwarder(managed, func(spec *cl.ClusterLogForwarderSpec) {
+				spec.Outputs[0].URL = "https://logs.customer.example.com"
+				spec.Outputs[0].Secret = nil
+			}),
+			allowed: false,
+			message: `spec.outputs[sre-audit].url: "https://audit.sre.example.com" -> "https://logs.customer.example.com"; spec.outputs[sre-audit].secret.name: "sre-audit" -> ""`,
+		},
+		{
+			name:      "customer removes audit output",
+			user:      customer,
+			operation: admissionv1.Update,
+			old:       forwarder(managed, nil),
+			obj: forwarder(managed, func(spec *cl.ClusterLogForwarderSpec) {
+				spec.Outputs = nil
+				spec.Pipelines = nil
+			}),
+			allowed: false,
+			message: "spec.outputs[sre-audit]: removed",
+		},
+		{
+			name:      "customer unlabels forwarder",
+			user:      customer,
+			operation: admissionv1.Update,
+			old:       forwarder(managed, nil),
+			obj:       forwarder(nil, nil),
+			allowed:   false,
+			message:   `metadata.labels[managed.openshift.io/managed]: "true" -> ""`,
+		},
+		{
+			name:      "customer adds own pipeline",
+			user:      customer,
+			operation: admissionv1.Update,
+			old:       forwarder(managed, nil),
+			obj: forwarder(managed, func(spec *cl.ClusterLogForwarderSpec) {
+				spec.Outputs = append(spec.Outputs, cl.OutputSpec{Name: "splunk", Type: "splunk", URL: "https://splunk.customer.example.com"})
+				spec.Pipelines = append(spec.Pipelines, cl.PipelineSpec{Name: "apps", InputRefs: []string{cl.InputNameApplication, cl.InputNameAudit}, OutputRefs: []string{"splunk"}})
+			}),
+			allowed: true,
+		},
+		{
+			name:      "customer splits audit pipeline",
+			user:      customer,
+			operation: admissionv1.Update,
+			old:       forwarder(managed, nil),
+			obj: forwarder(managed, func(spec *cl.ClusterLogForwarderSpec) {
+				spec.Pipelines[0].Name = "audit"
+			}),
+			allowed: true,
+		},
+		{
+			name:      "sre drops audit pipeline",
+			user:      sre,
+			operation: admissionv1.Update,
+			old:       forwarder(managed, nil),
+			obj: forwarder(managed, func(spec *cl.ClusterLogForwarderSpec) {
+				spec.Pipelines = nil
+			}),
+			allowed: true,
+		},
+		{
+			name:      "customer makes logging unmanaged",
+			user:      customer,
+			operation: admissionv1.Update,
+			old:       logging(managed, nil),
+			obj: logging(managed, func(spec *cl.ClusterLoggingSpec) {
+				spec.ManagementState = cl.ManagementStateUnmanaged
+				spec.Collection = nil
+			}),
+			allowed: false,
+			message: `spec.managementState: "Managed" -> "Unmanaged"; spec.collection: removed`,
+		},
+		{
+			name:      "customer changes logging collector",
+			user:      customer,
+			operation: admissionv1.Update,
+			old: logging(managed, func(spec *cl.ClusterLoggingSpec) {
+				spec.Collection = &cl.CollectionSpec{Logs: &cl.LogCollectionSpec{Type: cl.LogCollectionTypeFluentd}}
+			}),
+			obj:     logging(managed, nil),
+			allowed: false,
+			message: `spec.collection.type: "fluentd" -> "vector"`,
+		},
+		{
+			name:      "customer deletes managed logging",
+			user:      customer,
+			operation: admissionv1.Delete,
+			old:       logging(managed, nil),
+			allowed:   false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gvk := metav1.GroupVersionKind{Group: "logging.openshift.io", Version: "v1", Kind: test.old.GetObjectKind().GroupVersionKind().Kind}
+			request := testutils.NewRequest(t, gvk, test.operation, test.user, "openshift-logging", "instance", test.obj, test.old)
+			hook := NewWebhook()
+			if !hook.Validate(request) {
+				t.Fatalf("Expected request to be valid")
+			}
+			response := hook.Authorized(request)
+			if response.Allowed != test.allowed {
+				t.Fatalf("Expected allowed %v, got %v: %v", test.allowed, response.Allowed, response.Result)
+			}
+			if response.UID != request.UID {
+				t.Fatalf("Expected UID %s, got %s", request.UID, response.UID)
+			}
+			if test.message != "" && !strings.Contains(response.Result.Message, test.message) {
+				t.Errorf("Expected the message to contain %q, got %s", test.message, response.Result.Message)
+			}
+		})
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"strconv"
 
 	cl "github.com/openshift/cluster-logging-operator/apis/logging/v1"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
 	utils "github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
 	admissionregv1 "k8s.io/api/admissionregistration/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -51,6 +52,15 @@ func (s *ClusterloggingWebhook) Doc() string {
 	return docString
 }
 
+// RuleDocs implements Webhook interface
+func (s *ClusterloggingWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary: "Customers may not set the log retention of a ClusterLogging outside the allowed range of 0-7 days.",
+		},
+	}
+}
+
 // TimeoutSeconds implements Webhook interface
 func (s *ClusterloggingWebhook) TimeoutSeconds() int32 { return 1 }
 
@@ -104,7 +114,7 @@ func (r *retentionPolicyValidator) checkPolicy(retentionPolicy *cl.RetentionPoli
 		return false, admissionctl.Errored(http.StatusBadRequest, err)
 	}
 	if !isAllowed {
-		return false, admissionctl.Denied(deniedMessage)
+		return false, response.Denied(response.LogRetentionOutOfRange, deniedMessage)
 	}
 	return true, admissionctl.Allowed("Allowed to create ClusterLogging")
 }
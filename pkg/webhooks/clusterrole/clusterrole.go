@@ -7,6 +7,8 @@ import (
 	"slices"
 	"strings"
 
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
 	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionregv1 "k8s.io/api/admissionregistration/v1"
@@ -51,16 +53,6 @@ var (
 		"system:kube-scheduler",
 		"system:kube-controller-manager",
 	}
-
-	// Users allowed to delete protected ClusterRoles
-	allowedUsers = []string{
-		"backplane-cluster-admin",
-	}
-
-	// Groups allowed to delete protected ClusterRoles
-	allowedGroups = []string{
-		"system:serviceaccounts:openshift-backplane-srep",
-	}
 )
 
 type ClusterRoleWebHook struct {
@@ -96,7 +88,7 @@ func (s *ClusterRoleWebHook) authorized(request admissionctl.Request) admissionc
 
 	if request.AdmissionRequest.UserInfo.Username == "system:unauthenticated" {
 		log.Info("system:unauthenticated made a webhook request. Check RBAC rules", "request", request.AdmissionRequest)
-		ret = admissionctl.Denied("Unauthenticated")
+		ret = response.Denied(response.Unauthenticated, "Unauthenticated")
 		ret.UID = request.AdmissionRequest.UID
 		return ret
 	}
@@ -121,12 +113,12 @@ func (s *ClusterRoleWebHook) authorized(request admissionctl.Request) admissionc
 
 	log.Info(fmt.Sprintf("Found clusterrole: %v", clusterRole.Name))
 
-	if isProtectedClusterRole(clusterRole) && !isAllowedUserGroup(request) {
+	if isProtectedClusterRole(clusterRole) && !identity.IsSRE(request.UserInfo) {
 		switch request.Operation {
 		case admissionv1.Delete:
 			log.Info(fmt.Sprintf("Deleting operation detected on ClusterRole: %v", clusterRole.Name))
 
-			ret = admissionctl.Denied(fmt.Sprintf("Deleting ClusterRole %v is not allowed", clusterRole.Name))
+			ret = response.Denied(response.ManagedRBAC, fmt.Sprintf("Deleting ClusterRole %v is not allowed", clusterRole.Name))
 			ret.UID = request.AdmissionRequest.UID
 			return ret
 		}
@@ -153,19 +145,6 @@ func (s *ClusterRoleWebHook) renderClusterRole(request admissionctl.Request) (*r
 	return clusterRole, nil
 }
 
-// isAllowedUserGroup checks if the user or group is allowed to perform the action
-func isAllowedUserGroup(request admissionctl.Request) bool {
-	if slices.Contains(allowedUsers, request.UserInfo.Username) {
-		return true
-	}
-	for _, group := range allowedGroups {
-		if slices.Contains(request.UserInfo.Groups, group) {
-			return true
-		}
-	}
-	return false
-}
-
 // isProtectedClusterRole returns true if the ClusterRole is in the protected list or matches protected patterns
 func isProtectedClusterRole(clusterRole *rbacv1.ClusterRole) bool {
 	// Check if it's in the explicit protected list (includes specific system roles)
@@ -233,6 +212,16 @@ func (s *ClusterRoleWebHook) Doc() string {
 	return docString
 }
 
+// RuleDocs implements Webhook interface
+func (s *ClusterRoleWebHook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers may not delete protected ClusterRoles, including cluster-admin, view, edit, admin, the system roles and backplane-* roles.",
+			Exceptions: []string{utils.SystemUsersException},
+		},
+	}
+}
+
 // SyncSetLabelSelector returns the label selector to use in the SyncSet.
 // Return utils.DefaultLabelSelector() to stick with the default
 func (s *ClusterRoleWebHook) SyncSetLabelSelector() metav1.LabelSelector {
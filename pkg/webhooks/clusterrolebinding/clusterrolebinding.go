@@ -8,6 +8,8 @@ import (
 	"slices"
 	"strings"
 
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
 	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionregv1 "k8s.io/api/admissionregistration/v1"
@@ -50,13 +52,6 @@ var (
 		"openshift-backplane-managed-scripts",
 		"openshift-gitops",
 	}
-
-	allowedUsers = []string{
-		"backplane-cluster-admin",
-	}
-	allowedGroups = []string{
-		"system:serviceaccounts:openshift-backplane-srep",
-	}
 )
 
 type ClusterRoleBindingWebHook struct {
@@ -92,7 +87,7 @@ func (s *ClusterRoleBindingWebHook) authorized(request admissionctl.Request) adm
 
 	if request.AdmissionRequest.UserInfo.Username == "system:unauthenticated" {
 		log.Info("system:unauthenticated made a webhook request. Check RBAC rules", "request", request.AdmissionRequest)
-		ret = admissionctl.Denied("Unauthenticated")
+		ret = response.Denied(response.Unauthenticated, "Unauthenticated")
 		ret.UID = request.AdmissionRequest.UID
 		return ret
 	}
@@ -115,7 +110,7 @@ func (s *ClusterRoleBindingWebHook) authorized(request admissionctl.Request) adm
 
 	log.Info(fmt.Sprintf("Found clusterrolebinding: %v", clusterRoleBinding.Name))
 
-	if isProtectedNamespace(clusterRoleBinding) && !isAllowedUserGroup(request) {
+	if isProtectedNamespace(clusterRoleBinding) && !identity.IsSRE(request.UserInfo) {
 		switch request.Operation {
 		case admissionv1.Delete:
 			log.Info(fmt.Sprintf("Deleting operation detected on ClusterRoleBinding: %v", clusterRoleBinding.Name))
@@ -127,7 +122,7 @@ func (s *ClusterRoleBindingWebHook) authorized(request admissionctl.Request) adm
 				return ret
 			}
 
-			ret = admissionctl.Denied(fmt.Sprintf("Deleting ClusterRoleBinding %v is not allowed", clusterRoleBinding.Name))
+			ret = response.Denied(response.ManagedRBAC, fmt.Sprintf("Deleting ClusterRoleBinding %v is not allowed", clusterRoleBinding.Name))
 			ret.UID = request.AdmissionRequest.UID
 			return ret
 		}
@@ -154,19 +149,6 @@ func (s *ClusterRoleBindingWebHook) renderClusterRoleBinding(request admissionct
 	return clusterRoleBinding, nil
 }
 
-// isAllowedUserGroup checks if the user or group is allowed to perform the action
-func isAllowedUserGroup(request admissionctl.Request) bool {
-	if slices.Contains(allowedUsers, request.UserInfo.Username) {
-		return true
-	}
-	for _, group := range allowedGroups {
-		if slices.Contains(request.UserInfo.Groups, group) {
-			return true
-		}
-	}
-	return false
-}
-
 // isProtectedNamespace returns true if clusterRoleBinding subject link
 // to ServiceAccount and openshift-*|kube-system ns
 func isProtectedNamespace(clusterRoleBinding *rbacv1.ClusterRoleBinding) bool {
@@ -235,6 +217,16 @@ func (s *ClusterRoleBindingWebHook) Doc() string {
 	return fmt.Sprintf(docString, managedNamespaces)
 }
 
+// RuleDocs implements Webhook interface
+func (s *ClusterRoleBindingWebHook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers may not delete the ClusterRoleBindings of the managed namespaces.",
+			Exceptions: []string{utils.SystemUsersException, "Cluster admins deleting the ClusterRoleBindings of must-gather"},
+		},
+	}
+}
+
 // SyncSetLabelSelector returns the label selector to use in the SyncSet.
 // Return utils.DefaultLabelSelector() to stick with the default
 func (s *ClusterRoleBindingWebHook) SyncSetLabelSelector() metav1.LabelSelector {
@@ -0,0 +1,279 @@
+package clusterversion
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "clusterversion-validation"
+	docString   string = `Managed OpenShift customers, including cluster admins, may not change the channel, desired update or update server (%s) of the ClusterVersion. Upgrades are scheduled through OpenShift Cluster Manager, which the managed upgrade operator carries out in the cluster's maintenance window.`
+
+	// clusterVersionName is the name of the singleton ClusterVersion
+	clusterVersionName string = "version"
+
+	// upgradeOperatorNamespace runs the managed upgrade operator, which
+	// applies the upgrades scheduled in OpenShift Cluster Manager through
+	// UpgradeConfigs
+	upgradeOperatorNamespace string = "openshift-managed-upgrade-operator"
+)
+
+var (
+	scope = admissionregv1.ClusterScope
+	rules = []admissionregv1.RuleWithOperations{
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Update},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{"config.openshift.io"},
+				APIVersions: []string{"*"},
+				Resources:   []string{"clusterversions"},
+				Scope:       &scope,
+			},
+		},
+	}
+	log = logf.Log.WithName(WebhookName)
+
+	// protectedFields are the fields of the ClusterVersion which may not be
+	// changed, as paths
+	protectedFields = [][]string{
+		{"spec", "channel"},
+		{"spec", "desiredUpdate"},
+		{"spec", "upstream"},
+	}
+
+	upgradeConfigGVK = schema.GroupVersionKind{Group: "upgrade.managed.openshift.io", Version: "v1alpha1", Kind: "UpgradeConfigList"}
+)
+
+// ClusterVersionWebhook protects the upgrades of the cluster, which are
+// managed through OpenShift Cluster Manager
+type ClusterVersionWebhook struct {
+	s          *runtime.Scheme
+	kubeClient client.Client
+}
+
+// NewWebhook creates a new webhook
+func NewWebhook() *ClusterVersionWebhook {
+	scheme, err := k8sutil.SharedScheme()
+	if err != nil {
+		log.Error(err, "Fail getting the shared scheme for ClusterVersionWebhook")
+		os.Exit(1)
+	}
+
+	return &ClusterVersionWebhook{
+		s: scheme,
+	}
+}
+
+// InjectClient implements ClientWebhook interface
+func (s *ClusterVersionWebhook) InjectClient(c client.Client) {
+	s.kubeClient = c
+}
+
+// CachedObjects implements ClientWebhook interface. UpgradeConfigs are only
+// read for the messages of denials, so they aren't cached.
+func (s *ClusterVersionWebhook) CachedObjects() []client.Object { return nil }
+
+// Authorized implements Webhook interface
+func (s *ClusterVersionWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.AuthorizedContext(context.Background(), request)
+}
+
+// AuthorizedContext implements ContextWebhook interface
+func (s *ClusterVersionWebhook) AuthorizedContext(ctx context.Context, request admissionctl.Request) admissionctl.Response {
+	return s.authorized(ctx, request)
+}
+
+func (s *ClusterVersionWebhook) authorized(ctx context.Context, request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if request.Name != clusterVersionName {
+		ret = admissionctl.Allowed("Only the cluster's ClusterVersion is protected")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	if isUpgrader(request.UserInfo) {
+		ret = admissionctl.Allowed("SRE and the upgrade operators may change the ClusterVersion")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	changed, err := changedFields(request.OldObject.Raw, request.Object.Raw)
+	if err != nil {
+		log.Error(err, "Couldn't decode the ClusterVersion from the request")
+		ret = admissionctl.Errored(http.StatusBadRequest, err)
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	if len(changed) == 0 {
+		ret = admissionctl.Allowed("The channel, desired update and update server are unchanged")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	log.Info("Denying change of the cluster's upgrades", "fields", changed, "user", request.UserInfo.Username)
+	ret = response.Denied(response.ManagedUpgrade, fmt.Sprintf("Prevented from changing %s of the ClusterVersion: upgrades of managed clusters are scheduled through OpenShift Cluster Manager at https://console.redhat.com/openshift, and carried out in the cluster's maintenance window. %s If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", strings.Join(changed, ", "), s.scheduleHint(ctx)))
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// changedFields returns the protected fields which differ between the old
+// and new ClusterVersion, as dotted paths
+func changedFields(oldRaw, newRaw []byte) ([]string, error) {
+	old := map[string]interface{}{}
+	if err := json.Unmarshal(oldRaw, &old); err != nil {
+		return nil, err
+	}
+	obj := map[string]interface{}{}
+	if err := json.Unmarshal(newRaw, &obj); err != nil {
+		return nil, err
+	}
+
+	changed := []string{}
+	for _, path := range protectedFields {
+		oldValue, _, _ := unstructured.NestedFieldNoCopy(old, path...)
+		newValue, _, _ := unstructured.NestedFieldNoCopy(obj, path...)
+		if !equality.Semantic.DeepEqual(oldValue, newValue) {
+			changed = append(changed, strings.Join(path, "."))
+		}
+	}
+	return changed, nil
+}
+
+// scheduleHint describes the upgrade currently scheduled for the cluster, so
+// customers know what their change would have interfered with. When it can't
+// be read, customers are pointed to OpenShift Cluster Manager for it.
+func (s *ClusterVersionWebhook) scheduleHint(ctx context.Context) string {
+	const (
+		unknown = "The cluster's upgrade schedule is shown in OpenShift Cluster Manager."
+		none    = "No upgrade is scheduled; schedule one or change the channel in OpenShift Cluster Manager."
+	)
+	if s.kubeClient == nil {
+		var err error
+		s.kubeClient, err = k8sutil.KubeClient(s.s)
+		if err != nil {
+			log.Error(err, "Failed to create a client to read the upgrade schedule")
+			return unknown
+		}
+	}
+
+	upgradeConfigs := &unstructured.UnstructuredList{}
+	upgradeConfigs.SetGroupVersionKind(upgradeConfigGVK)
+	if err := s.kubeClient.List(ctx, upgradeConfigs, client.InNamespace(upgradeOperatorNamespace)); err != nil {
+		log.Error(err, "Failed to read the upgrade schedule")
+		return unknown
+	}
+	for _, upgradeConfig := range upgradeConfigs.Items {
+		version, _, _ := unstructured.NestedString(upgradeConfig.Object, "spec", "desired", "version")
+		upgradeAt, _, _ := unstructured.NestedString(upgradeConfig.Object, "spec", "upgradeAt")
+		if version != "" && upgradeAt != "" {
+			return fmt.Sprintf("The upgrade to %s is scheduled to start at %s.", version, upgradeAt)
+		}
+	}
+	return none
+}
+
+// isUpgrader returns true if the user manages the cluster's upgrades: the
+// managers of the cluster, including the operators applying upgrades, or
+// system users
+func isUpgrader(user authenticationv1.UserInfo) bool {
+	if identity.IsManager(user) {
+		return true
+	}
+	// as regular-user-validation, system users other than service accounts
+	// are trusted
+	return strings.HasPrefix(user.Username, "system:") && !strings.HasPrefix(user.Username, "system:serviceaccount:")
+}
+
+// GetURI implements Webhook interface
+func (s *ClusterVersionWebhook) GetURI() string { return "/" + WebhookName }
+
+// Validate implements Webhook interface
+func (s *ClusterVersionWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	valid = valid && (request.Kind.Kind == "ClusterVersion")
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *ClusterVersionWebhook) Name() string { return WebhookName }
+
+// FailurePolicy implements Webhook interface
+func (s *ClusterVersionWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *ClusterVersionWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *ClusterVersionWebhook) Rules() []admissionregv1.RuleWithOperations { return rules }
+
+// ObjectSelector implements Webhook interface
+func (s *ClusterVersionWebhook) ObjectSelector() *metav1.LabelSelector { return nil }
+
+// SideEffects implements Webhook interface
+func (s *ClusterVersionWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *ClusterVersionWebhook) TimeoutSeconds() int32 { return 2 }
+
+// Doc implements Webhook interface
+func (s *ClusterVersionWebhook) Doc() string {
+	return fmt.Sprintf(docString, fieldList())
+}
+
+// RuleDocs implements Webhook interface
+func (s *ClusterVersionWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    fmt.Sprintf("Customers, including cluster admins, may not change %s of the ClusterVersion.", fieldList()),
+			Exceptions: []string{utils.SREException, utils.PrivilegedServiceAccountsException, "Kubernetes and OpenShift system users, other than service accounts"},
+		},
+	}
+}
+
+// fieldList renders the protected fields for the documentation
+func fieldList() string {
+	fields := make([]string, 0, len(protectedFields))
+	for _, path := range protectedFields {
+		fields = append(fields, strings.Join(path, "."))
+	}
+	return strings.Join(fields, ", ")
+}
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+func (s *ClusterVersionWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// ClassicEnabled implements Webhook interface
+func (s *ClusterVersionWebhook) ClassicEnabled() bool { return true }
+
+// HypershiftEnabled implements Webhook interface. The ClusterVersion of a
+// hosted cluster is reconciled from its HostedCluster, outside the cluster.
+func (s *ClusterVersionWebhook) HypershiftEnabled() bool { return false }
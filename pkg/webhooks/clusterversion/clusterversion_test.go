@@ -0,0 +1,149 @@
+package clusterversion
+
+import (
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+)
+
+var (
+	clusterAdmin = authenticationv1.UserInfo{Username: "kube:admin", Groups: []string{"system:cluster-admins", "system:authenticated"}}
+	sre          = authenticationv1.UserInfo{Username: "backplane-cluster-admin"}
+	muo          = authenticationv1.UserInfo{Username: "system:serviceaccount:openshift-managed-upgrade-operator:managed-upgrade-operator", Groups: []string{"system:serviceaccounts", "system:serviceaccounts:openshift-managed-upgrade-operator"}}
+	otherSA      = authenticationv1.UserInfo{Username: "system:serviceaccount:customer:upgrader", Groups: []string{"system:serviceaccounts", "system:serviceaccounts:customer"}}
+)
+
+const (
+	clusterVersion = `{"apiVersion":"config.openshift.io/v1","kind":"ClusterVersion","metadata":{"name":"version"},
+		"spec":{"channel":"stable-4.16","clusterID":"9f4a1c3e-0000-0000-0000-000000000000"}}`
+	clusterVersionNewChannel = `{"apiVersion":"config.openshift.io/v1","kind":"ClusterVersion","metadata":{"name":"version"},
+		"spec":{"channel":"candidate-4.17","clusterID":"9f4a1c3e-0000-0000-0000-000000000000"}}`
+	clusterVersionUpdate = `{"apiVersion":"config.openshift.io/v1","kind":"ClusterVersion","metadata":{"name":"version"},
+		"spec":{"channel":"stable-4.16","clusterID":"9f4a1c3e-0000-0000-0000-000000000000","desiredUpdate":{"version":"4.16.9"},"upstream":"https://updates.example.com/graph"}}`
+	clusterVersionAnnotated = `{"apiVersion":"config.openshift.io/v1","kind":"ClusterVersion","metadata":{"name":"version","annotations":{"example.com/note":"x"}},
+		"spec":{"channel":"stable-4.16","clusterID":"9f4a1c3e-0000-0000-0000-000000000000"}}`
+)
+
+func newRequest(t *testing.T, user authenticationv1.UserInfo, name, oldObject, object string) admissionctl.Request {
+	gvk := metav1.GroupVersionKind{Group: "config.openshift.io", Version: "v1", Kind: "ClusterVersion"}
+	return testutils.NewRequest(t, gvk, admissionv1.Update, user, "", name, []byte(object), []byte(oldObject))
+}
+
+func TestAuthorized(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    authenticationv1.UserInfo
+		objName string
+		object  string
+		allowed bool
+		message string
+	}{
+		{
+			name:    "cluster admin changing the channel",
+			user:    clusterAdmin,
+			objName: "version",
+			object:  clusterVersionNewChannel,
+			allowed: false,
+			message: "Prevented from changing spec.channel of the ClusterVersion",
+		},
+		{
+			name:    "service account requesting an update from another server",
+			user:    otherSA,
+			objName: "version",
+			object:  clusterVersionUpdate,
+			allowed: false,
+			message: "spec.desiredUpdate, spec.upstream",
+		},
+		{
+			name:    "cluster admin annotating the ClusterVersion",
+			user:    clusterAdmin,
+			objName: "version",
+			object:  clusterVersionAnnotated,
+			allowed: true,
+		},
+		{
+			name:    "managed upgrade operator",
+			user:    muo,
+			objName: "version",
+			object:  clusterVersionUpdate,
+			allowed: true,
+		},
+		{
+			name:    "sre",
+			user:    sre,
+			objName: "version",
+			object:  clusterVersionNewChannel,
+			allowed: true,
+		},
+		{
+			name:    "another ClusterVersion",
+			user:    clusterAdmin,
+			objName: "other",
+			object:  clusterVersionNewChannel,
+			allowed: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hook := NewWebhook()
+			hook.InjectClient(fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build())
+			request := newRequest(t, test.user, test.objName, clusterVersion, test.object)
+			if !hook.Validate(request) {
+				t.Fatalf("Expected request to be valid")
+			}
+			ret := hook.Authorized(request)
+			if ret.Allowed != test.allowed {
+				t.Fatalf("Expected allowed %v, got %v: %v", test.allowed, ret.Allowed, ret.Result)
+			}
+			if ret.UID != request.UID {
+				t.Fatalf("Expected UID %s, got %s", request.UID, ret.UID)
+			}
+			if !test.allowed && response.CodeOf(ret) != response.ManagedUpgrade {
+				t.Errorf("Expected code %s, got %s", response.ManagedUpgrade, response.CodeOf(ret))
+			}
+			if test.message != "" && !strings.Contains(ret.Result.Message, test.message) {
+				t.Errorf("Expected the message to contain %q, got %s", test.message, ret.Result.Message)
+			}
+		})
+	}
+}
+
+func TestScheduleHint(t *testing.T) {
+	upgradeConfig := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "upgrade.managed.openshift.io/v1alpha1",
+		"kind":       "UpgradeConfig",
+		"metadata":   map[string]interface{}{"name": "managed-upgrade-config", "namespace": upgradeOperatorNamespace},
+		"spec": map[string]interface{}{
+			"type":      "OSD",
+			"upgradeAt": "2026-10-20T02:00:00Z",
+			"desired":   map[string]interface{}{"version": "4.16.9", "channel": "stable-4.16"},
+		},
+	}}
+
+	hook := NewWebhook()
+	hook.InjectClient(fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(upgradeConfig).Build())
+	ret := hook.Authorized(newRequest(t, clusterAdmin, "version", clusterVersion, clusterVersionNewChannel))
+	if ret.Allowed {
+		t.Fatalf("Expected the channel change to be denied")
+	}
+	if expected := "The upgrade to 4.16.9 is scheduled to start at 2026-10-20T02:00:00Z."; !strings.Contains(ret.Result.Message, expected) {
+		t.Errorf("Expected the message to contain %q, got %s", expected, ret.Result.Message)
+	}
+
+	hook.InjectClient(fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build())
+	ret = hook.Authorized(newRequest(t, clusterAdmin, "version", clusterVersion, clusterVersionNewChannel))
+	if expected := "No upgrade is scheduled"; !strings.Contains(ret.Result.Message, expected) {
+		t.Errorf("Expected the message to contain %q, got %s", expected, ret.Result.Message)
+	}
+}
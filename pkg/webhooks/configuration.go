@@ -0,0 +1,121 @@
+package webhooks
+
+import (
+	"fmt"
+	"strings"
+
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/config"
+)
+
+// IsMutating tells whether the webhook is registered with a
+// MutatingWebhookConfiguration. MutatingWebhookConfigurations have special
+// names (e.g., service-mutation).
+func IsMutating(name string) bool {
+	return strings.HasSuffix(name, "-mutation")
+}
+
+// ConfigurationName is the name of the webhook's Validating or
+// MutatingWebhookConfiguration
+func ConfigurationName(name string) string {
+	return fmt.Sprintf("sre-%s", name)
+}
+
+// ValidatingWebhookConfiguration is the ValidatingWebhookConfiguration
+// registering the webhook, served by the Service in namespace
+func ValidatingWebhookConfiguration(hook Webhook, namespace string) admissionregv1.ValidatingWebhookConfiguration {
+	return admissionregv1.ValidatingWebhookConfiguration{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "ValidatingWebhookConfiguration",
+			APIVersion: "admissionregistration.k8s.io/v1",
+		},
+		ObjectMeta: configurationMeta(hook),
+		Webhooks: []admissionregv1.ValidatingWebhook{
+			{
+				AdmissionReviewVersions: []string{"v1"},
+				TimeoutSeconds:          ptr.To(hook.TimeoutSeconds()),
+				SideEffects:             ptr.To(hook.SideEffects()),
+				MatchPolicy:             ptr.To(hook.MatchPolicy()),
+				Name:                    fmt.Sprintf("%s.managed.openshift.io", hook.Name()),
+				ObjectSelector:          hook.ObjectSelector(),
+				NamespaceSelector:       NamespaceSelector(hook),
+				FailurePolicy:           ptr.To(FailurePolicy(hook)),
+				ClientConfig:            clientConfig(hook, namespace),
+				Rules:                   hook.Rules(),
+			},
+		},
+	}
+}
+
+// MutatingWebhookConfiguration is the MutatingWebhookConfiguration
+// registering the webhook, served by the Service in namespace
+func MutatingWebhookConfiguration(hook Webhook, namespace string) admissionregv1.MutatingWebhookConfiguration {
+	return admissionregv1.MutatingWebhookConfiguration{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "MutatingWebhookConfiguration",
+			APIVersion: "admissionregistration.k8s.io/v1",
+		},
+		ObjectMeta: configurationMeta(hook),
+		Webhooks: []admissionregv1.MutatingWebhook{
+			{
+				AdmissionReviewVersions: []string{"v1"},
+				TimeoutSeconds:          ptr.To(hook.TimeoutSeconds()),
+				SideEffects:             ptr.To(hook.SideEffects()),
+				MatchPolicy:             ptr.To(hook.MatchPolicy()),
+				Name:                    fmt.Sprintf("%s.managed.openshift.io", hook.Name()),
+				ObjectSelector:          hook.ObjectSelector(),
+				NamespaceSelector:       NamespaceSelector(hook),
+				FailurePolicy:           ptr.To(FailurePolicy(hook)),
+				ClientConfig:            clientConfig(hook, namespace),
+				Rules:                   hook.Rules(),
+			},
+		},
+	}
+}
+
+// NamespaceSelector is the namespaceSelector the webhook is registered with.
+// It selects only the namespaces opted in with CanaryLabel for canaries, and
+// leaves out the namespaces webhooks implementing NamespaceExclusionWebhook
+// exclude. It's nil for webhooks called for every namespace.
+func NamespaceSelector(hook Webhook) *metav1.LabelSelector {
+	selector := &metav1.LabelSelector{}
+	if IsCanary(hook) {
+		selector.MatchLabels = map[string]string{
+			CanaryLabel: "true",
+		}
+	}
+	if exclusionHook, ok := hook.(NamespaceExclusionWebhook); ok {
+		selector.MatchExpressions = exclusionHook.ExcludedNamespaces().MatchExpressions()
+	}
+	if selector.MatchLabels == nil && selector.MatchExpressions == nil {
+		return nil
+	}
+	return selector
+}
+
+func configurationMeta(hook Webhook) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name: ConfigurationName(hook.Name()),
+
+		Annotations: map[string]string{
+			// service.beta.openshift.io/inject-cabundle annotation will instruct
+			// service-ca-operator to install a CA cert in the
+			// WebhookConfiguration object, which is required for Kubernetes to
+			// communicate securely to the Service.
+			"service.beta.openshift.io/inject-cabundle": "true",
+		},
+	}
+}
+
+func clientConfig(hook Webhook, namespace string) admissionregv1.WebhookClientConfig {
+	return admissionregv1.WebhookClientConfig{
+		Service: &admissionregv1.ServiceReference{
+			Namespace: namespace,
+			Path:      ptr.To(hook.GetURI()),
+			Name:      config.OperatorName,
+		},
+	}
+}
@@ -0,0 +1,327 @@
+package costlabels
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	hookconfig "github.com/openshift/managed-cluster-validating-webhooks/pkg/config"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/dependencies"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	"gomodules.xyz/jsonpatch/v2"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "costlabels-mutation"
+	docString   string = `Pods and Deployments customers create on Managed OpenShift clusters are given their namespace's cost allocation labels %s, so the cluster's usage can be charged back to the teams running it. Customers may not create them in namespaces lacking those labels. The policy is opt-in, and only enforced on clusters which enable the webhook.`
+)
+
+var (
+	scope = admissionregv1.NamespacedScope
+	rules = []admissionregv1.RuleWithOperations{
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Create},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{""},
+				APIVersions: []string{"v1"},
+				Resources:   []string{"pods"},
+				Scope:       &scope,
+			},
+		},
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Create},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{"apps"},
+				APIVersions: []string{"v1"},
+				Resources:   []string{"deployments"},
+				Scope:       &scope,
+			},
+		},
+	}
+	log = logf.Log.WithName(WebhookName)
+
+	// CostAllocationLabels are copied from the namespace onto the workloads
+	// created in it, sorted
+	CostAllocationLabels = []string{"cost-center", "team"}
+)
+
+// CostLabelsWebhook copies the namespace's cost allocation labels onto the
+// workloads customers create
+type CostLabelsWebhook struct {
+	s       *runtime.Scheme
+	decoder admissionctl.Decoder
+	// kubeClient is created when the dependencies have no client
+	kubeClient client.Client
+}
+
+// NewWebhook creates a new webhook
+func NewWebhook() *CostLabelsWebhook {
+	scheme, err := k8sutil.SharedScheme()
+	if err != nil {
+		log.Error(err, "Fail getting the shared scheme for CostLabelsWebhook")
+		os.Exit(1)
+	}
+	decoder, err := k8sutil.SharedDecoder()
+	if err != nil {
+		log.Error(err, "Fail getting the shared decoder for CostLabelsWebhook")
+		os.Exit(1)
+	}
+
+	return &CostLabelsWebhook{
+		s:       scheme,
+		decoder: decoder,
+	}
+}
+
+// CachedObjects implements CachingWebhook interface. Namespaces are read for
+// their cost allocation labels.
+func (s *CostLabelsWebhook) CachedObjects() []client.Object {
+	return []client.Object{&corev1.Namespace{}}
+}
+
+// Authorized implements Webhook interface
+func (s *CostLabelsWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.Admit(context.Background(), dependencies.Current(), request)
+}
+
+// Admit implements WebhookV2 interface. It labels the workload with its
+// namespace's cost allocation labels, denying customers creating it when the
+// namespace lacks them. Errors fail open, as the webhook's FailurePolicy does.
+func (s *CostLabelsWebhook) Admit(ctx context.Context, deps dependencies.Bundle, request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if s.ExcludedNamespaces().Excludes(request.Namespace) {
+		ret = admissionctl.Allowed("Workloads in managed namespaces are not cost allocated")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	c, err := s.client(deps)
+	if err != nil {
+		log.Error(err, "Failed to create a client to read the namespace")
+		ret = admissionctl.Allowed("Unable to read the namespace's cost allocation labels")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	ns := &corev1.Namespace{}
+	if err := c.Get(ctx, client.ObjectKey{Name: request.Namespace}, ns); err != nil {
+		log.Error(err, "Failed to read the namespace's cost allocation labels", "namespace", request.Namespace)
+		ret = admissionctl.Allowed("Unable to read the namespace's cost allocation labels")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	labels, missing := costLabels(ns.Labels)
+	if len(missing) > 0 {
+		// Workloads SRE and the platform create, such as the pods of a Job,
+		// are labelled with what the namespace has rather than denied
+		if identity.IsAdmin(request.UserInfo) {
+			if len(labels) == 0 {
+				ret = admissionctl.Allowed("Namespace has no cost allocation labels")
+				ret.UID = request.AdmissionRequest.UID
+				return ret
+			}
+		} else {
+			log.Info("Denying workload in namespace without cost allocation labels", "namespace", request.Namespace, "kind", request.Kind.Kind, "name", request.Name, "missing", missing, "user", request.UserInfo.Username)
+			ret = response.Denied(response.MissingCostAllocationLabels, fmt.Sprintf("Prevented from creating %s %s in namespace %s, which lacks the cost allocation labels %s. Label the namespace, and create the %s again. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", strings.ToLower(request.Kind.Kind), request.Name, request.Namespace, strings.Join(missing, ", "), strings.ToLower(request.Kind.Kind)))
+			ret.UID = request.AdmissionRequest.UID
+			return ret
+		}
+	}
+
+	var patches []jsonpatch.JsonPatchOperation
+	switch request.Kind.Kind {
+	case "Pod":
+		pod := &corev1.Pod{}
+		if err := s.decoder.Decode(request, pod); err != nil {
+			log.Error(err, "Couldn't decode the Pod from the request")
+			ret = admissionctl.Errored(http.StatusBadRequest, err)
+			ret.UID = request.AdmissionRequest.UID
+			return ret
+		}
+		patches = buildPatch("/metadata/labels", pod.Labels, labels)
+	case "Deployment":
+		deployment := &appsv1.Deployment{}
+		if err := s.decoder.Decode(request, deployment); err != nil {
+			log.Error(err, "Couldn't decode the Deployment from the request")
+			ret = admissionctl.Errored(http.StatusBadRequest, err)
+			ret.UID = request.AdmissionRequest.UID
+			return ret
+		}
+		// The pod template is labelled too, so the Deployment's pods are
+		// labelled without each being patched
+		patches = append(buildPatch("/metadata/labels", deployment.Labels, labels),
+			buildPatch("/spec/template/metadata/labels", deployment.Spec.Template.Labels, labels)...)
+	}
+	if len(patches) == 0 {
+		ret = admissionctl.Allowed("Workload already has the cost allocation labels")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	log.Info("Adding cost allocation labels", "namespace", request.Namespace, "kind", request.Kind.Kind, "name", request.Name, "user", request.UserInfo.Username)
+	ret = admissionctl.Patched(fmt.Sprintf("Added the cost allocation labels of namespace '%s'", request.Namespace), patches...)
+	// ret.Complete() sets the UID and finalizes the patch
+	if err := ret.Complete(request); err != nil {
+		log.Error(err, "Failed to complete the request")
+		ret = admissionctl.Errored(http.StatusInternalServerError, err)
+		ret.UID = request.AdmissionRequest.UID
+	}
+	return ret
+}
+
+// costLabels returns the cost allocation labels the namespace has, and the
+// keys of those it lacks, sorted. Empty values count as missing.
+func costLabels(nsLabels map[string]string) (map[string]string, []string) {
+	labels := map[string]string{}
+	missing := []string{}
+	for _, key := range CostAllocationLabels {
+		if value := nsLabels[key]; value != "" {
+			labels[key] = value
+		} else {
+			missing = append(missing, key)
+		}
+	}
+	return labels, missing
+}
+
+// buildPatch returns the JSONPatch operations setting the labels in the
+// labels map at path, which has the existing labels, creating the map if
+// there is none. The namespace's values replace those the workload sets, so
+// workloads can't be charged to another team.
+func buildPatch(path string, existing, labels map[string]string) []jsonpatch.JsonPatchOperation {
+	keys := make([]string, 0, len(labels))
+	for key, value := range labels {
+		if current, ok := existing[key]; !ok || current != value {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	if existing == nil {
+		return []jsonpatch.JsonPatchOperation{jsonpatch.NewOperation("add", path, labels)}
+	}
+	sort.Strings(keys)
+	patches := make([]jsonpatch.JsonPatchOperation, 0, len(keys))
+	for _, key := range keys {
+		patches = append(patches, jsonpatch.NewOperation("add", path+"/"+escapeJSONPointer(key), labels[key]))
+	}
+	return patches
+}
+
+// escapeJSONPointer escapes a label key for use in a JSONPatch path
+func escapeJSONPointer(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
+
+// client returns the dependencies' client, creating one if they have none
+func (s *CostLabelsWebhook) client(deps dependencies.Bundle) (client.Client, error) {
+	if deps.Client != nil {
+		return deps.Client, nil
+	}
+	if s.kubeClient == nil {
+		var err error
+		if s.kubeClient, err = k8sutil.KubeClient(s.s); err != nil {
+			return nil, err
+		}
+	}
+	return s.kubeClient, nil
+}
+
+// GetURI implements Webhook interface
+func (s *CostLabelsWebhook) GetURI() string { return "/" + WebhookName }
+
+// Validate implements Webhook interface
+func (s *CostLabelsWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	valid = valid && (request.Operation == admissionv1.Create)
+	valid = valid && (request.Namespace != "")
+	valid = valid && (request.Kind.Kind == "Pod" || request.Kind.Kind == "Deployment")
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *CostLabelsWebhook) Name() string { return WebhookName }
+
+// FailurePolicy implements Webhook interface
+func (s *CostLabelsWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *CostLabelsWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *CostLabelsWebhook) Rules() []admissionregv1.RuleWithOperations { return rules }
+
+// ExcludedNamespaces implements NamespaceExclusionWebhook interface
+func (s *CostLabelsWebhook) ExcludedNamespaces() utils.NamespaceExclusion {
+	return hookconfig.ExcludedNamespaces
+}
+
+// OptIn implements OptInWebhook interface. Only customers charging usage back
+// label their namespaces, and the others' workloads would be denied, so it's
+// only enforced on the clusters enabling it.
+func (s *CostLabelsWebhook) OptIn() bool { return true }
+
+// ObjectSelector implements Webhook interface
+func (s *CostLabelsWebhook) ObjectSelector() *metav1.LabelSelector { return nil }
+
+// SideEffects implements Webhook interface
+func (s *CostLabelsWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *CostLabelsWebhook) TimeoutSeconds() int32 { return 2 }
+
+// Doc implements Webhook interface
+func (s *CostLabelsWebhook) Doc() string {
+	return fmt.Sprintf(docString, strings.Join(CostAllocationLabels, ", "))
+}
+
+// RuleDocs implements Webhook interface
+func (s *CostLabelsWebhook) RuleDocs() []utils.RuleDoc {
+	labels := strings.Join(CostAllocationLabels, ", ")
+	return []utils.RuleDoc{
+		{
+			Summary:    fmt.Sprintf("On clusters which enable the webhook, Pods and Deployments, and the Deployments' pod templates, are given their namespace's %s labels.", labels),
+			Exceptions: []string{"Workloads in managed namespaces"},
+		},
+		{
+			Summary:    fmt.Sprintf("On clusters which enable the webhook, customers may not create Pods or Deployments in namespaces lacking the %s labels.", labels),
+			Exceptions: []string{utils.AdminsException, "Workloads in managed namespaces"},
+		},
+	}
+}
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+func (s *CostLabelsWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// ClassicEnabled implements Webhook interface
+func (s *CostLabelsWebhook) ClassicEnabled() bool { return true }
+
+// HypershiftEnabled implements Webhook interface
+func (s *CostLabelsWebhook) HypershiftEnabled() bool { return true }
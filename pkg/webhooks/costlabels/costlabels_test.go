@@ -0,0 +1,207 @@
+package costlabels
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	patchengine "github.com/evanphx/json-patch"
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/dependencies"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+)
+
+func newRequest(t *testing.T, username string, groups []string, namespace string, obj runtime.Object) admissionctl.Request {
+	kind := metav1.GroupVersionKind{Version: "v1", Kind: "Pod"}
+	if _, ok := obj.(*appsv1.Deployment); ok {
+		kind = metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	}
+	return testutils.NewRequest(t, kind, admissionv1.Create, authenticationv1.UserInfo{Username: username, Groups: groups}, namespace, "app", obj, nil)
+}
+
+func TestAuthorized(t *testing.T) {
+	namespaces := []*corev1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "payments", Labels: map[string]string{"cost-center": "cc-42", "team": "payments"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "scratch", Labels: map[string]string{"team": "platform"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "unlabelled"}},
+	}
+
+	tests := []struct {
+		name      string
+		username  string
+		groups    []string
+		namespace string
+		object    runtime.Object
+		allowed   bool
+		// labels are the expected labels of the pod, or of the Deployment
+		// and its pod template
+		labels  map[string]string
+		message string
+	}{
+		{
+			name:      "pod without labels",
+			username:  "customer",
+			namespace: "payments",
+			object:    &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "app"}},
+			allowed:   true,
+			labels:    map[string]string{"cost-center": "cc-42", "team": "payments"},
+		},
+		{
+			name:      "pod charged to another team",
+			username:  "customer",
+			namespace: "payments",
+			object:    &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "app", Labels: map[string]string{"app": "api", "team": "billing"}}},
+			allowed:   true,
+			labels:    map[string]string{"app": "api", "cost-center": "cc-42", "team": "payments"},
+		},
+		{
+			name:      "deployment",
+			username:  "customer",
+			namespace: "payments",
+			object: &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "app", Labels: map[string]string{"app": "api"}},
+				Spec: appsv1.DeploymentSpec{
+					Template: corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "api"}}},
+				},
+			},
+			allowed: true,
+			labels:  map[string]string{"app": "api", "cost-center": "cc-42", "team": "payments"},
+		},
+		{
+			name:      "namespace missing a label",
+			username:  "customer",
+			namespace: "scratch",
+			object:    &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "app"}},
+			allowed:   false,
+			message:   "Prevented from creating pod app in namespace scratch, which lacks the cost allocation labels cost-center",
+		},
+		{
+			name:      "namespace without labels",
+			username:  "customer",
+			namespace: "unlabelled",
+			object:    &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "app"}},
+			allowed:   false,
+			message:   "lacks the cost allocation labels cost-center, team",
+		},
+		{
+			name:      "platform pod in namespace missing a label",
+			username:  "system:serviceaccount:openshift-cluster-version:default",
+			groups:    []string{"system:serviceaccounts", "system:serviceaccounts:openshift-cluster-version"},
+			namespace: "scratch",
+			object:    &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "app"}},
+			allowed:   true,
+			labels:    map[string]string{"team": "platform"},
+		},
+		{
+			name:      "sre in namespace without labels",
+			username:  "backplane-cluster-admin",
+			namespace: "unlabelled",
+			object:    &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "app"}},
+			allowed:   true,
+		},
+		{
+			name:      "managed namespace",
+			username:  "customer",
+			namespace: "openshift-monitoring",
+			object:    &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "app"}},
+			allowed:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			builder := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme)
+			for _, ns := range namespaces {
+				builder = builder.WithObjects(ns.DeepCopy())
+			}
+			hook := NewWebhook()
+			deps := dependencies.Bundle{Client: builder.Build()}
+
+			request := newRequest(t, test.username, test.groups, test.namespace, test.object)
+			if !hook.Validate(request) {
+				t.Fatalf("Expected request to be valid")
+			}
+			response := hook.Admit(context.Background(), deps, request)
+			if response.Allowed != test.allowed {
+				t.Fatalf("Expected allowed %v, got %v: %v", test.allowed, response.Allowed, response.Result)
+			}
+			if response.UID != request.UID {
+				t.Fatalf("Expected UID %s, got %s", request.UID, response.UID)
+			}
+			if test.message != "" && !strings.Contains(response.Result.Message, test.message) {
+				t.Errorf("Expected the message to contain %q, got %s", test.message, response.Result.Message)
+			}
+			if test.labels == nil {
+				if len(response.Patches) != 0 {
+					t.Errorf("Expected no patch, got %v", response.Patches)
+				}
+				return
+			}
+
+			patch, err := json.Marshal(response.Patches)
+			if err != nil {
+				t.Fatalf("Expected no error, got %s", err.Error())
+			}
+			decoded, err := patchengine.DecodePatch(patch)
+			if err != nil {
+				t.Fatalf("Expected a valid patch, got %s", err.Error())
+			}
+			patched, err := decoded.Apply(request.Object.Raw)
+			if err != nil {
+				t.Fatalf("Expected the patch to apply, got %s", err.Error())
+			}
+			switch test.object.(type) {
+			case *corev1.Pod:
+				pod := &corev1.Pod{}
+				if err := json.Unmarshal(patched, pod); err != nil {
+					t.Fatalf("Expected no error, got %s", err.Error())
+				}
+				assertLabels(t, test.labels, pod.Labels)
+			case *appsv1.Deployment:
+				deployment := &appsv1.Deployment{}
+				if err := json.Unmarshal(patched, deployment); err != nil {
+					t.Fatalf("Expected no error, got %s", err.Error())
+				}
+				assertLabels(t, test.labels, deployment.Labels)
+				assertLabels(t, test.labels, deployment.Spec.Template.Labels)
+			}
+		})
+	}
+}
+
+func assertLabels(t *testing.T, expected, actual map[string]string) {
+	t.Helper()
+	if len(expected) != len(actual) {
+		t.Fatalf("Expected labels %v, got %v", expected, actual)
+	}
+	for key, value := range expected {
+		if actual[key] != value {
+			t.Fatalf("Expected labels %v, got %v", expected, actual)
+		}
+	}
+}
+
+func TestBuildPatch(t *testing.T) {
+	labels := map[string]string{"cost-center": "cc-42", "team": "payments"}
+	if patch := buildPatch("/metadata/labels", labels, labels); patch != nil {
+		t.Errorf("Expected no patch for a workload with the labels, got %v", patch)
+	}
+	patch := buildPatch("/metadata/labels", nil, labels)
+	if len(patch) != 1 || patch[0].Path != "/metadata/labels" {
+		t.Errorf("Expected the labels map to be added, got %v", patch)
+	}
+	patch = buildPatch("/metadata/labels", map[string]string{"team": "payments"}, labels)
+	if len(patch) != 1 || patch[0].Path != "/metadata/labels/cost-center" {
+		t.Errorf("Expected only the missing label to be added, got %v", patch)
+	}
+}
@@ -1,34 +1,73 @@
 package customresourcedefinitions
 
 import (
+	"context"
 	"fmt"
 	"net/http"
-	"regexp"
 	"slices"
 	"strings"
 
+	hookconfig "github.com/openshift/managed-cluster-validating-webhooks/pkg/config"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
 	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
 
+	admissionv1 "k8s.io/api/admission/v1"
 	admissionregv1 "k8s.io/api/admissionregistration/v1"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
 const (
 	WebhookName string = "customresourcedefinitions-validation"
-	docString   string = `Managed OpenShift Customers may not change CustomResourceDefinitions managed by Red Hat.`
+	docString   string = `Managed OpenShift Customers may not change CustomResourceDefinitions managed by Red Hat, create CustomResourceDefinitions in the API groups reserved for OpenShift (%s and its subgroups), or delete CustomResourceDefinitions with custom resources in platform namespaces.`
+
+	// ReservedGroup is the API group which, with its subgroups such as
+	// managed.openshift.io, is reserved for OpenShift and the managed
+	// components
+	ReservedGroup string = "openshift.io"
+
+	// AggregationLabel is set on the ClusterRoles granting list on the custom
+	// resources whose platform namespaces the webhook checks before their
+	// CustomResourceDefinitions are deleted. They're aggregated into
+	// AggregatedClusterRoleName, bound to the webhook's ServiceAccount.
+	AggregationLabel string = "managed.openshift.io/aggregate-to-validation-webhook"
+	// AggregatedClusterRoleName is the ClusterRole the ClusterRoles labelled
+	// AggregationLabel are aggregated into
+	AggregatedClusterRoleName string = "validation-webhook-custom-resources"
 )
 
 var (
-	timeout                          int32 = 2
-	allowedUsers                           = []string{"system:admin", "backplane-cluster-admin"}
-	sreAdminGroups                         = []string{"system:serviceaccounts:openshift-backplane-srep"}
-	privilegedServiceAccountGroupsRe       = regexp.MustCompile(utils.PrivilegedServiceAccountGroups)
-	scope                                  = admissionregv1.ClusterScope
-	rules                                  = []admissionregv1.RuleWithOperations{
+	// PlatformGroups are the API groups of the CustomResourceDefinitions whose
+	// custom resources OpenShift and the managed components keep in platform
+	// namespaces. The ClusterRole shipped with the webhook and labelled
+	// AggregationLabel lets it list them.
+	PlatformGroups = []string{
+		"cloudcredential.openshift.io",
+		"k8s.cni.cncf.io",
+		"logging.openshift.io",
+		"machine.openshift.io",
+		"managed.openshift.io",
+		"monitoring.coreos.com",
+		"ocmagent.managed.openshift.io",
+		"operator.openshift.io",
+		"operators.coreos.com",
+		"splunkforwarder.managed.openshift.io",
+		"upgrade.managed.openshift.io",
+		"velero.io",
+	}
+
+	timeout      int32 = 2
+	allowedUsers       = []string{"system:admin"}
+	scope              = admissionregv1.ClusterScope
+	rules              = []admissionregv1.RuleWithOperations{
 		{
 			Operations: []admissionregv1.OperationType{
 				admissionregv1.Create,
@@ -48,7 +87,8 @@ var (
 
 // customresourcedefinitionsruleWebhook validates a customresourcedefinition change
 type customresourcedefinitionsruleWebhook struct {
-	s runtime.Scheme
+	s          runtime.Scheme
+	kubeClient client.Client
 }
 
 // NewWebhook creates the new webhook
@@ -59,13 +99,27 @@ func NewWebhook() *customresourcedefinitionsruleWebhook {
 	}
 }
 
+// InjectClient implements ClientWebhook interface
+func (s *customresourcedefinitionsruleWebhook) InjectClient(c client.Client) {
+	s.kubeClient = c
+}
+
+// CachedObjects implements ClientWebhook interface. Custom resources are only
+// listed when their CustomResourceDefinition is deleted, and may be of any
+// kind, so none are cached.
+func (s *customresourcedefinitionsruleWebhook) CachedObjects() []client.Object { return nil }
+
 // Authorized implements Webhook interface
 func (s *customresourcedefinitionsruleWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
-	//Implement authorized next
-	return s.authorized(request)
+	return s.authorized(context.Background(), request)
+}
+
+// AuthorizedContext implements ContextWebhook interface
+func (s *customresourcedefinitionsruleWebhook) AuthorizedContext(ctx context.Context, request admissionctl.Request) admissionctl.Response {
+	return s.authorized(ctx, request)
 }
 
-func (s *customresourcedefinitionsruleWebhook) authorized(request admissionctl.Request) admissionctl.Response {
+func (s *customresourcedefinitionsruleWebhook) authorized(ctx context.Context, request admissionctl.Request) admissionctl.Response {
 	var ret admissionctl.Response
 
 	crd, err := s.renderCustomResourceDefinition(request)
@@ -81,37 +135,103 @@ func (s *customresourcedefinitionsruleWebhook) authorized(request admissionctl.R
 			ret.UID = request.AdmissionRequest.UID
 			return ret
 		}
-		for _, group := range request.UserInfo.Groups {
-			if privilegedServiceAccountGroupsRe.Match([]byte(group)) {
-				ret = admissionctl.Allowed(fmt.Sprintf("Privileged service accounts in group(s) '%s' can operate on CustomResourceDefinitions", strings.Join(request.UserInfo.Groups, ", ")))
-				ret.UID = request.AdmissionRequest.UID
-				return ret
-			}
+		if identity.IsPrivilegedServiceAccount(request.UserInfo) {
+			ret = admissionctl.Allowed(fmt.Sprintf("Privileged service accounts in group(s) '%s' can operate on CustomResourceDefinitions", strings.Join(request.UserInfo.Groups, ", ")))
+			ret.UID = request.AdmissionRequest.UID
+			return ret
 		}
 
-		ret = admissionctl.Denied(fmt.Sprintf("User '%s' prevented from accessing Red Mat managed resources. This is in an effort to prevent harmful actions that may cause unintended consequences or affect the stability of the cluster. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", request.UserInfo.Username))
+		ret = response.Denied(response.ManagedResource, fmt.Sprintf("User '%s' prevented from accessing Red Mat managed resources. This is in an effort to prevent harmful actions that may cause unintended consequences or affect the stability of the cluster. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", request.UserInfo.Username))
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	if isAllowedUser(request) || identity.IsPrivilegedServiceAccount(request.UserInfo) {
+		ret = admissionctl.Allowed("SRE and platform service accounts may create and delete any CustomResourceDefinition")
 		ret.UID = request.AdmissionRequest.UID
 		return ret
 	}
 
+	// The group of a CustomResourceDefinition can't be changed once created
+	if request.Operation == admissionv1.Create && isReservedGroup(crd.Spec.Group) {
+		log.Info("Denying CustomResourceDefinition in a reserved API group", "name", crd.Name, "group", crd.Spec.Group, "user", request.UserInfo.Username)
+		ret = response.Denied(response.ReservedAPIGroup, fmt.Sprintf("Prevented from creating CustomResourceDefinition %s: its API group %s is reserved for OpenShift and Red Hat managed components, like all groups ending in %s. Please use an API group of your own domain. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", crd.Name, crd.Spec.Group, ReservedGroup))
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	if request.Operation == admissionv1.Delete && crd.Spec.Scope == apiextensionsv1.NamespaceScoped {
+		namespace, err := s.platformCustomResource(ctx, crd)
+		if apierrors.IsForbidden(err) {
+			// Custom resources are only checked when a ClusterRole labelled
+			// AggregationLabel lets the webhook list them
+			log.Info("Not permitted to list custom resources, allowing the deletion", "name", crd.Name)
+			ret = admissionctl.Allowed("Not permitted to check whether platform namespaces have custom resources of the CustomResourceDefinition")
+			ret.UID = request.AdmissionRequest.UID
+			return ret
+		}
+		if err != nil {
+			// Not being able to tell is no reason to block deletions
+			log.Error(err, "Failed to list custom resources, allowing the deletion", "name", crd.Name)
+			ret = admissionctl.Allowed("Unable to determine whether platform namespaces have custom resources of the CustomResourceDefinition")
+			ret.UID = request.AdmissionRequest.UID
+			return ret
+		}
+		if namespace != "" {
+			log.Info("Denying deletion of CustomResourceDefinition with custom resources in a platform namespace", "name", crd.Name, "namespace", namespace, "user", request.UserInfo.Username)
+			ret = response.Denied(response.CustomResourceDefinitionInUse, fmt.Sprintf("Prevented from deleting CustomResourceDefinition %s: the platform namespace %s has %s resources, which deleting it would delete too. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", crd.Name, namespace, crd.Spec.Names.Kind))
+			ret.UID = request.AdmissionRequest.UID
+			return ret
+		}
+	}
+
 	ret = admissionctl.Allowed("Non managed CustomResourceDefinition")
 	ret.UID = request.AdmissionRequest.UID
 	return ret
 }
 
-// isAllowedUser checks if the user or group is allowed to perform the action
-func isAllowedUser(request admissionctl.Request) bool {
-	if slices.Contains(allowedUsers, request.UserInfo.Username) {
-		return true
+// isReservedGroup returns true if the API group is reserved for OpenShift
+func isReservedGroup(group string) bool {
+	return group == ReservedGroup || strings.HasSuffix(group, "."+ReservedGroup)
+}
+
+// platformCustomResource returns the first platform namespace holding custom
+// resources of the CustomResourceDefinition, or "" if none do
+func (s *customresourcedefinitionsruleWebhook) platformCustomResource(ctx context.Context, crd *apiextensionsv1.CustomResourceDefinition) (string, error) {
+	var err error
+	if s.kubeClient == nil {
+		s.kubeClient, err = k8sutil.KubeClient(&s.s)
+		if err != nil {
+			return "", err
+		}
 	}
 
-	for _, group := range sreAdminGroups {
-		if slices.Contains(request.UserInfo.Groups, group) {
-			return true
+	version := ""
+	for _, v := range crd.Spec.Versions {
+		if v.Storage || (version == "" && v.Served) {
+			version = v.Name
+		}
+	}
+	if version == "" {
+		return "", nil
+	}
+	// Only metadata is listed, as the resources themselves may be large
+	resources := &metav1.PartialObjectMetadataList{}
+	resources.SetGroupVersionKind(schema.GroupVersionKind{Group: crd.Spec.Group, Version: version, Kind: crd.Spec.Names.Kind + "List"})
+	if err := s.kubeClient.List(ctx, resources); err != nil {
+		return "", err
+	}
+	for _, resource := range resources.Items {
+		if hookconfig.ExcludedNamespaces.Excludes(resource.Namespace) {
+			return resource.Namespace, nil
 		}
 	}
+	return "", nil
+}
 
-	return false
+// isAllowedUser checks if the user or group is allowed to perform the action
+func isAllowedUser(request admissionctl.Request) bool {
+	return identity.IsSRE(request.UserInfo) || slices.Contains(allowedUsers, request.UserInfo.Username)
 }
 
 func (s *customresourcedefinitionsruleWebhook) renderCustomResourceDefinition(req admissionctl.Request) (*apiextensionsv1.CustomResourceDefinition, error) {
@@ -181,7 +301,25 @@ func (s *customresourcedefinitionsruleWebhook) TimeoutSeconds() int32 {
 
 // Doc implements Webhook interface
 func (s *customresourcedefinitionsruleWebhook) Doc() string {
-	return (docString)
+	return fmt.Sprintf(docString, ReservedGroup)
+}
+
+// RuleDocs implements Webhook interface
+func (s *customresourcedefinitionsruleWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers may not change CustomResourceDefinitions managed by Red Hat.",
+			Exceptions: []string{utils.SREException, utils.PrivilegedServiceAccountsException},
+		},
+		{
+			Summary:    fmt.Sprintf("Customers may not create CustomResourceDefinitions in the %s API group or its subgroups, such as managed.%s.", ReservedGroup, ReservedGroup),
+			Exceptions: []string{utils.SREException, utils.PrivilegedServiceAccountsException},
+		},
+		{
+			Summary:    "Customers may not delete CustomResourceDefinitions which have custom resources in platform namespaces.",
+			Exceptions: []string{utils.SREException, utils.PrivilegedServiceAccountsException},
+		},
+	}
 }
 
 // SyncSetLabelSelector returns the label selector to use in the SyncSet.
@@ -1,13 +1,24 @@
 package customresourcedefinitions
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"testing"
 
 	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
 )
@@ -251,3 +262,214 @@ func TestUsers(t *testing.T) {
 	}
 	runCustomResourceDefinitionTests(t, tests)
 }
+
+func TestPlatformLimits(t *testing.T) {
+	widgetGVK := schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}
+	// The fake client only lists the metadata of kinds its scheme knows
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypeWithName(widgetGVK, &unstructured.Unstructured{})
+	scheme.AddKnownTypeWithName(widgetGVK.GroupVersion().WithKind("WidgetList"), &unstructured.UnstructuredList{})
+	widget := func(namespace string) client.Object {
+		w := &unstructured.Unstructured{}
+		w.SetGroupVersionKind(widgetGVK)
+		w.SetNamespace(namespace)
+		w.SetName("widget")
+		return w
+	}
+	crd := func(group string, scope apiextensionsv1.ResourceScope) []byte {
+		raw, err := json.Marshal(apiextensionsv1.CustomResourceDefinition{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "apiextensions.k8s.io/v1", Kind: "CustomResourceDefinition"},
+			ObjectMeta: metav1.ObjectMeta{Name: "widgets." + group},
+			Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+				Group: group,
+				Names: apiextensionsv1.CustomResourceDefinitionNames{Plural: "widgets", Kind: "Widget", ListKind: "WidgetList"},
+				Scope: scope,
+				Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
+					{Name: "v1beta1", Served: true},
+					{Name: "v1", Served: true, Storage: true},
+				},
+			},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error marshalling the CustomResourceDefinition: %v", err)
+		}
+		return raw
+	}
+
+	tests := []struct {
+		name      string
+		operation admissionv1.Operation
+		username  string
+		groups    []string
+		crd       []byte
+		objects   []client.Object
+		// forbidden makes listing the custom resources forbidden, as it is
+		// until a ClusterRole labelled AggregationLabel grants it
+		forbidden bool
+		allowed   bool
+	}{
+		{
+			name:      "create in a reserved group",
+			operation: admissionv1.Create,
+			username:  "customer",
+			crd:       crd("widgets.managed.openshift.io", apiextensionsv1.NamespaceScoped),
+			allowed:   false,
+		},
+		{
+			name:      "create in the reserved group",
+			operation: admissionv1.Create,
+			username:  "customer",
+			crd:       crd("openshift.io", apiextensionsv1.ClusterScoped),
+			allowed:   false,
+		},
+		{
+			name:      "create in a group ending like the reserved group",
+			operation: admissionv1.Create,
+			username:  "customer",
+			crd:       crd("notopenshift.io", apiextensionsv1.NamespaceScoped),
+			allowed:   true,
+		},
+		{
+			name:      "platform service account creates in a reserved group",
+			operation: admissionv1.Create,
+			username:  "system:serviceaccount:openshift-operator-lifecycle-manager:olm-operator-serviceaccount",
+			groups:    []string{"system:serviceaccounts", "system:serviceaccounts:openshift-operator-lifecycle-manager"},
+			crd:       crd("operators.coreos.openshift.io", apiextensionsv1.NamespaceScoped),
+			allowed:   true,
+		},
+		{
+			name:      "delete with resources in a platform namespace",
+			operation: admissionv1.Delete,
+			username:  "customer",
+			crd:       crd("example.com", apiextensionsv1.NamespaceScoped),
+			objects:   []client.Object{widget("payments"), widget("openshift-monitoring")},
+			allowed:   false,
+		},
+		{
+			name:      "delete with resources in customer namespaces",
+			operation: admissionv1.Delete,
+			username:  "customer",
+			crd:       crd("example.com", apiextensionsv1.NamespaceScoped),
+			objects:   []client.Object{widget("payments")},
+			allowed:   true,
+		},
+		{
+			name:      "delete without permission to list the resources",
+			operation: admissionv1.Delete,
+			username:  "customer",
+			crd:       crd("example.com", apiextensionsv1.NamespaceScoped),
+			objects:   []client.Object{widget("openshift-monitoring")},
+			forbidden: true,
+			allowed:   true,
+		},
+		{
+			name:      "sre deletes with resources in a platform namespace",
+			operation: admissionv1.Delete,
+			username:  "backplane-cluster-admin",
+			crd:       crd("example.com", apiextensionsv1.NamespaceScoped),
+			objects:   []client.Object{widget("openshift-monitoring")},
+			allowed:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hook := NewWebhook()
+			builder := fake.NewClientBuilder().WithScheme(scheme).WithObjects(test.objects...)
+			if test.forbidden {
+				builder = builder.WithInterceptorFuncs(interceptor.Funcs{
+					List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+						return apierrors.NewForbidden(schema.GroupResource{Group: "example.com", Resource: "widgets"}, "", fmt.Errorf("not granted"))
+					},
+				})
+			}
+			hook.InjectClient(builder.Build())
+			gvk := metav1.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"}
+			user := authenticationv1.UserInfo{Username: test.username, Groups: test.groups}
+			var request admissionctl.Request
+			if test.operation == admissionv1.Delete {
+				request = testutils.NewRequest(t, gvk, test.operation, user, "", "", nil, test.crd)
+			} else {
+				request = testutils.NewRequest(t, gvk, test.operation, user, "", "", test.crd, nil)
+			}
+			response := hook.Authorized(request)
+			if response.Allowed != test.allowed {
+				t.Fatalf("Expected allowed %v, got %v: %v", test.allowed, response.Allowed, response.Result)
+			}
+			if response.UID != request.UID {
+				t.Errorf("Expected UID %s, got %s", request.UID, response.UID)
+			}
+		})
+	}
+}
+
+// TestShippedRBAC checks the deletion of a platform CustomResourceDefinition is
+// denied when listing custom resources is only granted for PlatformGroups, as
+// by the ClusterRole shipped with the webhook
+func TestShippedRBAC(t *testing.T) {
+	backupGVK := schema.GroupVersionKind{Group: "velero.io", Version: "v1", Kind: "Backup"}
+	widgetGVK := schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}
+	scheme := runtime.NewScheme()
+	objects := []client.Object{}
+	for _, gvk := range []schema.GroupVersionKind{backupGVK, widgetGVK} {
+		scheme.AddKnownTypeWithName(gvk, &unstructured.Unstructured{})
+		scheme.AddKnownTypeWithName(gvk.GroupVersion().WithKind(gvk.Kind+"List"), &unstructured.UnstructuredList{})
+		resource := &unstructured.Unstructured{}
+		resource.SetGroupVersionKind(gvk)
+		resource.SetNamespace("openshift-velero")
+		resource.SetName("daily")
+		objects = append(objects, resource)
+	}
+	// Only the groups the shipped ClusterRole grants may be listed
+	rbac := interceptor.Funcs{
+		List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+			group := list.GetObjectKind().GroupVersionKind().Group
+			if !slices.Contains(PlatformGroups, group) {
+				return apierrors.NewForbidden(schema.GroupResource{Group: group}, "", fmt.Errorf("not granted"))
+			}
+			return c.List(ctx, list, opts...)
+		},
+	}
+	crd := func(gvk schema.GroupVersionKind, plural string) *apiextensionsv1.CustomResourceDefinition {
+		return &apiextensionsv1.CustomResourceDefinition{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "apiextensions.k8s.io/v1", Kind: "CustomResourceDefinition"},
+			ObjectMeta: metav1.ObjectMeta{Name: plural + "." + gvk.Group},
+			Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+				Group:    gvk.Group,
+				Names:    apiextensionsv1.CustomResourceDefinitionNames{Plural: plural, Kind: gvk.Kind, ListKind: gvk.Kind + "List"},
+				Scope:    apiextensionsv1.NamespaceScoped,
+				Versions: []apiextensionsv1.CustomResourceDefinitionVersion{{Name: gvk.Version, Served: true, Storage: true}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		crd     *apiextensionsv1.CustomResourceDefinition
+		allowed bool
+	}{
+		{
+			name:    "platform group",
+			crd:     crd(backupGVK, "backups"),
+			allowed: false,
+		},
+		{
+			name:    "group without a grant",
+			crd:     crd(widgetGVK, "widgets"),
+			allowed: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hook := NewWebhook()
+			hook.InjectClient(fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).WithInterceptorFuncs(rbac).Build())
+			gvk := metav1.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"}
+			request := testutils.NewRequest(t, gvk, admissionv1.Delete, authenticationv1.UserInfo{Username: "customer"}, "", test.crd.Name, nil, test.crd)
+			response := hook.Authorized(request)
+			if response.Allowed != test.allowed {
+				t.Fatalf("Expected allowed %v, got %v: %v", test.allowed, response.Allowed, response.Result)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package debugnamespace
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "debugnamespace-validation"
+	docString   string = `Managed OpenShift customers may not delete must-gather (%s) or debug (%s) namespaces while a must-gather or debug pod is still running in them, as this interrupts in-flight diagnostics.`
+
+	mustGatherNamespace string = `^openshift-must-gather-.*`
+	debugNamespace      string = `^openshift-debug-.*`
+)
+
+var (
+	diagnosticNamespaceRe = regexp.MustCompile(mustGatherNamespace + "|" + debugNamespace)
+
+	scope = admissionregv1.ClusterScope
+	rules = []admissionregv1.RuleWithOperations{
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Delete},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{""},
+				APIVersions: []string{"*"},
+				Resources:   []string{"namespaces"},
+				Scope:       &scope,
+			},
+		},
+	}
+	log = logf.Log.WithName(WebhookName)
+)
+
+// DebugNamespaceWebhook prevents must-gather and debug namespaces from being
+// deleted while diagnostics are still running in them
+type DebugNamespaceWebhook struct {
+	s          *runtime.Scheme
+	kubeClient client.Client
+}
+
+// InjectClient implements ClientWebhook interface
+func (s *DebugNamespaceWebhook) InjectClient(c client.Client) {
+	s.kubeClient = c
+}
+
+// CachedObjects implements ClientWebhook interface. Pods are only listed when
+// a debug namespace is deleted, which doesn't justify caching every pod in the
+// cluster.
+func (s *DebugNamespaceWebhook) CachedObjects() []client.Object { return nil }
+
+// ObjectSelector implements Webhook interface
+func (s *DebugNamespaceWebhook) ObjectSelector() *metav1.LabelSelector { return nil }
+
+// Doc implements Webhook interface
+func (s *DebugNamespaceWebhook) Doc() string {
+	return fmt.Sprintf(docString, mustGatherNamespace, debugNamespace)
+}
+
+// RuleDocs implements Webhook interface
+func (s *DebugNamespaceWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers may not delete must-gather or debug namespaces while a must-gather or debug pod is still running in them.",
+			Exceptions: []string{utils.AdminsException, "Namespaces whose pods can't be listed"},
+		},
+	}
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *DebugNamespaceWebhook) TimeoutSeconds() int32 { return 2 }
+
+// MatchPolicy implements Webhook interface
+func (s *DebugNamespaceWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Name implements Webhook interface
+func (s *DebugNamespaceWebhook) Name() string { return WebhookName }
+
+// FailurePolicy implements Webhook interface
+func (s *DebugNamespaceWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// Rules implements Webhook interface
+func (s *DebugNamespaceWebhook) Rules() []admissionregv1.RuleWithOperations { return rules }
+
+// GetURI implements Webhook interface
+func (s *DebugNamespaceWebhook) GetURI() string { return "/" + WebhookName }
+
+// SideEffects implements Webhook interface
+func (s *DebugNamespaceWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// Validate - Make sure we're working with a well-formed Admission Request object
+func (s *DebugNamespaceWebhook) Validate(req admissionctl.Request) bool {
+	valid := true
+	valid = valid && (req.UserInfo.Username != "")
+	valid = valid && (req.Kind.Kind == "Namespace")
+
+	return valid
+}
+
+// Authorized implements Webhook interface
+func (s *DebugNamespaceWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.authorized(context.Background(), request)
+}
+
+// AuthorizedContext implements ContextWebhook interface
+func (s *DebugNamespaceWebhook) AuthorizedContext(ctx context.Context, request admissionctl.Request) admissionctl.Response {
+	return s.authorized(ctx, request)
+}
+
+func (s *DebugNamespaceWebhook) authorized(ctx context.Context, request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if request.Operation != admissionv1.Delete || !diagnosticNamespaceRe.MatchString(request.Name) {
+		ret = admissionctl.Allowed("Only deletion of must-gather and debug namespaces is restricted")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	if isAdmin(request) {
+		ret = admissionctl.Allowed("Cluster and SRE admins may delete must-gather and debug namespaces")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	running, err := s.runningPods(ctx, request.Name)
+	if err != nil {
+		// Not being able to tell is no reason to block cleanup
+		log.Error(err, "Failed to list pods, allowing namespace deletion", "namespace", request.Name)
+		ret = admissionctl.Allowed("Unable to determine whether diagnostics are running")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	if len(running) > 0 {
+		log.Info("Denying deletion of namespace with running diagnostics", "namespace", request.Name, "pods", running, "user", request.UserInfo.Username)
+		ret = response.Denied(response.NamespaceDeletionBlocked, fmt.Sprintf("Prevented from deleting namespace %s while must-gather or debug pods %v are still running. Wait for them to complete before deleting the namespace. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", request.Name, running))
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	ret = admissionctl.Allowed("No diagnostics are running in the namespace")
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// runningPods returns the names of the pods in the namespace which are still
+// running (or about to) and are not already being deleted
+func (s *DebugNamespaceWebhook) runningPods(ctx context.Context, namespace string) ([]string, error) {
+	var err error
+	if s.kubeClient == nil {
+		s.kubeClient, err = k8sutil.KubeClient(s.s)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	pods := &corev1.PodList{}
+	if err = s.kubeClient.List(ctx, pods, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+
+	running := []string{}
+	for _, pod := range pods.Items {
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		if pod.Status.Phase == corev1.PodRunning || pod.Status.Phase == corev1.PodPending {
+			running = append(running, pod.Name)
+		}
+	}
+	return running, nil
+}
+
+func isAdmin(request admissionctl.Request) bool {
+	return identity.IsAdmin(request.UserInfo)
+}
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+func (s *DebugNamespaceWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+func (s *DebugNamespaceWebhook) ClassicEnabled() bool { return true }
+
+func (s *DebugNamespaceWebhook) HypershiftEnabled() bool { return true }
+
+// NewWebhook creates a new webhook
+func NewWebhook() *DebugNamespaceWebhook {
+	scheme := runtime.NewScheme()
+	err := admissionv1.AddToScheme(scheme)
+	if err != nil {
+		log.Error(err, "Fail adding admissionv1 scheme to DebugNamespaceWebhook")
+		os.Exit(1)
+	}
+	err = corev1.AddToScheme(scheme)
+	if err != nil {
+		log.Error(err, "Fail adding corev1 scheme to DebugNamespaceWebhook")
+		os.Exit(1)
+	}
+
+	return &DebugNamespaceWebhook{
+		s: scheme,
+	}
+}
@@ -0,0 +1,149 @@
+package debugnamespace
+
+import (
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func newPod(namespace, name string, phase corev1.PodPhase, deleting bool) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Status: corev1.PodStatus{
+			Phase: phase,
+		},
+	}
+	if deleting {
+		now := metav1.Now()
+		pod.DeletionTimestamp = &now
+		pod.Finalizers = []string{"test"}
+	}
+	return pod
+}
+
+func TestAuthorized(t *testing.T) {
+	tests := []struct {
+		name            string
+		username        string
+		groups          []string
+		namespace       string
+		operation       admissionv1.Operation
+		pods            []client.Object
+		shouldBeAllowed bool
+	}{
+		{
+			name:            "Customer can delete must-gather namespace without running pods",
+			username:        "customer",
+			namespace:       "openshift-must-gather-abcde",
+			operation:       admissionv1.Delete,
+			pods:            []client.Object{newPod("openshift-must-gather-abcde", "must-gather-xyz", corev1.PodSucceeded, false)},
+			shouldBeAllowed: true,
+		},
+		{
+			name:            "Customer cannot delete must-gather namespace with a running pod",
+			username:        "customer",
+			namespace:       "openshift-must-gather-abcde",
+			operation:       admissionv1.Delete,
+			pods:            []client.Object{newPod("openshift-must-gather-abcde", "must-gather-xyz", corev1.PodRunning, false)},
+			shouldBeAllowed: false,
+		},
+		{
+			name:            "Customer cannot delete debug namespace with a pending pod",
+			username:        "customer",
+			namespace:       "openshift-debug-fghij",
+			operation:       admissionv1.Delete,
+			pods:            []client.Object{newPod("openshift-debug-fghij", "node-debug", corev1.PodPending, false)},
+			shouldBeAllowed: false,
+		},
+		{
+			name:            "Customer can delete debug namespace whose pod is terminating",
+			username:        "customer",
+			namespace:       "openshift-debug-fghij",
+			operation:       admissionv1.Delete,
+			pods:            []client.Object{newPod("openshift-debug-fghij", "node-debug", corev1.PodRunning, true)},
+			shouldBeAllowed: true,
+		},
+		{
+			name:            "Pods in other namespaces are ignored",
+			username:        "customer",
+			namespace:       "openshift-debug-fghij",
+			operation:       admissionv1.Delete,
+			pods:            []client.Object{newPod("openshift-debug-klmno", "node-debug", corev1.PodRunning, false)},
+			shouldBeAllowed: true,
+		},
+		{
+			name:            "SRE can delete must-gather namespace with a running pod",
+			username:        "sre",
+			groups:          []string{"system:serviceaccounts:openshift-backplane-srep"},
+			namespace:       "openshift-must-gather-abcde",
+			operation:       admissionv1.Delete,
+			pods:            []client.Object{newPod("openshift-must-gather-abcde", "must-gather-xyz", corev1.PodRunning, false)},
+			shouldBeAllowed: true,
+		},
+		{
+			name:            "Privileged service account can delete debug namespace with a running pod",
+			username:        "system:serviceaccount:openshift-must-gather-operator:must-gather-operator",
+			groups:          []string{"system:serviceaccounts:openshift-must-gather-operator"},
+			namespace:       "openshift-debug-fghij",
+			operation:       admissionv1.Delete,
+			pods:            []client.Object{newPod("openshift-debug-fghij", "node-debug", corev1.PodRunning, false)},
+			shouldBeAllowed: true,
+		},
+		{
+			name:            "Customer can delete other namespaces",
+			username:        "customer",
+			namespace:       "my-app",
+			operation:       admissionv1.Delete,
+			pods:            []client.Object{newPod("my-app", "app", corev1.PodRunning, false)},
+			shouldBeAllowed: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hook := NewWebhook()
+			hook.kubeClient = fake.NewClientBuilder().WithScheme(hook.s).WithObjects(test.pods...).Build()
+			request := admissionctl.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					UID:       "test-uid",
+					Name:      test.namespace,
+					Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Namespace"},
+					Operation: test.operation,
+					UserInfo: authenticationv1.UserInfo{
+						Username: test.username,
+						Groups:   test.groups,
+					},
+				},
+			}
+
+			response := hook.Authorized(request)
+			if response.Allowed != test.shouldBeAllowed {
+				t.Errorf("expected allowed to be %t, got %t: %s", test.shouldBeAllowed, response.Allowed, response.Result.Message)
+			}
+			if response.UID != request.UID {
+				t.Errorf("expected response UID %s, got %s", request.UID, response.UID)
+			}
+		})
+	}
+}
+
+func TestName(t *testing.T) {
+	if NewWebhook().Name() == "" {
+		t.Fatalf("Empty hook name")
+	}
+}
+
+func TestGetURI(t *testing.T) {
+	if NewWebhook().GetURI()[0] != '/' {
+		t.Fatalf("Hook URI does not begin with a /")
+	}
+}
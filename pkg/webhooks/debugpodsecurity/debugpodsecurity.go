@@ -0,0 +1,220 @@
+package debugpodsecurity
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	"gomodules.xyz/jsonpatch/v2"
+
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "debugpodsecurity-mutation"
+	docString   string = `Pods created in openshift-debug-* namespaces on Managed OpenShift clusters are given a baseline security context, the RuntimeDefault seccomp profile and no capabilities, unless they set their own, so SRE's debug tooling complies with pod security admission.`
+)
+
+var (
+	timeout int32 = 2
+	scope         = admissionregv1.NamespacedScope
+	rules         = []admissionregv1.RuleWithOperations{
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Create},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{""},
+				APIVersions: []string{"v1"},
+				Resources:   []string{"pods"},
+				Scope:       &scope,
+			},
+		},
+	}
+	log = logf.Log.WithName(WebhookName)
+
+	debugNamespaceRe = regexp.MustCompile(`^openshift-debug-.*`)
+
+	defaultSeccompProfile = corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault}
+	dropAllCapabilities   = corev1.Capabilities{Drop: []corev1.Capability{"ALL"}}
+)
+
+// DebugPodSecurityWebhook sets a baseline security context on debug pods
+type DebugPodSecurityWebhook struct {
+	s       *runtime.Scheme
+	decoder admissionctl.Decoder
+}
+
+// NewWebhook creates the new webhook
+func NewWebhook() *DebugPodSecurityWebhook {
+	scheme, err := k8sutil.SharedScheme()
+	if err != nil {
+		log.Error(err, "Fail getting the shared scheme for DebugPodSecurityWebhook")
+		os.Exit(1)
+	}
+	decoder, err := k8sutil.SharedDecoder()
+	if err != nil {
+		log.Error(err, "Fail getting the shared decoder for DebugPodSecurityWebhook")
+		os.Exit(1)
+	}
+
+	return &DebugPodSecurityWebhook{
+		s:       scheme,
+		decoder: decoder,
+	}
+}
+
+// Authorized implements Webhook interface
+func (s *DebugPodSecurityWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.authorizeOrMutate(request)
+}
+
+func (s *DebugPodSecurityWebhook) authorizeOrMutate(request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if !debugNamespaceRe.MatchString(request.Namespace) {
+		ret = admissionctl.Allowed("Only pods in debug namespaces are given a baseline security context")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	pod, err := s.renderPod(request)
+	if err != nil {
+		log.Error(err, "Couldn't render a Pod from the incoming request")
+		ret = admissionctl.Errored(http.StatusBadRequest, err)
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	patches := buildPatch(pod)
+	if len(patches) == 0 {
+		ret = admissionctl.Allowed("Debug pod already sets its security context")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	log.Info("Setting baseline security context on debug pod", "namespace", request.Namespace, "name", pod.GetName(), "user", request.UserInfo.Username)
+	ret = admissionctl.Patched(fmt.Sprintf("Set baseline security context on debug pod '%s'", pod.GetName()), patches...)
+	// ret.Complete() sets the UID and finalizes the patch
+	if err := ret.Complete(request); err != nil {
+		log.Error(err, "Failed to complete the request")
+		ret = admissionctl.Errored(http.StatusInternalServerError, err)
+		ret.UID = request.AdmissionRequest.UID
+	}
+	return ret
+}
+
+// buildPatch returns the JSONPatch operations setting the RuntimeDefault
+// seccomp profile on the pod and dropping all capabilities of its containers.
+// Whatever the pod or a container already sets is kept: a seccomp profile,
+// capabilities to add or drop, or running privileged.
+func buildPatch(pod *corev1.Pod) []jsonpatch.JsonPatchOperation {
+	patches := []jsonpatch.JsonPatchOperation{}
+	switch {
+	case pod.Spec.SecurityContext == nil:
+		patches = append(patches, jsonpatch.NewOperation("add", "/spec/securityContext", corev1.PodSecurityContext{SeccompProfile: &defaultSeccompProfile}))
+	case pod.Spec.SecurityContext.SeccompProfile == nil:
+		patches = append(patches, jsonpatch.NewOperation("add", "/spec/securityContext/seccompProfile", defaultSeccompProfile))
+	}
+	patches = append(patches, capabilitiesPatch("/spec/initContainers", pod.Spec.InitContainers)...)
+	patches = append(patches, capabilitiesPatch("/spec/containers", pod.Spec.Containers)...)
+	return patches
+}
+
+// capabilitiesPatch returns the JSONPatch operations dropping all
+// capabilities of the containers at path which don't set their own and
+// aren't privileged
+func capabilitiesPatch(path string, containers []corev1.Container) []jsonpatch.JsonPatchOperation {
+	patches := []jsonpatch.JsonPatchOperation{}
+	for i, c := range containers {
+		containerPath := fmt.Sprintf("%s/%d/securityContext", path, i)
+		switch {
+		case c.SecurityContext == nil:
+			patches = append(patches, jsonpatch.NewOperation("add", containerPath, corev1.SecurityContext{Capabilities: &dropAllCapabilities}))
+		case c.SecurityContext.Privileged != nil && *c.SecurityContext.Privileged:
+			continue
+		case c.SecurityContext.Capabilities == nil:
+			patches = append(patches, jsonpatch.NewOperation("add", containerPath+"/capabilities", dropAllCapabilities))
+		}
+	}
+	return patches
+}
+
+// renderPod renders the Pod in the admission Request
+func (s *DebugPodSecurityWebhook) renderPod(request admissionctl.Request) (*corev1.Pod, error) {
+	pod := &corev1.Pod{}
+	err := s.decoder.Decode(request, pod)
+	if err != nil {
+		return nil, err
+	}
+	return pod, nil
+}
+
+// GetURI implements Webhook interface
+func (s *DebugPodSecurityWebhook) GetURI() string { return "/" + WebhookName }
+
+// Validate implements Webhook interface
+func (s *DebugPodSecurityWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	valid = valid && (request.Kind.Kind == "Pod")
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *DebugPodSecurityWebhook) Name() string { return WebhookName }
+
+// FailurePolicy implements Webhook interface
+func (s *DebugPodSecurityWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *DebugPodSecurityWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *DebugPodSecurityWebhook) Rules() []admissionregv1.RuleWithOperations { return rules }
+
+// ObjectSelector implements Webhook interface
+func (s *DebugPodSecurityWebhook) ObjectSelector() *metav1.LabelSelector { return nil }
+
+// SideEffects implements Webhook interface
+func (s *DebugPodSecurityWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *DebugPodSecurityWebhook) TimeoutSeconds() int32 { return timeout }
+
+// Doc implements Webhook interface
+func (s *DebugPodSecurityWebhook) Doc() string { return docString }
+
+// RuleDocs implements Webhook interface
+func (s *DebugPodSecurityWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Pods created in openshift-debug-* namespaces are given the RuntimeDefault seccomp profile, and their containers drop all capabilities.",
+			Exceptions: []string{"Pods which set their own seccomp profile", "Containers which set their own capabilities or run privileged"},
+		},
+	}
+}
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+func (s *DebugPodSecurityWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// ClassicEnabled implements Webhook interface
+func (s *DebugPodSecurityWebhook) ClassicEnabled() bool { return true }
+
+// HypershiftEnabled implements Webhook interface
+func (s *DebugPodSecurityWebhook) HypershiftEnabled() bool { return true }
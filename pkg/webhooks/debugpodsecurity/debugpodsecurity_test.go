@@ -0,0 +1,125 @@
+package debugpodsecurity
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	patchengine "github.com/evanphx/json-patch"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+)
+
+func createPodRaw(t *testing.T, namespace string, podSecurity *corev1.PodSecurityContext, containerSecurity ...*corev1.SecurityContext) []byte {
+	pod := corev1.Pod{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{Name: "node-debug", Namespace: namespace},
+		Spec:       corev1.PodSpec{SecurityContext: podSecurity},
+	}
+	for _, sc := range containerSecurity {
+		pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: "container-00", Image: "registry.redhat.io/rhel9/support-tools", SecurityContext: sc})
+	}
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	return raw
+}
+
+func TestDebugPodSecurity(t *testing.T) {
+	unconfined := &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeUnconfined}
+	netAdmin := &corev1.Capabilities{Add: []corev1.Capability{"NET_ADMIN"}}
+	privileged := &corev1.SecurityContext{Privileged: ptr.To(true)}
+
+	tests := []struct {
+		name               string
+		namespace          string
+		podSecurity        *corev1.PodSecurityContext
+		containerSecurity  []*corev1.SecurityContext
+		expectedSeccomp    *corev1.SeccompProfile
+		expectedContainers []*corev1.SecurityContext
+	}{
+		{
+			name:               "debug pod without a security context",
+			namespace:          "openshift-debug-abcde",
+			containerSecurity:  []*corev1.SecurityContext{nil},
+			expectedSeccomp:    &defaultSeccompProfile,
+			expectedContainers: []*corev1.SecurityContext{{Capabilities: &dropAllCapabilities}},
+		},
+		{
+			name:               "debug pod with an empty security context",
+			namespace:          "openshift-debug-abcde",
+			podSecurity:        &corev1.PodSecurityContext{},
+			containerSecurity:  []*corev1.SecurityContext{{RunAsUser: ptr.To(int64(1000))}},
+			expectedSeccomp:    &defaultSeccompProfile,
+			expectedContainers: []*corev1.SecurityContext{{RunAsUser: ptr.To(int64(1000)), Capabilities: &dropAllCapabilities}},
+		},
+		{
+			name:               "debug pod setting its own security context",
+			namespace:          "openshift-debug-abcde",
+			podSecurity:        &corev1.PodSecurityContext{SeccompProfile: unconfined},
+			containerSecurity:  []*corev1.SecurityContext{{Capabilities: netAdmin}, privileged},
+			expectedSeccomp:    unconfined,
+			expectedContainers: []*corev1.SecurityContext{{Capabilities: netAdmin}, privileged},
+		},
+		{
+			name:               "pod outside debug namespaces",
+			namespace:          "my-project",
+			containerSecurity:  []*corev1.SecurityContext{nil},
+			expectedContainers: []*corev1.SecurityContext{nil},
+		},
+	}
+
+	gvk := metav1.GroupVersionKind{Version: "v1", Kind: "Pod"}
+	gvr := metav1.GroupVersionResource{Version: "v1", Resource: "pods"}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			raw := createPodRaw(t, test.namespace, test.podSecurity, test.containerSecurity...)
+			hook := NewWebhook()
+			httprequest, err := testutils.CreateHTTPRequest(hook.GetURI(), "test-uid", gvk, gvr, admissionv1.Create,
+				"system:serviceaccount:openshift-backplane-srep:srep-user", []string{"system:authenticated"}, test.namespace, &runtime.RawExtension{Raw: raw}, nil)
+			if err != nil {
+				t.Fatalf("Expected no error, got %s", err.Error())
+			}
+			response, err := testutils.SendHTTPRequest(httprequest, hook)
+			if err != nil {
+				t.Fatalf("Expected no error, got %s", err.Error())
+			}
+			if !response.Allowed {
+				t.Fatalf("Expected pod to be allowed, got %v", response.Result)
+			}
+
+			mutated := raw
+			if len(response.Patch) > 0 {
+				patch, err := patchengine.DecodePatch(response.Patch)
+				if err != nil {
+					t.Fatalf("Expected no error decoding the patch, got %s", err.Error())
+				}
+				if mutated, err = patch.Apply(raw); err != nil {
+					t.Fatalf("Expected no error applying the patch, got %s", err.Error())
+				}
+			}
+			pod := corev1.Pod{}
+			if err := json.Unmarshal(mutated, &pod); err != nil {
+				t.Fatalf("Expected no error, got %s", err.Error())
+			}
+			var seccomp *corev1.SeccompProfile
+			if pod.Spec.SecurityContext != nil {
+				seccomp = pod.Spec.SecurityContext.SeccompProfile
+			}
+			if !reflect.DeepEqual(seccomp, test.expectedSeccomp) {
+				t.Errorf("Expected seccomp profile %v, got %v", test.expectedSeccomp, seccomp)
+			}
+			for i, c := range pod.Spec.Containers {
+				if !reflect.DeepEqual(c.SecurityContext, test.expectedContainers[i]) {
+					t.Errorf("Expected container %d security context %v, got %v", i, test.expectedContainers[i], c.SecurityContext)
+				}
+			}
+		})
+	}
+}
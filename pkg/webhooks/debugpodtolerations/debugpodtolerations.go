@@ -0,0 +1,254 @@
+package debugpodtolerations
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	"gomodules.xyz/jsonpatch/v2"
+
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "debugpodtolerations-mutation"
+	docString   string = `Pods created in openshift-debug-* namespaces on Managed OpenShift clusters are given tolerations for not-ready, cordoned and infra nodes so SRE can debug any node.`
+)
+
+var (
+	timeout int32 = 2
+	scope         = admissionregv1.NamespacedScope
+	rules         = []admissionregv1.RuleWithOperations{
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Create},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{""},
+				APIVersions: []string{"v1"},
+				Resources:   []string{"pods"},
+				Scope:       &scope,
+			},
+		},
+	}
+	log = logf.Log.WithName(WebhookName)
+
+	debugNamespaceRe = regexp.MustCompile(`^openshift-debug-.*`)
+
+	// requiredTolerations are the tolerations debug pods need to be scheduled
+	// onto, and stay on, nodes which are unhealthy, cordoned or infra nodes
+	requiredTolerations = []corev1.Toleration{
+		{
+			Key:      corev1.TaintNodeNotReady,
+			Operator: corev1.TolerationOpExists,
+		},
+		{
+			Key:      corev1.TaintNodeUnschedulable,
+			Operator: corev1.TolerationOpExists,
+			Effect:   corev1.TaintEffectNoSchedule,
+		},
+		{
+			Key:      "node-role.kubernetes.io/infra",
+			Operator: corev1.TolerationOpExists,
+		},
+	}
+)
+
+// DebugPodTolerationsWebhook adds tolerations to debug pods
+type DebugPodTolerationsWebhook struct {
+	s       *runtime.Scheme
+	decoder admissionctl.Decoder
+}
+
+// NewWebhook creates the new webhook
+func NewWebhook() *DebugPodTolerationsWebhook {
+	scheme, err := k8sutil.SharedScheme()
+	if err != nil {
+		log.Error(err, "Fail getting the shared scheme for DebugPodTolerationsWebhook")
+		os.Exit(1)
+	}
+	decoder, err := k8sutil.SharedDecoder()
+	if err != nil {
+		log.Error(err, "Fail getting the shared decoder for DebugPodTolerationsWebhook")
+		os.Exit(1)
+	}
+
+	return &DebugPodTolerationsWebhook{
+		s:       scheme,
+		decoder: decoder,
+	}
+}
+
+// Authorized implements Webhook interface
+func (s *DebugPodTolerationsWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.authorizeOrMutate(request)
+}
+
+func (s *DebugPodTolerationsWebhook) authorizeOrMutate(request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if !debugNamespaceRe.MatchString(request.Namespace) {
+		ret = admissionctl.Allowed("Only pods in debug namespaces are given tolerations")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	pod, err := s.renderPod(request)
+	if err != nil {
+		log.Error(err, "Couldn't render a Pod from the incoming request")
+		ret = admissionctl.Errored(http.StatusBadRequest, err)
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	patches := buildPatch(pod.Spec.Tolerations)
+	if len(patches) == 0 {
+		ret = admissionctl.Allowed("Debug pod already has the required tolerations")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	log.Info("Adding tolerations to debug pod", "namespace", request.Namespace, "name", pod.GetName(), "user", request.UserInfo.Username)
+	ret = admissionctl.Patched(fmt.Sprintf("Added tolerations to debug pod '%s'", pod.GetName()), patches...)
+	// ret.Complete() sets the UID and finalizes the patch
+	if err := ret.Complete(request); err != nil {
+		log.Error(err, "Failed to complete the request")
+		ret = admissionctl.Errored(http.StatusInternalServerError, err)
+		ret.UID = request.AdmissionRequest.UID
+	}
+	return ret
+}
+
+// missingTolerations returns the requiredTolerations which aren't covered by
+// the pod's existing tolerations
+func missingTolerations(existing []corev1.Toleration) []corev1.Toleration {
+	missing := []corev1.Toleration{}
+	for _, required := range requiredTolerations {
+		covered := false
+		for _, toleration := range existing {
+			// a toleration with an empty key and Exists tolerates everything
+			if toleration.Key == "" && toleration.Operator == corev1.TolerationOpExists {
+				covered = true
+				break
+			}
+			if toleration.Key == required.Key && (toleration.Effect == "" || toleration.Effect == required.Effect) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			missing = append(missing, required)
+		}
+	}
+	return missing
+}
+
+// buildPatch returns the JSONPatch operations appending the missing
+// tolerations to the pod, creating the list if the pod has none
+func buildPatch(existing []corev1.Toleration) []jsonpatch.JsonPatchOperation {
+	missing := missingTolerations(existing)
+	if len(missing) == 0 {
+		return nil
+	}
+	if len(existing) == 0 {
+		return []jsonpatch.JsonPatchOperation{jsonpatch.NewOperation("add", "/spec/tolerations", missing)}
+	}
+	patches := make([]jsonpatch.JsonPatchOperation, 0, len(missing))
+	for _, toleration := range missing {
+		patches = append(patches, jsonpatch.NewOperation("add", "/spec/tolerations/-", toleration))
+	}
+	return patches
+}
+
+// renderPod renders the Pod in the admission Request
+func (s *DebugPodTolerationsWebhook) renderPod(request admissionctl.Request) (*corev1.Pod, error) {
+	pod := &corev1.Pod{}
+	err := s.decoder.Decode(request, pod)
+	if err != nil {
+		return nil, err
+	}
+	return pod, nil
+}
+
+// GetURI implements Webhook interface
+func (s *DebugPodTolerationsWebhook) GetURI() string {
+	return "/" + WebhookName
+}
+
+// Validate implements Webhook interface
+func (s *DebugPodTolerationsWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	valid = valid && (request.Kind.Kind == "Pod")
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *DebugPodTolerationsWebhook) Name() string {
+	return WebhookName
+}
+
+// FailurePolicy implements Webhook interface
+func (s *DebugPodTolerationsWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *DebugPodTolerationsWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *DebugPodTolerationsWebhook) Rules() []admissionregv1.RuleWithOperations {
+	return rules
+}
+
+// ObjectSelector implements Webhook interface
+func (s *DebugPodTolerationsWebhook) ObjectSelector() *metav1.LabelSelector {
+	return nil
+}
+
+// SideEffects implements Webhook interface
+func (s *DebugPodTolerationsWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *DebugPodTolerationsWebhook) TimeoutSeconds() int32 {
+	return timeout
+}
+
+// Doc implements Webhook interface
+func (s *DebugPodTolerationsWebhook) Doc() string {
+	return docString
+}
+
+// RuleDocs implements Webhook interface
+func (s *DebugPodTolerationsWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Pods created in openshift-debug-* namespaces are given tolerations for not-ready, cordoned and infra nodes.",
+			Exceptions: []string{"Pods which already tolerate those taints"},
+		},
+	}
+}
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+// Return utils.DefaultLabelSelector() to stick with the default
+func (s *DebugPodTolerationsWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// ClassicEnabled indicates that this webhook is compatible with classic clusters
+func (s *DebugPodTolerationsWebhook) ClassicEnabled() bool { return true }
+
+// HypershiftEnabled indicates that this webhook is compatible with hosted
+// control plane clusters
+func (s *DebugPodTolerationsWebhook) HypershiftEnabled() bool { return true }
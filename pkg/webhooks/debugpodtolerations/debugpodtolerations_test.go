@@ -0,0 +1,112 @@
+package debugpodtolerations
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	patchengine "github.com/evanphx/json-patch"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+)
+
+var tolerateEverything = corev1.Toleration{Operator: corev1.TolerationOpExists}
+
+func createPodRaw(t *testing.T, namespace string, tolerations []corev1.Toleration) []byte {
+	pod := corev1.Pod{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{Name: "node-debug", Namespace: namespace},
+		Spec: corev1.PodSpec{
+			Containers:  []corev1.Container{{Name: "container-00", Image: "registry.redhat.io/rhel9/support-tools"}},
+			Tolerations: tolerations,
+		},
+	}
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	return raw
+}
+
+func TestDebugPodTolerations(t *testing.T) {
+	customToleration := corev1.Toleration{Key: "example.com/dedicated", Operator: corev1.TolerationOpEqual, Value: "debug", Effect: corev1.TaintEffectNoSchedule}
+
+	tests := []struct {
+		name        string
+		namespace   string
+		tolerations []corev1.Toleration
+		expected    []corev1.Toleration
+	}{
+		{
+			name:      "debug pod without tolerations",
+			namespace: "openshift-debug-abcde",
+			expected:  requiredTolerations,
+		},
+		{
+			name:        "debug pod with other tolerations",
+			namespace:   "openshift-debug-abcde",
+			tolerations: []corev1.Toleration{customToleration},
+			expected:    append([]corev1.Toleration{customToleration}, requiredTolerations...),
+		},
+		{
+			name:        "debug pod with some required tolerations",
+			namespace:   "openshift-debug-abcde",
+			tolerations: []corev1.Toleration{requiredTolerations[0]},
+			expected:    requiredTolerations,
+		},
+		{
+			name:        "debug pod tolerating everything",
+			namespace:   "openshift-debug-abcde",
+			tolerations: []corev1.Toleration{tolerateEverything},
+			expected:    []corev1.Toleration{tolerateEverything},
+		},
+		{
+			name:      "pod outside debug namespaces",
+			namespace: "my-project",
+			expected:  nil,
+		},
+	}
+
+	gvk := metav1.GroupVersionKind{Version: "v1", Kind: "Pod"}
+	gvr := metav1.GroupVersionResource{Version: "v1", Resource: "pods"}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			raw := createPodRaw(t, test.namespace, test.tolerations)
+			hook := NewWebhook()
+			httprequest, err := testutils.CreateHTTPRequest(hook.GetURI(), "test-uid", gvk, gvr, admissionv1.Create,
+				"my_user", []string{"system:authenticated"}, test.namespace, &runtime.RawExtension{Raw: raw}, nil)
+			if err != nil {
+				t.Fatalf("Expected no error, got %s", err.Error())
+			}
+			response, err := testutils.SendHTTPRequest(httprequest, hook)
+			if err != nil {
+				t.Fatalf("Expected no error, got %s", err.Error())
+			}
+			if !response.Allowed {
+				t.Fatalf("Expected pod to be allowed, got %v", response.Result)
+			}
+
+			mutated := raw
+			if len(response.Patch) > 0 {
+				patch, err := patchengine.DecodePatch(response.Patch)
+				if err != nil {
+					t.Fatalf("Expected no error decoding the patch, got %s", err.Error())
+				}
+				if mutated, err = patch.Apply(raw); err != nil {
+					t.Fatalf("Expected no error applying the patch, got %s", err.Error())
+				}
+			}
+			pod := corev1.Pod{}
+			if err := json.Unmarshal(mutated, &pod); err != nil {
+				t.Fatalf("Expected no error, got %s", err.Error())
+			}
+			if !reflect.DeepEqual(pod.Spec.Tolerations, test.expected) {
+				t.Fatalf("Expected tolerations %v, got %v", test.expected, pod.Spec.Tolerations)
+			}
+		})
+	}
+}
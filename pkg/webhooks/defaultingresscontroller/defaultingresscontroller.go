@@ -0,0 +1,279 @@
+package defaultingresscontroller
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "defaultingresscontroller-validation"
+	docString   string = `Managed OpenShift customers may not delete the default IngressController in %s, scale it below %d replicas, place it only on worker nodes or remove its managed annotations.`
+
+	ingressOperatorNamespace string = "openshift-ingress-operator"
+	defaultIngressController string = "default"
+	// minReplicas keeps the default router available while a node is drained
+	minReplicas int32 = 2
+
+	workerNodeRoleLabel string = "node-role.kubernetes.io/worker"
+	infraNodeRoleLabel  string = "node-role.kubernetes.io/infra"
+)
+
+var (
+	// managedAnnotationPrefixes are the annotations set on the default
+	// IngressController by OCM and the managed ingress operators
+	managedAnnotationPrefixes = []string{
+		"hive.openshift.io/",
+		"managed.openshift.io/",
+		"cloudingress.managed.openshift.io/",
+	}
+
+	log = logf.Log.WithName(WebhookName)
+
+	scope = admissionregv1.NamespacedScope
+	rules = []admissionregv1.RuleWithOperations{
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Update, admissionregv1.Delete},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{"operator.openshift.io"},
+				APIVersions: []string{"*"},
+				Resources:   []string{"ingresscontrollers"},
+				Scope:       &scope,
+			},
+		},
+	}
+)
+
+// DefaultIngressControllerWebhook protects the default IngressController
+type DefaultIngressControllerWebhook struct {
+	s *runtime.Scheme
+}
+
+// NewWebhook creates a new webhook
+func NewWebhook() *DefaultIngressControllerWebhook {
+	scheme := runtime.NewScheme()
+	err := admissionv1.AddToScheme(scheme)
+	if err != nil {
+		log.Error(err, "Fail adding admissionv1 scheme to DefaultIngressControllerWebhook")
+		os.Exit(1)
+	}
+	err = operatorv1.AddToScheme(scheme)
+	if err != nil {
+		log.Error(err, "Fail adding operatorv1 scheme to DefaultIngressControllerWebhook")
+		os.Exit(1)
+	}
+
+	return &DefaultIngressControllerWebhook{
+		s: scheme,
+	}
+}
+
+// Authorized implements Webhook interface
+func (s *DefaultIngressControllerWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.authorized(request)
+}
+
+func (s *DefaultIngressControllerWebhook) authorized(request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if request.Namespace != ingressOperatorNamespace || request.Name != defaultIngressController {
+		ret = admissionctl.Allowed("Only the default IngressController is protected")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	if isAdmin(request) {
+		ret = admissionctl.Allowed("Admins and managed service accounts may change the default IngressController")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	if request.Operation == admissionv1.Delete {
+		log.Info("Denying deletion of the default IngressController", "user", request.UserInfo.Username)
+		ret = response.Denied(response.ManagedIngress, "Prevented from deleting the default IngressController, which serves the cluster's console and OAuth routes. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	oldIC, err := s.renderIngressController(request.OldObject)
+	if err != nil {
+		log.Error(err, "Couldn't render the old IngressController from the incoming request")
+		ret = admissionctl.Errored(http.StatusBadRequest, err)
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	ic, err := s.renderIngressController(request.Object)
+	if err != nil {
+		log.Error(err, "Couldn't render an IngressController from the incoming request")
+		ret = admissionctl.Errored(http.StatusBadRequest, err)
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	if reason := unsupportedChange(oldIC, ic); reason != "" {
+		log.Info("Denying change to the default IngressController", "user", request.UserInfo.Username, "reason", reason)
+		ret = response.Denied(response.ManagedIngress, fmt.Sprintf("Prevented from modifying the default IngressController: %s. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", reason))
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	ret = admissionctl.Allowed("Change to the default IngressController is supported")
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// unsupportedChange describes why updating oldIC to ic isn't allowed, or
+// returns "" when it is
+func unsupportedChange(oldIC, ic *operatorv1.IngressController) string {
+	if ic.Spec.Replicas != nil && *ic.Spec.Replicas < minReplicas {
+		return fmt.Sprintf("it must have at least %d replicas", minReplicas)
+	}
+
+	if workerOnly(ic.Spec.NodePlacement) && !workerOnly(oldIC.Spec.NodePlacement) {
+		return "its routers may not be placed only on worker nodes"
+	}
+
+	for key, value := range oldIC.Annotations {
+		if !isManagedAnnotation(key) {
+			continue
+		}
+		if newValue, ok := ic.Annotations[key]; !ok || newValue != value {
+			return fmt.Sprintf("the managed annotation %s may not be changed or removed", key)
+		}
+	}
+	return ""
+}
+
+// workerOnly checks whether the node placement selects worker nodes without
+// also selecting infra nodes
+func workerOnly(placement *operatorv1.NodePlacement) bool {
+	if placement == nil || placement.NodeSelector == nil {
+		return false
+	}
+	return selectsRole(placement.NodeSelector, workerNodeRoleLabel) && !selectsRole(placement.NodeSelector, infraNodeRoleLabel)
+}
+
+// selectsRole checks whether the selector requires the node role label
+func selectsRole(selector *metav1.LabelSelector, label string) bool {
+	if _, ok := selector.MatchLabels[label]; ok {
+		return true
+	}
+	for _, expression := range selector.MatchExpressions {
+		if expression.Key == label && (expression.Operator == metav1.LabelSelectorOpIn || expression.Operator == metav1.LabelSelectorOpExists) {
+			return true
+		}
+	}
+	return false
+}
+
+// isManagedAnnotation checks whether the annotation is set by OCM or the
+// managed ingress operators
+func isManagedAnnotation(key string) bool {
+	for _, prefix := range managedAnnotationPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isAdmin checks for cluster admins, SRE and the service accounts managing the
+// cluster
+func isAdmin(request admissionctl.Request) bool {
+	return identity.IsAdmin(request.UserInfo)
+}
+
+// renderIngressController renders an IngressController from the raw object
+func (s *DefaultIngressControllerWebhook) renderIngressController(raw runtime.RawExtension) (*operatorv1.IngressController, error) {
+	decoder := admissionctl.NewDecoder(s.s)
+	ic := &operatorv1.IngressController{}
+	if err := decoder.DecodeRaw(raw, ic); err != nil {
+		return nil, err
+	}
+	return ic, nil
+}
+
+// GetURI implements Webhook interface
+func (s *DefaultIngressControllerWebhook) GetURI() string { return "/" + WebhookName }
+
+// Validate implements Webhook interface
+func (s *DefaultIngressControllerWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	valid = valid && (request.Kind.Kind == "IngressController")
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *DefaultIngressControllerWebhook) Name() string { return WebhookName }
+
+// FailurePolicy implements Webhook interface
+func (s *DefaultIngressControllerWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *DefaultIngressControllerWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *DefaultIngressControllerWebhook) Rules() []admissionregv1.RuleWithOperations { return rules }
+
+// ObjectSelector implements Webhook interface
+func (s *DefaultIngressControllerWebhook) ObjectSelector() *metav1.LabelSelector { return nil }
+
+// SideEffects implements Webhook interface
+func (s *DefaultIngressControllerWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *DefaultIngressControllerWebhook) TimeoutSeconds() int32 { return 2 }
+
+// Doc implements Webhook interface
+func (s *DefaultIngressControllerWebhook) Doc() string {
+	return fmt.Sprintf(docString, ingressOperatorNamespace, minReplicas)
+}
+
+// RuleDocs implements Webhook interface
+func (s *DefaultIngressControllerWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers may not delete the default IngressController.",
+			Exceptions: []string{utils.AdminsException},
+		},
+		{
+			Summary:    "Customers may not scale the default IngressController below its minimum replicas.",
+			Exceptions: []string{utils.AdminsException},
+		},
+		{
+			Summary:    "Customers may not place the default IngressController only on worker nodes, nor remove its managed annotations.",
+			Exceptions: []string{utils.AdminsException},
+		},
+	}
+}
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+func (s *DefaultIngressControllerWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// ClassicEnabled implements Webhook interface
+func (s *DefaultIngressControllerWebhook) ClassicEnabled() bool { return true }
+
+// HypershiftEnabled implements Webhook interface
+func (s *DefaultIngressControllerWebhook) HypershiftEnabled() bool { return false }
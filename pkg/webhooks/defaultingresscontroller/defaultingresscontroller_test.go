@@ -0,0 +1,158 @@
+package defaultingresscontroller
+
+import (
+	"testing"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+)
+
+func newIngressController(name string, replicas int32, annotations map[string]string, nodeSelector map[string]string) *operatorv1.IngressController {
+	ic := &operatorv1.IngressController{
+		TypeMeta: metav1.TypeMeta{APIVersion: "operator.openshift.io/v1", Kind: "IngressController"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   ingressOperatorNamespace,
+			Annotations: annotations,
+		},
+		Spec: operatorv1.IngressControllerSpec{
+			Replicas: ptr.To(replicas),
+		},
+	}
+	if nodeSelector != nil {
+		ic.Spec.NodePlacement = &operatorv1.NodePlacement{
+			NodeSelector: &metav1.LabelSelector{MatchLabels: nodeSelector},
+		}
+	}
+	return ic
+}
+
+func TestAuthorized(t *testing.T) {
+	const managedAnnotation = "hive.openshift.io/managed"
+	annotations := map[string]string{managedAnnotation: "true"}
+	infra := map[string]string{infraNodeRoleLabel: ""}
+	base := newIngressController(defaultIngressController, 3, annotations, infra)
+
+	tests := []struct {
+		name      string
+		operation admissionv1.Operation
+		username  string
+		groups    []string
+		oldObj    *operatorv1.IngressController
+		newObj    *operatorv1.IngressController
+		allowed   bool
+	}{
+		{
+			name:      "customer scales the default ingresscontroller up",
+			operation: admissionv1.Update,
+			username:  "customer",
+			groups:    []string{"system:authenticated"},
+			newObj:    newIngressController(defaultIngressController, 4, annotations, infra),
+			allowed:   true,
+		},
+		{
+			name:      "customer scales the default ingresscontroller below the minimum",
+			operation: admissionv1.Update,
+			username:  "customer",
+			groups:    []string{"system:authenticated"},
+			newObj:    newIngressController(defaultIngressController, 1, annotations, infra),
+			allowed:   false,
+		},
+		{
+			name:      "customer places the default ingresscontroller on workers",
+			operation: admissionv1.Update,
+			username:  "customer",
+			groups:    []string{"system:authenticated"},
+			newObj:    newIngressController(defaultIngressController, 3, annotations, map[string]string{workerNodeRoleLabel: ""}),
+			allowed:   false,
+		},
+		{
+			name:      "customer places the default ingresscontroller on workers and infra",
+			operation: admissionv1.Update,
+			username:  "customer",
+			groups:    []string{"system:authenticated"},
+			newObj:    newIngressController(defaultIngressController, 3, annotations, map[string]string{workerNodeRoleLabel: "", infraNodeRoleLabel: ""}),
+			allowed:   true,
+		},
+		{
+			name:      "customer updates a default ingresscontroller already on workers",
+			operation: admissionv1.Update,
+			username:  "customer",
+			groups:    []string{"system:authenticated"},
+			oldObj:    newIngressController(defaultIngressController, 3, annotations, map[string]string{workerNodeRoleLabel: ""}),
+			newObj:    newIngressController(defaultIngressController, 4, annotations, map[string]string{workerNodeRoleLabel: ""}),
+			allowed:   true,
+		},
+		{
+			name:      "customer removes a managed annotation",
+			operation: admissionv1.Update,
+			username:  "customer",
+			groups:    []string{"system:authenticated"},
+			newObj:    newIngressController(defaultIngressController, 3, map[string]string{"example.com/other": "true"}, infra),
+			allowed:   false,
+		},
+		{
+			name:      "customer deletes the default ingresscontroller",
+			operation: admissionv1.Delete,
+			username:  "customer",
+			groups:    []string{"system:authenticated"},
+			allowed:   false,
+		},
+		{
+			name:      "customer deletes another ingresscontroller",
+			operation: admissionv1.Delete,
+			username:  "customer",
+			groups:    []string{"system:authenticated"},
+			oldObj:    newIngressController("apps2", 1, nil, nil),
+			allowed:   true,
+		},
+		{
+			name:      "sre deletes the default ingresscontroller",
+			operation: admissionv1.Delete,
+			username:  "sre",
+			groups:    []string{"system:serviceaccounts:openshift-backplane-srep"},
+			allowed:   true,
+		},
+		{
+			name:      "backplane-cluster-admin scales the default ingresscontroller below the minimum",
+			operation: admissionv1.Update,
+			username:  "backplane-cluster-admin",
+			newObj:    newIngressController(defaultIngressController, 1, annotations, infra),
+			allowed:   true,
+		},
+		{
+			name:      "cloud-ingress-operator removes a managed annotation",
+			operation: admissionv1.Update,
+			username:  "system:serviceaccount:openshift-cloud-ingress-operator:cloud-ingress-operator",
+			groups:    []string{"system:serviceaccounts:openshift-cloud-ingress-operator"},
+			newObj:    newIngressController(defaultIngressController, 3, nil, infra),
+			allowed:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			oldObj := test.oldObj
+			if oldObj == nil {
+				oldObj = base
+			}
+			request := testutils.NewRequest(t, metav1.GroupVersionKind{Group: "operator.openshift.io", Version: "v1", Kind: "IngressController"}, test.operation, authenticationv1.UserInfo{Username: test.username, Groups: test.groups}, ingressOperatorNamespace, oldObj.Name, test.newObj, oldObj)
+			hook := NewWebhook()
+			if !hook.Validate(request) {
+				t.Fatalf("Expected request to be valid")
+			}
+			response := hook.Authorized(request)
+			if response.Allowed != test.allowed {
+				t.Fatalf("Expected allowed %v, got %v: %v", test.allowed, response.Allowed, response.Result)
+			}
+			if response.UID != request.UID {
+				t.Fatalf("Expected UID %s, got %s", request.UID, response.UID)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package etcd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "etcd-validation"
+	docString   string = `Managed OpenShift customers, including cluster admins, may not delete Pods or Secrets, nor create, change or delete PodDisruptionBudgets, in the %s namespaces. Deleting etcd pods can lose etcd quorum and take down the cluster, so only the platform may do so.`
+
+	serviceAccountPrefix string = "system:serviceaccount:"
+)
+
+var (
+	scope = admissionregv1.NamespacedScope
+	rules = []admissionregv1.RuleWithOperations{
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Delete},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{""},
+				APIVersions: []string{"*"},
+				Resources:   []string{"pods", "secrets"},
+				Scope:       &scope,
+			},
+		},
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Create, admissionregv1.Update, admissionregv1.Delete},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{"policy"},
+				APIVersions: []string{"*"},
+				Resources:   []string{"poddisruptionbudgets"},
+				Scope:       &scope,
+			},
+		},
+	}
+	log = logf.Log.WithName(WebhookName)
+
+	// protectedNamespaces run etcd and the operator managing it
+	protectedNamespaces = []string{"openshift-etcd", "openshift-etcd-operator"}
+)
+
+// EtcdWebhook protects the resources etcd's quorum depends on
+type EtcdWebhook struct{}
+
+// NewWebhook creates a new webhook
+func NewWebhook() *EtcdWebhook {
+	return &EtcdWebhook{}
+}
+
+// Authorized implements Webhook interface
+func (s *EtcdWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.authorized(request)
+}
+
+func (s *EtcdWebhook) authorized(request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if !slices.Contains(protectedNamespaces, request.Namespace) {
+		ret = admissionctl.Allowed(fmt.Sprintf("Namespace %s doesn't run etcd", request.Namespace))
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	if isPlatform(request.UserInfo) {
+		ret = admissionctl.Allowed("The platform may manage etcd")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	verb := "deleting"
+	switch request.Operation {
+	case admissionv1.Create:
+		verb = "creating"
+	case admissionv1.Update:
+		verb = "changing"
+	}
+	log.Info("Denying change in etcd namespace", "kind", request.Kind.Kind, "namespace", request.Namespace, "name", request.Name, "operation", request.Operation, "user", request.UserInfo.Username)
+	ret = response.Denied(response.EtcdProtected, fmt.Sprintf("Prevented from %s %s %s in namespace %s. Etcd is managed by the platform, and deleting its pods, secrets or disruption budgets can lose etcd quorum and take down the cluster. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", verb, request.Kind.Kind, request.Name, request.Namespace))
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// isPlatform returns true if the user is part of the platform: SRE, a
+// privileged service account, or a system user such as a node or the
+// kube-controller-manager. Cluster admins are customers, so unlike most
+// webhooks they aren't trusted here.
+func isPlatform(user authenticationv1.UserInfo) bool {
+	if identity.IsSRE(user) || identity.IsPrivilegedServiceAccount(user) {
+		return true
+	}
+	return strings.HasPrefix(user.Username, "system:") && !strings.HasPrefix(user.Username, serviceAccountPrefix)
+}
+
+// GetURI implements Webhook interface
+func (s *EtcdWebhook) GetURI() string { return "/" + WebhookName }
+
+// Validate implements Webhook interface
+func (s *EtcdWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	valid = valid && (request.Kind.Kind == "Pod" || request.Kind.Kind == "Secret" || request.Kind.Kind == "PodDisruptionBudget")
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *EtcdWebhook) Name() string { return WebhookName }
+
+// FailurePolicy implements Webhook interface
+func (s *EtcdWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *EtcdWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *EtcdWebhook) Rules() []admissionregv1.RuleWithOperations { return rules }
+
+// ObjectSelector implements Webhook interface
+func (s *EtcdWebhook) ObjectSelector() *metav1.LabelSelector { return nil }
+
+// SideEffects implements Webhook interface
+func (s *EtcdWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *EtcdWebhook) TimeoutSeconds() int32 { return 2 }
+
+// Doc implements Webhook interface
+func (s *EtcdWebhook) Doc() string {
+	return fmt.Sprintf(docString, strings.Join(protectedNamespaces, " and "))
+}
+
+// RuleDocs implements Webhook interface
+func (s *EtcdWebhook) RuleDocs() []utils.RuleDoc {
+	exceptions := []string{utils.SREException, utils.PrivilegedServiceAccountsException, "Kubernetes and OpenShift system users, excluding kube:admin"}
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers, including cluster admins, may not delete Pods or Secrets in the openshift-etcd and openshift-etcd-operator namespaces.",
+			Exceptions: exceptions,
+		},
+		{
+			Summary:    "Customers, including cluster admins, may not create, change or delete PodDisruptionBudgets in the openshift-etcd and openshift-etcd-operator namespaces.",
+			Exceptions: exceptions,
+		},
+	}
+}
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+func (s *EtcdWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// ClassicEnabled implements Webhook interface
+func (s *EtcdWebhook) ClassicEnabled() bool { return true }
+
+// HypershiftEnabled implements Webhook interface. Hosted control planes run
+// etcd outside the cluster.
+func (s *EtcdWebhook) HypershiftEnabled() bool { return false }
@@ -0,0 +1,63 @@
+package etcd
+
+import (
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+)
+
+var (
+	clusterAdmin = authenticationv1.UserInfo{Username: "kube:admin", Groups: []string{"system:cluster-admins", "system:authenticated"}}
+	customer     = authenticationv1.UserInfo{Username: "customer", Groups: []string{"dedicated-admins", "system:authenticated"}}
+	customerSA   = authenticationv1.UserInfo{Username: "system:serviceaccount:customer:deployer", Groups: []string{"system:serviceaccounts", "system:serviceaccounts:customer"}}
+	sre          = authenticationv1.UserInfo{Username: "backplane-cluster-admin"}
+	etcdOperator = authenticationv1.UserInfo{Username: "system:serviceaccount:openshift-etcd-operator:etcd-operator", Groups: []string{"system:serviceaccounts", "system:serviceaccounts:openshift-etcd-operator"}}
+	node         = authenticationv1.UserInfo{Username: "system:node:master-0", Groups: []string{"system:nodes"}}
+)
+
+func TestAuthorized(t *testing.T) {
+	tests := []struct {
+		name      string
+		user      authenticationv1.UserInfo
+		operation admissionv1.Operation
+		kind      string
+		namespace string
+		allowed   bool
+	}{
+		{"cluster admin deletes etcd pod", clusterAdmin, admissionv1.Delete, "Pod", "openshift-etcd", false},
+		{"customer deletes etcd pod", customer, admissionv1.Delete, "Pod", "openshift-etcd", false},
+		{"customer service account deletes etcd pod", customerSA, admissionv1.Delete, "Pod", "openshift-etcd", false},
+		{"customer deletes etcd secret", customer, admissionv1.Delete, "Secret", "openshift-etcd", false},
+		{"customer deletes operator secret", customer, admissionv1.Delete, "Secret", "openshift-etcd-operator", false},
+		{"customer creates etcd pdb", customer, admissionv1.Create, "PodDisruptionBudget", "openshift-etcd", false},
+		{"customer changes etcd pdb", customer, admissionv1.Update, "PodDisruptionBudget", "openshift-etcd", false},
+		{"cluster admin deletes etcd pdb", clusterAdmin, admissionv1.Delete, "PodDisruptionBudget", "openshift-etcd", false},
+		{"customer deletes own pod", customer, admissionv1.Delete, "Pod", "customer", true},
+		{"customer deletes pod in other platform namespace", clusterAdmin, admissionv1.Delete, "Pod", "openshift-monitoring", true},
+		{"sre deletes etcd pod", sre, admissionv1.Delete, "Pod", "openshift-etcd", true},
+		{"etcd operator deletes etcd pod", etcdOperator, admissionv1.Delete, "Pod", "openshift-etcd", true},
+		{"etcd operator changes etcd pdb", etcdOperator, admissionv1.Update, "PodDisruptionBudget", "openshift-etcd", true},
+		{"node deletes mirror pod", node, admissionv1.Delete, "Pod", "openshift-etcd", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := NewWebhook()
+			request := testutils.NewRequest(t, metav1.GroupVersionKind{Version: "v1", Kind: test.kind}, test.operation, test.user, test.namespace, "etcd-master-0", nil, nil)
+			if !s.Validate(request) {
+				t.Fatalf("Expected the request to be valid")
+			}
+			response := s.Authorized(request)
+			if response.Allowed != test.allowed {
+				t.Errorf("Expected allowed %t, got %t: %s", test.allowed, response.Allowed, response.Result.Message)
+			}
+			if response.UID != "test-uid" {
+				t.Errorf("Expected the response UID to be set")
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package webhooks
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+)
+
+const (
+	// FailurePolicyEnvVar overrides the failure policies of webhooks, as a
+	// comma-separated list of webhook=policy, eg "*=Fail" to run every webhook
+	// failing closed on a staging cluster
+	FailurePolicyEnvVar string = "WEBHOOK_FAILURE_POLICY"
+
+	// FailurePolicyConfigMapName is the ConfigMap in the webhook namespace
+	// overriding the failure policies of webhooks on this cluster. Each key is
+	// a webhook name, or AllWebhooks, and each value "Fail" or "Ignore".
+	FailurePolicyConfigMapName string = "webhook-failure-policies"
+
+	// AllWebhooks overrides the failure policy of every webhook without an
+	// override of its own
+	AllWebhooks string = "*"
+)
+
+var (
+	failurePoliciesMu sync.RWMutex
+	failurePolicies   = FailurePolicies{}
+)
+
+// FailurePolicies override the failure policies of webhooks by name
+type FailurePolicies map[string]admissionregv1.FailurePolicyType
+
+// FailurePolicy is the failure policy the webhook is registered with: its own,
+// unless it's overridden
+func FailurePolicy(hook Webhook) admissionregv1.FailurePolicyType {
+	failurePoliciesMu.RLock()
+	defer failurePoliciesMu.RUnlock()
+	if policy, ok := failurePolicies[hook.Name()]; ok {
+		return policy
+	}
+	if policy, ok := failurePolicies[AllWebhooks]; ok {
+		return policy
+	}
+	return hook.FailurePolicy()
+}
+
+// SetFailurePolicies replaces the failure policy overrides
+func SetFailurePolicies(policies FailurePolicies) {
+	failurePoliciesMu.Lock()
+	defer failurePoliciesMu.Unlock()
+	failurePolicies = policies
+}
+
+// Merge returns the overrides with those of other added, replacing any for
+// the same webhook
+func (p FailurePolicies) Merge(other FailurePolicies) FailurePolicies {
+	merged := FailurePolicies{}
+	for name, policy := range p {
+		merged[name] = policy
+	}
+	for name, policy := range other {
+		merged[name] = policy
+	}
+	return merged
+}
+
+// ParseFailurePolicies reads overrides from a comma-separated list of
+// webhook=policy
+func ParseFailurePolicies(list string) (FailurePolicies, error) {
+	policies := FailurePolicies{}
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, found := strings.Cut(entry, "=")
+		if !found {
+			return nil, fmt.Errorf("failure policy override %q is not webhook=policy", entry)
+		}
+		policy, err := parseFailurePolicy(value)
+		if err != nil {
+			return nil, err
+		}
+		policies[strings.TrimSpace(name)] = policy
+	}
+	return policies, nil
+}
+
+// FailurePoliciesFromEnv reads the overrides set by FailurePolicyEnvVar
+func FailurePoliciesFromEnv() (FailurePolicies, error) {
+	policies, err := ParseFailurePolicies(os.Getenv(FailurePolicyEnvVar))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", FailurePolicyEnvVar, err)
+	}
+	return policies, nil
+}
+
+// FailurePoliciesFromData reads overrides from the data of the
+// FailurePolicyConfigMapName ConfigMap. Invalid entries are returned as errors
+// and left out, so one bad entry doesn't discard the others.
+func FailurePoliciesFromData(data map[string]string) (FailurePolicies, []error) {
+	policies := FailurePolicies{}
+	errs := []error{}
+	for name, value := range data {
+		policy, err := parseFailurePolicy(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("webhook %s: %w", name, err))
+			continue
+		}
+		policies[name] = policy
+	}
+	return policies, errs
+}
+
+func parseFailurePolicy(value string) (admissionregv1.FailurePolicyType, error) {
+	value = strings.TrimSpace(value)
+	for _, policy := range []admissionregv1.FailurePolicyType{admissionregv1.Fail, admissionregv1.Ignore} {
+		if strings.EqualFold(value, string(policy)) {
+			return policy, nil
+		}
+	}
+	return "", fmt.Errorf("unknown failure policy %q, expected %s or %s", value, admissionregv1.Fail, admissionregv1.Ignore)
+}
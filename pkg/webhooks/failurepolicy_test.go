@@ -0,0 +1,98 @@
+package webhooks
+
+import (
+	"testing"
+
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/hiveownership"
+)
+
+func TestParseFailurePolicies(t *testing.T) {
+	tests := []struct {
+		name     string
+		list     string
+		expected FailurePolicies
+		valid    bool
+	}{
+		{
+			name:     "empty",
+			list:     "",
+			expected: FailurePolicies{},
+			valid:    true,
+		},
+		{
+			name:     "every webhook with an exception",
+			list:     "*=Fail, podimagespec-mutation=ignore",
+			expected: FailurePolicies{AllWebhooks: admissionregv1.Fail, "podimagespec-mutation": admissionregv1.Ignore},
+			valid:    true,
+		},
+		{
+			name: "missing policy",
+			list: "namespace-validation",
+		},
+		{
+			name: "unknown policy",
+			list: "namespace-validation=Deny",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policies, err := ParseFailurePolicies(test.list)
+			if (err == nil) != test.valid {
+				t.Fatalf("Expected valid %t, got error %v", test.valid, err)
+			}
+			if len(policies) != len(test.expected) {
+				t.Fatalf("Expected %v, got %v", test.expected, policies)
+			}
+			for name, policy := range test.expected {
+				if policies[name] != policy {
+					t.Errorf("Expected %s to be %s, got %s", name, policy, policies[name])
+				}
+			}
+		})
+	}
+}
+
+func TestFailurePolicy(t *testing.T) {
+	t.Cleanup(func() { SetFailurePolicies(FailurePolicies{}) })
+	hook := hiveownership.NewWebhook()
+	own := hook.FailurePolicy()
+	other := admissionregv1.Fail
+	if own == admissionregv1.Fail {
+		other = admissionregv1.Ignore
+	}
+
+	if policy := FailurePolicy(hook); policy != own {
+		t.Errorf("Expected the webhook's own policy %s without overrides, got %s", own, policy)
+	}
+
+	SetFailurePolicies(FailurePolicies{AllWebhooks: other})
+	if policy := FailurePolicy(hook); policy != other {
+		t.Errorf("Expected the policy overridden for every webhook %s, got %s", other, policy)
+	}
+	if configuration := ValidatingWebhookConfiguration(hook, "test"); *configuration.Webhooks[0].FailurePolicy != other {
+		t.Errorf("Expected the configuration to carry the overridden policy %s, got %s", other, *configuration.Webhooks[0].FailurePolicy)
+	}
+
+	SetFailurePolicies(FailurePolicies{AllWebhooks: other}.Merge(FailurePolicies{hook.Name(): own}))
+	if policy := FailurePolicy(hook); policy != own {
+		t.Errorf("Expected the webhook's override %s to take precedence, got %s", own, policy)
+	}
+}
+
+func TestFailurePoliciesFromData(t *testing.T) {
+	policies, errs := FailurePoliciesFromData(map[string]string{
+		"namespace-validation":     "Fail",
+		"podimagespec-mutation":    "sometimes",
+		"priorityclass-validation": " Ignore ",
+	})
+	if len(errs) != 1 {
+		t.Errorf("Expected one invalid entry, got %v", errs)
+	}
+	expected := FailurePolicies{"namespace-validation": admissionregv1.Fail, "priorityclass-validation": admissionregv1.Ignore}
+	if len(policies) != len(expected) || policies["namespace-validation"] != admissionregv1.Fail || policies["priorityclass-validation"] != admissionregv1.Ignore {
+		t.Errorf("Expected %v, got %v", expected, policies)
+	}
+}
@@ -0,0 +1,260 @@
+package finalizers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	hookconfig "github.com/openshift/managed-cluster-validating-webhooks/pkg/config"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "finalizers-validation"
+	docString   string = `Managed OpenShift customers may not add finalizers to managed resources: managed namespaces and the objects in them, objects synced by Hive, and the cluster's platform configuration (%s). Finalizers nothing removes wedge the deletion of those resources and the cluster's upgrades. SRE may allow finalizers on a resource by listing them in its %s annotation.`
+
+	// AllowedFinalizersAnnotation is set by SRE on a managed resource to the
+	// comma-separated finalizers customers may add to it
+	AllowedFinalizersAnnotation string = "managed.openshift.io/allowed-finalizers"
+
+	// hiveManagedLabel is set on the objects Hive syncs to the cluster
+	hiveManagedLabel string = "hive.openshift.io/managed"
+)
+
+var (
+	scope = admissionregv1.AllScopes
+	// platformGroups hold the cluster's platform configuration, which its
+	// operators reconcile and upgrades depend on
+	platformGroups = []string{
+		"config.openshift.io",
+		"imageregistry.operator.openshift.io",
+		"machineconfiguration.openshift.io",
+		"operator.openshift.io",
+	}
+	// Finalizers are usually added by updates, and the objects they're added
+	// to already exist when customers get the cluster
+	rules = []admissionregv1.RuleWithOperations{
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Update},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{""},
+				APIVersions: []string{"v1"},
+				Resources:   []string{"namespaces"},
+				Scope:       &scope,
+			},
+		},
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Update},
+			Rule: admissionregv1.Rule{
+				APIGroups:   platformGroups,
+				APIVersions: []string{"*"},
+				Resources:   []string{"*"},
+				Scope:       &scope,
+			},
+		},
+		{
+			// commonly synced by Hive
+			Operations: []admissionregv1.OperationType{admissionregv1.Update},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{"quota.openshift.io", "rbac.authorization.k8s.io"},
+				APIVersions: []string{"*"},
+				Resources:   []string{"clusterresourcequotas", "clusterroles", "clusterrolebindings", "roles", "rolebindings"},
+				Scope:       &scope,
+			},
+		},
+	}
+	log = logf.Log.WithName(WebhookName)
+)
+
+// FinalizersWebhook prevents customers adding finalizers to managed resources
+type FinalizersWebhook struct{}
+
+// NewWebhook creates a new webhook
+func NewWebhook() *FinalizersWebhook {
+	return &FinalizersWebhook{}
+}
+
+// Authorized implements Webhook interface
+func (s *FinalizersWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.authorized(request)
+}
+
+func (s *FinalizersWebhook) authorized(request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	// the platform's controllers add and remove the finalizers they need
+	if isPrivileged(request.UserInfo) {
+		ret = admissionctl.Allowed("SRE, cluster admins and the platform may add finalizers to managed resources")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	old, obj, err := decode(request)
+	if err != nil {
+		log.Error(err, "Couldn't decode the object from the request")
+		ret = admissionctl.Errored(http.StatusBadRequest, err)
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	if !isManaged(request, old) {
+		ret = admissionctl.Allowed("The object is not managed")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	kind := strings.ToLower(request.Kind.Kind)
+	if old.Annotations[AllowedFinalizersAnnotation] != obj.Annotations[AllowedFinalizersAnnotation] {
+		log.Info("Denying change of the finalizer exceptions of a managed resource", "kind", request.Kind.Kind, "namespace", request.Namespace, "name", request.Name, "user", request.UserInfo.Username)
+		ret = response.Denied(response.ManagedFinalizer, fmt.Sprintf("Prevented from changing the %s annotation of managed %s %s: only Red Hat SRE may allow finalizers on managed resources. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", AllowedFinalizersAnnotation, kind, request.Name))
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	// Removing finalizers is always allowed, as it's how wedged deletions
+	// are unblocked
+	exceptions := allowedFinalizers(old)
+	added := []string{}
+	for _, finalizer := range obj.Finalizers {
+		if !slices.Contains(old.Finalizers, finalizer) && !slices.Contains(exceptions, finalizer) {
+			added = append(added, finalizer)
+		}
+	}
+	if len(added) == 0 {
+		ret = admissionctl.Allowed("No finalizers were added to the managed resource")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	log.Info("Denying finalizers added to a managed resource", "kind", request.Kind.Kind, "namespace", request.Namespace, "name", request.Name, "user", request.UserInfo.Username, "finalizers", added)
+	ret = response.Denied(response.ManagedFinalizer, fmt.Sprintf("Prevented from adding the finalizer %s to managed %s %s: finalizers on managed resources wedge their deletion and the cluster's upgrades until whatever added them removes them. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", strings.Join(added, ", "), kind, request.Name))
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// decode returns the metadata of the old and new object in the request
+func decode(request admissionctl.Request) (*metav1.PartialObjectMetadata, *metav1.PartialObjectMetadata, error) {
+	old := &metav1.PartialObjectMetadata{}
+	if err := json.Unmarshal(request.OldObject.Raw, old); err != nil {
+		return nil, nil, err
+	}
+	obj := &metav1.PartialObjectMetadata{}
+	if err := json.Unmarshal(request.Object.Raw, obj); err != nil {
+		return nil, nil, err
+	}
+	return old, obj, nil
+}
+
+// isManaged returns true for managed namespaces and the objects in them,
+// objects synced by Hive, and the platform configuration. The old object is
+// checked so customers can't unlabel an object in the same request.
+func isManaged(request admissionctl.Request, old *metav1.PartialObjectMetadata) bool {
+	if old.Labels[hiveManagedLabel] == "true" {
+		return true
+	}
+	if request.Kind.Kind == "Namespace" && request.Kind.Group == "" {
+		return hookconfig.IsPrivilegedNamespace(request.Name)
+	}
+	if request.Namespace != "" && hookconfig.IsPrivilegedNamespace(request.Namespace) {
+		return true
+	}
+	return slices.Contains(platformGroups, request.Kind.Group)
+}
+
+// allowedFinalizers returns the finalizers SRE allowed on the object
+func allowedFinalizers(obj *metav1.PartialObjectMetadata) []string {
+	allowed := []string{}
+	for _, finalizer := range strings.Split(obj.Annotations[AllowedFinalizersAnnotation], ",") {
+		if finalizer = strings.TrimSpace(finalizer); finalizer != "" {
+			allowed = append(allowed, finalizer)
+		}
+	}
+	return allowed
+}
+
+// isPrivileged returns true for SRE, cluster admins, the platform's service
+// accounts and system users other than service accounts
+func isPrivileged(user authenticationv1.UserInfo) bool {
+	if identity.IsAdmin(user) {
+		return true
+	}
+	return strings.HasPrefix(user.Username, "system:") && !strings.HasPrefix(user.Username, "system:serviceaccount:")
+}
+
+// GetURI implements Webhook interface
+func (s *FinalizersWebhook) GetURI() string { return "/" + WebhookName }
+
+// Validate implements Webhook interface
+func (s *FinalizersWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	valid = valid && (len(request.OldObject.Raw) > 0)
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *FinalizersWebhook) Name() string { return WebhookName }
+
+// FailurePolicy implements Webhook interface
+func (s *FinalizersWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *FinalizersWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *FinalizersWebhook) Rules() []admissionregv1.RuleWithOperations { return rules }
+
+// ObjectSelector implements Webhook interface
+func (s *FinalizersWebhook) ObjectSelector() *metav1.LabelSelector { return nil }
+
+// SideEffects implements Webhook interface
+func (s *FinalizersWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *FinalizersWebhook) TimeoutSeconds() int32 { return 2 }
+
+// Doc implements Webhook interface
+func (s *FinalizersWebhook) Doc() string {
+	return fmt.Sprintf(docString, strings.Join(platformGroups, ", "), AllowedFinalizersAnnotation)
+}
+
+// RuleDocs implements Webhook interface
+func (s *FinalizersWebhook) RuleDocs() []utils.RuleDoc {
+	exceptions := []string{utils.SREException, "The cluster's built-in administrators", "Platform and managed service accounts", "Kubernetes and OpenShift system users, other than service accounts"}
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers may not add finalizers to managed namespaces and the objects in them, objects synced by Hive, or the cluster's platform configuration.",
+			Exceptions: append(slices.Clone(exceptions), fmt.Sprintf("Finalizers SRE listed in the resource's %s annotation", AllowedFinalizersAnnotation)),
+		},
+		{
+			Summary:    fmt.Sprintf("Customers may not change the %s annotation of managed resources.", AllowedFinalizersAnnotation),
+			Exceptions: exceptions,
+		},
+	}
+}
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+func (s *FinalizersWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// ClassicEnabled implements Webhook interface
+func (s *FinalizersWebhook) ClassicEnabled() bool { return true }
+
+// HypershiftEnabled implements Webhook interface
+func (s *FinalizersWebhook) HypershiftEnabled() bool { return true }
@@ -0,0 +1,151 @@
+package finalizers
+
+import (
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+)
+
+func newRequest(t *testing.T, kind metav1.GroupVersionKind, namespace, username string, groups []string, old, obj metav1.PartialObjectMetadata) admissionctl.Request {
+	t.Helper()
+	old.Namespace, obj.Namespace = namespace, namespace
+	return testutils.NewRequest(t, kind, admissionv1.Update, authenticationv1.UserInfo{Username: username, Groups: groups}, namespace, obj.Name, obj, old)
+}
+
+func meta(name string, labels, annotations map[string]string, finalizers ...string) metav1.PartialObjectMetadata {
+	return metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels, Annotations: annotations, Finalizers: finalizers}}
+}
+
+func TestAuthorized(t *testing.T) {
+	namespaceKind := metav1.GroupVersionKind{Version: "v1", Kind: "Namespace"}
+	ingressControllerKind := metav1.GroupVersionKind{Group: "operator.openshift.io", Version: "v1", Kind: "IngressController"}
+	clusterRoleKind := metav1.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole"}
+	roleBindingKind := metav1.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "RoleBinding"}
+	hive := map[string]string{hiveManagedLabel: "true"}
+
+	tests := []struct {
+		name      string
+		kind      metav1.GroupVersionKind
+		namespace string
+		username  string
+		groups    []string
+		old       metav1.PartialObjectMetadata
+		obj       metav1.PartialObjectMetadata
+		allowed   bool
+		message   string
+	}{
+		{
+			name:     "finalizer added to a managed namespace",
+			kind:     namespaceKind,
+			username: "customer",
+			old:      meta("openshift-monitoring", nil, nil),
+			obj:      meta("openshift-monitoring", nil, nil, "acme.com/cleanup"),
+			allowed:  false,
+			message:  "Prevented from adding the finalizer acme.com/cleanup to managed namespace openshift-monitoring",
+		},
+		{
+			name:     "finalizer added to a customer namespace",
+			kind:     namespaceKind,
+			username: "customer",
+			old:      meta("payments", nil, nil),
+			obj:      meta("payments", nil, nil, "acme.com/cleanup"),
+			allowed:  true,
+		},
+		{
+			name:      "finalizer added to the platform configuration",
+			kind:      ingressControllerKind,
+			namespace: "openshift-ingress-operator",
+			username:  "customer",
+			old:       meta("default", nil, nil, "ingresscontroller.operator.openshift.io/finalizer-ingresscontroller"),
+			obj:       meta("default", nil, nil, "ingresscontroller.operator.openshift.io/finalizer-ingresscontroller", "acme.com/cleanup"),
+			allowed:   false,
+			message:   "finalizer acme.com/cleanup to managed ingresscontroller default",
+		},
+		{
+			name:     "finalizer added to an object synced by Hive",
+			kind:     clusterRoleKind,
+			username: "customer",
+			old:      meta("dedicated-admins-cluster", hive, nil),
+			obj:      meta("dedicated-admins-cluster", nil, nil, "acme.com/cleanup"),
+			allowed:  false,
+		},
+		{
+			name:      "finalizer added to a customer's object",
+			kind:      roleBindingKind,
+			namespace: "payments",
+			username:  "customer",
+			old:       meta("admins", nil, nil),
+			obj:       meta("admins", nil, nil, "acme.com/cleanup"),
+			allowed:   true,
+		},
+		{
+			name:     "finalizer removed from a managed resource",
+			kind:     clusterRoleKind,
+			username: "customer",
+			old:      meta("dedicated-admins-cluster", hive, nil, "acme.com/cleanup"),
+			obj:      meta("dedicated-admins-cluster", hive, nil),
+			allowed:  true,
+		},
+		{
+			name:     "finalizer allowed by SRE",
+			kind:     clusterRoleKind,
+			username: "customer",
+			old:      meta("dedicated-admins-cluster", hive, map[string]string{AllowedFinalizersAnnotation: "acme.com/audit, acme.com/cleanup"}),
+			obj:      meta("dedicated-admins-cluster", hive, map[string]string{AllowedFinalizersAnnotation: "acme.com/audit, acme.com/cleanup"}, "acme.com/cleanup"),
+			allowed:  true,
+		},
+		{
+			name:     "customer allowing their own finalizer",
+			kind:     clusterRoleKind,
+			username: "customer",
+			old:      meta("dedicated-admins-cluster", hive, nil),
+			obj:      meta("dedicated-admins-cluster", hive, map[string]string{AllowedFinalizersAnnotation: "acme.com/cleanup"}, "acme.com/cleanup"),
+			allowed:  false,
+			message:  "Prevented from changing the " + AllowedFinalizersAnnotation + " annotation",
+		},
+		{
+			name:     "sre",
+			kind:     namespaceKind,
+			username: "backplane-cluster-admin",
+			old:      meta("openshift-monitoring", nil, nil),
+			obj:      meta("openshift-monitoring", nil, map[string]string{AllowedFinalizersAnnotation: "acme.com/cleanup"}, "acme.com/cleanup"),
+			allowed:  true,
+		},
+		{
+			name:      "platform operator",
+			kind:      ingressControllerKind,
+			namespace: "openshift-ingress-operator",
+			username:  "system:serviceaccount:openshift-ingress-operator:ingress-operator",
+			groups:    []string{"system:serviceaccounts", "system:serviceaccounts:openshift-ingress-operator"},
+			old:       meta("default", nil, nil),
+			obj:       meta("default", nil, nil, "ingresscontroller.operator.openshift.io/finalizer-ingresscontroller"),
+			allowed:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hook := NewWebhook()
+			request := newRequest(t, test.kind, test.namespace, test.username, test.groups, test.old, test.obj)
+			if !hook.Validate(request) {
+				t.Fatalf("Expected request to be valid")
+			}
+			response := hook.Authorized(request)
+			if response.Allowed != test.allowed {
+				t.Fatalf("Expected allowed %v, got %v: %v", test.allowed, response.Allowed, response.Result)
+			}
+			if response.UID != request.UID {
+				t.Fatalf("Expected UID %s, got %s", request.UID, response.UID)
+			}
+			if test.message != "" && !strings.Contains(response.Result.Message, test.message) {
+				t.Errorf("Expected the message to contain %q, got %s", test.message, response.Result.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package hatopology
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+
+	hookconfig "github.com/openshift/managed-cluster-validating-webhooks/pkg/config"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "hatopology-validation"
+	docString   string = `Deployments and StatefulSets Managed OpenShift customers label %s=true claim to be highly available, which the SLA of managed clusters requires their pods to be spread across zones for. The webhook warns about, or denies when %s=%s, those lacking a topology spread constraint or pod anti-affinity across zones. The policy is opt-in, and only enforced on clusters which enable the webhook.`
+
+	// HALabel is the label customers set to true on the workloads they claim
+	// to be highly available
+	HALabel string = "ha"
+
+	// ModeEnvVar selects what happens to highly available workloads whose
+	// pods aren't spread across zones. Unset or "warn" admits them with a
+	// warning; "deny" denies them.
+	ModeEnvVar string = "HATOPOLOGY_MODE"
+	modeWarn   string = "warn"
+	modeDeny   string = "deny"
+)
+
+var (
+	scope = admissionregv1.NamespacedScope
+	rules = []admissionregv1.RuleWithOperations{
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Create, admissionregv1.Update},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{"apps"},
+				APIVersions: []string{"v1"},
+				Resources:   []string{"deployments", "statefulsets"},
+				Scope:       &scope,
+			},
+		},
+	}
+	log = logf.Log.WithName(WebhookName)
+
+	// zoneTopologyKeys are the node labels pods are spread across zones by
+	zoneTopologyKeys = []string{corev1.LabelTopologyZone, corev1.LabelFailureDomainBetaZone}
+)
+
+// HATopologyWebhook checks the workloads customers claim to be highly
+// available spread their pods across zones
+type HATopologyWebhook struct {
+	decoder admissionctl.Decoder
+}
+
+// NewWebhook creates a new webhook
+func NewWebhook() *HATopologyWebhook {
+	decoder, err := k8sutil.SharedDecoder()
+	if err != nil {
+		log.Error(err, "Fail getting the shared decoder for HATopologyWebhook")
+		os.Exit(1)
+	}
+
+	return &HATopologyWebhook{
+		decoder: decoder,
+	}
+}
+
+// Authorized implements Webhook interface
+func (s *HATopologyWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.authorized(request)
+}
+
+func (s *HATopologyWebhook) authorized(request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if s.ExcludedNamespaces().Excludes(request.Namespace) {
+		ret = admissionctl.Allowed("Workloads in managed namespaces are not checked for high availability")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	meta, template, err := s.renderWorkload(request)
+	if err != nil {
+		log.Error(err, "Couldn't render a workload from the incoming request")
+		ret = admissionctl.Errored(http.StatusBadRequest, err)
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	// The object selector also sends the updates removing the label
+	if meta.Labels[HALabel] != "true" {
+		ret = admissionctl.Allowed("Workload is not claimed to be highly available")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	if spreadAcrossZones(template) {
+		ret = admissionctl.Allowed("Workload spreads its pods across zones")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	// Warnings past response.MaxWarningLength are truncated, so the problem
+	// is kept short
+	problem := fmt.Sprintf("%s %s/%s is labelled %s=true, but its pods aren't spread across zones for the SLA's availability. Add a topologySpreadConstraint or podAntiAffinity term with topologyKey %s selecting them.", request.Kind.Kind, request.Namespace, request.Name, HALabel, corev1.LabelTopologyZone)
+	if mode() == modeDeny {
+		log.Info("Denying highly available workload not spread across zones", "kind", request.Kind.Kind, "namespace", request.Namespace, "name", request.Name, "user", request.UserInfo.Username)
+		ret = response.Denied(response.HighAvailabilityTopology, problem+" If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	ret = admissionctl.Allowed("Workload is not spread across zones")
+	ret = ret.WithWarnings(problem)
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// spreadAcrossZones is true when the template has a topology spread constraint
+// or a pod anti-affinity term, required or preferred, across zones selecting
+// its own pods. Terms selecting other pods don't spread the workload's.
+func spreadAcrossZones(template *corev1.PodTemplateSpec) bool {
+	for _, constraint := range template.Spec.TopologySpreadConstraints {
+		if slices.Contains(zoneTopologyKeys, constraint.TopologyKey) && selects(constraint.LabelSelector, template.Labels) {
+			return true
+		}
+	}
+	if template.Spec.Affinity == nil || template.Spec.Affinity.PodAntiAffinity == nil {
+		return false
+	}
+	antiAffinity := template.Spec.Affinity.PodAntiAffinity
+	terms := append([]corev1.PodAffinityTerm{}, antiAffinity.RequiredDuringSchedulingIgnoredDuringExecution...)
+	for _, weighted := range antiAffinity.PreferredDuringSchedulingIgnoredDuringExecution {
+		terms = append(terms, weighted.PodAffinityTerm)
+	}
+	for _, term := range terms {
+		if slices.Contains(zoneTopologyKeys, term.TopologyKey) && selects(term.LabelSelector, template.Labels) {
+			return true
+		}
+	}
+	return false
+}
+
+// selects is true when the selector matches the pod labels. A nil selector
+// matches no pod.
+func selects(selector *metav1.LabelSelector, podLabels map[string]string) bool {
+	if selector == nil {
+		return false
+	}
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return false
+	}
+	return !s.Empty() && s.Matches(labels.Set(podLabels))
+}
+
+// mode returns whether workloads not spread across zones are warned about or
+// denied
+func mode() string {
+	if os.Getenv(ModeEnvVar) == modeDeny {
+		return modeDeny
+	}
+	return modeWarn
+}
+
+// renderWorkload renders the metadata and pod template of the Deployment or
+// StatefulSet in the admission Request
+func (s *HATopologyWebhook) renderWorkload(request admissionctl.Request) (*metav1.ObjectMeta, *corev1.PodTemplateSpec, error) {
+	switch request.Kind.Kind {
+	case "Deployment":
+		deployment := &appsv1.Deployment{}
+		if err := s.decoder.DecodeRaw(request.Object, deployment); err != nil {
+			return nil, nil, err
+		}
+		return &deployment.ObjectMeta, &deployment.Spec.Template, nil
+	case "StatefulSet":
+		statefulSet := &appsv1.StatefulSet{}
+		if err := s.decoder.DecodeRaw(request.Object, statefulSet); err != nil {
+			return nil, nil, err
+		}
+		return &statefulSet.ObjectMeta, &statefulSet.Spec.Template, nil
+	}
+	return nil, nil, fmt.Errorf("unexpected kind %s", request.Kind.Kind)
+}
+
+// GetURI implements Webhook interface
+func (s *HATopologyWebhook) GetURI() string {
+	return "/" + WebhookName
+}
+
+// Validate implements Webhook interface
+func (s *HATopologyWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	valid = valid && (request.Operation == admissionv1.Create || request.Operation == admissionv1.Update)
+	valid = valid && (request.Kind.Kind == "Deployment" || request.Kind.Kind == "StatefulSet")
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *HATopologyWebhook) Name() string {
+	return WebhookName
+}
+
+// FailurePolicy implements Webhook interface
+func (s *HATopologyWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *HATopologyWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *HATopologyWebhook) Rules() []admissionregv1.RuleWithOperations {
+	return rules
+}
+
+// ExcludedNamespaces implements NamespaceExclusionWebhook interface
+func (s *HATopologyWebhook) ExcludedNamespaces() utils.NamespaceExclusion {
+	return hookconfig.ExcludedNamespaces
+}
+
+// OptIn implements OptInWebhook interface. Only some customers rely on the
+// SLA's high availability, so it's only enforced on the clusters enabling it.
+func (s *HATopologyWebhook) OptIn() bool {
+	return true
+}
+
+// ObjectSelector implements Webhook interface. Only the workloads claimed to
+// be highly available are sent.
+func (s *HATopologyWebhook) ObjectSelector() *metav1.LabelSelector {
+	return &metav1.LabelSelector{
+		MatchLabels: map[string]string{
+			HALabel: "true",
+		},
+	}
+}
+
+// SideEffects implements Webhook interface
+func (s *HATopologyWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *HATopologyWebhook) TimeoutSeconds() int32 {
+	return 2
+}
+
+// Doc implements Webhook interface
+func (s *HATopologyWebhook) Doc() string {
+	return fmt.Sprintf(docString, HALabel, ModeEnvVar, modeDeny)
+}
+
+// RuleDocs implements Webhook interface
+func (s *HATopologyWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    fmt.Sprintf("On clusters which enable the webhook, Deployments and StatefulSets labelled %s=true whose pod template has no topology spread constraint or pod anti-affinity term across zones selecting its pods are admitted with a warning, or denied when %s=%s.", HALabel, ModeEnvVar, modeDeny),
+			Exceptions: []string{"Workloads in managed namespaces"},
+		},
+	}
+}
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+func (s *HATopologyWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// ClassicEnabled implements Webhook interface
+func (s *HATopologyWebhook) ClassicEnabled() bool {
+	return true
+}
+
+// HypershiftEnabled implements Webhook interface
+func (s *HATopologyWebhook) HypershiftEnabled() bool {
+	return true
+}
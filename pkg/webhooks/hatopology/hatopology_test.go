@@ -0,0 +1,165 @@
+package hatopology
+
+import (
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+)
+
+var (
+	customer    = authenticationv1.UserInfo{Username: "customer", Groups: []string{"dedicated-admins", "system:authenticated"}}
+	podLabels   = map[string]string{"app": "api"}
+	podSelector = &metav1.LabelSelector{MatchLabels: podLabels}
+)
+
+func template(spec corev1.PodSpec) corev1.PodTemplateSpec {
+	return corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Labels: podLabels},
+		Spec:       spec,
+	}
+}
+
+func zoneSpread(selector *metav1.LabelSelector) corev1.PodSpec {
+	return corev1.PodSpec{
+		TopologySpreadConstraints: []corev1.TopologySpreadConstraint{
+			{MaxSkew: 1, TopologyKey: corev1.LabelTopologyZone, WhenUnsatisfiable: corev1.ScheduleAnyway, LabelSelector: selector},
+		},
+	}
+}
+
+func antiAffinity(topologyKey string) corev1.PodSpec {
+	return corev1.PodSpec{
+		Affinity: &corev1.Affinity{
+			PodAntiAffinity: &corev1.PodAntiAffinity{
+				PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{
+					{Weight: 100, PodAffinityTerm: corev1.PodAffinityTerm{TopologyKey: topologyKey, LabelSelector: podSelector}},
+				},
+			},
+		},
+	}
+}
+
+func deployment(labels map[string]string, spec corev1.PodSpec) runtime.Object {
+	return &appsv1.Deployment{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "payments", Labels: labels},
+		Spec:       appsv1.DeploymentSpec{Selector: podSelector, Template: template(spec)},
+	}
+}
+
+func statefulSet(labels map[string]string, spec corev1.PodSpec) runtime.Object {
+	return &appsv1.StatefulSet{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "StatefulSet"},
+		ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "payments", Labels: labels},
+		Spec:       appsv1.StatefulSetSpec{Selector: podSelector, Template: template(spec)},
+	}
+}
+
+func TestWorkloads(t *testing.T) {
+	ha := map[string]string{HALabel: "true"}
+	tests := []struct {
+		name      string
+		mode      string
+		namespace string
+		obj       runtime.Object
+		allowed   bool
+		warning   bool
+		message   string
+	}{
+		{
+			name:    "unspread deployment warned",
+			obj:     deployment(ha, corev1.PodSpec{}),
+			allowed: true,
+			warning: true,
+			message: "Deployment payments/api is labelled ha=true, but its pods aren't spread across zones",
+		},
+		{
+			name:    "unspread statefulset denied",
+			mode:    modeDeny,
+			obj:     statefulSet(ha, corev1.PodSpec{}),
+			allowed: false,
+			message: "StatefulSet payments/api is labelled ha=true, but its pods aren't spread across zones",
+		},
+		{
+			name:    "hostname anti-affinity denied",
+			mode:    modeDeny,
+			obj:     deployment(ha, antiAffinity(corev1.LabelHostname)),
+			allowed: false,
+		},
+		{
+			name:    "spread selecting other pods denied",
+			mode:    modeDeny,
+			obj:     deployment(ha, zoneSpread(&metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}})),
+			allowed: false,
+		},
+		{
+			name:    "zone spread allowed",
+			mode:    modeDeny,
+			obj:     deployment(ha, zoneSpread(podSelector)),
+			allowed: true,
+		},
+		{
+			name:    "zone anti-affinity allowed",
+			mode:    modeDeny,
+			obj:     statefulSet(ha, antiAffinity(corev1.LabelTopologyZone)),
+			allowed: true,
+		},
+		{
+			name:    "label removed allowed",
+			mode:    modeDeny,
+			obj:     deployment(map[string]string{HALabel: "false"}, corev1.PodSpec{}),
+			allowed: true,
+		},
+		{
+			name:      "managed namespace allowed",
+			mode:      modeDeny,
+			namespace: "openshift-monitoring",
+			obj:       deployment(ha, corev1.PodSpec{}),
+			allowed:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(ModeEnvVar, test.mode)
+			namespace := test.namespace
+			if namespace == "" {
+				namespace = "payments"
+			}
+			hook := NewWebhook()
+			gvk := metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: test.obj.GetObjectKind().GroupVersionKind().Kind}
+			request := testutils.NewRequest(t, gvk, admissionv1.Create, customer, namespace, "api", test.obj, nil)
+			if !hook.Validate(request) {
+				t.Fatalf("Expected request to be valid")
+			}
+			response := hook.Authorized(request)
+			if response.Allowed != test.allowed {
+				t.Fatalf("Expected allowed %v, got %v: %v", test.allowed, response.Allowed, response.Result)
+			}
+			if response.UID != request.UID {
+				t.Fatalf("Expected UID %s, got %s", request.UID, response.UID)
+			}
+			if (len(response.Warnings) > 0) != test.warning {
+				t.Errorf("Expected warnings %v, got %v", test.warning, response.Warnings)
+			}
+			if test.message == "" {
+				return
+			}
+			message := response.Result.Message
+			if test.warning {
+				message = strings.Join(response.Warnings, " ")
+			}
+			if !strings.Contains(message, test.message) {
+				t.Errorf("Expected the message to contain %q, got %s", test.message, message)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"regexp"
 	"slices"
 
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
 	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionregv1 "k8s.io/api/admissionregistration/v1"
@@ -76,6 +77,16 @@ func (s *HCPNamespaceWebhook) Doc() string {
 	return docString
 }
 
+// RuleDocs implements Webhook interface
+func (s *HCPNamespaceWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Only authorized users and service accounts may delete the namespaces of hosted control planes.",
+			Exceptions: []string{"The service accounts authorized to delete hosted control planes"},
+		},
+	}
+}
+
 // TimeoutSeconds implements Webhook interface
 func (s *HCPNamespaceWebhook) TimeoutSeconds() int32 { return 2 }
 
@@ -160,7 +171,7 @@ func (s *HCPNamespaceWebhook) authorized(request admissionctl.Request) admission
 		"namespace", namespace,
 		"groups", request.UserInfo.Groups)
 
-	ret = admissionctl.Denied(fmt.Sprintf("Only authorized users/service accounts can delete this namespace %s", namespace))
+	ret = response.Denied(response.HostedClusterDeletion, fmt.Sprintf("Only authorized users/service accounts can delete this namespace %s", namespace))
 	ret.UID = request.AdmissionRequest.UID
 	return ret
 }
@@ -0,0 +1,176 @@
+package hivedeletion
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "hivedeletion-validation"
+	docString   string = `Managed OpenShift customers may not delete resources delivered by Hive SelectorSyncSets, which have a "%s": "true" label. Hive would recreate them, so the deletion is denied with an explanation instead.`
+
+	managedLabel string = "hive.openshift.io/managed"
+)
+
+var (
+	// cascadingUsers delete managed resources along with their owner or
+	// namespace
+	cascadingUsers = []string{
+		"system:serviceaccount:kube-system:generic-garbage-collector",
+		"system:serviceaccount:kube-system:namespace-controller",
+	}
+
+	log = logf.Log.WithName(WebhookName)
+
+	scope = admissionregv1.AllScopes
+	rules = []admissionregv1.RuleWithOperations{
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Delete},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{"*"},
+				APIVersions: []string{"*"},
+				Resources:   []string{"*"},
+				Scope:       &scope,
+			},
+		},
+	}
+)
+
+// HiveDeletionWebhook prevents customers deleting resources Hive manages
+type HiveDeletionWebhook struct {
+	s *runtime.Scheme
+}
+
+// NewWebhook creates a new webhook
+func NewWebhook() *HiveDeletionWebhook {
+	scheme := runtime.NewScheme()
+	err := admissionv1.AddToScheme(scheme)
+	if err != nil {
+		log.Error(err, "Fail adding admissionv1 scheme to HiveDeletionWebhook")
+		os.Exit(1)
+	}
+
+	return &HiveDeletionWebhook{
+		s: scheme,
+	}
+}
+
+// Authorized implements Webhook interface
+func (s *HiveDeletionWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.authorized(request)
+}
+
+func (s *HiveDeletionWebhook) authorized(request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if isAdmin(request) {
+		ret = admissionctl.Allowed("Admins and managed service accounts may delete Hive managed resources")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	log.Info("Denying deletion of Hive managed resource", "resource", request.Resource.Resource, "namespace", request.Namespace, "name", request.Name, "user", request.UserInfo.Username)
+	ret = response.Denied(response.ManagedResource, deniedMessage(request))
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// deniedMessage explains that deleting the resource won't stick, and how to
+// have it changed instead
+func deniedMessage(request admissionctl.Request) string {
+	name := request.Name
+	if request.Namespace != "" {
+		name = request.Namespace + "/" + name
+	}
+	return fmt.Sprintf("Prevented from deleting %s %s, which Red Hat manages through OpenShift Cluster Manager: it would be recreated automatically. If it needs to be removed or changed, please open a support case at https://access.redhat.com/support", request.Kind.Kind, name)
+}
+
+// isAdmin checks for cluster admins, SRE, the controllers cascading deletions
+// and the service accounts managing the cluster
+func isAdmin(request admissionctl.Request) bool {
+	return identity.IsAdmin(request.UserInfo) || slices.Contains(cascadingUsers, request.UserInfo.Username)
+}
+
+// GetURI implements Webhook interface
+func (s *HiveDeletionWebhook) GetURI() string { return "/" + WebhookName }
+
+// Validate implements Webhook interface
+func (s *HiveDeletionWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	valid = valid && (request.Operation == admissionv1.Delete)
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *HiveDeletionWebhook) Name() string { return WebhookName }
+
+// FailurePolicy implements Webhook interface
+func (s *HiveDeletionWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *HiveDeletionWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *HiveDeletionWebhook) Rules() []admissionregv1.RuleWithOperations { return rules }
+
+// ObjectSelector implements Webhook interface. Only resources labelled by
+// their SelectorSyncSet are sent to the webhook.
+func (s *HiveDeletionWebhook) ObjectSelector() *metav1.LabelSelector {
+	return &metav1.LabelSelector{
+		MatchLabels: map[string]string{
+			managedLabel: "true",
+		},
+	}
+}
+
+// SideEffects implements Webhook interface
+func (s *HiveDeletionWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *HiveDeletionWebhook) TimeoutSeconds() int32 { return 2 }
+
+// Doc implements Webhook interface
+func (s *HiveDeletionWebhook) Doc() string {
+	return fmt.Sprintf(docString, managedLabel)
+}
+
+// RuleDocs implements Webhook interface
+func (s *HiveDeletionWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers may not delete resources delivered by Hive SelectorSyncSets, which have a \"hive.openshift.io/managed\": \"true\" label.",
+			Exceptions: []string{utils.AdminsException},
+		},
+	}
+}
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+func (s *HiveDeletionWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// ClassicEnabled implements Webhook interface
+func (s *HiveDeletionWebhook) ClassicEnabled() bool { return true }
+
+// HypershiftEnabled implements Webhook interface. Hosted clusters aren't
+// managed by Hive SelectorSyncSets.
+func (s *HiveDeletionWebhook) HypershiftEnabled() bool { return false }
@@ -0,0 +1,96 @@
+package hivedeletion
+
+import (
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+)
+
+func newRequest(t *testing.T, username string, groups []string) admissionctl.Request {
+	gvk := metav1.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "RoleBinding"}
+	return testutils.NewRequest(t, gvk, admissionv1.Delete, authenticationv1.UserInfo{Username: username, Groups: groups}, "openshift-monitoring", "managed-rolebinding", nil, nil)
+}
+
+func TestAuthorized(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		groups   []string
+		allowed  bool
+	}{
+		{
+			name:     "customer deletes a managed resource",
+			username: "customer",
+			groups:   []string{"system:authenticated", "dedicated-admins"},
+			allowed:  false,
+		},
+		{
+			name:     "customer service account deletes a managed resource",
+			username: "system:serviceaccount:customer-ns:pruner",
+			groups:   []string{"system:serviceaccounts", "system:serviceaccounts:customer-ns"},
+			allowed:  false,
+		},
+		{
+			name:     "sre deletes a managed resource",
+			username: "sre",
+			groups:   []string{"system:serviceaccounts:openshift-backplane-srep"},
+			allowed:  true,
+		},
+		{
+			name:     "backplane-cluster-admin deletes a managed resource",
+			username: "backplane-cluster-admin",
+			allowed:  true,
+		},
+		{
+			name:     "hive deletes a managed resource",
+			username: "system:admin",
+			allowed:  true,
+		},
+		{
+			name:     "garbage collector deletes a managed resource",
+			username: "system:serviceaccount:kube-system:generic-garbage-collector",
+			groups:   []string{"system:serviceaccounts", "system:serviceaccounts:kube-system"},
+			allowed:  true,
+		},
+		{
+			name:     "managed operator deletes a managed resource",
+			username: "system:serviceaccount:openshift-monitoring:cluster-monitoring-operator",
+			groups:   []string{"system:serviceaccounts", "system:serviceaccounts:openshift-monitoring"},
+			allowed:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := newRequest(t, test.username, test.groups)
+			hook := NewWebhook()
+			if !hook.Validate(request) {
+				t.Fatalf("Expected request to be valid")
+			}
+			response := hook.Authorized(request)
+			if response.Allowed != test.allowed {
+				t.Fatalf("Expected allowed %v, got %v: %v", test.allowed, response.Allowed, response.Result)
+			}
+			if response.UID != request.UID {
+				t.Fatalf("Expected UID %s, got %s", request.UID, response.UID)
+			}
+			if !test.allowed && !strings.Contains(response.Result.Message, "RoleBinding openshift-monitoring/managed-rolebinding") {
+				t.Errorf("Expected the denial to name the resource, got %s", response.Result.Message)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	request := newRequest(t, "customer", nil)
+	request.Operation = admissionv1.Update
+	if NewWebhook().Validate(request) {
+		t.Errorf("Expected an update to be invalid")
+	}
+}
@@ -1,10 +1,12 @@
 package hiveownership
 
 import (
+	"fmt"
 	"os"
-	"slices"
 	"sync"
 
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
 	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
 	admissionregv1 "k8s.io/api/admissionregistration/v1"
 	admissionv1 "k8s.io/api/apps/v1"
@@ -28,11 +30,13 @@ type HiveOwnershipWebhook struct {
 }
 
 var (
-	privilegedUsers = []string{"kube:admin", "system:admin", "system:serviceaccount:kube-system:generic-garbage-collector", "backplane-cluster-admin"}
-	adminGroups     = []string{"system:serviceaccounts:openshift-backplane-srep"}
+	// garbageCollector deletes managed resources along with their owner
+	garbageCollector = "system:serviceaccount:kube-system:generic-garbage-collector"
 
 	log = logf.Log.WithName(WebhookName)
 
+	deniedMessage = "Prevented from accessing Red Hat managed resources. This is in an effort to prevent harmful actions that may cause unintended consequences or affect the stability of the cluster. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support"
+
 	scope = admissionregv1.ClusterScope
 	rules = []admissionregv1.RuleWithOperations{
 		{
@@ -87,6 +91,16 @@ func (s *HiveOwnershipWebhook) Doc() string {
 	return docString
 }
 
+// RuleDocs implements Webhook interface
+func (s *HiveOwnershipWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers may not edit managed resources, which have a \"hive.openshift.io/managed\": \"true\" label.",
+			Exceptions: []string{utils.SREException, "The cluster's built-in administrators", "The garbage collector"},
+		},
+	}
+}
+
 // ObjectSelector intercepts based on having the label
 // .metadata.labels["hive.openshift.io/managed"] == "true"
 func (s *HiveOwnershipWebhook) ObjectSelector() *metav1.LabelSelector {
@@ -101,21 +115,19 @@ func (s *HiveOwnershipWebhook) authorized(request admissionctl.Request) admissio
 	var ret admissionctl.Response
 
 	// Admin users
-	if slices.Contains(privilegedUsers, request.AdmissionRequest.UserInfo.Username) {
+	if identity.IsClusterAdmin(request.UserInfo) || request.UserInfo.Username == garbageCollector {
 		ret = admissionctl.Allowed("Admin users may edit managed resources")
 		ret.UID = request.AdmissionRequest.UID
 		return ret
 	}
-	// Users in admin groups
-	for _, group := range request.AdmissionRequest.UserInfo.Groups {
-		if slices.Contains(adminGroups, group) {
-			ret = admissionctl.Allowed("Members of admin group may edit managed resources")
-			ret.UID = request.AdmissionRequest.UID
-			return ret
-		}
+	// SRE
+	if identity.IsSRE(request.UserInfo) {
+		ret = admissionctl.Allowed("Members of admin group may edit managed resources")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
 	}
 
-	ret = admissionctl.Denied("Prevented from accessing Red Hat managed resources. This is in an effort to prevent harmful actions that may cause unintended consequences or affect the stability of the cluster. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support")
+	ret = response.Denied(response.ManagedResource, deniedMessage)
 	ret.UID = request.AdmissionRequest.UID
 	return ret
 }
@@ -125,6 +137,23 @@ func (s *HiveOwnershipWebhook) Authorized(request admissionctl.Request) admissio
 	return s.authorized(request)
 }
 
+// Validations implements PolicyWebhook interface. The policy is generated at
+// build time, so it allows the identities of the default identity policy.
+func (s *HiveOwnershipWebhook) Validations() []admissionregv1.Validation {
+	reason := metav1.StatusReasonForbidden
+	policy := identity.Default()
+	privilegedUsers := append(append(policy.ClusterAdmins.Usernames, garbageCollector), policy.SRE.Usernames...)
+	adminGroups := append(policy.ClusterAdmins.Groups, policy.SRE.Groups...)
+	return []admissionregv1.Validation{
+		{
+			Expression: fmt.Sprintf("request.userInfo.username in %s || (has(request.userInfo.groups) && request.userInfo.groups.exists(g, g in %s))",
+				utils.CELStringList(privilegedUsers), utils.CELStringList(adminGroups)),
+			Message: deniedMessage,
+			Reason:  &reason,
+		},
+	}
+}
+
 // CustomSelector implements Webhook interface, returning the custom label selector for the syncset, if any
 func (s *HiveOwnershipWebhook) SyncSetLabelSelector() metav1.LabelSelector {
 	return utils.DefaultLabelSelector()
@@ -108,7 +108,7 @@ func TestThing(t *testing.T) {
 		{
 			testID:          "sre-test",
 			username:        "sre-foo@redhat.com",
-			userGroups:      []string{adminGroups[0], "system:authenticated", "system:authenticated:oauth"},
+			userGroups:      []string{"system:serviceaccounts:openshift-backplane-srep", "system:authenticated", "system:authenticated:oauth"},
 			operation:       admissionv1.Update,
 			shouldBeAllowed: true,
 		},
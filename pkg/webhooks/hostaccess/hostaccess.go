@@ -0,0 +1,256 @@
+package hostaccess
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	hookconfig "github.com/openshift/managed-cluster-validating-webhooks/pkg/config"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "hostaccess-validation"
+	docString   string = `Managed OpenShift customers may not run pods in customer namespaces using %s, unless SRE has labelled the namespace to allow that kind of host access with %s<kind>=true.`
+)
+
+var (
+	scope = admissionregv1.NamespacedScope
+	rules = []admissionregv1.RuleWithOperations{
+		{
+			// A pod's host access can't be changed once it's created
+			Operations: []admissionregv1.OperationType{admissionregv1.Create},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{""},
+				APIVersions: []string{"v1"},
+				Resources:   []string{"pods"},
+				Scope:       &scope,
+			},
+		},
+	}
+	log = logf.Log.WithName(WebhookName)
+)
+
+// HostAccessWebhook prevents pods using the host in customer namespaces
+type HostAccessWebhook struct {
+	s          *runtime.Scheme
+	decoder    admissionctl.Decoder
+	kubeClient client.Client
+}
+
+// NewWebhook creates a new webhook
+func NewWebhook() *HostAccessWebhook {
+	scheme, err := k8sutil.SharedScheme()
+	if err != nil {
+		log.Error(err, "Fail getting the shared scheme for HostAccessWebhook")
+		os.Exit(1)
+	}
+	decoder, err := k8sutil.SharedDecoder()
+	if err != nil {
+		log.Error(err, "Fail getting the shared decoder for HostAccessWebhook")
+		os.Exit(1)
+	}
+
+	return &HostAccessWebhook{
+		s:       scheme,
+		decoder: decoder,
+	}
+}
+
+// InjectClient implements ClientWebhook interface
+func (s *HostAccessWebhook) InjectClient(c client.Client) {
+	s.kubeClient = c
+}
+
+// CachedObjects implements ClientWebhook interface. Namespaces are read for
+// their host access labels.
+func (s *HostAccessWebhook) CachedObjects() []client.Object {
+	return []client.Object{&corev1.Namespace{}}
+}
+
+// Authorized implements Webhook interface
+func (s *HostAccessWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.AuthorizedContext(context.Background(), request)
+}
+
+// AuthorizedContext implements ContextWebhook interface
+func (s *HostAccessWebhook) AuthorizedContext(ctx context.Context, request admissionctl.Request) admissionctl.Response {
+	return s.authorized(ctx, request)
+}
+
+func (s *HostAccessWebhook) authorized(ctx context.Context, request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if s.ExcludedNamespaces().Excludes(request.Namespace) {
+		ret = admissionctl.Allowed("Pods in managed namespaces may use the host")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	// Pods created by controllers, such as for a customer's Deployment, are
+	// created by privileged service accounts, so only SRE and cluster admins
+	// are allowed
+	if identity.IsSRE(request.UserInfo) || identity.IsClusterAdmin(request.UserInfo) {
+		ret = admissionctl.Allowed("SRE and cluster admins may create pods using the host")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	pod, err := s.renderPod(request)
+	if err != nil {
+		log.Error(err, "Couldn't render a Pod from the incoming request")
+		ret = admissionctl.Errored(http.StatusBadRequest, err)
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	access := utils.PodHostAccess(pod)
+	if len(access) == 0 {
+		ret = admissionctl.Allowed("Pod does not use the host")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	ns, err := s.namespace(ctx, request.Namespace)
+	if err != nil {
+		// Fail open, as the webhook's FailurePolicy does
+		log.Error(err, "Failed to check namespace for host access exceptions", "namespace", request.Namespace)
+		ret = admissionctl.Allowed("Unable to check namespace for host access exceptions")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	if ns.Annotations[utils.PrivilegedSCCAnnotation] == "true" {
+		ret = admissionctl.Allowed("Namespace allows privileged pods")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	denied := []string{}
+	for _, kind := range access {
+		if !utils.HostAccessAllowed(ns.Labels, kind) {
+			denied = append(denied, kind)
+		}
+	}
+	if len(denied) == 0 {
+		ret = admissionctl.Allowed("Namespace allows the pod's host access")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	log.Info("Denying pod using the host", "namespace", request.Namespace, "user", request.UserInfo.Username, "access", denied)
+	ret = response.Denied(response.HostAccess, fmt.Sprintf("Prevented from creating a pod using %s in a customer namespace. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", strings.Join(denied, ", ")))
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// namespace reads the namespace the pod is created in
+func (s *HostAccessWebhook) namespace(ctx context.Context, name string) (*corev1.Namespace, error) {
+	if s.kubeClient == nil {
+		var err error
+		s.kubeClient, err = k8sutil.KubeClient(s.s)
+		if err != nil {
+			return nil, err
+		}
+	}
+	ns := &corev1.Namespace{}
+	if err := s.kubeClient.Get(ctx, client.ObjectKey{Name: name}, ns); err != nil {
+		return nil, err
+	}
+	return ns, nil
+}
+
+// renderPod renders the Pod in the admission Request
+func (s *HostAccessWebhook) renderPod(request admissionctl.Request) (*corev1.Pod, error) {
+	pod := &corev1.Pod{}
+	if err := s.decoder.DecodeRaw(request.Object, pod); err != nil {
+		return nil, err
+	}
+	return pod, nil
+}
+
+// GetURI implements Webhook interface
+func (s *HostAccessWebhook) GetURI() string { return "/" + WebhookName }
+
+// Validate implements Webhook interface
+func (s *HostAccessWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	valid = valid && (request.Kind.Kind == "Pod")
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *HostAccessWebhook) Name() string { return WebhookName }
+
+// FailurePolicy implements Webhook interface
+func (s *HostAccessWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *HostAccessWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *HostAccessWebhook) Rules() []admissionregv1.RuleWithOperations { return rules }
+
+// ExcludedNamespaces implements NamespaceExclusionWebhook interface
+func (s *HostAccessWebhook) ExcludedNamespaces() utils.NamespaceExclusion {
+	return hookconfig.ExcludedNamespaces
+}
+
+// DecisionTTL implements CacheableWebhook interface. Besides the pod,
+// decisions depend on the namespace's exceptions, which SRE rarely change.
+func (s *HostAccessWebhook) DecisionTTL() time.Duration { return 5 * time.Second }
+
+// ObjectSelector implements Webhook interface
+func (s *HostAccessWebhook) ObjectSelector() *metav1.LabelSelector { return nil }
+
+// SideEffects implements Webhook interface
+func (s *HostAccessWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *HostAccessWebhook) TimeoutSeconds() int32 { return 2 }
+
+// Doc implements Webhook interface
+func (s *HostAccessWebhook) Doc() string {
+	return fmt.Sprintf(docString, strings.Join(utils.HostAccessKinds, ", "), utils.HostAccessLabelPrefix)
+}
+
+// RuleDocs implements Webhook interface
+func (s *HostAccessWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers may not run pods in customer namespaces using the host network, PID or IPC namespaces, or hostPath volumes.",
+			Exceptions: []string{"Red Hat SRE and the cluster's built-in administrators", "Pods in managed namespaces", "Namespaces SRE have labelled to allow that kind of host access", "Namespaces SRE have annotated to allow privileged pods"},
+		},
+	}
+}
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+func (s *HostAccessWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// ClassicEnabled implements Webhook interface
+func (s *HostAccessWebhook) ClassicEnabled() bool { return true }
+
+// HypershiftEnabled implements Webhook interface
+func (s *HostAccessWebhook) HypershiftEnabled() bool { return true }
@@ -0,0 +1,148 @@
+package hostaccess
+
+import (
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+)
+
+func newRequest(t *testing.T, namespace, username string, groups []string, pod *corev1.Pod) admissionctl.Request {
+	t.Helper()
+	pod.Namespace = namespace
+	return testutils.NewRequest(t, metav1.GroupVersionKind{Version: "v1", Kind: "Pod"}, admissionv1.Create, authenticationv1.UserInfo{Username: username, Groups: groups}, namespace, "", pod, nil)
+}
+
+func TestAuthorized(t *testing.T) {
+	namespaces := []*corev1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "customer"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "node-exporter", Labels: map[string]string{
+			utils.HostAccessLabelPrefix + utils.HostNetwork: "true",
+			utils.HostAccessLabelPrefix + utils.HostPID:     "true",
+		}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "privileged", Annotations: map[string]string{utils.PrivilegedSCCAnnotation: "true"}}},
+	}
+	hostPath := corev1.Volume{Name: "host", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/var/log"}}}
+
+	tests := []struct {
+		name      string
+		namespace string
+		username  string
+		groups    []string
+		pod       *corev1.Pod
+		allowed   bool
+		message   string
+	}{
+		{
+			name:      "pod not using the host",
+			namespace: "customer",
+			username:  "customer",
+			pod:       &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}}},
+			allowed:   true,
+		},
+		{
+			name:      "host network",
+			namespace: "customer",
+			username:  "customer",
+			pod:       &corev1.Pod{Spec: corev1.PodSpec{HostNetwork: true}},
+			allowed:   false,
+			message:   "using hostNetwork",
+		},
+		{
+			name:      "host PID and IPC",
+			namespace: "customer",
+			username:  "customer",
+			pod:       &corev1.Pod{Spec: corev1.PodSpec{HostPID: true, HostIPC: true}},
+			allowed:   false,
+			message:   "using hostPID, hostIPC",
+		},
+		{
+			name:      "hostPath volume",
+			namespace: "customer",
+			username:  "customer",
+			pod:       &corev1.Pod{Spec: corev1.PodSpec{Volumes: []corev1.Volume{hostPath}}},
+			allowed:   false,
+			message:   "using hostPath",
+		},
+		{
+			name:      "host access allowed by the namespace's labels",
+			namespace: "node-exporter",
+			username:  "system:serviceaccount:kube-system:daemon-set-controller",
+			groups:    []string{"system:serviceaccounts", "system:serviceaccounts:kube-system"},
+			pod:       &corev1.Pod{Spec: corev1.PodSpec{HostNetwork: true, HostPID: true}},
+			allowed:   true,
+		},
+		{
+			name:      "host access not allowed by the namespace's labels",
+			namespace: "node-exporter",
+			username:  "customer",
+			pod:       &corev1.Pod{Spec: corev1.PodSpec{HostNetwork: true, Volumes: []corev1.Volume{hostPath}}},
+			allowed:   false,
+			message:   "using hostPath in",
+		},
+		{
+			name:      "controller creating a customer's pod",
+			namespace: "customer",
+			username:  "system:serviceaccount:kube-system:replicaset-controller",
+			groups:    []string{"system:serviceaccounts", "system:serviceaccounts:kube-system"},
+			pod:       &corev1.Pod{Spec: corev1.PodSpec{HostNetwork: true}},
+			allowed:   false,
+		},
+		{
+			name:      "namespace allowing privileged pods",
+			namespace: "privileged",
+			username:  "customer",
+			pod:       &corev1.Pod{Spec: corev1.PodSpec{HostNetwork: true, Volumes: []corev1.Volume{hostPath}}},
+			allowed:   true,
+		},
+		{
+			name:      "managed namespace",
+			namespace: "openshift-monitoring",
+			username:  "system:serviceaccount:kube-system:daemon-set-controller",
+			pod:       &corev1.Pod{Spec: corev1.PodSpec{HostNetwork: true}},
+			allowed:   true,
+		},
+		{
+			name:      "sre",
+			namespace: "customer",
+			username:  "backplane-cluster-admin",
+			pod:       &corev1.Pod{Spec: corev1.PodSpec{HostNetwork: true}},
+			allowed:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			builder := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme)
+			for _, ns := range namespaces {
+				builder = builder.WithObjects(ns.DeepCopy())
+			}
+			hook := NewWebhook()
+			hook.InjectClient(builder.Build())
+
+			request := newRequest(t, test.namespace, test.username, test.groups, test.pod)
+			if !hook.Validate(request) {
+				t.Fatalf("Expected request to be valid")
+			}
+			response := hook.Authorized(request)
+			if response.Allowed != test.allowed {
+				t.Fatalf("Expected allowed %v, got %v: %v", test.allowed, response.Allowed, response.Result)
+			}
+			if response.UID != request.UID {
+				t.Fatalf("Expected UID %s, got %s", request.UID, response.UID)
+			}
+			if test.message != "" && !strings.Contains(response.Result.Message, test.message) {
+				t.Errorf("Expected the message to contain %q, got %s", test.message, response.Result.Message)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"sync"
 
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
 	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionregv1 "k8s.io/api/admissionregistration/v1"
@@ -51,6 +52,15 @@ func (s *HostedClusterWebhook) Doc() string {
 	return fmt.Sprintf(docString)
 }
 
+// RuleDocs implements Webhook interface
+func (s *HostedClusterWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary: "Only the klusterlet work service account may delete HostedClusters.",
+		},
+	}
+}
+
 // TimeoutSeconds implements Webhook interface
 func (s *HostedClusterWebhook) TimeoutSeconds() int32 { return 2 }
 
@@ -116,7 +126,7 @@ func (s *HostedClusterWebhook) authorized(request admissionctl.Request) admissio
 		"user", request.UserInfo.Username,
 		"groups", request.UserInfo.Groups)
 
-	ret = admissionctl.Denied(fmt.Sprintf("Only %s is authorized to delete HostedCluster resources", allowedServiceAccount))
+	ret = response.Denied(response.HostedClusterDeletion, fmt.Sprintf("Only %s is authorized to delete HostedCluster resources", allowedServiceAccount))
 	ret.UID = request.AdmissionRequest.UID
 	return ret
 }
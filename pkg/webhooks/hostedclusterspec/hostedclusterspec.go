@@ -0,0 +1,311 @@
+package hostedclusterspec
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "hostedclusterspec-validation"
+	docString   string = `Managed OpenShift management clusters run the control planes of hosted clusters. HostedClusters and NodePools created or changed on them must use release images from the managed release repositories, HostedClusters may not change their AWS endpoint access once created, and NodePools must keep at least %d replica(s).`
+
+	hostedClusterKind string = "HostedCluster"
+	nodePoolKind      string = "NodePool"
+)
+
+var (
+	// ReleaseRepositories are the repositories of the release streams hosted
+	// clusters may run
+	ReleaseRepositories = []string{
+		"quay.io/openshift-release-dev/ocp-release",
+	}
+
+	// EndpointAccesses are the AWS endpoint accesses a HostedCluster may have
+	EndpointAccesses = []string{"Public", "PublicAndPrivate", "Private"}
+
+	// MinNodePoolReplicas is the fewest nodes a NodePool may have, whether
+	// its replicas are set or autoscaled
+	MinNodePoolReplicas int32 = 1
+
+	scope = admissionregv1.NamespacedScope
+	rules = []admissionregv1.RuleWithOperations{
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Create, admissionregv1.Update},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{"hypershift.openshift.io"},
+				APIVersions: []string{"*"},
+				Resources:   []string{"hostedclusters", "nodepools"},
+				Scope:       &scope,
+			},
+		},
+	}
+	log = logf.Log.WithName(WebhookName)
+)
+
+// hostedCluster has the fields of a HostedCluster the webhook checks
+type hostedCluster struct {
+	Spec struct {
+		Release  release `json:"release"`
+		Platform struct {
+			AWS *struct {
+				EndpointAccess string `json:"endpointAccess"`
+			} `json:"aws,omitempty"`
+		} `json:"platform"`
+	} `json:"spec"`
+}
+
+// nodePool has the fields of a NodePool the webhook checks
+type nodePool struct {
+	Spec struct {
+		Release     release `json:"release"`
+		Replicas    *int32  `json:"replicas,omitempty"`
+		AutoScaling *struct {
+			Min int32 `json:"min"`
+			Max int32 `json:"max"`
+		} `json:"autoScaling,omitempty"`
+	} `json:"spec"`
+}
+
+type release struct {
+	Image string `json:"image"`
+}
+
+// HostedClusterSpecWebhook enforces the managed invariants of HostedClusters
+// and NodePools on management clusters
+type HostedClusterSpecWebhook struct{}
+
+// NewWebhook creates a new webhook
+func NewWebhook() *HostedClusterSpecWebhook {
+	return &HostedClusterSpecWebhook{}
+}
+
+// Authorized implements Webhook interface
+func (s *HostedClusterSpecWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.authorized(request)
+}
+
+// authorized checks the invariants the request changes. Invariants left
+// unchanged by an update aren't checked, so objects predating the webhook can
+// still be updated.
+func (s *HostedClusterSpecWebhook) authorized(request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if identity.IsSRE(request.UserInfo) {
+		ret = admissionctl.Allowed("SRE may change hosted clusters")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	var violations []string
+	var err error
+	switch request.Kind.Kind {
+	case hostedClusterKind:
+		violations, err = checkHostedCluster(request)
+	case nodePoolKind:
+		violations, err = checkNodePool(request)
+	}
+	if err != nil {
+		log.Error(err, "Couldn't render the object from the incoming request", "kind", request.Kind.Kind)
+		ret = admissionctl.Errored(http.StatusBadRequest, err)
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	if len(violations) > 0 {
+		log.Info("Denying change breaking hosted cluster invariants", "kind", request.Kind.Kind, "namespace", request.Namespace, "name", request.Name, "user", request.UserInfo.Username, "violations", violations)
+		ret = response.Denied(response.HostedClusterInvariant, fmt.Sprintf("Prevented from changing %s %s in namespace %s: %s. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", request.Kind.Kind, request.Name, request.Namespace, strings.Join(violations, "; ")))
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	ret = admissionctl.Allowed("Hosted cluster invariants hold")
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// checkHostedCluster returns the invariants the HostedCluster request breaks
+func checkHostedCluster(request admissionctl.Request) ([]string, error) {
+	hc, old := &hostedCluster{}, &hostedCluster{}
+	if err := decode(request, hc, old); err != nil {
+		return nil, err
+	}
+	creating := request.Operation == admissionv1.Create
+
+	violations := []string{}
+	if creating || hc.Spec.Release.Image != old.Spec.Release.Image {
+		if v := checkRelease(hc.Spec.Release.Image); v != "" {
+			violations = append(violations, v)
+		}
+	}
+	access, oldAccess := "", ""
+	if hc.Spec.Platform.AWS != nil {
+		access = hc.Spec.Platform.AWS.EndpointAccess
+	}
+	if old.Spec.Platform.AWS != nil {
+		oldAccess = old.Spec.Platform.AWS.EndpointAccess
+	}
+	switch {
+	case !creating && access != oldAccess:
+		violations = append(violations, fmt.Sprintf("the AWS endpoint access can't change from %q to %q once the cluster is created", oldAccess, access))
+	case creating && access != "" && !slices.Contains(EndpointAccesses, access):
+		violations = append(violations, fmt.Sprintf("the AWS endpoint access %q isn't one of %s", access, strings.Join(EndpointAccesses, ", ")))
+	}
+	return violations, nil
+}
+
+// checkNodePool returns the invariants the NodePool request breaks
+func checkNodePool(request admissionctl.Request) ([]string, error) {
+	np, old := &nodePool{}, &nodePool{}
+	if err := decode(request, np, old); err != nil {
+		return nil, err
+	}
+	creating := request.Operation == admissionv1.Create
+
+	violations := []string{}
+	if creating || np.Spec.Release.Image != old.Spec.Release.Image {
+		if v := checkRelease(np.Spec.Release.Image); v != "" {
+			violations = append(violations, v)
+		}
+	}
+	if autoScaling := np.Spec.AutoScaling; autoScaling != nil {
+		if (creating || old.Spec.AutoScaling == nil || autoScaling.Min != old.Spec.AutoScaling.Min) && autoScaling.Min < MinNodePoolReplicas {
+			violations = append(violations, fmt.Sprintf("the autoscaling minimum %d is below %d", autoScaling.Min, MinNodePoolReplicas))
+		}
+	} else if replicas := np.Spec.Replicas; replicas != nil {
+		if (creating || old.Spec.Replicas == nil || *replicas != *old.Spec.Replicas) && *replicas < MinNodePoolReplicas {
+			violations = append(violations, fmt.Sprintf("%d replicas is below %d", *replicas, MinNodePoolReplicas))
+		}
+	}
+	return violations, nil
+}
+
+// checkRelease returns why the release image isn't from a managed release
+// stream, or "" if it is. An unset image is left to HyperShift to reject.
+func checkRelease(image string) string {
+	if image == "" {
+		return ""
+	}
+	if slices.Contains(ReleaseRepositories, repository(image)) {
+		return ""
+	}
+	return fmt.Sprintf("the release image %s isn't from %s", image, strings.Join(ReleaseRepositories, " or "))
+}
+
+// repository returns the image reference without its tag or digest
+func repository(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	return image
+}
+
+// decode renders the request's object, and for updates its old object
+func decode(request admissionctl.Request, obj, old interface{}) error {
+	if err := json.Unmarshal(request.Object.Raw, obj); err != nil {
+		return err
+	}
+	if request.Operation == admissionv1.Update && len(request.OldObject.Raw) > 0 {
+		return json.Unmarshal(request.OldObject.Raw, old)
+	}
+	return nil
+}
+
+// GetURI implements Webhook interface
+func (s *HostedClusterSpecWebhook) GetURI() string { return "/" + WebhookName }
+
+// Validate implements Webhook interface
+func (s *HostedClusterSpecWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	valid = valid && (request.Kind.Group == "hypershift.openshift.io")
+	valid = valid && (request.Kind.Kind == hostedClusterKind || request.Kind.Kind == nodePoolKind)
+	valid = valid && (request.Operation == admissionv1.Create || request.Operation == admissionv1.Update)
+	valid = valid && (len(request.Object.Raw) > 0)
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *HostedClusterSpecWebhook) Name() string { return WebhookName }
+
+// FailurePolicy implements Webhook interface
+func (s *HostedClusterSpecWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *HostedClusterSpecWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *HostedClusterSpecWebhook) Rules() []admissionregv1.RuleWithOperations { return rules }
+
+// ObjectSelector implements Webhook interface
+func (s *HostedClusterSpecWebhook) ObjectSelector() *metav1.LabelSelector { return nil }
+
+// SideEffects implements Webhook interface
+func (s *HostedClusterSpecWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *HostedClusterSpecWebhook) TimeoutSeconds() int32 { return 2 }
+
+// Doc implements Webhook interface
+func (s *HostedClusterSpecWebhook) Doc() string {
+	return fmt.Sprintf(docString, MinNodePoolReplicas)
+}
+
+// RuleDocs implements Webhook interface
+func (s *HostedClusterSpecWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    fmt.Sprintf("HostedClusters and NodePools created or upgraded on management clusters must use release images from %s.", strings.Join(ReleaseRepositories, " or ")),
+			Exceptions: []string{utils.SREException},
+		},
+		{
+			Summary:    "The AWS endpoint access of HostedClusters may not change once they're created.",
+			Exceptions: []string{utils.SREException},
+		},
+		{
+			Summary:    fmt.Sprintf("NodePools may not be scaled, or autoscaled, below %d replica(s).", MinNodePoolReplicas),
+			Exceptions: []string{utils.SREException},
+		},
+	}
+}
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet. The
+// webhook is only deployed to management clusters, which run HostedClusters.
+func (s *HostedClusterSpecWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	customLabelSelector := utils.DefaultLabelSelector()
+	customLabelSelector.MatchExpressions = append(customLabelSelector.MatchExpressions,
+		metav1.LabelSelectorRequirement{
+			Key:      "ext-hypershift.openshift.io/cluster-type",
+			Operator: metav1.LabelSelectorOpIn,
+			Values:   []string{"management-cluster"},
+		})
+	return customLabelSelector
+}
+
+// ClassicEnabled implements Webhook interface. Management clusters are
+// Classic clusters.
+func (s *HostedClusterSpecWebhook) ClassicEnabled() bool { return true }
+
+// HypershiftEnabled implements Webhook interface
+func (s *HostedClusterSpecWebhook) HypershiftEnabled() bool { return false }
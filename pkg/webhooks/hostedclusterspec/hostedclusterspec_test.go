@@ -0,0 +1,175 @@
+package hostedclusterspec
+
+import (
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+)
+
+const (
+	release419 = "quay.io/openshift-release-dev/ocp-release:4.19.3-multi"
+	release420 = "quay.io/openshift-release-dev/ocp-release@sha256:0123456789abcdef"
+	ciRelease  = "registry.ci.openshift.org/ocp/release:4.20.0-0.nightly"
+)
+
+func hostedClusterJSON(image, endpointAccess string) string {
+	return `{"apiVersion":"hypershift.openshift.io/v1beta1","kind":"HostedCluster","metadata":{"name":"hc","namespace":"ocm-production-abc"},"spec":{"release":{"image":"` + image + `"},"platform":{"type":"AWS","aws":{"endpointAccess":"` + endpointAccess + `"}}}}`
+}
+
+func nodePoolJSON(image, scaling string) string {
+	return `{"apiVersion":"hypershift.openshift.io/v1beta1","kind":"NodePool","metadata":{"name":"workers","namespace":"ocm-production-abc"},"spec":{"release":{"image":"` + image + `"},` + scaling + `}}`
+}
+
+func TestAuthorized(t *testing.T) {
+	tests := []struct {
+		name      string
+		kind      string
+		username  string
+		operation admissionv1.Operation
+		object    string
+		old       string
+		allowed   bool
+		message   string
+	}{
+		{
+			name:      "create hosted cluster",
+			kind:      hostedClusterKind,
+			username:  "system:serviceaccount:open-cluster-management-agent:klusterlet-work-sa",
+			operation: admissionv1.Create,
+			object:    hostedClusterJSON(release419, "Private"),
+			allowed:   true,
+		},
+		{
+			name:      "create hosted cluster from unmanaged release",
+			kind:      hostedClusterKind,
+			username:  "system:serviceaccount:open-cluster-management-agent:klusterlet-work-sa",
+			operation: admissionv1.Create,
+			object:    hostedClusterJSON(ciRelease, "Private"),
+			allowed:   false,
+			message:   "the release image " + ciRelease + " isn't from quay.io/openshift-release-dev/ocp-release",
+		},
+		{
+			name:      "create hosted cluster with unknown endpoint access",
+			kind:      hostedClusterKind,
+			username:  "system:serviceaccount:open-cluster-management-agent:klusterlet-work-sa",
+			operation: admissionv1.Create,
+			object:    hostedClusterJSON(release419, "Internal"),
+			allowed:   false,
+			message:   `the AWS endpoint access "Internal" isn't one of Public, PublicAndPrivate, Private`,
+		},
+		{
+			name:      "upgrade hosted cluster by digest",
+			kind:      hostedClusterKind,
+			username:  "system:serviceaccount:open-cluster-management-agent:klusterlet-work-sa",
+			operation: admissionv1.Update,
+			old:       hostedClusterJSON(release419, "Private"),
+			object:    hostedClusterJSON(release420, "Private"),
+			allowed:   true,
+		},
+		{
+			name:      "change endpoint access",
+			kind:      hostedClusterKind,
+			username:  "system:serviceaccount:open-cluster-management-agent:klusterlet-work-sa",
+			operation: admissionv1.Update,
+			old:       hostedClusterJSON(release419, "Private"),
+			object:    hostedClusterJSON(release419, "Public"),
+			allowed:   false,
+			message:   `the AWS endpoint access can't change from "Private" to "Public"`,
+		},
+		{
+			name:      "update hosted cluster predating the webhook",
+			kind:      hostedClusterKind,
+			username:  "system:serviceaccount:hypershift:operator",
+			operation: admissionv1.Update,
+			old:       hostedClusterJSON(ciRelease, "Private"),
+			object:    hostedClusterJSON(ciRelease, "Private"),
+			allowed:   true,
+		},
+		{
+			name:      "sre changes endpoint access",
+			kind:      hostedClusterKind,
+			username:  "backplane-cluster-admin",
+			operation: admissionv1.Update,
+			old:       hostedClusterJSON(release419, "Private"),
+			object:    hostedClusterJSON(release419, "PublicAndPrivate"),
+			allowed:   true,
+		},
+		{
+			name:      "scale node pool to zero",
+			kind:      nodePoolKind,
+			username:  "system:serviceaccount:open-cluster-management-agent:klusterlet-work-sa",
+			operation: admissionv1.Update,
+			old:       nodePoolJSON(release419, `"replicas":2`),
+			object:    nodePoolJSON(release419, `"replicas":0`),
+			allowed:   false,
+			message:   "0 replicas is below 1",
+		},
+		{
+			name:      "autoscale node pool from zero",
+			kind:      nodePoolKind,
+			username:  "system:serviceaccount:open-cluster-management-agent:klusterlet-work-sa",
+			operation: admissionv1.Create,
+			object:    nodePoolJSON(release419, `"autoScaling":{"min":0,"max":3}`),
+			allowed:   false,
+			message:   "the autoscaling minimum 0 is below 1",
+		},
+		{
+			name:      "create node pool",
+			kind:      nodePoolKind,
+			username:  "system:serviceaccount:open-cluster-management-agent:klusterlet-work-sa",
+			operation: admissionv1.Create,
+			object:    nodePoolJSON(release419, `"replicas":2`),
+			allowed:   true,
+		},
+		{
+			name:      "upgrade node pool to unmanaged release",
+			kind:      nodePoolKind,
+			username:  "system:serviceaccount:open-cluster-management-agent:klusterlet-work-sa",
+			operation: admissionv1.Update,
+			old:       nodePoolJSON(release419, `"replicas":2`),
+			object:    nodePoolJSON(ciRelease, `"replicas":2`),
+			allowed:   false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hook := NewWebhook()
+			gvk := metav1.GroupVersionKind{Group: "hypershift.openshift.io", Version: "v1beta1", Kind: test.kind}
+			request := testutils.NewRequest(t, gvk, test.operation, authenticationv1.UserInfo{Username: test.username}, "ocm-production-abc", "", []byte(test.object), []byte(test.old))
+			if !hook.Validate(request) {
+				t.Fatalf("Expected request to be valid")
+			}
+			response := hook.Authorized(request)
+			if response.Allowed != test.allowed {
+				t.Fatalf("Expected allowed %v, got %v: %v", test.allowed, response.Allowed, response.Result)
+			}
+			if response.UID != request.UID {
+				t.Fatalf("Expected UID %s, got %s", request.UID, response.UID)
+			}
+			if test.message != "" && !strings.Contains(response.Result.Message, test.message) {
+				t.Errorf("Expected the message to contain %q, got %s", test.message, response.Result.Message)
+			}
+		})
+	}
+}
+
+func TestRepository(t *testing.T) {
+	tests := map[string]string{
+		release419:                        "quay.io/openshift-release-dev/ocp-release",
+		release420:                        "quay.io/openshift-release-dev/ocp-release",
+		"registry:5000/ocp/release":       "registry:5000/ocp/release",
+		"registry:5000/ocp/release:4.19":  "registry:5000/ocp/release",
+		"quay.io/openshift-release-dev/x": "quay.io/openshift-release-dev/x",
+	}
+	for image, expected := range tests {
+		if actual := repository(image); actual != expected {
+			t.Errorf("Expected the repository of %s to be %s, got %s", image, expected, actual)
+		}
+	}
+}
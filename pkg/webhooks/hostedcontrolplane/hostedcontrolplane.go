@@ -6,6 +6,7 @@ import (
 	"slices"
 	"strings"
 
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
 	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionregv1 "k8s.io/api/admissionregistration/v1"
@@ -60,6 +61,16 @@ func (s *HostedControlPlaneWebhook) Doc() string {
 	return fmt.Sprintf(docString)
 }
 
+// RuleDocs implements Webhook interface
+func (s *HostedControlPlaneWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Only authorized service accounts may delete HostedControlPlanes.",
+			Exceptions: []string{"The HyperShift operator, cluster-api, control-plane-pki-operator, klusterlet and garbage collector service accounts"},
+		},
+	}
+}
+
 // TimeoutSeconds implements Webhook interface
 func (s *HostedControlPlaneWebhook) TimeoutSeconds() int32 { return 2 }
 
@@ -134,7 +145,7 @@ func (s *HostedControlPlaneWebhook) authorized(request admissionctl.Request) adm
 		"user", request.UserInfo.Username,
 		"groups", request.UserInfo.Groups)
 
-	ret = admissionctl.Denied(fmt.Sprintf("Only authorized service accounts %s can delete HostedControlPlane resources", strings.Join(append(allowedServiceAccountsUsernames, allowedServiceAccountsNames...), ", ")))
+	ret = response.Denied(response.HostedClusterDeletion, fmt.Sprintf("Only authorized service accounts %s can delete HostedControlPlane resources", strings.Join(append(allowedServiceAccountsUsernames, allowedServiceAccountsNames...), ", ")))
 	ret.UID = request.AdmissionRequest.UID
 	return ret
 
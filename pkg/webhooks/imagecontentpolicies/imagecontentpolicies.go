@@ -7,6 +7,7 @@ import (
 	"github.com/go-logr/logr"
 	configv1 "github.com/openshift/api/config/v1"
 	operatorv1alpha1 "github.com/openshift/api/operator/v1alpha1"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
 	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
 	admissionregv1 "k8s.io/api/admissionregistration/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -24,6 +25,8 @@ const (
 	unauthorizedRepositoryMirrors = `(^registry\.redhat\.io$|^quay\.io(/.*)?$|^registry\.access\.redhat\.com(/.*)?)`
 )
 
+var unauthorizedRepositoryMirrorsRe = regexp.MustCompile(unauthorizedRepositoryMirrors)
+
 type ImageContentPoliciesWebhook struct {
 	scheme *runtime.Scheme
 	log    logr.Logger
@@ -49,7 +52,9 @@ func (w *ImageContentPoliciesWebhook) Authorized(request admission.Request) admi
 
 		if !authorizeImageDigestMirrorSet(idms) {
 			w.log.Info("denying ImageDigestMirrorSet", "name", idms.Name)
-			return utils.WebhookResponse(request, false, WebhookDoc)
+			ret := response.Denied(response.ImageMirrorConflict, WebhookDoc)
+			ret.UID = request.AdmissionRequest.UID
+			return ret
 		}
 	case "ImageTagMirrorSet":
 		itms := configv1.ImageTagMirrorSet{}
@@ -60,7 +65,9 @@ func (w *ImageContentPoliciesWebhook) Authorized(request admission.Request) admi
 
 		if !authorizeImageTagMirrorSet(itms) {
 			w.log.Info("denying ImageTagMirrorSet", "name", itms.Name)
-			return utils.WebhookResponse(request, false, WebhookDoc)
+			ret := response.Denied(response.ImageMirrorConflict, WebhookDoc)
+			ret.UID = request.AdmissionRequest.UID
+			return ret
 		}
 	case "ImageContentSourcePolicy":
 		icsp := operatorv1alpha1.ImageContentSourcePolicy{}
@@ -71,7 +78,9 @@ func (w *ImageContentPoliciesWebhook) Authorized(request admission.Request) admi
 
 		if !authorizeImageContentSourcePolicy(icsp) {
 			w.log.Info("denying ImageContentSourcePolicy", "name", icsp.Name)
-			return utils.WebhookResponse(request, false, WebhookDoc)
+			ret := response.Denied(response.ImageMirrorConflict, WebhookDoc)
+			ret.UID = request.AdmissionRequest.UID
+			return ret
 		}
 	}
 
@@ -158,6 +167,15 @@ func (w *ImageContentPoliciesWebhook) Doc() string {
 	return WebhookDoc
 }
 
+// RuleDocs implements Webhook interface
+func (w *ImageContentPoliciesWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary: "Customers may not create ImageContentSourcePolicies, ImageDigestMirrorSets or ImageTagMirrorSets mirroring quay.io, registry.redhat.io or registry.access.redhat.com.",
+		},
+	}
+}
+
 func (w *ImageContentPoliciesWebhook) SyncSetLabelSelector() metav1.LabelSelector {
 	return utils.DefaultLabelSelector()
 }
@@ -172,9 +190,8 @@ func (w *ImageContentPoliciesWebhook) HypershiftEnabled() bool {
 
 // authorizeImageDigestMirrorSet should reject an ImageDigestMirrorSet that matches an unauthorized mirror list
 func authorizeImageDigestMirrorSet(idms configv1.ImageDigestMirrorSet) bool {
-	unauthorizedRepositoryMirrorsRe := regexp.MustCompile(unauthorizedRepositoryMirrors)
 	for _, mirror := range idms.Spec.ImageDigestMirrors {
-		if unauthorizedRepositoryMirrorsRe.Match([]byte(mirror.Source)) {
+		if unauthorizedRepositoryMirrorsRe.MatchString(mirror.Source) {
 			return false
 		}
 	}
@@ -184,9 +201,8 @@ func authorizeImageDigestMirrorSet(idms configv1.ImageDigestMirrorSet) bool {
 
 // authorizeImageTagMirrorSet should reject an ImageTagMirrorSet that matches an unauthorized mirror list
 func authorizeImageTagMirrorSet(itms configv1.ImageTagMirrorSet) bool {
-	unauthorizedRepositoryMirrorsRe := regexp.MustCompile(unauthorizedRepositoryMirrors)
 	for _, mirror := range itms.Spec.ImageTagMirrors {
-		if unauthorizedRepositoryMirrorsRe.Match([]byte(mirror.Source)) {
+		if unauthorizedRepositoryMirrorsRe.MatchString(mirror.Source) {
 			return false
 		}
 	}
@@ -196,9 +212,8 @@ func authorizeImageTagMirrorSet(itms configv1.ImageTagMirrorSet) bool {
 
 // authorizeImageContentSourcePolicy should reject an ImageContentSourcePolicy that matches an unauthorized mirror list
 func authorizeImageContentSourcePolicy(icsp operatorv1alpha1.ImageContentSourcePolicy) bool {
-	unauthorizedRepositoryMirrorsRe := regexp.MustCompile(unauthorizedRepositoryMirrors)
 	for _, mirror := range icsp.Spec.RepositoryDigestMirrors {
-		if unauthorizedRepositoryMirrorsRe.Match([]byte(mirror.Source)) {
+		if unauthorizedRepositoryMirrorsRe.MatchString(mirror.Source) {
 			return false
 		}
 	}
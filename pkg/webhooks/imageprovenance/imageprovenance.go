@@ -0,0 +1,441 @@
+package imageprovenance
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	hookconfig "github.com/openshift/managed-cluster-validating-webhooks/pkg/config"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "imageprovenance-validation"
+	docString   string = `Managed OpenShift customers may restrict the images of pods in their namespaces labelled %s=%s to the registries they list in the %s key of the %s/%s ConfigMap. The policy is opt-in, and only enforced on clusters which enable the webhook.`
+
+	// EnforceLabel opts a customer namespace into the policy when set to
+	// EnforceValue
+	EnforceLabel string = "managed.openshift.io/image-provenance"
+	EnforceValue string = "enforce"
+
+	// AllowlistNamespace and AllowlistName are the ConfigMap customers list
+	// their registries in, one per line or comma-separated. An entry allows
+	// images from a registry, eg quay.io, or from a repository path below it,
+	// eg quay.io/acme.
+	AllowlistNamespace string = "openshift-config"
+	AllowlistName      string = "image-provenance-allowlist"
+	registriesKey      string = "registries"
+
+	// dockerHub is the registry of image references without one
+	dockerHub string = "docker.io"
+)
+
+var (
+	scope = admissionregv1.NamespacedScope
+	rules = []admissionregv1.RuleWithOperations{
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Create, admissionregv1.Update},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{""},
+				APIVersions: []string{"v1"},
+				Resources:   []string{"pods"},
+				Scope:       &scope,
+			},
+		},
+	}
+	log = logf.Log.WithName(WebhookName)
+
+	// allowlistTTL is how long a read allowlist is trusted before the
+	// ConfigMap is read again
+	allowlistTTL = 10 * time.Second
+	// allowlist is shared by every ImageProvenanceWebhook because the
+	// dispatcher builds a new webhook for each request.
+	allowlist = &allowlistCache{}
+)
+
+// allowlistCache holds the most recently read allowlist so pods created in
+// bursts, such as by a scale up, don't each GET the ConfigMap
+type allowlistCache struct {
+	mu         sync.Mutex
+	registries []string
+	found      bool
+	expires    time.Time
+}
+
+// get returns the cached allowlist, whether the ConfigMap was found, and
+// whether it is still fresh
+func (c *allowlistCache) get(now time.Time) ([]string, bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Before(c.expires) {
+		return c.registries, c.found, true
+	}
+	return nil, false, false
+}
+
+// set stores the allowlist, valid for allowlistTTL from now
+func (c *allowlistCache) set(registries []string, found bool, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.registries = registries
+	c.found = found
+	c.expires = now.Add(allowlistTTL)
+}
+
+// ImageProvenanceWebhook restricts the images of pods in the customer
+// namespaces enforcing image provenance to the customer's allowlisted
+// registries
+type ImageProvenanceWebhook struct {
+	s          *runtime.Scheme
+	decoder    admissionctl.Decoder
+	kubeClient client.Client
+}
+
+// NewWebhook creates a new webhook
+func NewWebhook() *ImageProvenanceWebhook {
+	scheme, err := k8sutil.SharedScheme()
+	if err != nil {
+		log.Error(err, "Fail getting the shared scheme for ImageProvenanceWebhook")
+		os.Exit(1)
+	}
+	decoder, err := k8sutil.SharedDecoder()
+	if err != nil {
+		log.Error(err, "Fail getting the shared decoder for ImageProvenanceWebhook")
+		os.Exit(1)
+	}
+
+	return &ImageProvenanceWebhook{
+		s:       scheme,
+		decoder: decoder,
+	}
+}
+
+// InjectClient implements ClientWebhook interface
+func (s *ImageProvenanceWebhook) InjectClient(c client.Client) {
+	s.kubeClient = c
+}
+
+// CachedObjects implements ClientWebhook interface. Namespaces are read for
+// their enforcement label. The allowlist is a single ConfigMap, cached for
+// allowlistTTL rather than by an informer over every ConfigMap.
+func (s *ImageProvenanceWebhook) CachedObjects() []client.Object {
+	return []client.Object{&corev1.Namespace{}}
+}
+
+// Authorized implements Webhook interface
+func (s *ImageProvenanceWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.AuthorizedContext(context.Background(), request)
+}
+
+// AuthorizedContext implements ContextWebhook interface
+func (s *ImageProvenanceWebhook) AuthorizedContext(ctx context.Context, request admissionctl.Request) admissionctl.Response {
+	return s.authorized(ctx, request)
+}
+
+func (s *ImageProvenanceWebhook) authorized(ctx context.Context, request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if s.ExcludedNamespaces().Excludes(request.Namespace) {
+		ret = admissionctl.Allowed("Image provenance is not enforced in managed namespaces")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	// The policy is the customer's, so cluster admins and the controllers
+	// creating pods for them are held to it too
+	if identity.IsSRE(request.UserInfo) {
+		ret = admissionctl.Allowed("SRE may run images from any registry")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	ns, err := s.namespace(ctx, request.Namespace)
+	if err != nil {
+		// Fail open, as the webhook's FailurePolicy does
+		log.Error(err, "Failed to check namespace for image provenance enforcement", "namespace", request.Namespace)
+		ret = admissionctl.Allowed("Unable to check namespace for image provenance enforcement")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	if ns.Labels[EnforceLabel] != EnforceValue {
+		ret = admissionctl.Allowed("Namespace does not enforce image provenance")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	images, err := s.newImages(request)
+	if err != nil {
+		log.Error(err, "Couldn't render a Pod from the incoming request")
+		ret = admissionctl.Errored(http.StatusBadRequest, err)
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	if len(images) == 0 {
+		ret = admissionctl.Allowed("Pod does not add any images")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	registries, found, err := s.allowlist(ctx)
+	if apierrors.IsForbidden(err) {
+		// Unlike a missing allowlist, which denies every image, this means
+		// the webhook's RBAC is broken and the policy isn't enforced at all
+		log.Error(err, "Not permitted to read the image provenance allowlist, image provenance is not enforced", "configmap", AllowlistNamespace+"/"+AllowlistName)
+		ret = admissionctl.Allowed("Not permitted to read the image provenance allowlist")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	if err != nil {
+		log.Error(err, "Failed to read the image provenance allowlist")
+		ret = admissionctl.Allowed("Unable to read the image provenance allowlist")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	denied := []string{}
+	for _, image := range images {
+		if !allowed(registries, image) {
+			denied = append(denied, image)
+		}
+	}
+	if len(denied) == 0 {
+		ret = admissionctl.Allowed("Pod only uses images from allowlisted registries")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	hint := fmt.Sprintf("The namespace is labelled %s=%s, which only allows images from the registries listed in the %s key of the %s/%s ConfigMap", EnforceLabel, EnforceValue, registriesKey, AllowlistNamespace, AllowlistName)
+	if !found {
+		hint = fmt.Sprintf("The namespace is labelled %s=%s, but no registries are allowlisted: the %s/%s ConfigMap doesn't exist", EnforceLabel, EnforceValue, AllowlistNamespace, AllowlistName)
+	}
+	log.Info("Denying pod using images from registries which aren't allowlisted", "namespace", request.Namespace, "user", request.UserInfo.Username, "images", denied)
+	ret = response.Denied(response.ImageProvenance, fmt.Sprintf("Prevented from running %s in namespace %s. %s. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", strings.Join(denied, ", "), request.Namespace, hint))
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// newImages returns the images of the pod in the request. Images a pod
+// already had when it's updated were checked when they were added, so
+// shrinking the allowlist doesn't prevent updating running pods.
+func (s *ImageProvenanceWebhook) newImages(request admissionctl.Request) ([]string, error) {
+	pod := &corev1.Pod{}
+	if err := s.decoder.DecodeRaw(request.Object, pod); err != nil {
+		return nil, err
+	}
+	previous := []string{}
+	if request.Operation == admissionv1.Update {
+		oldPod := &corev1.Pod{}
+		if err := s.decoder.DecodeRaw(request.OldObject, oldPod); err != nil {
+			return nil, err
+		}
+		previous = podImages(oldPod)
+	}
+
+	images := []string{}
+	for _, image := range podImages(pod) {
+		if !slices.Contains(previous, image) && !slices.Contains(images, image) {
+			images = append(images, image)
+		}
+	}
+	return images, nil
+}
+
+// podImages returns the images of every container of the pod
+func podImages(pod *corev1.Pod) []string {
+	images := []string{}
+	for _, c := range pod.Spec.InitContainers {
+		images = append(images, c.Image)
+	}
+	for _, c := range pod.Spec.Containers {
+		images = append(images, c.Image)
+	}
+	for _, c := range pod.Spec.EphemeralContainers {
+		images = append(images, c.Image)
+	}
+	return images
+}
+
+// namespace reads the namespace the pod is created in
+func (s *ImageProvenanceWebhook) namespace(ctx context.Context, name string) (*corev1.Namespace, error) {
+	if err := s.ensureClient(); err != nil {
+		return nil, err
+	}
+	ns := &corev1.Namespace{}
+	if err := s.kubeClient.Get(ctx, client.ObjectKey{Name: name}, ns); err != nil {
+		return nil, err
+	}
+	return ns, nil
+}
+
+// allowlist returns the allowlisted registries, and whether the ConfigMap
+// listing them exists
+func (s *ImageProvenanceWebhook) allowlist(ctx context.Context) ([]string, bool, error) {
+	now := time.Now()
+	if registries, found, fresh := allowlist.get(now); fresh {
+		return registries, found, nil
+	}
+	if err := s.ensureClient(); err != nil {
+		return nil, false, err
+	}
+	cm := &corev1.ConfigMap{}
+	err := s.kubeClient.Get(ctx, client.ObjectKey{Namespace: AllowlistNamespace, Name: AllowlistName}, cm)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, false, err
+	}
+	found := err == nil
+	registries := parseAllowlist(cm.Data[registriesKey])
+	allowlist.set(registries, found, now)
+	return registries, found, nil
+}
+
+// ensureClient creates a client if none was injected
+func (s *ImageProvenanceWebhook) ensureClient() error {
+	if s.kubeClient != nil {
+		return nil
+	}
+	var err error
+	s.kubeClient, err = k8sutil.KubeClient(s.s)
+	return err
+}
+
+// parseAllowlist parses the registries listed one per line or
+// comma-separated. Blank entries and lines starting with # are ignored.
+func parseAllowlist(data string) []string {
+	registries := []string{}
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, entry := range strings.Split(line, ",") {
+			entry = strings.TrimSuffix(strings.TrimSpace(entry), "/")
+			if entry != "" {
+				registries = append(registries, entry)
+			}
+		}
+	}
+	return registries
+}
+
+// allowed returns true if the image's repository is from one of the
+// registries, or below one of the repository paths
+func allowed(registries []string, image string) bool {
+	repo := repository(image)
+	for _, registry := range registries {
+		if repo == registry || strings.HasPrefix(repo, registry+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// repository returns the repository of an image reference, including its
+// registry, without its tag or digest. References without a registry are
+// Docker Hub's, as the container runtime pulls them from there.
+func repository(image string) string {
+	name, _, _ := strings.Cut(image, "@")
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name = name[:i]
+	}
+	domain, path, found := strings.Cut(name, "/")
+	if !found {
+		return dockerHub + "/library/" + name
+	}
+	if !strings.ContainsAny(domain, ".:") && domain != "localhost" {
+		return dockerHub + "/" + name
+	}
+	if domain == "index.docker.io" {
+		return dockerHub + "/" + path
+	}
+	return name
+}
+
+// GetURI implements Webhook interface
+func (s *ImageProvenanceWebhook) GetURI() string { return "/" + WebhookName }
+
+// Validate implements Webhook interface
+func (s *ImageProvenanceWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	valid = valid && (request.Kind.Kind == "Pod")
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *ImageProvenanceWebhook) Name() string { return WebhookName }
+
+// FailurePolicy implements Webhook interface
+func (s *ImageProvenanceWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *ImageProvenanceWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *ImageProvenanceWebhook) Rules() []admissionregv1.RuleWithOperations { return rules }
+
+// ExcludedNamespaces implements NamespaceExclusionWebhook interface
+func (s *ImageProvenanceWebhook) ExcludedNamespaces() utils.NamespaceExclusion {
+	return hookconfig.ExcludedNamespaces
+}
+
+// OptIn implements OptInWebhook interface. The policy is only enforced on the
+// clusters whose customers ask for it.
+func (s *ImageProvenanceWebhook) OptIn() bool { return true }
+
+// ObjectSelector implements Webhook interface
+func (s *ImageProvenanceWebhook) ObjectSelector() *metav1.LabelSelector { return nil }
+
+// SideEffects implements Webhook interface
+func (s *ImageProvenanceWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *ImageProvenanceWebhook) TimeoutSeconds() int32 { return 2 }
+
+// Doc implements Webhook interface
+func (s *ImageProvenanceWebhook) Doc() string {
+	return fmt.Sprintf(docString, EnforceLabel, EnforceValue, registriesKey, AllowlistNamespace, AllowlistName)
+}
+
+// RuleDocs implements Webhook interface
+func (s *ImageProvenanceWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    fmt.Sprintf("On clusters which enable the webhook, pods in customer namespaces labelled %s=%s may only use images from the registries allowlisted in the %s/%s ConfigMap.", EnforceLabel, EnforceValue, AllowlistNamespace, AllowlistName),
+			Exceptions: []string{utils.SREException, "Pods in managed namespaces", "Images a pod already had when it's updated"},
+		},
+	}
+}
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+func (s *ImageProvenanceWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// ClassicEnabled implements Webhook interface
+func (s *ImageProvenanceWebhook) ClassicEnabled() bool { return true }
+
+// HypershiftEnabled implements Webhook interface
+func (s *ImageProvenanceWebhook) HypershiftEnabled() bool { return true }
@@ -0,0 +1,248 @@
+package imageprovenance
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+)
+
+func newPod(images ...string) *corev1.Pod {
+	pod := &corev1.Pod{}
+	for _, image := range images {
+		pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: "app", Image: image})
+	}
+	return pod
+}
+
+func newRequest(t *testing.T, namespace, username string, oldPod, pod *corev1.Pod) admissionctl.Request {
+	t.Helper()
+	operation := admissionv1.Create
+	if oldPod != nil {
+		operation = admissionv1.Update
+		oldPod.Namespace = namespace
+	}
+	pod.Namespace = namespace
+	return testutils.NewRequest(t, metav1.GroupVersionKind{Version: "v1", Kind: "Pod"}, operation, authenticationv1.UserInfo{Username: username}, namespace, "", pod, oldPod)
+}
+
+func TestAuthorized(t *testing.T) {
+	namespaces := []client.Object{
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "customer"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments", Labels: map[string]string{EnforceLabel: EnforceValue}}},
+	}
+	allowlistCM := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: AllowlistNamespace, Name: AllowlistName},
+		Data: map[string]string{registriesKey: `# the company's registries
+registry.acme.com
+quay.io/acme/, docker.io/library/busybox`},
+	}
+
+	tests := []struct {
+		name      string
+		namespace string
+		username  string
+		oldPod    *corev1.Pod
+		pod       *corev1.Pod
+		noList    bool
+		allowed   bool
+		message   string
+	}{
+		{
+			name:      "namespace not enforcing image provenance",
+			namespace: "customer",
+			username:  "customer",
+			pod:       newPod("evil.example.com/miner:latest"),
+			allowed:   true,
+		},
+		{
+			name:      "allowlisted registry",
+			namespace: "payments",
+			username:  "system:serviceaccount:kube-system:replicaset-controller",
+			pod:       newPod("registry.acme.com/payments/api:v1", "quay.io/acme/sidecar@sha256:4dbe2a75a516a947eab036ef6a1d086f1b1610f6bd21c6ab5f95db68ec177ea2"),
+			allowed:   true,
+		},
+		{
+			name:      "allowlisted Docker Hub repository by its short name",
+			namespace: "payments",
+			username:  "customer",
+			pod:       newPod("busybox:1.36"),
+			allowed:   true,
+		},
+		{
+			name:      "registry not allowlisted",
+			namespace: "payments",
+			username:  "customer",
+			pod:       newPod("registry.acme.com/payments/api:v1", "evil.example.com/miner:latest"),
+			allowed:   false,
+			message:   "Prevented from running evil.example.com/miner:latest in namespace payments",
+		},
+		{
+			name:      "repository outside the allowlisted path",
+			namespace: "payments",
+			username:  "customer",
+			pod:       newPod("quay.io/acmecorp/app:v1"),
+			allowed:   false,
+		},
+		{
+			name:      "cluster admin",
+			namespace: "payments",
+			username:  "kube:admin",
+			pod:       newPod("nginx"),
+			allowed:   false,
+		},
+		{
+			name:      "no allowlist",
+			namespace: "payments",
+			username:  "customer",
+			pod:       newPod("registry.acme.com/payments/api:v1"),
+			noList:    true,
+			allowed:   false,
+			message:   "ConfigMap doesn't exist",
+		},
+		{
+			name:      "update keeping an image no longer allowlisted",
+			namespace: "payments",
+			username:  "customer",
+			oldPod:    newPod("quay.io/legacy/app:v1"),
+			pod:       newPod("quay.io/legacy/app:v1"),
+			allowed:   true,
+		},
+		{
+			name:      "update adding an image which isn't allowlisted",
+			namespace: "payments",
+			username:  "customer",
+			oldPod:    newPod("registry.acme.com/payments/api:v1"),
+			pod:       newPod("registry.acme.com/payments/api:v1", "quay.io/legacy/app:v1"),
+			allowed:   false,
+			message:   "running quay.io/legacy/app:v1 in",
+		},
+		{
+			name:      "managed namespace",
+			namespace: "openshift-monitoring",
+			username:  "customer",
+			pod:       newPod("evil.example.com/miner:latest"),
+			allowed:   true,
+		},
+		{
+			name:      "sre",
+			namespace: "payments",
+			username:  "backplane-cluster-admin",
+			pod:       newPod("evil.example.com/miner:latest"),
+			allowed:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			allowlist = &allowlistCache{}
+			builder := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(namespaces...)
+			if !test.noList {
+				builder = builder.WithObjects(allowlistCM.DeepCopy())
+			}
+			hook := NewWebhook()
+			hook.InjectClient(builder.Build())
+
+			request := newRequest(t, test.namespace, test.username, test.oldPod, test.pod)
+			if !hook.Validate(request) {
+				t.Fatalf("Expected request to be valid")
+			}
+			response := hook.Authorized(request)
+			if response.Allowed != test.allowed {
+				t.Fatalf("Expected allowed %v, got %v: %v", test.allowed, response.Allowed, response.Result)
+			}
+			if response.UID != request.UID {
+				t.Fatalf("Expected UID %s, got %s", request.UID, response.UID)
+			}
+			if test.message != "" && !strings.Contains(response.Result.Message, test.message) {
+				t.Errorf("Expected the message to contain %q, got %s", test.message, response.Result.Message)
+			}
+		})
+	}
+}
+
+// TestUnreadableAllowlist checks an allowlist the webhook isn't permitted to
+// read is told apart from a missing one, rather than silently allowing pods
+func TestUnreadableAllowlist(t *testing.T) {
+	defer func(l logr.Logger) { log = l }(log)
+	logs := []string{}
+	log = funcr.New(func(prefix, args string) { logs = append(logs, args) }, funcr.Options{})
+
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments", Labels: map[string]string{EnforceLabel: EnforceValue}}}
+	tests := []struct {
+		name    string
+		funcs   interceptor.Funcs
+		allowed bool
+		log     string
+	}{
+		{
+			name:    "missing allowlist",
+			allowed: false,
+			log:     "Denying pod using images from registries which aren't allowlisted",
+		},
+		{
+			name: "forbidden allowlist",
+			funcs: interceptor.Funcs{
+				Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+					if _, ok := obj.(*corev1.ConfigMap); ok {
+						return apierrors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, key.Name, fmt.Errorf("not granted"))
+					}
+					return c.Get(ctx, key, obj, opts...)
+				},
+			},
+			allowed: true,
+			log:     "Not permitted to read the image provenance allowlist",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			allowlist = &allowlistCache{}
+			logs = logs[:0]
+			hook := NewWebhook()
+			hook.InjectClient(fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(namespace.DeepCopy()).WithInterceptorFuncs(test.funcs).Build())
+
+			response := hook.Authorized(newRequest(t, "payments", "customer", nil, newPod("evil.example.com/miner:latest")))
+			if response.Allowed != test.allowed {
+				t.Fatalf("Expected allowed %v, got %v: %v", test.allowed, response.Allowed, response.Result)
+			}
+			if len(logs) != 1 || !strings.Contains(logs[0], test.log) {
+				t.Errorf("Expected one log containing %q, got %v", test.log, logs)
+			}
+		})
+	}
+}
+
+func TestRepository(t *testing.T) {
+	tests := map[string]string{
+		"nginx":                                 "docker.io/library/nginx",
+		"nginx:1.25":                            "docker.io/library/nginx",
+		"bitnami/redis:7":                       "docker.io/bitnami/redis",
+		"index.docker.io/bitnami/redis":         "docker.io/bitnami/redis",
+		"quay.io/acme/app@sha256:4dbe2a75":      "quay.io/acme/app",
+		"quay.io/acme/app:v1@sha256:4dbe2a75":   "quay.io/acme/app",
+		"localhost/app:dev":                     "localhost/app",
+		"registry.acme.com:5000/payments/api":   "registry.acme.com:5000/payments/api",
+		"registry.acme.com:5000/payments/api:1": "registry.acme.com:5000/payments/api",
+	}
+	for image, expected := range tests {
+		if actual := repository(image); actual != expected {
+			t.Errorf("Expected the repository of %s to be %s, got %s", image, expected, actual)
+		}
+	}
+}
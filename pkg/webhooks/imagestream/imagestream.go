@@ -0,0 +1,143 @@
+package imagestream
+
+import (
+	"fmt"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "imagestream-validation"
+	docString   string = `Managed OpenShift customers, including cluster admins, may not delete the ImageStreams of the %s namespace. podimagespec-mutation resolves images of the internal registry through them, and the Samples Operator manages them.`
+
+	// ProtectedNamespace is the namespace whose ImageStreams may not be
+	// deleted. It's the namespace podimagespec-mutation resolves
+	// ImageStreamTags in by default.
+	ProtectedNamespace string = "openshift"
+
+	imageStreamKind string = "ImageStream"
+)
+
+var (
+	scope = admissionregv1.NamespacedScope
+	rules = []admissionregv1.RuleWithOperations{
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Delete},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{"image.openshift.io"},
+				APIVersions: []string{"*"},
+				Resources:   []string{"imagestreams"},
+				Scope:       &scope,
+			},
+		},
+	}
+	log = logf.Log.WithName(WebhookName)
+)
+
+// ImageStreamWebhook protects the ImageStreams podimagespec-mutation
+// resolves images through
+type ImageStreamWebhook struct{}
+
+// NewWebhook creates a new webhook
+func NewWebhook() *ImageStreamWebhook {
+	return &ImageStreamWebhook{}
+}
+
+// Authorized implements Webhook interface
+func (s *ImageStreamWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.authorized(request)
+}
+
+func (s *ImageStreamWebhook) authorized(request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if request.Namespace != ProtectedNamespace {
+		ret = admissionctl.Allowed("ImageStream isn't in the protected namespace")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	if identity.IsManager(request.UserInfo) {
+		ret = admissionctl.Allowed("SRE and platform service accounts may delete platform ImageStreams")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	log.Info("Denying deletion of platform ImageStream", "namespace", request.Namespace, "name", request.Name, "user", request.UserInfo.Username)
+	ret = response.Denied(response.PlatformImageStream, fmt.Sprintf("Prevented from deleting ImageStream %s in namespace %s. Pods using images of the internal registry are resolved through the ImageStreams of this namespace, so deleting them can break pulling those images. To stop the Samples Operator from managing this ImageStream, add it to spec.skippedImagestreams of configs.samples.operator.openshift.io/cluster instead. If it was deleted already, the Samples Operator recreates it while its spec.managementState is Managed: oc patch configs.samples.operator.openshift.io cluster --type merge -p '{\"spec\":{\"managementState\":\"Managed\"}}'. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", request.Name, request.Namespace))
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// GetURI implements Webhook interface
+func (s *ImageStreamWebhook) GetURI() string { return "/" + WebhookName }
+
+// Validate implements Webhook interface
+func (s *ImageStreamWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	valid = valid && (request.Kind.Kind == imageStreamKind)
+	valid = valid && (request.Operation == admissionv1.Delete)
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *ImageStreamWebhook) Name() string { return WebhookName }
+
+// FailurePolicy implements Webhook interface
+func (s *ImageStreamWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *ImageStreamWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *ImageStreamWebhook) Rules() []admissionregv1.RuleWithOperations { return rules }
+
+// ObjectSelector implements Webhook interface
+func (s *ImageStreamWebhook) ObjectSelector() *metav1.LabelSelector { return nil }
+
+// SideEffects implements Webhook interface
+func (s *ImageStreamWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *ImageStreamWebhook) TimeoutSeconds() int32 { return 2 }
+
+// Doc implements Webhook interface
+func (s *ImageStreamWebhook) Doc() string {
+	return fmt.Sprintf(docString, ProtectedNamespace)
+}
+
+// RuleDocs implements Webhook interface
+func (s *ImageStreamWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    fmt.Sprintf("Customers, including cluster admins, may not delete ImageStreams in the %s namespace.", ProtectedNamespace),
+			Exceptions: []string{utils.SREException, utils.PrivilegedServiceAccountsException, "Hive, as system:admin"},
+		},
+	}
+}
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+func (s *ImageStreamWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// ClassicEnabled implements Webhook interface
+func (s *ImageStreamWebhook) ClassicEnabled() bool { return true }
+
+// HypershiftEnabled implements Webhook interface
+func (s *ImageStreamWebhook) HypershiftEnabled() bool { return true }
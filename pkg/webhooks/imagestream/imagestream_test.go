@@ -0,0 +1,82 @@
+package imagestream
+
+import (
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+)
+
+func TestAuthorized(t *testing.T) {
+	tests := []struct {
+		name      string
+		user      authenticationv1.UserInfo
+		namespace string
+		allowed   bool
+		message   string
+	}{
+		{
+			name:      "cluster admin deletes openshift imagestream",
+			user:      authenticationv1.UserInfo{Username: "kube:admin", Groups: []string{"system:cluster-admins", "system:authenticated"}},
+			namespace: "openshift",
+			allowed:   false,
+			message:   "add it to spec.skippedImagestreams of configs.samples.operator.openshift.io/cluster",
+		},
+		{
+			name:      "customer deletes openshift imagestream",
+			user:      authenticationv1.UserInfo{Username: "customer", Groups: []string{"dedicated-admins", "system:authenticated"}},
+			namespace: "openshift",
+			allowed:   false,
+			message:   "Prevented from deleting ImageStream cli in namespace openshift",
+		},
+		{
+			name:      "customer deletes own imagestream",
+			user:      authenticationv1.UserInfo{Username: "customer", Groups: []string{"dedicated-admins", "system:authenticated"}},
+			namespace: "payments",
+			allowed:   true,
+		},
+		{
+			name:      "samples operator deletes openshift imagestream",
+			user:      authenticationv1.UserInfo{Username: "system:serviceaccount:openshift-cluster-samples-operator:cluster-samples-operator", Groups: []string{"system:serviceaccounts", "system:serviceaccounts:openshift-cluster-samples-operator"}},
+			namespace: "openshift",
+			allowed:   true,
+		},
+		{
+			name:      "SRE deletes openshift imagestream",
+			user:      authenticationv1.UserInfo{Username: "backplane-cluster-admin"},
+			namespace: "openshift",
+			allowed:   true,
+		},
+		{
+			name:      "hive deletes openshift imagestream",
+			user:      authenticationv1.UserInfo{Username: "system:admin"},
+			namespace: "openshift",
+			allowed:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hook := NewWebhook()
+			gvk := metav1.GroupVersionKind{Group: "image.openshift.io", Version: "v1", Kind: "ImageStream"}
+			request := testutils.NewRequest(t, gvk, admissionv1.Delete, test.user, test.namespace, "cli", nil, nil)
+			if !hook.Validate(request) {
+				t.Fatalf("Expected request to be valid")
+			}
+			response := hook.Authorized(request)
+			if response.Allowed != test.allowed {
+				t.Fatalf("Expected allowed %v, got %v: %v", test.allowed, response.Allowed, response.Result)
+			}
+			if response.UID != request.UID {
+				t.Fatalf("Expected UID %s, got %s", request.UID, response.UID)
+			}
+			if test.message != "" && !strings.Contains(response.Result.Message, test.message) {
+				t.Errorf("Expected the message to contain %q, got %s", test.message, response.Result.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,331 @@
+package impersonation
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	hookconfig "github.com/openshift/managed-cluster-validating-webhooks/pkg/config"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "impersonation-validation"
+	docString   string = `Managed OpenShift customers, including cluster admins, may not create or change ClusterRoles to grant impersonating Red Hat SRE, as the identity policy defines them, nor request tokens for the service accounts of platform namespaces. This backs up RBAC, so a mistaken grant doesn't let customers act as SRE or the platform.`
+
+	clusterRoleKind  string = "ClusterRole"
+	tokenRequestKind string = "TokenRequest"
+	userGroup        string = "user.openshift.io"
+)
+
+var (
+	clusterScope   = admissionregv1.ClusterScope
+	namespaceScope = admissionregv1.NamespacedScope
+	rules          = []admissionregv1.RuleWithOperations{
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Create, admissionregv1.Update},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{"rbac.authorization.k8s.io"},
+				APIVersions: []string{"*"},
+				Resources:   []string{"clusterroles"},
+				Scope:       &clusterScope,
+			},
+		},
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Create},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{""},
+				APIVersions: []string{"v1"},
+				Resources:   []string{"serviceaccounts/token"},
+				Scope:       &namespaceScope,
+			},
+		},
+	}
+	log = logf.Log.WithName(WebhookName)
+
+	// impersonatedResources are the resources RBAC checks the impersonate
+	// verb on, and what they impersonate. OpenShift also checks its
+	// user.openshift.io resources, systemusers and systemgroups for names
+	// starting with system:.
+	impersonatedResources = []impersonatedResource{
+		{apiGroup: "", resource: "users", kind: "user"},
+		{apiGroup: "", resource: "groups", kind: "group"},
+		{apiGroup: userGroup, resource: "users", kind: "user"},
+		{apiGroup: userGroup, resource: "groups", kind: "group"},
+		{apiGroup: userGroup, resource: "systemusers", kind: "user"},
+		{apiGroup: userGroup, resource: "systemgroups", kind: "group"},
+	}
+
+	// nodesGroup is the group of kubelets, which request the tokens of the
+	// service accounts their pods run as
+	nodesGroup = "system:nodes"
+)
+
+// impersonatedResource is a resource granting impersonating users or groups
+type impersonatedResource struct {
+	apiGroup string
+	resource string
+	// kind is what the resource impersonates, user or group
+	kind string
+}
+
+// ImpersonationWebhook denies customers ways of acting as SRE or the platform
+// which RBAC alone should already prevent
+type ImpersonationWebhook struct {
+	decoder admissionctl.Decoder
+}
+
+// NewWebhook creates a new webhook
+func NewWebhook() *ImpersonationWebhook {
+	decoder, err := k8sutil.SharedDecoder()
+	if err != nil {
+		log.Error(err, "Fail getting the shared decoder for ImpersonationWebhook")
+		os.Exit(1)
+	}
+
+	return &ImpersonationWebhook{
+		decoder: decoder,
+	}
+}
+
+// Authorized implements Webhook interface
+func (s *ImpersonationWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.authorized(request)
+}
+
+func (s *ImpersonationWebhook) authorized(request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if identity.IsManager(request.UserInfo) {
+		ret = admissionctl.Allowed("SRE and managed service accounts may impersonate SRE and request platform tokens")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	if request.Kind.Kind == tokenRequestKind {
+		return s.authorizeTokenRequest(request)
+	}
+	return s.authorizeClusterRole(request)
+}
+
+// authorizeClusterRole denies ClusterRoles newly granting impersonating SRE.
+// Grants the ClusterRole already had are left alone.
+func (s *ImpersonationWebhook) authorizeClusterRole(request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	role := &rbacv1.ClusterRole{}
+	if err := s.decoder.Decode(request, role); err != nil {
+		log.Error(err, "Couldn't decode the ClusterRole from the request", "name", request.Name)
+		ret = admissionctl.Errored(http.StatusBadRequest, err)
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	granted := sreImpersonation(role.Rules)
+	if len(granted) > 0 && request.Operation == admissionv1.Update {
+		old := &rbacv1.ClusterRole{}
+		if err := s.decoder.DecodeRaw(request.OldObject, old); err != nil {
+			log.Error(err, "Couldn't decode the old ClusterRole from the request", "name", request.Name)
+			ret = admissionctl.Errored(http.StatusBadRequest, err)
+			ret.UID = request.AdmissionRequest.UID
+			return ret
+		}
+		previously := sreImpersonation(old.Rules)
+		granted = slices.DeleteFunc(granted, func(grant string) bool { return slices.Contains(previously, grant) })
+	}
+	if len(granted) == 0 {
+		ret = admissionctl.Allowed("ClusterRole doesn't grant impersonating SRE")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	log.Info("Denying ClusterRole granting impersonation of SRE", "name", request.Name, "operation", request.Operation, "user", request.UserInfo.Username, "granted", granted)
+	ret = response.Denied(response.SREImpersonation, fmt.Sprintf("Prevented from granting impersonation of %s with ClusterRole %s. Impersonating Red Hat SRE would let its subjects act with SRE's access, so it can't be granted, even by cluster admins. Name the users and groups which may be impersonated in the rule's resourceNames instead. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", strings.Join(granted, ", "), request.Name))
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// authorizeTokenRequest denies requesting tokens for the service accounts of
+// platform namespaces
+func (s *ImpersonationWebhook) authorizeTokenRequest(request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if !hookconfig.ExcludedNamespaces.Excludes(request.Namespace) {
+		ret = admissionctl.Allowed("Service account isn't in a platform namespace")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	if slices.Contains(request.UserInfo.Groups, nodesGroup) {
+		ret = admissionctl.Allowed("Kubelets request the tokens of their pods' service accounts")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	// as regular-user-validation, system users other than service accounts
+	// are trusted, such as the kube-controller-manager requesting the tokens
+	// of its controllers' service accounts in kube-system
+	if strings.HasPrefix(request.UserInfo.Username, "system:") && !strings.HasPrefix(request.UserInfo.Username, "system:serviceaccount:") {
+		ret = admissionctl.Allowed("System users may request the tokens of platform service accounts")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	log.Info("Denying token request for platform service account", "namespace", request.Namespace, "name", request.Name, "user", request.UserInfo.Username)
+	ret = response.Denied(response.PlatformServiceAccountToken, fmt.Sprintf("Prevented from requesting a token for service account %s in namespace %s. The service accounts of platform namespaces are managed by Red Hat, and their tokens would let you act as the platform. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", request.Name, request.Namespace))
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// sreImpersonation returns the SRE users and groups, or "any user" and "any
+// group", the rules grant impersonating. Rules must name the impersonate verb
+// or one of the impersonatedResources; those granting every verb on every
+// resource are left to RBAC's escalation checks.
+func sreImpersonation(rules []rbacv1.PolicyRule) []string {
+	granted := []string{}
+	for _, rule := range rules {
+		explicitVerb := slices.Contains(rule.Verbs, "impersonate")
+		if !explicitVerb && !matchesAny(rule.Verbs, "impersonate") {
+			continue
+		}
+		for _, impersonated := range impersonatedResources {
+			if !matchesAny(rule.APIGroups, impersonated.apiGroup) {
+				continue
+			}
+			if !matchesAny(rule.Resources, impersonated.resource) || (!explicitVerb && !slices.Contains(rule.Resources, impersonated.resource)) {
+				continue
+			}
+			for _, grant := range impersonatedSRE(impersonated.kind, rule.ResourceNames) {
+				if !slices.Contains(granted, grant) {
+					granted = append(granted, grant)
+				}
+			}
+		}
+	}
+	return granted
+}
+
+// impersonatedSRE returns the SRE users or groups, as the identity policy
+// defines them, among the resource names, or "any user" or "any group" when
+// the rule isn't restricted to names
+func impersonatedSRE(kind string, names []string) []string {
+	if len(names) == 0 {
+		return []string{"any " + kind}
+	}
+	sre := []string{}
+	for _, name := range names {
+		user := authenticationv1.UserInfo{Username: name}
+		if kind == "group" {
+			user = authenticationv1.UserInfo{Groups: []string{name}}
+		}
+		if identity.IsSRE(user) {
+			sre = append(sre, kind+" "+name)
+		}
+	}
+	return sre
+}
+
+// matchesAny returns true if the values of a policy rule include the value
+// or the wildcard
+func matchesAny(values []string, value string) bool {
+	return slices.Contains(values, value) || slices.Contains(values, rbacv1.ResourceAll)
+}
+
+// GetURI implements Webhook interface
+func (s *ImpersonationWebhook) GetURI() string {
+	return "/" + WebhookName
+}
+
+// Validate implements Webhook interface
+func (s *ImpersonationWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	switch request.Kind.Kind {
+	case clusterRoleKind:
+		valid = valid && (request.Operation == admissionv1.Create || request.Operation == admissionv1.Update)
+	case tokenRequestKind:
+		valid = valid && (request.Operation == admissionv1.Create) && (request.SubResource == "token")
+	default:
+		valid = false
+	}
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *ImpersonationWebhook) Name() string {
+	return WebhookName
+}
+
+// FailurePolicy implements Webhook interface
+func (s *ImpersonationWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *ImpersonationWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *ImpersonationWebhook) Rules() []admissionregv1.RuleWithOperations {
+	return rules
+}
+
+// ObjectSelector implements Webhook interface
+func (s *ImpersonationWebhook) ObjectSelector() *metav1.LabelSelector {
+	return nil
+}
+
+// SideEffects implements Webhook interface
+func (s *ImpersonationWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *ImpersonationWebhook) TimeoutSeconds() int32 {
+	return 2
+}
+
+// Doc implements Webhook interface
+func (s *ImpersonationWebhook) Doc() string {
+	return docString
+}
+
+// RuleDocs implements Webhook interface
+func (s *ImpersonationWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers, including cluster admins, may not create or change ClusterRoles to grant impersonating SRE users or groups, or any user or group.",
+			Exceptions: []string{utils.SREException, utils.PrivilegedServiceAccountsException, "Hive, as system:admin", "Grants the ClusterRole already had"},
+		},
+		{
+			Summary:    "Customers, including cluster admins, may not request tokens for the service accounts of platform namespaces.",
+			Exceptions: []string{utils.SREException, utils.PrivilegedServiceAccountsException, "Kubelets, for their pods' service accounts"},
+		},
+	}
+}
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+func (s *ImpersonationWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// ClassicEnabled implements Webhook interface
+func (s *ImpersonationWebhook) ClassicEnabled() bool {
+	return true
+}
+
+// HypershiftEnabled implements Webhook interface
+func (s *ImpersonationWebhook) HypershiftEnabled() bool {
+	return true
+}
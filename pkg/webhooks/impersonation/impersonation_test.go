@@ -0,0 +1,258 @@
+package impersonation
+
+import (
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+)
+
+var (
+	clusterAdmin = authenticationv1.UserInfo{Username: "kube:admin", Groups: []string{"system:cluster-admins", "system:authenticated"}}
+	customer     = authenticationv1.UserInfo{Username: "customer", Groups: []string{"dedicated-admins", "system:authenticated"}}
+	sre          = authenticationv1.UserInfo{Username: "backplane-cluster-admin"}
+	kubelet      = authenticationv1.UserInfo{Username: "system:node:worker-0", Groups: []string{"system:nodes", "system:authenticated"}}
+)
+
+func impersonate(resource string, names ...string) rbacv1.PolicyRule {
+	return rbacv1.PolicyRule{APIGroups: []string{""}, Verbs: []string{"impersonate"}, Resources: []string{resource}, ResourceNames: names}
+}
+
+func clusterRoleRequest(t *testing.T, user authenticationv1.UserInfo, operation admissionv1.Operation, old, obj *rbacv1.ClusterRole) admissionctl.Request {
+	t.Helper()
+	return testutils.NewRequest(t, metav1.GroupVersionKind{Group: rbacv1.GroupName, Version: "v1", Kind: "ClusterRole"}, operation, user, "", obj.Name, obj, old)
+}
+
+func newRole(rules ...rbacv1.PolicyRule) *rbacv1.ClusterRole {
+	return &rbacv1.ClusterRole{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole"},
+		ObjectMeta: metav1.ObjectMeta{Name: "impersonator"},
+		Rules:      rules,
+	}
+}
+
+func TestClusterRoles(t *testing.T) {
+	tests := []struct {
+		name      string
+		user      authenticationv1.UserInfo
+		operation admissionv1.Operation
+		old       *rbacv1.ClusterRole
+		obj       *rbacv1.ClusterRole
+		allowed   bool
+		message   string
+	}{
+		{
+			name:      "impersonate SRE group",
+			user:      clusterAdmin,
+			operation: admissionv1.Create,
+			obj:       newRole(impersonate("groups", "developers", "system:serviceaccounts:openshift-backplane-srep")),
+			allowed:   false,
+			message:   "Prevented from granting impersonation of group system:serviceaccounts:openshift-backplane-srep with ClusterRole impersonator",
+		},
+		{
+			name:      "impersonate SRE system group through user.openshift.io",
+			user:      customer,
+			operation: admissionv1.Create,
+			obj:       newRole(rbacv1.PolicyRule{APIGroups: []string{"user.openshift.io"}, Verbs: []string{"impersonate"}, Resources: []string{"systemgroups"}, ResourceNames: []string{"system:serviceaccounts:openshift-backplane-srep"}}),
+			allowed:   false,
+			message:   "group system:serviceaccounts:openshift-backplane-srep",
+		},
+		{
+			name:      "impersonate any user through user.openshift.io",
+			user:      customer,
+			operation: admissionv1.Create,
+			obj:       newRole(rbacv1.PolicyRule{APIGroups: []string{"user.openshift.io"}, Verbs: []string{"impersonate"}, Resources: []string{"users"}}),
+			allowed:   false,
+			message:   "any user",
+		},
+		{
+			name:      "impersonate group which isn't SRE",
+			user:      customer,
+			operation: admissionv1.Create,
+			obj:       newRole(impersonate("groups", "osd-sre-admins")),
+			allowed:   true,
+		},
+		{
+			name:      "impersonate SRE user",
+			user:      customer,
+			operation: admissionv1.Create,
+			obj:       newRole(impersonate("users", "backplane-cluster-admin")),
+			allowed:   false,
+			message:   "user backplane-cluster-admin",
+		},
+		{
+			name:      "impersonate backplane service account group",
+			user:      customer,
+			operation: admissionv1.Create,
+			obj:       newRole(impersonate("groups", "system:serviceaccounts:openshift-backplane-srep")),
+			allowed:   false,
+		},
+		{
+			name:      "impersonate any group",
+			user:      customer,
+			operation: admissionv1.Create,
+			obj:       newRole(impersonate("groups")),
+			allowed:   false,
+			message:   "any group",
+		},
+		{
+			name:      "wildcard verbs on users",
+			user:      customer,
+			operation: admissionv1.Create,
+			obj:       newRole(rbacv1.PolicyRule{APIGroups: []string{"*"}, Verbs: []string{"*"}, Resources: []string{"users"}}),
+			allowed:   false,
+			message:   "any user",
+		},
+		{
+			name:      "impersonate named customer group",
+			user:      customer,
+			operation: admissionv1.Create,
+			obj:       newRole(impersonate("groups", "developers")),
+			allowed:   true,
+		},
+		{
+			name:      "wildcard verbs on wildcard resources",
+			user:      clusterAdmin,
+			operation: admissionv1.Create,
+			obj:       newRole(rbacv1.PolicyRule{APIGroups: []string{"*"}, Verbs: []string{"*"}, Resources: []string{"*"}}),
+			allowed:   true,
+		},
+		{
+			name:      "impersonate in another API group",
+			user:      customer,
+			operation: admissionv1.Create,
+			obj:       newRole(rbacv1.PolicyRule{APIGroups: []string{"example.com"}, Verbs: []string{"impersonate"}, Resources: []string{"users"}}),
+			allowed:   true,
+		},
+		{
+			name:      "update keeping existing grant",
+			user:      customer,
+			operation: admissionv1.Update,
+			old:       newRole(impersonate("groups")),
+			obj:       newRole(impersonate("groups"), rbacv1.PolicyRule{APIGroups: []string{""}, Verbs: []string{"get"}, Resources: []string{"pods"}}),
+			allowed:   true,
+		},
+		{
+			name:      "update adding grant",
+			user:      customer,
+			operation: admissionv1.Update,
+			old:       newRole(impersonate("groups", "developers")),
+			obj:       newRole(impersonate("users", "developer", "backplane-cluster-admin")),
+			allowed:   false,
+		},
+		{
+			name:      "SRE grants impersonation",
+			user:      sre,
+			operation: admissionv1.Create,
+			obj:       newRole(impersonate("groups")),
+			allowed:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hook := NewWebhook()
+			request := clusterRoleRequest(t, test.user, test.operation, test.old, test.obj)
+			if !hook.Validate(request) {
+				t.Fatalf("Expected request to be valid")
+			}
+			response := hook.Authorized(request)
+			if response.Allowed != test.allowed {
+				t.Fatalf("Expected allowed %v, got %v: %v", test.allowed, response.Allowed, response.Result)
+			}
+			if response.UID != request.UID {
+				t.Fatalf("Expected UID %s, got %s", request.UID, response.UID)
+			}
+			if test.message != "" && !strings.Contains(response.Result.Message, test.message) {
+				t.Errorf("Expected the message to contain %q, got %s", test.message, response.Result.Message)
+			}
+		})
+	}
+}
+
+func TestClusterRolesFollowIdentityPolicy(t *testing.T) {
+	t.Cleanup(func() { identity.Set(identity.Default()) })
+	policy := identity.Default()
+	policy.SRE.Groups = append(policy.SRE.Groups, "osd-sre-admins")
+	identity.Set(policy)
+
+	hook := NewWebhook()
+	response := hook.Authorized(clusterRoleRequest(t, customer, admissionv1.Create, nil, newRole(impersonate("groups", "osd-sre-admins"))))
+	if response.Allowed {
+		t.Fatalf("Expected impersonating a group the identity policy makes SRE to be denied")
+	}
+}
+
+func TestTokenRequests(t *testing.T) {
+	tests := []struct {
+		name      string
+		user      authenticationv1.UserInfo
+		namespace string
+		allowed   bool
+	}{
+		{
+			name:      "customer requests platform token",
+			user:      clusterAdmin,
+			namespace: "openshift-monitoring",
+			allowed:   false,
+		},
+		{
+			name:      "customer requests own token",
+			user:      customer,
+			namespace: "payments",
+			allowed:   true,
+		},
+		{
+			name:      "kubelet requests pod token",
+			user:      kubelet,
+			namespace: "openshift-monitoring",
+			allowed:   true,
+		},
+		{
+			name:      "kube-controller-manager requests kube-system token",
+			user:      authenticationv1.UserInfo{Username: "system:kube-controller-manager", Groups: []string{"system:authenticated"}},
+			namespace: "kube-system",
+			allowed:   true,
+		},
+		{
+			name:      "customer service account requests platform token",
+			user:      authenticationv1.UserInfo{Username: "system:serviceaccount:payments:deployer", Groups: []string{"system:serviceaccounts", "system:serviceaccounts:payments"}},
+			namespace: "kube-system",
+			allowed:   false,
+		},
+		{
+			name:      "platform service account requests token",
+			user:      authenticationv1.UserInfo{Username: "system:serviceaccount:openshift-monitoring:prometheus-operator", Groups: []string{"system:serviceaccounts", "system:serviceaccounts:openshift-monitoring"}},
+			namespace: "openshift-monitoring",
+			allowed:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hook := NewWebhook()
+			gvk := metav1.GroupVersionKind{Group: "authentication.k8s.io", Version: "v1", Kind: "TokenRequest"}
+			tokenRequest := &authenticationv1.TokenRequest{TypeMeta: metav1.TypeMeta{APIVersion: "authentication.k8s.io/v1", Kind: "TokenRequest"}}
+			request := testutils.NewRequest(t, gvk, admissionv1.Create, test.user, test.namespace, "prometheus-k8s", tokenRequest, nil)
+			request.Resource = metav1.GroupVersionResource{Version: "v1", Resource: "serviceaccounts"}
+			request.SubResource = "token"
+			if !hook.Validate(request) {
+				t.Fatalf("Expected request to be valid")
+			}
+			response := hook.Authorized(request)
+			if response.Allowed != test.allowed {
+				t.Fatalf("Expected allowed %v, got %v: %v", test.allowed, response.Allowed, response.Result)
+			}
+			if response.UID != request.UID {
+				t.Fatalf("Expected UID %s, got %s", request.UID, response.UID)
+			}
+		})
+	}
+}
@@ -13,6 +13,8 @@ import (
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
 	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
 )
 
@@ -23,9 +25,8 @@ const (
 )
 
 var (
-	log                         = logf.Log.WithName(WebhookName)
-	privilegedServiceAccountsRe = regexp.MustCompile(utils.PrivilegedServiceAccountGroups)
-	privilegedUsersRe           = regexp.MustCompile(privilegedUsers)
+	log               = logf.Log.WithName(WebhookName)
+	privilegedUsersRe = regexp.MustCompile(privilegedUsers)
 
 	scope = admissionregv1.ClusterScope
 	rules = []admissionregv1.RuleWithOperations{
@@ -48,15 +49,13 @@ type IngressConfigWebhook struct {
 
 // Authorized will determine if the request is allowed
 func (w *IngressConfigWebhook) Authorized(request admissionctl.Request) (ret admissionctl.Response) {
-	ret = admissionctl.Denied("Only privileged service accounts may access")
+	ret = response.Denied(response.ManagedIngress, "Only privileged service accounts may access")
 	ret.UID = request.AdmissionRequest.UID
 
 	// allow if modified by an allowlist-ed service account
-	for _, group := range request.UserInfo.Groups {
-		if privilegedServiceAccountsRe.Match([]byte(group)) {
-			ret = admissionctl.Allowed("Privileged service accounts may access")
-			ret.UID = request.AdmissionRequest.UID
-		}
+	if identity.IsPrivilegedServiceAccount(request.UserInfo) {
+		ret = admissionctl.Allowed("Privileged service accounts may access")
+		ret.UID = request.AdmissionRequest.UID
 	}
 
 	// allow if modified by an allowliste-ed user
@@ -121,6 +120,16 @@ func (w *IngressConfigWebhook) SyncSetLabelSelector() metav1.LabelSelector {
 	return utils.DefaultLabelSelector()
 }
 
+// RuleDocs implements Webhook interface
+func (w *IngressConfigWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers may not modify the cluster Ingress config.",
+			Exceptions: []string{utils.PrivilegedServiceAccountsException, "system:admin"},
+		},
+	}
+}
+
 func (w *IngressConfigWebhook) ClassicEnabled() bool { return true }
 
 // HypershiftEnabled will return boolean value for hypershift enabled configurations
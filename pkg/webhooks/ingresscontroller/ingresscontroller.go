@@ -3,10 +3,11 @@ package ingresscontroller
 import (
 	"fmt"
 	"net/http"
-	"slices"
 	"strings"
 
 	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
 	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
 	admissionregv1 "k8s.io/api/admissionregistration/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -36,9 +37,6 @@ var (
 			},
 		},
 	}
-	allowedUsers = []string{
-		"backplane-cluster-admin",
-	}
 )
 
 type IngressControllerWebhook struct {
@@ -52,6 +50,16 @@ func (wh *IngressControllerWebhook) Doc() string {
 	return fmt.Sprintf(docString)
 }
 
+// RuleDocs implements Webhook interface
+func (wh *IngressControllerWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers may not give IngressController pods tolerations for master nodes.",
+			Exceptions: []string{utils.SystemUsersException},
+		},
+	}
+}
+
 // TimeoutSeconds implements Webhook interface
 func (wh *IngressControllerWebhook) TimeoutSeconds() int32 { return 1 }
 
@@ -114,7 +122,7 @@ func (wh *IngressControllerWebhook) authorized(request admissionctl.Request) adm
 		// This could highlight a significant problem with RBAC since an
 		// unauthenticated user should have no permissions.
 		log.Info("system:unauthenticated made a webhook request. Check RBAC rules", "request", request.AdmissionRequest)
-		ret = admissionctl.Denied("Unauthenticated")
+		ret = response.Denied(response.Unauthenticated, "Unauthenticated")
 		ret.UID = request.AdmissionRequest.UID
 		return ret
 	}
@@ -137,7 +145,7 @@ func (wh *IngressControllerWebhook) authorized(request admissionctl.Request) adm
 	if !isAllowedUser(request) {
 		for _, toleration := range ic.Spec.NodePlacement.Tolerations {
 			if strings.Contains(toleration.Key, "node-role.kubernetes.io/master") {
-				ret = admissionctl.Denied("Not allowed to provision ingress controller pods with toleration for master nodes.")
+				ret = response.Denied(response.InfraNodeScheduling, "Not allowed to provision ingress controller pods with toleration for master nodes.")
 				ret.UID = request.AdmissionRequest.UID
 
 				return ret
@@ -153,9 +161,9 @@ func (wh *IngressControllerWebhook) authorized(request admissionctl.Request) adm
 
 // isAllowedUser checks if the user is allowed to perform the action
 func isAllowedUser(request admissionctl.Request) bool {
-	log.Info(fmt.Sprintf("Checking username %s on whitelist", request.UserInfo.Username))
-	if slices.Contains(allowedUsers, request.UserInfo.Username) {
-		log.Info(fmt.Sprintf("%s is listed in whitelist", request.UserInfo.Username))
+	log.Info(fmt.Sprintf("Checking whether %s is SRE", request.UserInfo.Username))
+	if identity.IsSRE(request.UserInfo) {
+		log.Info(fmt.Sprintf("%s is SRE", request.UserInfo.Username))
 		return true
 	}
 
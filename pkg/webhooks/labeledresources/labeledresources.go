@@ -0,0 +1,161 @@
+package labeledresources
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "labeledresources-validation"
+	docString   string = `Managed OpenShift customers may not update or delete Secrets and ConfigMaps labelled %s=true in any namespace, as SRE and the managed operators rely on them. Labelling a Secret or ConfigMap protects it without a webhook of its own.`
+
+	// ManagedLabel marks the Secrets and ConfigMaps SRE manage
+	ManagedLabel string = "managed.openshift.io/managed"
+)
+
+var (
+	scope = admissionregv1.NamespacedScope
+	rules = []admissionregv1.RuleWithOperations{
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Update, admissionregv1.Delete},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{""},
+				APIVersions: []string{"v1"},
+				Resources:   []string{"secrets", "configmaps"},
+				Scope:       &scope,
+			},
+		},
+	}
+	log = logf.Log.WithName(WebhookName)
+)
+
+// LabeledResourcesWebhook protects the Secrets and ConfigMaps labelled as
+// managed
+type LabeledResourcesWebhook struct{}
+
+// NewWebhook creates a new webhook
+func NewWebhook() *LabeledResourcesWebhook {
+	return &LabeledResourcesWebhook{}
+}
+
+// Authorized implements Webhook interface
+func (s *LabeledResourcesWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.authorized(request)
+}
+
+func (s *LabeledResourcesWebhook) authorized(request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if identity.IsAdmin(request.UserInfo) {
+		ret = admissionctl.Allowed("Admins and managed service accounts may change managed Secrets and ConfigMaps")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	// Only the metadata is decoded, so the data of Secrets isn't
+	old := &metav1.PartialObjectMetadata{}
+	if err := json.Unmarshal(request.OldObject.Raw, old); err != nil {
+		log.Error(err, "Couldn't decode the old object from the request")
+		ret = admissionctl.Errored(http.StatusBadRequest, err)
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	// The old object is checked, so removing the label is denied too
+	if old.Labels[ManagedLabel] != "true" {
+		ret = admissionctl.Allowed("Only managed Secrets and ConfigMaps are protected")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	verb := "updating"
+	if request.Operation == admissionv1.Delete {
+		verb = "deleting"
+	}
+	kind := strings.ToLower(request.Kind.Kind)
+	log.Info("Denying change of managed resource", "kind", request.Kind.Kind, "namespace", request.Namespace, "name", request.Name, "operation", request.Operation, "user", request.UserInfo.Username)
+	ret = response.Denied(response.ManagedResource, fmt.Sprintf("Prevented from %s the managed %s %s/%s, which is labelled %s=true. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", verb, kind, request.Namespace, request.Name, ManagedLabel))
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// GetURI implements Webhook interface
+func (s *LabeledResourcesWebhook) GetURI() string { return "/" + WebhookName }
+
+// Validate implements Webhook interface
+func (s *LabeledResourcesWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	valid = valid && (len(request.OldObject.Raw) > 0)
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *LabeledResourcesWebhook) Name() string { return WebhookName }
+
+// FailurePolicy implements Webhook interface
+func (s *LabeledResourcesWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *LabeledResourcesWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *LabeledResourcesWebhook) Rules() []admissionregv1.RuleWithOperations { return rules }
+
+// ObjectSelector implements Webhook interface. The API server matches the
+// selector against both the old and the new object, so updates removing the
+// label are still sent to the webhook.
+func (s *LabeledResourcesWebhook) ObjectSelector() *metav1.LabelSelector {
+	return &metav1.LabelSelector{
+		MatchLabels: map[string]string{
+			ManagedLabel: "true",
+		},
+	}
+}
+
+// SideEffects implements Webhook interface
+func (s *LabeledResourcesWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *LabeledResourcesWebhook) TimeoutSeconds() int32 { return 2 }
+
+// Doc implements Webhook interface
+func (s *LabeledResourcesWebhook) Doc() string { return fmt.Sprintf(docString, ManagedLabel) }
+
+// RuleDocs implements Webhook interface
+func (s *LabeledResourcesWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    fmt.Sprintf("Customers may not update, unlabel or delete Secrets and ConfigMaps labelled %s=true.", ManagedLabel),
+			Exceptions: []string{utils.SREException, "The cluster's built-in administrators", "Platform and managed service accounts"},
+		},
+	}
+}
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+func (s *LabeledResourcesWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// ClassicEnabled implements Webhook interface
+func (s *LabeledResourcesWebhook) ClassicEnabled() bool { return true }
+
+// HypershiftEnabled implements Webhook interface
+func (s *LabeledResourcesWebhook) HypershiftEnabled() bool { return true }
@@ -0,0 +1,132 @@
+package labeledresources
+
+import (
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+)
+
+func secret(labels map[string]string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "openshift-config", Name: "sre-pull-secret", Labels: labels},
+		Data:       map[string][]byte{".dockerconfigjson": []byte("{}")},
+	}
+}
+
+func configMap(labels map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "sre-settings", Labels: labels},
+	}
+}
+
+func TestAuthorized(t *testing.T) {
+	secretKind := metav1.GroupVersionKind{Version: "v1", Kind: "Secret"}
+	configMapKind := metav1.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	managed := map[string]string{ManagedLabel: "true"}
+
+	tests := []struct {
+		name      string
+		kind      metav1.GroupVersionKind
+		operation admissionv1.Operation
+		username  string
+		groups    []string
+		old       runtime.Object
+		obj       runtime.Object
+		allowed   bool
+		message   string
+	}{
+		{
+			name:      "managed secret updated",
+			kind:      secretKind,
+			operation: admissionv1.Update,
+			username:  "customer",
+			old:       secret(managed),
+			obj:       secret(managed),
+			allowed:   false,
+			message:   "Prevented from updating the managed secret openshift-config/sre-pull-secret",
+		},
+		{
+			name:      "managed configmap deleted",
+			kind:      configMapKind,
+			operation: admissionv1.Delete,
+			username:  "customer",
+			old:       configMap(managed),
+			allowed:   false,
+			message:   "Prevented from deleting the managed configmap payments/sre-settings",
+		},
+		{
+			name:      "label removed from a managed configmap",
+			kind:      configMapKind,
+			operation: admissionv1.Update,
+			username:  "customer",
+			old:       configMap(managed),
+			obj:       configMap(nil),
+			allowed:   false,
+		},
+		{
+			name:      "label added to a customer's configmap",
+			kind:      configMapKind,
+			operation: admissionv1.Update,
+			username:  "customer",
+			old:       configMap(nil),
+			obj:       configMap(managed),
+			allowed:   true,
+		},
+		{
+			name:      "unmanaged secret deleted",
+			kind:      secretKind,
+			operation: admissionv1.Delete,
+			username:  "customer",
+			old:       secret(map[string]string{ManagedLabel: "false"}),
+			allowed:   true,
+		},
+		{
+			name:      "sre",
+			kind:      secretKind,
+			operation: admissionv1.Update,
+			username:  "backplane-cluster-admin",
+			old:       secret(managed),
+			obj:       secret(managed),
+			allowed:   true,
+		},
+		{
+			name:      "managed operator",
+			kind:      secretKind,
+			operation: admissionv1.Update,
+			username:  "system:serviceaccount:openshift-config-operator:openshift-config-operator",
+			groups:    []string{"system:serviceaccounts", "system:serviceaccounts:openshift-config-operator"},
+			old:       secret(managed),
+			obj:       secret(managed),
+			allowed:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hook := NewWebhook()
+			meta := test.old.(metav1.Object)
+			user := authenticationv1.UserInfo{Username: test.username, Groups: test.groups}
+			request := testutils.NewRequest(t, test.kind, test.operation, user, meta.GetNamespace(), meta.GetName(), test.obj, test.old)
+			if !hook.Validate(request) {
+				t.Fatalf("Expected request to be valid")
+			}
+			response := hook.Authorized(request)
+			if response.Allowed != test.allowed {
+				t.Fatalf("Expected allowed %v, got %v: %v", test.allowed, response.Allowed, response.Result)
+			}
+			if response.UID != request.UID {
+				t.Fatalf("Expected UID %s, got %s", request.UID, response.UID)
+			}
+			if test.message != "" && !strings.Contains(response.Result.Message, test.message) {
+				t.Errorf("Expected the message to contain %q, got %s", test.message, response.Result.Message)
+			}
+		})
+	}
+}
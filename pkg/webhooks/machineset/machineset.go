@@ -0,0 +1,238 @@
+package machineset
+
+import (
+	"net/http"
+	"os"
+	"slices"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName      string = "machineset-validation"
+	docString        string = `Managed OpenShift customers may not modify or delete the MachineSets of managed machine pools in the openshift-machine-api namespace, other than to scale them. Use OCM to change managed machine pools.`
+	machineAPIPrefix string = "openshift-machine-api"
+	managedLabel     string = "hive.openshift.io/managed"
+)
+
+var (
+	// scalingAnnotations are the cluster autoscaler's bounds, which customers
+	// may change along with the replicas to scale a managed MachineSet
+	scalingAnnotations = []string{
+		"machine.openshift.io/cluster-api-autoscaler-node-group-min-size",
+		"machine.openshift.io/cluster-api-autoscaler-node-group-max-size",
+	}
+
+	log = logf.Log.WithName(WebhookName)
+
+	scope = admissionregv1.NamespacedScope
+	rules = []admissionregv1.RuleWithOperations{
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Update, admissionregv1.Delete},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{"machine.openshift.io"},
+				APIVersions: []string{"*"},
+				Resources:   []string{"machinesets"},
+				Scope:       &scope,
+			},
+		},
+	}
+)
+
+// MachineSetWebhook protects the MachineSets of managed machine pools
+type MachineSetWebhook struct {
+	s *runtime.Scheme
+}
+
+// NewWebhook creates a new webhook
+func NewWebhook() *MachineSetWebhook {
+	scheme := runtime.NewScheme()
+	err := admissionv1.AddToScheme(scheme)
+	if err != nil {
+		log.Error(err, "Fail adding admissionv1 scheme to MachineSetWebhook")
+		os.Exit(1)
+	}
+	err = machinev1beta1.AddToScheme(scheme)
+	if err != nil {
+		log.Error(err, "Fail adding machinev1beta1 scheme to MachineSetWebhook")
+		os.Exit(1)
+	}
+
+	return &MachineSetWebhook{
+		s: scheme,
+	}
+}
+
+// Authorized implements Webhook interface
+func (s *MachineSetWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.authorized(request)
+}
+
+func (s *MachineSetWebhook) authorized(request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if request.Namespace != machineAPIPrefix {
+		ret = admissionctl.Allowed("Only MachineSets in openshift-machine-api are protected")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	if isAdmin(request) {
+		ret = admissionctl.Allowed("Admins and managed service accounts may change managed MachineSets")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	if request.Operation == admissionv1.Delete {
+		ret = response.Denied(response.ManagedMachineSet, "Prevented from deleting a managed MachineSet. Delete the machine pool in OCM instead. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	oldMachineSet, newMachineSet, err := s.renderMachineSets(request)
+	if err != nil {
+		log.Error(err, "Couldn't render MachineSets from the incoming request")
+		ret = admissionctl.Errored(http.StatusBadRequest, err)
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	if isScalingOnly(oldMachineSet, newMachineSet) {
+		ret = admissionctl.Allowed("Managed MachineSets may be scaled")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	log.Info("Denying change to managed MachineSet", "name", request.Name, "user", request.UserInfo.Username)
+	ret = response.Denied(response.ManagedMachineSet, "Prevented from modifying a managed MachineSet other than to scale it. Edit the machine pool in OCM instead. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support")
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// isAdmin checks for cluster admins, SRE and the service accounts managing the
+// cluster (such as the cluster autoscaler)
+func isAdmin(request admissionctl.Request) bool {
+	return identity.IsAdmin(request.UserInfo)
+}
+
+// isScalingOnly checks the update changes nothing but the replicas and the
+// autoscaler's bounds
+func isScalingOnly(oldMachineSet, newMachineSet *machinev1beta1.MachineSet) bool {
+	oldSpec := oldMachineSet.Spec.DeepCopy()
+	oldSpec.Replicas = newMachineSet.Spec.Replicas
+	if !equality.Semantic.DeepEqual(*oldSpec, newMachineSet.Spec) {
+		return false
+	}
+	if !equality.Semantic.DeepEqual(oldMachineSet.Labels, newMachineSet.Labels) {
+		return false
+	}
+	return equality.Semantic.DeepEqual(withoutScalingAnnotations(oldMachineSet.Annotations), withoutScalingAnnotations(newMachineSet.Annotations))
+}
+
+func withoutScalingAnnotations(annotations map[string]string) map[string]string {
+	filtered := map[string]string{}
+	for k, v := range annotations {
+		if !slices.Contains(scalingAnnotations, k) {
+			filtered[k] = v
+		}
+	}
+	return filtered
+}
+
+// renderMachineSets renders the MachineSet before and after the update
+func (s *MachineSetWebhook) renderMachineSets(request admissionctl.Request) (*machinev1beta1.MachineSet, *machinev1beta1.MachineSet, error) {
+	decoder := admissionctl.NewDecoder(s.s)
+	oldMachineSet := &machinev1beta1.MachineSet{}
+	if err := decoder.DecodeRaw(request.OldObject, oldMachineSet); err != nil {
+		return nil, nil, err
+	}
+	newMachineSet := &machinev1beta1.MachineSet{}
+	if err := decoder.DecodeRaw(request.Object, newMachineSet); err != nil {
+		return nil, nil, err
+	}
+	return oldMachineSet, newMachineSet, nil
+}
+
+// GetURI implements Webhook interface
+func (s *MachineSetWebhook) GetURI() string { return "/" + WebhookName }
+
+// Validate implements Webhook interface
+func (s *MachineSetWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	valid = valid && (request.Kind.Kind == "MachineSet")
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *MachineSetWebhook) Name() string { return WebhookName }
+
+// FailurePolicy implements Webhook interface
+func (s *MachineSetWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *MachineSetWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *MachineSetWebhook) Rules() []admissionregv1.RuleWithOperations { return rules }
+
+// ObjectSelector only intercepts the MachineSets of managed machine pools
+func (s *MachineSetWebhook) ObjectSelector() *metav1.LabelSelector {
+	return &metav1.LabelSelector{
+		MatchLabels: map[string]string{
+			managedLabel: "true",
+		},
+	}
+}
+
+// SideEffects implements Webhook interface
+func (s *MachineSetWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *MachineSetWebhook) TimeoutSeconds() int32 { return 2 }
+
+// Doc implements Webhook interface
+func (s *MachineSetWebhook) Doc() string { return docString }
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+func (s *MachineSetWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// RuleDocs implements Webhook interface
+func (s *MachineSetWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers may not delete the MachineSets of managed machine pools in openshift-machine-api.",
+			Exceptions: []string{utils.AdminsException},
+		},
+		{
+			Summary:    "Customers may not modify the MachineSets of managed machine pools other than to scale them.",
+			Exceptions: []string{utils.AdminsException},
+		},
+	}
+}
+
+// ClassicEnabled implements Webhook interface
+func (s *MachineSetWebhook) ClassicEnabled() bool { return true }
+
+// HypershiftEnabled implements Webhook interface. Hosted clusters have no
+// MachineSets; their NodePools are managed from the management cluster.
+func (s *MachineSetWebhook) HypershiftEnabled() bool { return false }
@@ -0,0 +1,146 @@
+package machineset
+
+import (
+	"testing"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+)
+
+func newMachineSet(replicas int32, annotations map[string]string, instanceType string) *machinev1beta1.MachineSet {
+	return &machinev1beta1.MachineSet{
+		TypeMeta: metav1.TypeMeta{APIVersion: "machine.openshift.io/v1beta1", Kind: "MachineSet"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test-worker-us-east-1a",
+			Namespace:   machineAPIPrefix,
+			Labels:      map[string]string{managedLabel: "true"},
+			Annotations: annotations,
+		},
+		Spec: machinev1beta1.MachineSetSpec{
+			Replicas: ptr.To(replicas),
+			Template: machinev1beta1.MachineTemplateSpec{
+				Spec: machinev1beta1.MachineSpec{
+					ProviderSpec: machinev1beta1.ProviderSpec{
+						Value: &runtime.RawExtension{Raw: []byte(`{"instanceType":"` + instanceType + `"}`)},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestAuthorized(t *testing.T) {
+	minSize := "machine.openshift.io/cluster-api-autoscaler-node-group-min-size"
+	base := newMachineSet(2, map[string]string{"machine.openshift.io/memoryMb": "16384"}, "m5.xlarge")
+
+	tests := []struct {
+		name      string
+		operation admissionv1.Operation
+		namespace string
+		username  string
+		groups    []string
+		newObj    *machinev1beta1.MachineSet
+		allowed   bool
+	}{
+		{
+			name:      "customer scales the machineset",
+			operation: admissionv1.Update,
+			namespace: machineAPIPrefix,
+			username:  "customer",
+			groups:    []string{"system:authenticated"},
+			newObj:    newMachineSet(5, map[string]string{"machine.openshift.io/memoryMb": "16384"}, "m5.xlarge"),
+			allowed:   true,
+		},
+		{
+			name:      "customer changes the autoscaler bounds",
+			operation: admissionv1.Update,
+			namespace: machineAPIPrefix,
+			username:  "customer",
+			groups:    []string{"system:authenticated"},
+			newObj:    newMachineSet(2, map[string]string{"machine.openshift.io/memoryMb": "16384", minSize: "1"}, "m5.xlarge"),
+			allowed:   true,
+		},
+		{
+			name:      "customer changes the instance type",
+			operation: admissionv1.Update,
+			namespace: machineAPIPrefix,
+			username:  "customer",
+			groups:    []string{"system:authenticated"},
+			newObj:    newMachineSet(2, map[string]string{"machine.openshift.io/memoryMb": "16384"}, "m5.4xlarge"),
+			allowed:   false,
+		},
+		{
+			name:      "customer changes another annotation",
+			operation: admissionv1.Update,
+			namespace: machineAPIPrefix,
+			username:  "customer",
+			groups:    []string{"system:authenticated"},
+			newObj:    newMachineSet(2, map[string]string{"machine.openshift.io/memoryMb": "1"}, "m5.xlarge"),
+			allowed:   false,
+		},
+		{
+			name:      "customer deletes the machineset",
+			operation: admissionv1.Delete,
+			namespace: machineAPIPrefix,
+			username:  "customer",
+			groups:    []string{"system:authenticated"},
+			allowed:   false,
+		},
+		{
+			name:      "sre deletes the machineset",
+			operation: admissionv1.Delete,
+			namespace: machineAPIPrefix,
+			username:  "sre",
+			groups:    []string{"system:serviceaccounts:openshift-backplane-srep"},
+			allowed:   true,
+		},
+		{
+			name:      "backplane-cluster-admin changes the instance type",
+			operation: admissionv1.Update,
+			namespace: machineAPIPrefix,
+			username:  "backplane-cluster-admin",
+			newObj:    newMachineSet(2, map[string]string{"machine.openshift.io/memoryMb": "16384"}, "m5.4xlarge"),
+			allowed:   true,
+		},
+		{
+			name:      "machine-api service account changes the machineset",
+			operation: admissionv1.Update,
+			namespace: machineAPIPrefix,
+			username:  "system:serviceaccount:openshift-machine-api:cluster-autoscaler",
+			groups:    []string{"system:serviceaccounts:openshift-machine-api"},
+			newObj:    newMachineSet(2, map[string]string{"machine.openshift.io/memoryMb": "16384"}, "m5.4xlarge"),
+			allowed:   true,
+		},
+		{
+			name:      "customer deletes a machineset outside openshift-machine-api",
+			operation: admissionv1.Delete,
+			namespace: "customer-ns",
+			username:  "customer",
+			groups:    []string{"system:authenticated"},
+			allowed:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := testutils.NewRequest(t, metav1.GroupVersionKind{Group: "machine.openshift.io", Version: "v1beta1", Kind: "MachineSet"}, test.operation, authenticationv1.UserInfo{Username: test.username, Groups: test.groups}, test.namespace, base.Name, test.newObj, base)
+			hook := NewWebhook()
+			if !hook.Validate(request) {
+				t.Fatalf("Expected request to be valid")
+			}
+			response := hook.Authorized(request)
+			if response.Allowed != test.allowed {
+				t.Fatalf("Expected allowed %v, got %v: %v", test.allowed, response.Allowed, response.Result)
+			}
+			if response.UID != request.UID {
+				t.Fatalf("Expected UID %s, got %s", request.UID, response.UID)
+			}
+		})
+	}
+}
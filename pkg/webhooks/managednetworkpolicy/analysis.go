@@ -0,0 +1,126 @@
+package managednetworkpolicy
+
+import (
+	"fmt"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// platformNamespace is a namespace of the platform which must reach customer
+// workloads
+type platformNamespace struct {
+	name string
+	// labels are those NetworkPolicies select the namespace by
+	labels labels.Set
+}
+
+// requiredIngress is ingress the platform needs into customer workloads. It
+// is allowed when any of its namespaces may reach the pods.
+type requiredIngress struct {
+	purpose    string
+	namespaces []platformNamespace
+}
+
+// requiredIngresses are checked for each customer NetworkPolicy isolating pods
+// for ingress
+var requiredIngresses = []requiredIngress{
+	{
+		purpose: "metrics scraping",
+		namespaces: []platformNamespace{
+			{
+				name: "openshift-monitoring",
+				labels: labels.Set{
+					"kubernetes.io/metadata.name":       "openshift-monitoring",
+					"network.openshift.io/policy-group": "monitoring",
+				},
+			},
+			{
+				name:   "openshift-user-workload-monitoring",
+				labels: labels.Set{"kubernetes.io/metadata.name": "openshift-user-workload-monitoring"},
+			},
+		},
+	},
+}
+
+// names returns the names of the required ingress' namespaces
+func (r requiredIngress) names() string {
+	names := make([]string, 0, len(r.namespaces))
+	for _, ns := range r.namespaces {
+		names = append(names, ns.name)
+	}
+	return strings.Join(names, " or ")
+}
+
+// isolatesIngress is whether the policy isolates the pods it selects for
+// ingress, so they only accept what some policy allows
+func isolatesIngress(np *networkingv1.NetworkPolicy) bool {
+	if len(np.Spec.PolicyTypes) == 0 {
+		// Ingress is implied when no types are set
+		return true
+	}
+	for _, policyType := range np.Spec.PolicyTypes {
+		if policyType == networkingv1.PolicyTypeIngress {
+			return true
+		}
+	}
+	return false
+}
+
+// allows is whether the policy allows ingress from any of the required
+// ingress' namespaces. Ports aren't checked, as which port a workload serves
+// metrics on isn't known, nor are pod selectors within namespaces.
+func allows(np *networkingv1.NetworkPolicy, required requiredIngress) bool {
+	for _, rule := range np.Spec.Ingress {
+		if reason := ruleAllows(rule, required); reason == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleAllows returns why the ingress rule doesn't allow any of the required
+// ingress' namespaces, or "" if it does
+func ruleAllows(rule networkingv1.NetworkPolicyIngressRule, required requiredIngress) string {
+	if len(rule.From) == 0 {
+		// A rule without peers allows every source
+		return ""
+	}
+	reasons := []string{}
+	for _, peer := range rule.From {
+		switch {
+		case peer.IPBlock != nil:
+			reasons = append(reasons, fmt.Sprintf("the IP block %s isn't matched by namespace", peer.IPBlock.CIDR))
+		case peer.NamespaceSelector == nil:
+			reasons = append(reasons, "a pod selector without a namespace selector only matches pods of the policy's own namespace")
+		default:
+			selector, err := metav1.LabelSelectorAsSelector(peer.NamespaceSelector)
+			if err != nil {
+				reasons = append(reasons, fmt.Sprintf("the namespace selector is invalid: %v", err))
+				continue
+			}
+			for _, ns := range required.namespaces {
+				if selector.Matches(ns.labels) {
+					return ""
+				}
+			}
+			reasons = append(reasons, fmt.Sprintf("the namespace selector %s doesn't match %s", metav1.FormatLabelSelector(peer.NamespaceSelector), required.names()))
+		}
+	}
+	return strings.Join(reasons, ", ")
+}
+
+// explain describes, rule by rule, why the policy doesn't allow the required
+// ingress
+func explain(np *networkingv1.NetworkPolicy, required requiredIngress) string {
+	if len(np.Spec.Ingress) == 0 {
+		return "it has no ingress rules, so it denies all ingress"
+	}
+	rules := make([]string, 0, len(np.Spec.Ingress))
+	for i, rule := range np.Spec.Ingress {
+		rules = append(rules, fmt.Sprintf("ingress rule %d: %s", i+1, ruleAllows(rule, required)))
+	}
+	return strings.Join(rules, "; ")
+}
@@ -0,0 +1,312 @@
+package managednetworkpolicy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	hookconfig "github.com/openshift/managed-cluster-validating-webhooks/pkg/config"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/labeledresources"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "managednetworkpolicy-validation"
+	docString   string = `Managed OpenShift customers may not change or delete the NetworkPolicies labelled %s=true which Red Hat manages in any namespace, nor label their own NetworkPolicies so. Customers may not create NetworkPolicies cutting their workloads off from the ingress the platform needs, such as %s, unless another NetworkPolicy in the namespace still allows it.`
+)
+
+var (
+	scope = admissionregv1.NamespacedScope
+	rules = []admissionregv1.RuleWithOperations{
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Create, admissionregv1.Update, admissionregv1.Delete},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{"networking.k8s.io"},
+				APIVersions: []string{"v1"},
+				Resources:   []string{"networkpolicies"},
+				Scope:       &scope,
+			},
+		},
+	}
+	log = logf.Log.WithName(WebhookName)
+)
+
+// ManagedNetworkPolicyWebhook protects managed NetworkPolicies, and the
+// ingress the platform needs into customer workloads
+type ManagedNetworkPolicyWebhook struct {
+	s          *runtime.Scheme
+	decoder    admissionctl.Decoder
+	kubeClient client.Client
+}
+
+// NewWebhook creates a new webhook
+func NewWebhook() *ManagedNetworkPolicyWebhook {
+	scheme, err := k8sutil.SharedScheme()
+	if err != nil {
+		log.Error(err, "Fail getting the shared scheme for ManagedNetworkPolicyWebhook")
+		os.Exit(1)
+	}
+	decoder, err := k8sutil.SharedDecoder()
+	if err != nil {
+		log.Error(err, "Fail getting the shared decoder for ManagedNetworkPolicyWebhook")
+		os.Exit(1)
+	}
+
+	return &ManagedNetworkPolicyWebhook{
+		s:       scheme,
+		decoder: decoder,
+	}
+}
+
+// InjectClient implements ClientWebhook interface
+func (s *ManagedNetworkPolicyWebhook) InjectClient(c client.Client) {
+	s.kubeClient = c
+}
+
+// CachedObjects implements ClientWebhook interface. The other NetworkPolicies
+// of a namespace are read for the ingress they already allow.
+func (s *ManagedNetworkPolicyWebhook) CachedObjects() []client.Object {
+	return []client.Object{&networkingv1.NetworkPolicy{}}
+}
+
+// Authorized implements Webhook interface
+func (s *ManagedNetworkPolicyWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.AuthorizedContext(context.Background(), request)
+}
+
+// AuthorizedContext implements ContextWebhook interface
+func (s *ManagedNetworkPolicyWebhook) AuthorizedContext(ctx context.Context, request admissionctl.Request) admissionctl.Response {
+	return s.authorized(ctx, request)
+}
+
+func (s *ManagedNetworkPolicyWebhook) authorized(ctx context.Context, request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if identity.IsAdmin(request.UserInfo) {
+		ret = admissionctl.Allowed("Admins and managed service accounts may change any NetworkPolicy")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	var np, old *networkingv1.NetworkPolicy
+	if len(request.Object.Raw) > 0 {
+		np = &networkingv1.NetworkPolicy{}
+		if err := s.decoder.DecodeRaw(request.Object, np); err != nil {
+			log.Error(err, "Couldn't decode the NetworkPolicy from the request")
+			ret = admissionctl.Errored(http.StatusBadRequest, err)
+			ret.UID = request.AdmissionRequest.UID
+			return ret
+		}
+	}
+	if len(request.OldObject.Raw) > 0 {
+		old = &networkingv1.NetworkPolicy{}
+		if err := s.decoder.DecodeRaw(request.OldObject, old); err != nil {
+			log.Error(err, "Couldn't decode the old NetworkPolicy from the request")
+			ret = admissionctl.Errored(http.StatusBadRequest, err)
+			ret.UID = request.AdmissionRequest.UID
+			return ret
+		}
+	}
+
+	// The old object is checked so removing the label is denied, the new one
+	// so customers can't make their own policies look managed
+	if (old != nil && isManaged(old)) || (np != nil && isManaged(np)) {
+		log.Info("Denying change to managed NetworkPolicy", "namespace", request.Namespace, "name", request.Name, "operation", request.Operation, "user", request.UserInfo.Username)
+		ret = response.Denied(response.ManagedNetworkPolicy, fmt.Sprintf("Prevented from %s NetworkPolicy %s in namespace %s, as NetworkPolicies labelled %s=true are managed by Red Hat. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", verb(request.Operation), request.Name, request.Namespace, labeledresources.ManagedLabel))
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	// Managed NetworkPolicies are protected in every namespace, so the webhook
+	// doesn't implement NamespaceExclusionWebhook, but customers may not
+	// create NetworkPolicies in managed namespaces to analyze
+	if np == nil || hookconfig.ExcludedNamespaces.Excludes(request.Namespace) || !isolatesIngress(np) {
+		ret = admissionctl.Allowed("NetworkPolicy doesn't isolate customer workloads from the platform")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	missing := []requiredIngress{}
+	for _, required := range requiredIngresses {
+		if !allows(np, required) {
+			missing = append(missing, required)
+		}
+	}
+	if len(missing) == 0 {
+		ret = admissionctl.Allowed("NetworkPolicy allows the ingress the platform needs")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	if err := s.ensureClient(); err != nil {
+		log.Error(err, "Failed to create a client to read NetworkPolicies")
+		ret = admissionctl.Allowed("Unable to read the namespace's NetworkPolicies")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	others := &networkingv1.NetworkPolicyList{}
+	if err := s.kubeClient.List(ctx, others, client.InNamespace(request.Namespace)); err != nil {
+		log.Error(err, "Failed to read the namespace's NetworkPolicies", "namespace", request.Namespace)
+		ret = admissionctl.Allowed("Unable to read the namespace's NetworkPolicies")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	missing = notAllowedElsewhere(np, others.Items, missing)
+	if len(missing) == 0 {
+		ret = admissionctl.Allowed("Other NetworkPolicies allow the ingress the platform needs")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	analysis := make([]string, 0, len(missing))
+	for _, required := range missing {
+		analysis = append(analysis, fmt.Sprintf("%s needs ingress from %s, but %s", required.purpose, required.names(), explain(np, required)))
+	}
+	log.Info("Denying NetworkPolicy isolating workloads from the platform", "namespace", request.Namespace, "name", request.Name, "user", request.UserInfo.Username)
+	ret = response.Denied(response.NetworkPolicyBlocksPlatformIngress, fmt.Sprintf("Prevented from %s NetworkPolicy %s in namespace %s, which would cut the pods it selects off from the platform: %s. Add an ingress rule allowing those namespaces, or a NetworkPolicy allowing them to every pod of the namespace. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", verb(request.Operation), request.Name, request.Namespace, strings.Join(analysis, ". ")))
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// isManaged is whether the NetworkPolicy is labelled as managed by Red Hat
+func isManaged(np *networkingv1.NetworkPolicy) bool {
+	return np.Labels[labeledresources.ManagedLabel] == "true"
+}
+
+// notAllowedElsewhere returns the required ingresses which no other
+// NetworkPolicy of the namespace allows to the pods np selects. Only policies
+// selecting every pod, or the same pods as np, are considered.
+func notAllowedElsewhere(np *networkingv1.NetworkPolicy, others []networkingv1.NetworkPolicy, missing []requiredIngress) []requiredIngress {
+	remaining := []requiredIngress{}
+	for _, required := range missing {
+		allowed := false
+		for i := range others {
+			other := &others[i]
+			if other.Name == np.Name || !isolatesIngress(other) {
+				continue
+			}
+			selectsAll := len(other.Spec.PodSelector.MatchLabels) == 0 && len(other.Spec.PodSelector.MatchExpressions) == 0
+			if (selectsAll || equality.Semantic.DeepEqual(other.Spec.PodSelector, np.Spec.PodSelector)) && allows(other, required) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			remaining = append(remaining, required)
+		}
+	}
+	return remaining
+}
+
+// verb describes the operation in a denial
+func verb(operation admissionv1.Operation) string {
+	switch operation {
+	case admissionv1.Create:
+		return "creating"
+	case admissionv1.Delete:
+		return "deleting"
+	}
+	return "updating"
+}
+
+// ensureClient creates a client if none was injected
+func (s *ManagedNetworkPolicyWebhook) ensureClient() error {
+	if s.kubeClient != nil {
+		return nil
+	}
+	var err error
+	s.kubeClient, err = k8sutil.KubeClient(s.s)
+	return err
+}
+
+// GetURI implements Webhook interface
+func (s *ManagedNetworkPolicyWebhook) GetURI() string { return "/" + WebhookName }
+
+// Validate implements Webhook interface
+func (s *ManagedNetworkPolicyWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	valid = valid && (request.Kind.Kind == "NetworkPolicy")
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *ManagedNetworkPolicyWebhook) Name() string { return WebhookName }
+
+// FailurePolicy implements Webhook interface
+func (s *ManagedNetworkPolicyWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *ManagedNetworkPolicyWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *ManagedNetworkPolicyWebhook) Rules() []admissionregv1.RuleWithOperations { return rules }
+
+// ObjectSelector implements Webhook interface
+func (s *ManagedNetworkPolicyWebhook) ObjectSelector() *metav1.LabelSelector { return nil }
+
+// SideEffects implements Webhook interface
+func (s *ManagedNetworkPolicyWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *ManagedNetworkPolicyWebhook) TimeoutSeconds() int32 { return 2 }
+
+// Doc implements Webhook interface
+func (s *ManagedNetworkPolicyWebhook) Doc() string {
+	return fmt.Sprintf(docString, labeledresources.ManagedLabel, requiredPurposes())
+}
+
+// RuleDocs implements Webhook interface
+func (s *ManagedNetworkPolicyWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    fmt.Sprintf("Customers may not change or delete NetworkPolicies labelled %s=true, nor create NetworkPolicies with the label.", labeledresources.ManagedLabel),
+			Exceptions: []string{utils.AdminsException},
+		},
+		{
+			Summary:    fmt.Sprintf("Customers may not create or update NetworkPolicies isolating pods from the ingress the platform needs, such as %s, unless another NetworkPolicy selecting every pod of the namespace, or the same pods, allows it.", requiredPurposes()),
+			Exceptions: []string{utils.AdminsException, "NetworkPolicies in managed namespaces"},
+		},
+	}
+}
+
+// requiredPurposes lists the purposes of the required ingresses
+func requiredPurposes() string {
+	purposes := make([]string, 0, len(requiredIngresses))
+	for _, required := range requiredIngresses {
+		purposes = append(purposes, fmt.Sprintf("%s from %s", required.purpose, required.names()))
+	}
+	return strings.Join(purposes, ", ")
+}
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+func (s *ManagedNetworkPolicyWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// ClassicEnabled implements Webhook interface
+func (s *ManagedNetworkPolicyWebhook) ClassicEnabled() bool { return true }
+
+// HypershiftEnabled implements Webhook interface
+func (s *ManagedNetworkPolicyWebhook) HypershiftEnabled() bool { return true }
@@ -0,0 +1,166 @@
+package managednetworkpolicy
+
+import (
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/labeledresources"
+)
+
+func policy(namespace, name string, managed bool, ingress ...networkingv1.NetworkPolicyIngressRule) *networkingv1.NetworkPolicy {
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec: networkingv1.NetworkPolicySpec{
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress:     ingress,
+		},
+	}
+	if managed {
+		np.Labels = map[string]string{labeledresources.ManagedLabel: "true"}
+	}
+	return np
+}
+
+func fromNamespace(labels map[string]string) networkingv1.NetworkPolicyIngressRule {
+	return networkingv1.NetworkPolicyIngressRule{
+		From: []networkingv1.NetworkPolicyPeer{{NamespaceSelector: &metav1.LabelSelector{MatchLabels: labels}}},
+	}
+}
+
+func TestAuthorized(t *testing.T) {
+	allowMonitoring := policy("payments", "allow-from-openshift-monitoring", true, fromNamespace(map[string]string{"network.openshift.io/policy-group": "monitoring"}))
+	sameNamespace := networkingv1.NetworkPolicyIngressRule{From: []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{}}}}
+
+	tests := []struct {
+		name      string
+		username  string
+		operation admissionv1.Operation
+		object    *networkingv1.NetworkPolicy
+		old       *networkingv1.NetworkPolicy
+		existing  []client.Object
+		allowed   bool
+		message   string
+	}{
+		{
+			name:      "delete managed policy",
+			username:  "customer",
+			operation: admissionv1.Delete,
+			old:       allowMonitoring,
+			allowed:   false,
+			message:   "Prevented from deleting NetworkPolicy allow-from-openshift-monitoring in namespace payments",
+		},
+		{
+			name:      "remove managed label",
+			username:  "customer",
+			operation: admissionv1.Update,
+			old:       allowMonitoring,
+			object:    policy("payments", "allow-from-openshift-monitoring", false),
+			allowed:   false,
+		},
+		{
+			name:      "managed policy in platform namespace",
+			username:  "customer",
+			operation: admissionv1.Delete,
+			old:       policy("openshift-monitoring", "allow-all", true),
+			allowed:   false,
+		},
+		{
+			name:      "create policy labelled managed",
+			username:  "customer",
+			operation: admissionv1.Create,
+			object:    policy("payments", "allow-all", true, networkingv1.NetworkPolicyIngressRule{}),
+			allowed:   false,
+		},
+		{
+			name:      "sre deletes managed policy",
+			username:  "backplane-cluster-admin",
+			operation: admissionv1.Delete,
+			old:       allowMonitoring,
+			allowed:   true,
+		},
+		{
+			name:      "deny-all without managed policy",
+			username:  "customer",
+			operation: admissionv1.Create,
+			object:    policy("payments", "deny-all", false),
+			allowed:   false,
+			message:   "metrics scraping needs ingress from openshift-monitoring or openshift-user-workload-monitoring, but it has no ingress rules",
+		},
+		{
+			name:      "rule-level analysis",
+			username:  "customer",
+			operation: admissionv1.Create,
+			object:    policy("payments", "frontend", false, sameNamespace, fromNamespace(map[string]string{"team": "payments"})),
+			allowed:   false,
+			message:   "ingress rule 1: a pod selector without a namespace selector only matches pods of the policy's own namespace; ingress rule 2: the namespace selector team=payments doesn't match",
+		},
+		{
+			name:      "deny-all with managed policy allowing monitoring",
+			username:  "customer",
+			operation: admissionv1.Create,
+			object:    policy("payments", "deny-all", false),
+			existing:  []client.Object{allowMonitoring},
+			allowed:   true,
+		},
+		{
+			name:      "policy allowing user workload monitoring",
+			username:  "customer",
+			operation: admissionv1.Create,
+			object:    policy("payments", "frontend", false, sameNamespace, fromNamespace(map[string]string{"kubernetes.io/metadata.name": "openshift-user-workload-monitoring"})),
+			allowed:   true,
+		},
+		{
+			name:      "egress only policy",
+			username:  "customer",
+			operation: admissionv1.Create,
+			object: &networkingv1.NetworkPolicy{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "egress"},
+				Spec:       networkingv1.NetworkPolicySpec{PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress}},
+			},
+			allowed: true,
+		},
+		{
+			name:      "delete customer policy",
+			username:  "customer",
+			operation: admissionv1.Delete,
+			old:       policy("payments", "deny-all", false),
+			allowed:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hook := NewWebhook()
+			hook.InjectClient(fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(test.existing...).Build())
+
+			np := test.object
+			if np == nil {
+				np = test.old
+			}
+			gvk := metav1.GroupVersionKind{Group: "networking.k8s.io", Version: "v1", Kind: "NetworkPolicy"}
+			request := testutils.NewRequest(t, gvk, test.operation, authenticationv1.UserInfo{Username: test.username}, np.Namespace, np.Name, test.object, test.old)
+			if !hook.Validate(request) {
+				t.Fatalf("Expected request to be valid")
+			}
+			response := hook.Authorized(request)
+			if response.Allowed != test.allowed {
+				t.Fatalf("Expected allowed %v, got %v: %v", test.allowed, response.Allowed, response.Result)
+			}
+			if response.UID != request.UID {
+				t.Fatalf("Expected UID %s, got %s", request.UID, response.UID)
+			}
+			if test.message != "" && !strings.Contains(response.Result.Message, test.message) {
+				t.Errorf("Expected the message to contain %q, got %s", test.message, response.Result.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package managedrbac
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/events"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "managedrbac-validation"
+	docString   string = `Managed OpenShift customers, including cluster admins, may not delete, or change the rules, subjects or role of, the ClusterRoles and ClusterRoleBindings which grant Red Hat SRE access to the cluster: those named %s* or %s*, and ClusterRoleBindings with SRE subjects such as the %s groups. Attempts are recorded as Warning Events.`
+
+	// deniedReason is the reason of the Events recorded about denied attempts
+	deniedReason string = "ManagedRBACChangeDenied"
+)
+
+var (
+	scope = admissionregv1.ClusterScope
+	rules = []admissionregv1.RuleWithOperations{
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Update, admissionregv1.Delete},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{"rbac.authorization.k8s.io"},
+				APIVersions: []string{"*"},
+				Resources:   []string{"clusterroles", "clusterrolebindings"},
+				Scope:       &scope,
+			},
+		},
+	}
+	log = logf.Log.WithName(WebhookName)
+
+	// protectedPrefixes are the prefixes of the names of the ClusterRoles and
+	// ClusterRoleBindings granting SRE access
+	protectedPrefixes = []string{"backplane-", "osd-sre-"}
+
+	// sreGroups are the groups SRE are members of on clusters without
+	// backplane
+	sreGroups = []string{"osd-sre-admins", "osd-sre-cluster-admins"}
+)
+
+// ManagedRBACWebhook protects the ClusterRoles and ClusterRoleBindings which
+// grant SRE access to the cluster
+type ManagedRBACWebhook struct {
+	decoder admissionctl.Decoder
+}
+
+// NewWebhook creates a new webhook
+func NewWebhook() *ManagedRBACWebhook {
+	decoder, err := k8sutil.SharedDecoder()
+	if err != nil {
+		log.Error(err, "Fail getting the shared decoder for ManagedRBACWebhook")
+		os.Exit(1)
+	}
+
+	return &ManagedRBACWebhook{
+		decoder: decoder,
+	}
+}
+
+// Authorized implements Webhook interface
+func (s *ManagedRBACWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.authorized(request)
+}
+
+func (s *ManagedRBACWebhook) authorized(request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if identity.IsManager(request.UserInfo) {
+		ret = admissionctl.Allowed("SRE and managed service accounts may change the managed RBAC")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	var protected, changed bool
+	var err error
+	switch request.Kind.Kind {
+	case "ClusterRole":
+		protected, changed, err = s.clusterRoleChange(request)
+	default:
+		protected, changed, err = s.clusterRoleBindingChange(request)
+	}
+	if err != nil {
+		log.Error(err, "Couldn't decode the request", "kind", request.Kind.Kind, "name", request.Name)
+		ret = admissionctl.Errored(http.StatusBadRequest, err)
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	if !protected {
+		ret = admissionctl.Allowed(fmt.Sprintf("%s %s doesn't grant SRE access", request.Kind.Kind, request.Name))
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	if !changed {
+		ret = admissionctl.Allowed("The access granted is unchanged")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	verb := "changing"
+	if request.Operation == admissionv1.Delete {
+		verb = "deleting"
+	}
+	log.Info("Denying change of managed RBAC", "kind", request.Kind.Kind, "name", request.Name, "operation", request.Operation, "user", request.UserInfo.Username)
+	if request.DryRun == nil || !*request.DryRun {
+		events.Warning(corev1.ObjectReference{
+			APIVersion: rbacv1.SchemeGroupVersion.String(),
+			Kind:       request.Kind.Kind,
+			Name:       request.Name,
+		}, deniedReason, fmt.Sprintf("%s was prevented from %s %s %s, which grants Red Hat SRE access to the cluster", request.UserInfo.Username, verb, request.Kind.Kind, request.Name))
+	}
+
+	ret = response.Denied(response.SREAccess, fmt.Sprintf("Prevented from %s %s %s, which grants Red Hat SRE access to the cluster. Red Hat SRE need this access to support the cluster, so it can't be removed or changed, even by cluster admins. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", verb, request.Kind.Kind, request.Name))
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// clusterRoleChange returns whether the ClusterRole grants SRE access, and
+// whether the request deletes it or changes what it grants
+func (s *ManagedRBACWebhook) clusterRoleChange(request admissionctl.Request) (bool, bool, error) {
+	old := &rbacv1.ClusterRole{}
+	if err := s.decoder.DecodeRaw(request.OldObject, old); err != nil {
+		return false, false, err
+	}
+	if !hasProtectedName(old.Name) {
+		return false, false, nil
+	}
+	if request.Operation == admissionv1.Delete {
+		return true, true, nil
+	}
+
+	role := &rbacv1.ClusterRole{}
+	if err := s.decoder.DecodeRaw(request.Object, role); err != nil {
+		return true, false, err
+	}
+	changed := !equality.Semantic.DeepEqual(old.Rules, role.Rules) || !equality.Semantic.DeepEqual(old.AggregationRule, role.AggregationRule)
+	return true, changed, nil
+}
+
+// clusterRoleBindingChange returns whether the ClusterRoleBinding grants SRE
+// access, and whether the request deletes it or changes what it grants
+func (s *ManagedRBACWebhook) clusterRoleBindingChange(request admissionctl.Request) (bool, bool, error) {
+	old := &rbacv1.ClusterRoleBinding{}
+	if err := s.decoder.DecodeRaw(request.OldObject, old); err != nil {
+		return false, false, err
+	}
+	if !hasProtectedName(old.Name) && !slices.ContainsFunc(old.Subjects, isSRESubject) {
+		return false, false, nil
+	}
+	if request.Operation == admissionv1.Delete {
+		return true, true, nil
+	}
+
+	binding := &rbacv1.ClusterRoleBinding{}
+	if err := s.decoder.DecodeRaw(request.Object, binding); err != nil {
+		return true, false, err
+	}
+	changed := !equality.Semantic.DeepEqual(old.Subjects, binding.Subjects) || old.RoleRef != binding.RoleRef
+	return true, changed, nil
+}
+
+// hasProtectedName returns true if the name marks a ClusterRole or
+// ClusterRoleBinding granting SRE access
+func hasProtectedName(name string) bool {
+	for _, prefix := range protectedPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSRESubject returns true if the subject is SRE, as the identity policy
+// defines them, or one of the SRE groups
+func isSRESubject(subject rbacv1.Subject) bool {
+	user := authenticationv1.UserInfo{}
+	switch subject.Kind {
+	case rbacv1.GroupKind:
+		if slices.Contains(sreGroups, subject.Name) {
+			return true
+		}
+		user.Groups = []string{subject.Name}
+	case rbacv1.UserKind:
+		user.Username = subject.Name
+	case rbacv1.ServiceAccountKind:
+		user.Username = fmt.Sprintf("system:serviceaccount:%s:%s", subject.Namespace, subject.Name)
+		user.Groups = []string{"system:serviceaccounts", "system:serviceaccounts:" + subject.Namespace}
+	}
+	return identity.IsSRE(user)
+}
+
+// GetURI implements Webhook interface
+func (s *ManagedRBACWebhook) GetURI() string { return "/" + WebhookName }
+
+// Validate implements Webhook interface
+func (s *ManagedRBACWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	valid = valid && (request.Kind.Kind == "ClusterRole" || request.Kind.Kind == "ClusterRoleBinding")
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *ManagedRBACWebhook) Name() string { return WebhookName }
+
+// FailurePolicy implements Webhook interface
+func (s *ManagedRBACWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *ManagedRBACWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *ManagedRBACWebhook) Rules() []admissionregv1.RuleWithOperations { return rules }
+
+// ObjectSelector implements Webhook interface
+func (s *ManagedRBACWebhook) ObjectSelector() *metav1.LabelSelector { return nil }
+
+// SideEffects implements Webhook interface. Events are recorded for denied
+// changes, except for dry runs.
+func (s *ManagedRBACWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNoneOnDryRun
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *ManagedRBACWebhook) TimeoutSeconds() int32 { return 2 }
+
+// Doc implements Webhook interface
+func (s *ManagedRBACWebhook) Doc() string {
+	return fmt.Sprintf(docString, protectedPrefixes[0], protectedPrefixes[1], strings.Join(sreGroups, " and "))
+}
+
+// RuleDocs implements Webhook interface
+func (s *ManagedRBACWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers, including cluster admins, may not delete the ClusterRoles and ClusterRoleBindings granting Red Hat SRE access, nor change their rules, subjects or role.",
+			Exceptions: []string{utils.SREException, utils.PrivilegedServiceAccountsException, "Hive, as system:admin"},
+		},
+	}
+}
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+func (s *ManagedRBACWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// ClassicEnabled implements Webhook interface
+func (s *ManagedRBACWebhook) ClassicEnabled() bool { return true }
+
+// HypershiftEnabled implements Webhook interface
+func (s *ManagedRBACWebhook) HypershiftEnabled() bool { return true }
@@ -0,0 +1,222 @@
+package managedrbac
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/events"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+)
+
+var (
+	clusterAdmin = authenticationv1.UserInfo{Username: "kube:admin", Groups: []string{"system:cluster-admins", "system:authenticated"}}
+	customer     = authenticationv1.UserInfo{Username: "customer", Groups: []string{"dedicated-admins", "system:authenticated"}}
+	sre          = authenticationv1.UserInfo{Username: "backplane-cluster-admin"}
+	hive         = authenticationv1.UserInfo{Username: "system:admin"}
+)
+
+func newBinding(name, roleName string, subjects ...rbacv1.Subject) *rbacv1.ClusterRoleBinding {
+	return &rbacv1.ClusterRoleBinding{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRoleBinding"},
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: roleName},
+		Subjects:   subjects,
+	}
+}
+
+func newRole(name string, rules ...rbacv1.PolicyRule) *rbacv1.ClusterRole {
+	return &rbacv1.ClusterRole{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole"},
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Rules:      rules,
+	}
+}
+
+func newRequest(t *testing.T, user authenticationv1.UserInfo, operation admissionv1.Operation, oldObj, obj metav1.Object) admissionctl.Request {
+	t.Helper()
+	kind := metav1.GroupVersionKind{Group: rbacv1.GroupName, Version: "v1", Kind: "ClusterRoleBinding"}
+	if _, ok := oldObj.(*rbacv1.ClusterRole); ok {
+		kind.Kind = "ClusterRole"
+	}
+	return testutils.NewRequest(t, kind, operation, user, "", oldObj.GetName(), obj, oldObj)
+}
+
+func TestAuthorized(t *testing.T) {
+	sreGroup := rbacv1.Subject{APIGroup: rbacv1.GroupName, Kind: rbacv1.GroupKind, Name: "osd-sre-admins"}
+	backplaneGroup := rbacv1.Subject{APIGroup: rbacv1.GroupName, Kind: rbacv1.GroupKind, Name: "system:serviceaccounts:openshift-backplane-srep"}
+	backplaneSA := rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Namespace: "openshift-backplane-srep", Name: "srep-1234"}
+	customerUser := rbacv1.Subject{APIGroup: rbacv1.GroupName, Kind: rbacv1.UserKind, Name: "customer"}
+	readPods := rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}}
+	allResources := rbacv1.PolicyRule{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}}
+
+	tests := []struct {
+		name      string
+		user      authenticationv1.UserInfo
+		operation admissionv1.Operation
+		oldObj    metav1.Object
+		obj       metav1.Object
+		allowed   bool
+	}{
+		{
+			name:      "cluster admin deletes backplane binding",
+			user:      clusterAdmin,
+			operation: admissionv1.Delete,
+			oldObj:    newBinding("backplane-srep-admins-cluster", "cluster-admin", backplaneGroup),
+			allowed:   false,
+		},
+		{
+			name:      "customer deletes binding of SRE group",
+			user:      customer,
+			operation: admissionv1.Delete,
+			oldObj:    newBinding("sre-admins", "cluster-admin", sreGroup),
+			allowed:   false,
+		},
+		{
+			name:      "cluster admin deletes binding of backplane service account",
+			user:      clusterAdmin,
+			operation: admissionv1.Delete,
+			oldObj:    newBinding("srep-access", "cluster-admin", backplaneSA),
+			allowed:   false,
+		},
+		{
+			name:      "cluster admin removes SRE group from binding",
+			user:      clusterAdmin,
+			operation: admissionv1.Update,
+			oldObj:    newBinding("osd-sre-admins", "cluster-admin", sreGroup),
+			obj:       newBinding("osd-sre-admins", "cluster-admin"),
+			allowed:   false,
+		},
+		{
+			name:      "cluster admin changes role of backplane binding",
+			user:      clusterAdmin,
+			operation: admissionv1.Update,
+			oldObj:    newBinding("backplane-srep-admins-cluster", "cluster-admin", backplaneGroup),
+			obj:       newBinding("backplane-srep-admins-cluster", "view", backplaneGroup),
+			allowed:   false,
+		},
+		{
+			name:      "cluster admin labels backplane binding",
+			user:      clusterAdmin,
+			operation: admissionv1.Update,
+			oldObj:    newBinding("backplane-srep-admins-cluster", "cluster-admin", backplaneGroup),
+			obj: func() metav1.Object {
+				b := newBinding("backplane-srep-admins-cluster", "cluster-admin", backplaneGroup)
+				b.Labels = map[string]string{"team": "a"}
+				return b
+			}(),
+			allowed: true,
+		},
+		{
+			name:      "cluster admin deletes own binding",
+			user:      clusterAdmin,
+			operation: admissionv1.Delete,
+			oldObj:    newBinding("customer-admins", "cluster-admin", customerUser),
+			allowed:   true,
+		},
+		{
+			name:      "cluster admin deletes backplane role",
+			user:      clusterAdmin,
+			operation: admissionv1.Delete,
+			oldObj:    newRole("backplane-srep-admins-cluster", allResources),
+			allowed:   false,
+		},
+		{
+			name:      "customer reduces rules of backplane role",
+			user:      customer,
+			operation: admissionv1.Update,
+			oldObj:    newRole("backplane-srep-admins-cluster", allResources),
+			obj:       newRole("backplane-srep-admins-cluster", readPods),
+			allowed:   false,
+		},
+		{
+			name:      "cluster admin changes own role",
+			user:      clusterAdmin,
+			operation: admissionv1.Update,
+			oldObj:    newRole("customer-readers", readPods),
+			obj:       newRole("customer-readers", allResources),
+			allowed:   true,
+		},
+		{
+			name:      "SRE deletes backplane binding",
+			user:      sre,
+			operation: admissionv1.Delete,
+			oldObj:    newBinding("backplane-srep-admins-cluster", "cluster-admin", backplaneGroup),
+			allowed:   true,
+		},
+		{
+			name:      "hive updates backplane role",
+			user:      hive,
+			operation: admissionv1.Update,
+			oldObj:    newRole("backplane-srep-admins-cluster", allResources),
+			obj:       newRole("backplane-srep-admins-cluster", readPods),
+			allowed:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := NewWebhook()
+			request := newRequest(t, test.user, test.operation, test.oldObj, test.obj)
+			if !s.Validate(request) {
+				t.Fatalf("Expected the request to be valid")
+			}
+			response := s.Authorized(request)
+			if response.Allowed != test.allowed {
+				t.Errorf("Expected allowed %t, got %t: %s", test.allowed, response.Allowed, response.Result.Message)
+			}
+			if response.UID != "test-uid" {
+				t.Errorf("Expected the response UID to be set")
+			}
+		})
+	}
+}
+
+func TestAuthorizedRecordsEvents(t *testing.T) {
+	t.Cleanup(func() { events.SetRecorder(nil) })
+	eventClient := fake.NewClientBuilder().Build()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	recorder := events.NewRecorder(eventClient)
+	recorder.Start(ctx)
+	events.SetRecorder(recorder)
+
+	s := NewWebhook()
+	binding := newBinding("backplane-srep-admins-cluster", "cluster-admin", rbacv1.Subject{Kind: rbacv1.GroupKind, APIGroup: rbacv1.GroupName, Name: "system:serviceaccounts:openshift-backplane-srep"})
+
+	dryRun := true
+	request := newRequest(t, customer, admissionv1.Delete, binding, nil)
+	request.DryRun = &dryRun
+	if response := s.Authorized(request); response.Allowed {
+		t.Fatalf("Expected the dry run to be denied")
+	}
+	if response := s.Authorized(newRequest(t, customer, admissionv1.Delete, binding, nil)); response.Allowed {
+		t.Fatalf("Expected the deletion to be denied")
+	}
+
+	list := &corev1.EventList{}
+	err := wait.PollUntilContextTimeout(ctx, 10*time.Millisecond, 5*time.Second, true, func(ctx context.Context) (bool, error) {
+		if err := eventClient.List(ctx, list); err != nil {
+			return false, err
+		}
+		return len(list.Items) > 0, nil
+	})
+	if err != nil {
+		t.Fatalf("Expected an Event to be recorded: %v", err)
+	}
+	if len(list.Items) != 1 {
+		t.Fatalf("Expected one Event, not one for the dry run, got %d", len(list.Items))
+	}
+	if list.Items[0].Reason != deniedReason || list.Items[0].InvolvedObject.Name != binding.Name {
+		t.Errorf("Unexpected Event %+v", list.Items[0])
+	}
+}
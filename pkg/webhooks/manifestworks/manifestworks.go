@@ -6,6 +6,7 @@ import (
 	"slices"
 	"sync"
 
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
 	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionregv1 "k8s.io/api/admissionregistration/v1"
@@ -59,6 +60,16 @@ func (s *ManifestWorksWebhook) Doc() string {
 	return fmt.Sprintf(docString)
 }
 
+// RuleDocs implements Webhook interface
+func (s *ManifestWorksWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Only authorized service accounts may delete ManifestWorks.",
+			Exceptions: []string{"The open-cluster-management, multicluster engine and garbage collector service accounts"},
+		},
+	}
+}
+
 // TimeoutSeconds implements Webhook interface
 func (s *ManifestWorksWebhook) TimeoutSeconds() int32 { return 2 }
 
@@ -124,7 +135,7 @@ func (s *ManifestWorksWebhook) authorized(request admissionctl.Request) admissio
 		"user", request.UserInfo.Username,
 		"groups", request.UserInfo.Groups)
 
-	ret = admissionctl.Denied(fmt.Sprintf("Only authorized service accounts can delete ManifestWork resources. Allowed service accounts: %v", allowedServiceAccounts))
+	ret = response.Denied(response.HostedClusterDeletion, fmt.Sprintf("Only authorized service accounts can delete ManifestWork resources. Allowed service accounts: %v", allowedServiceAccounts))
 	ret.UID = request.AdmissionRequest.UID
 	return ret
 }
@@ -0,0 +1,251 @@
+package monitoringconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/ghodss/yaml"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "monitoringconfig-validation"
+	docString   string = `Managed OpenShift customers may not disable platform alerting in the %s ConfigMap in the %s namespace, nor delete it, nor delete PrometheusRules which have a "%s": "true" label or remove that label from them, as Red Hat SRE rely on them to be alerted of problems with the cluster.`
+
+	managedLabel string = "hive.openshift.io/managed"
+
+	// monitoringNamespace is where the platform monitoring stack runs
+	monitoringNamespace string = "openshift-monitoring"
+	// monitoringConfigMapName configures the platform monitoring stack
+	monitoringConfigMapName string = "cluster-monitoring-config"
+	// monitoringConfigKey is the key in the ConfigMap holding its configuration
+	monitoringConfigKey string = "config.yaml"
+
+	supportMessage string = "If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support"
+)
+
+var (
+	scope = admissionregv1.NamespacedScope
+	rules = []admissionregv1.RuleWithOperations{
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Update, admissionregv1.Delete},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{""},
+				APIVersions: []string{"*"},
+				Resources:   []string{"configmaps"},
+				Scope:       &scope,
+			},
+		},
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Update, admissionregv1.Delete},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{"monitoring.coreos.com"},
+				APIVersions: []string{"*"},
+				Resources:   []string{"prometheusrules"},
+				Scope:       &scope,
+			},
+		},
+	}
+	log = logf.Log.WithName(WebhookName)
+)
+
+// monitoringConfig is the part of the cluster-monitoring-config configuration
+// the webhook checks
+type monitoringConfig struct {
+	AlertmanagerMain *struct {
+		Enabled *bool `json:"enabled,omitempty"`
+	} `json:"alertmanagerMain,omitempty"`
+}
+
+// alertingDisabled returns true if the configuration turns off the platform
+// Alertmanager, which is enabled unless set otherwise
+func (c monitoringConfig) alertingDisabled() bool {
+	return c.AlertmanagerMain != nil && c.AlertmanagerMain.Enabled != nil && !*c.AlertmanagerMain.Enabled
+}
+
+// MonitoringConfigWebhook protects the platform monitoring configuration and
+// the PrometheusRules managed by SRE
+type MonitoringConfigWebhook struct{}
+
+// NewWebhook creates a new webhook
+func NewWebhook() *MonitoringConfigWebhook {
+	return &MonitoringConfigWebhook{}
+}
+
+// Authorized implements Webhook interface
+func (s *MonitoringConfigWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.authorized(request)
+}
+
+func (s *MonitoringConfigWebhook) authorized(request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if identity.IsAdmin(request.UserInfo) {
+		ret = admissionctl.Allowed("Admins and managed service accounts may change the monitoring configuration")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	if request.Kind.Kind == "ConfigMap" {
+		ret = s.authorizeConfigMap(request)
+	} else {
+		ret = s.authorizePrometheusRule(request)
+	}
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// authorizeConfigMap denies deleting cluster-monitoring-config and changes to
+// it which disable platform alerting
+func (s *MonitoringConfigWebhook) authorizeConfigMap(request admissionctl.Request) admissionctl.Response {
+	if request.Namespace != monitoringNamespace || request.Name != monitoringConfigMapName {
+		return admissionctl.Allowed("Only the platform monitoring configuration is protected")
+	}
+
+	if request.Operation == admissionv1.Delete {
+		log.Info("Denying deletion of the platform monitoring configuration", "user", request.UserInfo.Username)
+		return response.Denied(response.ManagedMonitoring, fmt.Sprintf("Prevented from deleting the %s ConfigMap in %s, which is managed by Red Hat SRE. To change the monitoring of your own workloads, edit the user-workload-monitoring-config ConfigMap in openshift-user-workload-monitoring instead. %s", monitoringConfigMapName, monitoringNamespace, supportMessage))
+	}
+
+	old, err := decodeMonitoringConfig(request.OldObject.Raw)
+	if err != nil {
+		// An invalid configuration already in place is no reason to block fixing it
+		log.Error(err, "Couldn't read the current platform monitoring configuration")
+		old = monitoringConfig{}
+	}
+	config, err := decodeMonitoringConfig(request.Object.Raw)
+	if err != nil {
+		log.Info("Denying invalid platform monitoring configuration", "user", request.UserInfo.Username, "error", err.Error())
+		return response.Denied(response.InvalidMonitoringConfig, fmt.Sprintf("Prevented from saving an invalid %s key in the %s ConfigMap in %s: %s. Correct the configuration and try again. %s", monitoringConfigKey, monitoringConfigMapName, monitoringNamespace, err.Error(), supportMessage))
+	}
+
+	if config.alertingDisabled() && !old.alertingDisabled() {
+		log.Info("Denying disabling of platform alerting", "user", request.UserInfo.Username)
+		return response.Denied(response.ManagedMonitoring, fmt.Sprintf("Prevented from setting alertmanagerMain.enabled to false in the %s ConfigMap in %s, as Red Hat SRE rely on platform alerting to support the cluster. Remove the setting and try again. To route alerts for your own workloads, configure user workload monitoring in openshift-user-workload-monitoring. %s", monitoringConfigMapName, monitoringNamespace, supportMessage))
+	}
+
+	return admissionctl.Allowed("Platform alerting is not disabled")
+}
+
+// authorizePrometheusRule denies deleting managed PrometheusRules and removing
+// the managed label from them
+func (s *MonitoringConfigWebhook) authorizePrometheusRule(request admissionctl.Request) admissionctl.Response {
+	old := &metav1.PartialObjectMetadata{}
+	if err := json.Unmarshal(request.OldObject.Raw, old); err != nil {
+		log.Error(err, "Couldn't decode the old PrometheusRule from the request")
+		return admissionctl.Errored(http.StatusBadRequest, err)
+	}
+	if old.Labels[managedLabel] != "true" {
+		return admissionctl.Allowed("Only managed PrometheusRules are protected")
+	}
+
+	if request.Operation == admissionv1.Delete {
+		log.Info("Denying deletion of managed PrometheusRule", "namespace", request.Namespace, "name", request.Name, "user", request.UserInfo.Username)
+		return response.Denied(response.ManagedMonitoring, fmt.Sprintf("Prevented from deleting the PrometheusRule %s/%s, which is managed by Red Hat SRE. Define your own alerting rules in a separate PrometheusRule instead. %s", request.Namespace, request.Name, supportMessage))
+	}
+
+	rule := &metav1.PartialObjectMetadata{}
+	if err := json.Unmarshal(request.Object.Raw, rule); err != nil {
+		log.Error(err, "Couldn't decode the PrometheusRule from the request")
+		return admissionctl.Errored(http.StatusBadRequest, err)
+	}
+	// Unlabelling a PrometheusRule would let it be deleted
+	if rule.Labels[managedLabel] != "true" {
+		log.Info("Denying removal of the managed label from PrometheusRule", "namespace", request.Namespace, "name", request.Name, "user", request.UserInfo.Username)
+		return response.Denied(response.ManagedMonitoring, fmt.Sprintf("Prevented from removing the %s label from the PrometheusRule %s/%s, which is managed by Red Hat SRE. %s", managedLabel, request.Namespace, request.Name, supportMessage))
+	}
+
+	return admissionctl.Allowed("The managed label is unchanged")
+}
+
+// decodeMonitoringConfig reads the configuration held by a
+// cluster-monitoring-config ConfigMap
+func decodeMonitoringConfig(raw []byte) (monitoringConfig, error) {
+	config := monitoringConfig{}
+	configMap := &corev1.ConfigMap{}
+	if err := json.Unmarshal(raw, configMap); err != nil {
+		return config, err
+	}
+	err := yaml.Unmarshal([]byte(configMap.Data[monitoringConfigKey]), &config)
+	return config, err
+}
+
+// GetURI implements Webhook interface
+func (s *MonitoringConfigWebhook) GetURI() string { return "/" + WebhookName }
+
+// Validate implements Webhook interface
+func (s *MonitoringConfigWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	valid = valid && (request.Kind.Kind == "ConfigMap" || request.Kind.Kind == "PrometheusRule")
+	valid = valid && (len(request.OldObject.Raw) > 0)
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *MonitoringConfigWebhook) Name() string { return WebhookName }
+
+// FailurePolicy implements Webhook interface
+func (s *MonitoringConfigWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *MonitoringConfigWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *MonitoringConfigWebhook) Rules() []admissionregv1.RuleWithOperations { return rules }
+
+// ObjectSelector implements Webhook interface. cluster-monitoring-config isn't
+// labelled, so requests are matched by namespace and name instead.
+func (s *MonitoringConfigWebhook) ObjectSelector() *metav1.LabelSelector { return nil }
+
+// SideEffects implements Webhook interface
+func (s *MonitoringConfigWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *MonitoringConfigWebhook) TimeoutSeconds() int32 { return 2 }
+
+// Doc implements Webhook interface
+func (s *MonitoringConfigWebhook) Doc() string {
+	return fmt.Sprintf(docString, monitoringConfigMapName, monitoringNamespace, managedLabel)
+}
+
+// RuleDocs implements Webhook interface
+func (s *MonitoringConfigWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers may not delete the cluster-monitoring-config ConfigMap in openshift-monitoring, save an invalid config.yaml in it, nor disable platform alerting in it.",
+			Exceptions: []string{utils.AdminsException},
+		},
+		{
+			Summary:    "Customers may not delete managed PrometheusRules, which have a \"hive.openshift.io/managed\": \"true\" label, nor remove that label from them.",
+			Exceptions: []string{utils.AdminsException},
+		},
+	}
+}
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+func (s *MonitoringConfigWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// ClassicEnabled implements Webhook interface
+func (s *MonitoringConfigWebhook) ClassicEnabled() bool { return true }
+
+// HypershiftEnabled implements Webhook interface
+func (s *MonitoringConfigWebhook) HypershiftEnabled() bool { return false }
@@ -0,0 +1,185 @@
+package monitoringconfig
+
+import (
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+)
+
+func newConfigMap(namespace, name, config string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Data:       map[string]string{monitoringConfigKey: config},
+	}
+}
+
+func newPrometheusRule(namespace, name string, managed bool) *metav1.PartialObjectMetadata {
+	rule := &metav1.PartialObjectMetadata{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "monitoring.coreos.com/v1", Kind: "PrometheusRule"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+	}
+	if managed {
+		rule.Labels = map[string]string{managedLabel: "true"}
+	}
+	return rule
+}
+
+func newRequest(t *testing.T, operation admissionv1.Operation, username string, groups []string, oldObj, obj metav1.Object) admissionctl.Request {
+	t.Helper()
+	kind := "ConfigMap"
+	if _, ok := oldObj.(*metav1.PartialObjectMetadata); ok {
+		kind = "PrometheusRule"
+	}
+	user := authenticationv1.UserInfo{Username: username, Groups: groups}
+	return testutils.NewRequest(t, metav1.GroupVersionKind{Kind: kind}, operation, user, oldObj.GetNamespace(), oldObj.GetName(), obj, oldObj)
+}
+
+func TestAuthorized(t *testing.T) {
+	const (
+		enabled  = "alertmanagerMain:\n  enabled: true\n"
+		disabled = "alertmanagerMain:\n  enabled: false\n"
+	)
+	tests := []struct {
+		name      string
+		operation admissionv1.Operation
+		username  string
+		groups    []string
+		oldObj    metav1.Object
+		obj       metav1.Object
+		allowed   bool
+	}{
+		{
+			name:      "customer disables platform alerting",
+			operation: admissionv1.Update,
+			username:  "customer",
+			oldObj:    newConfigMap(monitoringNamespace, monitoringConfigMapName, ""),
+			obj:       newConfigMap(monitoringNamespace, monitoringConfigMapName, disabled),
+			allowed:   false,
+		},
+		{
+			name:      "customer disables enabled platform alerting",
+			operation: admissionv1.Update,
+			username:  "customer",
+			oldObj:    newConfigMap(monitoringNamespace, monitoringConfigMapName, enabled),
+			obj:       newConfigMap(monitoringNamespace, monitoringConfigMapName, disabled),
+			allowed:   false,
+		},
+		{
+			name:      "customer changes retention",
+			operation: admissionv1.Update,
+			username:  "customer",
+			oldObj:    newConfigMap(monitoringNamespace, monitoringConfigMapName, enabled),
+			obj:       newConfigMap(monitoringNamespace, monitoringConfigMapName, enabled+"prometheusK8s:\n  retention: 7d\n"),
+			allowed:   true,
+		},
+		{
+			name:      "customer edits config with alerting already disabled",
+			operation: admissionv1.Update,
+			username:  "customer",
+			oldObj:    newConfigMap(monitoringNamespace, monitoringConfigMapName, disabled),
+			obj:       newConfigMap(monitoringNamespace, monitoringConfigMapName, disabled+"prometheusK8s:\n  retention: 7d\n"),
+			allowed:   true,
+		},
+		{
+			name:      "customer saves invalid config",
+			operation: admissionv1.Update,
+			username:  "customer",
+			oldObj:    newConfigMap(monitoringNamespace, monitoringConfigMapName, enabled),
+			obj:       newConfigMap(monitoringNamespace, monitoringConfigMapName, "alertmanagerMain: [\n"),
+			allowed:   false,
+		},
+		{
+			name:      "customer deletes platform monitoring config",
+			operation: admissionv1.Delete,
+			username:  "customer",
+			oldObj:    newConfigMap(monitoringNamespace, monitoringConfigMapName, enabled),
+			allowed:   false,
+		},
+		{
+			name:      "SRE disables platform alerting",
+			operation: admissionv1.Update,
+			username:  "backplane-cluster-admin",
+			oldObj:    newConfigMap(monitoringNamespace, monitoringConfigMapName, enabled),
+			obj:       newConfigMap(monitoringNamespace, monitoringConfigMapName, disabled),
+			allowed:   true,
+		},
+		{
+			name:      "customer edits other ConfigMap in openshift-monitoring",
+			operation: admissionv1.Update,
+			username:  "customer",
+			oldObj:    newConfigMap(monitoringNamespace, "other", enabled),
+			obj:       newConfigMap(monitoringNamespace, "other", disabled),
+			allowed:   true,
+		},
+		{
+			name:      "customer disables alerting in own namespace",
+			operation: admissionv1.Update,
+			username:  "customer",
+			oldObj:    newConfigMap("customer", monitoringConfigMapName, enabled),
+			obj:       newConfigMap("customer", monitoringConfigMapName, disabled),
+			allowed:   true,
+		},
+		{
+			name:      "customer deletes managed PrometheusRule",
+			operation: admissionv1.Delete,
+			username:  "customer",
+			oldObj:    newPrometheusRule("openshift-customer-monitoring", "sre-alerts", true),
+			allowed:   false,
+		},
+		{
+			name:      "customer deletes own PrometheusRule",
+			operation: admissionv1.Delete,
+			username:  "customer",
+			oldObj:    newPrometheusRule("openshift-customer-monitoring", "my-alerts", false),
+			allowed:   true,
+		},
+		{
+			name:      "customer unlabels managed PrometheusRule",
+			operation: admissionv1.Update,
+			username:  "customer",
+			oldObj:    newPrometheusRule("openshift-customer-monitoring", "sre-alerts", true),
+			obj:       newPrometheusRule("openshift-customer-monitoring", "sre-alerts", false),
+			allowed:   false,
+		},
+		{
+			name:      "customer edits managed PrometheusRule keeping the label",
+			operation: admissionv1.Update,
+			username:  "customer",
+			oldObj:    newPrometheusRule("openshift-customer-monitoring", "sre-alerts", true),
+			obj:       newPrometheusRule("openshift-customer-monitoring", "sre-alerts", true),
+			allowed:   true,
+		},
+		{
+			name:      "monitoring operator deletes managed PrometheusRule",
+			operation: admissionv1.Delete,
+			username:  "system:serviceaccount:openshift-monitoring:cluster-monitoring-operator",
+			groups:    []string{"system:serviceaccounts", "system:serviceaccounts:openshift-monitoring"},
+			oldObj:    newPrometheusRule(monitoringNamespace, "sre-alerts", true),
+			allowed:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := NewWebhook()
+			request := newRequest(t, test.operation, test.username, test.groups, test.oldObj, test.obj)
+			if !s.Validate(request) {
+				t.Fatalf("Expected the request to be valid")
+			}
+			response := s.Authorized(request)
+			if response.Allowed != test.allowed {
+				t.Errorf("Expected allowed %t, got %t: %s", test.allowed, response.Allowed, response.Result.Message)
+			}
+			if response.UID != "test-uid" {
+				t.Errorf("Expected the response UID to be set")
+			}
+		})
+	}
+}
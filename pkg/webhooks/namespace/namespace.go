@@ -9,6 +9,8 @@ import (
 	"sync"
 
 	hookconfig "github.com/openshift/managed-cluster-validating-webhooks/pkg/config"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
 	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionregv1 "k8s.io/api/admissionregistration/v1"
@@ -24,7 +26,7 @@ const (
 	badNamespace                 string = `(^com$|^io$|^in$)`
 	layeredProductNamespace      string = `^redhat-.*`
 	layeredProductAdminGroupName string = "layered-sre-cluster-admins"
-	docString                    string = `Managed OpenShift Customers may not modify namespaces specified in the %v ConfigMaps because customer workloads should be placed in customer-created namespaces. Customers may not create namespaces identified by this regular expression %s because it could interfere with critical DNS resolution. Additionally, customers may not set or change the values of these Namespace labels %s.`
+	docString                    string = `Managed OpenShift Customers may not modify namespaces specified in the %v ConfigMaps because customer workloads should be placed in customer-created namespaces. Customers may not create namespaces identified by this regular expression %s because it could interfere with critical DNS resolution. Additionally, customers may not set or change the values of these Namespace labels %s, nor set or change these Namespace annotations %s.`
 	clusterAdminGroup            string = "cluster-admins"
 )
 
@@ -34,16 +36,24 @@ var (
 )
 
 var (
-	clusterAdminUsers           = []string{"kube:admin", "system:admin", "backplane-cluster-admin"}
-	sreAdminGroups              = []string{"system:serviceaccounts:openshift-backplane-srep"}
-	privilegedServiceAccountsRe = regexp.MustCompile(utils.PrivilegedServiceAccountGroups)
-	layeredProductNamespaceRe   = regexp.MustCompile(layeredProductNamespace)
+	layeredProductNamespaceRe = regexp.MustCompile(layeredProductNamespace)
 	// protectedLabels are labels which managed customers should not be allowed
 	// change by dedicated-admins.
-	protectedLabels = []string{
+	protectedLabels = append([]string{
 		// https://github.com/openshift/managed-cluster-config/tree/master/deploy/resource-quotas
 		"managed.openshift.io/storage-pv-quota-exempt",
 		"managed.openshift.io/service-lb-quota-exempt",
+		// SRE's exemption from objectquota-validation
+		"managed.openshift.io/object-count-quota-exempt",
+	},
+		// SRE's host access exceptions for hostaccess-validation
+		utils.HostAccessLabels()...,
+	)
+	// protectedAnnotations are SRE's exceptions which managed customers should
+	// not be allowed to grant themselves by annotating their namespaces
+	protectedAnnotations = []string{
+		// privilegedscc-validation and hostaccess-validation's exception
+		utils.PrivilegedSCCAnnotation,
 	}
 
 	log = logf.Log.WithName(WebhookName)
@@ -72,7 +82,29 @@ type NamespaceWebhook struct {
 func (s *NamespaceWebhook) ObjectSelector() *metav1.LabelSelector { return nil }
 
 func (s *NamespaceWebhook) Doc() string {
-	return fmt.Sprintf(docString, hookconfig.ConfigMapSources, badNamespace, protectedLabels)
+	return fmt.Sprintf(docString, hookconfig.ConfigMapSources, badNamespace, protectedLabels, protectedAnnotations)
+}
+
+// RuleDocs implements Webhook interface
+func (s *NamespaceWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers may not modify Red Hat managed namespaces.",
+			Exceptions: []string{utils.AdminsException, "Members of the cluster-admins group", "Layered product SRE, in their own namespaces"},
+		},
+		{
+			Summary:    "Customers may not create namespaces which would interfere with DNS resolution.",
+			Exceptions: []string{utils.AdminsException},
+		},
+		{
+			Summary:    "Customers may not set or change the managed labels of namespaces.",
+			Exceptions: []string{utils.AdminsException},
+		},
+		{
+			Summary:    "Customers may not set or change the annotations granting namespaces SRE's exceptions.",
+			Exceptions: []string{utils.AdminsException},
+		},
+	}
 }
 
 // TimeoutSeconds implements Webhook interface
@@ -189,12 +221,10 @@ func (s *NamespaceWebhook) authorized(request admissionctl.Request) admissionctl
 		return ret
 	}
 	// Privileged ServiceAccounts are allowed to perform any operation
-	for _, group := range request.UserInfo.Groups {
-		if privilegedServiceAccountsRe.Match([]byte(group)) {
-			ret = admissionctl.Allowed("Privileged service accounts may access")
-			ret.UID = request.AdmissionRequest.UID
-			return ret
-		}
+	if identity.IsPrivilegedServiceAccount(request.UserInfo) {
+		ret = admissionctl.Allowed("Privileged service accounts may access")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
 	}
 
 	ns, err := s.renderNamespace(request)
@@ -215,21 +245,28 @@ func (s *NamespaceWebhook) authorized(request admissionctl.Request) admissionctl
 	// L64-73
 	if hookconfig.IsPrivilegedNamespace(ns.GetName()) {
 		log.Info("Non-admin attempted to access a privileged namespace matching a regex from this list", "list", hookconfig.PrivilegedNamespaces, "request", request.AdmissionRequest)
-		ret = admissionctl.Denied(fmt.Sprintf("Prevented from accessing Red Hat managed namespaces. Customer workloads should be placed in customer namespaces, and should not match an entry in this list of regular expressions: %v", hookconfig.PrivilegedNamespaces))
+		ret = response.Denied(response.ManagedNamespace, fmt.Sprintf("Prevented from accessing Red Hat managed namespaces. Customer workloads should be placed in customer namespaces, and should not match an entry in this list of regular expressions: %v", hookconfig.PrivilegedNamespaces))
 		ret.UID = request.AdmissionRequest.UID
 		return ret
 	}
 	// Unprivileged users cannot create namespaces with certain names
 	if BadNamespaceRe.Match([]byte(ns.GetName())) {
 		log.Info("Non-admin attempted to access a potentially harmful namespace (eg matching this regex)", "regex", badNamespace, "request", request.AdmissionRequest)
-		ret = admissionctl.Denied(fmt.Sprintf("Prevented from creating a potentially harmful namespace. Customer namespaces should not match this regular expression, as this would impact DNS resolution: %s", badNamespace))
+		ret = response.Denied(response.ReservedNamespaceName, fmt.Sprintf("Prevented from creating a potentially harmful namespace. Customer namespaces should not match this regular expression, as this would impact DNS resolution: %s", badNamespace))
 		ret.UID = request.AdmissionRequest.UID
 		return ret
 	}
 	// Check labels.
 	unauthorized, err := s.unauthorizedLabelChanges(request)
 	if !amIAdmin(request) && unauthorized {
-		ret = admissionctl.Denied(fmt.Sprintf("Denied. Err %+v", err))
+		ret = response.Denied(response.ManagedNamespaceLabel, fmt.Sprintf("Denied. Err %+v", err))
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	// Check annotations.
+	unauthorized, err = s.unauthorizedAnnotationChanges(request)
+	if unauthorized {
+		ret = response.Denied(response.ManagedNamespaceLabel, fmt.Sprintf("Denied. Err %+v", err))
 		ret.UID = request.AdmissionRequest.UID
 		return ret
 	}
@@ -292,6 +329,35 @@ func (s *NamespaceWebhook) unauthorizedLabelChanges(req admissionctl.Request) (b
 	return false, nil
 }
 
+// unauthorizedAnnotationChanges returns true if the request should be denied
+// because it sets or changes a protected annotation. Removing one is allowed, as
+// that only gives up an exception. The error is the reason for denial.
+func (s *NamespaceWebhook) unauthorizedAnnotationChanges(req admissionctl.Request) (bool, error) {
+	if req.Operation == admissionv1.Delete {
+		return false, nil
+	}
+	newNamespace, oldNamespace, err := s.renderOldAndNewNamespaces(req)
+	if err != nil {
+		return true, err
+	}
+	if newNamespace == nil {
+		return false, nil
+	}
+	for _, annotation := range protectedAnnotations {
+		value, set := newNamespace.Annotations[annotation]
+		if !set {
+			continue
+		}
+		if req.Operation == admissionv1.Update && oldNamespace != nil {
+			if oldValue, wasSet := oldNamespace.Annotations[annotation]; wasSet && oldValue == value {
+				continue
+			}
+		}
+		return true, fmt.Errorf("Managed OpenShift customers may not set or change certain protected annotations (%s) on Namespaces. %s is only granted by Red Hat SRE", protectedAnnotations, annotation)
+	}
+	return false, nil
+}
+
 // doesNamespaceContainProtectedLabels checks the namespace for any instances of
 // protectedLabels and returns a slice of any instances of matches
 func doesNamespaceContainProtectedLabels(ns *corev1.Namespace) []string {
@@ -334,15 +400,5 @@ func NewWebhook() *NamespaceWebhook {
 }
 
 func amIAdmin(request admissionctl.Request) bool {
-	if slices.Contains(clusterAdminUsers, request.UserInfo.Username) || slices.Contains(request.UserInfo.Groups, clusterAdminGroup) {
-		return true
-	}
-
-	for _, group := range sreAdminGroups {
-		if slices.Contains(request.UserInfo.Groups, group) {
-			return true
-		}
-	}
-
-	return false
+	return identity.IsSRE(request.UserInfo) || identity.IsClusterAdmin(request.UserInfo) || slices.Contains(request.UserInfo.Groups, clusterAdminGroup)
 }
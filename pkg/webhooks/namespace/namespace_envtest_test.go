@@ -0,0 +1,43 @@
+package namespace_test
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils/envtest"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/namespace"
+)
+
+func createNamespace(name string) func(context.Context, client.Client) error {
+	return func(ctx context.Context, c client.Client) error {
+		return c.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+}
+
+func TestNamespaceEnvtest(t *testing.T) {
+	e := envtest.Start(t, namespace.WebhookName)
+	e.Run(t, []envtest.Case{
+		{
+			Name:     "customer creates a customer namespace",
+			Username: "customer",
+			Do:       createNamespace("customer-app"),
+			Allowed:  true,
+		},
+		{
+			Name:     "customer creates a managed namespace",
+			Username: "customer",
+			Do:       createNamespace("openshift-customer"),
+			Allowed:  false,
+		},
+		{
+			Name:     "backplane-cluster-admin creates a managed namespace",
+			Username: "backplane-cluster-admin",
+			Do:       createNamespace("openshift-sre"),
+			Allowed:  true,
+		},
+	})
+}
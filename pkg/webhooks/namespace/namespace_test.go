@@ -6,8 +6,11 @@ import (
 	"testing"
 
 	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
 
 	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
@@ -986,6 +989,18 @@ func TestLabellingUpdates(t *testing.T) {
 			},
 			shouldBeAllowed: false,
 		},
+		{
+			testID:          "dedicated-admins-cant-allow-host-access",
+			targetNamespace: "my-customer-ns",
+			username:        "test@user",
+			userGroups:      []string{"system:authenticated", "system:authenticated:oauth", "dedicated-admins"},
+			operation:       admissionv1.Update,
+			oldObject:       createOldObject("my-customer-ns", "dedicated-admins-cant-allow-host-access", map[string]string{}),
+			labels: map[string]string{
+				"hostaccess.managed.openshift.io/hostNetwork": "true",
+			},
+			shouldBeAllowed: false,
+		},
 		{
 			testID:          "sres-can-exempt-customer-ns",
 			targetNamespace: "my-customer-ns",
@@ -1072,3 +1087,81 @@ func TestGetURI(t *testing.T) {
 		t.Fatalf("Hook URI does not begin with a /")
 	}
 }
+
+// TestExceptionAnnotation checks only SRE may grant a namespace the privileged
+// SCC exception
+func TestExceptionAnnotation(t *testing.T) {
+	customer := authenticationv1.UserInfo{Username: "test@user", Groups: []string{"system:authenticated", "system:authenticated:oauth", "dedicated-admins"}}
+	sre := authenticationv1.UserInfo{Username: "backplane-cluster-admin"}
+	namespace := func(annotations map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "my-customer-ns", Annotations: annotations}}
+	}
+	granted := map[string]string{utils.PrivilegedSCCAnnotation: "true"}
+	tests := []struct {
+		name      string
+		user      authenticationv1.UserInfo
+		operation admissionv1.Operation
+		old       *corev1.Namespace
+		obj       *corev1.Namespace
+		allowed   bool
+	}{
+		{
+			name:      "customer creates annotated namespace",
+			user:      customer,
+			operation: admissionv1.Create,
+			obj:       namespace(granted),
+			allowed:   false,
+		},
+		{
+			name:      "customer adds annotation",
+			user:      customer,
+			operation: admissionv1.Update,
+			old:       namespace(nil),
+			obj:       namespace(granted),
+			allowed:   false,
+		},
+		{
+			name:      "customer changes annotation",
+			user:      customer,
+			operation: admissionv1.Update,
+			old:       namespace(map[string]string{utils.PrivilegedSCCAnnotation: "false"}),
+			obj:       namespace(granted),
+			allowed:   false,
+		},
+		{
+			name:      "customer keeps granted annotation",
+			user:      customer,
+			operation: admissionv1.Update,
+			old:       namespace(granted),
+			obj:       namespace(map[string]string{utils.PrivilegedSCCAnnotation: "true", "example.com/note": "x"}),
+			allowed:   true,
+		},
+		{
+			name:      "customer removes annotation",
+			user:      customer,
+			operation: admissionv1.Update,
+			old:       namespace(granted),
+			obj:       namespace(nil),
+			allowed:   true,
+		},
+		{
+			name:      "sre adds annotation",
+			user:      sre,
+			operation: admissionv1.Update,
+			old:       namespace(nil),
+			obj:       namespace(granted),
+			allowed:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hook := NewWebhook()
+			request := testutils.NewRequest(t, metav1.GroupVersionKind{Version: "v1", Kind: "Namespace"}, test.operation, test.user, "", test.obj.Name, test.obj, test.old)
+			response := hook.Authorized(request)
+			if response.Allowed != test.allowed {
+				t.Fatalf("Expected allowed %v, got %v: %v", test.allowed, response.Allowed, response.Result)
+			}
+		})
+	}
+}
@@ -0,0 +1,296 @@
+package namespacelabels
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	hookconfig "github.com/openshift/managed-cluster-validating-webhooks/pkg/config"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	"gomodules.xyz/jsonpatch/v2"
+
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "namespacelabels-mutation"
+	docString   string = `Namespaces customers create on Managed OpenShift clusters are labelled %s, so managed selectors and policies apply to them uniformly, and are given the managed Pod Security audit and warn levels unless they set their own. Customers may not set the managed labels %s themselves.`
+
+	// tierLabel tells customer namespaces from managed ones
+	tierLabel string = "managed.openshift.io/tier"
+	// networkPolicyBootstrapLabel selects the namespaces the managed default
+	// NetworkPolicies are bootstrapped in
+	networkPolicyBootstrapLabel string = "managed.openshift.io/network-policy-bootstrap"
+)
+
+var (
+	scope = admissionregv1.ClusterScope
+	rules = []admissionregv1.RuleWithOperations{
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Create},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{""},
+				APIVersions: []string{"v1"},
+				Resources:   []string{"namespaces"},
+				Scope:       &scope,
+			},
+		},
+	}
+	log = logf.Log.WithName(WebhookName)
+
+	// managedLabels are set on every customer namespace. Customers may not set
+	// them themselves.
+	managedLabels = map[string]string{
+		tierLabel:                   "customer",
+		networkPolicyBootstrapLabel: "true",
+	}
+
+	// podSecurityLabels are the managed Pod Security levels, set on customer
+	// namespaces which don't set their own. Enforcement is left to the
+	// platform's label synchronization so customer workloads keep running.
+	podSecurityLabels = map[string]string{
+		"pod-security.kubernetes.io/audit": "restricted",
+		"pod-security.kubernetes.io/warn":  "restricted",
+	}
+)
+
+// NamespaceLabelsWebhook labels the namespaces customers create
+type NamespaceLabelsWebhook struct {
+	decoder admissionctl.Decoder
+}
+
+// NewWebhook creates the new webhook
+func NewWebhook() *NamespaceLabelsWebhook {
+	decoder, err := k8sutil.SharedDecoder()
+	if err != nil {
+		log.Error(err, "Fail getting the shared decoder for NamespaceLabelsWebhook")
+		os.Exit(1)
+	}
+
+	return &NamespaceLabelsWebhook{
+		decoder: decoder,
+	}
+}
+
+// Authorized implements Webhook interface
+func (s *NamespaceLabelsWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.authorizeOrMutate(request)
+}
+
+func (s *NamespaceLabelsWebhook) authorizeOrMutate(request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	ns := &corev1.Namespace{}
+	if err := s.decoder.Decode(request, ns); err != nil {
+		log.Error(err, "Couldn't decode the Namespace from the request")
+		ret = admissionctl.Errored(http.StatusBadRequest, err)
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	if s.ExcludedNamespaces().Excludes(ns.Name) {
+		ret = admissionctl.Allowed("Only customer namespaces are labelled")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	if !identity.IsSRE(request.UserInfo) && !identity.IsPrivilegedServiceAccount(request.UserInfo) {
+		if preset := presetManagedLabels(ns.Labels); len(preset) > 0 {
+			log.Info("Denying namespace setting managed labels", "name", ns.Name, "labels", preset, "user", request.UserInfo.Username)
+			ret = response.Denied(response.ManagedNamespaceLabel, fmt.Sprintf("Prevented from creating namespace %s with the managed labels %s, which are set by Red Hat. Remove them from the namespace. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", ns.Name, strings.Join(preset, ", ")))
+			ret.UID = request.AdmissionRequest.UID
+			return ret
+		}
+	}
+
+	patches := buildPatch(ns.Labels)
+	if len(patches) == 0 {
+		ret = admissionctl.Allowed("Namespace already has the managed labels")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	log.Info("Adding managed labels to namespace", "name", ns.Name, "user", request.UserInfo.Username)
+	ret = admissionctl.Patched(fmt.Sprintf("Added managed labels to namespace '%s'", ns.Name), patches...)
+	// ret.Complete() sets the UID and finalizes the patch
+	if err := ret.Complete(request); err != nil {
+		log.Error(err, "Failed to complete the request")
+		ret = admissionctl.Errored(http.StatusInternalServerError, err)
+		ret.UID = request.AdmissionRequest.UID
+	}
+	return ret
+}
+
+// presetManagedLabels returns the managed labels set on the namespace with a
+// value other than the managed one, sorted
+func presetManagedLabels(labels map[string]string) []string {
+	preset := []string{}
+	for key, value := range managedLabels {
+		if existing, ok := labels[key]; ok && existing != value {
+			preset = append(preset, key)
+		}
+	}
+	sort.Strings(preset)
+	return preset
+}
+
+// missingLabels returns the labels to add to the namespace: the managed
+// labels and the Pod Security labels it doesn't set
+func missingLabels(existing map[string]string) map[string]string {
+	missing := map[string]string{}
+	for _, labels := range []map[string]string{managedLabels, podSecurityLabels} {
+		for key, value := range labels {
+			if _, ok := existing[key]; !ok {
+				missing[key] = value
+			}
+		}
+	}
+	return missing
+}
+
+// buildPatch returns the JSONPatch operations adding the missing labels to
+// the namespace, creating its labels if it has none
+func buildPatch(existing map[string]string) []jsonpatch.JsonPatchOperation {
+	missing := missingLabels(existing)
+	if len(missing) == 0 {
+		return nil
+	}
+	if len(existing) == 0 {
+		return []jsonpatch.JsonPatchOperation{jsonpatch.NewOperation("add", "/metadata/labels", missing)}
+	}
+	keys := make([]string, 0, len(missing))
+	for key := range missing {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	patches := make([]jsonpatch.JsonPatchOperation, 0, len(keys))
+	for _, key := range keys {
+		patches = append(patches, jsonpatch.NewOperation("add", "/metadata/labels/"+escapeJSONPointer(key), missing[key]))
+	}
+	return patches
+}
+
+// escapeJSONPointer escapes a label key for use in a JSONPatch path
+func escapeJSONPointer(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
+
+// GetURI implements Webhook interface
+func (s *NamespaceLabelsWebhook) GetURI() string {
+	return "/" + WebhookName
+}
+
+// Validate implements Webhook interface
+func (s *NamespaceLabelsWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	valid = valid && (request.Kind.Kind == "Namespace")
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *NamespaceLabelsWebhook) Name() string {
+	return WebhookName
+}
+
+// FailurePolicy implements Webhook interface
+func (s *NamespaceLabelsWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *NamespaceLabelsWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *NamespaceLabelsWebhook) Rules() []admissionregv1.RuleWithOperations {
+	return rules
+}
+
+// ExcludedNamespaces implements NamespaceExclusionWebhook interface. The
+// namespaceSelector of a namespace webhook matches the namespace itself.
+func (s *NamespaceLabelsWebhook) ExcludedNamespaces() utils.NamespaceExclusion {
+	return hookconfig.ExcludedNamespaces
+}
+
+// ObjectSelector implements Webhook interface
+func (s *NamespaceLabelsWebhook) ObjectSelector() *metav1.LabelSelector {
+	return nil
+}
+
+// SideEffects implements Webhook interface
+func (s *NamespaceLabelsWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *NamespaceLabelsWebhook) TimeoutSeconds() int32 {
+	return 2
+}
+
+// Doc implements Webhook interface
+func (s *NamespaceLabelsWebhook) Doc() string {
+	return fmt.Sprintf(docString, labelList(managedLabels), labelKeys(managedLabels))
+}
+
+// RuleDocs implements Webhook interface
+func (s *NamespaceLabelsWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    fmt.Sprintf("Namespaces customers create are labelled %s.", labelList(managedLabels)),
+			Exceptions: []string{"Managed namespaces"},
+		},
+		{
+			Summary:    fmt.Sprintf("Namespaces customers create are labelled %s unless they set those labels themselves.", labelList(podSecurityLabels)),
+			Exceptions: []string{"Managed namespaces"},
+		},
+		{
+			Summary:    fmt.Sprintf("Customers may not create namespaces setting the managed labels %s to other values.", labelKeys(managedLabels)),
+			Exceptions: []string{utils.SREException, utils.PrivilegedServiceAccountsException},
+		},
+	}
+}
+
+// labelList renders labels as key=value pairs, sorted by key
+func labelList(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
+
+// labelKeys renders the keys of labels, sorted
+func labelKeys(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ", ")
+}
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+// Return utils.DefaultLabelSelector() to stick with the default
+func (s *NamespaceLabelsWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// ClassicEnabled indicates that this webhook is compatible with classic clusters
+func (s *NamespaceLabelsWebhook) ClassicEnabled() bool { return true }
+
+// HypershiftEnabled indicates that this webhook is compatible with hosted
+// control plane clusters
+func (s *NamespaceLabelsWebhook) HypershiftEnabled() bool { return true }
@@ -0,0 +1,174 @@
+package namespacelabels
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	patchengine "github.com/evanphx/json-patch"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+)
+
+func createNamespaceRaw(t *testing.T, name string, labels map[string]string) []byte {
+	ns := corev1.Namespace{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+	}
+	raw, err := json.Marshal(ns)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	return raw
+}
+
+func TestNamespaceLabels(t *testing.T) {
+	customerLabels := map[string]string{
+		tierLabel:                          "customer",
+		networkPolicyBootstrapLabel:        "true",
+		"pod-security.kubernetes.io/audit": "restricted",
+		"pod-security.kubernetes.io/warn":  "restricted",
+	}
+
+	tests := []struct {
+		name          string
+		namespace     string
+		username      string
+		groups        []string
+		labels        map[string]string
+		expectAllowed bool
+		expected      map[string]string
+	}{
+		{
+			name:          "customer namespace without labels",
+			namespace:     "my-project",
+			username:      "my_user",
+			groups:        []string{"system:authenticated"},
+			expectAllowed: true,
+			expected:      customerLabels,
+		},
+		{
+			name:          "customer namespace with other labels",
+			namespace:     "my-project",
+			username:      "my_user",
+			groups:        []string{"system:authenticated"},
+			labels:        map[string]string{"app": "web"},
+			expectAllowed: true,
+			expected: map[string]string{
+				"app":                              "web",
+				tierLabel:                          "customer",
+				networkPolicyBootstrapLabel:        "true",
+				"pod-security.kubernetes.io/audit": "restricted",
+				"pod-security.kubernetes.io/warn":  "restricted",
+			},
+		},
+		{
+			name:          "customer namespace with its own pod security levels",
+			namespace:     "my-project",
+			username:      "my_user",
+			groups:        []string{"system:authenticated"},
+			labels:        map[string]string{"pod-security.kubernetes.io/warn": "baseline", "pod-security.kubernetes.io/enforce": "baseline"},
+			expectAllowed: true,
+			expected: map[string]string{
+				tierLabel:                            "customer",
+				networkPolicyBootstrapLabel:          "true",
+				"pod-security.kubernetes.io/audit":   "restricted",
+				"pod-security.kubernetes.io/warn":    "baseline",
+				"pod-security.kubernetes.io/enforce": "baseline",
+			},
+		},
+		{
+			name:          "customer namespace with the managed labels",
+			namespace:     "my-project",
+			username:      "my_user",
+			groups:        []string{"system:authenticated"},
+			labels:        customerLabels,
+			expectAllowed: true,
+			expected:      customerLabels,
+		},
+		{
+			name:          "customer presetting a managed label",
+			namespace:     "my-project",
+			username:      "my_user",
+			groups:        []string{"system:authenticated"},
+			labels:        map[string]string{tierLabel: "platform"},
+			expectAllowed: false,
+		},
+		{
+			name:          "cluster admin presetting a managed label",
+			namespace:     "my-project",
+			username:      "my_user",
+			groups:        []string{"cluster-admins"},
+			labels:        map[string]string{networkPolicyBootstrapLabel: "false"},
+			expectAllowed: false,
+		},
+		{
+			name:          "SRE presetting a managed label",
+			namespace:     "my-project",
+			username:      "backplane-cluster-admin",
+			groups:        []string{"system:authenticated"},
+			labels:        map[string]string{networkPolicyBootstrapLabel: "false"},
+			expectAllowed: true,
+			expected: map[string]string{
+				tierLabel:                          "customer",
+				networkPolicyBootstrapLabel:        "false",
+				"pod-security.kubernetes.io/audit": "restricted",
+				"pod-security.kubernetes.io/warn":  "restricted",
+			},
+		},
+		{
+			name:          "managed namespace",
+			namespace:     "openshift-monitoring",
+			username:      "system:admin",
+			groups:        []string{"system:masters"},
+			expectAllowed: true,
+			expected:      nil,
+		},
+	}
+
+	gvk := metav1.GroupVersionKind{Version: "v1", Kind: "Namespace"}
+	gvr := metav1.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			raw := createNamespaceRaw(t, test.namespace, test.labels)
+			hook := NewWebhook()
+			httprequest, err := testutils.CreateHTTPRequest(hook.GetURI(), "test-uid", gvk, gvr, admissionv1.Create,
+				test.username, test.groups, "", &runtime.RawExtension{Raw: raw}, nil)
+			if err != nil {
+				t.Fatalf("Expected no error, got %s", err.Error())
+			}
+			response, err := testutils.SendHTTPRequest(httprequest, hook)
+			if err != nil {
+				t.Fatalf("Expected no error, got %s", err.Error())
+			}
+			if response.Allowed != test.expectAllowed {
+				t.Fatalf("Expected allowed %v, got %v: %v", test.expectAllowed, response.Allowed, response.Result)
+			}
+			if !response.Allowed {
+				return
+			}
+
+			mutated := raw
+			if len(response.Patch) > 0 {
+				patch, err := patchengine.DecodePatch(response.Patch)
+				if err != nil {
+					t.Fatalf("Expected no error decoding the patch, got %s", err.Error())
+				}
+				if mutated, err = patch.Apply(raw); err != nil {
+					t.Fatalf("Expected no error applying the patch, got %s", err.Error())
+				}
+			}
+			ns := corev1.Namespace{}
+			if err := json.Unmarshal(mutated, &ns); err != nil {
+				t.Fatalf("Expected no error, got %s", err.Error())
+			}
+			if !reflect.DeepEqual(ns.Labels, test.expected) {
+				t.Fatalf("Expected labels %v, got %v", test.expected, ns.Labels)
+			}
+		})
+	}
+}
@@ -0,0 +1,517 @@
+package namespacerate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/config"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "namespacerate-validation"
+	docString   string = `Managed OpenShift customers may not create more than %d namespaces or projects per user within %s, as bursts of namespace creation put pressure on etcd. SRE may change the limits in the %s ConfigMap. Each replica of the webhook server counts the creations it admits, so the limit applies per replica: with several replicas, a user may create up to the limit through each of them.`
+
+	// LimitsConfigMapName is the ConfigMap in the webhook namespace holding
+	// the limits set by SRE
+	LimitsConfigMapName string = "namespace-creation-limits"
+	// maxCreationsKey is how many namespaces a user may create per window
+	maxCreationsKey string = "maxCreations"
+	// windowKey is the duration of the sliding window
+	windowKey string = "window"
+	// persistKey, when "true", keeps the recent creations in the
+	// StateConfigMapName ConfigMap, so they survive restarts of the webhook
+	persistKey string = "persist"
+
+	// StateConfigMapName is the ConfigMap in the webhook namespace the recent
+	// creations are persisted to
+	StateConfigMapName string = "namespace-creation-state"
+	stateKey           string = "creations"
+
+	// limitsTTL is how long the limits read from the ConfigMap are reused
+	limitsTTL = 30 * time.Second
+	// persistInterval is the shortest time between writes of the state
+	persistInterval = 10 * time.Second
+)
+
+var (
+	// defaultLimits are used unless the ConfigMap sets them
+	defaultLimits = limits{
+		maxCreations: 30,
+		window:       10 * time.Minute,
+	}
+
+	scope = admissionregv1.ClusterScope
+	rules = []admissionregv1.RuleWithOperations{
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Create},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{""},
+				APIVersions: []string{"*"},
+				Resources:   []string{"namespaces"},
+				Scope:       &scope,
+			},
+		},
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Create},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{"project.openshift.io"},
+				APIVersions: []string{"*"},
+				Resources:   []string{"projectrequests"},
+				Scope:       &scope,
+			},
+		},
+	}
+	log = logf.Log.WithName(WebhookName)
+
+	// state is shared by every NamespaceRateWebhook because the dispatcher
+	// builds a new webhook for each request
+	state = newRateState()
+)
+
+// limits are the namespace creation limits of the cluster
+type limits struct {
+	maxCreations int
+	window       time.Duration
+	persist      bool
+}
+
+// NamespaceRateWebhook limits how many namespaces and projects each user may
+// create within a sliding window. Creations are counted by each replica of the
+// webhook server, so the limit is per replica. The persisted creations are
+// those of every replica, which a restarted replica counts against its limit.
+type NamespaceRateWebhook struct {
+	s          *runtime.Scheme
+	kubeClient client.Client
+	now        func() time.Time
+	*rateState
+}
+
+// rateState holds the namespace creations counted by this replica of the
+// webhook server
+type rateState struct {
+	mu sync.Mutex
+	// creations holds the times of each user's recent creations, oldest first
+	creations map[string][]time.Time
+	limits    limits
+	// limitsRead is when the limits were last read from the ConfigMap
+	limitsRead time.Time
+	// loaded tells whether the persisted creations were loaded
+	loaded        bool
+	lastPersisted time.Time
+}
+
+func newRateState() *rateState {
+	return &rateState{creations: map[string][]time.Time{}}
+}
+
+// NewWebhook creates a new webhook
+func NewWebhook() *NamespaceRateWebhook {
+	scheme := runtime.NewScheme()
+	err := admissionv1.AddToScheme(scheme)
+	if err != nil {
+		log.Error(err, "Fail adding admissionv1 scheme to NamespaceRateWebhook")
+		os.Exit(1)
+	}
+	err = corev1.AddToScheme(scheme)
+	if err != nil {
+		log.Error(err, "Fail adding corev1 scheme to NamespaceRateWebhook")
+		os.Exit(1)
+	}
+
+	return &NamespaceRateWebhook{
+		s:         scheme,
+		now:       time.Now,
+		rateState: state,
+	}
+}
+
+// InjectClient implements ClientWebhook interface
+func (s *NamespaceRateWebhook) InjectClient(c client.Client) {
+	s.kubeClient = c
+}
+
+// CachedObjects implements ClientWebhook interface. The ConfigMaps are read
+// at most every limitsTTL, so they aren't cached.
+func (s *NamespaceRateWebhook) CachedObjects() []client.Object {
+	return nil
+}
+
+// Authorized implements Webhook interface
+func (s *NamespaceRateWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.authorized(context.Background(), request)
+}
+
+// AuthorizedContext implements ContextWebhook interface
+func (s *NamespaceRateWebhook) AuthorizedContext(ctx context.Context, request admissionctl.Request) admissionctl.Response {
+	return s.authorized(ctx, request)
+}
+
+func (s *NamespaceRateWebhook) authorized(ctx context.Context, request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if identity.IsAdmin(request.UserInfo) {
+		ret = admissionctl.Allowed("Admins and managed service accounts may create namespaces without limit")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	l := s.currentLimits(ctx)
+	if l.persist {
+		s.load(ctx)
+	}
+
+	s.mu.Lock()
+	now := s.now()
+	user := request.UserInfo.Username
+	recent := prune(s.creations[user], now.Add(-l.window))
+	if len(recent) >= l.maxCreations {
+		s.creations[user] = recent
+		s.mu.Unlock()
+		log.Info("Denying namespace creation over the rate limit", "user", user, "kind", request.Kind.Kind, "name", request.Name, "recent", len(recent))
+		ret = response.Denied(response.NamespaceCreationRateLimited, fmt.Sprintf("Prevented from creating more than %d namespaces or projects within %s. Please wait before creating more. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", l.maxCreations, l.window))
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	// Dry runs don't create anything, so they aren't counted
+	if request.DryRun == nil || !*request.DryRun {
+		recent = append(recent, now)
+	}
+	s.creations[user] = recent
+	var snapshot map[string][]time.Time
+	if l.persist && now.Sub(s.lastPersisted) >= persistInterval {
+		s.lastPersisted = now
+		snapshot = s.snapshot(now.Add(-l.window))
+	}
+	s.mu.Unlock()
+
+	if snapshot != nil {
+		// Don't hold up the request writing the state
+		go s.persist(snapshot, now.Add(-l.window))
+	}
+
+	ret = admissionctl.Allowed("Namespace creation is within the rate limit")
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// prune drops the times before since
+func prune(times []time.Time, since time.Time) []time.Time {
+	i := 0
+	for i < len(times) && times[i].Before(since) {
+		i++
+	}
+	return times[i:]
+}
+
+// currentLimits returns the limits, reading them from the ConfigMap when
+// they're older than limitsTTL. The defaults are used when the ConfigMap
+// can't be read or is invalid.
+func (s *NamespaceRateWebhook) currentLimits(ctx context.Context) limits {
+	s.mu.Lock()
+	if !s.limitsRead.IsZero() && s.now().Sub(s.limitsRead) < limitsTTL {
+		defer s.mu.Unlock()
+		return s.limits
+	}
+	s.mu.Unlock()
+
+	l := defaultLimits
+	cm := &corev1.ConfigMap{}
+	c, err := s.client()
+	if err == nil {
+		err = c.Get(ctx, client.ObjectKey{Namespace: config.OperatorNamespace, Name: LimitsConfigMapName}, cm)
+	}
+	switch {
+	case err != nil && !apierrors.IsNotFound(err):
+		log.Error(err, "Failed to read namespace creation limits, using the defaults")
+	case err == nil:
+		if parsed, err := parseLimits(cm.Data); err != nil {
+			log.Error(err, "Invalid namespace creation limits, using the defaults")
+		} else {
+			l = parsed
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limits = l
+	s.limitsRead = s.now()
+	return l
+}
+
+// parseLimits parses the limits in the ConfigMap data
+func parseLimits(data map[string]string) (limits, error) {
+	l := defaultLimits
+	if value, ok := data[maxCreationsKey]; ok {
+		maxCreations, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || maxCreations < 1 {
+			return limits{}, fmt.Errorf("invalid %s %q", maxCreationsKey, value)
+		}
+		l.maxCreations = maxCreations
+	}
+	if value, ok := data[windowKey]; ok {
+		window, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || window <= 0 {
+			return limits{}, fmt.Errorf("invalid %s %q", windowKey, value)
+		}
+		l.window = window
+	}
+	if value, ok := data[persistKey]; ok {
+		persist, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return limits{}, fmt.Errorf("invalid %s %q", persistKey, value)
+		}
+		l.persist = persist
+	}
+	return l, nil
+}
+
+// load reads the persisted creations once, merging them with those counted
+// since the webhook started
+func (s *NamespaceRateWebhook) load(ctx context.Context) {
+	s.mu.Lock()
+	loaded := s.loaded
+	s.mu.Unlock()
+	if loaded {
+		return
+	}
+
+	cm := &corev1.ConfigMap{}
+	c, err := s.client()
+	if err == nil {
+		err = c.Get(ctx, client.ObjectKey{Namespace: config.OperatorNamespace, Name: StateConfigMapName}, cm)
+	}
+	if err != nil && !apierrors.IsNotFound(err) {
+		log.Error(err, "Failed to read persisted namespace creations")
+		return
+	}
+	persisted := map[string][]time.Time{}
+	if raw := cm.Data[stateKey]; raw != "" {
+		if err := json.Unmarshal([]byte(raw), &persisted); err != nil {
+			log.Error(err, "Ignoring invalid persisted namespace creations")
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.loaded {
+		return
+	}
+	for user, times := range persisted {
+		s.creations[user] = mergeTimes(times, s.creations[user])
+	}
+	s.loaded = true
+}
+
+// mergeTimes merges two lists of times, each oldest first
+func mergeTimes(a, b []time.Time) []time.Time {
+	merged := make([]time.Time, 0, len(a)+len(b))
+	for len(a) > 0 && len(b) > 0 {
+		if a[0].Before(b[0]) {
+			merged, a = append(merged, a[0]), a[1:]
+		} else {
+			merged, b = append(merged, b[0]), b[1:]
+		}
+	}
+	return append(append(merged, a...), b...)
+}
+
+// mergeUniqueTimes merges two lists of times, each oldest first, keeping
+// times in both only once. A replica's creations include those it loaded, so
+// merging them back into the persisted ones mustn't count those twice.
+func mergeUniqueTimes(a, b []time.Time) []time.Time {
+	merged := mergeTimes(a, b)
+	unique := merged[:0]
+	for i, t := range merged {
+		if i == 0 || !t.Equal(merged[i-1]) {
+			unique = append(unique, t)
+		}
+	}
+	return unique
+}
+
+// snapshot copies the creations since the start of the window. s.mu must be
+// held.
+func (s *NamespaceRateWebhook) snapshot(since time.Time) map[string][]time.Time {
+	state := map[string][]time.Time{}
+	for user, times := range s.creations {
+		if recent := prune(times, since); len(recent) > 0 {
+			state[user] = append([]time.Time{}, recent...)
+		}
+	}
+	return state
+}
+
+// persist merges the creations since the start of the window into those in
+// the state ConfigMap. Every replica persists its own creations, so they're
+// merged rather than overwriting those of the other replicas, retrying when
+// another replica wrote the ConfigMap first.
+func (s *NamespaceRateWebhook) persist(snapshot map[string][]time.Time, since time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c, err := s.client()
+	if err != nil {
+		log.Error(err, "Failed to persist namespace creations")
+		return
+	}
+	conflict := func(err error) bool {
+		return apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err)
+	}
+	err = retry.OnError(retry.DefaultRetry, conflict, func() error {
+		cm := &corev1.ConfigMap{}
+		err := c.Get(ctx, client.ObjectKey{Namespace: config.OperatorNamespace, Name: StateConfigMapName}, cm)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		found := err == nil
+
+		persisted := map[string][]time.Time{}
+		if raw := cm.Data[stateKey]; raw != "" {
+			if err := json.Unmarshal([]byte(raw), &persisted); err != nil {
+				log.Error(err, "Overwriting invalid persisted namespace creations")
+				persisted = map[string][]time.Time{}
+			}
+		}
+		state := map[string][]time.Time{}
+		for user, times := range persisted {
+			if recent := prune(times, since); len(recent) > 0 {
+				state[user] = recent
+			}
+		}
+		for user, times := range snapshot {
+			state[user] = mergeUniqueTimes(state[user], times)
+		}
+		raw, err := json.Marshal(state)
+		if err != nil {
+			return err
+		}
+
+		if !found {
+			cm = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: config.OperatorNamespace, Name: StateConfigMapName},
+				Data:       map[string]string{stateKey: string(raw)},
+			}
+			return c.Create(ctx, cm)
+		}
+		cm.Data = map[string]string{stateKey: string(raw)}
+		return c.Update(ctx, cm)
+	})
+	if err != nil {
+		log.Error(err, "Failed to persist namespace creations")
+	}
+}
+
+// client returns the client to read and persist the ConfigMaps with, creating
+// one if none was injected
+func (s *NamespaceRateWebhook) client() (client.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.kubeClient == nil {
+		var err error
+		s.kubeClient, err = k8sutil.KubeClient(s.s)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s.kubeClient, nil
+}
+
+// GetURI implements Webhook interface
+func (s *NamespaceRateWebhook) GetURI() string {
+	return "/" + WebhookName
+}
+
+// Validate implements Webhook interface
+func (s *NamespaceRateWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	valid = valid && (request.Kind.Kind == "Namespace" || request.Kind.Kind == "ProjectRequest")
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *NamespaceRateWebhook) Name() string {
+	return WebhookName
+}
+
+// FailurePolicy implements Webhook interface
+func (s *NamespaceRateWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *NamespaceRateWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *NamespaceRateWebhook) Rules() []admissionregv1.RuleWithOperations {
+	return rules
+}
+
+// ObjectSelector implements Webhook interface
+func (s *NamespaceRateWebhook) ObjectSelector() *metav1.LabelSelector {
+	return nil
+}
+
+// SideEffects implements Webhook interface. Creations are counted, and
+// possibly persisted, except for dry runs.
+func (s *NamespaceRateWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNoneOnDryRun
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *NamespaceRateWebhook) TimeoutSeconds() int32 {
+	return 2
+}
+
+// Doc implements Webhook interface
+func (s *NamespaceRateWebhook) Doc() string {
+	return fmt.Sprintf(docString, defaultLimits.maxCreations, defaultLimits.window, LimitsConfigMapName)
+}
+
+// RuleDocs implements Webhook interface
+func (s *NamespaceRateWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers may not create namespaces or projects faster than the rate limit set by SRE. The limit applies to each replica of the webhook server.",
+			Exceptions: []string{utils.AdminsException},
+		},
+	}
+}
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+func (s *NamespaceRateWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// ClassicEnabled implements Webhook interface
+func (s *NamespaceRateWebhook) ClassicEnabled() bool {
+	return true
+}
+
+// HypershiftEnabled implements Webhook interface
+func (s *NamespaceRateWebhook) HypershiftEnabled() bool {
+	return true
+}
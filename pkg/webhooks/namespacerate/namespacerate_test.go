@@ -0,0 +1,235 @@
+package namespacerate
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/config"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func newRequest(t *testing.T, kind, name, username string, groups []string, dryRun bool) admissionctl.Request {
+	request := testutils.NewRequest(t, metav1.GroupVersionKind{Version: "v1", Kind: kind}, admissionv1.Create, authenticationv1.UserInfo{Username: username, Groups: groups}, "", name, nil, nil)
+	request.DryRun = ptr.To(dryRun)
+	return request
+}
+
+func limitsConfigMap(data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: config.OperatorNamespace, Name: LimitsConfigMapName},
+		Data:       data,
+	}
+}
+
+// newTestWebhook returns a webhook reading its ConfigMaps from objs, whose
+// clock is advanced by the returned function
+func newTestWebhook(objs ...client.Object) (*NamespaceRateWebhook, client.Client, func(time.Duration)) {
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(objs...).Build()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewWebhook()
+	s.rateState = newRateState()
+	s.InjectClient(c)
+	s.now = func() time.Time { return now }
+	return s, c, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestAuthorized(t *testing.T) {
+	s, _, advance := newTestWebhook(limitsConfigMap(map[string]string{maxCreationsKey: "2", windowKey: "1m"}))
+
+	tests := []struct {
+		name     string
+		kind     string
+		username string
+		groups   []string
+		dryRun   bool
+		advance  time.Duration
+		allowed  bool
+	}{
+		{name: "first namespace", kind: "Namespace", username: "customer", allowed: true},
+		{name: "dry run isn't counted", kind: "Namespace", username: "customer", dryRun: true, allowed: true},
+		{name: "second project", kind: "ProjectRequest", username: "customer", advance: 10 * time.Second, allowed: true},
+		{name: "over the limit", kind: "Namespace", username: "customer", allowed: false},
+		{name: "dry run over the limit", kind: "Namespace", username: "customer", dryRun: true, allowed: false},
+		{name: "other users aren't limited", kind: "Namespace", username: "other", allowed: true},
+		{name: "SRE", kind: "Namespace", username: "backplane-cluster-admin", allowed: true},
+		{
+			name:     "openshift-apiserver creating a project",
+			kind:     "Namespace",
+			username: "system:serviceaccount:openshift-apiserver:openshift-apiserver-sa",
+			groups:   []string{"system:serviceaccounts", "system:serviceaccounts:openshift-apiserver"},
+			allowed:  true,
+		},
+		{name: "first creation left the window", kind: "Namespace", username: "customer", advance: 55 * time.Second, allowed: true},
+		{name: "window is full again", kind: "Namespace", username: "customer", allowed: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			advance(test.advance)
+			request := newRequest(t, test.kind, "new-namespace", test.username, test.groups, test.dryRun)
+			if !s.Validate(request) {
+				t.Fatalf("Expected the request to be valid")
+			}
+			response := s.Authorized(request)
+			if response.Allowed != test.allowed {
+				t.Fatalf("Expected allowed %t, got %t: %s", test.allowed, response.Allowed, response.Result.Message)
+			}
+			if response.UID != "test-uid" {
+				t.Errorf("Expected the response UID to be set")
+			}
+			if !test.allowed && !strings.Contains(response.Result.Message, "more than 2 namespaces or projects within 1m0s") {
+				t.Errorf("Expected the denial to name the limits, got %s", response.Result.Message)
+			}
+		})
+	}
+}
+
+func TestSharedState(t *testing.T) {
+	state = newRateState()
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(limitsConfigMap(map[string]string{maxCreationsKey: "1"})).Build()
+
+	// The dispatcher builds a new webhook for each request
+	for i, allowed := range []bool{true, false} {
+		s := NewWebhook()
+		s.InjectClient(c)
+		if response := s.Authorized(newRequest(t, "Namespace", "new-namespace", "customer", nil, false)); response.Allowed != allowed {
+			t.Errorf("Expected request %d to be allowed %t, got %t", i, allowed, response.Allowed)
+		}
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     map[string]string
+		expected limits
+		err      bool
+	}{
+		{name: "defaults", data: nil, expected: defaultLimits},
+		{
+			name:     "all set",
+			data:     map[string]string{maxCreationsKey: "5", windowKey: "1h", persistKey: "true"},
+			expected: limits{maxCreations: 5, window: time.Hour, persist: true},
+		},
+		{name: "invalid count", data: map[string]string{maxCreationsKey: "0"}, err: true},
+		{name: "invalid window", data: map[string]string{windowKey: "soon"}, err: true},
+		{name: "invalid persist", data: map[string]string{persistKey: "maybe"}, err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := parseLimits(test.data)
+			if (err != nil) != test.err {
+				t.Fatalf("Expected error %t, got %v", test.err, err)
+			}
+			if err == nil && actual != test.expected {
+				t.Errorf("Expected limits %+v, got %+v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestLimitsInvalidConfigMap(t *testing.T) {
+	s, _, _ := newTestWebhook(limitsConfigMap(map[string]string{maxCreationsKey: "lots"}))
+	if l := s.currentLimits(context.Background()); l != defaultLimits {
+		t.Errorf("Expected the default limits, got %+v", l)
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	ctx := context.Background()
+	limits := limitsConfigMap(map[string]string{maxCreationsKey: "2", windowKey: "1h", persistKey: "true"})
+	s, c, _ := newTestWebhook(limits)
+
+	if response := s.Authorized(newRequest(t, "Namespace", "first", "customer", nil, false)); !response.Allowed {
+		t.Fatalf("Expected the first namespace to be allowed: %s", response.Result.Message)
+	}
+
+	state := &corev1.ConfigMap{}
+	err := wait.PollUntilContextTimeout(ctx, 10*time.Millisecond, 5*time.Second, true, func(ctx context.Context) (bool, error) {
+		return c.Get(ctx, client.ObjectKey{Namespace: config.OperatorNamespace, Name: StateConfigMapName}, state) == nil, nil
+	})
+	if err != nil {
+		t.Fatalf("Expected the creations to be persisted, got %v", err)
+	}
+	persisted := map[string][]time.Time{}
+	if err := json.Unmarshal([]byte(state.Data[stateKey]), &persisted); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	if len(persisted["customer"]) != 1 {
+		t.Fatalf("Expected one persisted creation, got %v", persisted)
+	}
+
+	// A restarted webhook carries on counting from the persisted creations
+	state.ResourceVersion = ""
+	restarted, _, _ := newTestWebhook(limits, state)
+	if response := restarted.Authorized(newRequest(t, "Namespace", "second", "customer", nil, false)); !response.Allowed {
+		t.Fatalf("Expected the second namespace to be allowed: %s", response.Result.Message)
+	}
+	if response := restarted.Authorized(newRequest(t, "Namespace", "third", "customer", nil, false)); response.Allowed {
+		t.Errorf("Expected the third namespace to be denied after the restart")
+	}
+}
+
+func TestPersistenceMergesReplicas(t *testing.T) {
+	ctx := context.Background()
+	limits := limitsConfigMap(map[string]string{maxCreationsKey: "3", windowKey: "1h", persistKey: "true"})
+	first, c, advance := newTestWebhook(limits)
+	second := NewWebhook()
+	second.rateState = newRateState()
+	second.InjectClient(c)
+	second.now = first.now
+
+	// Each replica admits a creation, the second after loading the first's
+	for i, s := range []*NamespaceRateWebhook{first, second} {
+		s.load(ctx)
+		if response := s.Authorized(newRequest(t, "Namespace", "new-namespace", "customer", nil, false)); !response.Allowed {
+			t.Fatalf("Expected the namespace to be allowed: %s", response.Result.Message)
+		}
+		since := s.now().Add(-time.Hour)
+		s.mu.Lock()
+		snapshot := s.snapshot(since)
+		s.mu.Unlock()
+		s.persist(snapshot, since)
+		if i == 0 {
+			advance(time.Minute)
+		}
+	}
+
+	state := &corev1.ConfigMap{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: config.OperatorNamespace, Name: StateConfigMapName}, state); err != nil {
+		t.Fatalf("Expected the creations to be persisted, got %v", err)
+	}
+	persisted := map[string][]time.Time{}
+	if err := json.Unmarshal([]byte(state.Data[stateKey]), &persisted); err != nil {
+		t.Fatalf("Expected no error, got %s", err.Error())
+	}
+	// The second replica's write keeps the first's creation, without counting
+	// the creation it loaded twice
+	if len(persisted["customer"]) != 2 {
+		t.Errorf("Expected both replicas' creations to be persisted, got %v", persisted)
+	}
+}
+
+func TestMergeUniqueTimes(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	a := []time.Time{start, start.Add(2 * time.Second)}
+	b := []time.Time{start, start.Add(time.Second)}
+	merged := mergeUniqueTimes(a, b)
+	if len(merged) != 3 || !merged[0].Equal(start) || !merged[1].Equal(start.Add(time.Second)) || !merged[2].Equal(start.Add(2*time.Second)) {
+		t.Errorf("Expected three times oldest first, got %v", merged)
+	}
+}
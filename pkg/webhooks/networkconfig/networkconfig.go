@@ -0,0 +1,198 @@
+package networkconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "networkconfig-validation"
+	docString   string = `Managed OpenShift customers, including cluster admins, may not change the cluster network, service network or network type of the cluster network.config and network.operator configs, which are fixed when the cluster is installed. Other fields, such as annotations, may still be changed.`
+
+	// clusterName is the name of the singleton network configs
+	clusterName string = "cluster"
+)
+
+var (
+	scope = admissionregv1.ClusterScope
+	rules = []admissionregv1.RuleWithOperations{
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Update},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{"config.openshift.io", "operator.openshift.io"},
+				APIVersions: []string{"*"},
+				Resources:   []string{"networks"},
+				Scope:       &scope,
+			},
+		},
+	}
+	log = logf.Log.WithName(WebhookName)
+
+	// protectedFields are the fields of each group's Network config which may
+	// not be changed, as paths
+	protectedFields = map[string][][]string{
+		"config.openshift.io": {
+			{"spec", "clusterNetwork"},
+			{"spec", "serviceNetwork"},
+			{"spec", "networkType"},
+		},
+		"operator.openshift.io": {
+			{"spec", "clusterNetwork"},
+			{"spec", "serviceNetwork"},
+			{"spec", "defaultNetwork", "type"},
+		},
+	}
+)
+
+// NetworkConfigWebhook protects the network configuration fixed at install
+type NetworkConfigWebhook struct{}
+
+// NewWebhook creates a new webhook
+func NewWebhook() *NetworkConfigWebhook {
+	return &NetworkConfigWebhook{}
+}
+
+// Authorized implements Webhook interface
+func (s *NetworkConfigWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.authorized(request)
+}
+
+func (s *NetworkConfigWebhook) authorized(request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if request.Name != clusterName {
+		ret = admissionctl.Allowed("Only the cluster network config is protected")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	if identity.IsManager(request.UserInfo) {
+		ret = admissionctl.Allowed("SRE, managed service accounts and Hive may change the network config")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	changed, err := changedFields(request.Kind.Group, request.OldObject.Raw, request.Object.Raw)
+	if err != nil {
+		log.Error(err, "Couldn't decode the Network config from the request")
+		ret = admissionctl.Errored(http.StatusBadRequest, err)
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	if len(changed) == 0 {
+		ret = admissionctl.Allowed("The protected network config fields are unchanged")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	log.Info("Denying change of protected network config fields", "group", request.Kind.Group, "fields", changed, "user", request.UserInfo.Username)
+	ret = response.Denied(response.ManagedNetworkConfig, fmt.Sprintf("Prevented from changing %s of the %s Network config %s, which can't be changed on managed clusters. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", strings.Join(changed, ", "), request.Kind.Group, request.Name))
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// changedFields returns the protected fields of the group's Network config
+// which differ between the old and new objects, as dotted paths. Only the
+// protected fields are compared, so any other field may change.
+func changedFields(group string, oldRaw, newRaw []byte) ([]string, error) {
+	old := map[string]interface{}{}
+	if err := json.Unmarshal(oldRaw, &old); err != nil {
+		return nil, err
+	}
+	obj := map[string]interface{}{}
+	if err := json.Unmarshal(newRaw, &obj); err != nil {
+		return nil, err
+	}
+
+	changed := []string{}
+	for _, path := range protectedFields[group] {
+		oldValue, _, _ := unstructured.NestedFieldNoCopy(old, path...)
+		newValue, _, _ := unstructured.NestedFieldNoCopy(obj, path...)
+		if !equality.Semantic.DeepEqual(oldValue, newValue) {
+			changed = append(changed, strings.Join(path, "."))
+		}
+	}
+	return changed, nil
+}
+
+// GetURI implements Webhook interface
+func (s *NetworkConfigWebhook) GetURI() string { return "/" + WebhookName }
+
+// Validate implements Webhook interface
+func (s *NetworkConfigWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	valid = valid && (request.Kind.Kind == "Network")
+	_, known := protectedFields[request.Kind.Group]
+	valid = valid && known
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *NetworkConfigWebhook) Name() string { return WebhookName }
+
+// FailurePolicy implements Webhook interface
+func (s *NetworkConfigWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *NetworkConfigWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *NetworkConfigWebhook) Rules() []admissionregv1.RuleWithOperations { return rules }
+
+// ObjectSelector implements Webhook interface
+func (s *NetworkConfigWebhook) ObjectSelector() *metav1.LabelSelector { return nil }
+
+// SideEffects implements Webhook interface
+func (s *NetworkConfigWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *NetworkConfigWebhook) TimeoutSeconds() int32 { return 2 }
+
+// Doc implements Webhook interface
+func (s *NetworkConfigWebhook) Doc() string { return docString }
+
+// RuleDocs implements Webhook interface
+func (s *NetworkConfigWebhook) RuleDocs() []utils.RuleDoc {
+	exceptions := []string{utils.SREException, utils.PrivilegedServiceAccountsException, "Hive, as system:admin"}
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers, including cluster admins, may not change spec.clusterNetwork, spec.serviceNetwork or spec.networkType of the cluster network.config.openshift.io config.",
+			Exceptions: exceptions,
+		},
+		{
+			Summary:    "Customers, including cluster admins, may not change spec.clusterNetwork, spec.serviceNetwork or spec.defaultNetwork.type of the cluster network.operator.openshift.io config.",
+			Exceptions: exceptions,
+		},
+	}
+}
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+func (s *NetworkConfigWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// ClassicEnabled implements Webhook interface
+func (s *NetworkConfigWebhook) ClassicEnabled() bool { return true }
+
+// HypershiftEnabled implements Webhook interface. Hosted clusters' networks
+// are configured through their HostedCluster, outside the cluster.
+func (s *NetworkConfigWebhook) HypershiftEnabled() bool { return false }
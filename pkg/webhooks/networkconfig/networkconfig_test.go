@@ -0,0 +1,89 @@
+package networkconfig
+
+import (
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+)
+
+var (
+	clusterAdmin = authenticationv1.UserInfo{Username: "kube:admin", Groups: []string{"system:cluster-admins", "system:authenticated"}}
+	sre          = authenticationv1.UserInfo{Username: "backplane-cluster-admin"}
+	hive         = authenticationv1.UserInfo{Username: "system:admin", Groups: []string{"system:masters", "system:authenticated"}}
+	cno          = authenticationv1.UserInfo{Username: "system:serviceaccount:openshift-network-operator:cluster-network-operator", Groups: []string{"system:serviceaccounts", "system:serviceaccounts:openshift-network-operator"}}
+)
+
+const (
+	configNetwork = `{"apiVersion":"config.openshift.io/v1","kind":"Network","metadata":{"name":"cluster"},
+		"spec":{"clusterNetwork":[{"cidr":"10.128.0.0/14","hostPrefix":23}],"serviceNetwork":["172.30.0.0/16"],"networkType":"OVNKubernetes"}}`
+	configNetworkAnnotated = `{"apiVersion":"config.openshift.io/v1","kind":"Network","metadata":{"name":"cluster","annotations":{"network.openshift.io/network-type-migration":""}},
+		"spec":{"clusterNetwork":[{"hostPrefix":23,"cidr":"10.128.0.0/14"}],"serviceNetwork":["172.30.0.0/16"],"networkType":"OVNKubernetes"}}`
+	configNetworkNewCIDR = `{"apiVersion":"config.openshift.io/v1","kind":"Network","metadata":{"name":"cluster"},
+		"spec":{"clusterNetwork":[{"cidr":"10.128.0.0/14","hostPrefix":23},{"cidr":"10.200.0.0/14","hostPrefix":23}],"serviceNetwork":["172.30.0.0/16"],"networkType":"OVNKubernetes"}}`
+	configNetworkNewType = `{"apiVersion":"config.openshift.io/v1","kind":"Network","metadata":{"name":"cluster"},
+		"spec":{"clusterNetwork":[{"cidr":"10.128.0.0/14","hostPrefix":23}],"serviceNetwork":["172.31.0.0/16"],"networkType":"OpenShiftSDN"}}`
+	operatorNetwork = `{"apiVersion":"operator.openshift.io/v1","kind":"Network","metadata":{"name":"cluster"},
+		"spec":{"clusterNetwork":[{"cidr":"10.128.0.0/14","hostPrefix":23}],"serviceNetwork":["172.30.0.0/16"],"defaultNetwork":{"type":"OVNKubernetes"}}}`
+	operatorNetworkTuned = `{"apiVersion":"operator.openshift.io/v1","kind":"Network","metadata":{"name":"cluster"},
+		"spec":{"clusterNetwork":[{"cidr":"10.128.0.0/14","hostPrefix":23}],"serviceNetwork":["172.30.0.0/16"],"defaultNetwork":{"type":"OVNKubernetes","ovnKubernetesConfig":{"mtu":1400}}}}`
+	operatorNetworkNewType = `{"apiVersion":"operator.openshift.io/v1","kind":"Network","metadata":{"name":"cluster"},
+		"spec":{"clusterNetwork":[{"cidr":"10.128.0.0/14","hostPrefix":23}],"serviceNetwork":["172.30.0.0/16"],"defaultNetwork":{"type":"OpenShiftSDN"}}}`
+)
+
+func TestAuthorized(t *testing.T) {
+	tests := []struct {
+		name      string
+		user      authenticationv1.UserInfo
+		group     string
+		objName   string
+		oldObject string
+		object    string
+		allowed   bool
+	}{
+		{"cluster admin annotates config", clusterAdmin, "config.openshift.io", "cluster", configNetwork, configNetworkAnnotated, true},
+		{"cluster admin adds cluster network", clusterAdmin, "config.openshift.io", "cluster", configNetwork, configNetworkNewCIDR, false},
+		{"cluster admin changes network type and service network", clusterAdmin, "config.openshift.io", "cluster", configNetwork, configNetworkNewType, false},
+		{"cluster admin tunes operator config", clusterAdmin, "operator.openshift.io", "cluster", operatorNetwork, operatorNetworkTuned, true},
+		{"cluster admin changes operator network type", clusterAdmin, "operator.openshift.io", "cluster", operatorNetwork, operatorNetworkNewType, false},
+		{"cluster admin changes other network config", clusterAdmin, "config.openshift.io", "other", configNetwork, configNetworkNewType, true},
+		{"sre changes network type", sre, "config.openshift.io", "cluster", configNetwork, configNetworkNewType, true},
+		{"hive changes network type", hive, "config.openshift.io", "cluster", configNetwork, configNetworkNewType, true},
+		{"network operator changes operator network type", cno, "operator.openshift.io", "cluster", operatorNetwork, operatorNetworkNewType, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := NewWebhook()
+			request := testutils.NewRequest(t, metav1.GroupVersionKind{Group: test.group, Version: "v1", Kind: "Network"}, admissionv1.Update, test.user, "", test.objName, []byte(test.object), []byte(test.oldObject))
+			if !s.Validate(request) {
+				t.Fatalf("Expected the request to be valid")
+			}
+			response := s.Authorized(request)
+			if response.Allowed != test.allowed {
+				t.Errorf("Expected allowed %t, got %t: %s", test.allowed, response.Allowed, response.Result.Message)
+			}
+			if response.UID != "test-uid" {
+				t.Errorf("Expected the response UID to be set")
+			}
+		})
+	}
+}
+
+func TestChangedFields(t *testing.T) {
+	changed, err := changedFields("config.openshift.io", []byte(configNetwork), []byte(configNetworkNewType))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := []string{"spec.serviceNetwork", "spec.networkType"}
+	if len(changed) != len(expected) || changed[0] != expected[0] || changed[1] != expected[1] {
+		t.Errorf("Expected changed fields %v, got %v", expected, changed)
+	}
+
+	if _, err := changedFields("config.openshift.io", []byte(configNetwork), []byte("not json")); err == nil {
+		t.Errorf("Expected an error decoding a malformed object")
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"slices"
 
 	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
 	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionregv1 "k8s.io/api/admissionregistration/v1"
@@ -37,19 +39,12 @@ var (
 		},
 	}
 
-	// Users allowed to modify critical migration fields (exact username match).
-	// Includes backplane-cluster-admin and CNO/MUO service accounts (username format: system:serviceaccount:<namespace>:<name>).
+	// Users allowed to modify critical migration fields besides SRE (exact username match).
+	// The CNO/MUO service accounts (username format: system:serviceaccount:<namespace>:<name>).
 	allowedUsers = []string{
-		"backplane-cluster-admin",
 		"system:serviceaccount:openshift-network-operator:cluster-network-operator",
 		"system:serviceaccount:openshift-managed-upgrade-operator:managed-upgrade-operator",
 	}
-
-	// Groups allowed to modify critical migration fields (SRE service accounts only).
-	// Kubernetes only assigns system:serviceaccounts and system:serviceaccounts:<namespace>; CNO/MUO are in allowedUsers.
-	sreAdminGroups = []string{
-		"system:serviceaccounts:openshift-backplane-srep",
-	}
 )
 
 type NetworkOperatorWebhook struct {
@@ -99,11 +94,12 @@ func (w *NetworkOperatorWebhook) Authorized(request admissionctl.Request) admiss
 				"username", request.AdmissionRequest.UserInfo.Username,
 				"groups", request.AdmissionRequest.UserInfo.Groups,
 			)
-			return utils.WebhookResponse(
-				request,
-				false,
+			ret := response.Denied(
+				response.ManagedNetworkConfig,
 				"Modification of critical migration fields (spec.migration.networkType and related migration configuration) is not allowed, even for cluster-admin users. These fields are managed by the Cluster Network Operator and manual changes can disrupt CNI migrations.",
 			)
+			ret.UID = request.AdmissionRequest.UID
+			return ret
 		}
 
 		// Allow modifications to non-critical fields
@@ -199,29 +195,18 @@ func isAllowedUserGroup(request admissionctl.Request) bool {
 		"admissionRequestGroups", request.AdmissionRequest.UserInfo.Groups,
 		"userInfoGroups", request.UserInfo.Groups,
 		"allowedUsers", allowedUsers,
-		"sreAdminGroups", sreAdminGroups,
 	)
 
-	// Check username first
-	if slices.Contains(allowedUsers, username) {
-		log.Info("User is in allowedUsers list", "username", username)
+	// SRE, as the identity policy defines them
+	if identity.IsSRE(request.AdmissionRequest.UserInfo) {
+		log.Info("User is SRE", "username", username)
 		return true
 	}
 
-	// Check groups from AdmissionRequest.UserInfo first (for impersonation)
-	for _, group := range sreAdminGroups {
-		if slices.Contains(request.AdmissionRequest.UserInfo.Groups, group) {
-			log.Info("User is in allowed group (from AdmissionRequest)", "group", group)
-			return true
-		}
-	}
-
-	// Check groups from UserInfo as fallback (avoid duplicates by checking separately)
-	for _, group := range sreAdminGroups {
-		if slices.Contains(request.UserInfo.Groups, group) {
-			log.Info("User is in allowed group (from UserInfo)", "group", group)
-			return true
-		}
+	// Check username
+	if slices.Contains(allowedUsers, username) {
+		log.Info("User is in allowedUsers list", "username", username)
+		return true
 	}
 
 	log.Info("User is not authorized", "username", username)
@@ -287,6 +272,16 @@ func (w *NetworkOperatorWebhook) SyncSetLabelSelector() metav1.LabelSelector {
 	return utils.DefaultLabelSelector()
 }
 
+// RuleDocs implements Webhook interface
+func (w *NetworkOperatorWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers, including system:admin, may not modify critical fields of the cluster network.operator config, such as spec.migration.networkType.",
+			Exceptions: []string{utils.SREException, "The Cluster Network Operator and Managed Upgrade Operator service accounts"},
+		},
+	}
+}
+
 func (w *NetworkOperatorWebhook) ClassicEnabled() bool { return true }
 
 // HypershiftEnabled will return boolean value for hypershift enabled configurations
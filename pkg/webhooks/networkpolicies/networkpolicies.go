@@ -3,11 +3,12 @@ package networkpolicies
 import (
 	"fmt"
 	"net/http"
-	"regexp"
 	"slices"
 	"strings"
 
 	hookconfig "github.com/openshift/managed-cluster-validating-webhooks/pkg/config"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
 	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
 
 	admissionregv1 "k8s.io/api/admissionregistration/v1"
@@ -24,12 +25,10 @@ const (
 )
 
 var (
-	timeout                          int32 = 2
-	allowedUsers                           = []string{"system:admin", "backplane-cluster-admin"}
-	sreAdminGroups                         = []string{"system:serviceaccounts:openshift-backplane-srep"}
-	privilegedServiceAccountGroupsRe       = regexp.MustCompile(utils.PrivilegedServiceAccountGroups)
-	scope                                  = admissionregv1.NamespacedScope
-	rules                                  = []admissionregv1.RuleWithOperations{
+	timeout      int32 = 2
+	allowedUsers       = []string{"system:admin"}
+	scope              = admissionregv1.NamespacedScope
+	rules              = []admissionregv1.RuleWithOperations{
 		{
 			Operations: []admissionregv1.OperationType{
 				admissionregv1.Create,
@@ -82,15 +81,13 @@ func (s *networkpoliciesruleWebhook) authorized(request admissionctl.Request) ad
 			ret.UID = request.AdmissionRequest.UID
 			return ret
 		}
-		for _, group := range request.UserInfo.Groups {
-			if privilegedServiceAccountGroupsRe.Match([]byte(group)) {
-				ret = admissionctl.Allowed(fmt.Sprintf("Privileged service accounts in group(s) '%s' can operate on NetworkPolicies", strings.Join(request.UserInfo.Groups, ", ")))
-				ret.UID = request.AdmissionRequest.UID
-				return ret
-			}
+		if identity.IsPrivilegedServiceAccount(request.UserInfo) {
+			ret = admissionctl.Allowed(fmt.Sprintf("Privileged service accounts in group(s) '%s' can operate on NetworkPolicies", strings.Join(request.UserInfo.Groups, ", ")))
+			ret.UID = request.AdmissionRequest.UID
+			return ret
 		}
 
-		ret = admissionctl.Denied(fmt.Sprintf("User '%s' prevented from accessing Red Mat managed resources. This is in an effort to prevent harmful actions that may cause unintended consequences or affect the stability of the cluster. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", request.UserInfo.Username))
+		ret = response.Denied(response.ManagedResource, fmt.Sprintf("User '%s' prevented from accessing Red Mat managed resources. This is in an effort to prevent harmful actions that may cause unintended consequences or affect the stability of the cluster. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", request.UserInfo.Username))
 		ret.UID = request.AdmissionRequest.UID
 		return ret
 	}
@@ -99,16 +96,14 @@ func (s *networkpoliciesruleWebhook) authorized(request admissionctl.Request) ad
 		// Allow privileged service accounts (e.g. redhat-*, openshift-*) to
 		// manage NetworkPolicies for non-ingress-controller pods deployed in
 		// this namespace, such as kube-auth-proxy or payload-processing.
-		for _, group := range request.UserInfo.Groups {
-			if privilegedServiceAccountGroupsRe.Match([]byte(group)) {
-				ret = admissionctl.Allowed(fmt.Sprintf("Privileged service accounts in group(s) '%s' can operate on NetworkPolicies in openshift-ingress", strings.Join(request.UserInfo.Groups, ", ")))
-				ret.UID = request.AdmissionRequest.UID
-				return ret
-			}
+		if identity.IsPrivilegedServiceAccount(request.UserInfo) {
+			ret = admissionctl.Allowed(fmt.Sprintf("Privileged service accounts in group(s) '%s' can operate on NetworkPolicies in openshift-ingress", strings.Join(request.UserInfo.Groups, ", ")))
+			ret.UID = request.AdmissionRequest.UID
+			return ret
 		}
 		ingressName, labelFound := np.Spec.PodSelector.MatchLabels["ingresscontroller.operator.openshift.io/deployment-ingresscontroller"]
 		if !labelFound || ingressName == "default" {
-			ret = admissionctl.Denied(fmt.Sprintf("User '%s' prevented from creating network policy that may impact default ingress, which is managed by Red Hat. This is in an effort to prevent harmful actions that may cause unintended consequences or affect the stability of the cluster. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", request.UserInfo.Username))
+			ret = response.Denied(response.NetworkPolicyDefaultIngress, fmt.Sprintf("User '%s' prevented from creating network policy that may impact default ingress, which is managed by Red Hat. This is in an effort to prevent harmful actions that may cause unintended consequences or affect the stability of the cluster. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", request.UserInfo.Username))
 			ret.UID = request.AdmissionRequest.UID
 			return ret
 		}
@@ -126,17 +121,7 @@ func isAllowedNamespace(namespace string) bool {
 
 // isAllowedUser checks if the user or group is allowed to perform the action
 func isAllowedUser(request admissionctl.Request) bool {
-	if slices.Contains(allowedUsers, request.UserInfo.Username) {
-		return true
-	}
-
-	for _, group := range sreAdminGroups {
-		if slices.Contains(request.UserInfo.Groups, group) {
-			return true
-		}
-	}
-
-	return false
+	return identity.IsSRE(request.UserInfo) || slices.Contains(allowedUsers, request.UserInfo.Username)
 }
 
 func (s *networkpoliciesruleWebhook) renderNetworkPolicy(req admissionctl.Request) (*networkingv1.NetworkPolicy, error) {
@@ -205,6 +190,20 @@ func (s *networkpoliciesruleWebhook) Doc() string {
 	return (docString)
 }
 
+// RuleDocs implements Webhook interface
+func (s *networkpoliciesruleWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers may not create NetworkPolicies in namespaces managed by Red Hat.",
+			Exceptions: []string{utils.SREException, utils.PrivilegedServiceAccountsException},
+		},
+		{
+			Summary:    "Customers may not create NetworkPolicies which may impact the default ingress in openshift-ingress.",
+			Exceptions: []string{utils.PrivilegedServiceAccountsException},
+		},
+	}
+}
+
 // SyncSetLabelSelector returns the label selector to use in the SyncSet.
 // Return utils.DefaultLabelSelector() to stick with the default
 func (s *networkpoliciesruleWebhook) SyncSetLabelSelector() metav1.LabelSelector {
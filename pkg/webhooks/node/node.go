@@ -2,10 +2,11 @@ package node
 
 import (
 	"net/http"
-	"slices"
 	"strings"
 
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
 	"github.com/openshift/managed-cluster-validating-webhooks/pkg/localmetrics"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
 	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionregv1 "k8s.io/api/admissionregistration/v1"
@@ -26,10 +27,8 @@ const (
 )
 
 var (
-	adminGroups = []string{"system:serviceaccounts:openshift-backplane-srep"}
-	adminUsers  = []string{"backplane-cluster-admin"}
-	scope       = admissionregv1.AllScopes
-	rules       = []admissionregv1.RuleWithOperations{
+	scope = admissionregv1.AllScopes
+	rules = []admissionregv1.RuleWithOperations{
 		{
 			Operations: []admissionregv1.OperationType{
 				admissionregv1.OperationType(admissionv1.Create),
@@ -56,6 +55,20 @@ func (s *NodeWebhook) Doc() string {
 	return docString
 }
 
+// RuleDocs implements Webhook interface
+func (s *NodeWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers may not delete nodes.",
+			Exceptions: []string{utils.SystemUsersException, utils.SREException},
+		},
+		{
+			Summary:    "Customers may not modify infra, control plane or master nodes.",
+			Exceptions: []string{utils.SystemUsersException, utils.SREException},
+		},
+	}
+}
+
 // ObjectSelector implements Webhook interface
 func (s *NodeWebhook) ObjectSelector() *metav1.LabelSelector { return nil }
 
@@ -106,7 +119,7 @@ func (s *NodeWebhook) authorized(request admissionctl.Request) admissionctl.Resp
 		// This could highlight a significant problem with RBAC since an
 		// unauthenticated user should have no permissions.
 		log.Info("system:unauthenticated made a webhook request. Check RBAC rules", "request", request.AdmissionRequest)
-		ret = admissionctl.Denied("Unauthenticated")
+		ret = response.Denied(response.Unauthenticated, "Unauthenticated")
 		ret.UID = request.AdmissionRequest.UID
 		return ret
 	}
@@ -120,20 +133,13 @@ func (s *NodeWebhook) authorized(request admissionctl.Request) admissionctl.Resp
 		ret.UID = request.AdmissionRequest.UID
 		return ret
 	}
-	if slices.Contains(adminUsers, request.AdmissionRequest.UserInfo.Username) {
-		ret = admissionctl.Allowed("Specified admin users are allowed")
+	if identity.IsSRE(request.UserInfo) {
+		ret = admissionctl.Allowed("SRE are allowed")
 		ret.UID = request.AdmissionRequest.UID
 		return ret
 	}
-	for _, userGroup := range request.UserInfo.Groups {
-		if slices.Contains(adminGroups, userGroup) {
-			ret = admissionctl.Allowed("Members of admin groups are allowed")
-			ret.UID = request.AdmissionRequest.UID
-			return ret
-		}
-	}
 
-	//Checks for non-adminGroups non-ceeGroup non-adminGroups users
+	//Checks for non-SRE non-ceeGroup users
 	if request.Kind.Kind == "Node" {
 		node := corev1.Node{}
 		decoder := admission.NewDecoder(s.scheme)
@@ -155,7 +161,7 @@ func (s *NodeWebhook) authorized(request admissionctl.Request) admissionctl.Resp
 
 		if request.Operation == admissionv1.Delete {
 			localmetrics.IncrementNodeWebhookBlockedRequest(request.UserInfo.Username)
-			ret = admissionctl.Denied("Prevented from deleting nodes. This is in an effort to prevent harmful actions that may cause unintended consequences or affect the stability of the cluster. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support")
+			ret = response.Denied(response.ManagedNode, "Prevented from deleting nodes. This is in an effort to prevent harmful actions that may cause unintended consequences or affect the stability of the cluster. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support")
 			ret.UID = request.AdmissionRequest.UID
 			return ret
 		}
@@ -163,7 +169,7 @@ func (s *NodeWebhook) authorized(request admissionctl.Request) admissionctl.Resp
 		if _, ok := node.Labels["node-role.kubernetes.io/infra"]; ok {
 			localmetrics.IncrementNodeWebhookBlockedRequest(request.UserInfo.Username)
 			log.Info("Denying access to infra node")
-			ret = admissionctl.Denied("Prevented from modifying Red Hat managed infra nodes. This is in an effort to prevent harmful actions that may cause unintended consequences or affect the stability of the cluster. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support")
+			ret = response.Denied(response.ManagedNode, "Prevented from modifying Red Hat managed infra nodes. This is in an effort to prevent harmful actions that may cause unintended consequences or affect the stability of the cluster. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support")
 			ret.UID = request.AdmissionRequest.UID
 			return ret
 		}
@@ -171,7 +177,7 @@ func (s *NodeWebhook) authorized(request admissionctl.Request) admissionctl.Resp
 		if _, ok := node.Labels["node-role.kubernetes.io/control-plane"]; ok {
 			localmetrics.IncrementNodeWebhookBlockedRequest(request.UserInfo.Username)
 			log.Info("Denying access to control plane node")
-			ret = admissionctl.Denied("Prevented from modifying Red Hat managed control plane nodes. This is in an effort to prevent harmful actions that may cause unintended consequences or affect the stability of the cluster. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support")
+			ret = response.Denied(response.ManagedNode, "Prevented from modifying Red Hat managed control plane nodes. This is in an effort to prevent harmful actions that may cause unintended consequences or affect the stability of the cluster. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support")
 			ret.UID = request.AdmissionRequest.UID
 			return ret
 		}
@@ -179,7 +185,7 @@ func (s *NodeWebhook) authorized(request admissionctl.Request) admissionctl.Resp
 		if _, ok := node.Labels["node-role.kubernetes.io/master"]; ok {
 			localmetrics.IncrementNodeWebhookBlockedRequest(request.UserInfo.Username)
 			log.Info("Denying access to control plane node")
-			ret = admissionctl.Denied("Prevented from modifying Red Hat managed master nodes. This is in an effort to prevent harmful actions that may cause unintended consequences or affect the stability of the cluster. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support")
+			ret = response.Denied(response.ManagedNode, "Prevented from modifying Red Hat managed master nodes. This is in an effort to prevent harmful actions that may cause unintended consequences or affect the stability of the cluster. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support")
 			ret.UID = request.AdmissionRequest.UID
 			return ret
 		}
@@ -191,7 +197,7 @@ func (s *NodeWebhook) authorized(request admissionctl.Request) admissionctl.Resp
 
 	// Should never get here
 	log.Info("Unexpectedly denying access", "request", request.AdmissionRequest)
-	ret = admissionctl.Denied("Prevented from accessing Red Hat managed resources. This is in an effort to prevent harmful actions that may cause unintended consequences or affect the stability of the cluster. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support")
+	ret = response.Denied(response.ManagedResource, "Prevented from accessing Red Hat managed resources. This is in an effort to prevent harmful actions that may cause unintended consequences or affect the stability of the cluster. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support")
 	ret.UID = request.AdmissionRequest.UID
 	return ret
 }
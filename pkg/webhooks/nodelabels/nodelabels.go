@@ -0,0 +1,220 @@
+package nodelabels
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "nodelabels-validation"
+	docString   string = `Managed OpenShift customers may not remove or change the labels and taints of Nodes whose keys start with %s, which place the managed components of the cluster. Adding them, and changing other labels and taints, is allowed.`
+
+	// protectedPrefix is the prefix of the keys of the labels and taints
+	// which mark the roles of nodes
+	protectedPrefix string = "node-role.kubernetes.io/"
+
+	// nodeUserPrefix is the prefix of the usernames of kubelets
+	nodeUserPrefix string = "system:node:"
+	nodesGroup     string = "system:nodes"
+)
+
+var (
+	scope = admissionregv1.ClusterScope
+	rules = []admissionregv1.RuleWithOperations{
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Update},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{""},
+				APIVersions: []string{"*"},
+				Resources:   []string{"nodes"},
+				Scope:       &scope,
+			},
+		},
+	}
+	log = logf.Log.WithName(WebhookName)
+)
+
+// NodeLabelsWebhook protects the labels and taints of nodes which place the
+// managed components of the cluster
+type NodeLabelsWebhook struct {
+	decoder admissionctl.Decoder
+}
+
+// NewWebhook creates a new webhook
+func NewWebhook() *NodeLabelsWebhook {
+	decoder, err := k8sutil.SharedDecoder()
+	if err != nil {
+		log.Error(err, "Fail getting the shared decoder for NodeLabelsWebhook")
+		os.Exit(1)
+	}
+
+	return &NodeLabelsWebhook{
+		decoder: decoder,
+	}
+}
+
+// Authorized implements Webhook interface
+func (s *NodeLabelsWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.authorized(request)
+}
+
+func (s *NodeLabelsWebhook) authorized(request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if identity.IsAdmin(request.UserInfo) {
+		ret = admissionctl.Allowed("Admins and managed service accounts may change the labels and taints of nodes")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	if isKubelet(request.UserInfo.Username, request.UserInfo.Groups, request.Name) {
+		ret = admissionctl.Allowed("Kubelets may update their own node")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	old := &corev1.Node{}
+	if err := s.decoder.DecodeRaw(request.OldObject, old); err != nil {
+		log.Error(err, "Couldn't decode the old Node from the request")
+		ret = admissionctl.Errored(http.StatusBadRequest, err)
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	node := &corev1.Node{}
+	if err := s.decoder.DecodeRaw(request.Object, node); err != nil {
+		log.Error(err, "Couldn't decode the Node from the request")
+		ret = admissionctl.Errored(http.StatusBadRequest, err)
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	changed := append(changedLabels(old, node), changedTaints(old, node)...)
+	if len(changed) > 0 {
+		log.Info("Denying change of protected node labels and taints", "node", request.Name, "user", request.UserInfo.Username, "changed", changed)
+		ret = response.Denied(response.ManagedNodeLabel, fmt.Sprintf("Prevented from removing or changing %s on node %s, as they place the managed components of the cluster. To control where your own workloads run, add your own labels and taints, eg through the machine pool of the node. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", strings.Join(changed, ", "), request.Name))
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	ret = admissionctl.Allowed("Protected labels and taints are unchanged")
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// isKubelet returns true if the user is the kubelet of the named node
+func isKubelet(username string, groups []string, name string) bool {
+	return username == nodeUserPrefix+name && slices.Contains(groups, nodesGroup)
+}
+
+// changedLabels describes the protected labels of the old node which the new
+// one removes or changes
+func changedLabels(old, node *corev1.Node) []string {
+	changed := []string{}
+	for key, value := range old.Labels {
+		if !strings.HasPrefix(key, protectedPrefix) {
+			continue
+		}
+		if newValue, ok := node.Labels[key]; !ok || newValue != value {
+			changed = append(changed, fmt.Sprintf("label %s", key))
+		}
+	}
+	slices.Sort(changed)
+	return changed
+}
+
+// changedTaints describes the protected taints of the old node which the new
+// one removes or changes
+func changedTaints(old, node *corev1.Node) []string {
+	changed := []string{}
+	for _, taint := range old.Spec.Taints {
+		if !strings.HasPrefix(taint.Key, protectedPrefix) {
+			continue
+		}
+		kept := slices.ContainsFunc(node.Spec.Taints, func(t corev1.Taint) bool {
+			return t.Key == taint.Key && t.Value == taint.Value && t.Effect == taint.Effect
+		})
+		if !kept {
+			changed = append(changed, fmt.Sprintf("taint %s:%s", taint.Key, taint.Effect))
+		}
+	}
+	slices.Sort(changed)
+	return changed
+}
+
+// GetURI implements Webhook interface
+func (s *NodeLabelsWebhook) GetURI() string { return "/" + WebhookName }
+
+// Validate implements Webhook interface
+func (s *NodeLabelsWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	valid = valid && (request.Kind.Kind == "Node")
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *NodeLabelsWebhook) Name() string { return WebhookName }
+
+// FailurePolicy implements Webhook interface
+func (s *NodeLabelsWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *NodeLabelsWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *NodeLabelsWebhook) Rules() []admissionregv1.RuleWithOperations { return rules }
+
+// ObjectSelector implements Webhook interface
+func (s *NodeLabelsWebhook) ObjectSelector() *metav1.LabelSelector { return nil }
+
+// SideEffects implements Webhook interface
+func (s *NodeLabelsWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *NodeLabelsWebhook) TimeoutSeconds() int32 { return 2 }
+
+// Doc implements Webhook interface
+func (s *NodeLabelsWebhook) Doc() string {
+	return fmt.Sprintf(docString, protectedPrefix)
+}
+
+// RuleDocs implements Webhook interface
+func (s *NodeLabelsWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers may not remove or change the labels and taints of nodes whose keys start with node-role.kubernetes.io/.",
+			Exceptions: []string{utils.AdminsException, "Kubelets updating their own node"},
+		},
+	}
+}
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+func (s *NodeLabelsWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// ClassicEnabled implements Webhook interface
+func (s *NodeLabelsWebhook) ClassicEnabled() bool { return true }
+
+// HypershiftEnabled implements Webhook interface. The labels and taints of
+// the nodes of hosted clusters are set by their NodePools.
+func (s *NodeLabelsWebhook) HypershiftEnabled() bool { return false }
@@ -0,0 +1,140 @@
+package nodelabels
+
+import (
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+)
+
+const (
+	infraLabel = "node-role.kubernetes.io/infra"
+	nodeName   = "ip-10-0-1-1.ec2.internal"
+)
+
+var infraTaint = corev1.Taint{Key: infraLabel, Effect: corev1.TaintEffectNoSchedule}
+
+func newNode(labels map[string]string, taints ...corev1.Taint) *corev1.Node {
+	return &corev1.Node{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Node"},
+		ObjectMeta: metav1.ObjectMeta{Name: nodeName, Labels: labels},
+		Spec:       corev1.NodeSpec{Taints: taints},
+	}
+}
+
+func TestAuthorized(t *testing.T) {
+	infra := map[string]string{infraLabel: "", "kubernetes.io/os": "linux"}
+	tests := []struct {
+		name     string
+		username string
+		groups   []string
+		oldObj   *corev1.Node
+		obj      *corev1.Node
+		allowed  bool
+		changed  []string
+	}{
+		{
+			name:     "customer removes infra label",
+			username: "customer",
+			oldObj:   newNode(infra, infraTaint),
+			obj:      newNode(map[string]string{"kubernetes.io/os": "linux"}, infraTaint),
+			allowed:  false,
+			changed:  []string{"label " + infraLabel},
+		},
+		{
+			name:     "customer changes infra label",
+			username: "customer",
+			oldObj:   newNode(infra, infraTaint),
+			obj:      newNode(map[string]string{infraLabel: "no", "kubernetes.io/os": "linux"}, infraTaint),
+			allowed:  false,
+		},
+		{
+			name:     "customer removes infra taint",
+			username: "customer",
+			oldObj:   newNode(infra, infraTaint),
+			obj:      newNode(infra),
+			allowed:  false,
+			changed:  []string{"taint " + infraLabel + ":NoSchedule"},
+		},
+		{
+			name:     "customer changes effect of infra taint",
+			username: "customer",
+			oldObj:   newNode(infra, infraTaint),
+			obj:      newNode(infra, corev1.Taint{Key: infraLabel, Effect: corev1.TaintEffectPreferNoSchedule}),
+			allowed:  false,
+		},
+		{
+			name:     "customer adds own label and taint",
+			username: "customer",
+			oldObj:   newNode(infra, infraTaint),
+			obj: newNode(map[string]string{infraLabel: "", "kubernetes.io/os": "linux", "team": "a"},
+				infraTaint, corev1.Taint{Key: "team", Value: "a", Effect: corev1.TaintEffectNoSchedule}),
+			allowed: true,
+		},
+		{
+			name:     "customer removes own label from worker",
+			username: "customer",
+			oldObj:   newNode(map[string]string{"node-role.kubernetes.io/worker": "", "team": "a"}),
+			obj:      newNode(map[string]string{"node-role.kubernetes.io/worker": ""}),
+			allowed:  true,
+		},
+		{
+			name:     "SRE removes infra taint",
+			username: "backplane-cluster-admin",
+			oldObj:   newNode(infra, infraTaint),
+			obj:      newNode(infra),
+			allowed:  true,
+		},
+		{
+			name:     "machine config daemon removes infra taint",
+			username: "system:serviceaccount:openshift-machine-config-operator:machine-config-daemon",
+			groups:   []string{"system:serviceaccounts", "system:serviceaccounts:openshift-machine-config-operator"},
+			oldObj:   newNode(infra, infraTaint),
+			obj:      newNode(infra),
+			allowed:  true,
+		},
+		{
+			name:     "kubelet updates own node",
+			username: "system:node:" + nodeName,
+			groups:   []string{"system:nodes", "system:authenticated"},
+			oldObj:   newNode(infra, infraTaint),
+			obj:      newNode(map[string]string{"kubernetes.io/os": "linux"}),
+			allowed:  true,
+		},
+		{
+			name:     "kubelet updates other node",
+			username: "system:node:ip-10-0-2-2.ec2.internal",
+			groups:   []string{"system:nodes", "system:authenticated"},
+			oldObj:   newNode(infra, infraTaint),
+			obj:      newNode(infra),
+			allowed:  false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := NewWebhook()
+			request := testutils.NewRequest(t, metav1.GroupVersionKind{Version: "v1", Kind: "Node"}, admissionv1.Update, authenticationv1.UserInfo{Username: test.username, Groups: test.groups}, "", test.oldObj.Name, test.obj, test.oldObj)
+			if !s.Validate(request) {
+				t.Fatalf("Expected the request to be valid")
+			}
+			response := s.Authorized(request)
+			if response.Allowed != test.allowed {
+				t.Errorf("Expected allowed %t, got %t: %s", test.allowed, response.Allowed, response.Result.Message)
+			}
+			for _, changed := range test.changed {
+				if !strings.Contains(response.Result.Message, changed) {
+					t.Errorf("Expected the message to name %s, got %s", changed, response.Result.Message)
+				}
+			}
+			if response.UID != "test-uid" {
+				t.Errorf("Expected the response UID to be set")
+			}
+		})
+	}
+}
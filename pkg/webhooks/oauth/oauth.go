@@ -0,0 +1,213 @@
+package oauth
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	configv1 "github.com/openshift/api/config/v1"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "oauth-validation"
+	docString   string = `Managed OpenShift customers, including cluster admins, may not change the identity providers or templates of the cluster OAuth config, which OpenShift Cluster Manager manages, nor delete it. They may also not create or change the %s/%s Secret, so the kubeadmin user stays removed.`
+
+	// kubeadminNamespace and kubeadminSecret are the Secret holding the
+	// kubeadmin password. Removing it disables the kubeadmin user.
+	kubeadminNamespace string = "kube-system"
+	kubeadminSecret    string = "kubeadmin"
+)
+
+var (
+	clusterScope   = admissionregv1.ClusterScope
+	namespaceScope = admissionregv1.NamespacedScope
+	rules          = []admissionregv1.RuleWithOperations{
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Update, admissionregv1.Delete},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{"config.openshift.io"},
+				APIVersions: []string{"*"},
+				Resources:   []string{"oauths"},
+				Scope:       &clusterScope,
+			},
+		},
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Create, admissionregv1.Update},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{""},
+				APIVersions: []string{"*"},
+				Resources:   []string{"secrets"},
+				Scope:       &namespaceScope,
+			},
+		},
+	}
+	log = logf.Log.WithName(WebhookName)
+)
+
+// OAuthWebhook protects the identity providers OpenShift Cluster Manager
+// manages and the removal of the kubeadmin user
+type OAuthWebhook struct {
+	decoder admissionctl.Decoder
+}
+
+// NewWebhook creates a new webhook
+func NewWebhook() *OAuthWebhook {
+	decoder, err := k8sutil.SharedDecoder()
+	if err != nil {
+		log.Error(err, "Fail getting the shared decoder for OAuthWebhook")
+		os.Exit(1)
+	}
+
+	return &OAuthWebhook{
+		decoder: decoder,
+	}
+}
+
+// Authorized implements Webhook interface
+func (s *OAuthWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.authorized(request)
+}
+
+func (s *OAuthWebhook) authorized(request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if request.Kind.Kind == "Secret" && (request.Namespace != kubeadminNamespace || request.Name != kubeadminSecret) {
+		ret = admissionctl.Allowed("Only the kubeadmin Secret is protected")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	if identity.IsManager(request.UserInfo) {
+		ret = admissionctl.Allowed("SRE, managed service accounts and OpenShift Cluster Manager may change the OAuth config")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	if request.Kind.Kind == "Secret" {
+		log.Info("Denying change of kubeadmin Secret", "operation", request.Operation, "user", request.UserInfo.Username)
+		ret = response.Denied(response.KubeadminRemoved, fmt.Sprintf("Prevented from changing the %s/%s Secret. The kubeadmin user is removed from managed clusters; log in through an identity provider configured in OpenShift Cluster Manager instead. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", kubeadminNamespace, kubeadminSecret))
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	if request.Operation == admissionv1.Delete {
+		log.Info("Denying deletion of OAuth config", "name", request.Name, "user", request.UserInfo.Username)
+		ret = response.Denied(response.ManagedOAuthConfig, fmt.Sprintf("Prevented from deleting the OAuth config %s, which OpenShift Cluster Manager manages. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", request.Name))
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	old := &configv1.OAuth{}
+	if err := s.decoder.DecodeRaw(request.OldObject, old); err != nil {
+		log.Error(err, "Couldn't decode the old OAuth config from the request")
+		ret = admissionctl.Errored(http.StatusBadRequest, err)
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	oauth := &configv1.OAuth{}
+	if err := s.decoder.Decode(request, oauth); err != nil {
+		log.Error(err, "Couldn't decode the OAuth config from the request")
+		ret = admissionctl.Errored(http.StatusBadRequest, err)
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	var changed string
+	switch {
+	case !equality.Semantic.DeepEqual(old.Spec.IdentityProviders, oauth.Spec.IdentityProviders):
+		changed = "identity providers"
+	case !equality.Semantic.DeepEqual(old.Spec.Templates, oauth.Spec.Templates):
+		changed = "templates"
+	}
+	if changed == "" {
+		ret = admissionctl.Allowed("The identity providers and templates are unchanged")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	log.Info("Denying change of managed OAuth config", "name", request.Name, "changed", changed, "user", request.UserInfo.Username)
+	ret = response.Denied(response.ManagedOAuthConfig, fmt.Sprintf("Prevented from changing the %s of the OAuth config %s, which OpenShift Cluster Manager manages. Configure identity providers in OpenShift Cluster Manager instead. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", changed, request.Name))
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// GetURI implements Webhook interface
+func (s *OAuthWebhook) GetURI() string { return "/" + WebhookName }
+
+// Validate implements Webhook interface
+func (s *OAuthWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	valid = valid && (request.Kind.Kind == "OAuth" || request.Kind.Kind == "Secret")
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *OAuthWebhook) Name() string { return WebhookName }
+
+// FailurePolicy implements Webhook interface
+func (s *OAuthWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *OAuthWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *OAuthWebhook) Rules() []admissionregv1.RuleWithOperations { return rules }
+
+// ObjectSelector implements Webhook interface
+func (s *OAuthWebhook) ObjectSelector() *metav1.LabelSelector { return nil }
+
+// SideEffects implements Webhook interface
+func (s *OAuthWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *OAuthWebhook) TimeoutSeconds() int32 { return 2 }
+
+// Doc implements Webhook interface
+func (s *OAuthWebhook) Doc() string {
+	return fmt.Sprintf(docString, kubeadminNamespace, kubeadminSecret)
+}
+
+// RuleDocs implements Webhook interface
+func (s *OAuthWebhook) RuleDocs() []utils.RuleDoc {
+	exceptions := []string{utils.SREException, utils.PrivilegedServiceAccountsException, "OpenShift Cluster Manager, through Hive as system:admin"}
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers, including cluster admins, may not change the identity providers or templates of the cluster OAuth config, nor delete it.",
+			Exceptions: exceptions,
+		},
+		{
+			Summary:    "Customers, including cluster admins, may not create or change the kube-system/kubeadmin Secret.",
+			Exceptions: exceptions,
+		},
+	}
+}
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+func (s *OAuthWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// ClassicEnabled implements Webhook interface
+func (s *OAuthWebhook) ClassicEnabled() bool { return true }
+
+// HypershiftEnabled implements Webhook interface. Hosted clusters' OAuth is
+// configured through their HostedCluster, outside the cluster.
+func (s *OAuthWebhook) HypershiftEnabled() bool { return false }
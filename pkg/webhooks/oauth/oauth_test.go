@@ -0,0 +1,105 @@
+package oauth
+
+import (
+	"testing"
+
+	configv1 "github.com/openshift/api/config/v1"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+)
+
+var (
+	clusterAdmin = authenticationv1.UserInfo{Username: "kube:admin", Groups: []string{"system:cluster-admins", "system:authenticated"}}
+	customer     = authenticationv1.UserInfo{Username: "customer", Groups: []string{"dedicated-admins", "system:authenticated"}}
+	sre          = authenticationv1.UserInfo{Username: "backplane-cluster-admin"}
+	hive         = authenticationv1.UserInfo{Username: "system:admin"}
+	authOperator = authenticationv1.UserInfo{Username: "system:serviceaccount:openshift-authentication-operator:authentication-operator", Groups: []string{"system:serviceaccounts", "system:serviceaccounts:openshift-authentication-operator"}}
+)
+
+func newOAuth(idps ...string) *configv1.OAuth {
+	oauth := &configv1.OAuth{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "config.openshift.io/v1", Kind: "OAuth"},
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+	}
+	for _, idp := range idps {
+		oauth.Spec.IdentityProviders = append(oauth.Spec.IdentityProviders, configv1.IdentityProvider{
+			Name:                   idp,
+			MappingMethod:          configv1.MappingMethodClaim,
+			IdentityProviderConfig: configv1.IdentityProviderConfig{Type: configv1.IdentityProviderTypeGitHub},
+		})
+	}
+	return oauth
+}
+
+func newSecret(namespace, name string) *corev1.Secret {
+	return &corev1.Secret{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Data:       map[string][]byte{"kubeadmin": []byte("hash")},
+	}
+}
+
+func newRequest(t *testing.T, user authenticationv1.UserInfo, operation admissionv1.Operation, oldObj, obj runtime.Object) admissionctl.Request {
+	t.Helper()
+	ref := oldObj
+	if ref == nil {
+		ref = obj
+	}
+	meta := ref.(metav1.Object)
+	kind := ref.GetObjectKind().GroupVersionKind()
+	gvk := metav1.GroupVersionKind{Group: kind.Group, Version: kind.Version, Kind: kind.Kind}
+	return testutils.NewRequest(t, gvk, operation, user, meta.GetNamespace(), meta.GetName(), obj, oldObj)
+}
+
+func TestAuthorized(t *testing.T) {
+	withTemplates := newOAuth("github")
+	withTemplates.Spec.Templates.Login = configv1.SecretNameReference{Name: "custom-login"}
+	relabelled := newOAuth("github")
+	relabelled.Labels = map[string]string{"team": "platform"}
+
+	tests := []struct {
+		name      string
+		user      authenticationv1.UserInfo
+		operation admissionv1.Operation
+		oldObj    runtime.Object
+		obj       runtime.Object
+		allowed   bool
+	}{
+		{"cluster admin adds identity provider", clusterAdmin, admissionv1.Update, newOAuth("github"), newOAuth("github", "backdoor"), false},
+		{"customer removes identity provider", customer, admissionv1.Update, newOAuth("github"), newOAuth(), false},
+		{"customer changes templates", customer, admissionv1.Update, newOAuth("github"), withTemplates, false},
+		{"customer labels oauth config", customer, admissionv1.Update, newOAuth("github"), relabelled, true},
+		{"cluster admin deletes oauth config", clusterAdmin, admissionv1.Delete, newOAuth("github"), nil, false},
+		{"hive adds identity provider", hive, admissionv1.Update, newOAuth("github"), newOAuth("github", "ldap"), true},
+		{"sre changes identity providers", sre, admissionv1.Update, newOAuth("github"), newOAuth(), true},
+		{"authentication operator changes templates", authOperator, admissionv1.Update, newOAuth("github"), withTemplates, true},
+		{"cluster admin recreates kubeadmin", clusterAdmin, admissionv1.Create, nil, newSecret("kube-system", "kubeadmin"), false},
+		{"customer changes kubeadmin", customer, admissionv1.Update, newSecret("kube-system", "kubeadmin"), newSecret("kube-system", "kubeadmin"), false},
+		{"sre creates kubeadmin", sre, admissionv1.Create, nil, newSecret("kube-system", "kubeadmin"), true},
+		{"customer creates own secret", customer, admissionv1.Create, nil, newSecret("customer", "kubeadmin"), true},
+		{"customer creates other kube-system secret", clusterAdmin, admissionv1.Create, nil, newSecret("kube-system", "other"), true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := NewWebhook()
+			request := newRequest(t, test.user, test.operation, test.oldObj, test.obj)
+			if !s.Validate(request) {
+				t.Fatalf("Expected the request to be valid")
+			}
+			response := s.Authorized(request)
+			if response.Allowed != test.allowed {
+				t.Errorf("Expected allowed %t, got %t: %s", test.allowed, response.Allowed, response.Result.Message)
+			}
+			if response.UID != "test-uid" {
+				t.Errorf("Expected the response UID to be set")
+			}
+		})
+	}
+}
@@ -0,0 +1,396 @@
+package objectquota
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/config"
+	hookconfig "github.com/openshift/managed-cluster-validating-webhooks/pkg/config"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "objectquota-validation"
+	docString   string = `Managed OpenShift customers may only create as many Secrets, ConfigMaps and CronJobs per namespace as the quotas SRE set in the %s ConfigMap allow (%s by default), as every object is stored in etcd and unbounded numbers of them, usually created programmatically, degrade the whole cluster. The policy is opt-in, and only enforced on clusters which enable the webhook.`
+
+	// QuotaConfigMapName is the ConfigMap in the webhook namespace holding
+	// the object count quotas set by SRE
+	QuotaConfigMapName string = "object-count-limits"
+
+	// QuotaExemptLabel is set by SRE on the namespaces which may have any
+	// number of objects. Customers can't set it, as namespace-validation
+	// protects it.
+	QuotaExemptLabel string = "managed.openshift.io/object-count-quota-exempt"
+)
+
+// countedResource is a kind whose objects are counted
+type countedResource struct {
+	kind schema.GroupVersionKind
+	// key is the ConfigMap key holding how many objects of the kind each
+	// namespace may have
+	key string
+	// defaultMax is used unless the ConfigMap sets a quota
+	defaultMax int
+}
+
+var (
+	scope = admissionregv1.NamespacedScope
+	rules = []admissionregv1.RuleWithOperations{
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Create},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{""},
+				APIVersions: []string{"v1"},
+				Resources:   []string{"secrets", "configmaps"},
+				Scope:       &scope,
+			},
+		},
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Create},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{"batch"},
+				APIVersions: []string{"v1"},
+				Resources:   []string{"cronjobs"},
+				Scope:       &scope,
+			},
+		},
+	}
+	log = logf.Log.WithName(WebhookName)
+
+	// countedResources are the counted kinds, by resource. They're counted
+	// with metadata-only Lists, so the data of the Secrets is never read.
+	countedResources = map[string]countedResource{
+		"configmaps": {kind: schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, key: "maxConfigMapsPerNamespace", defaultMax: 1000},
+		"cronjobs":   {kind: schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "CronJob"}, key: "maxCronJobsPerNamespace", defaultMax: 100},
+		"secrets":    {kind: schema.GroupVersionKind{Version: "v1", Kind: "Secret"}, key: "maxSecretsPerNamespace", defaultMax: 1000},
+	}
+
+	// quotaTTL is how long the quotas read from the ConfigMap are reused
+	quotaTTL = 30 * time.Second
+	// quotas is shared by every ObjectQuotaWebhook because the dispatcher
+	// builds a new webhook for each request
+	quotas = &quotaCache{}
+)
+
+// quotaCache holds the most recently read quotas, by resource
+type quotaCache struct {
+	mu      sync.Mutex
+	limits  map[string]int
+	expires time.Time
+}
+
+// get returns the cached quotas and whether they are still fresh
+func (c *quotaCache) get(now time.Time) (map[string]int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Before(c.expires) {
+		return c.limits, true
+	}
+	return nil, false
+}
+
+// set stores the quotas, valid for quotaTTL from now
+func (c *quotaCache) set(limits map[string]int, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.limits = limits
+	c.expires = now.Add(quotaTTL)
+}
+
+// ObjectQuotaWebhook limits how many objects of high-cardinality kinds each
+// customer namespace may have. Objects are counted with a List limited to the
+// quota, so a check never reads more than the quota's worth of metadata, and
+// concurrent creations may let a namespace briefly exceed its quota.
+type ObjectQuotaWebhook struct {
+	s          *runtime.Scheme
+	kubeClient client.Client
+}
+
+// NewWebhook creates a new webhook
+func NewWebhook() *ObjectQuotaWebhook {
+	scheme, err := k8sutil.SharedScheme()
+	if err != nil {
+		log.Error(err, "Fail getting the shared scheme for ObjectQuotaWebhook")
+		os.Exit(1)
+	}
+
+	return &ObjectQuotaWebhook{
+		s: scheme,
+	}
+}
+
+// InjectClient implements ClientWebhook interface
+func (s *ObjectQuotaWebhook) InjectClient(c client.Client) {
+	s.kubeClient = c
+}
+
+// CachedObjects implements ClientWebhook interface. Namespaces are read for
+// their exemption label. The counted objects aren't cached, as caching every
+// Secret and ConfigMap of the cluster would cost more than the limited Lists
+// counting them.
+func (s *ObjectQuotaWebhook) CachedObjects() []client.Object {
+	return []client.Object{&corev1.Namespace{}}
+}
+
+// Authorized implements Webhook interface
+func (s *ObjectQuotaWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.AuthorizedContext(context.Background(), request)
+}
+
+// AuthorizedContext implements ContextWebhook interface
+func (s *ObjectQuotaWebhook) AuthorizedContext(ctx context.Context, request admissionctl.Request) admissionctl.Response {
+	return s.authorized(ctx, request)
+}
+
+// authorized checks the namespace has room in its quota for another object of
+// the kind. Errors fail open, as the webhook's FailurePolicy does.
+func (s *ObjectQuotaWebhook) authorized(ctx context.Context, request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if s.ExcludedNamespaces().Excludes(request.Namespace) {
+		ret = admissionctl.Allowed("Object counts are not limited in managed namespaces")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	if identity.IsSRE(request.UserInfo) || identity.IsPrivilegedServiceAccount(request.UserInfo) {
+		ret = admissionctl.Allowed("SRE and managed service accounts may create any number of objects")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	counted, ok := countedResources[request.Resource.Resource]
+	if !ok {
+		ret = admissionctl.Allowed("Objects of the kind are not counted")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	if err := s.ensureClient(); err != nil {
+		log.Error(err, "Failed to create a client to count objects")
+		ret = admissionctl.Allowed("Unable to count objects")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	ns := &corev1.Namespace{}
+	if err := s.kubeClient.Get(ctx, client.ObjectKey{Name: request.Namespace}, ns); err != nil {
+		log.Error(err, "Failed to check namespace for object quota exemption", "namespace", request.Namespace)
+		ret = admissionctl.Allowed("Unable to check namespace for object quota exemption")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	if ns.Labels[QuotaExemptLabel] == "true" {
+		ret = admissionctl.Allowed("Namespace is exempt from the object quotas")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	limit := s.quotas(ctx)[request.Resource.Resource]
+	count := 0
+	if limit > 0 {
+		// Only the metadata of up to limit objects is listed, which is enough
+		// to tell the namespace is full
+		objects := &metav1.PartialObjectMetadataList{}
+		objects.SetGroupVersionKind(counted.kind.GroupVersion().WithKind(counted.kind.Kind + "List"))
+		if err := s.kubeClient.List(ctx, objects, client.InNamespace(request.Namespace), client.Limit(int64(limit))); err != nil {
+			log.Error(err, "Failed to count objects", "namespace", request.Namespace, "resource", request.Resource.Resource)
+			ret = admissionctl.Allowed("Unable to count objects")
+			ret.UID = request.AdmissionRequest.UID
+			return ret
+		}
+		count = len(objects.Items)
+	}
+	if count < limit {
+		ret = admissionctl.Allowed("Namespace is within its object quota")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	kind := strings.ToLower(counted.kind.Kind)
+	log.Info("Denying object over the namespace's quota", "namespace", request.Namespace, "resource", request.Resource.Resource, "name", request.Name, "user", request.UserInfo.Username, "quota", limit)
+	ret = response.Denied(response.ObjectCountQuota, fmt.Sprintf("Prevented from creating %s %s in namespace %s, which already has the %d %ss allowed per namespace. Delete unused %ss, or spread them across namespaces. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", kind, request.Name, request.Namespace, limit, counted.kind.Kind, counted.kind.Kind))
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// quotas returns the quotas, reading them from the ConfigMap when they're
+// older than quotaTTL. The defaults are used when the ConfigMap can't be read
+// or is invalid.
+func (s *ObjectQuotaWebhook) quotas(ctx context.Context) map[string]int {
+	now := time.Now()
+	if limits, fresh := quotas.get(now); fresh {
+		return limits
+	}
+
+	limits := defaultQuotas()
+	cm := &corev1.ConfigMap{}
+	err := s.kubeClient.Get(ctx, client.ObjectKey{Namespace: config.OperatorNamespace, Name: QuotaConfigMapName}, cm)
+	switch {
+	case err != nil && !apierrors.IsNotFound(err):
+		log.Error(err, "Failed to read the object quotas, using the defaults")
+	case err == nil:
+		if parsed, err := parseQuotas(cm.Data); err != nil {
+			log.Error(err, "Invalid object quotas, using the defaults")
+		} else {
+			limits = parsed
+		}
+	}
+	quotas.set(limits, now)
+	return limits
+}
+
+// defaultQuotas returns the default quota of each counted resource
+func defaultQuotas() map[string]int {
+	limits := map[string]int{}
+	for resource, counted := range countedResources {
+		limits[resource] = counted.defaultMax
+	}
+	return limits
+}
+
+// parseQuotas parses the quotas in the ConfigMap data, keeping the default of
+// the resources it has no key for. A quota of 0 denies every object of the
+// kind.
+func parseQuotas(data map[string]string) (map[string]int, error) {
+	limits := defaultQuotas()
+	for resource, counted := range countedResources {
+		value, ok := data[counted.key]
+		if !ok {
+			continue
+		}
+		parsed, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || parsed < 0 {
+			return nil, fmt.Errorf("invalid %s %q", counted.key, value)
+		}
+		limits[resource] = parsed
+	}
+	return limits, nil
+}
+
+// ensureClient creates a client if none was injected
+func (s *ObjectQuotaWebhook) ensureClient() error {
+	if s.kubeClient != nil {
+		return nil
+	}
+	var err error
+	s.kubeClient, err = k8sutil.KubeClient(s.s)
+	return err
+}
+
+// GetURI implements Webhook interface
+func (s *ObjectQuotaWebhook) GetURI() string {
+	return "/" + WebhookName
+}
+
+// Validate implements Webhook interface
+func (s *ObjectQuotaWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	valid = valid && (request.Operation == admissionv1.Create)
+	valid = valid && (request.Namespace != "")
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *ObjectQuotaWebhook) Name() string {
+	return WebhookName
+}
+
+// FailurePolicy implements Webhook interface
+func (s *ObjectQuotaWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *ObjectQuotaWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *ObjectQuotaWebhook) Rules() []admissionregv1.RuleWithOperations {
+	return rules
+}
+
+// ExcludedNamespaces implements NamespaceExclusionWebhook interface
+func (s *ObjectQuotaWebhook) ExcludedNamespaces() utils.NamespaceExclusion {
+	return hookconfig.ExcludedNamespaces
+}
+
+// OptIn implements OptInWebhook interface. Customers legitimately keeping
+// many objects per namespace would be broken by a default quota, so it's only
+// enforced on the clusters enabling it.
+func (s *ObjectQuotaWebhook) OptIn() bool {
+	return true
+}
+
+// ObjectSelector implements Webhook interface
+func (s *ObjectQuotaWebhook) ObjectSelector() *metav1.LabelSelector {
+	return nil
+}
+
+// SideEffects implements Webhook interface
+func (s *ObjectQuotaWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *ObjectQuotaWebhook) TimeoutSeconds() int32 {
+	return 2
+}
+
+// Doc implements Webhook interface
+func (s *ObjectQuotaWebhook) Doc() string {
+	resources := make([]string, 0, len(countedResources))
+	for resource := range countedResources {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+	defaults := make([]string, 0, len(resources))
+	for _, resource := range resources {
+		defaults = append(defaults, fmt.Sprintf("%s: %d", countedResources[resource].key, countedResources[resource].defaultMax))
+	}
+	return fmt.Sprintf(docString, QuotaConfigMapName, strings.Join(defaults, ", "))
+}
+
+// RuleDocs implements Webhook interface
+func (s *ObjectQuotaWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    fmt.Sprintf("On clusters which enable the webhook, customer namespaces may only have as many Secrets, ConfigMaps and CronJobs as the %s ConfigMap allows.", QuotaConfigMapName),
+			Exceptions: []string{utils.SREException, "Platform and managed service accounts", "Objects in managed namespaces", fmt.Sprintf("Namespaces SRE labelled %s=true", QuotaExemptLabel)},
+		},
+	}
+}
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+func (s *ObjectQuotaWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// ClassicEnabled implements Webhook interface
+func (s *ObjectQuotaWebhook) ClassicEnabled() bool {
+	return true
+}
+
+// HypershiftEnabled implements Webhook interface
+func (s *ObjectQuotaWebhook) HypershiftEnabled() bool {
+	return true
+}
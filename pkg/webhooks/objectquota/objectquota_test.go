@@ -0,0 +1,148 @@
+package objectquota
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/config"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func newRequest(t *testing.T, username, namespace, resource string) admissionctl.Request {
+	group := ""
+	if resource == "cronjobs" {
+		group = "batch"
+	}
+	request := testutils.NewRequest(t, metav1.GroupVersionKind{}, admissionv1.Create, authenticationv1.UserInfo{Username: username}, namespace, "generated", nil, nil)
+	request.Resource = metav1.GroupVersionResource{Group: group, Version: "v1", Resource: resource}
+	return request
+}
+
+func TestAuthorized(t *testing.T) {
+	objects := []client.Object{
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "batch", Labels: map[string]string{QuotaExemptLabel: "true"}}},
+	}
+	for i := 0; i < 3; i++ {
+		objects = append(objects,
+			&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: fmt.Sprintf("token-%d", i)}},
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: fmt.Sprintf("settings-%d", i)}},
+			&batchv1.CronJob{ObjectMeta: metav1.ObjectMeta{Namespace: "batch", Name: fmt.Sprintf("report-%d", i)}},
+		)
+	}
+
+	tests := []struct {
+		name      string
+		username  string
+		namespace string
+		resource  string
+		quota     map[string]string
+		allowed   bool
+		message   string
+	}{
+		{
+			name:      "secret within the default quota",
+			username:  "customer",
+			namespace: "payments",
+			resource:  "secrets",
+			allowed:   true,
+		},
+		{
+			name:      "secret over the configured quota",
+			username:  "customer",
+			namespace: "payments",
+			resource:  "secrets",
+			quota:     map[string]string{"maxSecretsPerNamespace": "3"},
+			allowed:   false,
+			message:   "Prevented from creating secret generated in namespace payments, which already has the 3 Secrets allowed per namespace",
+		},
+		{
+			name:      "configmap within the configured quota",
+			username:  "customer",
+			namespace: "payments",
+			resource:  "configmaps",
+			quota:     map[string]string{"maxSecretsPerNamespace": "3", "maxConfigMapsPerNamespace": "4"},
+			allowed:   true,
+		},
+		{
+			name:      "zero quota",
+			username:  "customer",
+			namespace: "payments",
+			resource:  "cronjobs",
+			quota:     map[string]string{"maxCronJobsPerNamespace": "0"},
+			allowed:   false,
+		},
+		{
+			name:      "invalid quota uses the defaults",
+			username:  "customer",
+			namespace: "payments",
+			resource:  "secrets",
+			quota:     map[string]string{"maxSecretsPerNamespace": "3", "maxCronJobsPerNamespace": "-1"},
+			allowed:   true,
+		},
+		{
+			name:      "quota exempt namespace",
+			username:  "customer",
+			namespace: "batch",
+			resource:  "cronjobs",
+			quota:     map[string]string{"maxCronJobsPerNamespace": "1"},
+			allowed:   true,
+		},
+		{
+			name:      "managed namespace",
+			username:  "customer",
+			namespace: "openshift-config",
+			resource:  "secrets",
+			quota:     map[string]string{"maxSecretsPerNamespace": "0"},
+			allowed:   true,
+		},
+		{
+			name:      "sre",
+			username:  "backplane-cluster-admin",
+			namespace: "payments",
+			resource:  "secrets",
+			quota:     map[string]string{"maxSecretsPerNamespace": "0"},
+			allowed:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			quotas = &quotaCache{}
+			builder := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(objects...)
+			if test.quota != nil {
+				builder = builder.WithObjects(&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Namespace: config.OperatorNamespace, Name: QuotaConfigMapName},
+					Data:       test.quota,
+				})
+			}
+			hook := NewWebhook()
+			hook.InjectClient(builder.Build())
+
+			request := newRequest(t, test.username, test.namespace, test.resource)
+			if !hook.Validate(request) {
+				t.Fatalf("Expected request to be valid")
+			}
+			response := hook.Authorized(request)
+			if response.Allowed != test.allowed {
+				t.Fatalf("Expected allowed %v, got %v: %v", test.allowed, response.Allowed, response.Result)
+			}
+			if response.UID != request.UID {
+				t.Fatalf("Expected UID %s, got %s", request.UID, response.UID)
+			}
+			if test.message != "" && !strings.Contains(response.Result.Message, test.message) {
+				t.Errorf("Expected the message to contain %q, got %s", test.message, response.Result.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package operatorscale
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "operatorscale-validation"
+	docString   string = `Managed OpenShift customers may not scale the Deployments of managed platform operators and components to zero replicas, directly or through their scale subresource, as the cluster's ingress, monitoring, image registry and networking stop working without them. The protected Deployments are: %s.`
+)
+
+var (
+	scope = admissionregv1.NamespacedScope
+	rules = []admissionregv1.RuleWithOperations{
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Update},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{"apps"},
+				APIVersions: []string{"v1"},
+				Resources:   []string{"deployments", "deployments/scale"},
+				Scope:       &scope,
+			},
+		},
+	}
+	log = logf.Log.WithName(WebhookName)
+
+	// managedDeployments are the names of the Deployments of managed operators
+	// and components which may not be scaled to zero, by namespace
+	managedDeployments = map[string][]string{
+		"openshift-image-registry":   {"cluster-image-registry-operator", "image-registry"},
+		"openshift-ingress":          {"router-default"},
+		"openshift-ingress-operator": {"ingress-operator"},
+		"openshift-monitoring":       {"cluster-monitoring-operator", "prometheus-operator"},
+		"openshift-network-operator": {"network-operator"},
+		"openshift-ovn-kubernetes":   {"ovnkube-control-plane"},
+	}
+)
+
+// replicas is the spec of both Deployments and Scales, which is all the
+// webhook decodes
+type replicas struct {
+	Spec struct {
+		Replicas *int32 `json:"replicas,omitempty"`
+	} `json:"spec"`
+}
+
+// OperatorScaleWebhook protects the managed operators' Deployments from being
+// scaled to zero
+type OperatorScaleWebhook struct{}
+
+// NewWebhook creates a new webhook
+func NewWebhook() *OperatorScaleWebhook {
+	return &OperatorScaleWebhook{}
+}
+
+// Authorized implements Webhook interface
+func (s *OperatorScaleWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.authorized(request)
+}
+
+func (s *OperatorScaleWebhook) authorized(request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if !isManaged(request.Namespace, request.Name) {
+		ret = admissionctl.Allowed("Only the Deployments of managed operators are protected")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	if identity.IsAdmin(request.UserInfo) {
+		ret = admissionctl.Allowed("Admins and managed service accounts may scale managed operators")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	scale := request.SubResource == "scale"
+	old, err := decodeReplicas(request.OldObject.Raw, scale)
+	if err != nil {
+		log.Error(err, "Couldn't decode the old object from the request")
+		ret = admissionctl.Errored(http.StatusBadRequest, err)
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	updated, err := decodeReplicas(request.Object.Raw, scale)
+	if err != nil {
+		log.Error(err, "Couldn't decode the object from the request")
+		ret = admissionctl.Errored(http.StatusBadRequest, err)
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	// Deployments SRE scaled to zero may still be changed otherwise
+	if updated != 0 || old == 0 {
+		ret = admissionctl.Allowed("The Deployment isn't scaled to zero")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	log.Info("Denying scaling managed operator to zero", "namespace", request.Namespace, "name", request.Name, "subresource", request.SubResource, "user", request.UserInfo.Username)
+	ret = response.Denied(response.ManagedOperatorScaledToZero, fmt.Sprintf("Prevented from scaling the Deployment %s/%s to zero replicas, as the cluster relies on this managed operator or component. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", request.Namespace, request.Name))
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// isManaged checks whether the Deployment is one of the managedDeployments
+func isManaged(namespace, name string) bool {
+	return slices.Contains(managedDeployments[namespace], name)
+}
+
+// decodeReplicas returns the replicas of the Deployment, or of its Scale if
+// scale is set. A Deployment without replicas has one, while a Scale omits
+// them when there are none.
+func decodeReplicas(raw []byte, scale bool) (int32, error) {
+	obj := replicas{}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return 0, err
+	}
+	if obj.Spec.Replicas != nil {
+		return *obj.Spec.Replicas, nil
+	}
+	if scale {
+		return 0, nil
+	}
+	return 1, nil
+}
+
+// GetURI implements Webhook interface
+func (s *OperatorScaleWebhook) GetURI() string { return "/" + WebhookName }
+
+// Validate implements Webhook interface
+func (s *OperatorScaleWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	valid = valid && (len(request.OldObject.Raw) > 0)
+	valid = valid && (len(request.Object.Raw) > 0)
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *OperatorScaleWebhook) Name() string { return WebhookName }
+
+// FailurePolicy implements Webhook interface
+func (s *OperatorScaleWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *OperatorScaleWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *OperatorScaleWebhook) Rules() []admissionregv1.RuleWithOperations { return rules }
+
+// ObjectSelector implements Webhook interface
+func (s *OperatorScaleWebhook) ObjectSelector() *metav1.LabelSelector { return nil }
+
+// SideEffects implements Webhook interface
+func (s *OperatorScaleWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *OperatorScaleWebhook) TimeoutSeconds() int32 { return 2 }
+
+// Doc implements Webhook interface
+func (s *OperatorScaleWebhook) Doc() string {
+	return fmt.Sprintf(docString, strings.Join(deploymentNames(), ", "))
+}
+
+// deploymentNames lists the managedDeployments as namespace/name, sorted
+func deploymentNames() []string {
+	var names []string
+	for namespace, deployments := range managedDeployments {
+		for _, name := range deployments {
+			names = append(names, namespace+"/"+name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RuleDocs implements Webhook interface
+func (s *OperatorScaleWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers may not scale the Deployments of managed ingress, monitoring, image registry and networking operators and components to zero replicas.",
+			Exceptions: []string{utils.SREException, "The cluster's built-in administrators", "Platform and managed service accounts"},
+		},
+	}
+}
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+func (s *OperatorScaleWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// ClassicEnabled implements Webhook interface
+func (s *OperatorScaleWebhook) ClassicEnabled() bool { return true }
+
+// HypershiftEnabled implements Webhook interface
+func (s *OperatorScaleWebhook) HypershiftEnabled() bool { return true }
@@ -0,0 +1,135 @@
+package operatorscale
+
+import (
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+)
+
+func deployment(replicas *int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "openshift-ingress-operator", Name: "ingress-operator"},
+		Spec:       appsv1.DeploymentSpec{Replicas: replicas},
+	}
+}
+
+func scale(replicas int32) *autoscalingv1.Scale {
+	return &autoscalingv1.Scale{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "openshift-ingress-operator", Name: "ingress-operator"},
+		Spec:       autoscalingv1.ScaleSpec{Replicas: replicas},
+	}
+}
+
+func TestAuthorized(t *testing.T) {
+	tests := []struct {
+		name        string
+		deployment  string
+		subresource string
+		username    string
+		groups      []string
+		old         runtime.Object
+		obj         runtime.Object
+		allowed     bool
+		message     string
+	}{
+		{
+			name:     "deployment scaled to zero",
+			username: "customer",
+			old:      deployment(ptr.To(int32(2))),
+			obj:      deployment(ptr.To(int32(0))),
+			allowed:  false,
+			message:  "Prevented from scaling the Deployment openshift-ingress-operator/ingress-operator to zero replicas",
+		},
+		{
+			name:     "deployment without replicas scaled to zero",
+			username: "customer",
+			old:      deployment(nil),
+			obj:      deployment(ptr.To(int32(0))),
+			allowed:  false,
+		},
+		{
+			name:        "scale subresource set to zero",
+			subresource: "scale",
+			username:    "customer",
+			old:         scale(1),
+			obj:         scale(0),
+			allowed:     false,
+		},
+		{
+			name:        "scale subresource set to one",
+			subresource: "scale",
+			username:    "customer",
+			old:         scale(0),
+			obj:         scale(1),
+			allowed:     true,
+		},
+		{
+			name:     "deployment scaled to zero by SRE updated",
+			username: "customer",
+			old:      deployment(ptr.To(int32(0))),
+			obj:      deployment(ptr.To(int32(0))),
+			allowed:  true,
+		},
+		{
+			name:       "customer's deployment scaled to zero",
+			deployment: "payments",
+			username:   "customer",
+			old:        deployment(ptr.To(int32(2))),
+			obj:        deployment(ptr.To(int32(0))),
+			allowed:    true,
+		},
+		{
+			name:     "sre",
+			username: "backplane-cluster-admin",
+			old:      deployment(ptr.To(int32(2))),
+			obj:      deployment(ptr.To(int32(0))),
+			allowed:  true,
+		},
+		{
+			name:     "managed operator",
+			username: "system:serviceaccount:openshift-cluster-version:default",
+			groups:   []string{"system:serviceaccounts", "system:serviceaccounts:openshift-cluster-version"},
+			old:      deployment(ptr.To(int32(2))),
+			obj:      deployment(ptr.To(int32(0))),
+			allowed:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hook := NewWebhook()
+			gvk := metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+			user := authenticationv1.UserInfo{Username: test.username, Groups: test.groups}
+			request := testutils.NewRequest(t, gvk, admissionv1.Update, user, "openshift-ingress-operator", "ingress-operator", test.obj, test.old)
+			request.SubResource = test.subresource
+			if test.subresource == "scale" {
+				request.Kind = metav1.GroupVersionKind{Group: "autoscaling", Version: "v1", Kind: "Scale"}
+			}
+			if test.deployment != "" {
+				request.Name = test.deployment
+			}
+			if !hook.Validate(request) {
+				t.Fatalf("Expected request to be valid")
+			}
+			response := hook.Authorized(request)
+			if response.Allowed != test.allowed {
+				t.Fatalf("Expected allowed %v, got %v: %v", test.allowed, response.Allowed, response.Result)
+			}
+			if response.UID != request.UID {
+				t.Fatalf("Expected UID %s, got %s", request.UID, response.UID)
+			}
+			if test.message != "" && !strings.Contains(response.Result.Message, test.message) {
+				t.Errorf("Expected the message to contain %q, got %s", test.message, response.Result.Message)
+			}
+		})
+	}
+}
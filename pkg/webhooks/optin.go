@@ -0,0 +1,17 @@
+package webhooks
+
+// OptInWebhook is implemented by webhooks enforcing policies customers choose,
+// which are only enabled on the clusters whose feature gate ConfigMap enables
+// them
+type OptInWebhook interface {
+	// OptIn returns true if the webhook is disabled unless enabled per cluster
+	OptIn() bool
+}
+
+// IsOptIn tells whether the webhook is disabled unless enabled per cluster
+func IsOptIn(hook Webhook) bool {
+	if optInHook, ok := hook.(OptInWebhook); ok {
+		return optInHook.OptIn()
+	}
+	return false
+}
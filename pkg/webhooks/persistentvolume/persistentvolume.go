@@ -0,0 +1,299 @@
+package persistentvolume
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	hookconfig "github.com/openshift/managed-cluster-validating-webhooks/pkg/config"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "persistentvolume-validation"
+	docString   string = `Managed OpenShift customers, including cluster admins, may not change the reclaim policy of, or delete, PersistentVolumes bound to claims in platform namespaces, as that would orphan or destroy the data of managed components. Customers deleting more than %d PersistentVolumeClaims within %s are warned.`
+
+	persistentVolumeKind      string = "PersistentVolume"
+	persistentVolumeClaimKind string = "PersistentVolumeClaim"
+)
+
+var (
+	// BulkDeletionThreshold is how many PersistentVolumeClaims a user may
+	// delete within BulkDeletionWindow before their deletions are warned
+	// about
+	BulkDeletionThreshold = 20
+	// BulkDeletionWindow is the sliding window PersistentVolumeClaim
+	// deletions are counted in
+	BulkDeletionWindow = time.Minute
+
+	clusterScope   = admissionregv1.ClusterScope
+	namespaceScope = admissionregv1.NamespacedScope
+	rules          = []admissionregv1.RuleWithOperations{
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Update, admissionregv1.Delete},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{""},
+				APIVersions: []string{"*"},
+				Resources:   []string{"persistentvolumes"},
+				Scope:       &clusterScope,
+			},
+		},
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Delete},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{""},
+				APIVersions: []string{"*"},
+				Resources:   []string{"persistentvolumeclaims"},
+				Scope:       &namespaceScope,
+			},
+		},
+	}
+	log = logf.Log.WithName(WebhookName)
+
+	// controllerManager deletes released volumes whose reclaim policy is
+	// Delete
+	controllerManager = "system:kube-controller-manager"
+
+	// deletions is shared by every PersistentVolumeWebhook because the
+	// dispatcher builds a new webhook for each request
+	deletions = newDeletionCounter()
+)
+
+// PersistentVolumeWebhook protects the PersistentVolumes of platform
+// namespaces, and warns about bulk PersistentVolumeClaim deletions
+type PersistentVolumeWebhook struct {
+	decoder admissionctl.Decoder
+	now     func() time.Time
+	*deletionCounter
+}
+
+// deletionCounter holds the PersistentVolumeClaim deletions counted by this
+// replica of the webhook server
+type deletionCounter struct {
+	mu sync.Mutex
+	// deleted holds the times of each user's recent deletions, oldest first
+	deleted map[string][]time.Time
+}
+
+func newDeletionCounter() *deletionCounter {
+	return &deletionCounter{deleted: map[string][]time.Time{}}
+}
+
+// NewWebhook creates a new webhook
+func NewWebhook() *PersistentVolumeWebhook {
+	decoder, err := k8sutil.SharedDecoder()
+	if err != nil {
+		log.Error(err, "Fail getting the shared decoder for PersistentVolumeWebhook")
+		os.Exit(1)
+	}
+
+	return &PersistentVolumeWebhook{
+		decoder:         decoder,
+		now:             time.Now,
+		deletionCounter: deletions,
+	}
+}
+
+// Authorized implements Webhook interface
+func (s *PersistentVolumeWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.authorized(request)
+}
+
+func (s *PersistentVolumeWebhook) authorized(request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if identity.IsManager(request.UserInfo) || request.UserInfo.Username == controllerManager {
+		ret = admissionctl.Allowed("SRE and managed service accounts may change and delete persistent volumes")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	if request.Kind.Kind == persistentVolumeClaimKind {
+		return s.authorizeClaimDeletion(request)
+	}
+	return s.authorizeVolume(request)
+}
+
+// authorizeVolume denies changing the reclaim policy of, or deleting,
+// PersistentVolumes bound to claims in platform namespaces
+func (s *PersistentVolumeWebhook) authorizeVolume(request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	pv := &corev1.PersistentVolume{}
+	raw := request.Object
+	if request.Operation == admissionv1.Delete {
+		raw = request.OldObject
+	}
+	if err := s.decoder.DecodeRaw(raw, pv); err != nil {
+		log.Error(err, "Couldn't decode the PersistentVolume from the request", "name", request.Name)
+		ret = admissionctl.Errored(http.StatusBadRequest, err)
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	claim := pv.Spec.ClaimRef
+	if claim == nil || !hookconfig.ExcludedNamespaces.Excludes(claim.Namespace) {
+		ret = admissionctl.Allowed("PersistentVolume isn't bound to a platform namespace")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	if request.Operation == admissionv1.Delete {
+		log.Info("Denying deletion of platform PersistentVolume", "name", request.Name, "claimNamespace", claim.Namespace, "claim", claim.Name, "user", request.UserInfo.Username)
+		ret = response.Denied(response.PlatformPersistentVolume, fmt.Sprintf("Prevented from deleting PersistentVolume %s, bound to claim %s in the platform namespace %s. Its data belongs to a managed component. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", request.Name, claim.Name, claim.Namespace))
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	old := &corev1.PersistentVolume{}
+	if err := s.decoder.DecodeRaw(request.OldObject, old); err != nil {
+		log.Error(err, "Couldn't decode the old PersistentVolume from the request", "name", request.Name)
+		ret = admissionctl.Errored(http.StatusBadRequest, err)
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	if pv.Spec.PersistentVolumeReclaimPolicy != old.Spec.PersistentVolumeReclaimPolicy {
+		log.Info("Denying reclaim policy change of platform PersistentVolume", "name", request.Name, "claimNamespace", claim.Namespace, "from", old.Spec.PersistentVolumeReclaimPolicy, "to", pv.Spec.PersistentVolumeReclaimPolicy, "user", request.UserInfo.Username)
+		ret = response.Denied(response.PlatformPersistentVolume, fmt.Sprintf("Prevented from changing the reclaim policy of PersistentVolume %s, bound to claim %s in the platform namespace %s, from %s to %s. Its data belongs to a managed component, which relies on it being kept or deleted with its claim. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", request.Name, claim.Name, claim.Namespace, old.Spec.PersistentVolumeReclaimPolicy, pv.Spec.PersistentVolumeReclaimPolicy))
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	ret = admissionctl.Allowed("PersistentVolume keeps its reclaim policy")
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// authorizeClaimDeletion allows deleting PersistentVolumeClaims, warning the
+// user once they've deleted more than BulkDeletionThreshold within
+// BulkDeletionWindow. Dry runs aren't counted.
+func (s *PersistentVolumeWebhook) authorizeClaimDeletion(request admissionctl.Request) admissionctl.Response {
+	ret := admissionctl.Allowed("PersistentVolumeClaims may be deleted")
+	ret.UID = request.AdmissionRequest.UID
+	if request.DryRun != nil && *request.DryRun {
+		return ret
+	}
+
+	count := s.count(request.UserInfo.Username, s.now())
+	if count > BulkDeletionThreshold {
+		log.Info("Bulk PersistentVolumeClaim deletion", "namespace", request.Namespace, "name", request.Name, "user", request.UserInfo.Username, "deletions", count, "window", BulkDeletionWindow.String())
+		ret = ret.WithWarnings(fmt.Sprintf("You deleted %d PersistentVolumeClaims within %s. Deleting a claim may delete its volume and data, depending on the volume's reclaim policy.", count, BulkDeletionWindow))
+	}
+	return ret
+}
+
+// count records a deletion by the user at now, and returns how many they made
+// within BulkDeletionWindow
+func (c *deletionCounter) count(user string, now time.Time) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cutoff := now.Add(-BulkDeletionWindow)
+	for u, times := range c.deleted {
+		times = slices.DeleteFunc(times, func(t time.Time) bool { return !t.After(cutoff) })
+		if len(times) == 0 {
+			delete(c.deleted, u)
+			continue
+		}
+		c.deleted[u] = times
+	}
+	c.deleted[user] = append(c.deleted[user], now)
+	return len(c.deleted[user])
+}
+
+// GetURI implements Webhook interface
+func (s *PersistentVolumeWebhook) GetURI() string { return "/" + WebhookName }
+
+// Validate implements Webhook interface
+func (s *PersistentVolumeWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	switch request.Kind.Kind {
+	case persistentVolumeKind:
+		switch request.Operation {
+		case admissionv1.Update:
+			valid = valid && (len(request.Object.Raw) > 0) && (len(request.OldObject.Raw) > 0)
+		case admissionv1.Delete:
+			valid = valid && (len(request.OldObject.Raw) > 0)
+		default:
+			valid = false
+		}
+	case persistentVolumeClaimKind:
+		valid = valid && (request.Operation == admissionv1.Delete)
+	default:
+		valid = false
+	}
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *PersistentVolumeWebhook) Name() string { return WebhookName }
+
+// FailurePolicy implements Webhook interface
+func (s *PersistentVolumeWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *PersistentVolumeWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *PersistentVolumeWebhook) Rules() []admissionregv1.RuleWithOperations { return rules }
+
+// ObjectSelector implements Webhook interface
+func (s *PersistentVolumeWebhook) ObjectSelector() *metav1.LabelSelector { return nil }
+
+// SideEffects implements Webhook interface
+func (s *PersistentVolumeWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *PersistentVolumeWebhook) TimeoutSeconds() int32 { return 2 }
+
+// Doc implements Webhook interface
+func (s *PersistentVolumeWebhook) Doc() string {
+	return fmt.Sprintf(docString, BulkDeletionThreshold, BulkDeletionWindow)
+}
+
+// RuleDocs implements Webhook interface
+func (s *PersistentVolumeWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers, including cluster admins, may not change the reclaim policy of PersistentVolumes bound to claims in platform namespaces.",
+			Exceptions: []string{utils.SREException, utils.PrivilegedServiceAccountsException, "Hive, as system:admin"},
+		},
+		{
+			Summary:    "Customers, including cluster admins, may not delete PersistentVolumes bound to claims in platform namespaces.",
+			Exceptions: []string{utils.SREException, utils.PrivilegedServiceAccountsException, "Hive, as system:admin", "The kube-controller-manager, reclaiming released volumes"},
+		},
+		{
+			Summary: fmt.Sprintf("Customers deleting more than %d PersistentVolumeClaims within %s are warned; the deletions are allowed.", BulkDeletionThreshold, BulkDeletionWindow),
+		},
+	}
+}
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+func (s *PersistentVolumeWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// ClassicEnabled implements Webhook interface
+func (s *PersistentVolumeWebhook) ClassicEnabled() bool { return true }
+
+// HypershiftEnabled implements Webhook interface
+func (s *PersistentVolumeWebhook) HypershiftEnabled() bool { return true }
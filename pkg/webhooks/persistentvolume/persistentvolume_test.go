@@ -0,0 +1,179 @@
+package persistentvolume
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+)
+
+var (
+	clusterAdmin = authenticationv1.UserInfo{Username: "kube:admin", Groups: []string{"system:cluster-admins", "system:authenticated"}}
+	customer     = authenticationv1.UserInfo{Username: "customer", Groups: []string{"dedicated-admins", "system:authenticated"}}
+	sre          = authenticationv1.UserInfo{Username: "backplane-cluster-admin"}
+	binder       = authenticationv1.UserInfo{Username: "system:serviceaccount:kube-system:persistent-volume-binder", Groups: []string{"system:serviceaccounts", "system:serviceaccounts:kube-system"}}
+	controller   = authenticationv1.UserInfo{Username: "system:kube-controller-manager"}
+)
+
+func newVolume(policy corev1.PersistentVolumeReclaimPolicy, claimNamespace string) *corev1.PersistentVolume {
+	pv := &corev1.PersistentVolume{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "PersistentVolume"},
+		ObjectMeta: metav1.ObjectMeta{Name: "pvc-0"},
+		Spec:       corev1.PersistentVolumeSpec{PersistentVolumeReclaimPolicy: policy},
+	}
+	if claimNamespace != "" {
+		pv.Spec.ClaimRef = &corev1.ObjectReference{Namespace: claimNamespace, Name: "data-0"}
+	}
+	return pv
+}
+
+func TestVolumes(t *testing.T) {
+	tests := []struct {
+		name      string
+		user      authenticationv1.UserInfo
+		operation admissionv1.Operation
+		old       *corev1.PersistentVolume
+		obj       *corev1.PersistentVolume
+		allowed   bool
+		message   string
+	}{
+		{
+			name:      "retain platform volume",
+			user:      clusterAdmin,
+			operation: admissionv1.Update,
+			old:       newVolume(corev1.PersistentVolumeReclaimDelete, "openshift-monitoring"),
+			obj:       newVolume(corev1.PersistentVolumeReclaimRetain, "openshift-monitoring"),
+			allowed:   false,
+			message:   "Prevented from changing the reclaim policy of PersistentVolume pvc-0, bound to claim data-0 in the platform namespace openshift-monitoring, from Delete to Retain",
+		},
+		{
+			name:      "delete platform volume",
+			user:      customer,
+			operation: admissionv1.Delete,
+			old:       newVolume(corev1.PersistentVolumeReclaimRetain, "openshift-monitoring"),
+			allowed:   false,
+			message:   "Prevented from deleting PersistentVolume pvc-0",
+		},
+		{
+			name:      "relabel platform volume",
+			user:      customer,
+			operation: admissionv1.Update,
+			old:       newVolume(corev1.PersistentVolumeReclaimDelete, "openshift-monitoring"),
+			obj:       newVolume(corev1.PersistentVolumeReclaimDelete, "openshift-monitoring"),
+			allowed:   true,
+		},
+		{
+			name:      "retain customer volume",
+			user:      customer,
+			operation: admissionv1.Update,
+			old:       newVolume(corev1.PersistentVolumeReclaimDelete, "payments"),
+			obj:       newVolume(corev1.PersistentVolumeReclaimRetain, "payments"),
+			allowed:   true,
+		},
+		{
+			name:      "delete unbound volume",
+			user:      customer,
+			operation: admissionv1.Delete,
+			old:       newVolume(corev1.PersistentVolumeReclaimRetain, ""),
+			allowed:   true,
+		},
+		{
+			name:      "SRE retains platform volume",
+			user:      sre,
+			operation: admissionv1.Update,
+			old:       newVolume(corev1.PersistentVolumeReclaimDelete, "openshift-monitoring"),
+			obj:       newVolume(corev1.PersistentVolumeReclaimRetain, "openshift-monitoring"),
+			allowed:   true,
+		},
+		{
+			name:      "binder updates platform volume",
+			user:      binder,
+			operation: admissionv1.Update,
+			old:       newVolume(corev1.PersistentVolumeReclaimDelete, "openshift-monitoring"),
+			obj:       newVolume(corev1.PersistentVolumeReclaimRetain, "openshift-monitoring"),
+			allowed:   true,
+		},
+		{
+			name:      "controller reclaims platform volume",
+			user:      controller,
+			operation: admissionv1.Delete,
+			old:       newVolume(corev1.PersistentVolumeReclaimDelete, "openshift-monitoring"),
+			allowed:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hook := NewWebhook()
+			request := testutils.NewRequest(t, metav1.GroupVersionKind{Version: "v1", Kind: "PersistentVolume"}, test.operation, test.user, "", "pvc-0", test.obj, test.old)
+			if !hook.Validate(request) {
+				t.Fatalf("Expected request to be valid")
+			}
+			response := hook.Authorized(request)
+			if response.Allowed != test.allowed {
+				t.Fatalf("Expected allowed %v, got %v: %v", test.allowed, response.Allowed, response.Result)
+			}
+			if response.UID != request.UID {
+				t.Fatalf("Expected UID %s, got %s", request.UID, response.UID)
+			}
+			if test.message != "" && !strings.Contains(response.Result.Message, test.message) {
+				t.Errorf("Expected the message to contain %q, got %s", test.message, response.Result.Message)
+			}
+		})
+	}
+}
+
+func TestBulkClaimDeletion(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	hook := NewWebhook()
+	hook.deletionCounter = newDeletionCounter()
+	hook.now = func() time.Time { return now }
+
+	deleteClaim := func(user authenticationv1.UserInfo, dryRun bool) admissionctl.Response {
+		t.Helper()
+		request := testutils.NewRequest(t, metav1.GroupVersionKind{Version: "v1", Kind: "PersistentVolumeClaim"}, admissionv1.Delete, user, "payments", "data-0", nil, nil)
+		request.DryRun = ptr.To(dryRun)
+		if !hook.Validate(request) {
+			t.Fatalf("Expected request to be valid")
+		}
+		response := hook.Authorized(request)
+		if !response.Allowed {
+			t.Fatalf("Expected claim deletions to be allowed, got %v", response.Result)
+		}
+		return response
+	}
+
+	for i := 0; i < BulkDeletionThreshold; i++ {
+		if response := deleteClaim(customer, false); len(response.Warnings) > 0 {
+			t.Fatalf("Expected no warning for deletion %d, got %v", i+1, response.Warnings)
+		}
+	}
+	if response := deleteClaim(customer, true); len(response.Warnings) > 0 {
+		t.Errorf("Expected dry runs not to be counted, got %v", response.Warnings)
+	}
+	if response := deleteClaim(clusterAdmin, false); len(response.Warnings) > 0 {
+		t.Errorf("Expected each user to be counted apart, got %v", response.Warnings)
+	}
+	response := deleteClaim(customer, false)
+	if len(response.Warnings) != 1 || !strings.Contains(response.Warnings[0], "You deleted 21 PersistentVolumeClaims within 1m0s") {
+		t.Errorf("Expected a bulk deletion warning, got %v", response.Warnings)
+	}
+	for i := 0; i < 2*BulkDeletionThreshold; i++ {
+		if response := deleteClaim(binder, false); len(response.Warnings) > 0 {
+			t.Fatalf("Expected platform service accounts not to be counted, got %v", response.Warnings)
+		}
+	}
+
+	now = now.Add(BulkDeletionWindow)
+	if response := deleteClaim(customer, false); len(response.Warnings) > 0 {
+		t.Errorf("Expected deletions outside the window to be forgotten, got %v", response.Warnings)
+	}
+}
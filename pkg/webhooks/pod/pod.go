@@ -4,18 +4,18 @@ import (
 	"fmt"
 	"net/http"
 	"os"
-	"regexp"
 	"sync"
+	"time"
 
-	admissionv1 "k8s.io/api/admission/v1"
 	admissionregv1 "k8s.io/api/admissionregistration/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	hookconfig "github.com/openshift/managed-cluster-validating-webhooks/pkg/config"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
 	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
 )
 
@@ -26,8 +26,11 @@ const (
 )
 
 var (
-	unprivilegedNamespaceRe = regexp.MustCompile(unprivilegedNamespace)
-	log                     = logf.Log.WithName(WebhookName)
+	log = logf.Log.WithName(WebhookName)
+
+	// excludedNamespaces are the managed namespaces, except unprivilegedNamespace
+	// where customer workloads run too
+	excludedNamespaces = hookconfig.ExcludedNamespaces.Except(unprivilegedNamespace)
 
 	scope = admissionregv1.NamespacedScope
 	rules = []admissionregv1.RuleWithOperations{
@@ -44,8 +47,13 @@ var (
 )
 
 type PodWebhook struct {
-	mu sync.Mutex
-	s  runtime.Scheme
+	mu      sync.Mutex
+	decoder admissionctl.Decoder
+}
+
+// ExcludedNamespaces implements NamespaceExclusionWebhook interface
+func (s *PodWebhook) ExcludedNamespaces() utils.NamespaceExclusion {
+	return excludedNamespaces
 }
 
 // ObjectSelector implements Webhook interface
@@ -55,6 +63,16 @@ func (s *PodWebhook) Doc() string {
 	return fmt.Sprintf(docString)
 }
 
+// RuleDocs implements Webhook interface
+func (s *PodWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers may not give pods in customer namespaces tolerations for the NoSchedule or PreferNoSchedule taints of infra or master nodes.",
+			Exceptions: []string{"Pods in Red Hat managed namespaces"},
+		},
+	}
+}
+
 // TimeoutSeconds implements Webhook interface
 func (s *PodWebhook) TimeoutSeconds() int32 { return 1 }
 
@@ -94,13 +112,12 @@ func (s *PodWebhook) Validate(req admissionctl.Request) bool {
 }
 
 func (s *PodWebhook) renderPod(req admissionctl.Request) (*corev1.Pod, error) {
-	decoder := admissionctl.NewDecoder(&s.s)
 	pod := &corev1.Pod{}
 	var err error
 	if len(req.OldObject.Raw) > 0 {
-		err = decoder.DecodeRaw(req.OldObject, pod)
+		err = s.decoder.DecodeRaw(req.OldObject, pod)
 	} else {
-		err = decoder.DecodeRaw(req.Object, pod)
+		err = s.decoder.DecodeRaw(req.Object, pod)
 	}
 	if err != nil {
 		return nil, err
@@ -108,16 +125,6 @@ func (s *PodWebhook) renderPod(req admissionctl.Request) (*corev1.Pod, error) {
 	return pod, nil
 }
 
-func isRequestPrivileged(namespace string) bool {
-	if hookconfig.IsPrivilegedNamespace(namespace) {
-		if unprivilegedNamespaceRe.Match([]byte(namespace)) {
-			return false
-		}
-		return true
-	}
-	return false
-}
-
 // Authorized implements Webhook interface
 func (s *PodWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
 	return s.authorized(request)
@@ -133,25 +140,25 @@ func (s *PodWebhook) authorized(request admissionctl.Request) admissionctl.Respo
 
 	// If the incoming Pod is aimed at a privileged namespace except for unprivilegedNamespace, allow it to do whatever it wants.
 	// However, if the pod is targeting a customer's namespace (aka non-privileged), then it may not tolerate certain master/infra node taints.
-	if !isRequestPrivileged(pod.ObjectMeta.GetNamespace()) {
+	if !s.ExcludedNamespaces().Excludes(pod.ObjectMeta.GetNamespace()) {
 		for _, toleration := range pod.Spec.Tolerations {
 			if toleration.Key == "node-role.kubernetes.io/infra" && toleration.Effect == corev1.TaintEffectNoSchedule {
-				ret = admissionctl.Denied("Not allowed to schedule a pod with NoSchedule taint on infra node")
+				ret = response.Denied(response.InfraNodeScheduling, "Not allowed to schedule a pod with NoSchedule taint on infra node")
 				ret.UID = request.AdmissionRequest.UID
 				return ret
 			}
 			if toleration.Key == "node-role.kubernetes.io/infra" && toleration.Effect == corev1.TaintEffectPreferNoSchedule {
-				ret = admissionctl.Denied("Not allowed to schedule a pod with PreferNoSchedule taint on infra node")
+				ret = response.Denied(response.InfraNodeScheduling, "Not allowed to schedule a pod with PreferNoSchedule taint on infra node")
 				ret.UID = request.AdmissionRequest.UID
 				return ret
 			}
 			if toleration.Key == "node-role.kubernetes.io/master" && toleration.Effect == corev1.TaintEffectNoSchedule {
-				ret = admissionctl.Denied("Not allowed to schedule a pod with NoSchedule taint on master node")
+				ret = response.Denied(response.InfraNodeScheduling, "Not allowed to schedule a pod with NoSchedule taint on master node")
 				ret.UID = request.AdmissionRequest.UID
 				return ret
 			}
 			if toleration.Key == "node-role.kubernetes.io/master" && toleration.Effect == corev1.TaintEffectPreferNoSchedule {
-				ret = admissionctl.Denied("Not allowed to schedule a pod with PreferNoSchedule taint on master node")
+				ret = response.Denied(response.InfraNodeScheduling, "Not allowed to schedule a pod with PreferNoSchedule taint on master node")
 				ret.UID = request.AdmissionRequest.UID
 				return ret
 			}
@@ -164,6 +171,10 @@ func (s *PodWebhook) authorized(request admissionctl.Request) admissionctl.Respo
 	return ret
 }
 
+// DecisionTTL implements CacheableWebhook interface. Decisions only depend on
+// the pod.
+func (s *PodWebhook) DecisionTTL() time.Duration { return 30 * time.Second }
+
 // SyncSetLabelSelector returns the label selector to use in the SyncSet.
 func (s *PodWebhook) SyncSetLabelSelector() metav1.LabelSelector {
 	return utils.DefaultLabelSelector()
@@ -175,20 +186,13 @@ func (s *PodWebhook) HypershiftEnabled() bool { return false }
 
 // NewWebhook creates a new webhook
 func NewWebhook() *PodWebhook {
-	scheme := runtime.NewScheme()
-	err := admissionv1.AddToScheme(scheme)
-	if err != nil {
-		log.Error(err, "Fail adding admissionsv1 scheme to PodWebhook")
-		os.Exit(1)
-	}
-
-	err = corev1.AddToScheme(scheme)
+	decoder, err := k8sutil.SharedDecoder()
 	if err != nil {
-		log.Error(err, "Fail adding corev1 scheme to PodWebhook")
+		log.Error(err, "Fail getting the shared decoder for PodWebhook")
 		os.Exit(1)
 	}
 
 	return &PodWebhook{
-		s: *scheme,
+		decoder: decoder,
 	}
 }
@@ -0,0 +1,304 @@
+package poddisruptionbudget
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	hookconfig "github.com/openshift/managed-cluster-validating-webhooks/pkg/config"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "poddisruptionbudget-validation"
+	docString   string = `Managed OpenShift customers may not create PodDisruptionBudgets in customer namespaces which allow no disruptions, with maxUnavailable 0 or a minAvailable of 100%% or of at least their pod count, for workloads with more than one pod, as they block the node drains of managed upgrades. Red Hat SRE can grant an exception with the %s annotation.`
+
+	// exceptionAnnotation exempts a PodDisruptionBudget. Its value should say
+	// why the exception was granted. It's only honoured when SRE set it.
+	exceptionAnnotation string = "managed.openshift.io/pdb-drain-exception"
+)
+
+var (
+	scope = admissionregv1.NamespacedScope
+	rules = []admissionregv1.RuleWithOperations{
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Create, admissionregv1.Update},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{"policy"},
+				APIVersions: []string{"*"},
+				Resources:   []string{"poddisruptionbudgets"},
+				Scope:       &scope,
+			},
+		},
+	}
+	log = logf.Log.WithName(WebhookName)
+)
+
+// PodDisruptionBudgetWebhook denies PodDisruptionBudgets which would block
+// node drains
+type PodDisruptionBudgetWebhook struct {
+	s          *runtime.Scheme
+	decoder    admissionctl.Decoder
+	kubeClient client.Client
+}
+
+// NewWebhook creates a new webhook
+func NewWebhook() *PodDisruptionBudgetWebhook {
+	scheme, err := k8sutil.SharedScheme()
+	if err != nil {
+		log.Error(err, "Fail getting the shared scheme for PodDisruptionBudgetWebhook")
+		os.Exit(1)
+	}
+	decoder, err := k8sutil.SharedDecoder()
+	if err != nil {
+		log.Error(err, "Fail getting the shared decoder for PodDisruptionBudgetWebhook")
+		os.Exit(1)
+	}
+
+	return &PodDisruptionBudgetWebhook{
+		s:       scheme,
+		decoder: decoder,
+	}
+}
+
+// InjectClient implements ClientWebhook interface
+func (s *PodDisruptionBudgetWebhook) InjectClient(c client.Client) {
+	s.kubeClient = c
+}
+
+// CachedObjects implements ClientWebhook interface. Pods are only listed for
+// PodDisruptionBudgets allowing no disruptions, which doesn't justify caching
+// every pod in the cluster.
+func (s *PodDisruptionBudgetWebhook) CachedObjects() []client.Object { return nil }
+
+// Authorized implements Webhook interface
+func (s *PodDisruptionBudgetWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.authorized(context.Background(), request)
+}
+
+// AuthorizedContext implements ContextWebhook interface
+func (s *PodDisruptionBudgetWebhook) AuthorizedContext(ctx context.Context, request admissionctl.Request) admissionctl.Response {
+	return s.authorized(ctx, request)
+}
+
+func (s *PodDisruptionBudgetWebhook) authorized(ctx context.Context, request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if s.ExcludedNamespaces().Excludes(request.Namespace) {
+		ret = admissionctl.Allowed("Only PodDisruptionBudgets in customer namespaces are checked")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	if identity.IsManager(request.UserInfo) {
+		ret = admissionctl.Allowed("SRE and managed service accounts may create any PodDisruptionBudget")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	pdb := &policyv1.PodDisruptionBudget{}
+	if err := s.decoder.Decode(request, pdb); err != nil {
+		log.Error(err, "Couldn't decode the PodDisruptionBudget from the request")
+		ret = admissionctl.Errored(http.StatusBadRequest, err)
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	// Only SRE can grant the exception, so customers may neither add nor
+	// change it, even on PodDisruptionBudgets which allow disruptions, and it's
+	// only honoured once SRE have set it
+	if exception, ok := pdb.Annotations[exceptionAnnotation]; ok {
+		granted, wasGranted := "", false
+		if request.Operation == admissionv1.Update {
+			old := &policyv1.PodDisruptionBudget{}
+			if err := s.decoder.DecodeRaw(request.OldObject, old); err != nil {
+				log.Error(err, "Couldn't decode the old PodDisruptionBudget from the request")
+				ret = admissionctl.Errored(http.StatusBadRequest, err)
+				ret.UID = request.AdmissionRequest.UID
+				return ret
+			}
+			granted, wasGranted = old.Annotations[exceptionAnnotation]
+		}
+		if !wasGranted || granted != exception {
+			log.Info("Denying PodDisruptionBudget setting the exception annotation", "namespace", request.Namespace, "name", pdb.Name, "user", request.UserInfo.Username)
+			ret = response.Denied(response.DrainBlockingDisruptionBudget, fmt.Sprintf("Prevented from setting the %s annotation on PodDisruptionBudget %s: only Red Hat SRE may grant the exception. If the workload can't tolerate any disruption, please reach out to Red Hat support at https://access.redhat.com/support to request an exception", exceptionAnnotation, pdb.Name))
+			ret.UID = request.AdmissionRequest.UID
+			return ret
+		}
+		ret = admissionctl.Allowed(fmt.Sprintf("Red Hat SRE granted the PodDisruptionBudget an exception: %s", exception))
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	// Workloads of a single pod are never blocked, so the pods are only
+	// listed if the PodDisruptionBudget would block larger ones
+	if blocksDisruptions(pdb, 2) == "" {
+		ret = admissionctl.Allowed("The PodDisruptionBudget allows disruptions")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	pods, err := s.matchingPods(ctx, pdb)
+	if err != nil {
+		// Not being able to tell is no reason to block PodDisruptionBudgets
+		log.Error(err, "Failed to list pods, allowing the PodDisruptionBudget", "namespace", request.Namespace, "name", pdb.Name)
+		ret = admissionctl.Allowed("Unable to determine how many pods the PodDisruptionBudget protects")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	if pods < 2 {
+		ret = admissionctl.Allowed("The PodDisruptionBudget doesn't protect a workload with more than one pod")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	reason := blocksDisruptions(pdb, pods)
+	if reason == "" {
+		ret = admissionctl.Allowed("The PodDisruptionBudget allows disruptions")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	log.Info("Denying PodDisruptionBudget which blocks node drains", "namespace", request.Namespace, "name", pdb.Name, "reason", reason, "pods", pods, "user", request.UserInfo.Username)
+	ret = response.Denied(response.DrainBlockingDisruptionBudget, fmt.Sprintf("Prevented from setting %s on PodDisruptionBudget %s, which protects %d pods: it allows none of them to be disrupted, which blocks the node drains of managed upgrades. Allow at least one pod to be unavailable, eg with maxUnavailable: 1. If the workload can't tolerate any disruption, please reach out to Red Hat support at https://access.redhat.com/support to request an exception", reason, pdb.Name, pods))
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// blocksDisruptions returns the setting with which the PodDisruptionBudget
+// allows none of its pods to be disrupted, or "" if it allows some
+func blocksDisruptions(pdb *policyv1.PodDisruptionBudget, pods int) string {
+	if maxUnavailable := pdb.Spec.MaxUnavailable; maxUnavailable != nil && (maxUnavailable.String() == "0" || maxUnavailable.String() == "0%") {
+		return "maxUnavailable: " + maxUnavailable.String()
+	}
+	if minAvailable := pdb.Spec.MinAvailable; minAvailable != nil {
+		if minAvailable.String() == "100%" || (minAvailable.Type == intstr.Int && minAvailable.IntValue() >= pods) {
+			return "minAvailable: " + minAvailable.String()
+		}
+	}
+	return ""
+}
+
+// matchingPods returns how many pods not being deleted the
+// PodDisruptionBudget selects
+func (s *PodDisruptionBudgetWebhook) matchingPods(ctx context.Context, pdb *policyv1.PodDisruptionBudget) (int, error) {
+	var err error
+	if s.kubeClient == nil {
+		s.kubeClient, err = k8sutil.KubeClient(s.s)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// A PodDisruptionBudget without a selector selects no pods
+	if pdb.Spec.Selector == nil {
+		return 0, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+	if err != nil {
+		return 0, err
+	}
+	pods := &corev1.PodList{}
+	if err := s.kubeClient.List(ctx, pods, client.InNamespace(pdb.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, pod := range pods.Items {
+		if pod.DeletionTimestamp == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// GetURI implements Webhook interface
+func (s *PodDisruptionBudgetWebhook) GetURI() string { return "/" + WebhookName }
+
+// Validate implements Webhook interface
+func (s *PodDisruptionBudgetWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	valid = valid && (request.Kind.Kind == "PodDisruptionBudget")
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *PodDisruptionBudgetWebhook) Name() string { return WebhookName }
+
+// FailurePolicy implements Webhook interface
+func (s *PodDisruptionBudgetWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *PodDisruptionBudgetWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *PodDisruptionBudgetWebhook) Rules() []admissionregv1.RuleWithOperations { return rules }
+
+// ExcludedNamespaces implements NamespaceExclusionWebhook interface
+func (s *PodDisruptionBudgetWebhook) ExcludedNamespaces() utils.NamespaceExclusion {
+	return hookconfig.ExcludedNamespaces
+}
+
+// ObjectSelector implements Webhook interface
+func (s *PodDisruptionBudgetWebhook) ObjectSelector() *metav1.LabelSelector { return nil }
+
+// SideEffects implements Webhook interface
+func (s *PodDisruptionBudgetWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *PodDisruptionBudgetWebhook) TimeoutSeconds() int32 { return 2 }
+
+// Doc implements Webhook interface
+func (s *PodDisruptionBudgetWebhook) Doc() string {
+	return fmt.Sprintf(docString, exceptionAnnotation)
+}
+
+// RuleDocs implements Webhook interface
+func (s *PodDisruptionBudgetWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary: "Customers, including cluster admins, may not create or update PodDisruptionBudgets in customer namespaces with maxUnavailable 0 or a minAvailable of 100% or of at least their pod count selecting more than one pod.",
+			Exceptions: []string{
+				utils.SREException,
+				utils.PrivilegedServiceAccountsException,
+				"Hive, as system:admin",
+				"PodDisruptionBudgets Red Hat SRE annotated with " + exceptionAnnotation,
+				"PodDisruptionBudgets whose pods can't be listed",
+			},
+		},
+		{
+			Summary:    "Customers, including cluster admins, may not add or change the " + exceptionAnnotation + " annotation of PodDisruptionBudgets in customer namespaces.",
+			Exceptions: []string{utils.SREException, utils.PrivilegedServiceAccountsException, "Hive, as system:admin"},
+		},
+	}
+}
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+func (s *PodDisruptionBudgetWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// ClassicEnabled implements Webhook interface
+func (s *PodDisruptionBudgetWebhook) ClassicEnabled() bool { return true }
+
+// HypershiftEnabled implements Webhook interface
+func (s *PodDisruptionBudgetWebhook) HypershiftEnabled() bool { return true }
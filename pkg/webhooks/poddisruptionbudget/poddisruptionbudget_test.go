@@ -0,0 +1,222 @@
+package poddisruptionbudget
+
+import (
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+)
+
+var (
+	customer     = authenticationv1.UserInfo{Username: "customer", Groups: []string{"dedicated-admins", "system:authenticated"}}
+	clusterAdmin = authenticationv1.UserInfo{Username: "kube:admin", Groups: []string{"system:cluster-admins", "system:authenticated"}}
+	sre          = authenticationv1.UserInfo{Username: "backplane-cluster-admin"}
+)
+
+func newPod(name string) *corev1.Pod {
+	return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "customer", Labels: map[string]string{"app": "web"}}}
+}
+
+func newPDB(maxUnavailable, minAvailable *intstr.IntOrString, annotations map[string]string) *policyv1.PodDisruptionBudget {
+	return &policyv1.PodDisruptionBudget{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "policy/v1", Kind: "PodDisruptionBudget"},
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "customer", Annotations: annotations},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			MaxUnavailable: maxUnavailable,
+			MinAvailable:   minAvailable,
+			Selector:       &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+		},
+	}
+}
+
+func newRequest(t *testing.T, user authenticationv1.UserInfo, namespace string, oldObj, obj *policyv1.PodDisruptionBudget) admissionctl.Request {
+	t.Helper()
+	operation := admissionv1.Create
+	if oldObj != nil {
+		operation = admissionv1.Update
+	}
+	return testutils.NewRequest(t, metav1.GroupVersionKind{Group: "policy", Version: "v1", Kind: "PodDisruptionBudget"}, operation, user, namespace, obj.Name, obj, oldObj)
+}
+
+func TestAuthorized(t *testing.T) {
+	zero := intstr.FromInt32(0)
+	one := intstr.FromInt32(1)
+	zeroPercent := intstr.FromString("0%")
+	all := intstr.FromString("100%")
+	most := intstr.FromString("90%")
+	two := intstr.FromInt32(2)
+	three := intstr.FromInt32(3)
+	exception := map[string]string{exceptionAnnotation: "quorum-based database, approved in support case"}
+	twoPods := []client.Object{newPod("web-1"), newPod("web-2")}
+	threePods := []client.Object{newPod("web-1"), newPod("web-2"), newPod("web-3")}
+
+	tests := []struct {
+		name      string
+		user      authenticationv1.UserInfo
+		namespace string
+		oldObj    *policyv1.PodDisruptionBudget
+		obj       *policyv1.PodDisruptionBudget
+		pods      []client.Object
+		allowed   bool
+		message   string
+	}{
+		{
+			name:    "customer creates pdb allowing disruptions",
+			user:    customer,
+			obj:     newPDB(&one, nil, nil),
+			pods:    twoPods,
+			allowed: true,
+		},
+		{
+			name:    "customer creates pdb with 90% minAvailable",
+			user:    customer,
+			obj:     newPDB(nil, &most, nil),
+			pods:    twoPods,
+			allowed: true,
+		},
+		{
+			name:    "customer creates pdb with maxUnavailable 0",
+			user:    customer,
+			obj:     newPDB(&zero, nil, nil),
+			pods:    twoPods,
+			allowed: false,
+			message: "maxUnavailable: 0",
+		},
+		{
+			name:    "cluster admin creates pdb with maxUnavailable 0%",
+			user:    clusterAdmin,
+			obj:     newPDB(&zeroPercent, nil, nil),
+			pods:    twoPods,
+			allowed: false,
+		},
+		{
+			name:    "customer creates pdb with minAvailable 100%",
+			user:    customer,
+			obj:     newPDB(nil, &all, nil),
+			pods:    twoPods,
+			allowed: false,
+			message: "minAvailable: 100%",
+		},
+		{
+			name:    "customer creates pdb with minAvailable of every pod",
+			user:    customer,
+			obj:     newPDB(nil, &three, nil),
+			pods:    threePods,
+			allowed: false,
+			message: "minAvailable: 3",
+		},
+		{
+			name:    "customer creates pdb with minAvailable of all but one pod",
+			user:    customer,
+			obj:     newPDB(nil, &two, nil),
+			pods:    threePods,
+			allowed: true,
+		},
+		{
+			name:    "customer creates pdb with maxUnavailable 0 for a single pod",
+			user:    customer,
+			obj:     newPDB(&zero, nil, nil),
+			pods:    []client.Object{newPod("web-1")},
+			allowed: true,
+		},
+		{
+			name:      "customer creates pdb with maxUnavailable 0 in a managed namespace",
+			user:      customer,
+			namespace: "openshift-monitoring",
+			obj:       newPDB(&zero, nil, nil),
+			pods:      twoPods,
+			allowed:   true,
+		},
+		{
+			name:    "customer grants own exception",
+			user:    customer,
+			obj:     newPDB(&zero, nil, exception),
+			pods:    twoPods,
+			allowed: false,
+		},
+		{
+			// The first step of granting themselves the exception, before
+			// updating the pdb to block drains
+			name:    "customer creates pdb allowing disruptions with exception",
+			user:    customer,
+			obj:     newPDB(&one, nil, exception),
+			pods:    twoPods,
+			allowed: false,
+			message: exceptionAnnotation,
+		},
+		{
+			name:    "customer adds exception to pdb allowing disruptions",
+			user:    customer,
+			oldObj:  newPDB(&one, nil, nil),
+			obj:     newPDB(&one, nil, exception),
+			pods:    twoPods,
+			allowed: false,
+		},
+		{
+			name:    "sre creates pdb with exception",
+			user:    sre,
+			obj:     newPDB(&zero, nil, exception),
+			pods:    twoPods,
+			allowed: true,
+		},
+		{
+			name:    "customer updates pdb sre granted an exception",
+			user:    customer,
+			oldObj:  newPDB(&zero, nil, exception),
+			obj:     newPDB(nil, &all, exception),
+			pods:    twoPods,
+			allowed: true,
+		},
+		{
+			name:    "customer changes the exception",
+			user:    customer,
+			oldObj:  newPDB(&zero, nil, exception),
+			obj:     newPDB(&zero, nil, map[string]string{exceptionAnnotation: "mine now"}),
+			pods:    twoPods,
+			allowed: false,
+		},
+		{
+			name:    "customer makes pdb block drains",
+			user:    customer,
+			oldObj:  newPDB(&one, nil, nil),
+			obj:     newPDB(&zero, nil, nil),
+			pods:    twoPods,
+			allowed: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := NewWebhook()
+			s.InjectClient(fake.NewClientBuilder().WithScheme(s.s).WithObjects(test.pods...).Build())
+			namespace := test.namespace
+			if namespace == "" {
+				namespace = "customer"
+			}
+			request := newRequest(t, test.user, namespace, test.oldObj, test.obj)
+			if !s.Validate(request) {
+				t.Fatalf("Expected the request to be valid")
+			}
+			response := s.Authorized(request)
+			if response.Allowed != test.allowed {
+				t.Errorf("Expected allowed %t, got %t: %s", test.allowed, response.Allowed, response.Result.Message)
+			}
+			if test.message != "" && !strings.Contains(response.Result.Message, test.message) {
+				t.Errorf("Expected the message to mention %s, got %s", test.message, response.Result.Message)
+			}
+			if response.UID != "test-uid" {
+				t.Errorf("Expected the response UID to be set")
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+//go:build ignore
+// +build ignore
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// payloadImages are the payload images bundled, by the namespace/name:tag of
+// their ImageStreamTag, with the component of the release payload they're
+// imported from and the product image published for it
+var payloadImages = []struct {
+	imageStreamTag string
+	component      string
+	product        string
+}{
+	{imageStreamTag: "openshift/cli:latest", component: "cli", product: "registry.redhat.io/openshift4/ose-cli:latest"},
+	{imageStreamTag: "openshift/must-gather:latest", component: "must-gather", product: "registry.redhat.io/openshift4/ose-must-gather:latest"},
+	{imageStreamTag: "openshift/tools:latest", component: "tools", product: "registry.redhat.io/openshift4/ose-tools-rhel9:latest"},
+}
+
+const (
+	// generatedFileName defines the path to the generated file relative to the invoking go:generate command
+	generatedFileName = "./payloadimages.go"
+
+	// releaseImageEnvVar names the release image whose payload pull specs are
+	// bundled. Without it, the product images' floating tags are bundled.
+	releaseImageEnvVar = "RELEASE_IMAGE"
+)
+
+const templateText = `// Code generated by pkg/webhooks/podimagespec/generate/payloadimages.go; DO NOT EDIT.
+// Generated at {{ .Timestamp }} from {{ .Release }}
+package podimagespec
+
+// bundledPayloadImages are the pull specs of the payload images, by
+// namespace/name:tag, bundled into the webhook
+var bundledPayloadImages = map[string]string{
+{{- range $key, $value := .Images }}
+	"{{ $key }}": "{{ $value }}",
+{{- end }}
+}
+`
+
+type templateArgs struct {
+	Timestamp time.Time
+	Release   string
+	Images    map[string]string
+}
+
+func main() {
+	release := os.Getenv(releaseImageEnvVar)
+	args := templateArgs{
+		Timestamp: time.Now().UTC(),
+		Release:   "the product images' floating tags",
+		Images:    map[string]string{},
+	}
+	if release != "" {
+		args.Release = release
+	}
+
+	for _, image := range payloadImages {
+		if release == "" {
+			args.Images[image.imageStreamTag] = image.product
+			continue
+		}
+		// Pinned by digest, as the release payload references its images
+		out, err := exec.Command("oc", "adm", "release", "info", "--image-for="+image.component, release).Output()
+		if err != nil {
+			log.Fatalf("Error resolving %s in %s: %v", image.component, release, err)
+		}
+		pullSpec := strings.TrimSpace(string(out))
+		if !strings.Contains(pullSpec, "@sha256:") {
+			log.Fatalf("Expected %s in %s to be pinned by digest, got %q", image.component, release, pullSpec)
+		}
+		args.Images[image.imageStreamTag] = pullSpec
+	}
+
+	tmpl, err := template.New("payloadimages").Parse(templateText)
+	if err != nil {
+		log.Fatalf("Error parsing template: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, args); err != nil {
+		log.Fatalf("Error rendering %s: %v", generatedFileName, err)
+	}
+	formatted, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatalf("Error formatting %s: %v", generatedFileName, err)
+	}
+	if err := os.WriteFile(generatedFileName, formatted, 0644); err != nil {
+		log.Fatalf("Error writing %s: %v", generatedFileName, err)
+	}
+	fmt.Printf("Wrote %d payload images to %s\n", len(args.Images), generatedFileName)
+}
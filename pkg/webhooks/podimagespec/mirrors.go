@@ -0,0 +1,178 @@
+package podimagespec
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	configv1 "github.com/openshift/api/config/v1"
+	operatorv1alpha1 "github.com/openshift/api/operator/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// mirrorResolver points image references at the mirrors a (typically
+// disconnected) cluster is configured to pull them from
+type mirrorResolver struct {
+	// digestMirrors maps sources to mirrors for digest references, from
+	// ImageDigestMirrorSets and ImageContentSourcePolicies
+	digestMirrors map[string][]string
+	// tagMirrors maps sources to mirrors for tag references, from
+	// ImageTagMirrorSets
+	tagMirrors map[string][]string
+}
+
+var (
+	// mirrorsTTL is how long the mirror configuration read from the cluster
+	// is used before it's read again
+	mirrorsTTL = 30 * time.Second
+	// mirrorConfig is shared by every PodImageSpecWebhook because the
+	// dispatcher builds a new webhook for each request.
+	mirrorConfig = &mirrorCache{}
+)
+
+// mirrorCache holds the most recently read mirror configuration, so pods
+// don't each list every mirror kind of the cluster
+type mirrorCache struct {
+	mu       sync.Mutex
+	resolver *mirrorResolver
+	expires  time.Time
+}
+
+// get returns the cached resolver and whether it is still fresh
+func (c *mirrorCache) get(now time.Time) (*mirrorResolver, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.resolver, now.Before(c.expires)
+}
+
+// set stores the resolver, valid for mirrorsTTL from now
+func (c *mirrorCache) set(resolver *mirrorResolver, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.resolver = resolver
+	c.expires = now.Add(mirrorsTTL)
+}
+
+// getMirrorResolver returns the cluster's image mirror configuration. It's
+// cached for mirrorsTTL; errors reading it are not cached.
+func (s *PodImageSpecWebhook) getMirrorResolver(ctx context.Context) (*mirrorResolver, error) {
+	now := time.Now()
+	if resolver, fresh := mirrorConfig.get(now); fresh {
+		return resolver, nil
+	}
+	resolver, err := s.readMirrorResolver(ctx)
+	if err != nil {
+		return nil, err
+	}
+	mirrorConfig.set(resolver, now)
+	return resolver, nil
+}
+
+// readMirrorResolver reads the cluster's image mirror configuration. Mirror
+// kinds which aren't installed on the cluster are skipped.
+func (s *PodImageSpecWebhook) readMirrorResolver(ctx context.Context) (*mirrorResolver, error) {
+	r := &mirrorResolver{
+		digestMirrors: map[string][]string{},
+		tagMirrors:    map[string][]string{},
+	}
+
+	idmsList := &configv1.ImageDigestMirrorSetList{}
+	if err := s.listMirrors(ctx, idmsList); err != nil {
+		return nil, err
+	}
+	for _, idms := range idmsList.Items {
+		for _, m := range idms.Spec.ImageDigestMirrors {
+			for _, mirror := range m.Mirrors {
+				r.digestMirrors[m.Source] = append(r.digestMirrors[m.Source], string(mirror))
+			}
+		}
+	}
+
+	icspList := &operatorv1alpha1.ImageContentSourcePolicyList{}
+	if err := s.listMirrors(ctx, icspList); err != nil {
+		return nil, err
+	}
+	for _, icsp := range icspList.Items {
+		for _, m := range icsp.Spec.RepositoryDigestMirrors {
+			r.digestMirrors[m.Source] = append(r.digestMirrors[m.Source], m.Mirrors...)
+		}
+	}
+
+	itmsList := &configv1.ImageTagMirrorSetList{}
+	if err := s.listMirrors(ctx, itmsList); err != nil {
+		return nil, err
+	}
+	for _, itms := range itmsList.Items {
+		for _, m := range itms.Spec.ImageTagMirrors {
+			for _, mirror := range m.Mirrors {
+				r.tagMirrors[m.Source] = append(r.tagMirrors[m.Source], string(mirror))
+			}
+		}
+	}
+
+	return r, nil
+}
+
+// listMirrors lists one kind of mirror configuration, treating a kind which
+// isn't installed as empty
+func (s *PodImageSpecWebhook) listMirrors(ctx context.Context, list client.ObjectList) error {
+	if err := s.kubeClient.List(ctx, list); err != nil && !meta.IsNoMatchError(err) {
+		return err
+	}
+	return nil
+}
+
+// resolve rewrites the image reference to the first mirror of the most
+// specific source matching it, leaving it unchanged when no source matches
+func (r *mirrorResolver) resolve(ref string) string {
+	source, mirrors := r.match(ref)
+	if source == "" {
+		return ref
+	}
+	return mirrors[0] + strings.TrimPrefix(ref, source)
+}
+
+// candidates returns the image reference rewritten to each mirror of the most
+// specific source matching it, in order, or only the reference when no source
+// matches
+func (r *mirrorResolver) candidates(ref string) []string {
+	source, mirrors := r.match(ref)
+	if source == "" {
+		return []string{ref}
+	}
+	candidates := make([]string, 0, len(mirrors))
+	for _, mirror := range mirrors {
+		candidates = append(candidates, mirror+strings.TrimPrefix(ref, source))
+	}
+	return candidates
+}
+
+// match returns the most specific source matching the image reference and
+// its mirrors, or "" if none does
+func (r *mirrorResolver) match(ref string) (string, []string) {
+	if r == nil {
+		return "", nil
+	}
+	mirrors := r.tagMirrors
+	if strings.Contains(ref, "@") {
+		mirrors = r.digestMirrors
+	}
+
+	repository := imageRepository(ref)
+	var source string
+	for candidate, candidateMirrors := range mirrors {
+		if len(candidateMirrors) == 0 || len(candidate) <= len(source) {
+			continue
+		}
+		// A source matches a repository, or any repository under it
+		if repository == candidate || strings.HasPrefix(repository, candidate+"/") {
+			source = candidate
+		}
+	}
+	if source == "" {
+		return "", nil
+	}
+	return source, mirrors[source]
+}
@@ -0,0 +1,75 @@
+package podimagespec
+
+import (
+	"fmt"
+	"strings"
+
+	"gomodules.xyz/jsonpatch/v2"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// podPatch builds the JSONPatch operations rewriting a pod, touching only the
+// fields the webhook changes. Diffing the whole re-marshalled pod also emits
+// operations for fields marshalling adds, such as an empty status, which are
+// larger and can collide with the patches of other mutating webhooks.
+type podPatch struct {
+	pod        *corev1.Pod
+	operations []jsonpatch.JsonPatchOperation
+	// annotations and labels are whether the pod has the map, or the patch
+	// adds it
+	annotations bool
+	labels      bool
+}
+
+// newPodPatch starts a patch of the pod as it was admitted
+func newPodPatch(pod *corev1.Pod) *podPatch {
+	return &podPatch{
+		pod:         pod,
+		annotations: pod.Annotations != nil,
+		labels:      pod.Labels != nil,
+	}
+}
+
+// replaceImage replaces the image of the i'th container of the containers,
+// initContainers or ephemeralContainers field
+func (p *podPatch) replaceImage(field string, i int, image string) {
+	p.operations = append(p.operations, jsonpatch.NewOperation("replace", fmt.Sprintf("/spec/%s/%d/image", field, i), image))
+}
+
+// setAnnotation sets the pod's annotation
+func (p *podPatch) setAnnotation(key, value string) {
+	p.setMapEntry("/metadata/annotations", &p.annotations, key, value)
+}
+
+// setLabel sets the pod's label
+func (p *podPatch) setLabel(key, value string) {
+	p.setMapEntry("/metadata/labels", &p.labels, key, value)
+}
+
+// setMapEntry sets the key of the map at path, adding the map if neither the
+// pod nor the patch has it yet. Adding an existing key replaces its value.
+func (p *podPatch) setMapEntry(path string, exists *bool, key, value string) {
+	if !*exists {
+		p.operations = append(p.operations, jsonpatch.NewOperation("add", path, map[string]string{key: value}))
+		*exists = true
+		return
+	}
+	p.operations = append(p.operations, jsonpatch.NewOperation("add", path+"/"+escapePointer(key), value))
+}
+
+// addPullSecret appends a reference to the pull secret to the pod's
+// imagePullSecrets
+func (p *podPatch) addPullSecret(name string) {
+	ref := corev1.LocalObjectReference{Name: name}
+	if p.pod.Spec.ImagePullSecrets == nil {
+		p.operations = append(p.operations, jsonpatch.NewOperation("add", "/spec/imagePullSecrets", []corev1.LocalObjectReference{ref}))
+		return
+	}
+	p.operations = append(p.operations, jsonpatch.NewOperation("add", "/spec/imagePullSecrets/-", ref))
+}
+
+// escapePointer escapes a key for use in a JSON pointer, as annotation and
+// label keys may contain "/"
+func escapePointer(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
@@ -3,22 +3,32 @@ package podimagespec
 import (
 	"context"
 	"fmt"
+	"hash/fnv"
 	"net/http"
 	"os"
-	"regexp"
-
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/config"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/events"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/imagespec"
 	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
 	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
 
 	imagestreamv1 "github.com/openshift/api/image/v1"
 	registryv1 "github.com/openshift/api/imageregistry/v1"
 	operatorv1 "github.com/openshift/api/operator/v1"
+	"gomodules.xyz/jsonpatch/v2"
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionregv1 "k8s.io/api/admissionregistration/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/json"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
@@ -26,7 +36,61 @@ import (
 
 const (
 	WebhookName string = "podimagespec-mutation"
-	docString   string = `OpenShift debugging tools on Managed OpenShift clusters must be available even if internal image registry is removed.`
+	docString   string = `OpenShift debugging tools on Managed OpenShift clusters must be available even if internal image registry is removed. SRE may rewrite additional forms of image references with the patterns in the %s ConfigMap. The image each rewritten container originally had is recorded in the pod's managed.openshift.io/original-image-<container> annotation, except for ephemeral containers, as the API server discards the metadata changes of the pods/ephemeralcontainers subresource. Manifest lists are preferred so rewritten images run on nodes of any architecture, and pods whose rewritten images are only built for an architecture they aren't restricted to are annotated managed.openshift.io/image-architecture-warning.`
+
+	// ResolutionModeEnvVar selects how an ImageStreamTag is turned into the
+	// rewritten image reference. Unset or "digest" pins the image digest;
+	// "tag" uses the (floating) reference the tag points at.
+	ResolutionModeEnvVar string = "PODIMAGESPEC_RESOLUTION_MODE"
+	resolutionModeDigest string = "digest"
+	resolutionModeTag    string = "tag"
+
+	// NamespacesEnvVar is a comma-separated list of namespaces whose
+	// internal-registry image references are rewritten. Defaults to
+	// defaultNamespace when unset.
+	NamespacesEnvVar string = "PODIMAGESPEC_NAMESPACES"
+	defaultNamespace string = "openshift"
+
+	// RegistryHostnamesEnvVar is a comma-separated list of the hostnames of
+	// the internal image registry whose image references are rewritten. A
+	// hostname ending in "*" matches any hostname it prefixes. Defaults to
+	// imagespec.DefaultHostnames when unset.
+	RegistryHostnamesEnvVar string = "PODIMAGESPEC_REGISTRY_HOSTNAMES"
+
+	// PatternsConfigMapName is the ConfigMap in the webhook namespace whose
+	// patterns key lists the imagespec.Patterns matching additional forms of
+	// image references, such as new internal registry hostnames or other
+	// source registries, and the templates rewriting them
+	PatternsConfigMapName string = "podimagespec-patterns"
+	patternsKey           string = "patterns"
+
+	// PullSecretEnvVar names a pull secret which is referenced by pods created
+	// with rewritten images, when the secret exists in the pod's namespace, so
+	// the external registry can be pulled from in restricted namespaces
+	PullSecretEnvVar string = "PODIMAGESPEC_PULL_SECRET"
+
+	// imageRewrittenReason is the reason of the Events recorded for rewritten
+	// images
+	imageRewrittenReason string = "ImageRewritten"
+
+	// OriginalImageAnnotationPrefix prefixes the annotations recording the
+	// image each rewritten container originally had, followed by the
+	// container's name
+	OriginalImageAnnotationPrefix string = "managed.openshift.io/original-image-"
+
+	// ImageRewrittenLabel is set to "true" on pods with rewritten images, so
+	// they can be found once the internal image registry is available again
+	ImageRewrittenLabel string = "managed.openshift.io/image-rewritten"
+
+	// ArchitectureWarningAnnotation is set on pods with rewritten images which
+	// are only built for one architecture, which the pod isn't restricted to.
+	// It lists each such container with the architecture its image is built
+	// for, as the pod may be scheduled on a node of another architecture.
+	ArchitectureWarningAnnotation string = "managed.openshift.io/image-architecture-warning"
+
+	// ephemeralContainersSubResource is the subresource `kubectl debug` adds
+	// ephemeral containers through
+	ephemeralContainersSubResource string = "ephemeralcontainers"
 )
 
 var (
@@ -36,6 +100,7 @@ var (
 		{
 			Operations: []admissionregv1.OperationType{
 				admissionregv1.Create,
+				admissionregv1.Update,
 			},
 			Rule: admissionregv1.Rule{
 				APIGroups:   []string{""},
@@ -44,55 +109,165 @@ var (
 				Scope:       &scope,
 			},
 		},
+		{
+			// `kubectl debug` adds ephemeral containers through an UPDATE of
+			// the pods/ephemeralcontainers subresource
+			Operations: []admissionregv1.OperationType{
+				admissionregv1.Update,
+			},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{""},
+				APIVersions: []string{"v1"},
+				Resources:   []string{"pods/ephemeralcontainers"},
+				Scope:       &scope,
+			},
+		},
 	}
-	log        = logf.Log.WithName(WebhookName)
-	imageRegex = regexp.MustCompile(`^(image-registry\.openshift-image-registry\.svc:5000\/)(?P<namespace>\S*)(/)(?P<image>\w*)(:)(?P<tag>\S*)`)
+	log = logf.Log.WithName(WebhookName)
+	// defaultParser parses images pulled through the default hostnames of the
+	// internal image registry
+	defaultParser = imagespec.NewParser()
+
+	// registryStatusTTL is how long a looked-up image registry management state
+	// is trusted before the config.imageregistry/cluster object is fetched again.
+	registryStatusTTL = 10 * time.Second
+	// registryStatus is shared by every PodImageSpecWebhook because the
+	// dispatcher builds a new webhook for each request.
+	registryStatus = &registryStatusCache{}
+
+	// patternsTTL is how long the patterns read from the ConfigMap are used
+	// before it's read again
+	patternsTTL = 30 * time.Second
+	// patterns is shared by every PodImageSpecWebhook because the dispatcher
+	// builds a new webhook for each request.
+	patterns = &patternCache{}
 )
 
+// registryStatusCache holds the most recently observed image registry
+// availability so repeated admissions don't each GET the registry Config.
+type registryStatusCache struct {
+	mu        sync.Mutex
+	available bool
+	expires   time.Time
+}
+
+// get returns the cached availability and whether it is still fresh
+func (c *registryStatusCache) get(now time.Time) (bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Before(c.expires) {
+		return c.available, true
+	}
+	return false, false
+}
+
+// set stores the availability, valid for registryStatusTTL from now
+func (c *registryStatusCache) set(available bool, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.available = available
+	c.expires = now.Add(registryStatusTTL)
+}
+
+// patternCache holds the most recently read additional image patterns
+type patternCache struct {
+	mu       sync.Mutex
+	patterns []imagespec.Pattern
+	expires  time.Time
+}
+
+// get returns the cached patterns and whether they are still fresh
+func (c *patternCache) get(now time.Time) ([]imagespec.Pattern, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.patterns, now.Before(c.expires)
+}
+
+// set stores the patterns, valid for patternsTTL from now
+func (c *patternCache) set(patterns []imagespec.Pattern, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.patterns = patterns
+	c.expires = now.Add(patternsTTL)
+}
+
 // PodImageSpecWebhook mutates an image spec in a pod
 type PodImageSpecWebhook struct {
 	s          *runtime.Scheme
+	decoder    admissionctl.Decoder
 	kubeClient client.Client
 }
 
 // NewWebhook creates the new webhook
 func NewWebhook() *PodImageSpecWebhook {
-	scheme := runtime.NewScheme()
-
-	err := admissionv1.AddToScheme(scheme)
+	scheme, err := k8sutil.SharedScheme()
 	if err != nil {
-		log.Error(err, "Fail adding admissionv1 scheme to PodImageSpecWebhook")
+		log.Error(err, "Fail getting the shared scheme for PodImageSpecWebhook")
 		os.Exit(1)
 	}
-	err = admissionregv1.AddToScheme(scheme)
+	decoder, err := k8sutil.SharedDecoder()
 	if err != nil {
-		log.Error(err, "Fail adding admissionregv1 scheme to PodImageSpecWebhook")
+		log.Error(err, "Fail getting the shared decoder for PodImageSpecWebhook")
 		os.Exit(1)
 	}
-	err = corev1.AddToScheme(scheme)
-	if err != nil {
-		log.Error(err, "Fail adding corev1 scheme to PodImageSpecWebhook")
-		os.Exit(1)
+
+	return &PodImageSpecWebhook{
+		s:       scheme,
+		decoder: decoder,
 	}
-	err = imagestreamv1.AddToScheme(scheme)
-	if err != nil {
-		log.Error(err, "Fail adding imagestreamv1 scheme to PodImageSpecWebhook")
-		os.Exit(1)
+}
+
+// InjectClient implements ClientWebhook interface
+func (s *PodImageSpecWebhook) InjectClient(c client.Client) {
+	s.kubeClient = c
+}
+
+// CachedObjects implements ClientWebhook interface. ImageStreamTags can't be
+// watched, and pull secrets are read too rarely to be worth caching every
+// Secret, so only the registry config is cached.
+func (s *PodImageSpecWebhook) CachedObjects() []client.Object {
+	return []client.Object{&registryv1.Config{}}
+}
+
+// WarmUp implements WarmUpWebhook interface. It fills the caches of the
+// image patterns and the registry's status, and discovers the image and
+// mirror APIs, which the first pods using internal registry images would
+// otherwise wait for. The payload images are resolved too, so they can be
+// used should the ImageStreamTags become unreadable.
+func (s *PodImageSpecWebhook) WarmUp(ctx context.Context) error {
+	if err := s.ensureClient(); err != nil {
+		return err
 	}
-	err = registryv1.AddToScheme(scheme)
-	if err != nil {
-		log.Error(err, "Fail adding registryv1 scheme to PodImageSpecWebhook")
-		os.Exit(1)
+	s.loadPatterns(ctx)
+	if _, err := s.checkImageRegistryStatus(ctx); err != nil {
+		return err
+	}
+	if _, err := s.kubeClient.RESTMapper().RESTMapping(imagestreamv1.SchemeGroupVersion.WithKind("ImageStreamTag").GroupKind(), imagestreamv1.SchemeGroupVersion.Version); err != nil {
+		return err
 	}
+	s.resolvePayloadImages(ctx)
+	_, err := s.getMirrorResolver(ctx)
+	return err
+}
 
-	return &PodImageSpecWebhook{
-		s: scheme,
+// ensureClient creates a client if none was injected
+func (s *PodImageSpecWebhook) ensureClient() error {
+	if s.kubeClient != nil {
+		return nil
 	}
+	var err error
+	s.kubeClient, err = k8sutil.KubeClient(s.s)
+	return err
 }
 
 // Authorized implements Webhook interface
 func (s *PodImageSpecWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
-	ret := s.authorized(request)
+	return s.AuthorizedContext(context.Background(), request)
+}
+
+// AuthorizedContext implements ContextWebhook interface
+func (s *PodImageSpecWebhook) AuthorizedContext(ctx context.Context, request admissionctl.Request) admissionctl.Response {
+	ret := s.authorized(ctx, request)
 	if err := ret.Complete(request); err != nil {
 		log.Error(err, "Failed to complete the request")
 		ret = admissionctl.Errored(http.StatusInternalServerError, err)
@@ -102,21 +277,19 @@ func (s *PodImageSpecWebhook) Authorized(request admissionctl.Request) admission
 	return ret
 }
 
-func (s *PodImageSpecWebhook) authorized(request admissionctl.Request) admissionctl.Response {
+func (s *PodImageSpecWebhook) authorized(ctx context.Context, request admissionctl.Request) admissionctl.Response {
 	var err error
 	var ret admissionctl.Response
-	ctx := context.Background()
 
-	if s.kubeClient == nil {
-		s.kubeClient, err = k8sutil.KubeClient(s.s)
-		if err != nil {
-			log.Error(err, "Fail creating KubeClient for PodImageSpecWebhook")
-			ret = admissionctl.Errored(http.StatusBadRequest, err)
-			ret.UID = request.AdmissionRequest.UID
-			return ret
-		}
+	if err = s.ensureClient(); err != nil {
+		log.Error(err, "Fail creating KubeClient for PodImageSpecWebhook")
+		ret = admissionctl.Errored(http.StatusBadRequest, err)
+		ret.UID = request.AdmissionRequest.UID
+		return ret
 	}
 
+	s.loadPatterns(ctx)
+
 	pod, err := s.renderPod(request)
 	if err != nil {
 		log.Error(err, "couldn't render a Pod from the incoming request")
@@ -125,7 +298,7 @@ func (s *PodImageSpecWebhook) authorized(request admissionctl.Request) admission
 		return ret
 	}
 
-	if !podContainsContainerRegexMatch(pod) {
+	if !podContainsInternalImage(pod) {
 		ret = admissionctl.Allowed("Pod image spec is valid")
 		ret.UID = request.AdmissionRequest.UID
 		return ret
@@ -145,7 +318,18 @@ func (s *PodImageSpecWebhook) authorized(request admissionctl.Request) admission
 		return ret
 	}
 
-	mutatedPod, err := s.mutatePod(ctx, pod)
+	var previous map[string]string
+	if request.Operation == admissionv1.Update {
+		previous, err = oldContainerImages(request)
+		if err != nil {
+			log.Error(err, "couldn't render the old Pod from the incoming request")
+			ret = admissionctl.Errored(http.StatusBadRequest, err)
+			ret.UID = request.AdmissionRequest.UID
+			return ret
+		}
+	}
+
+	patch, rewrites, err := s.mutatePod(ctx, request.Namespace, pod, previous, request.SubResource)
 	if err != nil {
 		log.Error(err, "Unable mutate pod")
 		ret = admissionctl.Errored(http.StatusInternalServerError, err)
@@ -153,113 +337,512 @@ func (s *PodImageSpecWebhook) authorized(request admissionctl.Request) admission
 		return ret
 	}
 
-	ret = admissionctl.PatchResponseFromRaw(request.Object.Raw, mutatedPod)
+	if len(rewrites) == 0 {
+		ret = admissionctl.Allowed("Pod image spec is already rewritten")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	ret = admissionctl.Patched("Rewrote the pod's internal registry images", patch...)
+	// tell whoever creates the pod its images aren't the ones asked for
+	for _, rewrite := range rewrites {
+		ret = ret.WithWarnings(rewrite.warning())
+	}
 	ret.UID = request.AdmissionRequest.UID
+	if request.DryRun == nil || !*request.DryRun {
+		recordRewrites(request, pod, rewrites)
+	}
 	return ret
 }
 
 // renderPod renders the Pod in the admission Request
 func (s *PodImageSpecWebhook) renderPod(request admissionctl.Request) (*corev1.Pod, error) {
-	decoder := admissionctl.NewDecoder(s.s)
 	pod := &corev1.Pod{}
-	err := decoder.Decode(request, pod)
+	err := s.decoder.Decode(request, pod)
 	if err != nil {
 		return nil, err
 	}
 	return pod, nil
 }
 
-func podContainsContainerRegexMatch(pod *corev1.Pod) (podMatch bool) {
-	podMatch = false
+// podImages is the part of a Pod holding its containers' images
+type podImages struct {
+	Spec struct {
+		Containers          []containerImage `json:"containers"`
+		InitContainers      []containerImage `json:"initContainers"`
+		EphemeralContainers []containerImage `json:"ephemeralContainers"`
+	} `json:"spec"`
+}
 
-	for i := range pod.Spec.Containers {
-		containerMatch, namespace, _, _ := checkContainerImageSpecByRegex(pod.Spec.Containers[i].Image)
-		if containerMatch && namespace == "openshift" {
-			podMatch = true
+type containerImage struct {
+	Name  string `json:"name"`
+	Image string `json:"image"`
+}
+
+// oldContainerImages maps the names of the containers of the Pod being
+// updated by the admission Request to their images. Only the images are
+// decoded, rather than the whole Pod.
+func oldContainerImages(request admissionctl.Request) (map[string]string, error) {
+	if len(request.OldObject.Raw) == 0 {
+		return nil, fmt.Errorf("there is no content to decode")
+	}
+	old := podImages{}
+	if err := json.Unmarshal(request.OldObject.Raw, &old); err != nil {
+		return nil, err
+	}
+	images := make(map[string]string, len(old.Spec.Containers)+len(old.Spec.InitContainers)+len(old.Spec.EphemeralContainers))
+	for _, containers := range [][]containerImage{old.Spec.Containers, old.Spec.InitContainers, old.Spec.EphemeralContainers} {
+		for _, c := range containers {
+			images[c.Name] = c.Image
 		}
 	}
+	return images, nil
+}
+
+// containerImages maps the names of all of the pod's containers to their
+// images. Container names are unique across all kinds of container in a pod.
+func containerImages(pod *corev1.Pod) map[string]string {
+	images := map[string]string{}
+	for _, c := range pod.Spec.Containers {
+		images[c.Name] = c.Image
+	}
+	for _, c := range pod.Spec.InitContainers {
+		images[c.Name] = c.Image
+	}
+	for _, c := range pod.Spec.EphemeralContainers {
+		images[c.Name] = c.Image
+	}
+	return images
+}
 
+// podContainsInternalImage returns true if any of the pod's containers has an
+// image of the internal registry which is rewritten
+func podContainsInternalImage(pod *corev1.Pod) bool {
+	isInternal := func(image string) bool {
+		match, ok := parseImage(image)
+		return ok && (match.Rewrite != "" || isRewriteNamespace(match.Namespace))
+	}
+	for i := range pod.Spec.Containers {
+		if isInternal(pod.Spec.Containers[i].Image) {
+			return true
+		}
+	}
 	for i := range pod.Spec.InitContainers {
-		containerMatch, namespace, _, _ := checkContainerImageSpecByRegex(pod.Spec.InitContainers[i].Image)
-		if containerMatch && namespace == "openshift" {
-			podMatch = true
+		if isInternal(pod.Spec.InitContainers[i].Image) {
+			return true
 		}
 	}
+	for i := range pod.Spec.EphemeralContainers {
+		if isInternal(pod.Spec.EphemeralContainers[i].Image) {
+			return true
+		}
+	}
+	return false
+}
 
-	return
+// imageRewrite is a container image rewritten by mutatePod
+type imageRewrite struct {
+	container string
+	from      string
+	to        string
+	// architecture is the only architecture the new image is built for, or
+	// "" if it's a manifest list or its architecture isn't known
+	architecture string
 }
 
-func (s *PodImageSpecWebhook) mutatePod(ctx context.Context, pod *corev1.Pod) ([]byte, error) {
-	mutatedPod := pod.DeepCopy()
+// message explains the rewrite in the pod's Events
+func (r imageRewrite) message() string {
+	return fmt.Sprintf("Rewrote the image of container %s from %s to %s, as the internal image registry is not available", r.container, r.from, r.to)
+}
 
-	for i := range pod.Spec.Containers {
-		imageURI, err := s.lookupImageStreamTagSpec(ctx, pod.Spec.Containers[i].Image)
+// warning explains the rewrite to the client creating the pod. It leaves out
+// the image asked for, so digests fit within response.MaxWarningLength.
+func (r imageRewrite) warning() string {
+	return fmt.Sprintf("The internal image registry is not available, so container %s runs %s", r.container, r.to)
+}
+
+// mutatePod returns the JSONPatch rewriting the pod's internal registry
+// images. Containers whose image is the same in previous, the images of the
+// pod being updated, are left alone so unrelated updates don't restart them,
+// and repeated admission passes don't patch the pod again. rewrites lists the
+// images rewritten, if any. Pods created in namespace with rewritten images
+// also reference the configured pull secret. The patch only touches the
+// images, annotations, labels and pull secrets it changes.
+func (s *PodImageSpecWebhook) mutatePod(ctx context.Context, namespace string, pod *corev1.Pod, previous map[string]string, subResource string) (patch []jsonpatch.JsonPatchOperation, rewrites []imageRewrite, err error) {
+	mirrors, err := s.getMirrorResolver(ctx)
+	if err != nil {
+		// Without the mirror configuration, images are pulled from their source
+		log.Error(err, "failed to get image mirror configuration")
+	}
+
+	podPatch := newPodPatch(pod)
+	rewrite := func(field string, i int, name, image string) error {
+		if previousImage, ok := previous[name]; ok && previousImage == image {
+			return nil
+		}
+		imageURI, architecture, err := s.lookupImageStreamTagSpec(ctx, image, mirrors)
 		if err != nil {
-			return []byte{}, err
+			return err
+		}
+		if imageURI != image {
+			podPatch.replaceImage(field, i, imageURI)
+			rewrites = append(rewrites, imageRewrite{container: name, from: image, to: imageURI, architecture: architecture})
+		}
+		return nil
+	}
+
+	for i := range pod.Spec.Containers {
+		if err := rewrite("containers", i, pod.Spec.Containers[i].Name, pod.Spec.Containers[i].Image); err != nil {
+			return nil, nil, err
 		}
-		mutatedPod.Spec.Containers[i].Image = imageURI
 	}
 
 	for i := range pod.Spec.InitContainers {
-		imageURI, err := s.lookupImageStreamTagSpec(ctx, pod.Spec.InitContainers[i].Image)
-		if err != nil {
-			return []byte{}, err
+		if err := rewrite("initContainers", i, pod.Spec.InitContainers[i].Name, pod.Spec.InitContainers[i].Image); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for i := range pod.Spec.EphemeralContainers {
+		if err := rewrite("ephemeralContainers", i, pod.Spec.EphemeralContainers[i].Name, pod.Spec.EphemeralContainers[i].Image); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if len(rewrites) == 0 {
+		return nil, nil, nil
+	}
+	// The API server discards the changes to the pod's metadata made through
+	// the pods/ephemeralcontainers subresource, so ephemeral containers are
+	// rewritten without recording their original images or labelling the
+	// pod, and registryrevert doesn't find them
+	if subResource == ephemeralContainersSubResource {
+		return podPatch.operations, rewrites, nil
+	}
+
+	for _, rewrite := range rewrites {
+		podPatch.setAnnotation(OriginalImageAnnotation(rewrite.container), rewrite.from)
+	}
+	podPatch.setLabel(ImageRewrittenLabel, "true")
+	if warning := architectureWarning(pod, rewrites); warning != "" {
+		podPatch.setAnnotation(ArchitectureWarningAnnotation, warning)
+	}
+
+	// A pod's pull secrets can't be changed once it's created
+	if previous == nil {
+		if name := s.pullSecret(ctx, namespace, pod); name != "" {
+			podPatch.addPullSecret(name)
 		}
-		mutatedPod.Spec.InitContainers[i].Image = imageURI
 	}
+	return podPatch.operations, rewrites, nil
+}
 
-	return json.Marshal(mutatedPod)
+// OriginalImageAnnotation is the annotation recording the image the container
+// had before it was rewritten. The name of an annotation is at most 63
+// characters, so long container names are shortened and suffixed with a hash
+// of the container's name.
+func OriginalImageAnnotation(container string) string {
+	prefix, name, _ := strings.Cut(OriginalImageAnnotationPrefix+container, "/")
+	if len(name) > validation.DNS1123LabelMaxLength {
+		hash := fnv.New32a()
+		hash.Write([]byte(container))
+		suffix := fmt.Sprintf("-%08x", hash.Sum32())
+		name = name[:validation.DNS1123LabelMaxLength-len(suffix)] + suffix
+	}
+	return prefix + "/" + name
 }
 
-// checkImageRegistryStatus checks the status of the image registry service
+// architectureWarning lists the rewritten containers whose new image is only
+// built for an architecture the pod's nodeSelector doesn't restrict it to, or
+// returns "" if there are none
+func architectureWarning(pod *corev1.Pod, rewrites []imageRewrite) string {
+	nodeArchitecture := pod.Spec.NodeSelector[corev1.LabelArchStable]
+	warnings := []string{}
+	for _, rewrite := range rewrites {
+		if rewrite.architecture != "" && rewrite.architecture != nodeArchitecture {
+			warnings = append(warnings, fmt.Sprintf("%s=%s", rewrite.container, rewrite.architecture))
+		}
+	}
+	return strings.Join(warnings, ",")
+}
+
+// recordRewrites records an Event on the pod for each rewritten image, so the
+// difference between the pod's image and the image the user asked for can be
+// explained
+func recordRewrites(request admissionctl.Request, pod *corev1.Pod, rewrites []imageRewrite) {
+	name := request.Name
+	if name == "" {
+		name = pod.GenerateName
+	}
+	object := corev1.ObjectReference{
+		APIVersion: "v1",
+		Kind:       "Pod",
+		Namespace:  request.Namespace,
+		Name:       name,
+	}
+	for _, rewrite := range rewrites {
+		events.Normal(object, imageRewrittenReason, rewrite.message())
+	}
+}
+
+// pullSecret returns the configured pull secret if it exists in the namespace
+// and the pod doesn't reference it yet, or "" otherwise. The pod is left alone
+// when the secret can't be read, as the image may still be pullable without
+// it.
+func (s *PodImageSpecWebhook) pullSecret(ctx context.Context, namespace string, pod *corev1.Pod) string {
+	name := strings.TrimSpace(os.Getenv(PullSecretEnvVar))
+	if name == "" {
+		return ""
+	}
+	for _, ref := range pod.Spec.ImagePullSecrets {
+		if ref.Name == name {
+			return ""
+		}
+	}
+
+	secret := &corev1.Secret{}
+	err := s.kubeClient.Get(ctx, client.ObjectKey{Name: name, Namespace: namespace}, secret)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			log.Error(err, "failed to get pull secret", "secret", name, "namespace", namespace)
+		}
+		return ""
+	}
+	if secret.Type != corev1.SecretTypeDockerConfigJson && secret.Type != corev1.SecretTypeDockercfg {
+		log.Info("Configured pull secret is not a docker config, not referencing it", "secret", name, "namespace", namespace, "type", secret.Type)
+		return ""
+	}
+	return name
+}
+
+// checkImageRegistryStatus checks the status of the image registry service.
+// The result is cached for registryStatusTTL; lookup errors are not cached.
 func (s *PodImageSpecWebhook) checkImageRegistryStatus(ctx context.Context) (bool, error) {
 	var err error
-	registryV1 := &registryv1.Config{}
+	now := time.Now()
+	if available, ok := registryStatus.get(now); ok {
+		return available, nil
+	}
 
+	registryV1 := &registryv1.Config{}
 	err = s.kubeClient.Get(ctx, client.ObjectKey{Name: "cluster"}, registryV1)
 	if err != nil {
 		return false, fmt.Errorf("failed to get image registry config: %v", err)
 	}
 
 	// if image registry is set to managed then it is operational
-	if registryV1.Spec.ManagementState == operatorv1.Managed {
-		return true, nil
+	available := registryV1.Spec.ManagementState == operatorv1.Managed
+	registryStatus.set(available, now)
+
+	return available, nil
+}
+
+// parseImage parses the image if it's tagged in the internal registry, or
+// matches one of the additional patterns
+func parseImage(image string) (imagespec.Match, bool) {
+	current, _ := patterns.get(time.Now())
+	return imagespec.NewRegistry(registryParser(), current...).Match(image)
+}
+
+// loadPatterns reads the additional image patterns from the ConfigMap when
+// they're older than patternsTTL. The patterns read last are kept when the
+// ConfigMap can't be read or is invalid, so a bad edit doesn't stop images
+// from being rewritten.
+func (s *PodImageSpecWebhook) loadPatterns(ctx context.Context) {
+	now := time.Now()
+	current, fresh := patterns.get(now)
+	if fresh {
+		return
 	}
 
-	return false, nil
+	cm := &corev1.ConfigMap{}
+	err := s.kubeClient.Get(ctx, client.ObjectKey{Namespace: config.OperatorNamespace, Name: PatternsConfigMapName}, cm)
+	switch {
+	case apierrors.IsNotFound(err):
+		current = nil
+	case err != nil:
+		log.Error(err, "failed to read image patterns, using the patterns read last")
+	default:
+		if parsed, err := imagespec.ParsePatterns([]byte(cm.Data[patternsKey])); err != nil {
+			log.Error(err, "invalid image patterns, using the patterns read last")
+		} else {
+			current = parsed
+		}
+	}
+	patterns.set(current, now)
 }
 
-// checkContainerImageSpecByRegex checks to see if the image is in the openshift namespace in the internal registry
-func checkContainerImageSpecByRegex(imagespec string) (bool, string, string, string) {
-	matches := imageRegex.FindStringSubmatch(imagespec)
-	if matches == nil {
-		return false, "", "", ""
+// registryParser returns the parser for the configured hostnames of the
+// internal registry
+func registryParser() *imagespec.Parser {
+	configured := os.Getenv(RegistryHostnamesEnvVar)
+	if configured == "" {
+		return defaultParser
 	}
-	namespaceIndex := imageRegex.SubexpIndex("namespace")
-	imageIndex := imageRegex.SubexpIndex("image")
-	tagIndex := imageRegex.SubexpIndex("tag")
-	return true, matches[namespaceIndex], matches[imageIndex], matches[tagIndex]
+	hostnames := []string{}
+	for _, hostname := range strings.Split(configured, ",") {
+		if hostname = strings.TrimSpace(hostname); hostname != "" {
+			hostnames = append(hostnames, hostname)
+		}
+	}
+	return imagespec.NewParser(hostnames...)
 }
 
-func (s *PodImageSpecWebhook) lookupImageStreamTagSpec(ctx context.Context, imagespec string) (string, error) {
+// lookupImageStreamTagSpec returns the external image the internal registry
+// image resolves to, and the only architecture it's built for, if known
+func (s *PodImageSpecWebhook) lookupImageStreamTagSpec(ctx context.Context, image string, mirrors *mirrorResolver) (string, string, error) {
 	var err error
 
-	matched, namespace, image, tag := checkContainerImageSpecByRegex(imagespec)
-	if !matched {
-		return imagespec, nil
+	match, matched := parseImage(image)
+	if matched && match.Rewrite != "" {
+		return s.pullableImage(ctx, mirrors, match.Rewrite, image), "", nil
+	}
+	ref := match.Reference
+	if !matched || !isRewriteNamespace(ref.Namespace) {
+		return image, "", nil
 	}
 
 	// get the image refrence from the imagestream
 	imageStreamTag := imagestreamv1.ImageStreamTag{}
-	err = s.kubeClient.Get(ctx, client.ObjectKey{Name: fmt.Sprintf("%s:%s", image, tag), Namespace: namespace}, &imageStreamTag)
+	err = s.kubeClient.Get(ctx, client.ObjectKey{Name: ref.ImageStreamTag(), Namespace: ref.Namespace}, &imageStreamTag)
 	if err != nil {
-		return imagespec, fmt.Errorf("failed to get image spec: %v", err)
+		// Fall back to the digest the payload image last resolved to, or its
+		// bundled pull spec, so debug tooling still resolves while the API is
+		// unavailable. Missing ImageStreamTags aren't guessed at.
+		if !apierrors.IsNotFound(err) {
+			if imageURI, ok := staticImages.get(ref); ok {
+				log.Info("Failed to get ImageStreamTag, using the payload image it last resolved to or is bundled", "imagestreamtag", ref.ImageStreamTag(), "namespace", ref.Namespace, "image", imageURI, "error", err.Error())
+				return s.pullableImage(ctx, mirrors, imageURI, image), "", nil
+			}
+		}
+		return image, "", fmt.Errorf("failed to get image spec: %v", err)
+	}
+
+	imageURI, architecture, err := resolveImageStreamTag(&imageStreamTag, resolutionMode())
+	if err != nil {
+		return image, "", err
+	}
+	staticImages.set(ref, imageURI)
+	return s.pullableImage(ctx, mirrors, imageURI, image), architecture, nil
+}
+
+// resolvePayloadImages stores the digests the payload images resolve to. The
+// images which can't be resolved are only logged, as they're only used when
+// lookups fail.
+func (s *PodImageSpecWebhook) resolvePayloadImages(ctx context.Context) {
+	for _, image := range payloadImages {
+		namespace, imageStreamTag, _ := strings.Cut(image, "/")
+		name, tag, _ := strings.Cut(imageStreamTag, ":")
+		ref := imagespec.Reference{Namespace: namespace, Name: name, Tag: tag}
+
+		ist := imagestreamv1.ImageStreamTag{}
+		if err := s.kubeClient.Get(ctx, client.ObjectKey{Name: ref.ImageStreamTag(), Namespace: ref.Namespace}, &ist); err != nil {
+			log.Info("Failed to resolve payload image", "image", image, "error", err.Error())
+			continue
+		}
+		imageURI, _, err := resolveImageStreamTag(&ist, resolutionModeDigest)
+		if err != nil {
+			log.Info("Failed to resolve payload image", "image", image, "error", err.Error())
+			continue
+		}
+		staticImages.set(ref, imageURI)
+	}
+}
+
+// rewriteNamespaces returns the namespaces whose image references are rewritten
+func rewriteNamespaces() []string {
+	namespaces := []string{}
+	for _, ns := range strings.Split(os.Getenv(NamespacesEnvVar), ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	if len(namespaces) == 0 {
+		return []string{defaultNamespace}
 	}
+	return namespaces
+}
 
-	// TODO add error checking for imageStreamTag.Tag.From.Name is set
-	// ie: validateImageStreamTagFromName()
+// isRewriteNamespace returns true if images from the given namespace of the
+// internal registry should be rewritten
+func isRewriteNamespace(namespace string) bool {
+	return slices.Contains(rewriteNamespaces(), namespace)
+}
 
-	return imageStreamTag.Tag.From.Name, nil
+// resolutionMode returns the configured ImageStreamTag resolution mode
+func resolutionMode() string {
+	if os.Getenv(ResolutionModeEnvVar) == resolutionModeTag {
+		return resolutionModeTag
+	}
+	return resolutionModeDigest
+}
+
+// resolveImageStreamTag returns the external image reference for an
+// ImageStreamTag, and the only architecture the image is built for if it's
+// known not to be a manifest list. In digest mode the immutable digest of the
+// tagged image is pinned, falling back to the tag reference when the
+// ImageStreamTag does not carry image metadata. Images imported without
+// preserving their manifest list only have the digest for the architecture
+// of the cluster which imported them, so the tag reference, which may be a
+// manifest list each node resolves for its own architecture, is used instead.
+func resolveImageStreamTag(ist *imagestreamv1.ImageStreamTag, mode string) (ref, architecture string, err error) {
+	var tagRef string
+	if ist.Tag != nil && ist.Tag.From != nil {
+		tagRef = ist.Tag.From.Name
+	}
+
+	if mode == resolutionModeDigest {
+		architecture = imageArchitecture(&ist.Image)
+		switch {
+		case architecture != "" && !preservesManifestList(ist) && tagRef != "":
+			log.Info("ImageStreamTag image was imported for a single architecture, using tag reference", "imagestreamtag", ist.Name, "namespace", ist.Namespace, "architecture", architecture)
+			return tagRef, "", nil
+		case strings.Contains(ist.Image.DockerImageReference, "@sha256:"):
+			return ist.Image.DockerImageReference, architecture, nil
+		case strings.HasPrefix(ist.Image.Name, "sha256:") && tagRef != "":
+			return imageRepository(tagRef) + "@" + ist.Image.Name, architecture, nil
+		}
+		log.Info("ImageStreamTag has no image digest, using tag reference", "imagestreamtag", ist.Name, "namespace", ist.Namespace)
+	}
+
+	if tagRef == "" {
+		return "", "", fmt.Errorf("ImageStreamTag %s/%s does not reference an image", ist.Namespace, ist.Name)
+	}
+	return tagRef, "", nil
+}
+
+// preservesManifestList returns true if the ImageStreamTag imports manifest
+// lists as they are, rather than the image for the cluster's architecture
+func preservesManifestList(ist *imagestreamv1.ImageStreamTag) bool {
+	return ist.Tag != nil && ist.Tag.ImportPolicy.ImportMode == imagestreamv1.ImportModePreserveOriginal
+}
+
+// imageArchitecture returns the only architecture the image is built for, or
+// "" if it's a manifest list or its metadata doesn't say
+func imageArchitecture(image *imagestreamv1.Image) string {
+	if len(image.DockerImageManifests) > 0 || len(image.DockerImageMetadata.Raw) == 0 {
+		return ""
+	}
+	metadata := struct {
+		Architecture string `json:"architecture"`
+	}{}
+	if err := json.Unmarshal(image.DockerImageMetadata.Raw, &metadata); err != nil {
+		return ""
+	}
+	return metadata.Architecture
+}
+
+// imageRepository strips any tag or digest from an image reference
+func imageRepository(ref string) string {
+	if i := strings.Index(ref, "@"); i != -1 {
+		return ref[:i]
+	}
+	// A ':' after the last '/' separates the tag, anything before it may be a
+	// registry port
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		return ref[:i]
+	}
+	return ref
 }
 
 // GetURI implements Webhook interface
@@ -301,9 +884,10 @@ func (s *PodImageSpecWebhook) ObjectSelector() *metav1.LabelSelector {
 	return nil
 }
 
-// SideEffects implements Webhook interface
+// SideEffects implements Webhook interface. Events are recorded for rewritten
+// images, except for dry runs.
 func (s *PodImageSpecWebhook) SideEffects() admissionregv1.SideEffectClass {
-	return admissionregv1.SideEffectClassNone
+	return admissionregv1.SideEffectClassNoneOnDryRun
 }
 
 // TimeoutSeconds implements Webhook interface
@@ -313,7 +897,17 @@ func (s *PodImageSpecWebhook) TimeoutSeconds() int32 {
 
 // Doc implements Webhook interface
 func (s *PodImageSpecWebhook) Doc() string {
-	return docString
+	return fmt.Sprintf(docString, PatternsConfigMapName)
+}
+
+// RuleDocs implements Webhook interface
+func (s *PodImageSpecWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Pods using internal registry images of the OpenShift debugging tools are rewritten to the image the ImageStreamTag resolves to, so they run even if the internal image registry is removed.",
+			Exceptions: []string{"Clusters whose internal image registry is available"},
+		},
+	}
 }
 
 // SyncSetLabelSelector returns the label selector to use in the SyncSet.
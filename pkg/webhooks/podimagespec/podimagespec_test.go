@@ -2,22 +2,65 @@ package podimagespec
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	patchengine "github.com/evanphx/json-patch"
+	configv1 "github.com/openshift/api/config/v1"
+	imagestreamv1 "github.com/openshift/api/image/v1"
 	registryv1 "github.com/openshift/api/imageregistry/v1"
+	operatorv1 "github.com/openshift/api/operator/v1"
+	operatorv1alpha1 "github.com/openshift/api/operator/v1alpha1"
+	"gomodules.xyz/jsonpatch/v2"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/config"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/events"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
 )
 
-type outputImageSpecRegex struct {
-	matched   bool
-	namespace string
-	image     string
-	tag       string
+// applyPatch returns the pod patched by the webhook
+func applyPatch(t *testing.T, pod *corev1.Pod, patch []jsonpatch.JsonPatchOperation) *corev1.Pod {
+	t.Helper()
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		t.Fatalf("failed to marshal pod: %v", err)
+	}
+	rawPatch, err := json.Marshal(patch)
+	if err != nil {
+		t.Fatalf("failed to marshal patch: %v", err)
+	}
+	decoded, err := patchengine.DecodePatch(rawPatch)
+	if err != nil {
+		t.Fatalf("failed to decode patch: %v", err)
+	}
+	if raw, err = decoded.Apply(raw); err != nil {
+		t.Fatalf("failed to apply patch %s: %v", rawPatch, err)
+	}
+	mutated := &corev1.Pod{}
+	if err := json.Unmarshal(raw, mutated); err != nil {
+		t.Fatalf("failed to unmarshal mutated pod: %v", err)
+	}
+	return mutated
 }
 
 func newMockRegistry(obs ...client.Object) (client.Client, error) {
@@ -25,6 +68,18 @@ func newMockRegistry(obs ...client.Object) (client.Client, error) {
 	if err := registryv1.Install(s); err != nil {
 		return nil, err
 	}
+	if err := imagestreamv1.Install(s); err != nil {
+		return nil, err
+	}
+	if err := configv1.Install(s); err != nil {
+		return nil, err
+	}
+	if err := operatorv1alpha1.Install(s); err != nil {
+		return nil, err
+	}
+	if err := corev1.AddToScheme(s); err != nil {
+		return nil, err
+	}
 
 	return fake.NewClientBuilder().WithScheme(s).WithObjects(obs...).Build(), nil
 }
@@ -49,6 +104,7 @@ func TestCheckImageRegistryStatus(t *testing.T) {
 	}
 
 	for _, test := range tests {
+		registryStatus = &registryStatusCache{}
 		s := NewWebhook()
 		s.kubeClient, _ = newMockRegistry(test.config)
 		actual, _ := s.checkImageRegistryStatus(context.Background())
@@ -59,87 +115,182 @@ func TestCheckImageRegistryStatus(t *testing.T) {
 
 }
 
-func TestCheckContainerImageSpecByRegex(t *testing.T) {
+func TestWarmUp(t *testing.T) {
+	registryStatus = &registryStatusCache{}
+	patterns = &patternCache{}
+	mirrorConfig = &mirrorCache{}
+	t.Cleanup(func() {
+		registryStatus = &registryStatusCache{}
+		patterns = &patternCache{}
+		mirrorConfig = &mirrorCache{}
+	})
+	// The fake client's RESTMapper is empty, unlike the API server's
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(imagestreamv1.SchemeGroupVersion.WithKind("ImageStreamTag"), meta.RESTScopeNamespace)
+	mock, _ := newMockRegistry()
+	s := NewWebhook()
+	s.kubeClient = fake.NewClientBuilder().WithScheme(mock.Scheme()).WithRESTMapper(mapper).WithObjects(&registryv1.Config{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+		Spec:       registryv1.ImageRegistrySpec{OperatorSpec: operatorv1.OperatorSpec{ManagementState: operatorv1.Removed}},
+	}).Build()
 
-	tests := []struct {
-		name      string
-		imagespec string
-		expected  outputImageSpecRegex
-	}{
-		{
-			name:      "test uninteresting short imagespec",
-			imagespec: "ubuntu",
-			expected: outputImageSpecRegex{
-				matched:   false,
-				namespace: "",
-				image:     "",
-				tag:       "",
+	if err := s.WarmUp(context.Background()); err != nil {
+		t.Fatalf("unexpected error warming up: %v", err)
+	}
+	if available, fresh := registryStatus.get(time.Now()); !fresh || available {
+		t.Errorf("expected the removed registry's status to be cached, got fresh %t, available %t", fresh, available)
+	}
+	if _, fresh := patterns.get(time.Now()); !fresh {
+		t.Errorf("expected the image patterns to be cached")
+	}
+	if _, fresh := mirrorConfig.get(time.Now()); !fresh {
+		t.Errorf("expected the mirror configuration to be cached")
+	}
+
+	// Without the registry config, the failure is left to requests
+	registryStatus = &registryStatusCache{}
+	s.kubeClient, _ = newMockRegistry()
+	if err := s.WarmUp(context.Background()); err == nil {
+		t.Errorf("expected an error without the registry config")
+	}
+}
+
+func TestCheckImageRegistryStatusCached(t *testing.T) {
+	registryStatus = &registryStatusCache{}
+	config := &registryv1.Config{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cluster",
+		},
+		Spec: registryv1.ImageRegistrySpec{
+			OperatorSpec: operatorv1.OperatorSpec{
+				ManagementState: operatorv1.Managed,
 			},
 		},
-		{
-			name:      "test uninteresting short tagged imagespec",
-			imagespec: "ubuntu:latest",
-			expected: outputImageSpecRegex{
-				matched:   false,
-				namespace: "",
-				image:     "",
-				tag:       "",
+	}
+	ctx := context.Background()
+	s := NewWebhook()
+	s.kubeClient, _ = newMockRegistry(config)
+
+	actual, err := s.checkImageRegistryStatus(ctx)
+	if err != nil || !actual {
+		t.Fatalf("expected registry to be available, got %t (err: %v)", actual, err)
+	}
+
+	// The registry is removed, but the cached state is still within its TTL
+	config.Spec.ManagementState = operatorv1.Removed
+	if err := s.kubeClient.Update(ctx, config); err != nil {
+		t.Fatalf("failed to update registry config: %v", err)
+	}
+	actual, err = s.checkImageRegistryStatus(ctx)
+	if err != nil || !actual {
+		t.Fatalf("expected cached registry state to be available, got %t (err: %v)", actual, err)
+	}
+
+	// Once the cached state expires the new state is fetched
+	registryStatus.expires = time.Now().Add(-time.Second)
+	actual, err = s.checkImageRegistryStatus(ctx)
+	if err != nil || actual {
+		t.Fatalf("expected registry to be unavailable after cache expiry, got %t (err: %v)", actual, err)
+	}
+}
+
+func TestGetMirrorResolverCached(t *testing.T) {
+	mirrorConfig = &mirrorCache{}
+	t.Cleanup(func() { mirrorConfig = &mirrorCache{} })
+	idms := &configv1.ImageDigestMirrorSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "release"},
+		Spec: configv1.ImageDigestMirrorSetSpec{
+			ImageDigestMirrors: []configv1.ImageDigestMirrors{
+				{Source: "quay.io/openshift-release-dev", Mirrors: []configv1.ImageMirror{"mirror.example.com/release"}},
 			},
 		},
+	}
+	mock, _ := newMockRegistry(idms)
+	lists := 0
+	ctx := context.Background()
+	s := NewWebhook()
+	s.kubeClient = fake.NewClientBuilder().WithScheme(mock.Scheme()).WithObjects(idms).WithInterceptorFuncs(interceptor.Funcs{
+		List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+			lists++
+			return c.List(ctx, list, opts...)
+		},
+	}).Build()
+
+	for i := 0; i < 3; i++ {
+		r, err := s.getMirrorResolver(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(r.digestMirrors) != 1 {
+			t.Fatalf("expected the mirror of the ImageDigestMirrorSet, got %v", r.digestMirrors)
+		}
+	}
+	// one List of each mirror kind
+	if lists != 3 {
+		t.Errorf("expected the mirror configuration to be listed once, got %d Lists", lists)
+	}
+
+	// Once the cached configuration expires it's read again
+	if err := s.kubeClient.Delete(ctx, idms); err != nil {
+		t.Fatalf("failed to delete the ImageDigestMirrorSet: %v", err)
+	}
+	mirrorConfig.expires = time.Now().Add(-time.Second)
+	r, err := s.getMirrorResolver(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(r.digestMirrors) != 0 {
+		t.Errorf("expected no mirrors after cache expiry, got %v", r.digestMirrors)
+	}
+}
+
+func TestParseImageHostnames(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      string
+		image    string
+		expected bool
+	}{
 		{
-			name:      "test uninteresting fully qualified tagged imagespec",
-			imagespec: "docker.io/library/ubuntu:latest",
-			expected: outputImageSpecRegex{
-				matched:   false,
-				namespace: "",
-				image:     "",
-				tag:       "",
-			},
+			name:     "service hostname by default",
+			image:    "image-registry.openshift-image-registry.svc:5000/openshift/cli:latest",
+			expected: true,
 		},
 		{
-			name:      "test uninteresting fully qualified SHA imagespec",
-			imagespec: "quay.io/openshift-release-dev/ocp-release@sha256:4dbe2a75a516a947eab036ef6a1d086f1b1610f6bd21c6ab5f95db68ec177ea2",
-			expected: outputImageSpecRegex{
-				matched:   false,
-				namespace: "",
-				image:     "",
-				tag:       "",
-			},
+			name:     "fully qualified service hostname by default",
+			image:    "image-registry.openshift-image-registry.svc.cluster.local:5000/openshift/cli:latest",
+			expected: true,
 		},
-		/// TODO: Need to add functionality to replace this with a fully qualified quay.io image.
 		{
-			name:      "test interesting fully qualified SHA imagespec",
-			imagespec: "image-registry.openshift-image-registry.svc:5000/openshift/cli@sha256:4dbe2a75a516a947eab036ef6a1d086f1b1610f6bd21c6ab5f95db68ec177ea2",
-			expected: outputImageSpecRegex{
-				matched:   false,
-				namespace: "",
-				image:     "",
-				tag:       "",
-			},
+			name:     "default route by default",
+			image:    "default-route-openshift-image-registry.apps.example.com/openshift/cli:latest",
+			expected: true,
 		},
 		{
-			name:      "test interesting fully qualified tagged imagespec",
-			imagespec: "image-registry.openshift-image-registry.svc:5000/openshift/cli:latest",
-			expected: outputImageSpecRegex{
-				matched:   true,
-				namespace: "openshift",
-				image:     "cli",
-				tag:       "latest",
-			},
+			name:     "configured hostname",
+			env:      "registry.example.com, image-registry.openshift-image-registry.svc:5000",
+			image:    "registry.example.com/openshift/cli:latest",
+			expected: true,
+		},
+		{
+			name:     "default hostname not configured",
+			env:      "registry.example.com",
+			image:    "default-route-openshift-image-registry.apps.example.com/openshift/cli:latest",
+			expected: false,
 		},
 	}
 
 	for _, test := range tests {
-		actual := outputImageSpecRegex{}
-		actual.matched, actual.namespace, actual.image, actual.tag = checkContainerImageSpecByRegex(test.imagespec)
-		if !reflect.DeepEqual(actual, test.expected) {
-			t.Errorf("TestCheckContainerImageSpecByRegex() %s -\n imagespec: %s \n actual: %v\n expected: %v\n", test.name, test.imagespec, actual, test.expected)
-		}
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(RegistryHostnamesEnvVar, test.env)
+			if _, actual := parseImage(test.image); actual != test.expected {
+				t.Errorf("Expected %s to match %t, got %t", test.image, test.expected, actual)
+			}
+		})
 	}
-
 }
 
-func TestPodContainsContainerRegexMatch(t *testing.T) {
+func TestPodContainsInternalImage(t *testing.T) {
 	tests := []struct {
 		name     string
 		pod      *corev1.Pod
@@ -246,6 +397,47 @@ func TestPodContainsContainerRegexMatch(t *testing.T) {
 			},
 			expected: true,
 		},
+		{
+			name: "test pod with cli image in ephemeralcontainers",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test",
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{Image: "ubuntu"},
+					},
+					EphemeralContainers: []corev1.EphemeralContainer{
+						{
+							EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+								Image: "image-registry.openshift-image-registry.svc:5000/openshift/tools:latest",
+							},
+						},
+					},
+				},
+				Status: corev1.PodStatus{},
+			},
+			expected: true,
+		},
+		{
+			name: "test pod with uninteresting image in ephemeralcontainers",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test",
+				},
+				Spec: corev1.PodSpec{
+					EphemeralContainers: []corev1.EphemeralContainer{
+						{
+							EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+								Image: "ubuntu",
+							},
+						},
+					},
+				},
+				Status: corev1.PodStatus{},
+			},
+			expected: false,
+		},
 		{
 			name: "test pod with cli image in containers and initcontainers",
 			pod: &corev1.Pod{
@@ -266,10 +458,1146 @@ func TestPodContainsContainerRegexMatch(t *testing.T) {
 		},
 	}
 	for _, test := range tests {
-		actual := podContainsContainerRegexMatch(test.pod)
+		actual := podContainsInternalImage(test.pod)
 		if actual != test.expected {
 			t.Errorf("TestPodContainsContainerRegexMatch() %s -\n pod: %v \n actual: %t\n expected: %t\n", test.name, test.pod, actual, test.expected)
 		}
 	}
 
 }
+
+func TestMutatePodEphemeralContainers(t *testing.T) {
+	ist := &imagestreamv1.ImageStreamTag{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "tools:latest",
+			Namespace: "openshift",
+		},
+		Tag: &imagestreamv1.TagReference{
+			From: &corev1.ObjectReference{
+				Kind: "DockerImage",
+				Name: "quay.io/openshift-release-dev/ocp-v4.0-art-dev@sha256:4dbe2a75a516a947eab036ef6a1d086f1b1610f6bd21c6ab5f95db68ec177ea2",
+			},
+		},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Image: "ubuntu"},
+			},
+			EphemeralContainers: []corev1.EphemeralContainer{
+				{
+					EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+						Image: "image-registry.openshift-image-registry.svc:5000/openshift/tools:latest",
+					},
+				},
+			},
+		},
+	}
+
+	s := NewWebhook()
+	s.kubeClient, _ = newMockRegistry(ist)
+	patch, _, err := s.mutatePod(context.Background(), "test", pod, nil, "")
+	if err != nil {
+		t.Fatalf("unexpected error mutating pod: %v", err)
+	}
+	mutated := applyPatch(t, pod, patch)
+	if mutated.Spec.Containers[0].Image != "ubuntu" {
+		t.Errorf("expected container image to be unchanged, got %s", mutated.Spec.Containers[0].Image)
+	}
+	if mutated.Spec.EphemeralContainers[0].Image != ist.Tag.From.Name {
+		t.Errorf("expected ephemeral container image %s, got %s", ist.Tag.From.Name, mutated.Spec.EphemeralContainers[0].Image)
+	}
+
+	// Through the pods/ephemeralcontainers subresource, only the image is
+	// rewritten as the API server discards changes to the metadata
+	patch, _, err = s.mutatePod(context.Background(), "test", pod, nil, ephemeralContainersSubResource)
+	if err != nil {
+		t.Fatalf("unexpected error mutating pod: %v", err)
+	}
+	for _, op := range patch {
+		if op.Path != "/spec/ephemeralContainers/0/image" {
+			t.Errorf("unexpected patch operation %s %s for the ephemeralcontainers subresource", op.Operation, op.Path)
+		}
+	}
+	mutated = applyPatch(t, pod, patch)
+	if mutated.Spec.EphemeralContainers[0].Image != ist.Tag.From.Name {
+		t.Errorf("expected ephemeral container image %s, got %s", ist.Tag.From.Name, mutated.Spec.EphemeralContainers[0].Image)
+	}
+}
+
+func TestMutatePodMirrors(t *testing.T) {
+	mirrorConfig = &mirrorCache{}
+	defer func() { mirrorConfig = &mirrorCache{} }()
+	const digest = "sha256:4dbe2a75a516a947eab036ef6a1d086f1b1610f6bd21c6ab5f95db68ec177ea2"
+	ist := &imagestreamv1.ImageStreamTag{
+		ObjectMeta: metav1.ObjectMeta{Name: "tools:latest", Namespace: "openshift"},
+		Tag: &imagestreamv1.TagReference{
+			From: &corev1.ObjectReference{Kind: "DockerImage", Name: "quay.io/openshift-release-dev/ocp-v4.0-art-dev@" + digest},
+		},
+	}
+	idms := &configv1.ImageDigestMirrorSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "release"},
+		Spec: configv1.ImageDigestMirrorSetSpec{
+			ImageDigestMirrors: []configv1.ImageDigestMirrors{
+				{Source: "quay.io/openshift-release-dev", Mirrors: []configv1.ImageMirror{"mirror.example.com/release"}},
+			},
+		},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Image: "image-registry.openshift-image-registry.svc:5000/openshift/tools:latest"},
+			},
+		},
+	}
+
+	s := NewWebhook()
+	s.kubeClient, _ = newMockRegistry(ist, idms)
+	patch, _, err := s.mutatePod(context.Background(), "test", pod, nil, "")
+	if err != nil {
+		t.Fatalf("unexpected error mutating pod: %v", err)
+	}
+	mutated := applyPatch(t, pod, patch)
+	expected := "mirror.example.com/release/ocp-v4.0-art-dev@" + digest
+	if mutated.Spec.Containers[0].Image != expected {
+		t.Errorf("expected container image %s, got %s", expected, mutated.Spec.Containers[0].Image)
+	}
+}
+
+// newMockImageRegistry serves the manifests of the images, which are
+// references without the registry's host. Repositories under private/ require
+// an anonymous bearer token.
+func newMockImageRegistry(t *testing.T, images ...string) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			fmt.Fprint(w, `{"token":"anonymous"}`)
+			return
+		}
+		if strings.HasPrefix(r.URL.Path, "/v2/private/") && r.Header.Get("Authorization") != "Bearer anonymous" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="mock"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		for _, image := range images {
+			repository, reference, _ := strings.Cut(image, "@")
+			if r.Method == http.MethodHead && r.URL.Path == "/v2/"+repository+"/manifests/"+reference {
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(server.Close)
+
+	previous := pullability
+	pullability = newPullabilityChecker(server.Client())
+	t.Cleanup(func() { pullability = previous })
+	t.Setenv(PullabilityCheckEnvVar, "true")
+	return server
+}
+
+func TestMutatePodPullability(t *testing.T) {
+	const digest = "sha256:4dbe2a75a516a947eab036ef6a1d086f1b1610f6bd21c6ab5f95db68ec177ea2"
+	ist := &imagestreamv1.ImageStreamTag{
+		ObjectMeta: metav1.ObjectMeta{Name: "tools:latest", Namespace: "openshift"},
+		Tag: &imagestreamv1.TagReference{
+			From: &corev1.ObjectReference{Kind: "DockerImage", Name: "quay.io/openshift-release-dev/ocp-v4.0-art-dev@" + digest},
+		},
+	}
+	const internalImage = "image-registry.openshift-image-registry.svc:5000/openshift/tools:latest"
+
+	tests := []struct {
+		name     string
+		images   []string
+		expected string
+	}{
+		{
+			name:     "first mirror has the image",
+			images:   []string{"primary/ocp-v4.0-art-dev@" + digest, "private/ocp-v4.0-art-dev@" + digest},
+			expected: "/primary/ocp-v4.0-art-dev@" + digest,
+		},
+		{
+			name:     "secondary mirror with anonymous token",
+			images:   []string{"private/ocp-v4.0-art-dev@" + digest},
+			expected: "/private/ocp-v4.0-art-dev@" + digest,
+		},
+		{
+			name:     "no mirror has the image",
+			expected: internalImage,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mirrorConfig = &mirrorCache{}
+			t.Cleanup(func() { mirrorConfig = &mirrorCache{} })
+			server := newMockImageRegistry(t, test.images...)
+			host := strings.TrimPrefix(server.URL, "https://")
+			idms := &configv1.ImageDigestMirrorSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "release"},
+				Spec: configv1.ImageDigestMirrorSetSpec{
+					ImageDigestMirrors: []configv1.ImageDigestMirrors{
+						{Source: "quay.io/openshift-release-dev", Mirrors: []configv1.ImageMirror{configv1.ImageMirror(host + "/primary"), configv1.ImageMirror(host + "/private")}},
+					},
+				},
+			}
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "test"},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "tools", Image: internalImage}}},
+			}
+
+			s := NewWebhook()
+			s.kubeClient, _ = newMockRegistry(ist, idms)
+			patch, _, err := s.mutatePod(context.Background(), "test", pod, nil, "")
+			if err != nil {
+				t.Fatalf("unexpected error mutating pod: %v", err)
+			}
+			mutated := applyPatch(t, pod, patch)
+			expected := test.expected
+			if expected != internalImage {
+				expected = host + expected
+			}
+			if mutated.Spec.Containers[0].Image != expected {
+				t.Errorf("expected container image %s, got %s", expected, mutated.Spec.Containers[0].Image)
+			}
+		})
+	}
+}
+
+func TestPullabilityUnknown(t *testing.T) {
+	newMockImageRegistry(t)
+	// unreachable registries, and references which aren't understood, are
+	// assumed to have the image
+	for _, image := range []string{"127.0.0.1:1/release/tools:latest", "quay.io/"} {
+		if availability := pullability.check(context.Background(), image); availability != availabilityUnknown {
+			t.Errorf("expected the availability of %s to be unknown, got %v", image, availability)
+		}
+	}
+}
+
+func TestManifestURL(t *testing.T) {
+	tests := []struct {
+		image      string
+		url        string
+		repository string
+	}{
+		{"quay.io/openshift/origin-cli:4.20", "https://quay.io/v2/openshift/origin-cli/manifests/4.20", "openshift/origin-cli"},
+		{"mirror.example.com:5000/release/tools@sha256:abc", "https://mirror.example.com:5000/v2/release/tools/manifests/sha256:abc", "release/tools"},
+		{"busybox", "https://registry-1.docker.io/v2/library/busybox/manifests/latest", "library/busybox"},
+		{"bitnami/kubectl:1.33", "https://registry-1.docker.io/v2/bitnami/kubectl/manifests/1.33", "bitnami/kubectl"},
+		{"localhost/tools:dev", "https://localhost/v2/tools/manifests/dev", "tools"},
+	}
+	for _, test := range tests {
+		url, repository, err := manifestURL(test.image)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", test.image, err)
+		}
+		if url != test.url || repository != test.repository {
+			t.Errorf("expected %s and %s for %s, got %s and %s", test.url, test.repository, test.image, url, repository)
+		}
+	}
+}
+
+func TestMutatePodArchitectureWarning(t *testing.T) {
+	const digest = "sha256:4dbe2a75a516a947eab036ef6a1d086f1b1610f6bd21c6ab5f95db68ec177ea2"
+	ist := &imagestreamv1.ImageStreamTag{
+		ObjectMeta: metav1.ObjectMeta{Name: "tools:latest", Namespace: "openshift"},
+		Tag: &imagestreamv1.TagReference{
+			From:         &corev1.ObjectReference{Kind: "DockerImage", Name: "registry.redhat.io/rhel9/support-tools:latest"},
+			ImportPolicy: imagestreamv1.TagImportPolicy{ImportMode: imagestreamv1.ImportModePreserveOriginal},
+		},
+		Image: imagestreamv1.Image{
+			ObjectMeta:           metav1.ObjectMeta{Name: digest},
+			DockerImageReference: "registry.redhat.io/rhel9/support-tools@" + digest,
+			DockerImageMetadata:  runtime.RawExtension{Raw: []byte(`{"architecture":"amd64"}`)},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		nodeSelector map[string]string
+		expected     string
+	}{
+		{
+			name:     "pod not restricted to an architecture",
+			expected: "debug=amd64",
+		},
+		{
+			name:         "pod restricted to another architecture",
+			nodeSelector: map[string]string{corev1.LabelArchStable: "arm64"},
+			expected:     "debug=amd64",
+		},
+		{
+			name:         "pod restricted to the image architecture",
+			nodeSelector: map[string]string{corev1.LabelArchStable: "amd64"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "test"},
+				Spec: corev1.PodSpec{
+					NodeSelector: test.nodeSelector,
+					Containers: []corev1.Container{
+						{Name: "debug", Image: "image-registry.openshift-image-registry.svc:5000/openshift/tools:latest"},
+					},
+				},
+			}
+			s := NewWebhook()
+			s.kubeClient, _ = newMockRegistry(ist)
+			patch, _, err := s.mutatePod(context.Background(), "test", pod, nil, "")
+			if err != nil {
+				t.Fatalf("unexpected error mutating pod: %v", err)
+			}
+			mutated := applyPatch(t, pod, patch)
+			if actual := mutated.Annotations[ArchitectureWarningAnnotation]; actual != test.expected {
+				t.Errorf("expected architecture warning %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestMirrorResolverResolve(t *testing.T) {
+	const digest = "sha256:4dbe2a75a516a947eab036ef6a1d086f1b1610f6bd21c6ab5f95db68ec177ea2"
+	r := &mirrorResolver{
+		digestMirrors: map[string][]string{
+			"quay.io/openshift-release-dev":                     {"mirror.example.com/release", "backup.example.com/release"},
+			"quay.io/openshift-release-dev/ocp-v4.0-art-dev":    {"mirror.example.com/art-dev"},
+			"quay.io/openshift-release-dev/ocp-release-nightly": {},
+		},
+		tagMirrors: map[string][]string{
+			"registry.redhat.io": {"mirror.example.com/redhat"},
+		},
+	}
+	tests := []struct {
+		ref      string
+		expected string
+	}{
+		{
+			ref:      "quay.io/openshift-release-dev/ocp-v4.0-art-dev@" + digest,
+			expected: "mirror.example.com/art-dev@" + digest,
+		},
+		{
+			ref:      "quay.io/openshift-release-dev/ocp-release@" + digest,
+			expected: "mirror.example.com/release/ocp-release@" + digest,
+		},
+		{
+			// sources only match whole path components
+			ref:      "quay.io/openshift-release-dev-fork/ocp-release@" + digest,
+			expected: "quay.io/openshift-release-dev-fork/ocp-release@" + digest,
+		},
+		{
+			// digest mirrors don't apply to tag references
+			ref:      "quay.io/openshift-release-dev/ocp-release:4.18",
+			expected: "quay.io/openshift-release-dev/ocp-release:4.18",
+		},
+		{
+			ref:      "registry.redhat.io/rhel9/support-tools:latest",
+			expected: "mirror.example.com/redhat/rhel9/support-tools:latest",
+		},
+	}
+	for _, test := range tests {
+		if actual := r.resolve(test.ref); actual != test.expected {
+			t.Errorf("resolve(%s): expected %s, got %s", test.ref, test.expected, actual)
+		}
+	}
+	if actual := (*mirrorResolver)(nil).resolve(tests[0].ref); actual != tests[0].ref {
+		t.Errorf("expected a nil resolver to leave references unchanged, got %s", actual)
+	}
+}
+
+func TestResolveImageStreamTag(t *testing.T) {
+	const digest = "sha256:4dbe2a75a516a947eab036ef6a1d086f1b1610f6bd21c6ab5f95db68ec177ea2"
+	arm64Metadata := runtime.RawExtension{Raw: []byte(`{"kind":"DockerImage","apiVersion":"1.0","architecture":"arm64"}`)}
+	tests := []struct {
+		name                 string
+		ist                  *imagestreamv1.ImageStreamTag
+		mode                 string
+		expected             string
+		expectedArchitecture string
+		expectErr            bool
+	}{
+		{
+			name: "digest mode uses the image docker reference",
+			ist: &imagestreamv1.ImageStreamTag{
+				Tag: &imagestreamv1.TagReference{
+					From: &corev1.ObjectReference{Kind: "DockerImage", Name: "quay.io/openshift/origin-cli:4.15"},
+				},
+				Image: imagestreamv1.Image{
+					ObjectMeta:           metav1.ObjectMeta{Name: digest},
+					DockerImageReference: "quay.io/openshift/origin-cli@" + digest,
+				},
+			},
+			mode:     resolutionModeDigest,
+			expected: "quay.io/openshift/origin-cli@" + digest,
+		},
+		{
+			name: "digest mode pins the image name onto the tag repository",
+			ist: &imagestreamv1.ImageStreamTag{
+				Tag: &imagestreamv1.TagReference{
+					From: &corev1.ObjectReference{Kind: "DockerImage", Name: "registry.example.com:5000/openshift/origin-cli:4.15"},
+				},
+				Image: imagestreamv1.Image{
+					ObjectMeta: metav1.ObjectMeta{Name: digest},
+				},
+			},
+			mode:     resolutionModeDigest,
+			expected: "registry.example.com:5000/openshift/origin-cli@" + digest,
+		},
+		{
+			name: "digest mode pins the manifest list digest",
+			ist: &imagestreamv1.ImageStreamTag{
+				Tag: &imagestreamv1.TagReference{
+					From:         &corev1.ObjectReference{Kind: "DockerImage", Name: "quay.io/openshift/origin-cli:4.15"},
+					ImportPolicy: imagestreamv1.TagImportPolicy{ImportMode: imagestreamv1.ImportModePreserveOriginal},
+				},
+				Image: imagestreamv1.Image{
+					ObjectMeta:           metav1.ObjectMeta{Name: digest},
+					DockerImageReference: "quay.io/openshift/origin-cli@" + digest,
+					DockerImageManifests: []imagestreamv1.ImageManifest{
+						{Digest: "sha256:1111111111111111111111111111111111111111111111111111111111111111", Architecture: "amd64", OS: "linux"},
+						{Digest: "sha256:2222222222222222222222222222222222222222222222222222222222222222", Architecture: "arm64", OS: "linux"},
+					},
+				},
+			},
+			mode:     resolutionModeDigest,
+			expected: "quay.io/openshift/origin-cli@" + digest,
+		},
+		{
+			name: "digest mode uses the tag reference of images imported for one architecture",
+			ist: &imagestreamv1.ImageStreamTag{
+				Tag: &imagestreamv1.TagReference{
+					From: &corev1.ObjectReference{Kind: "DockerImage", Name: "quay.io/openshift/origin-cli:4.15"},
+				},
+				Image: imagestreamv1.Image{
+					ObjectMeta:           metav1.ObjectMeta{Name: digest},
+					DockerImageReference: "quay.io/openshift/origin-cli@" + digest,
+					DockerImageMetadata:  arm64Metadata,
+				},
+			},
+			mode:     resolutionModeDigest,
+			expected: "quay.io/openshift/origin-cli:4.15",
+		},
+		{
+			name: "digest mode pins single architecture images",
+			ist: &imagestreamv1.ImageStreamTag{
+				Tag: &imagestreamv1.TagReference{
+					From:         &corev1.ObjectReference{Kind: "DockerImage", Name: "quay.io/openshift/origin-cli:4.15"},
+					ImportPolicy: imagestreamv1.TagImportPolicy{ImportMode: imagestreamv1.ImportModePreserveOriginal},
+				},
+				Image: imagestreamv1.Image{
+					ObjectMeta:           metav1.ObjectMeta{Name: digest},
+					DockerImageReference: "quay.io/openshift/origin-cli@" + digest,
+					DockerImageMetadata:  arm64Metadata,
+				},
+			},
+			mode:                 resolutionModeDigest,
+			expected:             "quay.io/openshift/origin-cli@" + digest,
+			expectedArchitecture: "arm64",
+		},
+		{
+			name: "digest mode falls back to the tag reference",
+			ist: &imagestreamv1.ImageStreamTag{
+				Tag: &imagestreamv1.TagReference{
+					From: &corev1.ObjectReference{Kind: "DockerImage", Name: "quay.io/openshift/origin-cli:4.15"},
+				},
+			},
+			mode:     resolutionModeDigest,
+			expected: "quay.io/openshift/origin-cli:4.15",
+		},
+		{
+			name: "tag mode uses the tag reference",
+			ist: &imagestreamv1.ImageStreamTag{
+				Tag: &imagestreamv1.TagReference{
+					From: &corev1.ObjectReference{Kind: "DockerImage", Name: "quay.io/openshift/origin-cli:4.15"},
+				},
+				Image: imagestreamv1.Image{
+					ObjectMeta:           metav1.ObjectMeta{Name: digest},
+					DockerImageReference: "quay.io/openshift/origin-cli@" + digest,
+				},
+			},
+			mode:     resolutionModeTag,
+			expected: "quay.io/openshift/origin-cli:4.15",
+		},
+		{
+			name:      "no image reference at all",
+			ist:       &imagestreamv1.ImageStreamTag{},
+			mode:      resolutionModeTag,
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, architecture, err := resolveImageStreamTag(test.ist, test.mode)
+			if (err != nil) != test.expectErr {
+				t.Fatalf("expected error: %t, got %v", test.expectErr, err)
+			}
+			if actual != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, actual)
+			}
+			if architecture != test.expectedArchitecture {
+				t.Errorf("expected architecture %q, got %q", test.expectedArchitecture, architecture)
+			}
+		})
+	}
+}
+
+func TestRewriteNamespaces(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       string
+		namespace string
+		expected  bool
+	}{
+		{
+			name:      "default allows openshift",
+			env:       "",
+			namespace: "openshift",
+			expected:  true,
+		},
+		{
+			name:      "default ignores other namespaces",
+			env:       "",
+			namespace: "openshift-monitoring",
+			expected:  false,
+		},
+		{
+			name:      "configured namespace is allowed",
+			env:       "openshift, openshift-monitoring",
+			namespace: "openshift-monitoring",
+			expected:  true,
+		},
+		{
+			name:      "configuration replaces the default",
+			env:       "openshift-monitoring",
+			namespace: "openshift",
+			expected:  false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(NamespacesEnvVar, test.env)
+			pod := &corev1.Pod{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{Image: "image-registry.openshift-image-registry.svc:5000/" + test.namespace + "/cli:latest"},
+					},
+				},
+			}
+			if actual := podContainsInternalImage(pod); actual != test.expected {
+				t.Errorf("expected %t, got %t", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestMutatePodIdempotent(t *testing.T) {
+	const internalImage = "image-registry.openshift-image-registry.svc:5000/openshift/tools:latest"
+	ist := &imagestreamv1.ImageStreamTag{
+		ObjectMeta: metav1.ObjectMeta{Name: "tools:latest", Namespace: "openshift"},
+		Tag: &imagestreamv1.TagReference{
+			From: &corev1.ObjectReference{
+				Kind: "DockerImage",
+				Name: "quay.io/openshift-release-dev/ocp-v4.0-art-dev@sha256:4dbe2a75a516a947eab036ef6a1d086f1b1610f6bd21c6ab5f95db68ec177ea2",
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		images   map[string]string
+		previous map[string]string
+		changed  bool
+		expected map[string]string
+		// originals are the original images recorded in the annotations
+		originals map[string]string
+	}{
+		{
+			name:      "create rewrites internal images",
+			images:    map[string]string{"tools": internalImage},
+			changed:   true,
+			expected:  map[string]string{"tools": ist.Tag.From.Name},
+			originals: map[string]string{OriginalImageAnnotationPrefix + "tools": internalImage},
+		},
+		{
+			name:     "already rewritten images aren't patched again",
+			images:   map[string]string{"tools": ist.Tag.From.Name},
+			changed:  false,
+			expected: map[string]string{"tools": ist.Tag.From.Name},
+		},
+		{
+			name:     "update leaves unchanged containers alone",
+			images:   map[string]string{"tools": internalImage},
+			previous: map[string]string{"tools": internalImage},
+			changed:  false,
+			expected: map[string]string{"tools": internalImage},
+		},
+		{
+			name:      "update rewrites changed containers",
+			images:    map[string]string{"tools": internalImage, "app": internalImage},
+			previous:  map[string]string{"tools": "ubuntu", "app": internalImage},
+			changed:   true,
+			expected:  map[string]string{"tools": ist.Tag.From.Name, "app": internalImage},
+			originals: map[string]string{OriginalImageAnnotationPrefix + "tools": internalImage},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test"}}
+			for name, image := range test.images {
+				pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: name, Image: image})
+			}
+
+			s := NewWebhook()
+			s.kubeClient, _ = newMockRegistry(ist)
+			patch, rewrites, err := s.mutatePod(context.Background(), "test", pod, test.previous, "")
+			if err != nil {
+				t.Fatalf("unexpected error mutating pod: %v", err)
+			}
+			changed := len(rewrites) > 0
+			if changed != test.changed {
+				t.Fatalf("expected changed %v, got %v", test.changed, changed)
+			}
+			if !changed {
+				return
+			}
+			mutated := applyPatch(t, pod, patch)
+			if actual := containerImages(mutated); !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("expected images %v, got %v", test.expected, actual)
+			}
+			if !reflect.DeepEqual(mutated.Annotations, test.originals) {
+				t.Errorf("expected original image annotations %v, got %v", test.originals, mutated.Annotations)
+			}
+			if mutated.Labels[ImageRewrittenLabel] != "true" {
+				t.Errorf("expected the pod to be labelled as rewritten, got %v", mutated.Labels)
+			}
+		})
+	}
+}
+
+func TestOriginalImageAnnotation(t *testing.T) {
+	if actual := OriginalImageAnnotation("tools"); actual != "managed.openshift.io/original-image-tools" {
+		t.Errorf("expected the annotation to be named for the container, got %s", actual)
+	}
+
+	long := strings.Repeat("a", 63)
+	actual := OriginalImageAnnotation(long)
+	if errs := validation.IsQualifiedName(actual); len(errs) > 0 {
+		t.Errorf("expected a valid annotation name for a long container name, got %s: %v", actual, errs)
+	}
+	if other := OriginalImageAnnotation(strings.Repeat("a", 62) + "b"); other == actual {
+		t.Errorf("expected long container names to keep distinct annotations, both got %s", actual)
+	}
+}
+
+func TestLookupImageStreamTagSpecStaticFallback(t *testing.T) {
+	const digest = "sha256:4dbe2a75a516a947eab036ef6a1d086f1b1610f6bd21c6ab5f95db68ec177ea2"
+	cli := &imagestreamv1.ImageStreamTag{
+		ObjectMeta: metav1.ObjectMeta{Name: "cli:latest", Namespace: "openshift"},
+		Image: imagestreamv1.Image{
+			ObjectMeta:           metav1.ObjectMeta{Name: digest},
+			DockerImageReference: "quay.io/openshift-release-dev/ocp-v4.0-art-dev@" + digest,
+		},
+	}
+	unavailable := interceptor.Funcs{
+		Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			if _, ok := obj.(*imagestreamv1.ImageStreamTag); ok {
+				return apierrors.NewServiceUnavailable("the server is currently unable to handle the request")
+			}
+			return c.Get(ctx, key, obj, opts...)
+		},
+	}
+
+	tests := []struct {
+		name        string
+		imagespec   string
+		unavailable bool
+		expected    string
+		expectErr   bool
+	}{
+		{
+			name:        "payload image falls back to the digest it resolved to",
+			imagespec:   "image-registry.openshift-image-registry.svc:5000/openshift/cli:latest",
+			unavailable: true,
+			expected:    cli.Image.DockerImageReference,
+		},
+		{
+			name:        "other tag of a payload image still errors",
+			imagespec:   "image-registry.openshift-image-registry.svc:5000/openshift/cli:4.16",
+			unavailable: true,
+			expected:    "image-registry.openshift-image-registry.svc:5000/openshift/cli:4.16",
+			expectErr:   true,
+		},
+		{
+			name:        "payload image not resolved yet falls back to its bundled pull spec",
+			imagespec:   "image-registry.openshift-image-registry.svc:5000/openshift/tools:latest",
+			unavailable: true,
+			expected:    bundledPayloadImages["openshift/tools:latest"],
+		},
+		{
+			name:        "unknown image still errors",
+			imagespec:   "image-registry.openshift-image-registry.svc:5000/openshift/unknown:latest",
+			unavailable: true,
+			expected:    "image-registry.openshift-image-registry.svc:5000/openshift/unknown:latest",
+			expectErr:   true,
+		},
+		{
+			name:      "missing ImageStreamTag isn't guessed at",
+			imagespec: "image-registry.openshift-image-registry.svc:5000/openshift/cli:latest",
+			expected:  "image-registry.openshift-image-registry.svc:5000/openshift/cli:latest",
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			staticImages = newStaticImageCache()
+			t.Cleanup(func() { staticImages = newStaticImageCache() })
+			s := NewWebhook()
+			s.kubeClient, _ = newMockRegistry(cli)
+			s.resolvePayloadImages(context.Background())
+
+			builder := fake.NewClientBuilder().WithScheme(s.kubeClient.Scheme())
+			if test.unavailable {
+				builder = builder.WithInterceptorFuncs(unavailable)
+			}
+			s.kubeClient = builder.Build()
+			actual, _, err := s.lookupImageStreamTagSpec(context.Background(), test.imagespec, nil)
+			if (err != nil) != test.expectErr {
+				t.Fatalf("expected error %v, got %v", test.expectErr, err)
+			}
+			if actual != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestLookupImageStreamTagSpecPatterns(t *testing.T) {
+	patterns = &patternCache{}
+	defer func() { patterns = &patternCache{} }()
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: config.OperatorNamespace, Name: PatternsConfigMapName},
+		Data: map[string]string{patternsKey: `
+- name: ci-registry
+  source: '^registry\.ci\.example\.com/(?P<namespace>[a-z0-9-]+)/(?P<name>\w+):(?P<tag>[\w.-]+)$'
+- name: origin-tools
+  source: '^quay\.io/openshift/origin-(?P<name>cli|tools):(?P<tag>[\w.-]+)$'
+  rewrite: 'registry.redhat.io/openshift4/ose-${name}:${tag}'
+`},
+	}
+	const digest = "sha256:4dbe2a75a516a947eab036ef6a1d086f1b1610f6bd21c6ab5f95db68ec177ea2"
+	tools := &imagestreamv1.ImageStreamTag{
+		ObjectMeta: metav1.ObjectMeta{Name: "tools:latest", Namespace: "openshift"},
+		Image: imagestreamv1.Image{
+			ObjectMeta:           metav1.ObjectMeta{Name: digest},
+			DockerImageReference: "registry.redhat.io/rhel9/support-tools@" + digest,
+		},
+	}
+	s := NewWebhook()
+	s.kubeClient, _ = newMockRegistry(cm, tools)
+	s.loadPatterns(context.Background())
+
+	tests := []struct {
+		name      string
+		imagespec string
+		expected  string
+	}{
+		{
+			name:      "image rewritten by a template",
+			imagespec: "quay.io/openshift/origin-cli:4.16",
+			expected:  "registry.redhat.io/openshift4/ose-cli:4.16",
+		},
+		{
+			name:      "image resolved through its ImageStreamTag",
+			imagespec: "registry.ci.example.com/openshift/tools:latest",
+			expected:  tools.Image.DockerImageReference,
+		},
+		{
+			name:      "image outside the rewritten namespaces",
+			imagespec: "registry.ci.example.com/customer/tools:latest",
+			expected:  "registry.ci.example.com/customer/tools:latest",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, _, err := s.lookupImageStreamTagSpec(context.Background(), test.imagespec, nil)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if actual != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, actual)
+			}
+		})
+	}
+
+	// an invalid edit keeps the patterns read last
+	cm.Data[patternsKey] = "- name: broken\n  source: '('\n"
+	if err := s.kubeClient.Update(context.Background(), cm); err != nil {
+		t.Fatalf("expected no error updating the ConfigMap, got %v", err)
+	}
+	patterns.expires = time.Time{}
+	s.loadPatterns(context.Background())
+	if _, ok := parseImage("quay.io/openshift/origin-cli:4.16"); !ok {
+		t.Errorf("expected the patterns read last to be kept")
+	}
+}
+
+func TestMutatePodPullSecret(t *testing.T) {
+	const internalImage = "image-registry.openshift-image-registry.svc:5000/openshift/tools:latest"
+	ist := &imagestreamv1.ImageStreamTag{
+		ObjectMeta: metav1.ObjectMeta{Name: "tools:latest", Namespace: "openshift"},
+		Tag: &imagestreamv1.TagReference{
+			From: &corev1.ObjectReference{Kind: "DockerImage", Name: "quay.io/openshift/tools:latest"},
+		},
+	}
+	pullSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "external-pull-secret", Namespace: "restricted"},
+		Type:       corev1.SecretTypeDockerConfigJson,
+	}
+	opaqueSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "external-pull-secret", Namespace: "opaque"},
+		Type:       corev1.SecretTypeOpaque,
+	}
+
+	tests := []struct {
+		name       string
+		configured string
+		namespace  string
+		existing   []corev1.LocalObjectReference
+		previous   map[string]string
+		expected   []corev1.LocalObjectReference
+	}{
+		{
+			name:      "no pull secret configured",
+			namespace: "restricted",
+		},
+		{
+			name:       "pull secret is referenced",
+			configured: "external-pull-secret",
+			namespace:  "restricted",
+			expected:   []corev1.LocalObjectReference{{Name: "external-pull-secret"}},
+		},
+		{
+			name:       "pull secret is added to existing references",
+			configured: "external-pull-secret",
+			namespace:  "restricted",
+			existing:   []corev1.LocalObjectReference{{Name: "other"}},
+			expected:   []corev1.LocalObjectReference{{Name: "other"}, {Name: "external-pull-secret"}},
+		},
+		{
+			name:       "pull secret is only referenced once",
+			configured: "external-pull-secret",
+			namespace:  "restricted",
+			existing:   []corev1.LocalObjectReference{{Name: "external-pull-secret"}},
+			expected:   []corev1.LocalObjectReference{{Name: "external-pull-secret"}},
+		},
+		{
+			name:       "pull secret is missing from the namespace",
+			configured: "external-pull-secret",
+			namespace:  "other",
+		},
+		{
+			name:       "secret isn't a pull secret",
+			configured: "external-pull-secret",
+			namespace:  "opaque",
+		},
+		{
+			name:       "pull secrets of existing pods aren't changed",
+			configured: "external-pull-secret",
+			namespace:  "restricted",
+			previous:   map[string]string{"tools": "ubuntu"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(PullSecretEnvVar, test.configured)
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "test"},
+				Spec: corev1.PodSpec{
+					Containers:       []corev1.Container{{Name: "tools", Image: internalImage}},
+					ImagePullSecrets: test.existing,
+				},
+			}
+
+			s := NewWebhook()
+			s.kubeClient, _ = newMockRegistry(ist, pullSecret, opaqueSecret)
+			patch, rewrites, err := s.mutatePod(context.Background(), test.namespace, pod, test.previous, "")
+			if err != nil {
+				t.Fatalf("unexpected error mutating pod: %v", err)
+			}
+			if len(rewrites) == 0 {
+				t.Fatalf("expected the pod to be mutated")
+			}
+			mutated := applyPatch(t, pod, patch)
+			expected := test.expected
+			if expected == nil {
+				expected = test.existing
+			}
+			if !reflect.DeepEqual(mutated.Spec.ImagePullSecrets, expected) {
+				t.Errorf("expected pull secrets %v, got %v", expected, mutated.Spec.ImagePullSecrets)
+			}
+		})
+	}
+}
+
+func TestOldContainerImages(t *testing.T) {
+	old := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			Containers:     []corev1.Container{{Name: "app", Image: "ubuntu"}},
+			InitContainers: []corev1.Container{{Name: "init", Image: "busybox"}},
+			EphemeralContainers: []corev1.EphemeralContainer{
+				{EphemeralContainerCommon: corev1.EphemeralContainerCommon{Name: "debug", Image: "tools"}},
+			},
+		},
+	}
+	images, err := oldContainerImages(newPodRequest(t, admissionv1.Update, &corev1.Pod{}, old))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]string{"app": "ubuntu", "init": "busybox", "debug": "tools"}
+	if !reflect.DeepEqual(images, expected) {
+		t.Errorf("expected images %v, got %v", expected, images)
+	}
+
+	if _, err := oldContainerImages(newPodRequest(t, admissionv1.Update, &corev1.Pod{}, nil)); err == nil {
+		t.Errorf("expected an error without an old object")
+	}
+}
+
+func newPodRequest(t testing.TB, operation admissionv1.Operation, pod, old *corev1.Pod) admissionctl.Request {
+	return testutils.NewRequest(t, metav1.GroupVersionKind{Version: "v1", Kind: "Pod"}, operation, authenticationv1.UserInfo{Username: "customer"}, "test", "test", pod, old)
+}
+
+// benchmarkPod is a typical customer pod, with a few containers and enough of
+// a spec that decoding and marshalling it isn't trivial
+func benchmarkPod(images ...string) *corev1.Pod {
+	pod := &corev1.Pod{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test",
+			Namespace:   "test",
+			Labels:      map[string]string{"app": "test", "tier": "backend"},
+			Annotations: map[string]string{"openshift.io/scc": "restricted-v2"},
+		},
+	}
+	for i, image := range images {
+		pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{
+			Name:    fmt.Sprintf("container-%d", i),
+			Image:   image,
+			Command: []string{"/bin/sh", "-c", "sleep infinity"},
+			Env:     []corev1.EnvVar{{Name: "LOG_LEVEL", Value: "info"}},
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("100m"),
+					corev1.ResourceMemory: resource.MustParse("128Mi"),
+				},
+			},
+			VolumeMounts: []corev1.VolumeMount{{Name: "data", MountPath: "/data"}},
+		})
+	}
+	pod.Spec.Volumes = []corev1.Volume{{Name: "data", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}}}
+	return pod
+}
+
+func BenchmarkParseImage(b *testing.B) {
+	for _, image := range []string{
+		"quay.io/app-sre/managed-cluster-validating-webhooks:latest",
+		"image-registry.openshift-image-registry.svc:5000/openshift/tools:latest",
+	} {
+		b.Run(image, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				parseImage(image)
+			}
+		})
+	}
+}
+
+func BenchmarkAuthorized(b *testing.B) {
+	const internalImage = "image-registry.openshift-image-registry.svc:5000/openshift/tools:latest"
+	ist := &imagestreamv1.ImageStreamTag{
+		ObjectMeta: metav1.ObjectMeta{Name: "tools:latest", Namespace: "openshift"},
+		Tag: &imagestreamv1.TagReference{
+			From: &corev1.ObjectReference{
+				Kind: "DockerImage",
+				Name: "quay.io/openshift-release-dev/ocp-v4.0-art-dev@sha256:4dbe2a75a516a947eab036ef6a1d086f1b1610f6bd21c6ab5f95db68ec177ea2",
+			},
+		},
+	}
+	registry := &registryv1.Config{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+		Spec:       registryv1.ImageRegistrySpec{OperatorSpec: operatorv1.OperatorSpec{ManagementState: operatorv1.Removed}},
+	}
+	tools := benchmarkPod("ubuntu", internalImage)
+
+	benchmarks := []struct {
+		name    string
+		request admissionctl.Request
+	}{
+		{
+			name:    "external images",
+			request: newPodRequest(b, admissionv1.Create, benchmarkPod("ubuntu", "quay.io/app-sre/app:latest"), nil),
+		},
+		{
+			name:    "internal image rewritten",
+			request: newPodRequest(b, admissionv1.Create, tools, nil),
+		},
+		{
+			name:    "update of unchanged internal image",
+			request: newPodRequest(b, admissionv1.Update, tools, tools),
+		},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			b.Cleanup(func() { registryStatus = &registryStatusCache{} })
+			s := NewWebhook()
+			s.kubeClient, _ = newMockRegistry(ist, registry)
+			b.ReportAllocs()
+			for b.Loop() {
+				s.Authorized(bm.request)
+			}
+		})
+	}
+}
+
+func TestAuthorizedRecordsEvents(t *testing.T) {
+	t.Cleanup(func() {
+		registryStatus = &registryStatusCache{}
+		events.SetRecorder(nil)
+	})
+	ist := &imagestreamv1.ImageStreamTag{
+		ObjectMeta: metav1.ObjectMeta{Name: "tools:latest", Namespace: "openshift"},
+		Tag: &imagestreamv1.TagReference{
+			From: &corev1.ObjectReference{Kind: "DockerImage", Name: "quay.io/openshift/tools:latest"},
+		},
+	}
+	registry := &registryv1.Config{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+		Spec:       registryv1.ImageRegistrySpec{OperatorSpec: operatorv1.OperatorSpec{ManagementState: operatorv1.Removed}},
+	}
+	eventClient := fake.NewClientBuilder().Build()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	recorder := events.NewRecorder(eventClient)
+	recorder.Start(ctx)
+	events.SetRecorder(recorder)
+
+	s := NewWebhook()
+	s.kubeClient, _ = newMockRegistry(ist, registry)
+	pod := benchmarkPod("ubuntu", "image-registry.openshift-image-registry.svc:5000/openshift/tools:latest")
+
+	dryRun := true
+	request := newPodRequest(t, admissionv1.Create, pod, nil)
+	request.DryRun = &dryRun
+	dryRunResponse := s.Authorized(request)
+	if len(dryRunResponse.Patches) == 0 {
+		t.Fatalf("expected the dry run to be patched")
+	}
+	response := s.Authorized(newPodRequest(t, admissionv1.Create, pod, nil))
+	if len(response.Patches) == 0 {
+		t.Fatalf("expected the pod to be patched")
+	}
+
+	list := &corev1.EventList{}
+	err := wait.PollUntilContextTimeout(ctx, 10*time.Millisecond, 5*time.Second, true, func(ctx context.Context) (bool, error) {
+		if err := eventClient.List(ctx, list); err != nil {
+			return false, err
+		}
+		return len(list.Items) > 0, nil
+	})
+	if err != nil {
+		t.Fatalf("expected an Event to be recorded: %v", err)
+	}
+	if len(list.Items) != 1 {
+		t.Fatalf("expected one Event, not one for the dry run, got %d", len(list.Items))
+	}
+	event := list.Items[0]
+	if event.Namespace != "test" || event.InvolvedObject.Name != "test" || event.Reason != imageRewrittenReason {
+		t.Errorf("unexpected Event %+v", event)
+	}
+	if !strings.Contains(event.Message, "container-1") || !strings.Contains(event.Message, "quay.io/openshift/tools:latest") {
+		t.Errorf("expected the Event to name the container and its new image, got %s", event.Message)
+	}
+	// the client is warned of the rewrite, dry run or not
+	for _, r := range []admissionctl.Response{dryRunResponse, response} {
+		if len(r.Warnings) != 1 || r.Warnings[0] != "The internal image registry is not available, so container container-1 runs quay.io/openshift/tools:latest" {
+			t.Errorf("expected a warning explaining the rewrite, got %q", r.Warnings)
+		}
+	}
+}
+
+func TestPodPatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		pod      *corev1.Pod
+		expected *corev1.Pod
+	}{
+		{
+			name: "adds the maps and pull secrets",
+			pod: &corev1.Pod{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "image-registry.openshift-image-registry.svc:5000/test/app:latest"}}},
+			},
+			expected: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{OriginalImageAnnotation("app"): "image-registry.openshift-image-registry.svc:5000/test/app:latest", "example.com/a~b": "c"},
+					Labels:      map[string]string{ImageRewrittenLabel: "true"},
+				},
+				Spec: corev1.PodSpec{
+					Containers:       []corev1.Container{{Name: "app", Image: "quay.io/test/app@sha256:abc"}},
+					ImagePullSecrets: []corev1.LocalObjectReference{{Name: "mirror-pull-secret"}},
+				},
+			},
+		},
+		{
+			name: "adds to existing maps and pull secrets",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{"owner": "payments"},
+					Labels:      map[string]string{ImageRewrittenLabel: "false"},
+				},
+				Spec: corev1.PodSpec{
+					Containers:       []corev1.Container{{Name: "app", Image: "image-registry.openshift-image-registry.svc:5000/test/app:latest"}},
+					ImagePullSecrets: []corev1.LocalObjectReference{{Name: "existing"}},
+				},
+			},
+			expected: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{"owner": "payments", OriginalImageAnnotation("app"): "image-registry.openshift-image-registry.svc:5000/test/app:latest", "example.com/a~b": "c"},
+					Labels:      map[string]string{ImageRewrittenLabel: "true"},
+				},
+				Spec: corev1.PodSpec{
+					Containers:       []corev1.Container{{Name: "app", Image: "quay.io/test/app@sha256:abc"}},
+					ImagePullSecrets: []corev1.LocalObjectReference{{Name: "existing"}, {Name: "mirror-pull-secret"}},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := newPodPatch(test.pod)
+			p.replaceImage("containers", 0, "quay.io/test/app@sha256:abc")
+			p.setAnnotation(OriginalImageAnnotation("app"), test.pod.Spec.Containers[0].Image)
+			p.setAnnotation("example.com/a~b", "c")
+			p.setLabel(ImageRewrittenLabel, "true")
+			p.addPullSecret("mirror-pull-secret")
+
+			mutated := applyPatch(t, test.pod, p.operations)
+			if !reflect.DeepEqual(mutated, test.expected) {
+				t.Errorf("expected pod %+v, got %+v", test.expected, mutated)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package podimagespec
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// PullabilityCheckEnvVar enables checking that the registry has the image
+	// a pod is rewritten to, when set to "true". Images missing from the
+	// first mirror are rewritten to the next mirror having them, and
+	// containers whose image no mirror has keep their original image rather
+	// than being stuck in ImagePullBackOff.
+	PullabilityCheckEnvVar string = "PODIMAGESPEC_PULLABILITY_CHECK"
+
+	// manifestAccept are the manifest media types the check asks for, so
+	// registries answer for manifest lists as well as single images
+	manifestAccept string = "application/vnd.oci.image.index.v1+json, application/vnd.docker.distribution.manifest.list.v2+json, application/vnd.oci.image.manifest.v1+json, application/vnd.docker.distribution.manifest.v2+json"
+
+	// maxPullabilityEntries bounds the cached results; the cache is emptied
+	// when it's full
+	maxPullabilityEntries = 1000
+)
+
+var (
+	// pullabilityTimeout bounds each registry check, so a slow registry
+	// leaves enough of the webhook's timeout to answer
+	pullabilityTimeout = 500 * time.Millisecond
+
+	// pullabilityTTL is how long the result of a registry check is used
+	pullabilityTTL = time.Minute
+
+	// pullability checks the images pods are rewritten to
+	pullability = newPullabilityChecker(&http.Client{})
+)
+
+// imageAvailability is what a registry says of an image
+type imageAvailability int
+
+const (
+	// availabilityUnknown is used when the registry couldn't be asked, or
+	// wouldn't answer anonymously. The image is assumed to exist, as it was
+	// before the check.
+	availabilityUnknown imageAvailability = iota
+	availabilityPresent
+	availabilityMissing
+)
+
+// pullabilityChecker asks registries whether they have images, caching their
+// answers for pullabilityTTL
+type pullabilityChecker struct {
+	client *http.Client
+
+	mu      sync.Mutex
+	entries map[string]pullabilityEntry
+}
+
+type pullabilityEntry struct {
+	availability imageAvailability
+	expires      time.Time
+}
+
+func newPullabilityChecker(c *http.Client) *pullabilityChecker {
+	return &pullabilityChecker{client: c, entries: map[string]pullabilityEntry{}}
+}
+
+// pullabilityCheckEnabled returns true if rewritten images are checked
+func pullabilityCheckEnabled() bool {
+	return strings.TrimSpace(os.Getenv(PullabilityCheckEnvVar)) == "true"
+}
+
+// pullableImage returns ref pointed at the first of its mirrors whose registry
+// doesn't say it's missing the image, or original if every one is missing.
+// Without the check enabled it's pointed at the first mirror unchecked.
+func (s *PodImageSpecWebhook) pullableImage(ctx context.Context, mirrors *mirrorResolver, ref, original string) string {
+	if !pullabilityCheckEnabled() {
+		return mirrors.resolve(ref)
+	}
+	for _, candidate := range mirrors.candidates(ref) {
+		if pullability.check(ctx, candidate) != availabilityMissing {
+			return candidate
+		}
+		log.Info("Registry doesn't have the rewritten image, trying the next mirror", "image", candidate)
+	}
+	log.Info("No registry has the rewritten image, keeping the original image", "image", ref, "original", original)
+	return original
+}
+
+// check returns whether the image's registry has it, from the cache if the
+// registry was asked within pullabilityTTL
+func (c *pullabilityChecker) check(ctx context.Context, image string) imageAvailability {
+	now := time.Now()
+	c.mu.Lock()
+	entry, ok := c.entries[image]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.availability
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, pullabilityTimeout)
+	defer cancel()
+	availability, err := c.head(ctx, image)
+	if err != nil {
+		log.Info("Couldn't check the registry has the rewritten image, assuming it does", "image", image, "error", err.Error())
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxPullabilityEntries {
+		c.entries = map[string]pullabilityEntry{}
+	}
+	c.entries[image] = pullabilityEntry{availability: availability, expires: now.Add(pullabilityTTL)}
+	return availability
+}
+
+// head asks the registry for the image's manifest with a HEAD request, as
+// an anonymous user. Registries requiring a bearer token are asked for an
+// anonymous one; those which won't grant it leave the image unknown.
+func (c *pullabilityChecker) head(ctx context.Context, image string) (imageAvailability, error) {
+	manifestURL, repository, err := manifestURL(image)
+	if err != nil {
+		return availabilityUnknown, err
+	}
+
+	resp, err := c.do(ctx, manifestURL, "")
+	if err != nil {
+		return availabilityUnknown, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		token, err := c.anonymousToken(ctx, resp.Header.Get("WWW-Authenticate"), repository)
+		if err != nil {
+			return availabilityUnknown, err
+		}
+		if resp, err = c.do(ctx, manifestURL, token); err != nil {
+			return availabilityUnknown, err
+		}
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return availabilityPresent, nil
+	case http.StatusNotFound:
+		return availabilityMissing, nil
+	default:
+		return availabilityUnknown, fmt.Errorf("registry answered %s", resp.Status)
+	}
+}
+
+// do sends the HEAD request for the manifest, with the token if it's set
+func (c *pullabilityChecker) do(ctx context.Context, manifestURL, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, manifestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", manifestAccept)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	_ = resp.Body.Close()
+	return resp, nil
+}
+
+// anonymousToken requests a pull token for the repository from the realm of
+// the registry's bearer challenge, without credentials
+func (c *pullabilityChecker) anonymousToken(ctx context.Context, challenge, repository string) (string, error) {
+	params := bearerChallenge(challenge)
+	realm := params["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("registry requires authentication: %q", challenge)
+	}
+	tokenURL, err := url.Parse(realm)
+	if err != nil {
+		return "", fmt.Errorf("invalid token realm %q: %w", realm, err)
+	}
+	query := tokenURL.Query()
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	query.Set("scope", "repository:"+repository+":pull")
+	tokenURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenURL.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token realm answered %s", resp.Status)
+	}
+	body := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("invalid token response: %w", err)
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	if body.AccessToken != "" {
+		return body.AccessToken, nil
+	}
+	return "", fmt.Errorf("token realm granted no token")
+}
+
+// bearerChallenge returns the parameters of a Bearer WWW-Authenticate
+// challenge, or none if it's another scheme
+func bearerChallenge(challenge string) map[string]string {
+	params := map[string]string{}
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(challenge), " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return params
+	}
+	for _, param := range strings.Split(rest, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if ok {
+			params[strings.ToLower(key)] = strings.Trim(value, `"`)
+		}
+	}
+	return params
+}
+
+// manifestURL returns the registry API URL of the image's manifest, and its
+// repository. Images without a registry hostname are on Docker Hub.
+func manifestURL(image string) (string, string, error) {
+	name, reference := image, "latest"
+	if i := strings.Index(name, "@"); i != -1 {
+		name, reference = name[:i], name[i+1:]
+	} else if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, reference = name[:i], name[i+1:]
+	}
+
+	host, repository, ok := strings.Cut(name, "/")
+	if !ok || (!strings.ContainsAny(host, ".:") && host != "localhost") {
+		host, repository = "docker.io", name
+	}
+	if host == "docker.io" {
+		host = "registry-1.docker.io"
+		if !strings.Contains(repository, "/") {
+			repository = "library/" + repository
+		}
+	}
+	if repository == "" || reference == "" {
+		return "", "", fmt.Errorf("invalid image reference %q", image)
+	}
+	return "https://" + host + "/v2/" + repository + "/manifests/" + reference, repository, nil
+}
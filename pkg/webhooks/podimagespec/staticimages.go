@@ -0,0 +1,76 @@
+package podimagespec
+
+import (
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/imagespec"
+)
+
+//go:generate go run ./generate/payloadimages.go
+
+// payloadImages are the images of debug tooling in the internal registry,
+// as namespace/name:tag, which the release payload imports. When their
+// ImageStreamTag can't be read, eg while the OpenShift API server is
+// unavailable, they're rewritten to the digests they last resolved to or,
+// before they've been resolved, to the pull specs in bundledPayloadImages, so
+// debug tooling still resolves. The bundled pull specs are those of the
+// release payloadimages.go was generated from, or the product images'
+// floating tags, rather than the cluster's own release, so the images
+// resolved on the cluster are preferred.
+var payloadImages = []string{
+	"openshift/cli:latest",
+	"openshift/must-gather:latest",
+	"openshift/tools:latest",
+}
+
+// staticImages holds the digests the payload images last resolved to. It's
+// shared by every PodImageSpecWebhook because the dispatcher builds a new
+// webhook for each request.
+var staticImages = newStaticImageCache()
+
+// staticImageCache holds the digest pull specs of the payload images, keyed
+// by namespace/name:tag
+type staticImageCache struct {
+	mu     sync.Mutex
+	images map[string]string
+}
+
+func newStaticImageCache() *staticImageCache {
+	return &staticImageCache{images: map[string]string{}}
+}
+
+// payloadImageKey returns the key of a payload image, and whether ref is one
+func payloadImageKey(ref imagespec.Reference) (string, bool) {
+	key := ref.Namespace + "/" + ref.ImageStreamTag()
+	return key, slices.Contains(payloadImages, key)
+}
+
+// get returns the digest pull spec ref last resolved to, or its bundled pull
+// spec if it hasn't been resolved, if it's a payload image
+func (c *staticImageCache) get(ref imagespec.Reference) (string, bool) {
+	key, ok := payloadImageKey(ref)
+	if !ok {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if imageURI, ok := c.images[key]; ok {
+		return imageURI, true
+	}
+	imageURI, ok := bundledPayloadImages[key]
+	return imageURI, ok
+}
+
+// set stores the image ref resolved to, if it's a payload image and the image
+// is pinned by digest. Tags may have moved by the time the image is used.
+func (c *staticImageCache) set(ref imagespec.Reference, imageURI string) {
+	key, ok := payloadImageKey(ref)
+	if !ok || !strings.Contains(imageURI, "@sha256:") {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.images[key] = imageURI
+}
@@ -0,0 +1,360 @@
+package podresources
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	hookconfig "github.com/openshift/managed-cluster-validating-webhooks/pkg/config"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "podresources-validation"
+	docString   string = `Managed OpenShift customers may not create pods in customer namespaces with a container requesting more %s than any node in the cluster can allocate, as such pods could never be scheduled. Larger nodes an autoscaler has yet to add from a machine pool scaled to zero are not considered.`
+)
+
+var (
+	scope = admissionregv1.NamespacedScope
+	rules = []admissionregv1.RuleWithOperations{
+		{
+			// A pod's requests can only be resized once it's scheduled
+			Operations: []admissionregv1.OperationType{admissionregv1.Create},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{""},
+				APIVersions: []string{"v1"},
+				Resources:   []string{"pods"},
+				Scope:       &scope,
+			},
+		},
+	}
+	log = logf.Log.WithName(WebhookName)
+
+	// checkedResources are the requests compared with the nodes' allocatable.
+	// Extended resources, such as GPUs, are left to the scheduler, as the nodes
+	// providing them are commonly added on demand.
+	checkedResources = []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory, corev1.ResourceEphemeralStorage}
+)
+
+// PodResourcesWebhook prevents pods whose requests no node can satisfy
+type PodResourcesWebhook struct {
+	s          *runtime.Scheme
+	decoder    admissionctl.Decoder
+	kubeClient client.Client
+}
+
+// NewWebhook creates a new webhook
+func NewWebhook() *PodResourcesWebhook {
+	scheme, err := k8sutil.SharedScheme()
+	if err != nil {
+		log.Error(err, "Fail getting the shared scheme for PodResourcesWebhook")
+		os.Exit(1)
+	}
+	decoder, err := k8sutil.SharedDecoder()
+	if err != nil {
+		log.Error(err, "Fail getting the shared decoder for PodResourcesWebhook")
+		os.Exit(1)
+	}
+
+	return &PodResourcesWebhook{
+		s:       scheme,
+		decoder: decoder,
+	}
+}
+
+// InjectClient implements ClientWebhook interface
+func (s *PodResourcesWebhook) InjectClient(c client.Client) {
+	s.kubeClient = c
+}
+
+// CachedObjects implements ClientWebhook interface. Nodes are read for their
+// allocatable resources on every pod creation, so they're cached.
+func (s *PodResourcesWebhook) CachedObjects() []client.Object {
+	return []client.Object{&corev1.Node{}}
+}
+
+// Authorized implements Webhook interface
+func (s *PodResourcesWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.AuthorizedContext(context.Background(), request)
+}
+
+// AuthorizedContext implements ContextWebhook interface
+func (s *PodResourcesWebhook) AuthorizedContext(ctx context.Context, request admissionctl.Request) admissionctl.Response {
+	return s.authorized(ctx, request)
+}
+
+func (s *PodResourcesWebhook) authorized(ctx context.Context, request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if s.ExcludedNamespaces().Excludes(request.Namespace) {
+		ret = admissionctl.Allowed("Pods in managed namespaces are not checked")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	// Pods created by controllers, such as for a customer's Deployment, are
+	// created by privileged service accounts, so only SRE and cluster admins
+	// are allowed, for example to wait on nodes an autoscaler will add
+	if identity.IsSRE(request.UserInfo) || identity.IsClusterAdmin(request.UserInfo) {
+		ret = admissionctl.Allowed("SRE and cluster admins may create pods with any requests")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	pod := &corev1.Pod{}
+	if err := s.decoder.DecodeRaw(request.Object, pod); err != nil {
+		log.Error(err, "Couldn't render a Pod from the incoming request")
+		ret = admissionctl.Errored(http.StatusBadRequest, err)
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	nodes, err := s.nodes(ctx)
+	if err != nil {
+		// Fail open, as the webhook's FailurePolicy does
+		log.Error(err, "Failed to list nodes to check the pod's requests")
+		ret = admissionctl.Allowed("Unable to list nodes to check the pod's requests")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	if len(nodes) == 0 {
+		// Hosted clusters may have no nodes yet
+		ret = admissionctl.Allowed("No nodes to check the pod's requests against")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	oversized := unsatisfiable(pod, nodes)
+	if len(oversized) == 0 {
+		ret = admissionctl.Allowed("A node can satisfy the requests of each container")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	log.Info("Denying pod with requests no node can satisfy", "namespace", request.Namespace, "user", request.UserInfo.Username, "containers", oversized)
+	ret = response.Denied(response.RequestsExceedNodeAllocatable, fmt.Sprintf("Prevented from creating a pod which could never be scheduled: %s, but the most any node can allocate is %s. Lower the requests, or add a machine pool with larger nodes. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", strings.Join(oversized, "; "), formatResources(largestAllocatable(nodes))))
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// unsatisfiable describes the containers of the pod whose requests no single
+// node can satisfy
+func unsatisfiable(pod *corev1.Pod, nodes []corev1.Node) []string {
+	oversized := []string{}
+	containers := append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+	for _, container := range containers {
+		requests := checkedRequests(container.Resources.Requests)
+		if len(requests) == 0 || anyNodeFits(requests, nodes) {
+			continue
+		}
+		oversized = append(oversized, fmt.Sprintf("container %s requests %s", container.Name, formatResources(requests)))
+	}
+	return oversized
+}
+
+// checkedRequests returns the checked resources among the requests
+func checkedRequests(requests corev1.ResourceList) corev1.ResourceList {
+	checked := corev1.ResourceList{}
+	for _, name := range checkedResources {
+		if quantity, ok := requests[name]; ok && !quantity.IsZero() {
+			checked[name] = quantity
+		}
+	}
+	return checked
+}
+
+// anyNodeFits returns true if one of the nodes can allocate all of the
+// requests
+func anyNodeFits(requests corev1.ResourceList, nodes []corev1.Node) bool {
+	for _, node := range nodes {
+		if fits(requests, node.Status.Allocatable) {
+			return true
+		}
+	}
+	return false
+}
+
+// fits returns true if the allocatable resources cover every request
+func fits(requests, allocatable corev1.ResourceList) bool {
+	for name, quantity := range requests {
+		available, ok := allocatable[name]
+		if !ok || quantity.Cmp(available) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// largestAllocatable returns the largest allocatable amount of each checked
+// resource among the nodes, which may each come from a different node
+func largestAllocatable(nodes []corev1.Node) corev1.ResourceList {
+	largest := corev1.ResourceList{}
+	for _, node := range nodes {
+		for _, name := range checkedResources {
+			available, ok := node.Status.Allocatable[name]
+			if !ok {
+				continue
+			}
+			if current, ok := largest[name]; !ok || available.Cmp(current) > 0 {
+				largest[name] = available
+			}
+		}
+	}
+	return largest
+}
+
+// formatResources renders the checked resources of the list as name=quantity
+// pairs, in the order of checkedResources
+func formatResources(resources corev1.ResourceList) string {
+	pairs := []string{}
+	for _, name := range checkedResources {
+		if quantity, ok := resources[name]; ok {
+			pairs = append(pairs, fmt.Sprintf("%s=%s", name, formatQuantity(quantity)))
+		}
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// formatQuantity renders byte quantities, which nodes report in Ki, in the
+// largest binary unit they're a whole number of, so they can be compared with
+// the requests at a glance
+func formatQuantity(quantity resource.Quantity) string {
+	if quantity.Format != resource.BinarySI {
+		return quantity.String()
+	}
+	value := quantity.Value()
+	unit := ""
+	for _, next := range []string{"Ki", "Mi", "Gi", "Ti"} {
+		if value == 0 || value%1024 != 0 {
+			break
+		}
+		value /= 1024
+		unit = next
+	}
+	if unit == "" {
+		return quantity.String()
+	}
+	return fmt.Sprintf("%d%s", value, unit)
+}
+
+// nodes lists the nodes of the cluster
+func (s *PodResourcesWebhook) nodes(ctx context.Context) ([]corev1.Node, error) {
+	if s.kubeClient == nil {
+		var err error
+		s.kubeClient, err = k8sutil.KubeClient(s.s)
+		if err != nil {
+			return nil, err
+		}
+	}
+	nodes := &corev1.NodeList{}
+	if err := s.kubeClient.List(ctx, nodes); err != nil {
+		return nil, err
+	}
+	return nodes.Items, nil
+}
+
+// GetURI implements Webhook interface
+func (s *PodResourcesWebhook) GetURI() string {
+	return "/" + WebhookName
+}
+
+// Validate implements Webhook interface
+func (s *PodResourcesWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	valid = valid && (request.Kind.Kind == "Pod")
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *PodResourcesWebhook) Name() string {
+	return WebhookName
+}
+
+// FailurePolicy implements Webhook interface
+func (s *PodResourcesWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *PodResourcesWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *PodResourcesWebhook) Rules() []admissionregv1.RuleWithOperations {
+	return rules
+}
+
+// ExcludedNamespaces implements NamespaceExclusionWebhook interface
+func (s *PodResourcesWebhook) ExcludedNamespaces() utils.NamespaceExclusion {
+	return hookconfig.ExcludedNamespaces
+}
+
+// ObjectSelector implements Webhook interface
+func (s *PodResourcesWebhook) ObjectSelector() *metav1.LabelSelector {
+	return nil
+}
+
+// SideEffects implements Webhook interface
+func (s *PodResourcesWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *PodResourcesWebhook) TimeoutSeconds() int32 {
+	return 2
+}
+
+// Doc implements Webhook interface
+func (s *PodResourcesWebhook) Doc() string {
+	return fmt.Sprintf(docString, resourceNames())
+}
+
+// RuleDocs implements Webhook interface
+func (s *PodResourcesWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    fmt.Sprintf("Customers may not create pods in customer namespaces with a container requesting more %s than any node can allocate.", resourceNames()),
+			Exceptions: []string{"Red Hat SRE and the cluster's built-in administrators", "Pods in managed namespaces", "Clusters without nodes"},
+		},
+	}
+}
+
+// resourceNames renders the checked resources for the documentation
+func resourceNames() string {
+	names := make([]string, 0, len(checkedResources))
+	for _, name := range checkedResources {
+		names = append(names, string(name))
+	}
+	return strings.Join(names, ", ")
+}
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+func (s *PodResourcesWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// ClassicEnabled implements Webhook interface
+func (s *PodResourcesWebhook) ClassicEnabled() bool {
+	return true
+}
+
+// HypershiftEnabled implements Webhook interface
+func (s *PodResourcesWebhook) HypershiftEnabled() bool {
+	return true
+}
@@ -0,0 +1,199 @@
+package podresources
+
+import (
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+)
+
+func newRequest(t *testing.T, namespace, username string, groups []string, pod *corev1.Pod) admissionctl.Request {
+	t.Helper()
+	pod.Namespace = namespace
+	return testutils.NewRequest(t, metav1.GroupVersionKind{Version: "v1", Kind: "Pod"}, admissionv1.Create, authenticationv1.UserInfo{Username: username, Groups: groups}, namespace, "", pod, nil)
+}
+
+func newNode(name, cpu, memory string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{Allocatable: corev1.ResourceList{
+			corev1.ResourceCPU:              resource.MustParse(cpu),
+			corev1.ResourceMemory:           resource.MustParse(memory),
+			corev1.ResourceEphemeralStorage: resource.MustParse("100Gi"),
+		}},
+	}
+}
+
+func podRequesting(cpu, memory string) *corev1.Pod {
+	requests := corev1.ResourceList{}
+	if cpu != "" {
+		requests[corev1.ResourceCPU] = resource.MustParse(cpu)
+	}
+	if memory != "" {
+		requests[corev1.ResourceMemory] = resource.MustParse(memory)
+	}
+	return &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{
+		{Name: "sidecar"},
+		{Name: "app", Resources: corev1.ResourceRequirements{Requests: requests}},
+	}}}
+}
+
+func TestAuthorized(t *testing.T) {
+	// The most CPU and the most memory are on different nodes
+	nodes := []*corev1.Node{
+		newNode("compute", "15500m", "60Gi"),
+		newNode("memory", "7500m", "120Gi"),
+	}
+	controller := "system:serviceaccount:kube-system:replicaset-controller"
+	controllerGroups := []string{"system:serviceaccounts", "system:serviceaccounts:kube-system"}
+
+	tests := []struct {
+		name      string
+		namespace string
+		username  string
+		groups    []string
+		nodes     []*corev1.Node
+		pod       *corev1.Pod
+		allowed   bool
+		message   string
+	}{
+		{
+			name:      "pod without requests",
+			namespace: "customer",
+			username:  controller,
+			groups:    controllerGroups,
+			nodes:     nodes,
+			pod:       podRequesting("", ""),
+			allowed:   true,
+		},
+		{
+			name:      "requests fitting a node",
+			namespace: "customer",
+			username:  controller,
+			groups:    controllerGroups,
+			nodes:     nodes,
+			pod:       podRequesting("12", "48Gi"),
+			allowed:   true,
+		},
+		{
+			name:      "too much cpu",
+			namespace: "customer",
+			username:  controller,
+			groups:    controllerGroups,
+			nodes:     nodes,
+			pod:       podRequesting("64", ""),
+			allowed:   false,
+			message:   "container app requests cpu=64, but the most any node can allocate is cpu=15500m, memory=120Gi, ephemeral-storage=100Gi",
+		},
+		{
+			name:      "cpu and memory each fitting a different node",
+			namespace: "customer",
+			username:  controller,
+			groups:    controllerGroups,
+			nodes:     nodes,
+			pod:       podRequesting("12", "100Gi"),
+			allowed:   false,
+			message:   "container app requests cpu=12, memory=100Gi",
+		},
+		{
+			name:      "init container requesting too much memory",
+			namespace: "customer",
+			username:  "customer",
+			nodes:     nodes,
+			pod: &corev1.Pod{Spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{{Name: "migrate", Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Gi")}}}},
+				Containers:     []corev1.Container{{Name: "app"}},
+			}},
+			allowed: false,
+			message: "container migrate requests memory=256Gi",
+		},
+		{
+			name:      "no nodes",
+			namespace: "customer",
+			username:  controller,
+			groups:    controllerGroups,
+			pod:       podRequesting("64", ""),
+			allowed:   true,
+		},
+		{
+			name:      "managed namespace",
+			namespace: "openshift-monitoring",
+			username:  controller,
+			groups:    controllerGroups,
+			nodes:     nodes,
+			pod:       podRequesting("64", ""),
+			allowed:   true,
+		},
+		{
+			name:      "cluster admin",
+			namespace: "customer",
+			username:  "kube:admin",
+			nodes:     nodes,
+			pod:       podRequesting("64", ""),
+			allowed:   true,
+		},
+		{
+			name:      "sre",
+			namespace: "customer",
+			username:  "backplane-cluster-admin",
+			nodes:     nodes,
+			pod:       podRequesting("64", ""),
+			allowed:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			builder := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme)
+			for _, node := range test.nodes {
+				builder = builder.WithObjects(node.DeepCopy())
+			}
+			hook := NewWebhook()
+			hook.InjectClient(builder.Build())
+
+			request := newRequest(t, test.namespace, test.username, test.groups, test.pod)
+			if !hook.Validate(request) {
+				t.Fatalf("Expected request to be valid")
+			}
+			ret := hook.Authorized(request)
+			if ret.Allowed != test.allowed {
+				t.Fatalf("Expected allowed %v, got %v: %v", test.allowed, ret.Allowed, ret.Result)
+			}
+			if ret.UID != request.UID {
+				t.Fatalf("Expected UID %s, got %s", request.UID, ret.UID)
+			}
+			if !test.allowed && response.CodeOf(ret) != response.RequestsExceedNodeAllocatable {
+				t.Errorf("Expected code %s, got %s", response.RequestsExceedNodeAllocatable, response.CodeOf(ret))
+			}
+			if test.message != "" && !strings.Contains(ret.Result.Message, test.message) {
+				t.Errorf("Expected the message to contain %q, got %s", test.message, ret.Result.Message)
+			}
+		})
+	}
+}
+
+func TestFormatQuantity(t *testing.T) {
+	tests := map[string]string{
+		"15500m":     "15500m",
+		"64":         "64",
+		"65536Ki":    "64Mi",
+		"62914560Ki": "60Gi",
+		"15877148Ki": "15877148Ki",
+		"0":          "0",
+	}
+	for in, expected := range tests {
+		if got := formatQuantity(resource.MustParse(in)); got != expected {
+			t.Errorf("Expected %s to be formatted %s, got %s", in, expected, got)
+		}
+	}
+}
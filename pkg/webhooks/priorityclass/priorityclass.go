@@ -0,0 +1,172 @@
+package priorityclass
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "priorityclass-validation"
+	docString   string = `Managed OpenShift customers may not modify or delete the cluster-critical PriorityClasses (%s), which control-plane and managed pods depend on.`
+)
+
+var (
+
+	// protectedPriorityClasses are the PriorityClasses customers may not change
+	protectedPriorityClasses = []string{
+		"system-cluster-critical",
+		"system-node-critical",
+		"openshift-user-critical",
+	}
+
+	log = logf.Log.WithName(WebhookName)
+
+	scope = admissionregv1.ClusterScope
+	rules = []admissionregv1.RuleWithOperations{
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Update, admissionregv1.Delete},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{"scheduling.k8s.io"},
+				APIVersions: []string{"*"},
+				Resources:   []string{"priorityclasses"},
+				Scope:       &scope,
+			},
+		},
+	}
+)
+
+// PriorityClassWebhook protects the cluster-critical PriorityClasses
+type PriorityClassWebhook struct {
+	s *runtime.Scheme
+}
+
+// NewWebhook creates a new webhook
+func NewWebhook() *PriorityClassWebhook {
+	scheme := runtime.NewScheme()
+	err := admissionv1.AddToScheme(scheme)
+	if err != nil {
+		log.Error(err, "Fail adding admissionv1 scheme to PriorityClassWebhook")
+		os.Exit(1)
+	}
+
+	return &PriorityClassWebhook{
+		s: scheme,
+	}
+}
+
+// Authorized implements Webhook interface
+func (s *PriorityClassWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.authorized(request)
+}
+
+func (s *PriorityClassWebhook) authorized(request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if !slices.Contains(protectedPriorityClasses, request.Name) {
+		ret = admissionctl.Allowed("Only cluster-critical PriorityClasses are protected")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	if isAdmin(request) {
+		ret = admissionctl.Allowed("Admins and managed service accounts may change cluster-critical PriorityClasses")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	log.Info("Denying change to cluster-critical PriorityClass", "name", request.Name, "operation", request.Operation, "user", request.UserInfo.Username)
+	ret = response.Denied(response.ClusterCriticalPriorityClass, fmt.Sprintf("Prevented from %s the cluster-critical PriorityClass %s, which Red Hat managed pods depend on. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", operationVerb(request.Operation), request.Name))
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// operationVerb describes the operation in a denial
+func operationVerb(operation admissionv1.Operation) string {
+	if operation == admissionv1.Delete {
+		return "deleting"
+	}
+	return "modifying"
+}
+
+// isAdmin checks for cluster admins, SRE and the service accounts managing the
+// cluster
+func isAdmin(request admissionctl.Request) bool {
+	return identity.IsAdmin(request.UserInfo)
+}
+
+// GetURI implements Webhook interface
+func (s *PriorityClassWebhook) GetURI() string { return "/" + WebhookName }
+
+// Validate implements Webhook interface
+func (s *PriorityClassWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	valid = valid && (request.Kind.Kind == "PriorityClass")
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *PriorityClassWebhook) Name() string { return WebhookName }
+
+// FailurePolicy implements Webhook interface
+func (s *PriorityClassWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *PriorityClassWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *PriorityClassWebhook) Rules() []admissionregv1.RuleWithOperations { return rules }
+
+// ObjectSelector implements Webhook interface
+func (s *PriorityClassWebhook) ObjectSelector() *metav1.LabelSelector { return nil }
+
+// SideEffects implements Webhook interface
+func (s *PriorityClassWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *PriorityClassWebhook) TimeoutSeconds() int32 { return 2 }
+
+// Doc implements Webhook interface
+func (s *PriorityClassWebhook) Doc() string {
+	return fmt.Sprintf(docString, strings.Join(protectedPriorityClasses, ", "))
+}
+
+// RuleDocs implements Webhook interface
+func (s *PriorityClassWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers may not modify or delete the cluster-critical PriorityClasses.",
+			Exceptions: []string{utils.AdminsException},
+		},
+	}
+}
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+func (s *PriorityClassWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// ClassicEnabled implements Webhook interface
+func (s *PriorityClassWebhook) ClassicEnabled() bool { return true }
+
+// HypershiftEnabled implements Webhook interface
+func (s *PriorityClassWebhook) HypershiftEnabled() bool { return true }
@@ -0,0 +1,88 @@
+package priorityclass
+
+import (
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+)
+
+func TestAuthorized(t *testing.T) {
+	tests := []struct {
+		name      string
+		operation admissionv1.Operation
+		class     string
+		username  string
+		groups    []string
+		allowed   bool
+	}{
+		{
+			name:      "customer deletes system-cluster-critical",
+			operation: admissionv1.Delete,
+			class:     "system-cluster-critical",
+			username:  "customer",
+			groups:    []string{"system:authenticated"},
+			allowed:   false,
+		},
+		{
+			name:      "customer updates system-node-critical",
+			operation: admissionv1.Update,
+			class:     "system-node-critical",
+			username:  "customer",
+			groups:    []string{"system:authenticated"},
+			allowed:   false,
+		},
+		{
+			name:      "customer deletes their own PriorityClass",
+			operation: admissionv1.Delete,
+			class:     "customer-high",
+			username:  "customer",
+			groups:    []string{"system:authenticated"},
+			allowed:   true,
+		},
+		{
+			name:      "SRE updates openshift-user-critical",
+			operation: admissionv1.Update,
+			class:     "openshift-user-critical",
+			username:  "sre",
+			groups:    []string{"system:serviceaccounts:openshift-backplane-srep"},
+			allowed:   true,
+		},
+		{
+			name:      "kube:admin deletes system-cluster-critical",
+			operation: admissionv1.Delete,
+			class:     "system-cluster-critical",
+			username:  "kube:admin",
+			allowed:   true,
+		},
+		{
+			name:      "openshift operator updates system-cluster-critical",
+			operation: admissionv1.Update,
+			class:     "system-cluster-critical",
+			username:  "system:serviceaccount:openshift-kube-scheduler-operator:openshift-kube-scheduler-operator",
+			groups:    []string{"system:serviceaccounts:openshift-kube-scheduler-operator"},
+			allowed:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gvk := metav1.GroupVersionKind{Group: "scheduling.k8s.io", Version: "v1", Kind: "PriorityClass"}
+			request := testutils.NewRequest(t, gvk, test.operation, authenticationv1.UserInfo{Username: test.username, Groups: test.groups}, "", test.class, nil, nil)
+			hook := NewWebhook()
+			if !hook.Validate(request) {
+				t.Fatalf("Expected request to be valid")
+			}
+			response := hook.Authorized(request)
+			if response.Allowed != test.allowed {
+				t.Fatalf("Expected allowed %v, got %v: %v", test.allowed, response.Allowed, response.Result)
+			}
+			if response.UID != request.UID {
+				t.Fatalf("Expected UID %s, got %s", request.UID, response.UID)
+			}
+		})
+	}
+}
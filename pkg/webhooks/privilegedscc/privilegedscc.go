@@ -0,0 +1,319 @@
+package privilegedscc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	hookconfig "github.com/openshift/managed-cluster-validating-webhooks/pkg/config"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "privilegedscc-validation"
+	docString   string = `Managed OpenShift customers may not run pods in customer namespaces using the %s SCCs, or equivalent privileged, host namespace or hostPath settings, unless SRE has annotated the namespace with %s=true. Host namespaces and hostPath volumes are also allowed where SRE has labelled the namespace for hostaccess-validation.`
+
+	// AllowedGroupsEnvVar is a comma-separated list of groups which may create
+	// privileged pods in customer namespaces
+	AllowedGroupsEnvVar string = "PRIVILEGEDSCC_ALLOWED_GROUPS"
+
+	// ExceptionAnnotation is applied to a namespace by SRE to allow privileged
+	// pods in it
+	ExceptionAnnotation string = utils.PrivilegedSCCAnnotation
+
+	// sccAnnotation is set on a pod to the SCC which admitted it
+	sccAnnotation string = "openshift.io/scc"
+	// requiredSCCAnnotation requests a specific SCC for a pod
+	requiredSCCAnnotation string = "openshift.io/required-scc"
+)
+
+var (
+	privilegedSCCs = []string{"privileged", "hostaccess"}
+
+	scope = admissionregv1.NamespacedScope
+	rules = []admissionregv1.RuleWithOperations{
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Create},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{""},
+				APIVersions: []string{"v1"},
+				Resources:   []string{"pods"},
+				Scope:       &scope,
+			},
+		},
+	}
+	log = logf.Log.WithName(WebhookName)
+)
+
+// PrivilegedSCCWebhook prevents privileged pods in customer namespaces
+type PrivilegedSCCWebhook struct {
+	s          *runtime.Scheme
+	decoder    admissionctl.Decoder
+	kubeClient client.Client
+}
+
+// NewWebhook creates a new webhook
+func NewWebhook() *PrivilegedSCCWebhook {
+	scheme, err := k8sutil.SharedScheme()
+	if err != nil {
+		log.Error(err, "Fail getting the shared scheme for PrivilegedSCCWebhook")
+		os.Exit(1)
+	}
+	decoder, err := k8sutil.SharedDecoder()
+	if err != nil {
+		log.Error(err, "Fail getting the shared decoder for PrivilegedSCCWebhook")
+		os.Exit(1)
+	}
+
+	return &PrivilegedSCCWebhook{
+		s:       scheme,
+		decoder: decoder,
+	}
+}
+
+// InjectClient implements ClientWebhook interface
+func (s *PrivilegedSCCWebhook) InjectClient(c client.Client) {
+	s.kubeClient = c
+}
+
+// CachedObjects implements ClientWebhook interface. Namespaces are only read
+// for their exception annotation.
+func (s *PrivilegedSCCWebhook) CachedObjects() []client.Object {
+	return []client.Object{&corev1.Namespace{}}
+}
+
+// Authorized implements Webhook interface
+func (s *PrivilegedSCCWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.AuthorizedContext(context.Background(), request)
+}
+
+// AuthorizedContext implements ContextWebhook interface
+func (s *PrivilegedSCCWebhook) AuthorizedContext(ctx context.Context, request admissionctl.Request) admissionctl.Response {
+	return s.authorized(ctx, request)
+}
+
+func (s *PrivilegedSCCWebhook) authorized(ctx context.Context, request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if s.ExcludedNamespaces().Excludes(request.Namespace) {
+		ret = admissionctl.Allowed("Pods in managed namespaces may be privileged")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	if isAllowedUser(request) {
+		ret = admissionctl.Allowed("Allowed users may create privileged pods")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	pod, err := s.renderPod(request)
+	if err != nil {
+		log.Error(err, "Couldn't render a Pod from the incoming request")
+		ret = admissionctl.Errored(http.StatusBadRequest, err)
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	reason := privilegedReason(pod, nil)
+	if reason == "" {
+		ret = admissionctl.Allowed("Pod is not privileged")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	ns, err := s.namespace(ctx, request.Namespace)
+	if err != nil {
+		// Fail open, as the webhook's FailurePolicy does
+		log.Error(err, "Failed to check namespace for the privileged SCC exception", "namespace", request.Namespace)
+		ret = admissionctl.Allowed("Unable to check namespace for the privileged SCC exception")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	if ns.Annotations[ExceptionAnnotation] == "true" {
+		ret = admissionctl.Allowed("Namespace allows privileged pods")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	// Host access the namespace's labels allow is left to hostaccess-validation
+	if reason = privilegedReason(pod, ns.Labels); reason == "" {
+		ret = admissionctl.Allowed("Namespace allows the pod's host access")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	log.Info("Denying privileged pod", "namespace", request.Namespace, "user", request.UserInfo.Username, "reason", reason)
+	ret = response.Denied(response.PrivilegedPod, fmt.Sprintf("Prevented from creating a privileged pod in a customer namespace: %s. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", reason))
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// isAllowedUser checks for cluster admins, SRE and the allowed groups
+func isAllowedUser(request admissionctl.Request) bool {
+	if identity.IsSRE(request.UserInfo) || identity.IsClusterAdmin(request.UserInfo) {
+		return true
+	}
+	allowedGroups := allowedGroups()
+	for _, group := range request.UserInfo.Groups {
+		if slices.Contains(allowedGroups, group) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedGroups returns the groups configured in AllowedGroupsEnvVar
+func allowedGroups() []string {
+	groups := []string{}
+	for _, group := range strings.Split(os.Getenv(AllowedGroupsEnvVar), ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// privilegedReason describes why the pod is privileged, or returns "" when it
+// isn't. Host access allowed by the namespace's labels isn't privileged.
+func privilegedReason(pod *corev1.Pod, namespaceLabels map[string]string) string {
+	for _, annotation := range []string{sccAnnotation, requiredSCCAnnotation} {
+		if scc := pod.Annotations[annotation]; slices.Contains(privilegedSCCs, scc) {
+			return fmt.Sprintf("it uses the %s SCC", scc)
+		}
+	}
+	for _, kind := range utils.PodHostAccess(pod) {
+		if utils.HostAccessAllowed(namespaceLabels, kind) {
+			continue
+		}
+		if kind != utils.HostPath {
+			return "it uses the host's namespaces"
+		}
+		for _, volume := range pod.Spec.Volumes {
+			if volume.HostPath != nil {
+				return fmt.Sprintf("volume %s is a hostPath", volume.Name)
+			}
+		}
+	}
+
+	containers := []corev1.Container{}
+	containers = append(containers, pod.Spec.InitContainers...)
+	containers = append(containers, pod.Spec.Containers...)
+	for _, container := range containers {
+		sc := container.SecurityContext
+		if sc == nil {
+			continue
+		}
+		if sc.Privileged != nil && *sc.Privileged {
+			return fmt.Sprintf("container %s is privileged", container.Name)
+		}
+	}
+	return ""
+}
+
+// namespace reads the namespace for SRE's exceptions
+func (s *PrivilegedSCCWebhook) namespace(ctx context.Context, name string) (*corev1.Namespace, error) {
+	if s.kubeClient == nil {
+		var err error
+		s.kubeClient, err = k8sutil.KubeClient(s.s)
+		if err != nil {
+			return nil, err
+		}
+	}
+	ns := &corev1.Namespace{}
+	if err := s.kubeClient.Get(ctx, client.ObjectKey{Name: name}, ns); err != nil {
+		return nil, err
+	}
+	return ns, nil
+}
+
+// renderPod renders the Pod in the admission Request
+func (s *PrivilegedSCCWebhook) renderPod(request admissionctl.Request) (*corev1.Pod, error) {
+	pod := &corev1.Pod{}
+	if err := s.decoder.DecodeRaw(request.Object, pod); err != nil {
+		return nil, err
+	}
+	return pod, nil
+}
+
+// GetURI implements Webhook interface
+func (s *PrivilegedSCCWebhook) GetURI() string { return "/" + WebhookName }
+
+// Validate implements Webhook interface
+func (s *PrivilegedSCCWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	valid = valid && (request.Kind.Kind == "Pod")
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *PrivilegedSCCWebhook) Name() string { return WebhookName }
+
+// FailurePolicy implements Webhook interface
+func (s *PrivilegedSCCWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *PrivilegedSCCWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *PrivilegedSCCWebhook) Rules() []admissionregv1.RuleWithOperations { return rules }
+
+// ExcludedNamespaces implements NamespaceExclusionWebhook interface
+func (s *PrivilegedSCCWebhook) ExcludedNamespaces() utils.NamespaceExclusion {
+	return hookconfig.ExcludedNamespaces
+}
+
+// ObjectSelector implements Webhook interface
+func (s *PrivilegedSCCWebhook) ObjectSelector() *metav1.LabelSelector { return nil }
+
+// SideEffects implements Webhook interface
+func (s *PrivilegedSCCWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *PrivilegedSCCWebhook) TimeoutSeconds() int32 { return 2 }
+
+// Doc implements Webhook interface
+func (s *PrivilegedSCCWebhook) Doc() string {
+	return fmt.Sprintf(docString, strings.Join(privilegedSCCs, " and "), ExceptionAnnotation)
+}
+
+// RuleDocs implements Webhook interface
+func (s *PrivilegedSCCWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers may not run pods in customer namespaces using the privileged SCCs, or equivalent privileged, host namespace or hostPath settings.",
+			Exceptions: []string{"Pods in managed namespaces", "Red Hat SRE and the cluster's built-in administrators", "The groups allowed by SRE", "Namespaces SRE have annotated to allow privileged pods", "Namespaces SRE have labelled to allow the pod's host access"},
+		},
+	}
+}
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+func (s *PrivilegedSCCWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// ClassicEnabled implements Webhook interface
+func (s *PrivilegedSCCWebhook) ClassicEnabled() bool { return true }
+
+// HypershiftEnabled implements Webhook interface
+func (s *PrivilegedSCCWebhook) HypershiftEnabled() bool { return true }
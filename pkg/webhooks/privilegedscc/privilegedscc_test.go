@@ -0,0 +1,169 @@
+package privilegedscc
+
+import (
+	"context"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+)
+
+func newRequest(t *testing.T, namespace, username string, groups []string, pod *corev1.Pod) admissionctl.Request {
+	t.Helper()
+	pod.Namespace = namespace
+	return testutils.NewRequest(t, metav1.GroupVersionKind{Version: "v1", Kind: "Pod"}, admissionv1.Create, authenticationv1.UserInfo{Username: username, Groups: groups}, namespace, "", pod, nil)
+}
+
+func TestAuthorized(t *testing.T) {
+	namespaces := []*corev1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "customer"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "excepted", Annotations: map[string]string{ExceptionAnnotation: "true"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "host-network", Labels: map[string]string{utils.HostAccessLabelPrefix + utils.HostNetwork: "true"}}},
+	}
+	privilegedContainer := &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{
+		{Name: "app", SecurityContext: &corev1.SecurityContext{Privileged: ptr.To(true)}},
+	}}}
+
+	tests := []struct {
+		name          string
+		namespace     string
+		username      string
+		groups        []string
+		allowedGroups string
+		pod           *corev1.Pod
+		allowed       bool
+	}{
+		{
+			name:      "unprivileged pod",
+			namespace: "customer",
+			username:  "customer",
+			pod:       &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}}},
+			allowed:   true,
+		},
+		{
+			name:      "privileged container",
+			namespace: "customer",
+			username:  "customer",
+			pod:       privilegedContainer.DeepCopy(),
+			allowed:   false,
+		},
+		{
+			name:      "privileged SCC",
+			namespace: "customer",
+			username:  "system:serviceaccount:kube-system:replicaset-controller",
+			groups:    []string{"system:serviceaccounts:kube-system"},
+			pod:       &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{sccAnnotation: "privileged"}}},
+			allowed:   false,
+		},
+		{
+			name:      "required hostaccess SCC",
+			namespace: "customer",
+			username:  "customer",
+			pod:       &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{requiredSCCAnnotation: "hostaccess"}}},
+			allowed:   false,
+		},
+		{
+			name:      "host network",
+			namespace: "customer",
+			username:  "customer",
+			pod:       &corev1.Pod{Spec: corev1.PodSpec{HostNetwork: true}},
+			allowed:   false,
+		},
+		{
+			name:      "hostPath volume",
+			namespace: "customer",
+			username:  "customer",
+			pod: &corev1.Pod{Spec: corev1.PodSpec{Volumes: []corev1.Volume{
+				{Name: "host", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/"}}},
+			}}},
+			allowed: false,
+		},
+		{
+			name:      "host network allowed by the namespace's labels",
+			namespace: "host-network",
+			username:  "customer",
+			pod:       &corev1.Pod{Spec: corev1.PodSpec{HostNetwork: true}},
+			allowed:   true,
+		},
+		{
+			name:      "host network and hostPath with only host network allowed",
+			namespace: "host-network",
+			username:  "customer",
+			pod: &corev1.Pod{Spec: corev1.PodSpec{HostNetwork: true, Volumes: []corev1.Volume{
+				{Name: "host", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/"}}},
+			}}},
+			allowed: false,
+		},
+		{
+			name:      "privileged container with host network allowed",
+			namespace: "host-network",
+			username:  "customer",
+			pod:       privilegedContainer.DeepCopy(),
+			allowed:   false,
+		},
+		{
+			name:      "privileged pod in an excepted namespace",
+			namespace: "excepted",
+			username:  "customer",
+			pod:       privilegedContainer.DeepCopy(),
+			allowed:   true,
+		},
+		{
+			name:      "privileged pod in a managed namespace",
+			namespace: "openshift-monitoring",
+			username:  "customer",
+			pod:       privilegedContainer.DeepCopy(),
+			allowed:   true,
+		},
+		{
+			name:      "privileged pod by SRE",
+			namespace: "customer",
+			username:  "sre",
+			groups:    []string{"system:serviceaccounts:openshift-backplane-srep"},
+			pod:       privilegedContainer.DeepCopy(),
+			allowed:   true,
+		},
+		{
+			name:          "privileged pod by an allowed group",
+			namespace:     "customer",
+			username:      "customer",
+			groups:        []string{"system:authenticated", "privileged-workloads"},
+			allowedGroups: "other, privileged-workloads",
+			pod:           privilegedContainer.DeepCopy(),
+			allowed:       true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(AllowedGroupsEnvVar, test.allowedGroups)
+			builder := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme)
+			for _, ns := range namespaces {
+				builder = builder.WithObjects(ns.DeepCopy())
+			}
+			hook := NewWebhook()
+			hook.InjectClient(builder.Build())
+
+			request := newRequest(t, test.namespace, test.username, test.groups, test.pod)
+			if !hook.Validate(request) {
+				t.Fatalf("Expected request to be valid")
+			}
+			response := hook.AuthorizedContext(context.Background(), request)
+			if response.Allowed != test.allowed {
+				t.Fatalf("Expected allowed %v, got %v: %v", test.allowed, response.Allowed, response.Result)
+			}
+			if response.UID != request.UID {
+				t.Fatalf("Expected UID %s, got %s", request.UID, response.UID)
+			}
+		})
+	}
+}
@@ -3,10 +3,11 @@ package prometheusrule
 import (
 	"fmt"
 	"net/http"
-	"regexp"
 	"slices"
 
 	hookconfig "github.com/openshift/managed-cluster-validating-webhooks/pkg/config"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
 	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
 
 	admissionregv1 "k8s.io/api/admissionregistration/v1"
@@ -23,13 +24,10 @@ const (
 )
 
 var (
-	timeout                          int32 = 2
-	allowedUsers                           = []string{"kube:admin", "system:admin", "backplane-cluster-admin"}
-	sreAdminGroups                         = []string{"system:serviceaccounts:openshift-backplane-srep"}
-	privilegedServiceAccountGroupsRe       = regexp.MustCompile(utils.PrivilegedServiceAccountGroups)
-	privilegedLabels                       = map[string]string{"app.kubernetes.io/name": "stackrox"}
-	scope                                  = admissionregv1.NamespacedScope
-	rules                                  = []admissionregv1.RuleWithOperations{
+	timeout          int32 = 2
+	privilegedLabels       = map[string]string{"app.kubernetes.io/name": "stackrox"}
+	scope                  = admissionregv1.NamespacedScope
+	rules                  = []admissionregv1.RuleWithOperations{
 		{
 			Operations: []admissionregv1.OperationType{
 				admissionregv1.Create,
@@ -91,12 +89,10 @@ func (s *prometheusruleWebhook) authorized(request admissionctl.Request) admissi
 			ret.UID = request.AdmissionRequest.UID
 			return ret
 		}
-		for _, group := range request.UserInfo.Groups {
-			if privilegedServiceAccountGroupsRe.Match([]byte(group)) {
-				ret = admissionctl.Allowed("Privileged service accounts do operations on PrometheusRules")
-				ret.UID = request.AdmissionRequest.UID
-				return ret
-			}
+		if identity.IsPrivilegedServiceAccount(request.UserInfo) {
+			ret = admissionctl.Allowed("Privileged service accounts do operations on PrometheusRules")
+			ret.UID = request.AdmissionRequest.UID
+			return ret
 		}
 
 		// TODO: [OSD-20025] Remove this exception after MON-3518 is completed
@@ -106,7 +102,7 @@ func (s *prometheusruleWebhook) authorized(request admissionctl.Request) admissi
 			return ret
 		}
 
-		ret = admissionctl.Denied(fmt.Sprintf("Prevented from accessing Red Hat managed resources. This is in an effort to prevent harmful actions that may cause unintended consequences or affect the stability of the cluster. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support"))
+		ret = response.Denied(response.ManagedResource, fmt.Sprintf("Prevented from accessing Red Hat managed resources. This is in an effort to prevent harmful actions that may cause unintended consequences or affect the stability of the cluster. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support"))
 		ret.UID = request.AdmissionRequest.UID
 		return ret
 	}
@@ -118,17 +114,7 @@ func (s *prometheusruleWebhook) authorized(request admissionctl.Request) admissi
 
 // isAllowedUser checks if the user or group is allowed to perform the action
 func isAllowedUser(request admissionctl.Request) bool {
-	if slices.Contains(allowedUsers, request.UserInfo.Username) {
-		return true
-	}
-
-	for _, group := range sreAdminGroups {
-		if slices.Contains(request.UserInfo.Groups, group) {
-			return true
-		}
-	}
-
-	return false
+	return identity.IsSRE(request.UserInfo) || identity.IsClusterAdmin(request.UserInfo)
 }
 
 // hasPrivilegedLabel checks if the rendered rule's labels match one of the privilegedLabels
@@ -211,6 +197,16 @@ func (s *prometheusruleWebhook) Doc() string {
 	return (docString)
 }
 
+// RuleDocs implements Webhook interface
+func (s *prometheusruleWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers may not create PrometheusRules in namespaces managed by Red Hat.",
+			Exceptions: []string{utils.SREException, utils.PrivilegedServiceAccountsException},
+		},
+	}
+}
+
 // SyncSetLabelSelector returns the label selector to use in the SyncSet.
 // Return utils.DefaultLabelSelector() to stick with the default
 func (s *prometheusruleWebhook) SyncSetLabelSelector() metav1.LabelSelector {
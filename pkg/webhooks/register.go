@@ -1,9 +1,15 @@
 package webhooks
 
 import (
+	"context"
+
 	admissionregv1 "k8s.io/api/admissionregistration/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/dependencies"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
 )
 
 type RegisteredWebhooks map[string]WebhookFactory
@@ -11,9 +17,15 @@ type RegisteredWebhooks map[string]WebhookFactory
 // Webhooks are all registered webhooks mapping name to hook
 var Webhooks = RegisteredWebhooks{}
 
+// sharedClient is injected into every ClientWebhook built by a registered
+// WebhookFactory. When nil, webhooks create their own client.
+var sharedClient client.Client
+
 // Webhook interface
 type Webhook interface {
-	// Authorized will determine if the request is allowed
+	// Authorized will determine if the request is allowed. Warnings set on
+	// the response, eg with WithWarnings, are returned to the client, which
+	// kubectl and oc print, whether the request is allowed or not.
 	Authorized(request admissionctl.Request) admissionctl.Response
 	// GetURI returns the URI for the webhook
 	GetURI() string
@@ -44,6 +56,10 @@ type Webhook interface {
 	TimeoutSeconds() int32
 	// Doc returns a string for end-customer documentation purposes.
 	Doc() string
+	// RuleDocs describes each rule the webhook enforces, and who is excepted
+	// from it, for end-customer documentation purposes. Every webhook must
+	// describe at least one rule.
+	RuleDocs() []utils.RuleDoc
 	// SyncSetLabelSelector returns the label selector to use in the SyncSet.
 	// Return utils.DefaultLabelSelector() to stick with the default
 	SyncSetLabelSelector() metav1.LabelSelector
@@ -53,10 +69,119 @@ type Webhook interface {
 	HypershiftEnabled() bool
 }
 
+// WebhookV2 is implemented by webhooks answering requests with a context and
+// the dependencies the server provides, rather than creating their own.
+// Webhooks migrate to it one at a time; use Admit to answer requests with
+// webhooks of either kind. Authorized stays, usually calling Admit with
+// context.Background() and dependencies.Current().
+type WebhookV2 interface {
+	Webhook
+	// Admit answers the request, bounded by ctx, reading the cluster with
+	// deps.Client and recording Events and metrics with deps
+	Admit(ctx context.Context, deps dependencies.Bundle, request admissionctl.Request) admissionctl.Response
+}
+
+// CachingWebhook is implemented by webhooks which read objects from the
+// cluster, so the kinds they read are cached in the shared client
+type CachingWebhook interface {
+	// CachedObjects returns the kinds the webhook reads which should be served
+	// from an informer cache. Other kinds are read from the API server.
+	CachedObjects() []client.Object
+}
+
+// ClientWebhook is implemented by webhooks which read objects from the
+// cluster, so they can share one cached client instead of each building
+// their own. WebhookV2 webhooks are given the client by Admit instead.
+type ClientWebhook interface {
+	// InjectClient hands the shared client to the webhook
+	InjectClient(client.Client)
+	CachingWebhook
+}
+
+// ContextWebhook is implemented by webhooks which make calls while answering a
+// request, so those calls can be cancelled once the request's latency budget
+// is spent
+type ContextWebhook interface {
+	// AuthorizedContext is Authorized, bounded by ctx
+	AuthorizedContext(ctx context.Context, request admissionctl.Request) admissionctl.Response
+}
+
+// WarmUpWebhook is implemented by webhooks with dependencies which are slow to
+// initialize, such as caches filled from the cluster, so they're initialized
+// before the server reports ready rather than by the first requests
+type WarmUpWebhook interface {
+	// WarmUp initializes the webhook's dependencies. It's called once, after
+	// the shared client is set. Dependencies it fails to initialize are left
+	// to requests, as they were without warm-up.
+	WarmUp(ctx context.Context) error
+}
+
+// PolicyWebhook is implemented by webhooks whose logic can be expressed in
+// CEL, so it can also be rendered as a ValidatingAdmissionPolicy and run in
+// the API server instead of calling out to the webhook
+type PolicyWebhook interface {
+	// Validations returns the CEL validations equivalent to Authorized
+	Validations() []admissionregv1.Validation
+}
+
+// NamespaceExclusionWebhook is implemented by webhooks which only police
+// customer namespaces, so the platform namespaces they skip are rendered
+// into their namespaceSelector as well as checked in code
+type NamespaceExclusionWebhook interface {
+	// ExcludedNamespaces returns the namespaces the webhook skips, usually
+	// config.ExcludedNamespaces. Authorized must allow requests in namespaces
+	// it excludes.
+	ExcludedNamespaces() utils.NamespaceExclusion
+}
+
 // WebhookFactory return a kind of Webhook
 type WebhookFactory func() Webhook
 
 // Register webhooks
 func Register(name string, input WebhookFactory) {
-	Webhooks[name] = input
+	Webhooks[name] = func() Webhook {
+		hook := input()
+		if cw, ok := hook.(ClientWebhook); ok && sharedClient != nil {
+			cw.InjectClient(sharedClient)
+		}
+		return hook
+	}
+}
+
+// SetClient sets the client injected into every ClientWebhook. It must be
+// called before any requests are served.
+func SetClient(c client.Client) {
+	sharedClient = c
+}
+
+// CachedObjects returns the kinds the registered CachingWebhooks want cached,
+// skipping the webhooks which enabled reports aren't enabled on this cluster
+func (r RegisteredWebhooks) CachedObjects(enabled func(name string) bool) []client.Object {
+	objs := []client.Object{}
+	for name, factory := range r {
+		if !enabled(name) {
+			continue
+		}
+		if cw, ok := factory().(CachingWebhook); ok {
+			objs = append(objs, cw.CachedObjects()...)
+		}
+	}
+	return objs
+}
+
+// Admit answers the request with the webhook, whichever interface it
+// implements. Webhooks not yet implementing WebhookV2 are adapted: deps.Client,
+// when set, is injected into ClientWebhooks, and ctx is passed to
+// ContextWebhooks.
+func Admit(ctx context.Context, hook Webhook, deps dependencies.Bundle, request admissionctl.Request) admissionctl.Response {
+	if v2, ok := hook.(WebhookV2); ok {
+		return v2.Admit(ctx, deps.WithDefaults(), request)
+	}
+	if cw, ok := hook.(ClientWebhook); ok && deps.Client != nil {
+		cw.InjectClient(deps.Client)
+	}
+	if contextHook, ok := hook.(ContextWebhook); ok {
+		return contextHook.AuthorizedContext(ctx, request)
+	}
+	return hook.Authorized(request)
 }
@@ -0,0 +1,139 @@
+package webhooks
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/dependencies"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/hiveownership"
+)
+
+// clientHook is a webhook which records the client injected into it
+type clientHook struct {
+	*hiveownership.HiveOwnershipWebhook
+	c client.Client
+}
+
+func (h *clientHook) InjectClient(c client.Client) { h.c = c }
+
+func (h *clientHook) CachedObjects() []client.Object {
+	return []client.Object{&corev1.ConfigMap{}}
+}
+
+func (h *clientHook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return admissionctl.Allowed("legacy")
+}
+
+// v2Hook is a WebhookV2 which records the dependencies it's given
+type v2Hook struct {
+	*hiveownership.HiveOwnershipWebhook
+	deps dependencies.Bundle
+}
+
+func (h *v2Hook) Admit(ctx context.Context, deps dependencies.Bundle, request admissionctl.Request) admissionctl.Response {
+	h.deps = deps
+	return admissionctl.Allowed("v2")
+}
+
+func TestRegisterInjectsSharedClient(t *testing.T) {
+	const name = "test-client-hook"
+	Register(name, func() Webhook { return &clientHook{HiveOwnershipWebhook: hiveownership.NewWebhook()} })
+	t.Cleanup(func() {
+		delete(Webhooks, name)
+		SetClient(nil)
+	})
+
+	if hook := Webhooks[name]().(*clientHook); hook.c != nil {
+		t.Fatalf("expected no client to be injected before SetClient, got %v", hook.c)
+	}
+
+	c := fake.NewClientBuilder().Build()
+	SetClient(c)
+	if hook := Webhooks[name]().(*clientHook); hook.c != c {
+		t.Fatalf("expected the shared client to be injected, got %v", hook.c)
+	}
+
+	cachesConfigMaps := func(enabled func(string) bool) bool {
+		for _, obj := range Webhooks.CachedObjects(enabled) {
+			if _, ok := obj.(*corev1.ConfigMap); ok {
+				return true
+			}
+		}
+		return false
+	}
+	if !cachesConfigMaps(func(string) bool { return true }) {
+		t.Fatalf("expected CachedObjects to include the ConfigMaps requested by %s", name)
+	}
+	if cachesConfigMaps(func(hookName string) bool { return hookName != name }) {
+		t.Fatalf("expected CachedObjects to skip the ConfigMaps requested by the disabled %s", name)
+	}
+}
+
+func TestAdmit(t *testing.T) {
+	ctx := context.Background()
+	c := fake.NewClientBuilder().Build()
+
+	legacy := &clientHook{HiveOwnershipWebhook: hiveownership.NewWebhook()}
+	if response := Admit(ctx, legacy, dependencies.Bundle{}, admissionctl.Request{}); response.Result.Message != "legacy" || legacy.c != nil {
+		t.Fatalf("expected Authorized to answer without a client injected, got %q and %v", response.Result.Message, legacy.c)
+	}
+	if Admit(ctx, legacy, dependencies.Bundle{Client: c}, admissionctl.Request{}); legacy.c != c {
+		t.Fatalf("expected the dependencies' client to be injected, got %v", legacy.c)
+	}
+
+	v2 := &v2Hook{HiveOwnershipWebhook: hiveownership.NewWebhook()}
+	if response := Admit(ctx, v2, dependencies.Bundle{Client: c}, admissionctl.Request{}); response.Result.Message != "v2" {
+		t.Fatalf("expected Admit to answer, got %q", response.Result.Message)
+	}
+	if v2.deps.Client != c || v2.deps.Metrics == nil || v2.deps.ClusterContext == nil {
+		t.Fatalf("expected the dependencies with defaults, got %+v", v2.deps)
+	}
+}
+
+func TestRegisteredWebhooksDocumentRules(t *testing.T) {
+	for name, factory := range Webhooks {
+		ruleDocs := factory().RuleDocs()
+		if len(ruleDocs) == 0 {
+			t.Errorf("%s doesn't describe the rules it enforces", name)
+		}
+		for i, ruleDoc := range ruleDocs {
+			if ruleDoc.Summary == "" {
+				t.Errorf("%s rule %d has no summary", name, i)
+			}
+		}
+	}
+}
+
+func TestNamespaceSelectorsMatchExclusions(t *testing.T) {
+	excluding := 0
+	for name, factory := range Webhooks {
+		hook, ok := factory().(NamespaceExclusionWebhook)
+		if !ok {
+			continue
+		}
+		excluding++
+		exclusion := hook.ExcludedNamespaces()
+		selector := NamespaceSelector(factory())
+		if selector == nil {
+			t.Errorf("Expected %s to be registered with a namespace selector", name)
+			continue
+		}
+		// every namespace the API server doesn't call the webhook for must
+		// also be skipped in code
+		for _, requirement := range selector.MatchExpressions {
+			for _, namespace := range requirement.Values {
+				if !exclusion.Excludes(namespace) {
+					t.Errorf("Expected %s to exclude namespace %s, which its namespace selector leaves out", name, namespace)
+				}
+			}
+		}
+	}
+	if excluding == 0 {
+		t.Errorf("Expected some webhooks to exclude the managed namespaces")
+	}
+}
@@ -8,6 +8,8 @@ import (
 
 	networkv1 "github.com/openshift/api/network/v1"
 	hookconfig "github.com/openshift/managed-cluster-validating-webhooks/pkg/config"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
 	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/namespace"
 	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
 	admissionv1 "k8s.io/api/admission/v1"
@@ -43,8 +45,6 @@ const (
 )
 
 var (
-	adminGroups         = []string{"system:serviceaccounts:openshift-backplane-srep"}
-	adminUsers          = []string{"backplane-cluster-admin"}
 	clusterVersionUsers = []string{
 		"system:serviceaccount:openshift-managed-upgrade-operator:managed-upgrade-operator",
 		"system:serviceaccount:openshift-cluster-version:default",
@@ -171,6 +171,20 @@ func (s *RegularuserWebhook) Doc() string {
 	return fmt.Sprintf(docString, allGroups)
 }
 
+// RuleDocs implements Webhook interface
+func (s *RegularuserWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers may not manage objects in the Red Hat managed APIGroups.",
+			Exceptions: []string{utils.SystemUsersException, utils.SREException},
+		},
+		{
+			Summary:    "Customers may not alter the APIServer, KubeAPIServer, OpenShiftAPIServer, ClusterVersion, Proxy or SubjectPermission objects.",
+			Exceptions: []string{utils.SystemUsersException, utils.SREException},
+		},
+	}
+}
+
 // ObjectSelector implements Webhook interface
 func (s *RegularuserWebhook) ObjectSelector() *metav1.LabelSelector { return nil }
 
@@ -221,7 +235,7 @@ func (s *RegularuserWebhook) authorized(request admissionctl.Request) admissionc
 		// This could highlight a significant problem with RBAC since an
 		// unauthenticated user should have no permissions.
 		log.Info("system:unauthenticated made a webhook request. Check RBAC rules", "request", request.AdmissionRequest)
-		ret = admissionctl.Denied("Unauthenticated")
+		ret = response.Denied(response.Unauthenticated, "Unauthenticated")
 		ret.UID = request.AdmissionRequest.UID
 		return ret
 	}
@@ -232,7 +246,9 @@ func (s *RegularuserWebhook) authorized(request admissionctl.Request) admissionc
 			return utils.WebhookResponse(request, true, "")
 		} else {
 			log.Info("Denying access", "request", request.AdmissionRequest)
-			return utils.WebhookResponse(request, false, "Prevented from accessing Red Hat managed resources. This is in an effort to prevent harmful actions that may cause unintended consequences or affect the stability of the cluster. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support")
+			ret = response.Denied(response.ManagedResource, "Prevented from accessing Red Hat managed resources. This is in an effort to prevent harmful actions that may cause unintended consequences or affect the stability of the cluster. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support")
+			ret.UID = request.AdmissionRequest.UID
+			return ret
 		}
 	}
 
@@ -260,7 +276,9 @@ func (s *RegularuserWebhook) authorized(request admissionctl.Request) admissionc
 			return utils.WebhookResponse(request, true, "")
 		} else {
 			log.Info("Denying access", "request", request.AdmissionRequest)
-			return utils.WebhookResponse(request, false, "Prevented from accessing Red Hat managed resources. This is in an effort to prevent harmful actions that may cause unintended consequences or affect the stability of the cluster. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support")
+			ret = response.Denied(response.ManagedResource, "Prevented from accessing Red Hat managed resources. This is in an effort to prevent harmful actions that may cause unintended consequences or affect the stability of the cluster. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support")
+			ret.UID = request.AdmissionRequest.UID
+			return ret
 		}
 	case utils.RequestMatchesGroupKind(request, netNamespaceKind, netNamespaceGroup):
 		if isNetNamespaceAuthorized(s, request) {
@@ -278,20 +296,12 @@ func (s *RegularuserWebhook) authorized(request admissionctl.Request) admissionc
 		return ret
 	}
 
-	if slices.Contains(adminUsers, request.AdmissionRequest.UserInfo.Username) {
-		ret = admissionctl.Allowed("Specified admin users are allowed")
+	if identity.IsSRE(request.UserInfo) {
+		ret = admissionctl.Allowed("SRE are allowed")
 		ret.UID = request.AdmissionRequest.UID
 		return ret
 	}
 
-	for _, userGroup := range request.UserInfo.Groups {
-		if slices.Contains(adminGroups, userGroup) {
-			ret = admissionctl.Allowed("Members of admin groups are allowed")
-			ret.UID = request.AdmissionRequest.UID
-			return ret
-		}
-	}
-
 	if request.Kind.Kind == "ConfigMap" && shouldAllowConfigMapChange(s, request) {
 		ret = admissionctl.Allowed("Modification of Config Maps that are not user-ca-bundle are allowed")
 		ret.UID = request.AdmissionRequest.UID
@@ -299,7 +309,7 @@ func (s *RegularuserWebhook) authorized(request admissionctl.Request) admissionc
 	}
 
 	log.Info("Denying access", "request", request.AdmissionRequest)
-	ret = admissionctl.Denied("Prevented from accessing Red Hat managed resources. This is in an effort to prevent harmful actions that may cause unintended consequences or affect the stability of the cluster. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support")
+	ret = response.Denied(response.ManagedResource, "Prevented from accessing Red Hat managed resources. This is in an effort to prevent harmful actions that may cause unintended consequences or affect the stability of the cluster. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support")
 	ret.UID = request.AdmissionRequest.UID
 	return ret
 }
@@ -328,16 +338,10 @@ func isClusterVersionAuthorized(request admissionctl.Request) bool {
 		return true
 	}
 
-	if slices.Contains(adminUsers, request.AdmissionRequest.UserInfo.Username) {
+	if identity.IsSRE(request.UserInfo) {
 		return true
 	}
 
-	for _, userGroup := range request.UserInfo.Groups {
-		if slices.Contains(adminGroups, userGroup) {
-			return true
-		}
-	}
-
 	if strings.HasPrefix(request.AdmissionRequest.UserInfo.Username, "system:") && !strings.HasPrefix(request.AdmissionRequest.UserInfo.Username, "system:serviceaccount:") {
 		return true
 	}
@@ -345,16 +349,16 @@ func isClusterVersionAuthorized(request admissionctl.Request) bool {
 	return false
 }
 
-// isMachineConfigAuthorized allows cluster-admins group, backplane-cluster-admin user,
-// and specific serviceaccounts to modify MachineConfig resources
+// isMachineConfigAuthorized allows cluster-admins group, SRE users, and specific
+// serviceaccounts to modify MachineConfig resources
 func isMachineConfigAuthorized(request admissionctl.Request) bool {
 	// Allow cluster-admins group
 	if slices.Contains(request.UserInfo.Groups, "cluster-admins") {
 		return true
 	}
 
-	// Allow backplane-cluster-admin user
-	if slices.Contains(adminUsers, request.AdmissionRequest.UserInfo.Username) {
+	// Allow SRE users such as backplane-cluster-admin, but not the SRE service accounts
+	if identity.IsSRE(request.AdmissionRequest.UserInfo) && !strings.HasPrefix(request.AdmissionRequest.UserInfo.Username, "system:serviceaccount:") {
 		return true
 	}
 
@@ -0,0 +1,337 @@
+package routehosts
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	configv1 "github.com/openshift/api/config/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "routehosts-validation"
+	docString   string = `Managed OpenShift customers may not create Routes or Ingresses with wildcard hosts, nor with hosts under the reserved %s names of the cluster's base and apps domains, so they can't shadow the cluster's API, console and OAuth endpoints.`
+
+	// clusterConfigName is the name of the cluster's singleton configs
+	clusterConfigName string = "cluster"
+)
+
+var (
+	scope = admissionregv1.NamespacedScope
+	rules = []admissionregv1.RuleWithOperations{
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Create, admissionregv1.Update},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{"route.openshift.io"},
+				APIVersions: []string{"*"},
+				Resources:   []string{"routes"},
+				Scope:       &scope,
+			},
+		},
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Create, admissionregv1.Update},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{"networking.k8s.io"},
+				APIVersions: []string{"*"},
+				Resources:   []string{"ingresses"},
+				Scope:       &scope,
+			},
+		},
+	}
+	log = logf.Log.WithName(WebhookName)
+
+	// reservedNames are the names, in the cluster's base and apps domains, of
+	// the platform's endpoints
+	reservedNames = []string{"api", "api-int", "console", "console-openshift-console", "downloads-openshift-console", "oauth-openshift"}
+)
+
+// RouteHostsWebhook denies Routes and Ingresses which could shadow the
+// platform's endpoints
+type RouteHostsWebhook struct {
+	s          *runtime.Scheme
+	decoder    admissionctl.Decoder
+	kubeClient client.Client
+}
+
+// NewWebhook creates a new webhook
+func NewWebhook() *RouteHostsWebhook {
+	scheme, err := k8sutil.SharedScheme()
+	if err != nil {
+		log.Error(err, "Fail getting the shared scheme for RouteHostsWebhook")
+		os.Exit(1)
+	}
+	decoder, err := k8sutil.SharedDecoder()
+	if err != nil {
+		log.Error(err, "Fail getting the shared decoder for RouteHostsWebhook")
+		os.Exit(1)
+	}
+
+	return &RouteHostsWebhook{
+		s:       scheme,
+		decoder: decoder,
+	}
+}
+
+// InjectClient implements ClientWebhook interface
+func (s *RouteHostsWebhook) InjectClient(c client.Client) {
+	s.kubeClient = c
+}
+
+// CachedObjects implements ClientWebhook interface. The cluster's DNS and
+// Ingress configs are singletons, which are cheap to cache.
+func (s *RouteHostsWebhook) CachedObjects() []client.Object {
+	return []client.Object{&configv1.DNS{}, &configv1.Ingress{}}
+}
+
+// Authorized implements Webhook interface
+func (s *RouteHostsWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.authorized(context.Background(), request)
+}
+
+// AuthorizedContext implements ContextWebhook interface
+func (s *RouteHostsWebhook) AuthorizedContext(ctx context.Context, request admissionctl.Request) admissionctl.Response {
+	return s.authorized(ctx, request)
+}
+
+func (s *RouteHostsWebhook) authorized(ctx context.Context, request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if identity.IsAdmin(request.UserInfo) {
+		ret = admissionctl.Allowed("Admins and managed service accounts may use any host")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	hosts, err := s.hosts(request.Kind.Kind, request.Object)
+	if err != nil {
+		log.Error(err, "Couldn't decode the request", "kind", request.Kind.Kind)
+		ret = admissionctl.Errored(http.StatusBadRequest, err)
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	// Hosts an object already has are left alone, so updates unrelated to them
+	// aren't denied
+	if request.Operation == admissionv1.Update {
+		oldHosts, err := s.hosts(request.Kind.Kind, request.OldObject)
+		if err != nil {
+			log.Error(err, "Couldn't decode the old object from the request", "kind", request.Kind.Kind)
+			ret = admissionctl.Errored(http.StatusBadRequest, err)
+			ret.UID = request.AdmissionRequest.UID
+			return ret
+		}
+		hosts = slices.DeleteFunc(hosts, func(host string) bool { return slices.Contains(oldHosts, host) })
+	}
+	if len(hosts) == 0 {
+		ret = admissionctl.Allowed("No hosts were added")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	for _, host := range hosts {
+		if strings.HasPrefix(host, "*.") {
+			log.Info("Denying wildcard host", "kind", request.Kind.Kind, "namespace", request.Namespace, "name", request.Name, "host", host, "user", request.UserInfo.Username)
+			ret = response.Denied(response.ReservedRouteHost, fmt.Sprintf("Prevented from using the wildcard host %s, which could shadow the cluster's endpoints. Use a host for each of your applications instead. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", host))
+			ret.UID = request.AdmissionRequest.UID
+			return ret
+		}
+	}
+
+	reserved, err := s.reservedDomains(ctx)
+	if err != nil {
+		log.Error(err, "Couldn't read the cluster's domains")
+		ret = admissionctl.Allowed("Unable to determine the cluster's domains")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	for _, host := range hosts {
+		if domain, ok := ReservedDomain(host, reserved); ok {
+			log.Info("Denying reserved host", "kind", request.Kind.Kind, "namespace", request.Namespace, "name", request.Name, "host", host, "user", request.UserInfo.Username)
+			ret = response.Denied(response.ReservedRouteHost, fmt.Sprintf("Prevented from using the host %s, as %s is reserved for the cluster's endpoints. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", host, domain))
+			ret.UID = request.AdmissionRequest.UID
+			return ret
+		}
+	}
+
+	ret = admissionctl.Allowed("Hosts are neither wildcards nor reserved")
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// hosts returns the hosts of the Route or Ingress, normalised. The wildcard
+// of a Route with the Subdomain wildcard policy is returned as *.<its domain>.
+func (s *RouteHostsWebhook) hosts(kind string, raw runtime.RawExtension) ([]string, error) {
+	hosts := []string{}
+	switch kind {
+	case "Route":
+		route := &routev1.Route{}
+		if err := s.decoder.DecodeRaw(raw, route); err != nil {
+			return nil, err
+		}
+		host := NormaliseHost(route.Spec.Host)
+		if host == "" {
+			// The router generates a host in the apps domain
+			return hosts, nil
+		}
+		if route.Spec.WildcardPolicy == routev1.WildcardPolicySubdomain {
+			if _, domain, ok := strings.Cut(host, "."); ok {
+				host = "*." + domain
+			}
+		}
+		hosts = append(hosts, host)
+	default:
+		ingress := &networkingv1.Ingress{}
+		if err := s.decoder.DecodeRaw(raw, ingress); err != nil {
+			return nil, err
+		}
+		for _, rule := range ingress.Spec.Rules {
+			hosts = append(hosts, NormaliseHost(rule.Host))
+		}
+		for _, tls := range ingress.Spec.TLS {
+			for _, host := range tls.Hosts {
+				hosts = append(hosts, NormaliseHost(host))
+			}
+		}
+		hosts = slices.DeleteFunc(hosts, func(host string) bool { return host == "" })
+	}
+	slices.Sort(hosts)
+	return slices.Compact(hosts), nil
+}
+
+// NormaliseHost lowercases the host and removes any trailing dot
+func NormaliseHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// reservedDomains returns the reserved names in the cluster's base and apps
+// domains
+func (s *RouteHostsWebhook) reservedDomains(ctx context.Context) ([]string, error) {
+	var err error
+	if s.kubeClient == nil {
+		s.kubeClient, err = k8sutil.KubeClient(s.s)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ReservedDomains(ctx, s.kubeClient)
+}
+
+// ReservedDomains returns the reserved names in the cluster's base and apps
+// domains, which are the hosts of the platform's endpoints. The client must
+// read the cluster's DNS and Ingress configs.
+func ReservedDomains(ctx context.Context, c client.Client) ([]string, error) {
+	dns := &configv1.DNS{}
+	if err := c.Get(ctx, client.ObjectKey{Name: clusterConfigName}, dns); err != nil {
+		return nil, err
+	}
+	ingress := &configv1.Ingress{}
+	if err := c.Get(ctx, client.ObjectKey{Name: clusterConfigName}, ingress); err != nil {
+		return nil, err
+	}
+
+	domains := []string{}
+	for _, domain := range []string{dns.Spec.BaseDomain, ingress.Spec.Domain, ingress.Spec.AppsDomain} {
+		domain = NormaliseHost(domain)
+		if domain == "" {
+			continue
+		}
+		for _, name := range reservedNames {
+			domains = append(domains, name+"."+domain)
+		}
+	}
+	return domains, nil
+}
+
+// ReservedDomain returns the reserved domain the host is, or is under
+func ReservedDomain(host string, reserved []string) (string, bool) {
+	for _, domain := range reserved {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return domain, true
+		}
+	}
+	return "", false
+}
+
+// GetURI implements Webhook interface
+func (s *RouteHostsWebhook) GetURI() string { return "/" + WebhookName }
+
+// Validate implements Webhook interface
+func (s *RouteHostsWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	valid = valid && (request.Kind.Kind == "Route" || request.Kind.Kind == "Ingress")
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *RouteHostsWebhook) Name() string { return WebhookName }
+
+// FailurePolicy implements Webhook interface
+func (s *RouteHostsWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *RouteHostsWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *RouteHostsWebhook) Rules() []admissionregv1.RuleWithOperations { return rules }
+
+// ObjectSelector implements Webhook interface
+func (s *RouteHostsWebhook) ObjectSelector() *metav1.LabelSelector { return nil }
+
+// SideEffects implements Webhook interface
+func (s *RouteHostsWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *RouteHostsWebhook) TimeoutSeconds() int32 { return 2 }
+
+// Doc implements Webhook interface
+func (s *RouteHostsWebhook) Doc() string {
+	return fmt.Sprintf(docString, strings.Join(reservedNames, ", "))
+}
+
+// RuleDocs implements Webhook interface
+func (s *RouteHostsWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers may not create Routes or Ingresses with wildcard hosts, including Routes with the Subdomain wildcard policy.",
+			Exceptions: []string{utils.AdminsException, "Hosts the Route or Ingress already had"},
+		},
+		{
+			Summary:    "Customers may not create Routes or Ingresses with hosts under the cluster's API, console and OAuth endpoints.",
+			Exceptions: []string{utils.AdminsException, "Hosts the Route or Ingress already had", "Clusters whose domains can't be read"},
+		},
+	}
+}
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+func (s *RouteHostsWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// ClassicEnabled implements Webhook interface
+func (s *RouteHostsWebhook) ClassicEnabled() bool { return true }
+
+// HypershiftEnabled implements Webhook interface
+func (s *RouteHostsWebhook) HypershiftEnabled() bool { return true }
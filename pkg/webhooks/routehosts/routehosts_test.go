@@ -0,0 +1,196 @@
+package routehosts
+
+import (
+	"strings"
+	"testing"
+
+	configv1 "github.com/openshift/api/config/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+)
+
+const (
+	baseDomain = "mycluster.abcd.p1.openshiftapps.com"
+	appsDomain = "apps." + baseDomain
+)
+
+func clusterConfigs() []client.Object {
+	return []client.Object{
+		&configv1.DNS{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}, Spec: configv1.DNSSpec{BaseDomain: baseDomain}},
+		&configv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}, Spec: configv1.IngressSpec{Domain: appsDomain}},
+	}
+}
+
+func newRoute(host string, wildcardPolicy routev1.WildcardPolicyType) *routev1.Route {
+	return &routev1.Route{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "route.openshift.io/v1", Kind: "Route"},
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "customer"},
+		Spec: routev1.RouteSpec{
+			Host:           host,
+			WildcardPolicy: wildcardPolicy,
+			To:             routev1.RouteTargetReference{Kind: "Service", Name: "app"},
+		},
+	}
+}
+
+func newIngress(hosts ...string) *networkingv1.Ingress {
+	ingress := &networkingv1.Ingress{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "Ingress"},
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "customer"},
+	}
+	for _, host := range hosts {
+		ingress.Spec.Rules = append(ingress.Spec.Rules, networkingv1.IngressRule{Host: host})
+	}
+	return ingress
+}
+
+func newRequest(t *testing.T, operation admissionv1.Operation, username string, groups []string, oldObj, obj runtime.Object) admissionctl.Request {
+	t.Helper()
+	kind := obj.GetObjectKind().GroupVersionKind()
+	gvk := metav1.GroupVersionKind{Group: kind.Group, Version: kind.Version, Kind: kind.Kind}
+	return testutils.NewRequest(t, gvk, operation, authenticationv1.UserInfo{Username: username, Groups: groups}, "customer", "app", obj, oldObj)
+}
+
+func TestAuthorized(t *testing.T) {
+	tests := []struct {
+		name      string
+		operation admissionv1.Operation
+		username  string
+		groups    []string
+		oldObj    runtime.Object
+		obj       runtime.Object
+		existing  []client.Object
+		allowed   bool
+		message   string
+	}{
+		{
+			name:      "customer creates route",
+			operation: admissionv1.Create,
+			username:  "customer",
+			obj:       newRoute("app."+appsDomain, routev1.WildcardPolicyNone),
+			existing:  clusterConfigs(),
+			allowed:   true,
+		},
+		{
+			name:      "customer creates route without host",
+			operation: admissionv1.Create,
+			username:  "customer",
+			obj:       newRoute("", ""),
+			existing:  clusterConfigs(),
+			allowed:   true,
+		},
+		{
+			name:      "customer creates wildcard route",
+			operation: admissionv1.Create,
+			username:  "customer",
+			obj:       newRoute("wildcard."+appsDomain, routev1.WildcardPolicySubdomain),
+			existing:  clusterConfigs(),
+			allowed:   false,
+			message:   "*." + appsDomain,
+		},
+		{
+			name:      "customer creates console route",
+			operation: admissionv1.Create,
+			username:  "customer",
+			obj:       newRoute("Console-OpenShift-Console."+appsDomain+".", routev1.WildcardPolicyNone),
+			existing:  clusterConfigs(),
+			allowed:   false,
+			message:   "console-openshift-console." + appsDomain,
+		},
+		{
+			name:      "customer creates route under api",
+			operation: admissionv1.Create,
+			username:  "customer",
+			obj:       newRoute("login.api."+baseDomain, routev1.WildcardPolicyNone),
+			existing:  clusterConfigs(),
+			allowed:   false,
+		},
+		{
+			name:      "customer creates route like api in own domain",
+			operation: admissionv1.Create,
+			username:  "customer",
+			obj:       newRoute("api.example.com", routev1.WildcardPolicyNone),
+			existing:  clusterConfigs(),
+			allowed:   true,
+		},
+		{
+			name:      "customer creates wildcard ingress",
+			operation: admissionv1.Create,
+			username:  "customer",
+			obj:       newIngress("app.example.com", "*.example.com"),
+			existing:  clusterConfigs(),
+			allowed:   false,
+		},
+		{
+			name:      "customer creates oauth ingress",
+			operation: admissionv1.Create,
+			username:  "customer",
+			obj:       newIngress("oauth-openshift." + appsDomain),
+			existing:  clusterConfigs(),
+			allowed:   false,
+		},
+		{
+			name:      "customer updates existing wildcard route",
+			operation: admissionv1.Update,
+			username:  "customer",
+			oldObj:    newRoute("wildcard."+appsDomain, routev1.WildcardPolicySubdomain),
+			obj:       newRoute("wildcard."+appsDomain, routev1.WildcardPolicySubdomain),
+			existing:  clusterConfigs(),
+			allowed:   true,
+		},
+		{
+			name:      "customer makes route wildcard",
+			operation: admissionv1.Update,
+			username:  "customer",
+			oldObj:    newRoute("wildcard."+appsDomain, routev1.WildcardPolicyNone),
+			obj:       newRoute("wildcard."+appsDomain, routev1.WildcardPolicySubdomain),
+			existing:  clusterConfigs(),
+			allowed:   false,
+		},
+		{
+			name:      "console operator creates console route",
+			operation: admissionv1.Create,
+			username:  "system:serviceaccount:openshift-console-operator:console-operator",
+			groups:    []string{"system:serviceaccounts", "system:serviceaccounts:openshift-console-operator"},
+			obj:       newRoute("console-openshift-console."+appsDomain, routev1.WildcardPolicyNone),
+			allowed:   true,
+		},
+		{
+			name:      "customer creates api route without cluster configs",
+			operation: admissionv1.Create,
+			username:  "customer",
+			obj:       newRoute("api."+baseDomain, routev1.WildcardPolicyNone),
+			allowed:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := NewWebhook()
+			s.InjectClient(fake.NewClientBuilder().WithScheme(s.s).WithObjects(test.existing...).Build())
+			request := newRequest(t, test.operation, test.username, test.groups, test.oldObj, test.obj)
+			if !s.Validate(request) {
+				t.Fatalf("Expected the request to be valid")
+			}
+			response := s.Authorized(request)
+			if response.Allowed != test.allowed {
+				t.Errorf("Expected allowed %t, got %t: %s", test.allowed, response.Allowed, response.Result.Message)
+			}
+			if test.message != "" && !strings.Contains(response.Result.Message, test.message) {
+				t.Errorf("Expected the message to mention %s, got %s", test.message, response.Result.Message)
+			}
+			if response.UID != "test-uid" {
+				t.Errorf("Expected the response UID to be set")
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"slices"
 
 	securityv1 "github.com/openshift/api/security/v1"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
 	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionregv1 "k8s.io/api/admissionregistration/v1"
@@ -86,12 +87,12 @@ func (s *SCCWebHook) authorized(request admissionctl.Request) admissionctl.Respo
 		switch request.Operation {
 		case admissionv1.Delete:
 			log.Info(fmt.Sprintf("Deleting operation detected on default SCC: %v", scc.Name))
-			ret = admissionctl.Denied(fmt.Sprintf("Deleting default SCCs %v is not allowed", defaultSCCs))
+			ret = response.Denied(response.ManagedSecurityContextConstraint, fmt.Sprintf("Deleting default SCCs %v is not allowed", defaultSCCs))
 			ret.UID = request.AdmissionRequest.UID
 			return ret
 		case admissionv1.Update:
 			log.Info(fmt.Sprintf("Updating operation detected on default SCC: %v", scc.Name))
-			ret = admissionctl.Denied(fmt.Sprintf("Modifying default SCCs %v is not allowed", defaultSCCs))
+			ret = response.Denied(response.ManagedSecurityContextConstraint, fmt.Sprintf("Modifying default SCCs %v is not allowed", defaultSCCs))
 			ret.UID = request.AdmissionRequest.UID
 			return ret
 		}
@@ -198,6 +199,16 @@ func (s *SCCWebHook) Doc() string {
 	return fmt.Sprintf(docString, defaultSCCs)
 }
 
+// RuleDocs implements Webhook interface
+func (s *SCCWebHook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers may not modify or delete the default SecurityContextConstraints.",
+			Exceptions: []string{"The kube-apiserver, cluster-monitoring and cluster-version operators, and system:admin"},
+		},
+	}
+}
+
 // SyncSetLabelSelector returns the label selector to use in the SyncSet.
 // Return utils.DefaultLabelSelector() to stick with the default
 func (s *SCCWebHook) SyncSetLabelSelector() metav1.LabelSelector {
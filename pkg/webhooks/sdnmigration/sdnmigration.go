@@ -2,7 +2,6 @@ package sdnmigration
 
 import (
 	"net/http"
-	"regexp"
 
 	configv1 "github.com/openshift/api/config/v1"
 	admissionv1 "k8s.io/api/admission/v1"
@@ -12,6 +11,8 @@ import (
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
 	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
 )
 
@@ -23,8 +24,7 @@ const (
 )
 
 var (
-	log                         = logf.Log.WithName(WebhookName)
-	privilegedServiceAccountsRe = regexp.MustCompile(utils.PrivilegedServiceAccountGroups)
+	log = logf.Log.WithName(WebhookName)
 
 	scope = admissionregv1.ClusterScope
 	rules = []admissionregv1.RuleWithOperations{
@@ -56,10 +56,8 @@ func (w *NetworkConfigWebhook) Authorized(request admissionctl.Request) admissio
 	}
 
 	// allow if modified by an allow listed service account
-	for _, group := range request.UserInfo.Groups {
-		if privilegedServiceAccountsRe.Match([]byte(group)) {
-			return utils.WebhookResponse(request, true, "Privileged service accounts may access")
-		}
+	if identity.IsPrivilegedServiceAccount(request.UserInfo) {
+		return utils.WebhookResponse(request, true, "Privileged service accounts may access")
 	}
 
 	if request.Operation == admissionv1.Update {
@@ -85,13 +83,17 @@ func (w *NetworkConfigWebhook) Authorized(request admissionctl.Request) admissio
 		}
 
 		if object.Spec.NetworkType != oldObject.Status.NetworkType {
-			return utils.WebhookResponse(request, false, "Changing the network type is not allowed")
+			ret := response.Denied(response.ManagedNetworkConfig, "Changing the network type is not allowed")
+			ret.UID = request.AdmissionRequest.UID
+			return ret
 		}
 
 		return utils.WebhookResponse(request, true, "allowed action")
 	}
 
-	return utils.WebhookResponse(request, false, "Changing the network type is not allowed")
+	ret := response.Denied(response.ManagedNetworkConfig, "Changing the network type is not allowed")
+	ret.UID = request.AdmissionRequest.UID
+	return ret
 }
 
 // GetURI returns the URI for the webhook
@@ -147,6 +149,16 @@ func (w *NetworkConfigWebhook) SyncSetLabelSelector() metav1.LabelSelector {
 	return utils.DefaultLabelSelector()
 }
 
+// RuleDocs implements Webhook interface
+func (w *NetworkConfigWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers may not change the network type of the cluster Network config.",
+			Exceptions: []string{utils.PrivilegedServiceAccountsException, "Hive, as system:admin"},
+		},
+	}
+}
+
 func (w *NetworkConfigWebhook) ClassicEnabled() bool { return true }
 
 // HypershiftEnabled will return boolean value for hypershift enabled configurations
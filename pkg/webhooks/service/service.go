@@ -209,6 +209,16 @@ func (s *ServiceWebhook) Doc() string {
 	return (docString)
 }
 
+// RuleDocs implements Webhook interface
+func (s *ServiceWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "LoadBalancer Services are given the red-hat-managed=true AWS load balancer resource tag annotation.",
+			Exceptions: []string{"Services which aren't LoadBalancers"},
+		},
+	}
+}
+
 // SyncSetLabelSelector returns the label selector to use in the SyncSet.
 // Return utils.DefaultLabelSelector() to stick with the  default
 func (s *ServiceWebhook) SyncSetLabelSelector() metav1.LabelSelector {
@@ -16,6 +16,8 @@ import (
 	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	"github.com/openshift/managed-cluster-validating-webhooks/pkg/config"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
 	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
 )
 
@@ -39,12 +41,6 @@ var (
 			},
 		},
 	}
-	allowedUsers = []string{
-		"backplane-cluster-admin",
-	}
-	allowedGroups = []string{
-		"system:serviceaccounts:openshift-backplane-srep",
-	}
 	allowedServiceAccounts = []string{
 		"builder",
 		"default",
@@ -93,7 +89,7 @@ func (s *serviceAccountWebhook) authorized(request admissionctl.Request) admissi
 		// This could highlight a significant problem with RBAC since an
 		// unauthenticated user should have no permissions.
 		log.Info("system:unauthenticated made a webhook request. Check RBAC rules", "request", request.AdmissionRequest)
-		ret = admissionctl.Denied("Unauthenticated")
+		ret = response.Denied(response.Unauthenticated, "Unauthenticated")
 		ret.UID = request.AdmissionRequest.UID
 		return ret
 	}
@@ -114,10 +110,10 @@ func (s *serviceAccountWebhook) authorized(request admissionctl.Request) admissi
 		return admissionctl.Errored(http.StatusBadRequest, err)
 	}
 
-	if isProtectedNamespace(request) && !isAllowedUserGroup(request) {
+	if isProtectedNamespace(request) && !identity.IsSRE(request.UserInfo) {
 		if request.Operation == admissionv1.Delete && !isAllowedServiceAccount(sa) {
 			log.Info(fmt.Sprintf("Deleting operation detected on proteced serviceaccount: %v", sa.Name))
-			ret = admissionctl.Denied(fmt.Sprintf("Deleting protected service account under namespace %v is not allowed", request.Namespace))
+			ret = response.Denied(response.ManagedServiceAccount, fmt.Sprintf("Deleting protected service account under namespace %v is not allowed", request.Namespace))
 			ret.UID = request.AdmissionRequest.UID
 			return ret
 		}
@@ -144,21 +140,6 @@ func (s *serviceAccountWebhook) renderServiceAccount(request admissionctl.Reques
 	return sa, nil
 }
 
-// isAllowedUserGroup checks if the user or group is allowed to perform the action
-func isAllowedUserGroup(request admissionctl.Request) bool {
-	if slices.Contains(allowedUsers, request.UserInfo.Username) {
-		return true
-	}
-
-	for _, group := range allowedGroups {
-		if slices.Contains(request.UserInfo.Groups, group) {
-			return true
-		}
-	}
-
-	return false
-}
-
 // isProtectedNamespace checks if the request is going to operate on the serviceaccount in the
 // protected namespace list
 func isProtectedNamespace(request admissionctl.Request) bool {
@@ -234,6 +215,16 @@ func (s *serviceAccountWebhook) Doc() string {
 	return fmt.Sprintf(docString)
 }
 
+// RuleDocs implements Webhook interface
+func (s *serviceAccountWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers may not delete the service accounts of the managed namespaces.",
+			Exceptions: []string{utils.SystemUsersException},
+		},
+	}
+}
+
 // SyncSetLabelSelector returns the label selector to use in the SyncSet.
 // Return utils.DefaultLabelSelector() to stick with the default
 func (s *serviceAccountWebhook) SyncSetLabelSelector() metav1.LabelSelector {
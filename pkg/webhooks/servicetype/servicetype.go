@@ -0,0 +1,388 @@
+package servicetype
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/config"
+	hookconfig "github.com/openshift/managed-cluster-validating-webhooks/pkg/config"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "servicetype-validation"
+	docString   string = `Managed OpenShift customers may not create NodePort Services in their namespaces, and may only create as many LoadBalancer Services per namespace as the quota SRE set in the %s key of the %s ConfigMap (%d by default), as every load balancer is billed to and counts against the quotas of the cloud account. The policy is opt-in, and only enforced on clusters which enable the webhook.`
+
+	// QuotaConfigMapName is the ConfigMap in the webhook namespace holding
+	// the LoadBalancer quota set by SRE
+	QuotaConfigMapName string = "service-limits"
+	// maxLoadBalancersKey is how many LoadBalancer Services each namespace
+	// may have
+	maxLoadBalancersKey string = "maxLoadBalancersPerNamespace"
+	// defaultMaxLoadBalancers is used unless the ConfigMap sets a quota
+	defaultMaxLoadBalancers int = 2
+
+	// QuotaExemptLabel is set by SRE on the namespaces which may have any
+	// number of LoadBalancer Services. Customers can't set it, as
+	// namespace-validation protects it.
+	QuotaExemptLabel string = "managed.openshift.io/service-lb-quota-exempt"
+)
+
+var (
+	scope = admissionregv1.NamespacedScope
+	rules = []admissionregv1.RuleWithOperations{
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Create, admissionregv1.Update},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{""},
+				APIVersions: []string{"v1"},
+				Resources:   []string{"services"},
+				Scope:       &scope,
+			},
+		},
+	}
+	log = logf.Log.WithName(WebhookName)
+
+	// quotaTTL is how long the quota read from the ConfigMap is reused
+	quotaTTL = 30 * time.Second
+	// quota is shared by every ServiceTypeWebhook because the dispatcher
+	// builds a new webhook for each request
+	quota = &quotaCache{}
+)
+
+// quotaCache holds the most recently read LoadBalancer quota
+type quotaCache struct {
+	mu               sync.Mutex
+	maxLoadBalancers int
+	expires          time.Time
+}
+
+// get returns the cached quota and whether it is still fresh
+func (c *quotaCache) get(now time.Time) (int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Before(c.expires) {
+		return c.maxLoadBalancers, true
+	}
+	return 0, false
+}
+
+// set stores the quota, valid for quotaTTL from now
+func (c *quotaCache) set(maxLoadBalancers int, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maxLoadBalancers = maxLoadBalancers
+	c.expires = now.Add(quotaTTL)
+}
+
+// ServiceTypeWebhook denies NodePort Services in customer namespaces and
+// limits how many LoadBalancer Services each of them may have. Services are
+// counted from the cache, so replicas admitting concurrent creations may let
+// a namespace briefly exceed its quota.
+type ServiceTypeWebhook struct {
+	s          *runtime.Scheme
+	decoder    admissionctl.Decoder
+	kubeClient client.Client
+}
+
+// NewWebhook creates a new webhook
+func NewWebhook() *ServiceTypeWebhook {
+	scheme, err := k8sutil.SharedScheme()
+	if err != nil {
+		log.Error(err, "Fail getting the shared scheme for ServiceTypeWebhook")
+		os.Exit(1)
+	}
+	decoder, err := k8sutil.SharedDecoder()
+	if err != nil {
+		log.Error(err, "Fail getting the shared decoder for ServiceTypeWebhook")
+		os.Exit(1)
+	}
+
+	return &ServiceTypeWebhook{
+		s:       scheme,
+		decoder: decoder,
+	}
+}
+
+// InjectClient implements ClientWebhook interface
+func (s *ServiceTypeWebhook) InjectClient(c client.Client) {
+	s.kubeClient = c
+}
+
+// CachedObjects implements ClientWebhook interface. Namespaces are read for
+// their exemption label and Services are counted. The quota is a single
+// ConfigMap, cached for quotaTTL rather than by an informer over every
+// ConfigMap.
+func (s *ServiceTypeWebhook) CachedObjects() []client.Object {
+	return []client.Object{&corev1.Namespace{}, &corev1.Service{}}
+}
+
+// Authorized implements Webhook interface
+func (s *ServiceTypeWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.AuthorizedContext(context.Background(), request)
+}
+
+// AuthorizedContext implements ContextWebhook interface
+func (s *ServiceTypeWebhook) AuthorizedContext(ctx context.Context, request admissionctl.Request) admissionctl.Response {
+	return s.authorized(ctx, request)
+}
+
+func (s *ServiceTypeWebhook) authorized(ctx context.Context, request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if s.ExcludedNamespaces().Excludes(request.Namespace) {
+		ret = admissionctl.Allowed("Service types are not restricted in managed namespaces")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	if identity.IsSRE(request.UserInfo) || identity.IsPrivilegedServiceAccount(request.UserInfo) {
+		ret = admissionctl.Allowed("SRE and managed service accounts may create Services of any type")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	svc, oldType, err := s.renderService(request)
+	if err != nil {
+		log.Error(err, "Couldn't render a Service from the incoming request")
+		ret = admissionctl.Errored(http.StatusBadRequest, err)
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	// Services are only checked when they become NodePort or LoadBalancer,
+	// so those which already were can still be updated
+	if svc.Spec.Type == oldType {
+		ret = admissionctl.Allowed("The Service's type is unchanged")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	switch svc.Spec.Type {
+	case corev1.ServiceTypeNodePort:
+		log.Info("Denying NodePort Service", "namespace", request.Namespace, "name", svc.Name, "user", request.UserInfo.Username)
+		ret = response.Denied(response.ServiceNodePort, fmt.Sprintf("Prevented from creating NodePort Service %s in namespace %s: NodePort Services expose every node of the cluster. Use a ClusterIP Service with a Route, or a LoadBalancer Service, instead. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", svc.Name, request.Namespace))
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	case corev1.ServiceTypeLoadBalancer:
+		return s.authorizeLoadBalancer(ctx, request, svc)
+	}
+
+	ret = admissionctl.Allowed("Service is neither NodePort nor LoadBalancer")
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// authorizeLoadBalancer checks the namespace has room in its quota for
+// another LoadBalancer Service. Errors fail open, as the webhook's
+// FailurePolicy does.
+func (s *ServiceTypeWebhook) authorizeLoadBalancer(ctx context.Context, request admissionctl.Request, svc *corev1.Service) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if err := s.ensureClient(); err != nil {
+		log.Error(err, "Failed to create a client to count LoadBalancer Services")
+		ret = admissionctl.Allowed("Unable to count LoadBalancer Services")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	ns := &corev1.Namespace{}
+	if err := s.kubeClient.Get(ctx, client.ObjectKey{Name: request.Namespace}, ns); err != nil {
+		log.Error(err, "Failed to check namespace for LoadBalancer quota exemption", "namespace", request.Namespace)
+		ret = admissionctl.Allowed("Unable to check namespace for LoadBalancer quota exemption")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	if ns.Labels[QuotaExemptLabel] == "true" {
+		ret = admissionctl.Allowed("Namespace is exempt from the LoadBalancer quota")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	services := &corev1.ServiceList{}
+	if err := s.kubeClient.List(ctx, services, client.InNamespace(request.Namespace)); err != nil {
+		log.Error(err, "Failed to count LoadBalancer Services", "namespace", request.Namespace)
+		ret = admissionctl.Allowed("Unable to count LoadBalancer Services")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	count := 0
+	for _, existing := range services.Items {
+		if existing.Spec.Type == corev1.ServiceTypeLoadBalancer && existing.Name != svc.Name {
+			count++
+		}
+	}
+
+	maxLoadBalancers := s.maxLoadBalancers(ctx)
+	if count < maxLoadBalancers {
+		ret = admissionctl.Allowed("Namespace is within its LoadBalancer quota")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	log.Info("Denying LoadBalancer Service over the namespace's quota", "namespace", request.Namespace, "name", svc.Name, "user", request.UserInfo.Username, "count", count)
+	ret = response.Denied(response.ServiceLoadBalancerQuota, fmt.Sprintf("Prevented from creating LoadBalancer Service %s in namespace %s, which already has %d of the %d LoadBalancer Services allowed per namespace. Share a load balancer through a Route, or delete an unused LoadBalancer Service. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", svc.Name, request.Namespace, count, maxLoadBalancers))
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// renderService returns the Service in the request, and the type it had
+// before an update
+func (s *ServiceTypeWebhook) renderService(request admissionctl.Request) (*corev1.Service, corev1.ServiceType, error) {
+	svc := &corev1.Service{}
+	if err := s.decoder.DecodeRaw(request.Object, svc); err != nil {
+		return nil, "", err
+	}
+	if request.Operation != admissionv1.Update {
+		return svc, "", nil
+	}
+	old := &corev1.Service{}
+	if err := s.decoder.DecodeRaw(request.OldObject, old); err != nil {
+		return nil, "", err
+	}
+	return svc, old.Spec.Type, nil
+}
+
+// maxLoadBalancers returns the quota, reading it from the ConfigMap when it's
+// older than quotaTTL. The default is used when the ConfigMap can't be read
+// or is invalid.
+func (s *ServiceTypeWebhook) maxLoadBalancers(ctx context.Context) int {
+	now := time.Now()
+	if maxLoadBalancers, fresh := quota.get(now); fresh {
+		return maxLoadBalancers
+	}
+
+	maxLoadBalancers := defaultMaxLoadBalancers
+	cm := &corev1.ConfigMap{}
+	err := s.kubeClient.Get(ctx, client.ObjectKey{Namespace: config.OperatorNamespace, Name: QuotaConfigMapName}, cm)
+	switch {
+	case err != nil && !apierrors.IsNotFound(err):
+		log.Error(err, "Failed to read the LoadBalancer quota, using the default")
+	case err == nil:
+		if parsed, err := parseQuota(cm.Data); err != nil {
+			log.Error(err, "Invalid LoadBalancer quota, using the default")
+		} else {
+			maxLoadBalancers = parsed
+		}
+	}
+	quota.set(maxLoadBalancers, now)
+	return maxLoadBalancers
+}
+
+// parseQuota parses the LoadBalancer quota in the ConfigMap data. A quota of
+// 0 denies every LoadBalancer Service.
+func parseQuota(data map[string]string) (int, error) {
+	value, ok := data[maxLoadBalancersKey]
+	if !ok {
+		return defaultMaxLoadBalancers, nil
+	}
+	maxLoadBalancers, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || maxLoadBalancers < 0 {
+		return 0, fmt.Errorf("invalid %s %q", maxLoadBalancersKey, value)
+	}
+	return maxLoadBalancers, nil
+}
+
+// ensureClient creates a client if none was injected
+func (s *ServiceTypeWebhook) ensureClient() error {
+	if s.kubeClient != nil {
+		return nil
+	}
+	var err error
+	s.kubeClient, err = k8sutil.KubeClient(s.s)
+	return err
+}
+
+// GetURI implements Webhook interface
+func (s *ServiceTypeWebhook) GetURI() string { return "/" + WebhookName }
+
+// Validate implements Webhook interface
+func (s *ServiceTypeWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	valid = valid && (request.Kind.Kind == "Service")
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *ServiceTypeWebhook) Name() string { return WebhookName }
+
+// FailurePolicy implements Webhook interface
+func (s *ServiceTypeWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *ServiceTypeWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *ServiceTypeWebhook) Rules() []admissionregv1.RuleWithOperations { return rules }
+
+// ExcludedNamespaces implements NamespaceExclusionWebhook interface
+func (s *ServiceTypeWebhook) ExcludedNamespaces() utils.NamespaceExclusion {
+	return hookconfig.ExcludedNamespaces
+}
+
+// OptIn implements OptInWebhook interface. Some customers rely on NodePort
+// Services, so the policy is only enforced on the clusters enabling it.
+func (s *ServiceTypeWebhook) OptIn() bool { return true }
+
+// ObjectSelector implements Webhook interface
+func (s *ServiceTypeWebhook) ObjectSelector() *metav1.LabelSelector { return nil }
+
+// SideEffects implements Webhook interface
+func (s *ServiceTypeWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *ServiceTypeWebhook) TimeoutSeconds() int32 { return 2 }
+
+// Doc implements Webhook interface
+func (s *ServiceTypeWebhook) Doc() string {
+	return fmt.Sprintf(docString, maxLoadBalancersKey, QuotaConfigMapName, defaultMaxLoadBalancers)
+}
+
+// RuleDocs implements Webhook interface
+func (s *ServiceTypeWebhook) RuleDocs() []utils.RuleDoc {
+	exceptions := []string{utils.SREException, "Platform and managed service accounts", "Services in managed namespaces", "Services which already had the type when they're updated"}
+	return []utils.RuleDoc{
+		{
+			Summary:    "On clusters which enable the webhook, customers may not create NodePort Services.",
+			Exceptions: exceptions,
+		},
+		{
+			Summary:    fmt.Sprintf("On clusters which enable the webhook, customer namespaces may only have as many LoadBalancer Services as the %s key of the %s ConfigMap allows.", maxLoadBalancersKey, QuotaConfigMapName),
+			Exceptions: append(exceptions, fmt.Sprintf("Namespaces SRE labelled %s=true", QuotaExemptLabel)),
+		},
+	}
+}
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+func (s *ServiceTypeWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// ClassicEnabled implements Webhook interface
+func (s *ServiceTypeWebhook) ClassicEnabled() bool { return true }
+
+// HypershiftEnabled implements Webhook interface
+func (s *ServiceTypeWebhook) HypershiftEnabled() bool { return true }
@@ -0,0 +1,167 @@
+package servicetype
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/config"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func newService(namespace, name string, serviceType corev1.ServiceType) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec:       corev1.ServiceSpec{Type: serviceType},
+	}
+}
+
+func newRequest(t *testing.T, username string, old, svc *corev1.Service) admissionctl.Request {
+	t.Helper()
+	operation := admissionv1.Create
+	if old != nil {
+		operation = admissionv1.Update
+	}
+	return testutils.NewRequest(t, metav1.GroupVersionKind{Version: "v1", Kind: "Service"}, operation, authenticationv1.UserInfo{Username: username}, svc.Namespace, svc.Name, svc, old)
+}
+
+func TestAuthorized(t *testing.T) {
+	objects := []client.Object{
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "customer"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "edge", Labels: map[string]string{QuotaExemptLabel: "true"}}},
+		newService("payments", "api", corev1.ServiceTypeLoadBalancer),
+		newService("payments", "admin", corev1.ServiceTypeLoadBalancer),
+		newService("payments", "db", corev1.ServiceTypeClusterIP),
+		newService("customer", "api", corev1.ServiceTypeLoadBalancer),
+		newService("edge", "a", corev1.ServiceTypeLoadBalancer),
+		newService("edge", "b", corev1.ServiceTypeLoadBalancer),
+	}
+
+	tests := []struct {
+		name     string
+		username string
+		old      *corev1.Service
+		svc      *corev1.Service
+		quota    map[string]string
+		allowed  bool
+		message  string
+	}{
+		{
+			name:     "ClusterIP service",
+			username: "customer",
+			svc:      newService("payments", "cache", corev1.ServiceTypeClusterIP),
+			allowed:  true,
+		},
+		{
+			name:     "NodePort service",
+			username: "customer",
+			svc:      newService("payments", "debug", corev1.ServiceTypeNodePort),
+			allowed:  false,
+			message:  "Prevented from creating NodePort Service debug in namespace payments",
+		},
+		{
+			name:     "service changed to NodePort",
+			username: "customer",
+			old:      newService("payments", "db", corev1.ServiceTypeClusterIP),
+			svc:      newService("payments", "db", corev1.ServiceTypeNodePort),
+			allowed:  false,
+		},
+		{
+			name:     "existing NodePort service updated",
+			username: "customer",
+			old:      newService("payments", "legacy", corev1.ServiceTypeNodePort),
+			svc:      newService("payments", "legacy", corev1.ServiceTypeNodePort),
+			allowed:  true,
+		},
+		{
+			name:     "LoadBalancer over the default quota",
+			username: "customer",
+			svc:      newService("payments", "web", corev1.ServiceTypeLoadBalancer),
+			allowed:  false,
+			message:  "already has 2 of the 2 LoadBalancer Services allowed per namespace",
+		},
+		{
+			name:     "LoadBalancer within the configured quota",
+			username: "customer",
+			svc:      newService("payments", "web", corev1.ServiceTypeLoadBalancer),
+			quota:    map[string]string{maxLoadBalancersKey: "3"},
+			allowed:  true,
+		},
+		{
+			name:     "invalid quota uses the default",
+			username: "customer",
+			svc:      newService("payments", "web", corev1.ServiceTypeLoadBalancer),
+			quota:    map[string]string{maxLoadBalancersKey: "lots"},
+			allowed:  false,
+		},
+		{
+			name:     "LoadBalancer within the quota",
+			username: "customer",
+			svc:      newService("customer", "web", corev1.ServiceTypeLoadBalancer),
+			allowed:  true,
+		},
+		{
+			name:     "service changed to LoadBalancer over the quota",
+			username: "customer",
+			old:      newService("payments", "db", corev1.ServiceTypeClusterIP),
+			svc:      newService("payments", "db", corev1.ServiceTypeLoadBalancer),
+			allowed:  false,
+		},
+		{
+			name:     "quota exempt namespace",
+			username: "customer",
+			svc:      newService("edge", "c", corev1.ServiceTypeLoadBalancer),
+			allowed:  true,
+		},
+		{
+			name:     "managed namespace",
+			username: "customer",
+			svc:      newService("openshift-ingress", "router-nodeport", corev1.ServiceTypeNodePort),
+			allowed:  true,
+		},
+		{
+			name:     "sre",
+			username: "backplane-cluster-admin",
+			svc:      newService("payments", "debug", corev1.ServiceTypeNodePort),
+			allowed:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			quota = &quotaCache{}
+			builder := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(objects...)
+			if test.quota != nil {
+				builder = builder.WithObjects(&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Namespace: config.OperatorNamespace, Name: QuotaConfigMapName},
+					Data:       test.quota,
+				})
+			}
+			hook := NewWebhook()
+			hook.InjectClient(builder.Build())
+
+			request := newRequest(t, test.username, test.old, test.svc)
+			if !hook.Validate(request) {
+				t.Fatalf("Expected request to be valid")
+			}
+			response := hook.Authorized(request)
+			if response.Allowed != test.allowed {
+				t.Fatalf("Expected allowed %v, got %v: %v", test.allowed, response.Allowed, response.Result)
+			}
+			if response.UID != request.UID {
+				t.Fatalf("Expected UID %s, got %s", request.UID, response.UID)
+			}
+			if test.message != "" && !strings.Contains(response.Result.Message, test.message) {
+				t.Errorf("Expected the message to contain %q, got %s", test.message, response.Result.Message)
+			}
+		})
+	}
+}
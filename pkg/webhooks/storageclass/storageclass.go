@@ -0,0 +1,272 @@
+package storageclass
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "storageclass-validation"
+	docString   string = `Managed OpenShift customers may not delete managed StorageClasses, which have a "%s": "true" label, nor make the managed default StorageClass no longer the default unless another StorageClass is the default, so the cluster always has a default StorageClass.`
+
+	managedLabel string = "hive.openshift.io/managed"
+
+	// defaultClassAnnotation marks the default StorageClass
+	defaultClassAnnotation string = "storageclass.kubernetes.io/is-default-class"
+	// betaDefaultClassAnnotation is the deprecated annotation marking the
+	// default StorageClass, which is still honoured
+	betaDefaultClassAnnotation string = "storageclass.beta.kubernetes.io/is-default-class"
+)
+
+var (
+	scope = admissionregv1.ClusterScope
+	rules = []admissionregv1.RuleWithOperations{
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Update, admissionregv1.Delete},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{"storage.k8s.io"},
+				APIVersions: []string{"*"},
+				Resources:   []string{"storageclasses"},
+				Scope:       &scope,
+			},
+		},
+	}
+	log = logf.Log.WithName(WebhookName)
+)
+
+// StorageClassWebhook protects the managed StorageClasses and keeps the
+// cluster's default StorageClass
+type StorageClassWebhook struct {
+	s          *runtime.Scheme
+	decoder    admissionctl.Decoder
+	kubeClient client.Client
+}
+
+// NewWebhook creates a new webhook
+func NewWebhook() *StorageClassWebhook {
+	scheme, err := k8sutil.SharedScheme()
+	if err != nil {
+		log.Error(err, "Fail getting the shared scheme for StorageClassWebhook")
+		os.Exit(1)
+	}
+	decoder, err := k8sutil.SharedDecoder()
+	if err != nil {
+		log.Error(err, "Fail getting the shared decoder for StorageClassWebhook")
+		os.Exit(1)
+	}
+
+	return &StorageClassWebhook{
+		s:       scheme,
+		decoder: decoder,
+	}
+}
+
+// InjectClient implements ClientWebhook interface
+func (s *StorageClassWebhook) InjectClient(c client.Client) {
+	s.kubeClient = c
+}
+
+// CachedObjects implements ClientWebhook interface. Clusters only have a few
+// StorageClasses, which are cheap to cache.
+func (s *StorageClassWebhook) CachedObjects() []client.Object {
+	return []client.Object{&storagev1.StorageClass{}}
+}
+
+// Authorized implements Webhook interface
+func (s *StorageClassWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.authorized(context.Background(), request)
+}
+
+// AuthorizedContext implements ContextWebhook interface
+func (s *StorageClassWebhook) AuthorizedContext(ctx context.Context, request admissionctl.Request) admissionctl.Response {
+	return s.authorized(ctx, request)
+}
+
+func (s *StorageClassWebhook) authorized(ctx context.Context, request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if identity.IsAdmin(request.UserInfo) {
+		ret = admissionctl.Allowed("Admins and managed service accounts may change managed StorageClasses")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	old := &storagev1.StorageClass{}
+	if err := s.decoder.DecodeRaw(request.OldObject, old); err != nil {
+		log.Error(err, "Couldn't decode the old StorageClass from the request")
+		ret = admissionctl.Errored(http.StatusBadRequest, err)
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	if old.Labels[managedLabel] != "true" {
+		ret = admissionctl.Allowed("Only managed StorageClasses are protected")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	if request.Operation == admissionv1.Delete {
+		log.Info("Denying deletion of managed StorageClass", "name", request.Name, "user", request.UserInfo.Username)
+		ret = response.Denied(response.ManagedStorageClass, fmt.Sprintf("Prevented from deleting the managed StorageClass %s. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", request.Name))
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	storageClass := &storagev1.StorageClass{}
+	if err := s.decoder.DecodeRaw(request.Object, storageClass); err != nil {
+		log.Error(err, "Couldn't decode the StorageClass from the request")
+		ret = admissionctl.Errored(http.StatusBadRequest, err)
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	// Unlabelling a StorageClass would let it be deleted
+	if storageClass.Labels[managedLabel] != "true" {
+		log.Info("Denying removal of the managed label from StorageClass", "name", request.Name, "user", request.UserInfo.Username)
+		ret = response.Denied(response.ManagedStorageClass, fmt.Sprintf("Prevented from removing the %s label from the managed StorageClass %s. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", managedLabel, request.Name))
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	if !isDefault(old) || isDefault(storageClass) {
+		ret = admissionctl.Allowed("The default StorageClass is unchanged")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	other, err := s.otherDefault(ctx, request.Name)
+	if err != nil {
+		// Not being able to tell is no reason to block changes to StorageClasses
+		log.Error(err, "Failed to list StorageClasses, allowing the default to be removed", "name", request.Name)
+		ret = admissionctl.Allowed("Unable to determine whether another StorageClass is the default")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+	if other == "" {
+		log.Info("Denying removal of the default from the managed StorageClass", "name", request.Name, "user", request.UserInfo.Username)
+		ret = response.Denied(response.ManagedStorageClass, fmt.Sprintf("Prevented from making the managed StorageClass %s no longer the default, as the cluster would have no default StorageClass. Make another StorageClass the default first. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", request.Name))
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	ret = admissionctl.Allowed(fmt.Sprintf("StorageClass %s is the default", other))
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// isDefault returns true if the StorageClass is marked as the default
+func isDefault(storageClass *storagev1.StorageClass) bool {
+	return storageClass.Annotations[defaultClassAnnotation] == "true" || storageClass.Annotations[betaDefaultClassAnnotation] == "true"
+}
+
+// otherDefault returns the name of a default StorageClass other than name, if
+// there is one
+func (s *StorageClassWebhook) otherDefault(ctx context.Context, name string) (string, error) {
+	var err error
+	if s.kubeClient == nil {
+		s.kubeClient, err = k8sutil.KubeClient(s.s)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	storageClasses := &storagev1.StorageClassList{}
+	if err := s.kubeClient.List(ctx, storageClasses); err != nil {
+		return "", err
+	}
+	for i := range storageClasses.Items {
+		storageClass := &storageClasses.Items[i]
+		if storageClass.Name != name && storageClass.DeletionTimestamp == nil && isDefault(storageClass) {
+			return storageClass.Name, nil
+		}
+	}
+	return "", nil
+}
+
+// GetURI implements Webhook interface
+func (s *StorageClassWebhook) GetURI() string { return "/" + WebhookName }
+
+// Validate implements Webhook interface
+func (s *StorageClassWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	valid = valid && (request.Kind.Kind == "StorageClass")
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *StorageClassWebhook) Name() string { return WebhookName }
+
+// FailurePolicy implements Webhook interface
+func (s *StorageClassWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *StorageClassWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *StorageClassWebhook) Rules() []admissionregv1.RuleWithOperations { return rules }
+
+// ObjectSelector implements Webhook interface. Only managed StorageClasses
+// are protected.
+func (s *StorageClassWebhook) ObjectSelector() *metav1.LabelSelector {
+	return &metav1.LabelSelector{
+		MatchLabels: map[string]string{
+			managedLabel: "true",
+		},
+	}
+}
+
+// SideEffects implements Webhook interface
+func (s *StorageClassWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *StorageClassWebhook) TimeoutSeconds() int32 { return 2 }
+
+// Doc implements Webhook interface
+func (s *StorageClassWebhook) Doc() string {
+	return fmt.Sprintf(docString, managedLabel)
+}
+
+// RuleDocs implements Webhook interface
+func (s *StorageClassWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers may not delete managed StorageClasses, which have a \"hive.openshift.io/managed\": \"true\" label, nor remove that label.",
+			Exceptions: []string{utils.AdminsException},
+		},
+		{
+			Summary:    "Customers may not make the managed default StorageClass no longer the default unless another StorageClass is the default.",
+			Exceptions: []string{utils.AdminsException},
+		},
+	}
+}
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+func (s *StorageClassWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// ClassicEnabled implements Webhook interface
+func (s *StorageClassWebhook) ClassicEnabled() bool { return true }
+
+// HypershiftEnabled implements Webhook interface
+func (s *StorageClassWebhook) HypershiftEnabled() bool { return true }
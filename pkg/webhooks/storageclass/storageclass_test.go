@@ -0,0 +1,139 @@
+package storageclass
+
+import (
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+)
+
+func newStorageClass(name string, managed, isDefault bool) *storagev1.StorageClass {
+	storageClass := &storagev1.StorageClass{
+		ObjectMeta:  metav1.ObjectMeta{Name: name},
+		Provisioner: "ebs.csi.aws.com",
+	}
+	if managed {
+		storageClass.Labels = map[string]string{managedLabel: "true"}
+	}
+	if isDefault {
+		storageClass.Annotations = map[string]string{defaultClassAnnotation: "true"}
+	}
+	return storageClass
+}
+
+func newRequest(t *testing.T, operation admissionv1.Operation, username string, groups []string, oldObj, obj *storagev1.StorageClass) admissionctl.Request {
+	t.Helper()
+	gvk := metav1.GroupVersionKind{Group: "storage.k8s.io", Version: "v1", Kind: "StorageClass"}
+	return testutils.NewRequest(t, gvk, operation, authenticationv1.UserInfo{Username: username, Groups: groups}, "", oldObj.Name, obj, oldObj)
+}
+
+func TestAuthorized(t *testing.T) {
+	tests := []struct {
+		name      string
+		operation admissionv1.Operation
+		username  string
+		groups    []string
+		oldObj    *storagev1.StorageClass
+		obj       *storagev1.StorageClass
+		existing  []client.Object
+		allowed   bool
+	}{
+		{
+			name:      "customer deletes managed StorageClass",
+			operation: admissionv1.Delete,
+			username:  "customer",
+			oldObj:    newStorageClass("gp3-csi", true, true),
+			allowed:   false,
+		},
+		{
+			name:      "customer deletes own StorageClass",
+			operation: admissionv1.Delete,
+			username:  "customer",
+			oldObj:    newStorageClass("fast", false, false),
+			allowed:   true,
+		},
+		{
+			name:      "SRE deletes managed StorageClass",
+			operation: admissionv1.Delete,
+			username:  "backplane-cluster-admin",
+			oldObj:    newStorageClass("gp3-csi", true, true),
+			allowed:   true,
+		},
+		{
+			name:      "cluster-storage-operator deletes managed StorageClass",
+			operation: admissionv1.Delete,
+			username:  "system:serviceaccount:openshift-cluster-storage-operator:cluster-storage-operator",
+			groups:    []string{"system:serviceaccounts", "system:serviceaccounts:openshift-cluster-storage-operator"},
+			oldObj:    newStorageClass("gp3-csi", true, true),
+			allowed:   true,
+		},
+		{
+			name:      "customer removes default without another default",
+			operation: admissionv1.Update,
+			username:  "customer",
+			oldObj:    newStorageClass("gp3-csi", true, true),
+			obj:       newStorageClass("gp3-csi", true, false),
+			existing:  []client.Object{newStorageClass("gp3-csi", true, true), newStorageClass("fast", false, false)},
+			allowed:   false,
+		},
+		{
+			name:      "customer removes default with another default",
+			operation: admissionv1.Update,
+			username:  "customer",
+			oldObj:    newStorageClass("gp3-csi", true, true),
+			obj:       newStorageClass("gp3-csi", true, false),
+			existing:  []client.Object{newStorageClass("gp3-csi", true, true), newStorageClass("fast", false, true)},
+			allowed:   true,
+		},
+		{
+			name:      "customer removes default from unmanaged StorageClass",
+			operation: admissionv1.Update,
+			username:  "customer",
+			oldObj:    newStorageClass("fast", false, true),
+			obj:       newStorageClass("fast", false, false),
+			allowed:   true,
+		},
+		{
+			name:      "customer edits managed default keeping it the default",
+			operation: admissionv1.Update,
+			username:  "customer",
+			oldObj:    newStorageClass("gp3-csi", true, true),
+			obj:       newStorageClass("gp3-csi", true, true),
+			allowed:   true,
+		},
+		{
+			name:      "customer unlabels managed StorageClass",
+			operation: admissionv1.Update,
+			username:  "customer",
+			oldObj:    newStorageClass("gp2-csi", true, false),
+			obj:       newStorageClass("gp2-csi", false, false),
+			allowed:   false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := NewWebhook()
+			s.InjectClient(fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(test.existing...).Build())
+			request := newRequest(t, test.operation, test.username, test.groups, test.oldObj, test.obj)
+			if !s.Validate(request) {
+				t.Fatalf("Expected the request to be valid")
+			}
+			response := s.Authorized(request)
+			if response.Allowed != test.allowed {
+				t.Errorf("Expected allowed %t, got %t: %s", test.allowed, response.Allowed, response.Result.Message)
+			}
+			if response.UID != "test-uid" {
+				t.Errorf("Expected the response UID to be set")
+			}
+		})
+	}
+}
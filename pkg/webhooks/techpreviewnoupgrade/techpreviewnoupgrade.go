@@ -6,6 +6,7 @@ import (
 	"os"
 
 	configv1 "github.com/openshift/api/config/v1"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
 	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionregv1 "k8s.io/api/admissionregistration/v1"
@@ -48,6 +49,15 @@ func (s *TechPreviewNoUpgradeWebhook) Doc() string {
 	return fmt.Sprintf(docString)
 }
 
+// RuleDocs implements Webhook interface
+func (s *TechPreviewNoUpgradeWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary: "Customers may not enable the TechPreviewNoUpgrade FeatureGate.",
+		},
+	}
+}
+
 func (s *TechPreviewNoUpgradeWebhook) TimeoutSeconds() int32 { return 1 }
 
 func (s *TechPreviewNoUpgradeWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
@@ -84,6 +94,18 @@ func (s *TechPreviewNoUpgradeWebhook) SyncSetLabelSelector() metav1.LabelSelecto
 	return utils.DefaultLabelSelector()
 }
 
+// Validations implements PolicyWebhook interface
+func (s *TechPreviewNoUpgradeWebhook) Validations() []admissionregv1.Validation {
+	reason := metav1.StatusReasonForbidden
+	return []admissionregv1.Validation{
+		{
+			Expression: `!has(object.spec) || !has(object.spec.featureSet) || object.spec.featureSet != "TechPreviewNoUpgrade"`,
+			Message:    "The TechPreviewNoUpgrade Feature Gate is not allowed",
+			Reason:     &reason,
+		},
+	}
+}
+
 func (s *TechPreviewNoUpgradeWebhook) ClassicEnabled() bool { return true }
 
 func (s *TechPreviewNoUpgradeWebhook) HypershiftEnabled() bool { return true }
@@ -118,7 +140,7 @@ func (s *TechPreviewNoUpgradeWebhook) authorized(request admissionctl.Request) a
 	if featureGate != nil && featureGate.Spec.FeatureSet == "TechPreviewNoUpgrade" {
 		log.Info("Not allowing access because of TechPreviewNoUpgrade Feature Gate", "request", request.AdmissionRequest)
 
-		ret = admissionctl.Denied("The TechPreviewNoUpgrade Feature Gate is not allowed")
+		ret = response.Denied(response.TechPreviewFeatureSet, "The TechPreviewNoUpgrade Feature Gate is not allowed")
 		ret.UID = request.AdmissionRequest.UID
 
 		return ret
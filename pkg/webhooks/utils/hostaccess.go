@@ -0,0 +1,58 @@
+package utils
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// HostAccessLabelPrefix prefixes the labels SRE set to "true" on a customer
+	// namespace to allow its pods one kind of host access, eg.
+	// hostaccess.managed.openshift.io/hostNetwork
+	HostAccessLabelPrefix string = "hostaccess.managed.openshift.io/"
+	// PrivilegedSCCAnnotation is set to "true" by SRE on a customer namespace
+	// to allow any privileged pod in it, including pods using the host
+	PrivilegedSCCAnnotation string = "managed.openshift.io/allow-privileged-scc"
+
+	HostNetwork string = "hostNetwork"
+	HostPID     string = "hostPID"
+	HostIPC     string = "hostIPC"
+	HostPath    string = "hostPath"
+)
+
+// HostAccessKinds are the kinds of host access a namespace can be allowed
+var HostAccessKinds = []string{HostNetwork, HostPID, HostIPC, HostPath}
+
+// HostAccessLabels returns the labels allowing each kind of host access
+func HostAccessLabels() []string {
+	labels := make([]string, 0, len(HostAccessKinds))
+	for _, kind := range HostAccessKinds {
+		labels = append(labels, HostAccessLabelPrefix+kind)
+	}
+	return labels
+}
+
+// PodHostAccess returns the kinds of host access the pod uses
+func PodHostAccess(pod *corev1.Pod) []string {
+	access := []string{}
+	if pod.Spec.HostNetwork {
+		access = append(access, HostNetwork)
+	}
+	if pod.Spec.HostPID {
+		access = append(access, HostPID)
+	}
+	if pod.Spec.HostIPC {
+		access = append(access, HostIPC)
+	}
+	for _, volume := range pod.Spec.Volumes {
+		if volume.HostPath != nil {
+			access = append(access, HostPath)
+			break
+		}
+	}
+	return access
+}
+
+// HostAccessAllowed checks the namespace labels allow the kind of host access
+func HostAccessAllowed(namespaceLabels map[string]string, kind string) bool {
+	return namespaceLabels[HostAccessLabelPrefix+kind] == "true"
+}
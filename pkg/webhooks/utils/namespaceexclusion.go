@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NamespaceNameLabel is set by the API server on every namespace to its name,
+// so namespaceSelectors can select namespaces by name
+const NamespaceNameLabel string = "kubernetes.io/metadata.name"
+
+// exactNamespaceRe matches the patterns which match a single namespace name
+var exactNamespaceRe = regexp.MustCompile(`^\^[a-z0-9]([-a-z0-9]*[a-z0-9])?\$$`)
+
+// NamespaceExclusion is the platform namespaces a webhook which only polices
+// customer namespaces skips. The same exclusion is rendered into the
+// webhook's namespaceSelector and checked in code, so the webhook skips the
+// same namespaces whether or not the API server calls it for them.
+type NamespaceExclusion struct {
+	// Namespaces are regular expressions matching the excluded namespaces
+	Namespaces []string
+	// Exceptions are regular expressions matching namespaces which aren't
+	// excluded even though they match Namespaces
+	Exceptions []string
+}
+
+// Except returns a copy of the exclusion which doesn't exclude the namespaces
+// matching the patterns
+func (e NamespaceExclusion) Except(patterns ...string) NamespaceExclusion {
+	return NamespaceExclusion{
+		Namespaces: e.Namespaces,
+		Exceptions: append(slices.Clone(e.Exceptions), patterns...),
+	}
+}
+
+// Excludes returns true if the webhook should skip the namespace. Requests
+// for cluster-scoped objects, which have no namespace, are never excluded.
+func (e NamespaceExclusion) Excludes(namespace string) bool {
+	if namespace == "" {
+		return false
+	}
+	return RegexSliceContains(namespace, e.Namespaces) && !RegexSliceContains(namespace, e.Exceptions)
+}
+
+// ExactNames returns the excluded namespaces matched by a pattern matching a
+// single name, in the order of the patterns
+func (e NamespaceExclusion) ExactNames() []string {
+	names := []string{}
+	for _, pattern := range e.Namespaces {
+		if !exactNamespaceRe.MatchString(pattern) {
+			continue
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(pattern, "^"), "$")
+		if e.Excludes(name) && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// MatchExpressions renders the exclusion as namespaceSelector requirements.
+// Only patterns matching a single name can be rendered, so the API server
+// may still call the webhook for other excluded namespaces, which Excludes
+// then skips in code. It returns nil if no pattern can be rendered.
+func (e NamespaceExclusion) MatchExpressions() []metav1.LabelSelectorRequirement {
+	names := e.ExactNames()
+	if len(names) == 0 {
+		return nil
+	}
+	return []metav1.LabelSelectorRequirement{
+		{
+			Key:      NamespaceNameLabel,
+			Operator: metav1.LabelSelectorOpNotIn,
+			Values:   names,
+		},
+	}
+}
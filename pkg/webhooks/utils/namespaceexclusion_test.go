@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNamespaceExclusion(t *testing.T) {
+	exclusion := NamespaceExclusion{Namespaces: []string{"^default$", "^kube-.*", "^openshift-logging$", "^openshift-monitoring$", "^default$"}}
+	tests := []struct {
+		namespace string
+		excluded  bool
+	}{
+		{"default", true},
+		{"kube-system", true},
+		{"openshift-logging", true},
+		{"openshift-monitoring", true},
+		{"customer", false},
+		{"my-default", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		if excluded := exclusion.Excludes(test.namespace); excluded != test.excluded {
+			t.Errorf("Expected Excludes(%q) to be %t, got %t", test.namespace, test.excluded, excluded)
+		}
+	}
+
+	// patterns matching more than one name are only checked in code, and
+	// duplicates are rendered once
+	expected := []metav1.LabelSelectorRequirement{
+		{Key: NamespaceNameLabel, Operator: metav1.LabelSelectorOpNotIn, Values: []string{"default", "openshift-logging", "openshift-monitoring"}},
+	}
+	if requirements := exclusion.MatchExpressions(); !reflect.DeepEqual(requirements, expected) {
+		t.Errorf("Expected %v, got %v", expected, requirements)
+	}
+
+	// exceptions are neither excluded in code nor by the selector
+	excepted := exclusion.Except("openshift-logging")
+	if excepted.Excludes("openshift-logging") {
+		t.Errorf("Expected the excepted namespace not to be excluded")
+	}
+	if !exclusion.Excludes("openshift-logging") {
+		t.Errorf("Expected Except not to change the original exclusion")
+	}
+	expected[0].Values = []string{"default", "openshift-monitoring"}
+	if requirements := excepted.MatchExpressions(); !reflect.DeepEqual(requirements, expected) {
+		t.Errorf("Expected %v, got %v", expected, requirements)
+	}
+
+	if requirements := (NamespaceExclusion{Namespaces: []string{"^kube-.*"}}).MatchExpressions(); requirements != nil {
+		t.Errorf("Expected no requirements when no pattern can be rendered, got %v", requirements)
+	}
+}
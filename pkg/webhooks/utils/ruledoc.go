@@ -0,0 +1,24 @@
+package utils
+
+// Exceptions shared by many webhooks, worded for customer-facing documentation
+const (
+	// AdminsException is for webhooks allowing identity.IsAdmin users
+	AdminsException string = "Red Hat SRE, the cluster's built-in administrators and the service accounts of the platform and managed operators"
+	// SREException is for webhooks allowing identity.IsSRE users
+	SREException string = "Red Hat SRE"
+	// PrivilegedServiceAccountsException is for webhooks allowing
+	// identity.IsPrivilegedServiceAccount users
+	PrivilegedServiceAccountsException string = "The service accounts of the platform and managed operators"
+	// SystemUsersException is for webhooks allowing all system: and kube: users
+	SystemUsersException string = "Kubernetes and OpenShift system users, including kube:admin"
+)
+
+// RuleDoc documents one rule a webhook enforces, for the customer-facing
+// documentation generated from the webhooks
+type RuleDoc struct {
+	// Summary says what the rule denies, or what it changes for mutating
+	// webhooks, from the customer's point of view
+	Summary string `json:"summary"`
+	// Exceptions lists who or what the rule doesn't apply to
+	Exceptions []string `json:"exceptions,omitempty"`
+}
@@ -4,9 +4,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"regexp"
 	"slices"
+	"strconv"
+	"strings"
+	"sync"
 
 	admissionv1 "k8s.io/api/admission/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -26,8 +30,21 @@ const (
 )
 
 var (
+	// ErrUnsupportedContentType is returned parsing requests which aren't JSON
+	ErrUnsupportedContentType = errors.New("unsupported content type")
+	// ErrRequestTooLarge is returned parsing requests whose body is larger than
+	// the caller allows
+	ErrRequestTooLarge = errors.New("request body too large")
+	// ErrMalformedReview is returned parsing requests whose body isn't an
+	// AdmissionReview with a request
+	ErrMalformedReview = errors.New("malformed AdmissionReview")
+
 	admissionScheme = runtime.NewScheme()
 	admissionCodecs = serializer.NewCodecFactory(admissionScheme)
+
+	// compiledRegexes caches the patterns compiled by RegexSliceContains, which
+	// is called on every request with the same few patterns
+	compiledRegexes sync.Map
 )
 
 func RequestMatchesGroupKind(req admissionctl.Request, kind, group string) bool {
@@ -58,51 +75,85 @@ func IsProtectedByResourceName(name string) bool {
 	return slices.Contains(protectedNames, name)
 }
 
+// CELStringList renders strings as a CEL list literal, eg ["a", "b"]
+func CELStringList(strs []string) string {
+	quoted := make([]string, len(strs))
+	for i, str := range strs {
+		quoted[i] = strconv.Quote(str)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
 func RegexSliceContains(needle string, haystack []string) bool {
 	for _, check := range haystack {
-		checkRe := regexp.MustCompile(check)
-		if checkRe.Match([]byte(needle)) {
+		if compiledRegex(check).MatchString(needle) {
 			return true
 		}
 	}
 	return false
 }
 
+// compiledRegex returns the compiled pattern, compiling it only once
+func compiledRegex(pattern string) *regexp.Regexp {
+	if re, ok := compiledRegexes.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+	re, _ := compiledRegexes.LoadOrStore(pattern, regexp.MustCompile(pattern))
+	return re.(*regexp.Regexp)
+}
+
+// ParseHTTPRequest reads the AdmissionReview in the request body. Its errors
+// wrap ErrUnsupportedContentType, ErrRequestTooLarge or ErrMalformedReview so
+// callers can tell the client what was wrong with the request. Callers should
+// bound the body, eg with http.MaxBytesReader, as it's read in full.
 func ParseHTTPRequest(r *http.Request) (admissionctl.Request, admissionctl.Response, error) {
 	var resp admissionctl.Response
 	var req admissionctl.Request
 	var err error
 	var body []byte
+	// check the content type before reading what may be a large body
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != validContentType {
+		err := fmt.Errorf("%w: contentType=%s, expected %s", ErrUnsupportedContentType, r.Header.Get("Content-Type"), validContentType)
+		resp = admissionctl.Errored(http.StatusUnsupportedMediaType, err)
+		return req, resp, err
+	}
 	if r.Body != nil {
 		if body, err = io.ReadAll(r.Body); err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				err = fmt.Errorf("%w: request body is larger than %d bytes", ErrRequestTooLarge, maxBytesErr.Limit)
+				resp = admissionctl.Errored(http.StatusRequestEntityTooLarge, err)
+				return req, resp, err
+			}
 			resp = admissionctl.Errored(http.StatusBadRequest, err)
 			return req, resp, err
 		}
 	} else {
-		err := errors.New("request body is nil")
+		err := fmt.Errorf("%w: request body is nil", ErrMalformedReview)
 		resp = admissionctl.Errored(http.StatusBadRequest, err)
 		return req, resp, err
 	}
 	if len(body) == 0 {
-		err := errors.New("request body is empty")
-		resp = admissionctl.Errored(http.StatusBadRequest, err)
-		return req, resp, err
-	}
-	contentType := r.Header.Get("Content-Type")
-	if contentType != validContentType {
-		err := fmt.Errorf("contentType=%s, expected application/json", contentType)
+		err := fmt.Errorf("%w: request body is empty", ErrMalformedReview)
 		resp = admissionctl.Errored(http.StatusBadRequest, err)
 		return req, resp, err
 	}
 	ar := admissionv1.AdmissionReview{}
 	if _, _, err := admissionCodecs.UniversalDeserializer().Decode(body, nil, &ar); err != nil {
+		err = fmt.Errorf("%w: %w", ErrMalformedReview, err)
 		resp = admissionctl.Errored(http.StatusBadRequest, err)
 		return req, resp, err
 	}
 
 	// Copy for tracking
 	if ar.Request == nil {
-		err = fmt.Errorf("No request in request body")
+		err = fmt.Errorf("%w: no request in request body", ErrMalformedReview)
+		resp = admissionctl.Errored(http.StatusBadRequest, err)
+		return req, resp, err
+	}
+	// the API server matches responses to requests by UID
+	if ar.Request.UID == "" {
+		err = fmt.Errorf("%w: request has no uid", ErrMalformedReview)
 		resp = admissionctl.Errored(http.StatusBadRequest, err)
 		return req, resp, err
 	}
@@ -1,6 +1,10 @@
 package utils
 
 import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	admissionv1 "k8s.io/api/admission/v1"
@@ -55,3 +59,103 @@ func TestRequestMatchesGroupKind(t *testing.T) {
 		})
 	}
 }
+
+func TestCELStringList(t *testing.T) {
+	tests := []struct {
+		name     string
+		strs     []string
+		expected string
+	}{
+		{
+			name:     "empty",
+			strs:     []string{},
+			expected: "[]",
+		},
+		{
+			name:     "quoted",
+			strs:     []string{"kube:admin", `a"b`},
+			expected: `["kube:admin", "a\"b"]`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := CELStringList(test.strs); actual != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestRegexSliceContains(t *testing.T) {
+	haystack := []string{"^openshift-.*", "^kube-system$"}
+	for _, test := range []struct {
+		needle   string
+		expected bool
+	}{
+		{"openshift-monitoring", true},
+		{"kube-system", true},
+		{"kube-system-2", false},
+		{"customer", false},
+	} {
+		// Twice, to use the compiled patterns
+		for i := 0; i < 2; i++ {
+			if actual := RegexSliceContains(test.needle, haystack); actual != test.expected {
+				t.Errorf("Expected RegexSliceContains(%q) to be %v, got %v", test.needle, test.expected, actual)
+			}
+		}
+	}
+}
+
+func BenchmarkRegexSliceContains(b *testing.B) {
+	haystack := []string{"^openshift-.*", "^kube-.*", "^default$", "^redhat-.*"}
+	b.ReportAllocs()
+	for b.Loop() {
+		RegexSliceContains("customer", haystack)
+	}
+}
+
+func TestParseHTTPRequest(t *testing.T) {
+	review := `{"apiVersion":"admission.k8s.io/v1","kind":"AdmissionReview","request":{"uid":"test-uid","kind":{"group":"","version":"v1","kind":"Pod"},"operation":"CREATE","userInfo":{"username":"user"}}}`
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		maxBytes    int64
+		err         error
+	}{
+		{name: "valid", contentType: "application/json", body: review},
+		{name: "content type with charset", contentType: "application/json; charset=utf-8", body: review},
+		{name: "yaml", contentType: "application/yaml", body: review, err: ErrUnsupportedContentType},
+		{name: "no content type", body: review, err: ErrUnsupportedContentType},
+		{name: "too large", contentType: "application/json", body: review, maxBytes: 64, err: ErrRequestTooLarge},
+		{name: "empty", contentType: "application/json", err: ErrMalformedReview},
+		{name: "not json", contentType: "application/json", body: "{not json", err: ErrMalformedReview},
+		{name: "no request", contentType: "application/json", body: `{"apiVersion":"admission.k8s.io/v1","kind":"AdmissionReview"}`, err: ErrMalformedReview},
+		{name: "no uid", contentType: "application/json", body: strings.Replace(review, `"uid":"test-uid",`, "", 1), err: ErrMalformedReview},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(test.body))
+			if test.contentType != "" {
+				r.Header.Set("Content-Type", test.contentType)
+			}
+			if test.maxBytes > 0 {
+				r.Body = http.MaxBytesReader(httptest.NewRecorder(), r.Body, test.maxBytes)
+			}
+			request, response, err := ParseHTTPRequest(r)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("Expected error %v, got %v", test.err, err)
+			}
+			if test.err != nil {
+				if response.Result == nil || response.Result.Message == "" {
+					t.Errorf("Expected an errored response explaining the error")
+				}
+				return
+			}
+			if request.UID != "test-uid" || response.UID != "test-uid" {
+				t.Errorf("Expected the request and response UID to be test-uid, got %s and %s", request.UID, response.UID)
+			}
+		})
+	}
+}
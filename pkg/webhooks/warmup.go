@@ -0,0 +1,32 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// WarmUp warms up the webhooks implementing WarmUpWebhook, so the first
+// requests don't pay for initializing them within their timeout. It returns
+// once every webhook is warmed up or ctx is done, with the errors of those
+// which failed.
+func (r RegisteredWebhooks) WarmUp(ctx context.Context) error {
+	names := make([]string, 0, len(r))
+	for name := range r {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	errs := []error{}
+	for _, name := range names {
+		hook, ok := r[name]().(WarmUpWebhook)
+		if !ok {
+			continue
+		}
+		if err := hook.WarmUp(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,43 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/hiveownership"
+)
+
+// warmUpHook is a webhook which counts its warm-ups
+type warmUpHook struct {
+	*hiveownership.HiveOwnershipWebhook
+	warmUps *int
+	err     error
+}
+
+func (h *warmUpHook) WarmUp(context.Context) error {
+	*h.warmUps++
+	return h.err
+}
+
+func TestWarmUp(t *testing.T) {
+	warm, failing := 0, 0
+	hooks := RegisteredWebhooks{
+		"warm-validation": func() Webhook {
+			return &warmUpHook{HiveOwnershipWebhook: hiveownership.NewWebhook(), warmUps: &warm}
+		},
+		"failing-validation": func() Webhook {
+			return &warmUpHook{HiveOwnershipWebhook: hiveownership.NewWebhook(), warmUps: &failing, err: errors.New("registry config not served")}
+		},
+		hiveownership.WebhookName: func() Webhook { return hiveownership.NewWebhook() },
+	}
+
+	err := hooks.WarmUp(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "failing-validation: registry config not served") {
+		t.Fatalf("Expected the failing webhook's error, got %v", err)
+	}
+	if warm != 1 || failing != 1 {
+		t.Errorf("Expected each webhook to be warmed up once, got %d and %d", warm, failing)
+	}
+}
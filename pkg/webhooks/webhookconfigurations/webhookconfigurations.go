@@ -0,0 +1,286 @@
+package webhookconfigurations
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	hookconfig "github.com/openshift/managed-cluster-validating-webhooks/pkg/config"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "webhookconfigurations-validation"
+	docString   string = `Managed OpenShift customers may not create or update Validating or MutatingWebhookConfigurations whose webhooks fail closed, with a timeout over %d seconds, on requests in platform namespaces or for nodes and namespaces, as an unavailable webhook would then make the whole cluster unavailable. Configurations named like the managed webhooks' (%s*) are reserved. Red Hat SRE can grant an exception with the %s annotation.`
+
+	// MaxTimeoutSeconds is the longest timeout of a webhook which fails closed
+	// on platform requests. The API server defaults an unset timeoutSeconds to
+	// 10.
+	MaxTimeoutSeconds int32 = 5
+
+	// ExceptionAnnotation exempts a webhook configuration. Its value should
+	// say why the exception was granted. It's only honoured when SRE set it.
+	ExceptionAnnotation string = "managed.openshift.io/webhook-configuration-exception"
+
+	// reservedPrefix prefixes the names of the managed webhooks'
+	// configurations
+	reservedPrefix string = "sre-"
+)
+
+var (
+	scope = admissionregv1.ClusterScope
+	rules = []admissionregv1.RuleWithOperations{
+		{
+			Operations: []admissionregv1.OperationType{admissionregv1.Create, admissionregv1.Update},
+			Rule: admissionregv1.Rule{
+				APIGroups:   []string{"admissionregistration.k8s.io"},
+				APIVersions: []string{"*"},
+				Resources:   []string{"validatingwebhookconfigurations", "mutatingwebhookconfigurations"},
+				Scope:       &scope,
+			},
+		},
+	}
+	log = logf.Log.WithName(WebhookName)
+)
+
+// webhookConfiguration holds the fields validating and mutating webhook
+// configurations share
+type webhookConfiguration struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Webhooks          []admissionregv1.ValidatingWebhook `json:"webhooks,omitempty"`
+}
+
+// WebhookConfigurationsWebhook denies webhook configurations which could make
+// the cluster unavailable
+type WebhookConfigurationsWebhook struct{}
+
+// NewWebhook creates a new webhook
+func NewWebhook() *WebhookConfigurationsWebhook {
+	return &WebhookConfigurationsWebhook{}
+}
+
+// Authorized implements Webhook interface
+func (s *WebhookConfigurationsWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.authorized(request)
+}
+
+func (s *WebhookConfigurationsWebhook) authorized(request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if identity.IsAdmin(request.UserInfo) {
+		ret = admissionctl.Allowed("Admins and managed service accounts may create any webhook configuration")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	config := &webhookConfiguration{}
+	if err := json.Unmarshal(request.Object.Raw, config); err != nil {
+		log.Error(err, "Couldn't decode the webhook configuration from the request")
+		ret = admissionctl.Errored(http.StatusBadRequest, err)
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	if strings.HasPrefix(config.Name, reservedPrefix) {
+		log.Info("Denying webhook configuration with a reserved name", "kind", request.Kind.Kind, "name", config.Name, "user", request.UserInfo.Username)
+		ret = response.Denied(response.ManagedResource, fmt.Sprintf("Prevented from %s %s %s: names starting with %s are reserved for the webhooks Red Hat manages. Please choose another name. If you have any questions about this, please reach out to Red Hat support at https://access.redhat.com/support", verb(request.Operation), request.Kind.Kind, config.Name, reservedPrefix))
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	// Only SRE can grant the exception, so customers may neither add nor
+	// change it, and it's only honoured once SRE have set it
+	if exception, ok := config.Annotations[ExceptionAnnotation]; ok {
+		granted, wasGranted := "", false
+		if request.Operation == admissionv1.Update {
+			old := &metav1.PartialObjectMetadata{}
+			if err := json.Unmarshal(request.OldObject.Raw, old); err != nil {
+				log.Error(err, "Couldn't decode the old webhook configuration from the request")
+				ret = admissionctl.Errored(http.StatusBadRequest, err)
+				ret.UID = request.AdmissionRequest.UID
+				return ret
+			}
+			granted, wasGranted = old.Annotations[ExceptionAnnotation]
+		}
+		if !wasGranted || granted != exception {
+			log.Info("Denying webhook configuration setting the exception annotation", "kind", request.Kind.Kind, "name", config.Name, "user", request.UserInfo.Username)
+			ret = response.Denied(response.ManagedResource, fmt.Sprintf("Prevented from %s %s %s: only Red Hat SRE may set its %s annotation. If the webhook must fail closed, please reach out to Red Hat support at https://access.redhat.com/support to request an exception", verb(request.Operation), request.Kind.Kind, config.Name, ExceptionAnnotation))
+			ret.UID = request.AdmissionRequest.UID
+			return ret
+		}
+		ret = admissionctl.Allowed(fmt.Sprintf("Red Hat SRE granted the webhook configuration an exception: %s", exception))
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	for _, hook := range config.Webhooks {
+		intercepted := interceptedPlatformRequests(hook)
+		if intercepted == "" || !failsClosed(hook) {
+			continue
+		}
+		log.Info("Denying webhook configuration which fails closed on platform requests", "kind", request.Kind.Kind, "name", config.Name, "webhook", hook.Name, "intercepts", intercepted, "user", request.UserInfo.Username)
+		ret = response.Denied(response.FailClosedWebhookConfiguration, fmt.Sprintf("Prevented from %s %s %s: its webhook %s fails closed on %s, so the cluster would become unavailable whenever the webhook is. Set failurePolicy: Ignore or a timeoutSeconds of at most %d, or exclude platform namespaces with a namespaceSelector on %s. If the webhook must fail closed, please reach out to Red Hat support at https://access.redhat.com/support to request an exception", verb(request.Operation), request.Kind.Kind, config.Name, hook.Name, intercepted, MaxTimeoutSeconds, utils.NamespaceNameLabel))
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	ret = admissionctl.Allowed("The webhook configuration doesn't fail closed on platform requests")
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// verb describes the operation in the denial message
+func verb(operation admissionv1.Operation) string {
+	if operation == admissionv1.Update {
+		return "updating"
+	}
+	return "creating"
+}
+
+// failsClosed returns true if the webhook rejects requests when it's
+// unavailable, without a short timeout bounding how long they wait for it
+func failsClosed(hook admissionregv1.ValidatingWebhook) bool {
+	if hook.FailurePolicy != nil && *hook.FailurePolicy == admissionregv1.Ignore {
+		return false
+	}
+	return hook.TimeoutSeconds == nil || *hook.TimeoutSeconds > MaxTimeoutSeconds
+}
+
+// interceptedPlatformRequests describes the requests the platform depends on
+// the webhook intercepts, or returns "" if it intercepts none. The webhook's
+// namespaceSelector is matched against the name label of the platform
+// namespaces, as other namespace labels aren't known here.
+func interceptedPlatformRequests(hook admissionregv1.ValidatingWebhook) string {
+	namespace, err := platformNamespace(hook.NamespaceSelector)
+	if err != nil {
+		// The API server rejects invalid selectors itself
+		return ""
+	}
+	for _, rule := range hook.Rules {
+		var ruleScope admissionregv1.ScopeType = admissionregv1.AllScopes
+		if rule.Scope != nil {
+			ruleScope = *rule.Scope
+		}
+		if ruleScope != admissionregv1.ClusterScope && namespace != "" {
+			return fmt.Sprintf("requests in the platform namespace %s", namespace)
+		}
+		if ruleScope == admissionregv1.NamespacedScope || !matchesAny(rule.APIGroups, "") {
+			continue
+		}
+		for _, resource := range rule.Resources {
+			resource, _, _ = strings.Cut(resource, "/")
+			switch {
+			case resource == "*" || resource == "nodes":
+				return "requests for nodes"
+			case resource == "namespaces" && namespace != "":
+				return fmt.Sprintf("requests for the platform namespace %s", namespace)
+			}
+		}
+	}
+	return ""
+}
+
+// platformNamespace returns the first platform namespace the selector
+// matches, or "" if it matches none
+func platformNamespace(namespaceSelector *metav1.LabelSelector) (string, error) {
+	// A webhook without a namespaceSelector is called for every namespace
+	if namespaceSelector == nil {
+		return "kube-system", nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(namespaceSelector)
+	if err != nil {
+		return "", err
+	}
+	for _, namespace := range append([]string{"kube-system"}, hookconfig.ExcludedNamespaces.ExactNames()...) {
+		if selector.Matches(labels.Set{utils.NamespaceNameLabel: namespace}) {
+			return namespace, nil
+		}
+	}
+	return "", nil
+}
+
+// matchesAny returns true if values holds value or the "*" wildcard
+func matchesAny(values []string, value string) bool {
+	return slices.Contains(values, value) || slices.Contains(values, "*")
+}
+
+// GetURI implements Webhook interface
+func (s *WebhookConfigurationsWebhook) GetURI() string { return "/" + WebhookName }
+
+// Validate implements Webhook interface
+func (s *WebhookConfigurationsWebhook) Validate(request admissionctl.Request) bool {
+	valid := true
+	valid = valid && (request.UserInfo.Username != "")
+	valid = valid && (len(request.Object.Raw) > 0)
+	valid = valid && (request.Operation != admissionv1.Update || len(request.OldObject.Raw) > 0)
+
+	return valid
+}
+
+// Name implements Webhook interface
+func (s *WebhookConfigurationsWebhook) Name() string { return WebhookName }
+
+// FailurePolicy implements Webhook interface
+func (s *WebhookConfigurationsWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *WebhookConfigurationsWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface
+func (s *WebhookConfigurationsWebhook) Rules() []admissionregv1.RuleWithOperations { return rules }
+
+// ObjectSelector implements Webhook interface
+func (s *WebhookConfigurationsWebhook) ObjectSelector() *metav1.LabelSelector { return nil }
+
+// SideEffects implements Webhook interface
+func (s *WebhookConfigurationsWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *WebhookConfigurationsWebhook) TimeoutSeconds() int32 { return 2 }
+
+// Doc implements Webhook interface
+func (s *WebhookConfigurationsWebhook) Doc() string {
+	return fmt.Sprintf(docString, MaxTimeoutSeconds, reservedPrefix, ExceptionAnnotation)
+}
+
+// RuleDocs implements Webhook interface
+func (s *WebhookConfigurationsWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    fmt.Sprintf("Customers may not create or update webhook configurations whose webhooks fail closed, with a timeout over %d seconds, on requests in platform namespaces or for nodes and namespaces.", MaxTimeoutSeconds),
+			Exceptions: []string{utils.SREException, "The cluster's built-in administrators", "Platform and managed service accounts", fmt.Sprintf("Configurations SRE annotated with %s", ExceptionAnnotation)},
+		},
+		{
+			Summary:    fmt.Sprintf("Customers may not create or update webhook configurations named %s*, which are reserved for the managed webhooks.", reservedPrefix),
+			Exceptions: []string{utils.SREException, "The cluster's built-in administrators", "Platform and managed service accounts"},
+		},
+	}
+}
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+func (s *WebhookConfigurationsWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// ClassicEnabled implements Webhook interface
+func (s *WebhookConfigurationsWebhook) ClassicEnabled() bool { return true }
+
+// HypershiftEnabled implements Webhook interface
+func (s *WebhookConfigurationsWebhook) HypershiftEnabled() bool { return true }
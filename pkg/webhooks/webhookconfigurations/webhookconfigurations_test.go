@@ -0,0 +1,253 @@
+package webhookconfigurations
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+)
+
+var validatingKind = metav1.GroupVersionKind{Group: "admissionregistration.k8s.io", Version: "v1", Kind: "ValidatingWebhookConfiguration"}
+
+// podWebhook returns a webhook on pods, which fails closed with the default
+// timeout unless changed
+func podWebhook(change func(hook *admissionregv1.ValidatingWebhook)) admissionregv1.ValidatingWebhook {
+	hook := admissionregv1.ValidatingWebhook{
+		Name:           "pods.example.com",
+		FailurePolicy:  ptr.To(admissionregv1.Fail),
+		TimeoutSeconds: ptr.To(int32(10)),
+		Rules: []admissionregv1.RuleWithOperations{
+			{
+				Operations: []admissionregv1.OperationType{admissionregv1.Create},
+				Rule: admissionregv1.Rule{
+					APIGroups:   []string{""},
+					APIVersions: []string{"v1"},
+					Resources:   []string{"pods"},
+				},
+			},
+		},
+	}
+	if change != nil {
+		change(&hook)
+	}
+	return hook
+}
+
+func configuration(t *testing.T, name string, annotations map[string]string, hooks ...admissionregv1.ValidatingWebhook) []byte {
+	t.Helper()
+	raw, err := json.Marshal(admissionregv1.ValidatingWebhookConfiguration{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "admissionregistration.k8s.io/v1", Kind: "ValidatingWebhookConfiguration"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: annotations},
+		Webhooks:   hooks,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error marshalling the configuration: %v", err)
+	}
+	return raw
+}
+
+func TestAuthorized(t *testing.T) {
+	customerNamespaces := &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: utils.NamespaceNameLabel, Operator: metav1.LabelSelectorOpIn, Values: []string{"payments"}},
+		},
+	}
+	exception := map[string]string{ExceptionAnnotation: "OHSS-1234"}
+
+	tests := []struct {
+		name      string
+		operation admissionv1.Operation
+		username  string
+		old       []byte
+		obj       []byte
+		allowed   bool
+		message   string
+	}{
+		{
+			name:      "fails closed in every namespace",
+			operation: admissionv1.Create,
+			username:  "customer",
+			obj:       configuration(t, "policy", nil, podWebhook(nil)),
+			allowed:   false,
+			message:   "its webhook pods.example.com fails closed on requests in the platform namespace kube-system",
+		},
+		{
+			name:      "fails closed without a timeout",
+			operation: admissionv1.Create,
+			username:  "customer",
+			obj: configuration(t, "policy", nil, podWebhook(func(hook *admissionregv1.ValidatingWebhook) {
+				hook.FailurePolicy, hook.TimeoutSeconds = nil, nil
+			})),
+			allowed: false,
+		},
+		{
+			name:      "fails closed on nodes",
+			operation: admissionv1.Create,
+			username:  "customer",
+			obj: configuration(t, "policy", nil, podWebhook(func(hook *admissionregv1.ValidatingWebhook) {
+				hook.NamespaceSelector = customerNamespaces
+				hook.Rules[0].Resources = []string{"nodes/status"}
+			})),
+			allowed: false,
+			message: "fails closed on requests for nodes",
+		},
+		{
+			name:      "fails closed in customer namespaces",
+			operation: admissionv1.Create,
+			username:  "customer",
+			obj: configuration(t, "policy", nil, podWebhook(func(hook *admissionregv1.ValidatingWebhook) {
+				hook.NamespaceSelector = customerNamespaces
+			})),
+			allowed: true,
+		},
+		{
+			name:      "excludes platform namespaces by label",
+			operation: admissionv1.Create,
+			username:  "customer",
+			obj: configuration(t, "policy", nil, podWebhook(func(hook *admissionregv1.ValidatingWebhook) {
+				hook.NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"policy.example.com/enforce": "true"}}
+			})),
+			allowed: true,
+		},
+		{
+			name:      "fails open",
+			operation: admissionv1.Create,
+			username:  "customer",
+			obj: configuration(t, "policy", nil, podWebhook(func(hook *admissionregv1.ValidatingWebhook) {
+				hook.FailurePolicy = ptr.To(admissionregv1.Ignore)
+			})),
+			allowed: true,
+		},
+		{
+			name:      "fails closed with a short timeout",
+			operation: admissionv1.Create,
+			username:  "customer",
+			obj: configuration(t, "policy", nil, podWebhook(func(hook *admissionregv1.ValidatingWebhook) {
+				hook.TimeoutSeconds = ptr.To(MaxTimeoutSeconds)
+			})),
+			allowed: true,
+		},
+		{
+			name:      "reserved name",
+			operation: admissionv1.Create,
+			username:  "customer",
+			obj: configuration(t, "sre-pod-validation", nil, podWebhook(func(hook *admissionregv1.ValidatingWebhook) {
+				hook.FailurePolicy = ptr.To(admissionregv1.Ignore)
+			})),
+			allowed: false,
+			message: "names starting with sre- are reserved",
+		},
+		{
+			name:      "exception granted by SRE",
+			operation: admissionv1.Update,
+			username:  "customer",
+			old:       configuration(t, "policy", exception, podWebhook(nil)),
+			obj:       configuration(t, "policy", exception, podWebhook(nil)),
+			allowed:   true,
+		},
+		{
+			name:      "exception added by the customer",
+			operation: admissionv1.Update,
+			username:  "customer",
+			old:       configuration(t, "policy", nil, podWebhook(nil)),
+			obj:       configuration(t, "policy", exception, podWebhook(nil)),
+			allowed:   false,
+			message:   "only Red Hat SRE may set its " + ExceptionAnnotation + " annotation",
+		},
+		{
+			name:      "exception changed by the customer",
+			operation: admissionv1.Update,
+			username:  "customer",
+			old:       configuration(t, "policy", exception, podWebhook(nil)),
+			obj:       configuration(t, "policy", map[string]string{ExceptionAnnotation: "OHSS-5678"}, podWebhook(nil)),
+			allowed:   false,
+		},
+		{
+			name:      "exception removed by the customer",
+			operation: admissionv1.Update,
+			username:  "customer",
+			old:       configuration(t, "policy", exception, podWebhook(nil)),
+			obj:       configuration(t, "policy", nil, podWebhook(nil)),
+			allowed:   false,
+			message:   "fails closed",
+		},
+		{
+			name:      "exception set by the customer on create",
+			operation: admissionv1.Create,
+			username:  "customer",
+			obj: configuration(t, "policy", exception, podWebhook(func(hook *admissionregv1.ValidatingWebhook) {
+				hook.FailurePolicy = ptr.To(admissionregv1.Ignore)
+			})),
+			allowed: false,
+			message: "only Red Hat SRE may set its " + ExceptionAnnotation + " annotation",
+		},
+		{
+			name:      "exception set by SRE on create",
+			operation: admissionv1.Create,
+			username:  "backplane-cluster-admin",
+			obj:       configuration(t, "policy", exception, podWebhook(nil)),
+			allowed:   true,
+		},
+		{
+			name:      "sre",
+			operation: admissionv1.Create,
+			username:  "backplane-cluster-admin",
+			obj:       configuration(t, "sre-pod-validation", nil, podWebhook(nil)),
+			allowed:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hook := NewWebhook()
+			request := testutils.NewRequest(t, validatingKind, test.operation, authenticationv1.UserInfo{Username: test.username}, "", "", test.obj, test.old)
+			if !hook.Validate(request) {
+				t.Fatalf("Expected request to be valid")
+			}
+			response := hook.Authorized(request)
+			if response.Allowed != test.allowed {
+				t.Fatalf("Expected allowed %v, got %v: %v", test.allowed, response.Allowed, response.Result)
+			}
+			if response.UID != request.UID {
+				t.Fatalf("Expected UID %s, got %s", request.UID, response.UID)
+			}
+			if test.message != "" && !strings.Contains(response.Result.Message, test.message) {
+				t.Errorf("Expected the message to contain %q, got %s", test.message, response.Result.Message)
+			}
+		})
+	}
+}
+
+// TestExceptionCreateThenUpdate checks customers can't set the exception on a
+// configuration which fails open and then update it to fail closed
+func TestExceptionCreateThenUpdate(t *testing.T) {
+	exception := map[string]string{ExceptionAnnotation: "OHSS-1234"}
+	failsOpen := func(hook *admissionregv1.ValidatingWebhook) {
+		hook.FailurePolicy = ptr.To(admissionregv1.Ignore)
+	}
+	request := func(operation admissionv1.Operation, old, obj []byte) admissionctl.Request {
+		return testutils.NewRequest(t, validatingKind, operation, authenticationv1.UserInfo{Username: "customer"}, "", "", obj, old)
+	}
+	hook := NewWebhook()
+
+	if response := hook.Authorized(request(admissionv1.Create, nil, configuration(t, "policy", exception, podWebhook(failsOpen)))); response.Allowed {
+		t.Fatalf("Expected creating a configuration with the exception to be denied")
+	}
+
+	created := configuration(t, "policy", nil, podWebhook(failsOpen))
+	if response := hook.Authorized(request(admissionv1.Create, nil, created)); !response.Allowed {
+		t.Fatalf("Expected creating a configuration which fails open to be allowed: %v", response.Result)
+	}
+	if response := hook.Authorized(request(admissionv1.Update, created, configuration(t, "policy", exception, podWebhook(nil)))); response.Allowed {
+		t.Fatalf("Expected updating the configuration to fail closed with the exception to be denied")
+	}
+}
@@ -0,0 +1,262 @@
+package webhookpolicy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/apis/managed/v1alpha1"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/identity"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/k8sutil"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/response"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/webhooks/utils"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	WebhookName string = "webhookpolicy-validation"
+	docString   string = `Enforces the WebhookPolicies on the cluster, which deny changes to the resources they select unless made by the users and groups they except. SRE ship WebhookPolicies as data to add simple rules without a new webhook.`
+)
+
+var (
+	log = logf.Log.WithName(WebhookName)
+
+	mu       sync.RWMutex
+	policies []v1alpha1.WebhookPolicy
+)
+
+// SetPolicies replaces the WebhookPolicies the webhook enforces
+func SetPolicies(p []v1alpha1.WebhookPolicy) {
+	mu.Lock()
+	defer mu.Unlock()
+	policies = p
+}
+
+func currentPolicies() []v1alpha1.WebhookPolicy {
+	mu.RLock()
+	defer mu.RUnlock()
+	return policies
+}
+
+// Operations returns the admission operations of the policy's verbs
+func Operations(policy v1alpha1.WebhookPolicy) []admissionregv1.OperationType {
+	operations := []admissionregv1.OperationType{}
+	for _, verb := range policy.Spec.Verbs {
+		operation := admissionregv1.OperationType(strings.ToUpper(verb))
+		if !slices.Contains(operations, operation) {
+			operations = append(operations, operation)
+		}
+	}
+	return operations
+}
+
+// WebhookPolicyWebhook enforces WebhookPolicies
+type WebhookPolicyWebhook struct {
+	s          *runtime.Scheme
+	kubeClient client.Client
+}
+
+// NewWebhook creates a new webhook
+func NewWebhook() *WebhookPolicyWebhook {
+	scheme := runtime.NewScheme()
+	err := admissionv1.AddToScheme(scheme)
+	if err != nil {
+		log.Error(err, "Fail adding admissionv1 scheme to WebhookPolicyWebhook")
+		os.Exit(1)
+	}
+	err = corev1.AddToScheme(scheme)
+	if err != nil {
+		log.Error(err, "Fail adding corev1 scheme to WebhookPolicyWebhook")
+		os.Exit(1)
+	}
+
+	return &WebhookPolicyWebhook{
+		s: scheme,
+	}
+}
+
+// InjectClient implements ClientWebhook interface
+func (s *WebhookPolicyWebhook) InjectClient(c client.Client) {
+	s.kubeClient = c
+}
+
+// CachedObjects implements ClientWebhook interface. Namespaces are read for
+// the policies' namespace selectors.
+func (s *WebhookPolicyWebhook) CachedObjects() []client.Object {
+	return []client.Object{&corev1.Namespace{}}
+}
+
+// Authorized implements Webhook interface
+func (s *WebhookPolicyWebhook) Authorized(request admissionctl.Request) admissionctl.Response {
+	return s.authorized(context.Background(), request)
+}
+
+// AuthorizedContext implements ContextWebhook interface
+func (s *WebhookPolicyWebhook) AuthorizedContext(ctx context.Context, request admissionctl.Request) admissionctl.Response {
+	return s.authorized(ctx, request)
+}
+
+func (s *WebhookPolicyWebhook) authorized(ctx context.Context, request admissionctl.Request) admissionctl.Response {
+	var ret admissionctl.Response
+
+	if identity.IsAdmin(request.UserInfo) {
+		ret = admissionctl.Allowed("Admins and managed service accounts are excepted from WebhookPolicies")
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	var namespaceLabels labels.Set
+	for _, policy := range currentPolicies() {
+		if !matchesRequest(policy, request) || isExcepted(policy, request) {
+			continue
+		}
+		if policy.Spec.NamespaceSelector != nil {
+			if request.Namespace == "" {
+				continue
+			}
+			if namespaceLabels == nil {
+				var err error
+				namespaceLabels, err = s.namespaceLabels(ctx, request.Namespace)
+				if err != nil {
+					// Fail open, as the webhook's FailurePolicy does
+					log.Error(err, "Failed to read namespace for WebhookPolicy namespace selectors", "namespace", request.Namespace)
+					ret = admissionctl.Allowed("Unable to check namespace for WebhookPolicies")
+					ret.UID = request.AdmissionRequest.UID
+					return ret
+				}
+			}
+			selector, err := metav1.LabelSelectorAsSelector(policy.Spec.NamespaceSelector)
+			if err != nil {
+				log.Error(err, "Ignoring WebhookPolicy with invalid namespace selector", "policy", policy.Name)
+				continue
+			}
+			if !selector.Matches(namespaceLabels) {
+				continue
+			}
+		}
+
+		log.Info("Denying request by WebhookPolicy", "policy", policy.Name, "resource", request.Resource.Resource, "namespace", request.Namespace, "name", request.Name, "user", request.UserInfo.Username)
+		ret = response.Denied(response.WebhookPolicy, fmt.Sprintf("%s (WebhookPolicy %s)", policy.Spec.Message, policy.Name))
+		ret.UID = request.AdmissionRequest.UID
+		return ret
+	}
+
+	ret = admissionctl.Allowed("No WebhookPolicy denies the request")
+	ret.UID = request.AdmissionRequest.UID
+	return ret
+}
+
+// matchesRequest checks the policy selects the request's resource and verb
+func matchesRequest(policy v1alpha1.WebhookPolicy, request admissionctl.Request) bool {
+	return matches(policy.Spec.APIGroups, request.Resource.Group) &&
+		matches(policy.Spec.Resources, request.Resource.Resource) &&
+		matches(policy.Spec.Verbs, strings.ToLower(string(request.Operation)))
+}
+
+// matches checks value is one of values, or values contains "*"
+func matches(values []string, value string) bool {
+	return slices.Contains(values, "*") || slices.Contains(values, value)
+}
+
+// isExcepted checks the policy excepts the request's user
+func isExcepted(policy v1alpha1.WebhookPolicy, request admissionctl.Request) bool {
+	if slices.Contains(policy.Spec.Exceptions.Users, request.UserInfo.Username) {
+		return true
+	}
+	for _, group := range request.UserInfo.Groups {
+		if slices.Contains(policy.Spec.Exceptions.Groups, group) {
+			return true
+		}
+	}
+	return false
+}
+
+// namespaceLabels returns the labels of the namespace
+func (s *WebhookPolicyWebhook) namespaceLabels(ctx context.Context, namespace string) (labels.Set, error) {
+	var err error
+	if s.kubeClient == nil {
+		s.kubeClient, err = k8sutil.KubeClient(s.s)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ns := &corev1.Namespace{}
+	if err := s.kubeClient.Get(ctx, client.ObjectKey{Name: namespace}, ns); err != nil {
+		return nil, err
+	}
+	return labels.Set(ns.Labels), nil
+}
+
+// GetURI implements Webhook interface
+func (s *WebhookPolicyWebhook) GetURI() string { return "/" + WebhookName }
+
+// Validate implements Webhook interface
+func (s *WebhookPolicyWebhook) Validate(request admissionctl.Request) bool {
+	return request.UserInfo.Username != ""
+}
+
+// Name implements Webhook interface
+func (s *WebhookPolicyWebhook) Name() string { return WebhookName }
+
+// FailurePolicy implements Webhook interface
+func (s *WebhookPolicyWebhook) FailurePolicy() admissionregv1.FailurePolicyType {
+	return admissionregv1.Ignore
+}
+
+// MatchPolicy implements Webhook interface
+func (s *WebhookPolicyWebhook) MatchPolicy() admissionregv1.MatchPolicyType {
+	return admissionregv1.Equivalent
+}
+
+// Rules implements Webhook interface. The webhook's configuration is managed
+// on the cluster from the WebhookPolicies, so it has no static rules and
+// isn't part of the SyncSet.
+func (s *WebhookPolicyWebhook) Rules() []admissionregv1.RuleWithOperations { return nil }
+
+// ObjectSelector implements Webhook interface
+func (s *WebhookPolicyWebhook) ObjectSelector() *metav1.LabelSelector { return nil }
+
+// SideEffects implements Webhook interface
+func (s *WebhookPolicyWebhook) SideEffects() admissionregv1.SideEffectClass {
+	return admissionregv1.SideEffectClassNone
+}
+
+// TimeoutSeconds implements Webhook interface
+func (s *WebhookPolicyWebhook) TimeoutSeconds() int32 { return 2 }
+
+// Doc implements Webhook interface
+func (s *WebhookPolicyWebhook) Doc() string { return docString }
+
+// SyncSetLabelSelector returns the label selector to use in the SyncSet.
+func (s *WebhookPolicyWebhook) SyncSetLabelSelector() metav1.LabelSelector {
+	return utils.DefaultLabelSelector()
+}
+
+// RuleDocs implements Webhook interface
+func (s *WebhookPolicyWebhook) RuleDocs() []utils.RuleDoc {
+	return []utils.RuleDoc{
+		{
+			Summary:    "Customers may not change resources selected by a WebhookPolicy.",
+			Exceptions: []string{utils.AdminsException, "The users and groups the WebhookPolicy excepts"},
+		},
+	}
+}
+
+// ClassicEnabled implements Webhook interface
+func (s *WebhookPolicyWebhook) ClassicEnabled() bool { return true }
+
+// HypershiftEnabled implements Webhook interface. The WebhookPolicy CRD is
+// only shipped to Classic clusters.
+func (s *WebhookPolicyWebhook) HypershiftEnabled() bool { return false }
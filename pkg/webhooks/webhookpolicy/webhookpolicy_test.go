@@ -0,0 +1,179 @@
+package webhookpolicy
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/apis/managed/v1alpha1"
+	"github.com/openshift/managed-cluster-validating-webhooks/pkg/testutils"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	admissionctl "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func newRequest(t *testing.T, group, resource string, operation admissionv1.Operation, namespace, username string, groups []string) admissionctl.Request {
+	request := testutils.NewRequest(t, metav1.GroupVersionKind{}, operation, authenticationv1.UserInfo{Username: username, Groups: groups}, namespace, "test", nil, nil)
+	request.Resource = metav1.GroupVersionResource{Group: group, Version: "v1", Resource: resource}
+	return request
+}
+
+func newPolicy(name string, spec v1alpha1.WebhookPolicySpec) v1alpha1.WebhookPolicy {
+	return v1alpha1.WebhookPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       spec,
+	}
+}
+
+func TestAuthorized(t *testing.T) {
+	policies := []v1alpha1.WebhookPolicy{
+		newPolicy("protect-routes", v1alpha1.WebhookPolicySpec{
+			APIGroups: []string{"route.openshift.io"},
+			Resources: []string{"routes"},
+			Verbs:     []string{"delete"},
+			NamespaceSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"managed": "true"},
+			},
+			Exceptions: v1alpha1.WebhookPolicyExceptions{Groups: []string{"route-admins"}},
+			Message:    "Managed routes cannot be deleted",
+		}),
+		newPolicy("protect-console-config", v1alpha1.WebhookPolicySpec{
+			APIGroups:  []string{"*"},
+			Resources:  []string{"consoles"},
+			Verbs:      []string{"*"},
+			Exceptions: v1alpha1.WebhookPolicyExceptions{Users: []string{"console-operator"}},
+			Message:    "The console configuration is managed",
+		}),
+	}
+	namespaces := []*corev1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "managed", Labels: map[string]string{"managed": "true"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "customer"}},
+	}
+
+	tests := []struct {
+		name      string
+		group     string
+		resource  string
+		operation admissionv1.Operation
+		namespace string
+		username  string
+		groups    []string
+		allowed   bool
+		message   string
+	}{
+		{
+			name:      "deleting a route in a selected namespace",
+			group:     "route.openshift.io",
+			resource:  "routes",
+			operation: admissionv1.Delete,
+			namespace: "managed",
+			username:  "customer",
+			allowed:   false,
+			message:   "Managed routes cannot be deleted (WebhookPolicy protect-routes)",
+		},
+		{
+			name:      "updating a route in a selected namespace",
+			group:     "route.openshift.io",
+			resource:  "routes",
+			operation: admissionv1.Update,
+			namespace: "managed",
+			username:  "customer",
+			allowed:   true,
+		},
+		{
+			name:      "deleting a route in another namespace",
+			group:     "route.openshift.io",
+			resource:  "routes",
+			operation: admissionv1.Delete,
+			namespace: "customer",
+			username:  "customer",
+			allowed:   true,
+		},
+		{
+			name:      "deleting a route as an excepted group",
+			group:     "route.openshift.io",
+			resource:  "routes",
+			operation: admissionv1.Delete,
+			namespace: "managed",
+			username:  "customer",
+			groups:    []string{"route-admins"},
+			allowed:   true,
+		},
+		{
+			name:      "deleting a route as sre",
+			group:     "route.openshift.io",
+			resource:  "routes",
+			operation: admissionv1.Delete,
+			namespace: "managed",
+			username:  "backplane-cluster-admin",
+			allowed:   true,
+		},
+		{
+			name:      "changing a cluster-scoped resource in any group",
+			group:     "operator.openshift.io",
+			resource:  "consoles",
+			operation: admissionv1.Update,
+			username:  "customer",
+			allowed:   false,
+			message:   "The console configuration is managed",
+		},
+		{
+			name:      "changing a cluster-scoped resource as an excepted user",
+			group:     "operator.openshift.io",
+			resource:  "consoles",
+			operation: admissionv1.Update,
+			username:  "console-operator",
+			allowed:   true,
+		},
+		{
+			name:      "unselected resource",
+			group:     "",
+			resource:  "configmaps",
+			operation: admissionv1.Delete,
+			namespace: "managed",
+			username:  "customer",
+			allowed:   true,
+		},
+	}
+
+	SetPolicies(policies)
+	defer SetPolicies(nil)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hook := NewWebhook()
+			builder := fake.NewClientBuilder().WithScheme(hook.s)
+			for _, ns := range namespaces {
+				builder = builder.WithObjects(ns.DeepCopy())
+			}
+			hook.InjectClient(builder.Build())
+
+			request := newRequest(t, test.group, test.resource, test.operation, test.namespace, test.username, test.groups)
+			if !hook.Validate(request) {
+				t.Fatalf("Expected request to be valid")
+			}
+			response := hook.Authorized(request)
+			if response.Allowed != test.allowed {
+				t.Fatalf("Expected allowed %v, got %v: %v", test.allowed, response.Allowed, response.Result)
+			}
+			if response.UID != request.UID {
+				t.Fatalf("Expected UID %s, got %s", request.UID, response.UID)
+			}
+			if test.message != "" && !strings.Contains(response.Result.Message, test.message) {
+				t.Errorf("Expected the message to contain %q, got %s", test.message, response.Result.Message)
+			}
+		})
+	}
+}
+
+func TestOperations(t *testing.T) {
+	policy := newPolicy("test", v1alpha1.WebhookPolicySpec{Verbs: []string{"create", "Update", "update", "*"}})
+	expected := []admissionregv1.OperationType{admissionregv1.Create, admissionregv1.Update, admissionregv1.OperationAll}
+	if operations := Operations(policy); !reflect.DeepEqual(operations, expected) {
+		t.Fatalf("Expected %v, got %v", expected, operations)
+	}
+}